@@ -0,0 +1,458 @@
+// Command scalegraph-sim runs a simulated ScaleGraph network and prints a summary of its lookups.
+//
+//	scalegraph-sim -size 200 -drop 0.01 -churn 2 -duration 30s -lookups 500
+//	scalegraph-sim -size 50 -churn 1 -dot graph.dot && dot -Tsvg graph.dot > graph.svg
+//	scalegraph-sim -scenario experiment.json
+//	scalegraph-sim -scenario experiment.json -seed 42 -check-determinism
+//	scalegraph-sim -size 50 -console
+//	scalegraph-sim -size 50 -duration 10m -lookups 0 -http localhost:8080
+//	scalegraph-sim -bench -size 1000
+//	scalegraph-sim -size 200 -trace rpcs.jsonl -trace-sample 0.1
+//	scalegraph-sim -size 200 -seed 42 -replay rpcs.jsonl
+//	scalegraph-sim -sweep grid.json -format csv > results.csv
+//	scalegraph-sim -size 10000 -lookups 0 -snapshot converged.json
+//	scalegraph-sim -restore converged.json -lookups 500
+//	scalegraph-sim -size 500 -subnets 4 -nat
+//
+// With -console the cluster is handed to an interactive console on stdin instead of running
+// lookups, see kademlia.Simnet.Console. With -http the cluster is served over HTTP while it
+// runs, see package api. With -scenario the script decides the cluster, see package scenario, and the cluster flags are
+// ignored. The seed makes node ids and every random choice of the simulator reproducible, the
+// interleaving of goroutines is not. With -check-determinism the scenario is run twice with the
+// same seed and the first divergence between the runs is reported, see scenario.CheckDeterminism.
+// With -bench clusters of -size nodes and its halvings are formed one after the other and the
+// cost of joining, lookups and storing and finding values is measured in each, see package bench.
+// With -sweep every configuration of a JSON experiments.Grid is run once per seed and the results
+// are written to stdout in the given -format, see package experiments. With -trace the RPCs routed
+// while the lookups run are recorded to a file, see kademlia.Simnet.RecordTrace. With -replay the
+// requests of such a trace are routed through a cluster of -size nodes instead of running lookups,
+// use the seed of the recorded run to reproduce its nodes, see kademlia.Simnet.Replay. With
+// -snapshot the cluster is written to a file once it has formed, and with -restore such a file is
+// loaded instead of spawning a cluster, so a large cluster only has to converge once, see
+// kademlia.Simnet.Snapshot. With -subnets the nodes are spread over that many /16 subnets, every
+// other one behind a NAT with -nat, and the share of each subnet's routing table entries pointing
+// into the subnet is printed after the run, see kademlia.Simnet.SubnetLocality.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"main/src/api"
+	"main/src/bench"
+	"main/src/experiments"
+	"main/src/kademlia"
+	"main/src/scenario"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings of a simulation run.
+type config struct {
+	size      int
+	drop      float64
+	churn     float64
+	minUptime time.Duration
+	maxUptime time.Duration
+	duration  time.Duration
+	lookups   int
+	console   bool
+	http      string
+	dot       string
+	paths     int
+	recursive bool
+	trace     string
+	sample    float64
+	snapshot  string
+	restore   string
+	subnets   int
+	nat       bool
+}
+
+// Lookup statistics of a simulation run.
+type summary struct {
+	nodes     int
+	lookups   int
+	succeeded int
+	hops      int
+	rpcs      int
+	churned   int
+	converged int // disjoint lookups whose paths agreed, only counted with more than one path
+	paths     int
+	elapsed   time.Duration
+}
+
+func (sum summary) display() string {
+	res := fmt.Sprintf("ran for %v with %d live nodes, %d churned\n", sum.elapsed.Round(time.Millisecond), sum.nodes, sum.churned)
+	if sum.lookups == 0 {
+		return res + "no lookups\n"
+	}
+	res += fmt.Sprintf("lookup success rate: %.4f (%d/%d)\n", float64(sum.succeeded)/float64(sum.lookups), sum.succeeded, sum.lookups)
+	res += fmt.Sprintf("average hops: %.2f\n", float64(sum.hops)/float64(sum.lookups))
+	res += fmt.Sprintf("messages per lookup: %.2f\n", float64(sum.rpcs)/float64(sum.lookups))
+	if sum.paths > 1 {
+		res += fmt.Sprintf("lookups with converged paths: %.4f (%d/%d) over %d paths\n", float64(sum.converged)/float64(sum.lookups), sum.converged, sum.lookups, sum.paths)
+	}
+	return res
+}
+
+func main() {
+	cfg := config{}
+	flag.IntVar(&cfg.size, "size", 100, "nodes in the cluster, not counting the master node")
+	flag.Float64Var(&cfg.drop, "drop", 0, "probability that the simnet drops a RPC")
+	flag.Float64Var(&cfg.churn, "churn", 0, "nodes replaced per second, 0 disables churn")
+	flag.DurationVar(&cfg.minUptime, "min-uptime", 5*time.Second, "uptime before a node may be churned")
+	flag.DurationVar(&cfg.maxUptime, "max-uptime", time.Minute, "uptime after which a node is always churned")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long lookups are run for")
+	flag.IntVar(&cfg.lookups, "lookups", 200, "lookups between random pairs of live nodes, spread over the duration")
+	flag.BoolVar(&cfg.console, "console", false, "open an interactive console on the cluster instead of running lookups")
+	flag.StringVar(&cfg.http, "http", "", "address to serve the cluster's HTTP API on while it runs, empty disables it")
+	flag.IntVar(&cfg.paths, "paths", 1, "disjoint paths every lookup takes, see kademlia.Node.SetLookupPaths")
+	flag.BoolVar(&cfg.recursive, "recursive", false, "run recursive instead of iterative lookups, see kademlia.Node.FindNodeRecursive")
+	flag.StringVar(&cfg.dot, "dot", "", "file to write the cluster's routing tables to as a DOT graph after the run")
+	flag.StringVar(&cfg.snapshot, "snapshot", "", "file to write the cluster to once it has formed, see kademlia.Simnet.Snapshot")
+	flag.StringVar(&cfg.restore, "restore", "", "snapshot to load the cluster from instead of spawning -size nodes")
+	flag.IntVar(&cfg.subnets, "subnets", 0, "/16 subnets to spread the nodes over, 0 places them anywhere")
+	flag.BoolVar(&cfg.nat, "nat", false, "put every other subnet behind a NAT")
+	flag.StringVar(&cfg.trace, "trace", "", "file to record the RPCs routed during the lookups to, as CSV if it ends in .csv and JSONL otherwise")
+	flag.Float64Var(&cfg.sample, "trace-sample", 0, "share of the RPCs recorded by -trace, 0 records all of them")
+	seed := flag.Int64("seed", 0, "seed of the simulator's randomness, 0 picks one")
+	scenarioPath := flag.String("scenario", "", "JSON scenario to run instead of the cluster flags")
+	checkDeterminism := flag.Bool("check-determinism", false, "run the scenario twice with the same seed and fail if the runs diverge")
+	benchMode := flag.Bool("bench", false, "measure clusters of up to -size nodes instead of running lookups")
+	sweepPath := flag.String("sweep", "", "JSON parameter grid to sweep instead of running lookups")
+	format := flag.String("format", "csv", "output format of -sweep, csv or json")
+	replayPath := flag.String("replay", "", "trace whose requests are replayed instead of running lookups")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rand.Seed(*seed)
+	fmt.Printf("seed: %d\n", *seed)
+
+	if *scenarioPath != "" {
+		os.Exit(runScenario(*scenarioPath, *seed, *checkDeterminism))
+	}
+	if *sweepPath != "" {
+		if err := runSweep(*sweepPath, *format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	if *replayPath != "" {
+		if err := replay(cfg, *seed, *replayPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	if *benchMode {
+		fmt.Print(bench.Display(nil))
+		bench.Run(bench.Sizes(cfg.size), func(res bench.Result) { fmt.Print(res.Display()) })
+		return
+	}
+	sum, err := simulate(cfg, *seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !cfg.console {
+		fmt.Print(sum.display())
+	}
+}
+
+// Runs the scenario script at path and prints its report, the script's own seed wins over seed.
+// Returns the exit code, 1 if an assertion failed or the runs diverged when checking determinism
+// and 2 if the scenario could not be run.
+func runScenario(path string, seed int64, checkDeterminism bool) int {
+	script, err := scenario.LoadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if script.Seed == 0 {
+		script.Seed = seed
+	}
+	var report scenario.Report
+	if checkDeterminism {
+		report, err = scenario.CheckDeterminism(script)
+	} else {
+		report, err = scenario.Run(script)
+	}
+	var divergence *scenario.DivergenceError
+	if errors.As(err, &divergence) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Print(report.Display())
+	if checkDeterminism {
+		fmt.Println("both runs produced the same trace")
+	}
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
+
+// Sweeps the parameter grid at path, reporting each run on stderr as it completes, and writes the
+// results to stdout.
+func runSweep(path string, format string) error {
+	if format != "csv" && format != "json" {
+		return errors.New(fmt.Sprintf("unknown output format %q, expected csv or json", format))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	grid := experiments.Grid{}
+	if err := json.Unmarshal(data, &grid); err != nil {
+		return errors.New(fmt.Sprintf("invalid grid %s: %s", path, err.Error()))
+	}
+	results, err := experiments.Sweep(grid, func(run experiments.Run) {
+		fmt.Fprintf(os.Stderr, "k=%d alpha=%d fanout=%d drop=%v size=%d seed=%d: success %.3f, %.1f rpcs per lookup\n",
+			run.K, run.Alpha, run.Fanout, run.Drop, run.Size, run.Seed, run.Success, run.RPCs)
+	})
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		return experiments.WriteJSON(os.Stdout, results)
+	}
+	return experiments.WriteCSV(os.Stdout, results)
+}
+
+// Spawns the cluster and replays the trace at path through it, printing the requests answered
+// differently than when they were recorded.
+func replay(cfg config, seed int64, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	trace, err := kademlia.ReadTrace(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	s := kademlia.NewSeededServer(false, float32(cfg.drop), seed)
+	s.SetLogLevel(kademlia.LOG_SILENT)
+	go s.StartServer()
+	defer s.Shutdown()
+	done := make(chan struct{}, 1)
+	s.SpawnCluster(cfg.size, done)
+	<-done
+	report, err := s.Replay(trace)
+	if err != nil {
+		return err
+	}
+	for _, m := range report.Mismatches {
+		fmt.Printf("%s %s: recorded %s, replayed %q\n", m.Cmd, m.ID, m.Recorded, m.Replayed)
+	}
+	fmt.Printf("replayed %d requests, %d answered as recorded, %d differently, %d skipped\n",
+		report.Replayed, report.Matched, len(report.Mismatches), report.Skipped)
+	return nil
+}
+
+// Starts recording the simnet's RPCs to the file at path.
+// Returns a function that stops the recording and closes the file.
+func recordTrace(s *kademlia.Simnet, path string, sample float64) (func() error, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	opts := kademlia.TraceOptions{SampleRate: sample}
+	if strings.HasSuffix(path, ".csv") {
+		opts.Format = kademlia.TRACE_CSV
+	}
+	stop, err := s.RecordTrace(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() error {
+		err := stop()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// Returns the subnets configured by -subnets and -nat.
+func subnets(cfg config) []kademlia.Subnet {
+	res := make([]kademlia.Subnet, 0, cfg.subnets)
+	for i := range cfg.subnets {
+		res = append(res, kademlia.Subnet{Prefix: [4]byte{10, byte(i), 0, 0}, Bits: 16, NAT: cfg.nat && i%2 == 1})
+	}
+	return res
+}
+
+// Spawns the cluster, starts churn if configured and starts the lookups evenly spread over the
+// configured duration, a slow lookup does not hold back the ones after it.
+func simulate(cfg config, seed int64) (summary, error) {
+	if cfg.size < 1 || cfg.lookups < 0 || cfg.drop < 0 || cfg.drop > 1 || cfg.paths < 1 || cfg.paths > kademlia.MAX_LOOKUP_PATHS || (cfg.recursive && cfg.paths > 1) {
+		return summary{}, errors.New(fmt.Sprintf("invalid configuration: size %d, lookups %d, drop %v, paths %d, recursive %t", cfg.size, cfg.lookups, cfg.drop, cfg.paths, cfg.recursive))
+	}
+	s := kademlia.NewSeededServer(false, float32(cfg.drop), seed)
+	s.SetLogLevel(kademlia.LOG_SILENT)
+	if err := s.SetSubnets(subnets(cfg)...); err != nil {
+		return summary{}, err
+	}
+	if cfg.restore != "" {
+		nodes, err := s.Restore(cfg.restore)
+		if err != nil {
+			return summary{}, err
+		}
+		fmt.Printf("restored %d nodes from %s\n", len(nodes), cfg.restore)
+	}
+	go s.StartServer()
+	defer s.Shutdown()
+	if cfg.subnets > 0 {
+		defer func() {
+			for _, loc := range s.SubnetLocality() {
+				fmt.Println(loc.Display())
+			}
+		}()
+	}
+	if cfg.restore == "" {
+		done := make(chan struct{}, 1)
+		s.SpawnCluster(cfg.size, done)
+		<-done
+	}
+	if cfg.snapshot != "" {
+		if err := s.Snapshot(cfg.snapshot); err != nil {
+			return summary{}, err
+		}
+	}
+
+	if cfg.http != "" {
+		listener, err := net.Listen("tcp", cfg.http)
+		if err != nil {
+			return summary{}, err
+		}
+		server := &http.Server{Handler: api.NewSimnetHandler(s)}
+		go server.Serve(listener)
+		defer server.Close()
+		fmt.Printf("serving the HTTP API on http://%s\n", listener.Addr())
+	}
+
+	if cfg.console {
+		return summary{}, s.Console(os.Stdin, os.Stdout)
+	}
+
+	if cfg.trace != "" {
+		stop, err := recordTrace(s, cfg.trace, cfg.sample)
+		if err != nil {
+			return summary{}, err
+		}
+		defer func() {
+			if err := stop(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	events, cancel := s.Events().Subscribe(1 << 16)
+	defer cancel()
+	if cfg.churn > 0 {
+		err := s.StartChurn(cfg.churn, cfg.minUptime, cfg.maxUptime)
+		if err != nil {
+			return summary{}, err
+		}
+		defer s.StopChurn()
+	}
+
+	sum := summary{paths: cfg.paths}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	interval := time.Duration(0)
+	if cfg.lookups > 0 {
+		interval = cfg.duration / time.Duration(cfg.lookups)
+	}
+	for i := range cfg.lookups {
+		if wait := time.Duration(i)*interval - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+		nodes := s.AllNodePointers()
+		if len(nodes) < 2 {
+			break
+		}
+		from := nodes[rand.Intn(len(nodes))]
+		target := nodes[rand.Intn(len(nodes))]
+		for target == from {
+			target = nodes[rand.Intn(len(nodes))]
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var found []kademlia.Contact
+			var stats kademlia.LookupStats
+			converged := false
+			if cfg.paths > 1 {
+				from.SetLookupPaths(cfg.paths)
+				var report kademlia.DisjointLookup
+				found, report = from.FindNodeDisjoint(target.ID())
+				for _, path := range report.Stats {
+					stats.Hops = max(stats.Hops, path.Hops)
+					stats.RPCs += path.RPCs
+				}
+				converged = report.Converged
+			} else if cfg.recursive {
+				var report kademlia.RecursiveLookup
+				found, report, _ = from.FindNodeRecursive(target.ID())
+				stats = report.Stats
+			} else {
+				found, stats = from.FindNodeWithStats(target.ID())
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			sum.lookups++
+			sum.hops += stats.Hops
+			sum.rpcs += stats.RPCs
+			if converged {
+				sum.converged++
+			}
+			if len(found) > 0 && found[0].ID() == target.ID() {
+				sum.succeeded++
+			}
+		}()
+	}
+	wg.Wait()
+	if wait := cfg.duration - time.Since(start); wait > 0 {
+		time.Sleep(wait)
+	}
+	sum.elapsed = time.Since(start)
+	sum.nodes = len(s.AllNodePointers())
+	if cfg.dot != "" {
+		if err := writeDOT(s, cfg.dot); err != nil {
+			return summary{}, err
+		}
+	}
+	for len(events) > 0 {
+		if _, ok := (<-events).(kademlia.NodeChurned); ok {
+			sum.churned++
+		}
+	}
+	return sum, nil
+}
+
+// Writes the simnet's routing tables to path as a DOT graph with edges colored by bucket.
+func writeDOT(s *kademlia.Simnet, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return s.ExportDOTWithOptions(file, kademlia.DOTOptions{ColorByBucket: true, Labels: true})
+}
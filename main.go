@@ -3,9 +3,15 @@ package main
 import (
 	"fmt"
 	"main/src/kademlia"
+	"main/src/scenario"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		runScenario(os.Args[1])
+		return
+	}
 	testSize := 50
 	batchSize := 20
 	for i := testSize; i > 35; i -= 5 {
@@ -13,6 +19,24 @@ func main() {
 	}
 }
 
+// Runs the scenario script at path and prints its report, exits non-zero if the scenario failed.
+func runScenario(path string) {
+	script, err := scenario.LoadFile(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	report, err := scenario.Run(script)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	fmt.Print(report.Display())
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
+
 func testIteration(testSize int, batchSize int) {
 	testData := make([]int, kademlia.REPLICATION)
 	totalMisses := 0
@@ -0,0 +1,271 @@
+// Package api exposes nodes over HTTP, so external tooling and dashboards can drive and observe
+// them without linking Go code. Responses are JSON, errors are JSON objects with an error field.
+//
+// A node handler serves:
+//
+//	GET  /routing-table  contacts in the node's routing table
+//	POST /lookup/{id}    runs a node lookup for id
+//	POST /store          stores the request body as a value, responds with its key
+//	GET  /value/{key}    finds a stored value, responds with its raw bytes
+//	GET  /wallet/{id}    the wallet as reported by its validators
+//	POST /wallet         submits the WalletRequest in the body as a new wallet
+//	POST /transaction    proposes the JSON encoded, signed transaction in the body
+//
+// A simnet handler serves GET /nodes, the node routes of every live node under /nodes/{node}/,
+// where {node} is a prefix of the node's hex id, and the network graph:
+//
+//	GET  /topology       the live nodes and their routing table entries
+//	GET  /topology/feed  a WebSocket streaming the graph's changes and RPC traffic as FeedMessages
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"main/src/kademlia"
+	"main/src/scalegraph"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	MAX_VALUE_SIZE = 1 << 20 // largest body accepted by POST /store
+	MAX_JSON_SIZE  = 1 << 16 // largest body accepted by POST /wallet and POST /transaction
+)
+
+// A node in GET /nodes.
+type NodeInfo struct {
+	ID       kademlia.KademliaID `json:"id"`
+	IP       string              `json:"ip"`
+	Master   bool                `json:"master"`
+	Contacts int                 `json:"contacts"`
+}
+
+// A contact in GET /routing-table and POST /lookup/{id}.
+type ContactInfo struct {
+	ID           kademlia.KademliaID `json:"id"`
+	IP           string              `json:"ip"`
+	Bucket       int                 `json:"bucket,omitempty"`
+	Capabilities string              `json:"capabilities,omitempty"`
+}
+
+// The response to POST /lookup/{id}.
+type LookupResult struct {
+	Target   kademlia.KademliaID `json:"target"`
+	Found    bool                `json:"found"` // whether the closest contact is the target
+	Contacts []ContactInfo       `json:"contacts"`
+	Hops     int                 `json:"hops"`
+	RPCs     int                 `json:"rpcs"`
+	Failed   int                 `json:"failed"`
+	Duration string              `json:"duration"`
+}
+
+// The response to POST /store.
+type StoreResult struct {
+	Key kademlia.KademliaID `json:"key"`
+}
+
+// The response to GET /wallet/{id}.
+type WalletInfo struct {
+	ID           kademlia.KademliaID `json:"id"`
+	Balance      uint64              `json:"balance"`
+	Transactions int                 `json:"transactions"`
+	Nonce        uint64              `json:"nonce"`
+	Signed       bool                `json:"signed"` // whether spends must be signed
+}
+
+// The body of POST /wallet. Spends from a wallet submitted with a public key must be signed with
+// the matching private key.
+type WalletRequest struct {
+	ID        kademlia.KademliaID `json:"id"`
+	PublicKey []byte              `json:"publicKey,omitempty"`
+	Balance   uint64              `json:"balance"`
+}
+
+// Returns a handler serving the node routes of node.
+func NewNodeHandler(node *kademlia.Node) http.Handler {
+	mux := http.NewServeMux()
+	routeNode(mux, "", func(*http.Request) (*kademlia.Node, error) { return node, nil })
+	return mux
+}
+
+// Returns a handler serving GET /nodes and the node routes of every live node of simnet.
+func NewSimnetHandler(simnet *kademlia.Simnet) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /nodes", func(w http.ResponseWriter, r *http.Request) {
+		nodes := simnet.AllNodePointers()
+		slices.SortFunc(nodes, func(a, b *kademlia.Node) int { return a.ID().Cmp(b.ID()) })
+		master := simnet.MasterNode()
+		res := make([]NodeInfo, 0, len(nodes))
+		for _, n := range nodes {
+			res = append(res, NodeInfo{n.ID(), formatIP(n.IP()), n.ID() == master.ID(), n.Len()})
+		}
+		respond(w, http.StatusOK, res)
+	})
+	mux.HandleFunc("GET /topology", serveTopology(simnet))
+	mux.HandleFunc("GET /topology/feed", serveTopologyFeed(simnet))
+	routeNode(mux, "/nodes/{node}", func(r *http.Request) (*kademlia.Node, error) {
+		return simnet.NodeByPrefix(r.PathValue("node"))
+	})
+	return mux
+}
+
+// Registers the node routes under prefix, resolve picks the node a request is for.
+func routeNode(mux *http.ServeMux, prefix string, resolve func(r *http.Request) (*kademlia.Node, error)) {
+	handle := func(pattern string, serve func(w http.ResponseWriter, r *http.Request, node *kademlia.Node)) {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.HandleFunc(method+" "+prefix+path, func(w http.ResponseWriter, r *http.Request) {
+			node, err := resolve(r)
+			if err != nil {
+				fail(w, http.StatusNotFound, err)
+				return
+			}
+			serve(w, r, node)
+		})
+	}
+	handle("GET /routing-table", serveRoutingTable)
+	handle("POST /lookup/{id}", serveLookup)
+	handle("POST /store", serveStore)
+	handle("GET /value/{key}", serveValue)
+	handle("GET /wallet/{id}", serveWallet)
+	handle("POST /wallet", serveSubmitWallet)
+	handle("POST /transaction", serveTransaction)
+}
+
+func serveRoutingTable(w http.ResponseWriter, r *http.Request, node *kademlia.Node) {
+	contacts := node.AllContacts()
+	slices.SortFunc(contacts, func(a, b kademlia.Contact) int { return a.ID().Cmp(b.ID()) })
+	res := make([]ContactInfo, 0, len(contacts))
+	for _, con := range contacts {
+		info := ContactInfo{ID: con.ID(), IP: formatIP(con.IP())}
+		info.Bucket, _ = node.BucketIndex(con.ID())
+		if caps, ok := node.ContactCapabilities(con.ID()); ok {
+			info.Capabilities = caps.String()
+		}
+		res = append(res, info)
+	}
+	respond(w, http.StatusOK, res)
+}
+
+func serveLookup(w http.ResponseWriter, r *http.Request, node *kademlia.Node) {
+	target, err := parseID(r.PathValue("id"))
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	found, stats := node.FindNodeWithStats(target)
+	res := LookupResult{
+		Target:   target,
+		Found:    len(found) > 0 && found[0].ID() == target,
+		Contacts: make([]ContactInfo, 0, len(found)),
+		Hops:     stats.Hops,
+		RPCs:     stats.RPCs,
+		Failed:   stats.Failed,
+		Duration: stats.Duration.Round(time.Microsecond).String(),
+	}
+	for _, con := range found {
+		res.Contacts = append(res.Contacts, ContactInfo{ID: con.ID(), IP: formatIP(con.IP())})
+	}
+	respond(w, http.StatusOK, res)
+}
+
+func serveStore(w http.ResponseWriter, r *http.Request, node *kademlia.Node) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_VALUE_SIZE))
+	if err != nil {
+		fail(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if len(data) == 0 {
+		fail(w, http.StatusBadRequest, errors.New("empty value"))
+		return
+	}
+	key, err := node.StoreValue(data)
+	if err != nil {
+		fail(w, http.StatusBadGateway, err)
+		return
+	}
+	respond(w, http.StatusCreated, StoreResult{key})
+}
+
+func serveValue(w http.ResponseWriter, r *http.Request, node *kademlia.Node) {
+	key, err := parseID(r.PathValue("key"))
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := node.FindValue(key)
+	if err != nil {
+		fail(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func serveWallet(w http.ResponseWriter, r *http.Request, node *kademlia.Node) {
+	id, err := parseID(r.PathValue("id"))
+	if err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	wallet, err := node.ShowWallet(id)
+	if err != nil {
+		fail(w, http.StatusNotFound, err)
+		return
+	}
+	respond(w, http.StatusOK, WalletInfo{wallet.ID, wallet.Balance, wallet.Transactions, wallet.Nonce, wallet.PublicKey != nil})
+}
+
+func serveSubmitWallet(w http.ResponseWriter, r *http.Request, node *kademlia.Node) {
+	req := WalletRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_JSON_SIZE)).Decode(&req); err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := node.SubmitWalletWithKey(req.ID, req.PublicKey, req.Balance); err != nil {
+		fail(w, http.StatusBadGateway, err)
+		return
+	}
+	// an empty wallet is stored without an opening deposit
+	transactions := 0
+	if req.Balance > 0 {
+		transactions = 1
+	}
+	respond(w, http.StatusCreated, WalletInfo{req.ID, req.Balance, transactions, 0, req.PublicKey != nil})
+}
+
+func serveTransaction(w http.ResponseWriter, r *http.Request, node *kademlia.Node) {
+	trx := scalegraph.Transaction{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_JSON_SIZE)).Decode(&trx); err != nil {
+		fail(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := node.ProposeTransaction(&trx); err != nil {
+		fail(w, http.StatusConflict, err)
+		return
+	}
+	respond(w, http.StatusOK, map[string]bool{"committed": true})
+}
+
+func parseID(text string) (kademlia.KademliaID, error) {
+	var id kademlia.KademliaID
+	err := id.UnmarshalText([]byte(strings.ToLower(text)))
+	return id, err
+}
+
+func formatIP(ip [4]byte) string {
+	return fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], ip[3])
+}
+
+func respond(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func fail(w http.ResponseWriter, status int, err error) {
+	respond(w, status, map[string]string{"error": err.Error()})
+}
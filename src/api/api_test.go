@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"main/src/kademlia"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSimnetHandler(t *testing.T) {
+	testName := "TestSimnetHandler"
+	done := make(chan struct{}, 1)
+	s := kademlia.NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(8, done)
+	<-done
+	defer s.Shutdown()
+
+	server := httptest.NewServer(NewSimnetHandler(s))
+	defer server.Close()
+	request := func(method string, path string, body string, into any) int {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			log.Printf("[%s] - %s %s: %s", testName, method, path, err.Error())
+			t.FailNow()
+		}
+		defer resp.Body.Close()
+		if into != nil {
+			json.NewDecoder(resp.Body).Decode(into)
+		}
+		return resp.StatusCode
+	}
+
+	listed := make([]NodeInfo, 0)
+	if status := request("GET", "/nodes", "", &listed); status != http.StatusOK || len(listed) != 9 {
+		log.Printf("[%s] - expected 9 nodes, got status %d and %d nodes", testName, status, len(listed))
+		t.Fail()
+	}
+
+	from, target := nodes[0], nodes[1]
+	prefix := "/nodes/" + from.ID().String()[:12]
+	contacts := make([]ContactInfo, 0)
+	if status := request("GET", prefix+"/routing-table", "", &contacts); status != http.StatusOK || len(contacts) == 0 {
+		log.Printf("[%s] - expected a routing table, got status %d and %d contacts", testName, status, len(contacts))
+		t.Fail()
+	}
+
+	lookup := LookupResult{}
+	if status := request("POST", prefix+"/lookup/"+target.ID().String(), "", &lookup); status != http.StatusOK || !lookup.Found {
+		log.Printf("[%s] - lookup did not find its target, status %d: %+v", testName, status, lookup)
+		t.Fail()
+	}
+
+	stored := StoreResult{}
+	if status := request("POST", prefix+"/store", "hello", &stored); status != http.StatusCreated {
+		log.Printf("[%s] - store failed with status %d", testName, status)
+		t.FailNow()
+	}
+	resp, err := server.Client().Get(server.URL + "/nodes/" + target.ID().String() + "/value/" + stored.Key.String())
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	value, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(value) != "hello" {
+		log.Printf("[%s] - expected the stored value, got status %d and %q", testName, resp.StatusCode, value)
+		t.Fail()
+	}
+
+	wallet := kademlia.RandomID()
+	if err := from.SubmitWallet(wallet, 100); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	info := WalletInfo{}
+	if status := request("GET", prefix+"/wallet/"+wallet.String(), "", &info); status != http.StatusOK || info.Balance != 100 {
+		log.Printf("[%s] - expected a balance of 100, got status %d: %+v", testName, status, info)
+		t.Fail()
+	}
+
+	empty := kademlia.RandomID()
+	created := WalletInfo{}
+	if status := request("POST", prefix+"/wallet", fmt.Sprintf(`{"id": %q, "balance": 0}`, empty.String()), &created); status != http.StatusCreated {
+		log.Printf("[%s] - submitting an empty wallet failed with status %d", testName, status)
+		t.FailNow()
+	}
+	request("GET", prefix+"/wallet/"+empty.String(), "", &info)
+	if created.Transactions != 0 || created != info {
+		log.Printf("[%s] - created empty wallet %+v, stored %+v", testName, created, info)
+		t.Fail()
+	}
+
+	failures := map[string]int{
+		"/nodes/zz/routing-table":                         http.StatusNotFound,
+		prefix + "/wallet/nonsense":                       http.StatusBadRequest,
+		prefix + "/value/" + kademlia.RandomID().String(): http.StatusNotFound,
+	}
+	for path, expected := range failures {
+		failure := map[string]string{}
+		if status := request("GET", path, "", &failure); status != expected || failure["error"] == "" {
+			log.Printf("[%s] - GET %s: expected status %d with an error, got %d: %v", testName, path, expected, status, failure)
+			t.Fail()
+		}
+	}
+}
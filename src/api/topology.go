@@ -0,0 +1,173 @@
+package api
+
+import (
+	"main/src/kademlia"
+	"net/http"
+	"time"
+)
+
+const (
+	FEED_BUFFER = 1 << 14         // events a feed holds while its client catches up
+	FEED_RESYNC = 5 * time.Second // how often a feed resends the snapshot, repairing changes missed while its buffer was full
+)
+
+// A node of the network graph.
+type GraphNode struct {
+	ID     kademlia.KademliaID `json:"id"`
+	IP     string              `json:"ip"`
+	Master bool                `json:"master"`
+}
+
+// A routing table entry of the network graph: From has To in its routing table.
+type GraphEdge struct {
+	From kademlia.KademliaID `json:"from"`
+	To   kademlia.KademliaID `json:"to"`
+}
+
+// The response to GET /topology, and the snapshot sent by the topology feed.
+type TopologyInfo struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// A RPC delivered or dropped by the simnet.
+type RPCInfo struct {
+	Cmd      string               `json:"cmd"`
+	From     kademlia.KademliaID  `json:"from"`
+	To       *kademlia.KademliaID `json:"to,omitempty"` // missing if the receiver is not a live node
+	Response bool                 `json:"response"`
+	Dropped  bool                 `json:"dropped"`
+	Reason   string               `json:"reason,omitempty"`
+}
+
+// Types of topology feed messages.
+const (
+	FEED_SNAPSHOT     = "snapshot"
+	FEED_NODE_ADDED   = "node_added"
+	FEED_NODE_REMOVED = "node_removed"
+	FEED_EDGE_ADDED   = "edge_added"
+	FEED_EDGE_REMOVED = "edge_removed"
+	FEED_RPC          = "rpc"
+)
+
+// A message of the topology feed, only the field matching its Type is set.
+type FeedMessage struct {
+	Type     string        `json:"type"`
+	Topology *TopologyInfo `json:"topology,omitempty"`
+	Node     *GraphNode    `json:"node,omitempty"`
+	Edge     *GraphEdge    `json:"edge,omitempty"`
+	RPC      *RPCInfo      `json:"rpc,omitempty"`
+}
+
+// Translates simnet events to feed messages, resolving RPC receivers to node ids.
+type feed struct {
+	master kademlia.KademliaID
+	ids    map[[4]byte]kademlia.KademliaID
+}
+
+func newFeed(master kademlia.Contact) *feed {
+	return &feed{
+		master: master.ID(),
+		ids:    make(map[[4]byte]kademlia.KademliaID),
+	}
+}
+
+func (f *feed) node(con kademlia.Contact) *GraphNode {
+	return &GraphNode{con.ID(), formatIP(con.IP()), con.ID() == f.master}
+}
+
+// Returns the snapshot message of topology, and learns the ids of its nodes.
+func (f *feed) snapshot(topology kademlia.Topology) FeedMessage {
+	res := TopologyInfo{make([]GraphNode, 0, len(topology.Nodes)), make([]GraphEdge, 0, len(topology.Edges))}
+	clear(f.ids)
+	for _, con := range topology.Nodes {
+		f.ids[con.IP()] = con.ID()
+		res.Nodes = append(res.Nodes, *f.node(con))
+	}
+	for _, edge := range topology.Edges {
+		res.Edges = append(res.Edges, GraphEdge{edge.From, edge.To})
+	}
+	return FeedMessage{Type: FEED_SNAPSHOT, Topology: &res}
+}
+
+// Returns the feed message of event, false if the event is not part of the feed.
+func (f *feed) message(event kademlia.Event, traffic bool) (FeedMessage, bool) {
+	switch event := event.(type) {
+	case kademlia.NodeSpawned:
+		f.ids[event.Node.IP()] = event.Node.ID()
+		return FeedMessage{Type: FEED_NODE_ADDED, Node: f.node(event.Node)}, true
+	case kademlia.NodeShutdown:
+		delete(f.ids, event.Node.IP())
+		return FeedMessage{Type: FEED_NODE_REMOVED, Node: f.node(event.Node)}, true
+	case kademlia.ContactAdded:
+		return FeedMessage{Type: FEED_EDGE_ADDED, Edge: &GraphEdge{event.Node.ID(), event.Contact.ID()}}, true
+	case kademlia.ContactRemoved:
+		return FeedMessage{Type: FEED_EDGE_REMOVED, Edge: &GraphEdge{event.Node.ID(), event.Contact.ID()}}, true
+	case kademlia.RPCDelivered:
+		if traffic {
+			return FeedMessage{Type: FEED_RPC, RPC: f.rpc(event.Cmd, event.Sender, event.Receiver, event.Response, "")}, true
+		}
+	case kademlia.RPCDropped:
+		if traffic {
+			return FeedMessage{Type: FEED_RPC, RPC: f.rpc(event.Cmd, event.Sender, event.Receiver, event.Response, event.Reason)}, true
+		}
+	}
+	return FeedMessage{}, false
+}
+
+func (f *feed) rpc(cmd kademlia.Command, sender kademlia.Contact, receiver [4]byte, response bool, dropped string) *RPCInfo {
+	res := &RPCInfo{Cmd: cmd.String(), From: sender.ID(), Response: response, Dropped: dropped != "", Reason: dropped}
+	if id, ok := f.ids[receiver]; ok {
+		res.To = &id
+	}
+	return res
+}
+
+// Serves the simnet's graph as JSON.
+func serveTopology(simnet *kademlia.Simnet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := newFeed(simnet.MasterNode())
+		respond(w, http.StatusOK, f.snapshot(simnet.Topology()).Topology)
+	}
+}
+
+// Upgrades the request to a WebSocket that receives a snapshot of the simnet's graph, then every
+// node and routing table change and, unless the traffic query parameter is false, every RPC the
+// simnet delivers or drops. The snapshot is resent every FEED_RESYNC.
+func serveTopologyFeed(simnet *kademlia.Simnet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		traffic := r.URL.Query().Get("traffic") != "false"
+		events, cancel := simnet.Events().Subscribe(FEED_BUFFER)
+		defer cancel()
+		ws, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		f := newFeed(simnet.MasterNode())
+		if ws.writeJSON(f.snapshot(simnet.Topology())) != nil {
+			return
+		}
+		resync := time.NewTicker(FEED_RESYNC)
+		defer resync.Stop()
+		for {
+			select {
+			case <-ws.closed:
+				return
+			case <-resync.C:
+				if ws.writeJSON(f.snapshot(simnet.Topology())) != nil {
+					return
+				}
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				msg, ok := f.message(event, traffic)
+				if ok && ws.writeJSON(msg) != nil {
+					return
+				}
+			}
+		}
+	}
+}
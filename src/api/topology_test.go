@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"main/src/kademlia"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reads a single unmasked frame sent by the server.
+func readServerFrame(reader *bufio.Reader) (byte, []byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(reader, head); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(head[1] & FRAME_LENGTH_MASK)
+	if length == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(reader, ext)
+		length = uint64(binary.BigEndian.Uint16(ext))
+	} else if length == 127 {
+		ext := make([]byte, 8)
+		io.ReadFull(reader, ext)
+		length = binary.BigEndian.Uint64(ext)
+	}
+	payload := make([]byte, length)
+	_, err := io.ReadFull(reader, payload)
+	return head[0] & 0x0f, payload, err
+}
+
+func TestTopologyFeed(t *testing.T) {
+	testName := "TestTopologyFeed"
+	done := make(chan struct{}, 1)
+	s := kademlia.NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	server := httptest.NewServer(NewSimnetHandler(s))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/topology/feed")
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		log.Printf("[%s] - expected a plain request to be refused, got status %d", testName, resp.StatusCode)
+		t.Fail()
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	fmt.Fprintf(conn, "GET /topology/feed?traffic=false HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	handshake, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	// The accept key of the sample nonce from RFC 6455 section 1.3.
+	if handshake.StatusCode != http.StatusSwitchingProtocols || handshake.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		log.Printf("[%s] - bad handshake: %d %v", testName, handshake.StatusCode, handshake.Header)
+		t.FailNow()
+	}
+
+	_, payload, err := readServerFrame(reader)
+	snapshot := FeedMessage{}
+	if err != nil || json.Unmarshal(payload, &snapshot) != nil || snapshot.Type != FEED_SNAPSHOT || len(snapshot.Topology.Nodes) != 6 {
+		log.Printf("[%s] - expected a snapshot of 6 nodes, got %s", testName, payload)
+		t.FailNow()
+	}
+
+	victim := nodes[0]
+	s.ShutdownNode(victim)
+	for {
+		opcode, payload, err := readServerFrame(reader)
+		if err != nil {
+			log.Printf("[%s] - never saw the node removal: %s", testName, err.Error())
+			t.FailNow()
+		}
+		msg := FeedMessage{}
+		json.Unmarshal(payload, &msg)
+		if opcode != OPCODE_TEXT || msg.Type == FEED_RPC {
+			log.Printf("[%s] - unexpected frame with traffic off: %d %s", testName, opcode, payload)
+			t.FailNow()
+		}
+		if msg.Type == FEED_NODE_REMOVED && msg.Node.ID == victim.ID() {
+			break
+		}
+	}
+
+	// A masked close frame carrying the normal closure code, the server echoes the code.
+	mask := []byte{1, 2, 3, 4}
+	code := []byte{0x03 ^ mask[0], 0xe8 ^ mask[1]}
+	conn.Write(append([]byte{FRAME_FINAL | OPCODE_CLOSE, FRAME_MASKED | 2}, append(mask, code...)...))
+	for {
+		opcode, payload, err := readServerFrame(reader)
+		if err != nil {
+			log.Printf("[%s] - no close frame: %s", testName, err.Error())
+			t.FailNow()
+		}
+		if opcode == OPCODE_CLOSE {
+			if binary.BigEndian.Uint16(payload) != 1000 {
+				log.Printf("[%s] - expected close code 1000, got %v", testName, payload)
+				t.Fail()
+			}
+			break
+		}
+	}
+}
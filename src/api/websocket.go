@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// The server side of a WebSocket connection (RFC 6455), enough of it to push JSON to a browser.
+// Messages are sent as single unfragmented text frames. Frames from the client are read only to
+// answer pings and close the connection, their payloads are discarded.
+type websocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	closed chan struct{} // closed once the client closed the connection or it failed
+	once   sync.Once
+	sync.Mutex
+}
+
+const (
+	WEBSOCKET_GUID    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11" // appended to the client key, see RFC 6455 section 1.3
+	MAX_CLIENT_FRAME  = 1 << 16                                // largest frame accepted from a client
+	OPCODE_TEXT       = 0x1
+	OPCODE_CLOSE      = 0x8
+	OPCODE_PING       = 0x9
+	OPCODE_PONG       = 0xa
+	FRAME_FINAL       = 0x80
+	FRAME_MASKED      = 0x80
+	FRAME_LENGTH_MASK = 0x7f
+)
+
+// Completes the opening handshake and takes over the connection.
+// Responds with 400 and returns an error if the request is not a WebSocket upgrade.
+func upgrade(w http.ResponseWriter, r *http.Request) (*websocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		err := errors.New("expected a websocket upgrade")
+		fail(w, http.StatusBadRequest, err)
+		return nil, err
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		err := errors.New("unsupported websocket version")
+		fail(w, http.StatusBadRequest, err)
+		return nil, err
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		err := errors.New("connection can not be taken over")
+		fail(w, http.StatusInternalServerError, err)
+		return nil, err
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha1.Sum([]byte(key + WEBSOCKET_GUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(digest[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ws := &websocket{
+		conn:   conn,
+		reader: rw.Reader,
+		closed: make(chan struct{}),
+	}
+	go ws.readLoop()
+	return ws, nil
+}
+
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sends v as a JSON text message.
+func (ws *websocket) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(OPCODE_TEXT, data)
+}
+
+func (ws *websocket) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{FRAME_FINAL | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+	ws.Lock()
+	defer ws.Unlock()
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// Reads the client's frames until it closes the connection, answering pings and closes.
+func (ws *websocket) readLoop() {
+	defer ws.Close()
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case OPCODE_PING:
+			ws.writeFrame(OPCODE_PONG, payload)
+		case OPCODE_CLOSE:
+			ws.writeFrame(OPCODE_CLOSE, payload[:min(2, len(payload))])
+			return
+		}
+	}
+}
+
+func (ws *websocket) readFrame() (byte, []byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(ws.reader, head); err != nil {
+		return 0, nil, err
+	}
+	if head[1]&FRAME_MASKED == 0 {
+		return 0, nil, errors.New("client frame is not masked")
+	}
+	length := uint64(head[1] & FRAME_LENGTH_MASK)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(ws.reader, ext); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(ws.reader, ext); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > MAX_CLIENT_FRAME {
+		return 0, nil, errors.New(fmt.Sprintf("client frame of %d bytes is too large", length))
+	}
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(ws.reader, mask); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return head[0] & 0x0f, payload, nil
+}
+
+// Closes the connection, safe to call more than once.
+func (ws *websocket) Close() {
+	ws.once.Do(func() {
+		close(ws.closed)
+		ws.conn.Close()
+	})
+}
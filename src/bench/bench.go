@@ -0,0 +1,246 @@
+// Package bench measures the simulated network: how long a cluster takes to form, what a node
+// lookup costs as the network grows, how many values a cluster stores and finds per second and
+// how much memory a node holds. The package's Go benchmarks and scalegraph-sim -bench both run
+// these measurements.
+package bench
+
+import (
+	"crypto/rand"
+	"fmt"
+	"main/src/kademlia"
+	mrand "math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	MIN_SIZE    = 25  // smallest cluster measured by Sizes
+	LOOKUPS     = 200 // node lookups measured per cluster by Run
+	VALUES      = 200 // values stored and then found per cluster by Run
+	VALUE_BYTES = 256 // size of the values stored by StoreFind
+	CONCURRENCY = 16  // stores or finds in flight at once during StoreFind
+)
+
+// A running simnet and its nodes, not counting the master node.
+type Cluster struct {
+	Simnet *kademlia.Simnet
+	Nodes  []*kademlia.Node
+}
+
+func (cluster *Cluster) Shutdown() {
+	cluster.Simnet.Shutdown()
+}
+
+func (cluster *Cluster) randomNode() *kademlia.Node {
+	return cluster.Nodes[mrand.Intn(len(cluster.Nodes))]
+}
+
+// Cost of forming a cluster.
+type JoinResult struct {
+	Size          int
+	Duration      time.Duration // from spawning the first node until every node has joined
+	RPCsPerNode   float64       // RPCs routed while the cluster formed, per node
+	MemoryPerNode uint64        // heap and stack bytes the cluster grew by while it formed, per node
+}
+
+// Cost of node lookups between random pairs of live nodes.
+type LookupResult struct {
+	Lookups   int
+	Succeeded int // lookups whose closest contact was their target
+	Mean      time.Duration
+	P99       time.Duration
+	RPCs      float64 // find node queries per lookup
+	Hops      float64 // longest chain of queries per lookup
+}
+
+// Values stored and then found per second.
+type ThroughputResult struct {
+	Stored int
+	Found  int
+	Stores float64 // successful stores per second
+	Finds  float64 // successful finds per second
+}
+
+// Measurements of one cluster size, see Run.
+type Result struct {
+	Join      JoinResult
+	Lookup    LookupResult
+	StoreFind ThroughputResult
+}
+
+// Starts a silent simnet and spawns size nodes, returning once all of them have joined, and
+// measures what forming the cluster cost.
+func Join(size int) (*Cluster, JoinResult) {
+	before := memory()
+	done := make(chan struct{}, 1)
+	simnet := kademlia.NewServer(false, 0.0)
+	simnet.Silence()
+	go simnet.StartServer()
+	start := time.Now()
+	nodes := simnet.SpawnCluster(size, done)
+	<-done
+	res := JoinResult{Size: size, Duration: time.Since(start)}
+	routed := 0
+	for _, count := range simnet.Stats().Routed {
+		routed += count
+	}
+	res.RPCsPerNode = float64(routed) / float64(size)
+	if after := memory(); after > before {
+		res.MemoryPerNode = (after - before) / uint64(size+1)
+	}
+	return &Cluster{simnet, nodes}, res
+}
+
+// Returns the bytes held by the heap and goroutine stacks after a garbage collection.
+func memory() uint64 {
+	runtime.GC()
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc + stats.StackInuse
+}
+
+// Runs count node lookups, each from a random node for another random node.
+func Lookups(cluster *Cluster, count int) LookupResult {
+	res := LookupResult{Lookups: count}
+	durations := make([]time.Duration, 0, count)
+	var total time.Duration
+	rpcs, hops := 0, 0
+	for range count {
+		from, target := cluster.randomNode(), cluster.randomNode()
+		found, stats := from.FindNodeWithStats(target.ID())
+		if len(found) > 0 && found[0].ID() == target.ID() {
+			res.Succeeded++
+		}
+		durations = append(durations, stats.Duration)
+		total += stats.Duration
+		rpcs += stats.RPCs
+		hops += stats.Hops
+	}
+	if count == 0 {
+		return res
+	}
+	slices.Sort(durations)
+	res.Mean = total / time.Duration(count)
+	res.P99 = durations[min(count-1, count*99/100)]
+	res.RPCs = float64(rpcs) / float64(count)
+	res.Hops = float64(hops) / float64(count)
+	return res
+}
+
+// Stores count random values through random nodes, then finds each of them through another
+// random node, CONCURRENCY at a time.
+func StoreFind(cluster *Cluster, count int) ThroughputResult {
+	res := ThroughputResult{}
+	keys := make([]kademlia.KademliaID, count)
+	stored := make([]bool, count)
+	start := time.Now()
+	parallel(count, func(i int) {
+		data := make([]byte, VALUE_BYTES)
+		rand.Read(data)
+		key, err := cluster.randomNode().StoreValue(data)
+		keys[i], stored[i] = key, err == nil
+	})
+	elapsed := time.Since(start)
+	for _, ok := range stored {
+		if ok {
+			res.Stored++
+		}
+	}
+	res.Stores = float64(res.Stored) / elapsed.Seconds()
+
+	found := make([]bool, count)
+	start = time.Now()
+	parallel(count, func(i int) {
+		if stored[i] {
+			_, err := cluster.randomNode().FindValue(keys[i])
+			found[i] = err == nil
+		}
+	})
+	elapsed = time.Since(start)
+	for _, ok := range found {
+		if ok {
+			res.Found++
+		}
+	}
+	res.Finds = float64(res.Found) / elapsed.Seconds()
+	return res
+}
+
+// Calls do for every index below count, CONCURRENCY at a time.
+func parallel(count int, do func(i int)) {
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range CONCURRENCY {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				do(i)
+			}
+		}()
+	}
+	for i := range count {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// Returns the cluster sizes measured up to largest: largest itself and its halvings down to
+// MIN_SIZE, smallest first.
+func Sizes(largest int) []int {
+	res := make([]int, 0)
+	for size := largest; size >= MIN_SIZE; size /= 2 {
+		res = append(res, size)
+	}
+	if len(res) == 0 {
+		res = append(res, largest)
+	}
+	slices.Reverse(res)
+	return res
+}
+
+// Forms a cluster of every size in turn and measures joining, LOOKUPS node lookups and storing
+// and finding VALUES values in it. progress, if not nil, is called with the result of each size
+// as it completes.
+func Run(sizes []int, progress func(Result)) []Result {
+	res := make([]Result, 0, len(sizes))
+	for _, size := range sizes {
+		cluster, join := Join(size)
+		result := Result{
+			Join:      join,
+			Lookup:    Lookups(cluster, LOOKUPS),
+			StoreFind: StoreFind(cluster, VALUES),
+		}
+		cluster.Shutdown()
+		res = append(res, result)
+		if progress != nil {
+			progress(result)
+		}
+	}
+	return res
+}
+
+// Returns a table of the results, one row per cluster size.
+func Display(results []Result) string {
+	res := fmt.Sprintf("%6s %10s %9s %9s %9s %9s %6s %6s %7s %9s %9s\n",
+		"nodes", "converge", "join rpcs", "mem/node", "lookup", "p99", "rpcs", "hops", "success", "stores/s", "finds/s")
+	for _, r := range results {
+		res += r.Display()
+	}
+	return res
+}
+
+// Returns a row of the table written by Display.
+func (r Result) Display() string {
+	success := 0.0
+	if r.Lookup.Lookups > 0 {
+		success = float64(r.Lookup.Succeeded) / float64(r.Lookup.Lookups)
+	}
+	return fmt.Sprintf("%6d %10v %9.1f %8dK %9v %9v %6.1f %6.1f %7.3f %9.1f %9.1f\n",
+		r.Join.Size, r.Join.Duration.Round(time.Millisecond), r.Join.RPCsPerNode, r.Join.MemoryPerNode/1024,
+		r.Lookup.Mean.Round(time.Microsecond), r.Lookup.P99.Round(time.Microsecond), r.Lookup.RPCs, r.Lookup.Hops, success,
+		r.StoreFind.Stores, r.StoreFind.Finds)
+}
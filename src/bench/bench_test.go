@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"log"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestSizes(t *testing.T) {
+	testName := "TestSizes"
+	if res := Sizes(200); !slices.Equal(res, []int{25, 50, 100, 200}) {
+		log.Printf("[%s] - unexpected sizes up to 200: %v", testName, res)
+		t.Fail()
+	}
+	if res := Sizes(10); !slices.Equal(res, []int{10}) {
+		log.Printf("[%s] - expected a size below MIN_SIZE to be measured alone, got %v", testName, res)
+		t.Fail()
+	}
+}
+
+func TestRun(t *testing.T) {
+	testName := "TestRun"
+	calls := 0
+	res := Run([]int{10}, func(Result) { calls++ })
+	if len(res) != 1 || calls != 1 {
+		log.Printf("[%s] - expected one result, got %d and %d progress calls", testName, len(res), calls)
+		t.FailNow()
+	}
+	r := res[0]
+	if r.Join.Size != 10 || r.Join.Duration <= 0 || r.Join.RPCsPerNode <= 0 {
+		log.Printf("[%s] - unexpected join result: %+v", testName, r.Join)
+		t.Fail()
+	}
+	if r.Lookup.Lookups != LOOKUPS || r.Lookup.Succeeded == 0 || r.Lookup.RPCs <= 0 || r.Lookup.P99 < r.Lookup.Mean/2 {
+		log.Printf("[%s] - unexpected lookup result: %+v", testName, r.Lookup)
+		t.Fail()
+	}
+	if r.StoreFind.Stored != VALUES || r.StoreFind.Found == 0 || r.StoreFind.Stores <= 0 {
+		log.Printf("[%s] - unexpected store and find result: %+v", testName, r.StoreFind)
+		t.Fail()
+	}
+}
+
+// Reports the time to form a cluster of size nodes as the time per operation.
+func benchmarkJoin(b *testing.B, size int) {
+	rpcs, mem := 0.0, uint64(0)
+	for range b.N {
+		cluster, res := Join(size)
+		b.StopTimer()
+		cluster.Shutdown()
+		rpcs += res.RPCsPerNode
+		mem += res.MemoryPerNode
+		b.StartTimer()
+	}
+	b.ReportMetric(rpcs/float64(b.N), "rpcs/node")
+	b.ReportMetric(float64(mem)/float64(b.N), "bytes/node")
+}
+
+func BenchmarkJoin100(b *testing.B)  { benchmarkJoin(b, 100) }
+func BenchmarkJoin1000(b *testing.B) { benchmarkJoin(b, 1000) }
+
+// Reports the time of a node lookup in a cluster of size nodes as the time per operation, along
+// with the allocations and garbage collector pauses of the whole cluster while the lookups ran.
+func benchmarkFindNode(b *testing.B, size int) {
+	cluster, _ := Join(size)
+	defer cluster.Shutdown()
+	before := runtime.MemStats{}
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+	res := Lookups(cluster, b.N)
+	b.StopTimer()
+	after := runtime.MemStats{}
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gcs/op")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+	b.ReportMetric(res.RPCs, "rpcs/op")
+	b.ReportMetric(res.Hops, "hops/op")
+	b.ReportMetric(float64(res.Succeeded)/float64(res.Lookups), "success")
+}
+
+func BenchmarkFindNode100(b *testing.B)   { benchmarkFindNode(b, 100) }
+func BenchmarkFindNode250(b *testing.B)   { benchmarkFindNode(b, 250) }
+func BenchmarkFindNode500(b *testing.B)   { benchmarkFindNode(b, 500) }
+func BenchmarkFindNode1000(b *testing.B)  { benchmarkFindNode(b, 1000) }
+func BenchmarkFindNode10000(b *testing.B) { benchmarkFindNode(b, 10000) }
+
+// Reports the time to store and then find a value in a cluster of size nodes as the time per
+// operation.
+func benchmarkStoreFind(b *testing.B, size int) {
+	cluster, _ := Join(size)
+	defer cluster.Shutdown()
+	b.ResetTimer()
+	res := StoreFind(cluster, b.N)
+	b.ReportMetric(res.Stores, "stores/s")
+	b.ReportMetric(res.Finds, "finds/s")
+}
+
+func BenchmarkStoreFind100(b *testing.B) { benchmarkStoreFind(b, 100) }
+func BenchmarkStoreFind500(b *testing.B) { benchmarkStoreFind(b, 500) }
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"errors"
+	"main/src/kademlia"
+	"sync"
+	"time"
+)
+
+const (
+	CACHE_CAPACITY = 256              // default number of wallets kept by the wallet cache
+	CACHE_TTL      = 30 * time.Second // default age after which a cached wallet is fetched again
+)
+
+// Implemented by gateways that can push wallet changes, such as *kademlia.Node. Without it the
+// cache only expires wallets after their TTL.
+type Notifier interface {
+	SubscribeWallet(id kademlia.KademliaID) (<-chan kademlia.Wallet, func(), error)
+}
+
+// Counters of the wallet cache.
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64 // wallets dropped because their validators reported a change
+	Evictions     uint64 // wallets dropped to stay within the capacity
+}
+
+// Recently fetched wallets, each subscribed to its validators' change notifications while cached.
+type walletCache struct {
+	capacity int
+	ttl      time.Duration
+	entries  map[WalletID]*cacheEntry
+	order    []WalletID // least recently used first
+	stats    CacheStats
+	sync.Mutex
+}
+
+type cacheEntry struct {
+	wallet  kademlia.Wallet
+	fetched time.Time
+	cancel  func() // ends the change subscription, nil if the gateway does not push changes
+}
+
+// Keeps up to capacity recently fetched wallets so that Wallet and Balance are answered without a
+// lookup. Cached wallets are invalidated as soon as their validators report a change, and fetched
+// again once they are older than ttl in case a notification was lost. Replaces any existing cache.
+// Returns an error if capacity or ttl is not positive.
+func (client *Client) EnableCache(capacity int, ttl time.Duration) error {
+	if capacity <= 0 || ttl <= 0 {
+		return errors.New("cache capacity and ttl must be positive")
+	}
+	client.DisableCache()
+	client.Lock()
+	defer client.Unlock()
+	client.cache = &walletCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[WalletID]*cacheEntry),
+		order:    make([]WalletID, 0, capacity),
+	}
+	return nil
+}
+
+// Drops every cached wallet and ends their change subscriptions.
+func (client *Client) DisableCache() {
+	client.Lock()
+	cache := client.cache
+	client.cache = nil
+	client.Unlock()
+	if cache == nil {
+		return
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	for id := range cache.entries {
+		cache.remove(id)
+	}
+}
+
+// Returns the counters of the wallet cache, the zero value if caching is disabled.
+func (client *Client) CacheStats() CacheStats {
+	client.Lock()
+	cache := client.cache
+	client.Unlock()
+	if cache == nil {
+		return CacheStats{}
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.stats
+}
+
+// Returns the wallet, from the cache if it holds a current copy.
+func (client *Client) Wallet(wallet WalletID) (kademlia.Wallet, error) {
+	client.Lock()
+	cache := client.cache
+	client.Unlock()
+	if cache == nil {
+		return client.gateway.ShowWallet(wallet)
+	}
+	if state, ok := cache.get(wallet); ok {
+		return state, nil
+	}
+	state, err := client.gateway.ShowWallet(wallet)
+	if err != nil {
+		return state, err
+	}
+	changes, cancel := client.subscribe(wallet)
+	entry := cache.put(state, cancel)
+	if changes != nil {
+		go cache.watch(entry, changes)
+	}
+	return state, nil
+}
+
+// Subscribes to changes of the wallet if the gateway pushes them.
+// Returns nil if the gateway does not push changes or the subscription failed.
+func (client *Client) subscribe(wallet WalletID) (<-chan kademlia.Wallet, func()) {
+	notifier, ok := client.gateway.(Notifier)
+	if !ok {
+		return nil, nil
+	}
+	changes, cancel, err := notifier.SubscribeWallet(wallet)
+	if err != nil {
+		return nil, nil
+	}
+	return changes, cancel
+}
+
+// Drops the cached copy of the wallet, used after the client changed it itself.
+func (client *Client) invalidate(wallet WalletID) {
+	client.Lock()
+	cache := client.cache
+	client.Unlock()
+	if cache == nil {
+		return
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	if entry, ok := cache.entries[wallet]; ok {
+		cache.drop(entry, true)
+	}
+}
+
+func (cache *walletCache) get(wallet WalletID) (kademlia.Wallet, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+	entry, ok := cache.entries[wallet]
+	if !ok || time.Since(entry.fetched) > cache.ttl {
+		if ok {
+			cache.remove(wallet)
+		}
+		cache.stats.Misses++
+		return kademlia.Wallet{}, false
+	}
+	cache.touch(wallet)
+	cache.stats.Hits++
+	return entry.wallet, true
+}
+
+// Caches the wallet, evicting the least recently used wallets beyond the capacity.
+// Returns the new cache entry.
+func (cache *walletCache) put(wallet kademlia.Wallet, cancel func()) *cacheEntry {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.remove(wallet.ID)
+	entry := &cacheEntry{wallet, time.Now(), cancel}
+	cache.entries[wallet.ID] = entry
+	cache.order = append(cache.order, wallet.ID)
+	for len(cache.order) > cache.capacity {
+		cache.remove(cache.order[0])
+		cache.stats.Evictions++
+	}
+	return entry
+}
+
+// Drops the entry on the first change pushed for its wallet. The channel is also closed when the
+// entry is removed, in which case there is nothing left to drop.
+func (cache *walletCache) watch(entry *cacheEntry, changes <-chan kademlia.Wallet) {
+	_, changed := <-changes
+	cache.Lock()
+	defer cache.Unlock()
+	cache.drop(entry, changed)
+}
+
+// Drops the entry unless it was already replaced, the cache must be locked.
+func (cache *walletCache) drop(entry *cacheEntry, changed bool) {
+	if cache.entries[entry.wallet.ID] != entry {
+		return
+	}
+	cache.remove(entry.wallet.ID)
+	if changed {
+		cache.stats.Invalidations++
+	}
+}
+
+// Moves the wallet to the most recently used end, the cache must be locked.
+func (cache *walletCache) touch(wallet WalletID) {
+	for i, id := range cache.order {
+		if id == wallet {
+			cache.order = append(append(cache.order[:i], cache.order[i+1:]...), wallet)
+			return
+		}
+	}
+}
+
+// Drops the wallet and ends its subscription, the cache must be locked.
+func (cache *walletCache) remove(wallet WalletID) {
+	entry, ok := cache.entries[wallet]
+	if !ok {
+		return
+	}
+	delete(cache.entries, wallet)
+	for i, id := range cache.order {
+		if id == wallet {
+			cache.order = append(cache.order[:i], cache.order[i+1:]...)
+			break
+		}
+	}
+	if entry.cancel != nil {
+		// The subscription's goroutine takes the cache lock once the channel closes.
+		go entry.cancel()
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"log"
+	"main/src/kademliatest"
+	"main/src/scalegraph"
+	"testing"
+	"time"
+)
+
+func TestWalletCache(t *testing.T) {
+	testName := "TestWalletCache"
+	_, nodes := kademliatest.NewCluster(t, 8)
+	client := New(nodes[0])
+	if err := client.EnableCache(CACHE_CAPACITY, time.Minute); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	defer client.DisableCache()
+
+	wallet, err := client.CreateWallet(10)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	for range 3 {
+		balance, err := client.Balance(wallet)
+		if err != nil || balance != 10 {
+			log.Printf("[%s] - expected balance 10, got %d: %v", testName, balance, err)
+			t.Fail()
+		}
+	}
+	stats := client.CacheStats()
+	if stats.Misses != 1 || stats.Hits != 2 {
+		log.Printf("[%s] - expected 1 miss and 2 hits, got %+v", testName, stats)
+		t.Fail()
+	}
+
+	// A change made through another node reaches the cache through the validators' notifications.
+	err = nodes[len(nodes)-1].Transfer(scalegraph.MINT_ACCOUNT, wallet, 5)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for client.CacheStats().Invalidations == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if client.CacheStats().Invalidations != 1 {
+		log.Printf("[%s] - cached wallet was not invalidated by the change", testName)
+		t.FailNow()
+	}
+	balance, err := client.Balance(wallet)
+	if err != nil || balance != 15 {
+		log.Printf("[%s] - expected balance 15 after the change, got %d: %v", testName, balance, err)
+		t.Fail()
+	}
+}
+
+func TestWalletCacheEviction(t *testing.T) {
+	testName := "TestWalletCacheEviction"
+	_, nodes := kademliatest.NewCluster(t, 5)
+	client := New(nodes[0])
+	client.EnableCache(2, time.Minute)
+	defer client.DisableCache()
+
+	wallets := make([]WalletID, 0, 3)
+	for range 3 {
+		wallet, err := client.CreateWallet(1)
+		if err != nil {
+			log.Printf("[%s] - %s", testName, err.Error())
+			t.FailNow()
+		}
+		wallets = append(wallets, wallet)
+		client.Balance(wallet)
+	}
+	if stats := client.CacheStats(); stats.Evictions != 1 {
+		log.Printf("[%s] - expected one eviction, got %+v", testName, stats)
+		t.Fail()
+	}
+	client.Balance(wallets[0])
+	if stats := client.CacheStats(); stats.Misses != 4 {
+		log.Printf("[%s] - evicted wallet was served from the cache: %+v", testName, stats)
+		t.Fail()
+	}
+	if client.EnableCache(0, time.Minute) == nil {
+		log.Printf("[%s] - enabled a cache without capacity", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/src/api"
+	"main/src/kademlia"
+	"main/src/scalegraph"
+	"net/http"
+	"strings"
+)
+
+// Gateway reaching a node through its HTTP API, see package api, for applications that do not
+// run a node in the same process.
+type HTTPGateway struct {
+	base string // URL the node routes are served under, without a trailing slash
+	http *http.Client
+}
+
+// Returns a gateway to the node whose routes are served under base, for example
+// "http://host:8080" for a node handler or "http://host:8080/nodes/{node}" for a simnet handler.
+// Requests are sent with http.DefaultClient unless httpClient is set.
+func NewHTTPGateway(base string, httpClient *http.Client) *HTTPGateway {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &HTTPGateway{strings.TrimSuffix(base, "/"), httpClient}
+}
+
+func (gateway *HTTPGateway) SubmitWalletWithKey(id kademlia.KademliaID, key ed25519.PublicKey, balance uint64) error {
+	return gateway.do("POST", "/wallet", api.WalletRequest{ID: id, PublicKey: key, Balance: balance}, nil)
+}
+
+func (gateway *HTTPGateway) Balance(accID kademlia.KademliaID) (uint64, error) {
+	wallet, err := gateway.ShowWallet(accID)
+	return wallet.Balance, err
+}
+
+// Returns the wallet as reported by the node, without its public key which the API does not
+// expose.
+func (gateway *HTTPGateway) ShowWallet(id kademlia.KademliaID) (kademlia.Wallet, error) {
+	info := api.WalletInfo{}
+	if err := gateway.do("GET", "/wallet/"+id.String(), nil, &info); err != nil {
+		return kademlia.Wallet{}, err
+	}
+	return kademlia.Wallet{ID: info.ID, Balance: info.Balance, Transactions: info.Transactions, Nonce: info.Nonce}, nil
+}
+
+func (gateway *HTTPGateway) ProposeTransaction(trx *scalegraph.Transaction) error {
+	return gateway.do("POST", "/transaction", trx, nil)
+}
+
+// Sends a request with body encoded as JSON, unless it is nil, and decodes the response into
+// into, unless it is nil.
+// Returns the error reported by the API for responses that are not a success.
+func (gateway *HTTPGateway) do(method string, path string, body any, into any) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, gateway.base+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := gateway.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		failure := map[string]string{}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return errors.New(fmt.Sprintf("%s %s: %d %s", method, path, resp.StatusCode, failure["error"]))
+	}
+	if into != nil {
+		return json.NewDecoder(resp.Body).Decode(into)
+	}
+	return nil
+}
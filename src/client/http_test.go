@@ -0,0 +1,49 @@
+package client
+
+import (
+	"log"
+	"main/src/api"
+	"main/src/kademliatest"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPGateway(t *testing.T) {
+	testName := "TestHTTPGateway"
+	_, nodes := kademliatest.NewCluster(t, 8)
+	server := httptest.NewServer(api.NewNodeHandler(nodes[0]))
+	defer server.Close()
+	client := New(NewHTTPGateway(server.URL, server.Client()))
+
+	alice, err := client.CreateWallet(100)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	bob, err := client.CreateWallet(0)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	if err := client.Transfer(alice, bob, 30); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	if client.Transfer(alice, bob, 71) == nil {
+		log.Printf("[%s] - transfer exceeding the balance succeeded", testName)
+		t.Fail()
+	}
+	// the failed transfer resynced the nonce through the API, so the next one goes through
+	if err := client.Transfer(alice, bob, 10); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	if balance, err := client.Balance(bob); err != nil || balance != 40 {
+		log.Printf("[%s] - expected receiver balance 40, got %d: %v", testName, balance, err)
+		t.Fail()
+	}
+	if _, err := client.Balance(WalletID{}); err == nil {
+		log.Printf("[%s] - balance of an unknown wallet succeeded", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"main/src/kademlia"
+	"main/src/scalegraph"
+	"sync"
+	"time"
+)
+
+const WATCH_INTERVAL = 100 * time.Millisecond // default polling interval for WatchWallet
+
+// The part of a node's API the client uses. Applications reach a node through its HTTP API with
+// an HTTPGateway, *kademlia.Node implements it as well for clients running next to their node.
+type Gateway interface {
+	SubmitWalletWithKey(id kademlia.KademliaID, key ed25519.PublicKey, balance uint64) error
+	Balance(accID kademlia.KademliaID) (uint64, error)
+	ShowWallet(id kademlia.KademliaID) (kademlia.Wallet, error)
+	ProposeTransaction(trx *scalegraph.Transaction) error
+}
+
+// A wallet id as seen by applications.
+type WalletID = kademlia.KademliaID
+
+// Balance of a wallet observed by WatchWallet.
+type WalletUpdate struct {
+	Wallet  WalletID
+	Balance uint64
+}
+
+// High level wallet client that talks to the network through a single gateway node.
+// The client holds the private keys of the wallets it created and signs their transfers.
+type Client struct {
+	gateway       Gateway
+	watchInterval time.Duration
+	keys          map[WalletID]ed25519.PrivateKey
+	nonces        map[WalletID]uint64 // nonce of the last transfer signed for each wallet
+	cache         *walletCache        // nil unless enabled with EnableCache
+	sync.Mutex
+}
+
+func New(gateway Gateway) *Client {
+	return &Client{
+		gateway:       gateway,
+		watchInterval: WATCH_INTERVAL,
+		keys:          make(map[WalletID]ed25519.PrivateKey),
+		nonces:        make(map[WalletID]uint64),
+	}
+}
+
+// Sets how often watched wallets are polled for balance changes.
+func (client *Client) SetWatchInterval(interval time.Duration) {
+	client.watchInterval = interval
+}
+
+// Creates a wallet funded with deposit. The wallet gets a fresh ed25519 key pair and its id is
+// derived from the public key, the private key never leaves the client.
+func (client *Client) CreateWallet(deposit uint64) (WalletID, error) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return WalletID{}, errors.New(fmt.Sprintf("failed to generate wallet key: %s", err.Error()))
+	}
+	id := kademlia.NewKeyFromData(pub)
+	err = client.gateway.SubmitWalletWithKey(id, pub, deposit)
+	if err != nil {
+		return WalletID{}, errors.New(fmt.Sprintf("failed to create wallet: %s", err.Error()))
+	}
+	client.Lock()
+	client.keys[id] = priv
+	client.Unlock()
+	return id, nil
+}
+
+// Returns the balance of the wallet, from the wallet cache if it is enabled.
+func (client *Client) Balance(wallet WalletID) (uint64, error) {
+	client.Lock()
+	cached := client.cache != nil
+	client.Unlock()
+	if !cached {
+		return client.gateway.Balance(wallet)
+	}
+	state, err := client.Wallet(wallet)
+	return state.Balance, err
+}
+
+// Moves amount from one wallet to another, fails if the sending wallet lacks the funds.
+func (client *Client) Transfer(from WalletID, to WalletID, amount uint64) error {
+	if amount == 0 {
+		return errors.New("transfer amount must be positive")
+	}
+	if from == to {
+		return errors.New("cannot transfer to the sending wallet")
+	}
+	client.Lock()
+	key, ok := client.keys[from]
+	if !ok {
+		client.Unlock()
+		return errors.New(fmt.Sprintf("no key for wallet %v", from))
+	}
+	client.nonces[from]++
+	nonce := client.nonces[from]
+	client.Unlock()
+	trx := scalegraph.NewTransferWithNonce(from, to, amount, nonce)
+	trx.Sign(key)
+	err := client.gateway.ProposeTransaction(trx)
+	client.invalidate(from)
+	client.invalidate(to)
+	if err != nil {
+		client.syncNonce(from)
+	}
+	return err
+}
+
+// Resets the wallet's nonce to the one held by its validators after a failed transfer, which
+// may or may not have consumed the nonce.
+func (client *Client) syncNonce(wallet WalletID) {
+	state, err := client.gateway.ShowWallet(wallet)
+	if err != nil {
+		return
+	}
+	client.Lock()
+	client.nonces[wallet] = state.Nonce
+	client.Unlock()
+}
+
+// Publishes the wallet's balance on the returned channel whenever it changes, starting with the
+// current balance, until ctx is done. The channel is closed when watching stops.
+func (client *Client) WatchWallet(ctx context.Context, wallet WalletID) <-chan WalletUpdate {
+	updates := make(chan WalletUpdate, 1)
+	go func() {
+		defer close(updates)
+		ticker := time.NewTicker(client.watchInterval)
+		defer ticker.Stop()
+		known := false
+		var last uint64
+		for {
+			balance, err := client.gateway.Balance(wallet)
+			if err == nil && (!known || balance != last) {
+				known = true
+				last = balance
+				select {
+				case updates <- WalletUpdate{wallet, balance}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return updates
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"log"
+	"main/src/kademliatest"
+	"testing"
+	"time"
+)
+
+func TestWalletTransfer(t *testing.T) {
+	testName := "TestWalletTransfer"
+	_, nodes := kademliatest.NewCluster(t, 8)
+	client := New(nodes[0])
+
+	alice, err := client.CreateWallet(100)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	bob, err := client.CreateWallet(0)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+
+	err = client.Transfer(alice, bob, 30)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	if client.Transfer(alice, bob, 71) == nil {
+		log.Printf("[%s] - transfer exceeding the balance succeeded", testName)
+		t.Fail()
+	}
+
+	// balances must agree regardless of which node the client talks to
+	other := New(nodes[len(nodes)-1])
+	if other.Transfer(alice, bob, 10) == nil {
+		log.Printf("[%s] - transfer from a wallet without its key succeeded", testName)
+		t.Fail()
+	}
+	balance, err := other.Balance(alice)
+	if err != nil || balance != 70 {
+		log.Printf("[%s] - expected sender balance 70, got %d: %v", testName, balance, err)
+		t.Fail()
+	}
+	balance, err = other.Balance(bob)
+	if err != nil || balance != 30 {
+		log.Printf("[%s] - expected receiver balance 30, got %d: %v", testName, balance, err)
+		t.Fail()
+	}
+}
+
+func TestWatchWallet(t *testing.T) {
+	testName := "TestWatchWallet"
+	_, nodes := kademliatest.NewCluster(t, 5)
+	client := New(nodes[0])
+	client.SetWatchInterval(5 * time.Millisecond)
+
+	wallet, err := client.CreateWallet(10)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	updates := client.WatchWallet(ctx, wallet)
+	first := <-updates
+	if first.Balance != 10 {
+		log.Printf("[%s] - expected initial balance 10, got %d", testName, first.Balance)
+		t.Fail()
+	}
+
+	other, _ := client.CreateWallet(0)
+	err = client.Transfer(wallet, other, 4)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	update, ok := <-updates
+	if !ok || update.Balance != 6 {
+		log.Printf("[%s] - expected update with balance 6, got %d", testName, update.Balance)
+		t.Fail()
+	}
+	cancel()
+	for range updates {
+	}
+}
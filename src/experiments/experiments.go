@@ -0,0 +1,296 @@
+// Package experiments sweeps grids of simulation parameters: every combination of bucket size,
+// lookup concurrency, drop rate and cluster size is run once per seed, and the measurements of
+// each combination are summarised with 95% confidence intervals across the seeds. Results are
+// written as CSV or JSON.
+package experiments
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"main/src/bench"
+	"main/src/kademlia"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DEFAULT_LOOKUPS = 100 // node lookups measured per run when the grid does not set Lookups
+
+// The parameters swept by Sweep. Every combination of K, Alpha, Fanout, Drop and Sizes is one
+// configuration, each run once per seed. Empty parameter lists use the package defaults.
+type Grid struct {
+	K        []int     // bucket size and replication
+	Alpha    []int     // find node queries in flight per lookup
+	Fanout   []int     // contacts asked for per find node query, zero for K
+	Drop     []float32 // probability that the simnet drops a RPC
+	Sizes    []int     // cluster sizes
+	Seeds    []int64   // seeds of the simnets, at least two are needed for a confidence interval
+	Lookups  int       // node lookups measured per run, zero uses DEFAULT_LOOKUPS
+	Parallel int       // runs in flight at once, one or less runs them one after another
+}
+
+// One combination of the grid's parameters.
+type Params struct {
+	K      int
+	Alpha  int
+	Fanout int
+	Drop   float32
+	Size   int
+}
+
+// Measurements of a configuration run with a single seed.
+type Run struct {
+	Params
+	Seed    int64
+	Join    time.Duration // from spawning the first node until every node has joined
+	Success float64       // share of lookups whose closest contact was their target
+	Latency time.Duration // mean lookup duration
+	RPCs    float64       // find node queries per lookup
+	Hops    float64       // longest chain of queries per lookup
+}
+
+// Mean of a measurement across seeds and its 95% confidence interval.
+type Estimate struct {
+	Mean float64 `json:"mean"`
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// Summary of a configuration's runs, durations in milliseconds.
+type Result struct {
+	K       int      `json:"k"`
+	Alpha   int      `json:"alpha"`
+	Fanout  int      `json:"fanout"`
+	Drop    float32  `json:"drop"`
+	Size    int      `json:"size"`
+	Runs    int      `json:"runs"`
+	Join    Estimate `json:"join_ms"`
+	Success Estimate `json:"success"`
+	Latency Estimate `json:"latency_ms"`
+	RPCs    Estimate `json:"rpcs"`
+	Hops    Estimate `json:"hops"`
+}
+
+// Returns every combination of the grid's parameters, in the order Sweep reports them.
+func (grid Grid) Configurations() []Params {
+	ks := orDefault(grid.K, kademlia.REPLICATION)
+	alphas := orDefault(grid.Alpha, kademlia.CONCURRENCY)
+	fanouts := orDefault(grid.Fanout, 0)
+	drops := orDefault(grid.Drop, 0)
+	sizes := orDefault(grid.Sizes, bench.MIN_SIZE)
+	res := make([]Params, 0, len(ks)*len(alphas)*len(fanouts)*len(drops)*len(sizes))
+	for _, k := range ks {
+		for _, alpha := range alphas {
+			for _, fanout := range fanouts {
+				for _, drop := range drops {
+					for _, size := range sizes {
+						res = append(res, Params{k, alpha, fanout, drop, size})
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+func orDefault[T any](values []T, def T) []T {
+	if len(values) == 0 {
+		return []T{def}
+	}
+	return values
+}
+
+// Returns an error naming the first parameter out of range.
+func (grid Grid) validate() error {
+	for _, params := range grid.Configurations() {
+		if params.Size < 1 {
+			return errors.New(fmt.Sprintf("cluster size must be positive, got %d", params.Size))
+		}
+		if params.Drop < 0 || params.Drop > 1 {
+			return errors.New(fmt.Sprintf("drop rate must be between 0 and 1, got %v", params.Drop))
+		}
+		if err := params.config().Validate(); err != nil {
+			return err
+		}
+	}
+	if grid.Lookups < 0 {
+		return errors.New(fmt.Sprintf("lookups must not be negative, got %d", grid.Lookups))
+	}
+	return nil
+}
+
+func (params Params) config() kademlia.Config {
+	config := kademlia.DefaultConfig()
+	config.BucketSize = params.K
+	config.Replication = params.K
+	config.Concurrency = params.Alpha
+	config.ResponseSize = params.Fanout
+	return config
+}
+
+// Runs every configuration of the grid once per seed, grid.Parallel runs at a time, and
+// summarises the runs of each configuration. progress, if not nil, is called with each run as it
+// completes, possibly from several goroutines at once.
+// Returns one result per configuration in the order of Configurations, or an error if a
+// parameter is out of range.
+func Sweep(grid Grid, progress func(Run)) ([]Result, error) {
+	if err := grid.validate(); err != nil {
+		return nil, err
+	}
+	lookups := grid.Lookups
+	if lookups == 0 {
+		lookups = DEFAULT_LOOKUPS
+	}
+	seeds := orDefault(grid.Seeds, 1)
+	configs := grid.Configurations()
+	runs := make([][]Run, len(configs))
+	for i := range runs {
+		runs[i] = make([]Run, len(seeds))
+	}
+
+	type job struct{ config, seed int }
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	var progressLock sync.Mutex
+	for range max(grid.Parallel, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				run := RunOnce(configs[j.config], seeds[j.seed], lookups)
+				runs[j.config][j.seed] = run
+				if progress != nil {
+					progressLock.Lock()
+					progress(run)
+					progressLock.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range configs {
+		for j := range seeds {
+			jobs <- job{i, j}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	res := make([]Result, len(configs))
+	for i, params := range configs {
+		res[i] = Summarise(params, runs[i])
+	}
+	return res, nil
+}
+
+// Forms a cluster with the given parameters and seed and measures lookups node lookups in it.
+// The parameters must be valid, see Sweep.
+func RunOnce(params Params, seed int64, lookups int) Run {
+	simnet, _ := kademlia.NewSeededServerWithConfig(false, params.Drop, seed, params.config())
+	simnet.Silence()
+	go simnet.StartServer()
+	defer simnet.Shutdown()
+	done := make(chan struct{}, 1)
+	start := time.Now()
+	nodes := simnet.SpawnCluster(params.Size, done)
+	<-done
+	res := Run{Params: params, Seed: seed, Join: time.Since(start)}
+	lookup := bench.Lookups(&bench.Cluster{Simnet: simnet, Nodes: nodes}, lookups)
+	if lookup.Lookups > 0 {
+		res.Success = float64(lookup.Succeeded) / float64(lookup.Lookups)
+	}
+	res.Latency, res.RPCs, res.Hops = lookup.Mean, lookup.RPCs, lookup.Hops
+	return res
+}
+
+// Summarises runs of the same configuration.
+func Summarise(params Params, runs []Run) Result {
+	res := Result{K: params.K, Alpha: params.Alpha, Fanout: params.Fanout, Drop: params.Drop, Size: params.Size, Runs: len(runs)}
+	measure := func(get func(run Run) float64) Estimate {
+		samples := make([]float64, len(runs))
+		for i, run := range runs {
+			samples[i] = get(run)
+		}
+		return estimate(samples)
+	}
+	res.Join = measure(func(run Run) float64 { return milliseconds(run.Join) })
+	res.Success = measure(func(run Run) float64 { return run.Success })
+	res.Latency = measure(func(run Run) float64 { return milliseconds(run.Latency) })
+	res.RPCs = measure(func(run Run) float64 { return run.RPCs })
+	res.Hops = measure(func(run Run) float64 { return run.Hops })
+	return res
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Two-sided 95% quantiles of Student's t distribution by degrees of freedom, starting at one.
+var tQuantiles = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+const NORMAL_QUANTILE = 1.960 // two-sided 95% quantile used past the t table
+
+// Returns the mean of the samples and its 95% confidence interval, which is the mean alone for
+// fewer than two samples.
+func estimate(samples []float64) Estimate {
+	if len(samples) == 0 {
+		return Estimate{}
+	}
+	mean := 0.0
+	for _, s := range samples {
+		mean += s
+	}
+	mean /= float64(len(samples))
+	if len(samples) < 2 {
+		return Estimate{mean, mean, mean}
+	}
+	variance := 0.0
+	for _, s := range samples {
+		variance += (s - mean) * (s - mean)
+	}
+	variance /= float64(len(samples) - 1)
+	quantile := NORMAL_QUANTILE
+	if df := len(samples) - 1; df <= len(tQuantiles) {
+		quantile = tQuantiles[df-1]
+	}
+	half := quantile * math.Sqrt(variance/float64(len(samples)))
+	return Estimate{mean, mean - half, mean + half}
+}
+
+// Writes the results as CSV with a header row, each measurement as its mean and the bounds of
+// its confidence interval.
+func WriteCSV(out io.Writer, results []Result) error {
+	w := csv.NewWriter(out)
+	header := []string{"k", "alpha", "fanout", "drop", "size", "runs"}
+	for _, name := range []string{"join_ms", "success", "latency_ms", "rpcs", "hops"} {
+		header = append(header, name, name+"_low", name+"_high")
+	}
+	w.Write(header)
+	for _, r := range results {
+		row := []string{strconv.Itoa(r.K), strconv.Itoa(r.Alpha), strconv.Itoa(r.Fanout), strconv.FormatFloat(float64(r.Drop), 'g', -1, 32), strconv.Itoa(r.Size), strconv.Itoa(r.Runs)}
+		for _, e := range []Estimate{r.Join, r.Success, r.Latency, r.RPCs, r.Hops} {
+			row = append(row, formatFloat(e.Mean), formatFloat(e.Low), formatFloat(e.High))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+// Writes the results as an indented JSON array.
+func WriteJSON(out io.Writer, results []Result) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
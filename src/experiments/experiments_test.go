@@ -0,0 +1,81 @@
+package experiments
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"math"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	testName := "TestEstimate"
+	e := estimate([]float64{1, 2, 3})
+	// mean 2, sample deviation 1, t quantile with 2 degrees of freedom 4.303
+	half := 4.303 / math.Sqrt(3)
+	if e.Mean != 2 || math.Abs(e.Low-(2-half)) > 1e-9 || math.Abs(e.High-(2+half)) > 1e-9 {
+		log.Printf("[%s] - unexpected estimate %+v", testName, e)
+		t.Fail()
+	}
+	if e := estimate([]float64{5}); e != (Estimate{5, 5, 5}) {
+		log.Printf("[%s] - expected a single sample to have no interval, got %+v", testName, e)
+		t.Fail()
+	}
+}
+
+func TestSweep(t *testing.T) {
+	testName := "TestSweep"
+	if _, err := Sweep(Grid{K: []int{0}}, nil); err == nil {
+		log.Printf("[%s] - swept a grid with K=0", testName)
+		t.Fail()
+	}
+	if _, err := Sweep(Grid{Drop: []float32{2}}, nil); err == nil {
+		log.Printf("[%s] - swept a grid with a drop rate of 2", testName)
+		t.Fail()
+	}
+
+	if _, err := Sweep(Grid{K: []int{5}, Fanout: []int{6}}, nil); err == nil {
+		log.Printf("[%s] - swept a grid with a fanout larger than K", testName)
+		t.Fail()
+	}
+
+	grid := Grid{K: []int{5, 10}, Sizes: []int{10}, Seeds: []int64{1, 2}, Lookups: 20, Parallel: 2}
+	runs := 0
+	results, err := Sweep(grid, func(Run) { runs++ })
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	if len(results) != 2 || runs != 4 {
+		log.Printf("[%s] - expected 2 results of 4 runs, got %d results and %d runs", testName, len(results), runs)
+		t.FailNow()
+	}
+	for i, r := range results {
+		if r.K != grid.K[i] || r.Runs != 2 || r.Success.Mean <= 0 || r.RPCs.Low > r.RPCs.Mean || r.RPCs.High < r.RPCs.Mean {
+			log.Printf("[%s] - unexpected result %+v", testName, r)
+			t.Fail()
+		}
+	}
+
+	out := bytes.Buffer{}
+	if err := WriteCSV(&out, results); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(rows) != 3 || rows[0][0] != "k" || rows[1][0] != "5" || rows[2][0] != "10" {
+		log.Printf("[%s] - unexpected csv %v: %v", testName, rows, err)
+		t.Fail()
+	}
+	out.Reset()
+	if err := WriteJSON(&out, results); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	decoded := make([]Result, 0)
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1] != results[1] {
+		log.Printf("[%s] - json did not round trip: %v", testName, err)
+		t.Fail()
+	}
+}
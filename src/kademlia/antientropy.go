@@ -0,0 +1,100 @@
+package kademlia
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+)
+
+// Counters of the anti-entropy rounds a node has run, see Config.AntiEntropy.
+type AntiEntropyStats struct {
+	Rounds    int // reconciliations with a co-validator that completed
+	Failed    int // reconciliations whose peer failed to answer
+	Divergent int // completed reconciliations in which the node and its peer disagreed
+	Leaves    int // wallet tree leaves found to differ over all reconciliations
+	Repaired  int // wallets added or brought up to date over all reconciliations
+}
+
+type antiEntropyCounters struct {
+	sync.Mutex
+	stats AntiEntropyStats
+}
+
+func newAntiEntropyCounters() *antiEntropyCounters {
+	return &antiEntropyCounters{}
+}
+
+func (counters *antiEntropyCounters) record(rec Reconciliation, err error) {
+	counters.Lock()
+	defer counters.Unlock()
+	if err != nil {
+		counters.stats.Failed++
+		return
+	}
+	counters.stats.Rounds++
+	if rec.Divergent() {
+		counters.stats.Divergent++
+	}
+	counters.stats.Leaves += rec.Leaves
+	counters.stats.Repaired += rec.Repaired
+}
+
+// Returns the counters of the node's anti-entropy rounds. Divergent over Rounds is the share of
+// replica pairs found out of sync, and drops towards zero as the replicas converge.
+func (node *Node) AntiEntropyStats() AntiEntropyStats {
+	node.antiEntropy.Lock()
+	defer node.antiEntropy.Unlock()
+	return node.antiEntropy.stats
+}
+
+// Returns one of the other nodes the node knows among the validators of a random wallet it holds,
+// the K closest unless the wallet was stored with a replication of its own, or false if it holds
+// no wallet or knows no other node.
+func (node *Node) randomCoValidator() (Contact, bool) {
+	wallets := node.scalegraph.StoredAccounts()
+	if len(wallets) == 0 {
+		return Contact{}, false
+	}
+	id := KademliaID(wallets[rand.Intn(len(wallets))])
+	closest, _ := node.FindXClosest(node.config.Replication, id)
+	closest = closest[:min(len(closest), node.walletReplication(node.heldReplication(id)))]
+	if len(closest) == 0 {
+		return Contact{}, false
+	}
+	return closest[rand.Intn(len(closest))], true
+}
+
+// Runs one anti-entropy round: the node reconciles the wallets it shares with a co-validator of a
+// random wallet it holds, see Reconcile, and counts the outcome in its stats and metrics.
+// Returns an error if the node has no co-validator to pick or the peer failed to answer.
+func (node *Node) AntiEntropy() (Reconciliation, error) {
+	peer, ok := node.randomCoValidator()
+	if !ok {
+		return Reconciliation{}, errors.New("no co-validator to reconcile with")
+	}
+	rec, err := node.Reconcile(peer)
+	node.antiEntropy.record(rec, err)
+	if err == nil {
+		node.Network.metrics.WalletsReconciled(rec.Leaves, rec.Repaired)
+	}
+	return rec, err
+}
+
+// Runs an anti-entropy round every Config.AntiEntropy until the node stops.
+func (node *Node) antiEntropyLoop() {
+	ticker := node.timebase.NewTicker(node.config.AntiEntropy)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-node.Network.listener.Done():
+			return
+		case <-ticker.C():
+		}
+		if node.Role() == OBSERVER {
+			continue
+		}
+		if _, err := node.AntiEntropy(); err != nil {
+			node.logger.Debug("anti-entropy round failed", "err", err)
+		}
+	}
+}
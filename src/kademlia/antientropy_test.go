@@ -0,0 +1,69 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+	"time"
+)
+
+func TestAntiEntropy(t *testing.T) {
+	testName := "TestAntiEntropy"
+	config := DefaultConfig()
+	config.AntiEntropy = 50 * time.Millisecond
+	s, err := NewServerWithConfig(false, 0.0, config)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	s.Silence()
+	done := make(chan struct{}, 1)
+	go s.StartServer()
+	nodes := s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	holders := make([]*Node, 0)
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err == nil {
+			holders = append(holders, n)
+		}
+	}
+	// a single replica learns of a transaction and the others pull it from one another
+	holders[0].scalegraph.ApplyTransaction(id, scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 5))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		current := 0
+		for _, n := range holders {
+			if wallet, err := n.Ledger().Wallet(id); err == nil && wallet.Balance == 15 {
+				current++
+			}
+		}
+		if current == len(holders) {
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Printf("[%s] - replicas did not converge, %d of %d current", testName, current, len(holders))
+			t.FailNow()
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	var total AntiEntropyStats
+	for _, n := range holders {
+		stats := n.AntiEntropyStats()
+		total.Rounds += stats.Rounds
+		total.Divergent += stats.Divergent
+		total.Repaired += stats.Repaired
+	}
+	if total.Repaired < len(holders)-1 || total.Divergent < total.Repaired || total.Rounds < total.Divergent {
+		log.Printf("[%s] - unexpected counters: %+v", testName, total)
+		t.Fail()
+	}
+}
@@ -0,0 +1,138 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+)
+
+const AUDIT_NONCE_BYTES = 32 // length of the nonces an audit challenges validators with
+
+// Set a RPC as a challenge to prove the receiver holds the wallet, see AuditWallet.
+func (rpc *RPC) AuditWallet(walletID KademliaID, nonce []byte) {
+	rpc.cmd = AUDIT_WALLET
+	rpc.accountID = walletID
+	rpc.auditNonce = nonce
+}
+
+// Set a RPC as the answer to a wallet audit, proof is the digest of the wallet's state under the
+// audit's nonce and is only meaningful if the wallet was found.
+func (rpc *RPC) AuditedWallet(walletID KademliaID, proof []byte, found bool) {
+	rpc.cmd = AUDITED_WALLET
+	rpc.accountID = walletID
+	rpc.auditProof = proof
+	rpc.walletStored = found
+}
+
+// Returns the digest of the wallet's state under nonce, see scalegraph.Account.Digest, or an error
+// if the node does not hold the wallet.
+func (ledger *Ledger) Digest(id KademliaID, nonce []byte) ([]byte, error) {
+	acc, err := ledger.scale.FindAccount(id)
+	if err != nil {
+		return nil, err
+	}
+	digest := acc.Digest(nonce)
+	return digest[:], nil
+}
+
+// Outcome of auditing the replicas of a wallet, each of its validators in exactly one list.
+// The reference state is the one proven by the most validators, ties going to the state of the
+// validator closest to the wallet.
+type WalletAudit struct {
+	Wallet      KademliaID
+	Validators  []Contact // the K closest nodes of the wallet, closest first
+	Agreeing    []Contact // validators proving the reference state
+	Divergent   []Contact // validators proving a different state
+	Missing     []Contact // validators that do not hold the wallet
+	Unreachable []Contact // validators that did not answer
+}
+
+// Returns true if every validator proved the reference state.
+func (audit WalletAudit) Consistent() bool {
+	return len(audit.Validators) > 0 && len(audit.Agreeing) == len(audit.Validators)
+}
+
+func (audit WalletAudit) Display() string {
+	return fmt.Sprintf("wallet: %v validators: %d agreeing: %d divergent: %d missing: %d unreachable: %d\n",
+		audit.Wallet, len(audit.Validators), len(audit.Agreeing), len(audit.Divergent), len(audit.Missing), len(audit.Unreachable))
+}
+
+// Challenges each of the wallet's validators in parallel to prove it holds the wallet's state, by
+// hashing its copy together with a fresh nonce, and compares the proofs to find the replicas
+// that diverge from the others.
+func (node *Node) AuditWallet(id KademliaID) WalletAudit {
+	nonce := make([]byte, AUDIT_NONCE_BYTES)
+	rand.Read(nonce)
+	validators := node.FindNode(id)
+	type answer struct {
+		reachable bool
+		found     bool
+		proof     []byte
+	}
+	answers := make([]answer, len(validators))
+	done := make(chan struct{}, len(validators))
+	for i, val := range validators {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.AuditWallet(id, nonce)
+		node.routines.Go("audit wallet", func() {
+			defer func() { done <- struct{}{} }()
+			res, err := node.Send(rpc)
+			if err == nil {
+				answers[i] = answer{true, res.walletStored, res.auditProof}
+			}
+		})
+	}
+	for range validators {
+		<-done
+	}
+
+	// the reference is the most common proof, validators are closest first so ties go to the closest
+	var reference []byte
+	votes := 0
+	for _, a := range answers {
+		if !a.found {
+			continue
+		}
+		count := 0
+		for _, b := range answers {
+			if b.found && bytes.Equal(a.proof, b.proof) {
+				count++
+			}
+		}
+		if count > votes {
+			reference, votes = a.proof, count
+		}
+	}
+	audit := WalletAudit{
+		Wallet:      id,
+		Validators:  validators,
+		Agreeing:    make([]Contact, 0, len(validators)),
+		Divergent:   make([]Contact, 0),
+		Missing:     make([]Contact, 0),
+		Unreachable: make([]Contact, 0),
+	}
+	for i, a := range answers {
+		switch {
+		case !a.reachable:
+			audit.Unreachable = append(audit.Unreachable, validators[i])
+		case !a.found:
+			audit.Missing = append(audit.Missing, validators[i])
+		case bytes.Equal(a.proof, reference):
+			audit.Agreeing = append(audit.Agreeing, validators[i])
+		default:
+			audit.Divergent = append(audit.Divergent, validators[i])
+		}
+	}
+	if len(audit.Divergent) > 0 {
+		node.logger.Warn("wallet replicas diverge", "wallet", id, "agreeing", len(audit.Agreeing), "divergent", len(audit.Divergent))
+	}
+	return audit
+}
+
+// Response logic for an incoming audit wallet RPC.
+func (node *Node) handleAuditWallet(rpc *RPC) {
+	proof, err := node.Ledger().Digest(rpc.accountID, rpc.auditNonce)
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.AuditedWallet(rpc.accountID, proof, err == nil)
+	node.Send(resp)
+}
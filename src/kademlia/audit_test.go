@@ -0,0 +1,58 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+)
+
+func TestAuditWallet(t *testing.T) {
+	testName := "TestAuditWallet"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	audit := nodes[1].AuditWallet(id)
+	if !audit.Consistent() {
+		log.Printf("[%s] - expected consistent replicas:\n%s", testName, audit.Display())
+		t.Fail()
+	}
+
+	// one replica applies a transaction the others never saw and another loses the wallet
+	var diverged, lost *Node
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err != nil {
+			continue
+		}
+		if diverged == nil {
+			diverged = n
+		} else if lost == nil {
+			lost = n
+		}
+	}
+	diverged.scalegraph.ApplyTransaction(id, scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 5))
+	lost.scalegraph.RemoveAccount(id)
+
+	audit = nodes[1].AuditWallet(id)
+	if audit.Consistent() || len(audit.Agreeing) != len(audit.Validators)-2 {
+		log.Printf("[%s] - expected two bad replicas:\n%s", testName, audit.Display())
+		t.Fail()
+	}
+	if len(audit.Divergent) != 1 || audit.Divergent[0].ID() != diverged.ID() {
+		log.Printf("[%s] - expected %v to diverge, got %v", testName, diverged.ID(), audit.Divergent)
+		t.Fail()
+	}
+	if len(audit.Missing) != 1 || audit.Missing[0].ID() != lost.ID() {
+		log.Printf("[%s] - expected %v to miss the wallet, got %v", testName, lost.ID(), audit.Missing)
+		t.Fail()
+	}
+}
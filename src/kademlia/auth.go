@@ -0,0 +1,177 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"main/src/scalegraph"
+)
+
+// Returns the bytes covered by a RPC's signature: the header, the sender and receiver, the
+// sender's public key and every field a command carries. Channels and the receiver's own
+// bookkeeping, such as when the RPC was queued, are not covered.
+func (rpc *RPC) signingData() []byte {
+	data := make([]byte, 0, 256)
+	appendID := func(id KademliaID) {
+		raw := id.Bytes()
+		data = append(data, raw[:]...)
+	}
+	appendBytes := func(b []byte) {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(b)))
+		data = append(data, b...)
+	}
+	appendContact := func(con Contact) {
+		appendID(con.id)
+		data = append(data, con.ip[:]...)
+		data = binary.BigEndian.AppendUint16(data, uint16(con.port))
+	}
+	appendTransaction := func(trx *scalegraph.Transaction) {
+		if trx == nil {
+			trx = &scalegraph.Transaction{}
+		}
+		appendBytes(trx.SigningData())
+		appendBytes(trx.Signature())
+	}
+	var flags uint16
+	for i, flag := range []bool{rpc.response, rpc.storeAccSucc, rpc.findAccountSucc, rpc.snapshotDone, rpc.snapshotFailed, rpc.appendSucc,
+		rpc.balanceFound, rpc.messageStored, rpc.walletStored, rpc.accepted, rpc.commit, rpc.valueFound, rpc.valueCached, rpc.joinRejected} {
+		if flag {
+			flags |= 1 << i
+		}
+	}
+
+	appendID(rpc.id)
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.cmd))
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.network))
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.version))
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.minVersion))
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.role))
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.caps))
+	data = binary.BigEndian.AppendUint16(data, flags)
+	appendContact(rpc.sender)
+	data = append(data, rpc.receiver[:]...)
+	appendBytes(rpc.senderKey)
+
+	appendID(rpc.findNodeTarget)
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.findNodeCount))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(rpc.foundNodes)))
+	for _, con := range rpc.foundNodes {
+		appendContact(con)
+	}
+	appendID(rpc.accountID)
+	appendBytes([]byte(rpc.displayString))
+	appendID(rpc.blockID)
+	appendTransaction(&rpc.transaction)
+	appendID(rpc.transactionID)
+	appendBytes(rpc.payload)
+	appendID(rpc.snapshotID)
+	data = binary.BigEndian.AppendUint64(data, uint64(rpc.snapshotOffset))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(rpc.snapshotAccounts)))
+	for _, acc := range rpc.snapshotAccounts {
+		appendID(acc.ID)
+		data = binary.BigEndian.AppendUint32(data, uint32(len(acc.Blocks)))
+		for _, block := range acc.Blocks {
+			appendID(block.ID())
+			appendID(block.PrevID())
+			appendTransaction(block.Transaction)
+		}
+	}
+	data = binary.BigEndian.AppendUint64(data, rpc.balance)
+	for _, msg := range append([]Message{rpc.message}, rpc.messages...) {
+		appendID(msg.ID)
+		appendID(msg.Sender)
+		appendID(msg.Recipient)
+		appendBytes(msg.Payload)
+		data = binary.BigEndian.AppendUint64(data, uint64(msg.Expires.UnixNano()))
+	}
+	appendID(rpc.wallet.ID)
+	data = binary.BigEndian.AppendUint64(data, rpc.wallet.Balance)
+	data = binary.BigEndian.AppendUint64(data, uint64(rpc.wallet.Transactions))
+	appendBytes(rpc.wallet.PublicKey)
+	data = binary.BigEndian.AppendUint64(data, rpc.wallet.Nonce)
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.wallet.Replication))
+	appendBytes(rpc.publicKey)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(rpc.walletStates)))
+	for _, state := range rpc.walletStates {
+		appendID(state.ID)
+		appendBytes(state.PublicKey)
+		data = binary.BigEndian.AppendUint32(data, uint32(state.Replication))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(state.Transactions)))
+		for _, trx := range state.Transactions {
+			appendTransaction(&trx)
+		}
+	}
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.unknownCmd))
+	appendBytes(rpc.value)
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.replication))
+	appendID(rpc.publication.ID)
+	appendID(rpc.publication.Topic)
+	appendID(rpc.publication.Publisher)
+	appendBytes(rpc.publication.Data)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(rpc.transfers)))
+	for _, val := range rpc.transfers {
+		appendID(val.key)
+		appendBytes(val.data)
+		data = binary.BigEndian.AppendUint64(data, uint64(val.ttl))
+		data = binary.BigEndian.AppendUint32(data, uint32(val.replication))
+	}
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.hops))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(rpc.path)))
+	for _, con := range rpc.path {
+		appendContact(con)
+	}
+	appendBytes(rpc.joinChallenge)
+	appendBytes(rpc.joinProof)
+	appendBytes(rpc.auditNonce)
+	appendBytes(rpc.auditProof)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(rpc.merkleNodes)))
+	for _, n := range rpc.merkleNodes {
+		data = binary.BigEndian.AppendUint32(data, uint32(n.Depth))
+		data = binary.BigEndian.AppendUint32(data, uint32(n.Index))
+		data = append(data, n.Hash[:]...)
+	}
+	appendID(rpc.session)
+	appendBytes(rpc.sessionKey)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(rpc.batch)))
+	for _, sub := range rpc.batch {
+		appendBytes(sub.signingData())
+	}
+	return data
+}
+
+// Signs the RPC with the sender's private key.
+func (rpc *RPC) sign(key ed25519.PrivateKey) {
+	rpc.signature = ed25519.Sign(key, rpc.signingData())
+}
+
+// Returns an error unless the RPC carries a valid signature by the public key its sender
+// presented.
+func (rpc *RPC) verifySignature() error {
+	if len(rpc.senderKey) != ed25519.PublicKeySize || len(rpc.signature) != ed25519.SignatureSize {
+		return errors.New(fmt.Sprintf("rpc %v is not signed", rpc.id))
+	}
+	if !ed25519.Verify(rpc.senderKey, rpc.signingData(), rpc.signature) {
+		return errors.New(fmt.Sprintf("rpc %v has a bad signature", rpc.id))
+	}
+	return nil
+}
+
+// Returns an error unless the sender of the RPC proves its id, see VerifyIdentity, and signed the
+// RPC. ENTER responses are written by the simnet's entry service on behalf of the requester, they
+// are not signed.
+func (net *Network) authenticate(rpc *RPC) error {
+	if err := VerifyIdentity(rpc.sender.ID(), rpc.senderKey, net.puzzle); err != nil {
+		return err
+	}
+	if rpc.cmd == ENTER && rpc.response {
+		return nil
+	}
+	return rpc.verifySignature()
+}
+
+// Returns the number of incoming RPCs the node dropped because their sender could not prove its
+// id or their signature was invalid.
+func (net *Network) Rejected() uint64 {
+	return net.rejected.Load()
+}
@@ -0,0 +1,73 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestRPCSignature(t *testing.T) {
+	testName := "TestRPCSignature"
+	identity, _ := GenerateIdentity(0)
+	sender := NewContact([4]byte{10, 0, 0, 1}, identity.ID())
+	rpc := GenerateRPC([4]byte{10, 0, 0, 2}, sender)
+	rpc.FoundNodes(RandomID(), []Contact{NewContact([4]byte{10, 0, 0, 3}, RandomID())})
+	if rpc.verifySignature() == nil {
+		log.Printf("[%s] - verified an unsigned rpc", testName)
+		t.Fail()
+	}
+	rpc.senderKey = identity.PublicKey
+	rpc.sign(identity.PrivateKey)
+	if err := rpc.verifySignature(); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+
+	tampered := rpc
+	tampered.cmd = PING
+	if tampered.verifySignature() == nil {
+		log.Printf("[%s] - verified a rpc with a changed command", testName)
+		t.Fail()
+	}
+	tampered = rpc
+	tampered.foundNodes = []Contact{NewContact([4]byte{10, 0, 0, 4}, RandomID())}
+	if tampered.verifySignature() == nil {
+		log.Printf("[%s] - verified a rpc with changed contacts", testName)
+		t.Fail()
+	}
+	other, _ := GenerateIdentity(0)
+	tampered = rpc
+	tampered.senderKey = other.PublicKey
+	if tampered.verifySignature() == nil {
+		log.Printf("[%s] - verified a rpc against another key", testName)
+		t.Fail()
+	}
+}
+
+func TestSimnetAuthentication(t *testing.T) {
+	testName := "TestSimnetAuthentication"
+	done := make(chan struct{}, 1)
+	s, _ := NewServerWithIdentities(false, 0.0, 2)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	if nodes[1].Rejected() != 0 {
+		log.Printf("[%s] - rejected %d rpcs from honest nodes", testName, nodes[1].Rejected())
+		t.Fail()
+	}
+	s.SetLinkPolicy(nodes[0].IP(), nodes[1].IP(), LinkPolicy{Corrupt: 1})
+	answered := 0
+	for range 5 {
+		rpc := GenerateRPC(nodes[1].IP(), nodes[0].Contact)
+		rpc.FindNode(nodes[2].ID())
+		if _, err := nodes[0].Send(rpc); err == nil {
+			answered++
+		}
+	}
+	if nodes[1].Rejected() == 0 || nodes[1].Rejected()+uint64(answered) != 5 {
+		log.Printf("[%s] - expected every corrupted rpc to be rejected or answered, rejected %d answered %d", testName, nodes[1].Rejected(), answered)
+		t.Fail()
+	}
+}
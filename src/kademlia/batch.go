@@ -0,0 +1,157 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	MAX_BATCH     = 256         // requests carried by a single BATCH
+	BATCH_TIMEOUT = TIMEOUT / 2 // how long a receiver waits for the answers to a batch, leaving the sender time to get them
+)
+
+// Commands that can not be batched: ENTER is answered by the simnet, locks hold the handler
+// until they are released and batches do not nest.
+var unbatchable = map[cmd]bool{
+	ENTER:               true,
+	LOCK_ACCOUNT:        true,
+	UNLOCK_ACCOUNT:      true,
+	BATCH:               true,
+	RECURSIVE_FIND_NODE: true, // forwarding may outlast the batch
+}
+
+// Set a RPC as a batch of requests to its receiver.
+func (rpc *RPC) Batch(requests []RPC) {
+	rpc.cmd = BATCH
+	rpc.batch = requests
+}
+
+// Set a RPC as the answers to a batch, in the order of its requests.
+func (rpc *RPC) Batched(responses []RPC) {
+	rpc.cmd = BATCHED
+	rpc.batch = responses
+}
+
+// Sends requests to receiver in a single BATCH and returns the answers in the same order.
+// Requests are made with GenerateRPC and the usual setters, the batch's sender and receiver
+// replace their own. A request the receiver did not answer within BATCH_TIMEOUT, or dropped
+// for exceeding a rate limit, has a response with command NO_CMD.
+// Returns an error if the batch is too large, holds a request that can not be batched, or the
+// batch itself fails.
+func (node *Node) SendBatch(receiver [4]byte, requests []RPC) ([]RPC, error) {
+	if len(requests) == 0 || len(requests) > MAX_BATCH {
+		return nil, errors.New(fmt.Sprintf("a batch holds 1 to %d requests, got %d", MAX_BATCH, len(requests)))
+	}
+	for _, req := range requests {
+		if req.response || unbatchable[req.cmd] {
+			return nil, errors.New(fmt.Sprintf("rpc %v with command %s can not be batched", req.id, req.cmd))
+		}
+	}
+	rpc := GenerateRPC(receiver, node.Contact)
+	rpc.Batch(requests)
+	res, err := node.Send(rpc)
+	if err != nil {
+		return nil, err
+	}
+	if res.cmd != BATCHED || len(res.batch) != len(requests) {
+		return nil, errors.New(fmt.Sprintf("node %v answered a batch of %d with %s carrying %d responses", receiver, len(requests), res.cmd, len(res.batch)))
+	}
+	return res.batch, nil
+}
+
+// Responses of the batched requests a node is handling, keyed by the local id each request is
+// handled under.
+type batchTable struct {
+	content map[KademliaID]chan RPC
+	sync.Mutex
+}
+
+func newBatchTable() *batchTable {
+	return &batchTable{
+		content: make(map[KademliaID]chan RPC),
+	}
+}
+
+func (table *batchTable) open(id KademliaID) chan RPC {
+	table.Lock()
+	defer table.Unlock()
+	ch := make(chan RPC, 1)
+	table.content[id] = ch
+	return ch
+}
+
+func (table *batchTable) close(id KademliaID) {
+	table.Lock()
+	defer table.Unlock()
+	delete(table.content, id)
+}
+
+// Hands a response to the batch waiting for it, returns false if it does not answer a batched
+// request.
+func (table *batchTable) deliver(rpc RPC) bool {
+	table.Lock()
+	ch, ok := table.content[rpc.id]
+	delete(table.content, rpc.id)
+	table.Unlock()
+	if ok {
+		ch <- rpc
+	}
+	return ok
+}
+
+// Response logic for an incoming batch.
+// Every request is handled as if the batch's sender had sent it on its own, under a fresh local
+// id so batches of different senders can not collide, and their responses are gathered into a
+// single BATCHED response instead of being sent one by one.
+func (node *Node) handleBatch(rpc *RPC) {
+	responses := make([]RPC, len(rpc.batch))
+	pending := make([]chan RPC, len(rpc.batch))
+	ids := make([]KademliaID, len(rpc.batch))
+	for i, req := range rpc.batch {
+		if len(rpc.batch) > MAX_BATCH || req.response || unbatchable[req.cmd] {
+			responses[i] = GenerateResponse(req.id, rpc.sender.IP(), node.Contact)
+			responses[i].UnknownCommand(req.cmd)
+			continue
+		}
+		ids[i] = RandomID()
+		pending[i] = node.batches.open(ids[i])
+		req.id = ids[i]
+		req.sender, req.receiver = rpc.sender, node.IP()
+		req.network, req.version, req.minVersion, req.role, req.caps = rpc.network, rpc.version, rpc.minVersion, rpc.role, rpc.caps
+		node.Handler(&req)
+	}
+
+	deadline := node.timebase.After(node.config.scaled(BATCH_TIMEOUT))
+	for i, ch := range pending {
+		if ch == nil {
+			continue
+		}
+		select {
+		case res := <-ch:
+			res.id = rpc.batch[i].id
+			responses[i] = res
+		case <-deadline:
+			// every later request is out of time as well
+			deadline = expired
+		case <-node.Network.listener.Done():
+			deadline = expired
+		}
+		if responses[i].cmd == NO_CMD {
+			node.batches.close(ids[i])
+			responses[i] = GenerateResponse(rpc.batch[i].id, rpc.sender.IP(), node.Contact)
+			node.logger.Debug("batched request went unanswered", "rpc", rpc.id, "cmd", rpc.batch[i].cmd)
+		}
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.Batched(responses)
+	node.Send(resp)
+}
+
+// A channel that is always ready, standing in for a deadline that has passed.
+var expired = func() <-chan time.Time {
+	ch := make(chan time.Time)
+	close(ch)
+	return ch
+}()
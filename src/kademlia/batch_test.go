@@ -0,0 +1,71 @@
+package kademlia
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	testName := "TestBatch"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+	from, to := nodes[0], nodes[1]
+
+	data := []byte("batched value")
+	key := NewKeyFromData(data)
+	requests := make([]RPC, 0)
+	add := func(set func(rpc *RPC)) {
+		rpc := GenerateRPC(to.IP(), from.Contact)
+		set(&rpc)
+		requests = append(requests, rpc)
+	}
+	add(func(rpc *RPC) { rpc.Ping() })
+	add(func(rpc *RPC) { rpc.StoreValue(key, data, false) })
+	add(func(rpc *RPC) { rpc.FindValue(key) })
+	add(func(rpc *RPC) { rpc.FindNode(nodes[5].ID()) })
+	add(func(rpc *RPC) { rpc.Custom(USER_CMD+77, nil) })
+	add(func(rpc *RPC) { rpc.InsertAccount(RandomID()) })
+
+	stored := s.Stats().Routed[STORE_VALUE]
+	responses, err := from.SendBatch(to.IP(), requests)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	expected := []cmd{PONG, STORED_VALUE, FOUND_VALUE, FOUND_NODES, UNKNOWN_COMMAND, NO_CMD}
+	for i, res := range responses {
+		if res.cmd != expected[i] || res.id != requests[i].id {
+			log.Printf("[%s] - expected %s answering %v, got %s answering %v", testName, expected[i], requests[i].id, res.cmd, res.id)
+			t.Fail()
+		}
+	}
+	if !responses[1].valueFound || !bytes.Equal(responses[2].value, data) {
+		log.Printf("[%s] - the batched store and find did not see each other", testName)
+		t.Fail()
+	}
+	if len(responses[3].foundNodes) == 0 {
+		log.Printf("[%s] - batched find node returned no contacts", testName)
+		t.Fail()
+	}
+	if routed := s.Stats().Routed[STORE_VALUE]; routed != stored {
+		log.Printf("[%s] - batched store was routed on its own %d times", testName, routed-stored)
+		t.Fail()
+	}
+
+	lock := GenerateRPC(to.IP(), from.Contact)
+	lock.LockAccount(RandomID(), nil)
+	if _, err := from.SendBatch(to.IP(), []RPC{requests[0], lock}); err == nil {
+		log.Printf("[%s] - batched a lock request", testName)
+		t.Fail()
+	}
+	if _, err := from.SendBatch(to.IP(), nil); err == nil {
+		log.Printf("[%s] - sent an empty batch", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,103 @@
+package kademlia
+
+import (
+	"math/rand"
+)
+
+// Misbehavior of a node, for simulating attacks on the lookup and wallet layers. A node with a
+// behavior offers every request it receives to the behavior before handling it, see SetBehavior.
+type Behavior interface {
+	// Handles the request in place of the node. Returns false to let the node handle it as usual.
+	// The RPC is recycled once the node is done with it, copy it to keep it past the call.
+	Handle(node *Node, rpc *RPC) bool
+}
+
+// Makes the node misbehave, nil restores honest behavior. Must be called before the node is
+// started.
+func (node *Node) SetBehavior(b Behavior) {
+	node.behavior = b
+}
+
+// Spawns a node that misbehaves as b.
+func (simnet *Simnet) SpawnNodeWithBehavior(b Behavior, done chan KademliaID) *Node {
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	newNode := simnet.generateNode(config, DEFAULT_NETWORK, KademliaID{}, FULL_NODE)
+	newNode.SetBehavior(b)
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
+	return newNode
+}
+
+// Offers a request to each behavior in turn until one handles it.
+type Behaviors []Behavior
+
+func (behaviors Behaviors) Handle(node *Node, rpc *RPC) bool {
+	for _, b := range behaviors {
+		if b.Handle(node, rpc) {
+			return true
+		}
+	}
+	return false
+}
+
+// Answers FIND_NODE with contacts picked at random from the routing table instead of the closest.
+type RandomContacts struct{}
+
+func (RandomContacts) Handle(node *Node, rpc *RPC) bool {
+	if rpc.Cmd() != FIND_NODE {
+		return false
+	}
+	contacts := node.AllContacts()
+	rand.Shuffle(len(contacts), func(i, j int) { contacts[i], contacts[j] = contacts[j], contacts[i] })
+	resp := rpc.Reply(node.Contact)
+	resp.FoundNodes(rpc.FindNodeTarget(), contacts[:min(len(contacts), node.config.Replication)])
+	node.routines.Go("respond", func() { node.Send(resp) })
+	return true
+}
+
+// Answers FIND_NODE for Target with the Colluders only, trying to hide Target from the network
+// behind nodes under the attacker's control.
+type Eclipse struct {
+	Target    KademliaID
+	Colluders []Contact
+}
+
+func (eclipse Eclipse) Handle(node *Node, rpc *RPC) bool {
+	if rpc.Cmd() != FIND_NODE || rpc.FindNodeTarget() != eclipse.Target {
+		return false
+	}
+	resp := rpc.Reply(node.Contact)
+	resp.FoundNodes(eclipse.Target, eclipse.Colluders[:min(len(eclipse.Colluders), node.config.Replication)])
+	node.routines.Go("respond", func() { node.Send(resp) })
+	return true
+}
+
+// Never answers FIND_NODE, so lookups through the node time out.
+type DropFindNode struct{}
+
+func (DropFindNode) Handle(node *Node, rpc *RPC) bool {
+	return rpc.Cmd() == FIND_NODE
+}
+
+// Claims to hold every wallet asked for, with the given balance.
+type FakeWallet struct {
+	Balance uint64
+}
+
+func (fake FakeWallet) Handle(node *Node, rpc *RPC) bool {
+	resp := rpc.Reply(node.Contact)
+	switch rpc.Cmd() {
+	case SHOW_WALLET:
+		resp.ShownWallet(Wallet{ID: rpc.AccountID(), Balance: fake.Balance, Transactions: 1}, true)
+	case FIND_BALANCE:
+		resp.FoundBalance(rpc.AccountID(), fake.Balance, true)
+	case AUDIT_WALLET:
+		proof := RandomID().Bytes()
+		resp.AuditedWallet(rpc.AccountID(), proof[:], true)
+	default:
+		return false
+	}
+	node.Send(resp)
+	return true
+}
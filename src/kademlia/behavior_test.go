@@ -0,0 +1,55 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestBehaviors(t *testing.T) {
+	testName := "TestBehaviors"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(6, done)
+	<-done
+	defer s.Shutdown()
+
+	target := nodes[0].ID()
+	colluders := []Contact{nodes[4].Contact, nodes[5].Contact}
+	spawned := make(chan KademliaID, 1)
+	adversary := s.SpawnNodeWithBehavior(Behaviors{Eclipse{target, colluders}, FakeWallet{1000}}, spawned)
+	<-spawned
+	dropper := s.SpawnNodeWithBehavior(DropFindNode{}, spawned)
+	<-spawned
+
+	res := nodes[1].findNodeQuery(adversary.Contact, target)
+	if res.err != nil || len(res.found) != len(colluders) || res.found[0].ID() != nodes[4].ID() || res.found[1].ID() != nodes[5].ID() {
+		log.Printf("[%s] - expected the eclipse to answer with the colluders, got %v", testName, res.found)
+		t.Fail()
+	}
+	res = nodes[1].findNodeQuery(adversary.Contact, nodes[2].ID())
+	if res.err != nil || len(res.found) == 0 || res.found[0].ID() != nodes[2].ID() {
+		log.Printf("[%s] - expected an honest answer for other targets, got %v", testName, res.found)
+		t.Fail()
+	}
+	res = nodes[1].findNodeQuery(dropper.Contact, target)
+	if res.err == nil {
+		log.Printf("[%s] - expected the dropped find node to time out", testName)
+		t.Fail()
+	}
+
+	rpc := GenerateRPC(adversary.IP(), nodes[1].Contact)
+	rpc.ShowWallet(target)
+	resp, err := nodes[1].Send(rpc)
+	if err != nil || !resp.walletStored || resp.wallet.ID != target || resp.wallet.Balance != 1000 {
+		log.Printf("[%s] - expected a fake wallet, got %+v", testName, resp.wallet)
+		t.Fail()
+	}
+	rpc = GenerateRPC(adversary.IP(), nodes[1].Contact)
+	rpc.Ping()
+	if _, err := nodes[1].Send(rpc); err != nil {
+		log.Printf("[%s] - unhandled requests were not left to the node: %s", testName, err.Error())
+		t.Fail()
+	}
+}
@@ -0,0 +1,197 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	ENTER_ATTEMPTS    = 8           // requests for entry points a joining node makes before giving up
+	ENTER_BACKOFF     = TIMEOUT / 4 // wait after the first failed request, doubled after every failure
+	ENTER_MAX_BACKOFF = 8 * TIMEOUT // upper bound on the wait between ENTER attempts
+
+	ENTER_VERIFY_ATTEMPTS = 3 // peers asked to find a node that has just joined
+)
+
+// Provides a joining node with the contacts it enters the network through, see Node.Enter.
+// EntryPoints is called again after a backoff if it fails or returns no usable contacts.
+type Bootstrapper interface {
+	EntryPoints(node *Node) ([]Contact, error)
+}
+
+// Enters through the node's master node, the default of nodes created with NewNode.
+type MasterNodeBootstrap struct{}
+
+func (MasterNodeBootstrap) EntryPoints(node *Node) ([]Contact, error) {
+	if node.masterNode.ID().IsZero() {
+		return nil, errors.New("node has no master node")
+	}
+	return []Contact{node.masterNode}, nil
+}
+
+// Enters through a fixed list of known peers.
+type StaticPeerList struct {
+	Peers []Contact
+}
+
+func (list StaticPeerList) EntryPoints(node *Node) ([]Contact, error) {
+	return list.Peers, nil
+}
+
+// Enters through the addresses that seed host names resolve to, in the style of DNS seeds.
+// Addresses carry no node ids, so each address is pinged to learn the contact behind it.
+type SeedResolver struct {
+	Seeds  []string
+	Lookup func(host string) ([][4]byte, error) // resolves a seed, nil uses the system resolver
+}
+
+func (seeds SeedResolver) EntryPoints(node *Node) ([]Contact, error) {
+	lookup := seeds.Lookup
+	if lookup == nil {
+		lookup = lookupIPv4
+	}
+	res := make([]Contact, 0)
+	var lastErr error
+	for _, seed := range seeds.Seeds {
+		addrs, err := lookup(seed)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, addr := range addrs {
+			con, err := node.probe(addr)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			res = append(res, con)
+		}
+	}
+	if len(res) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return res, nil
+}
+
+// Resolves host with the system resolver, keeping only its IPv4 addresses.
+func lookupIPv4(host string) ([][4]byte, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	res := make([][4]byte, 0, len(ips))
+	for _, ip := range ips {
+		if v4 := ip.To4(); v4 != nil {
+			res = append(res, [4]byte(v4))
+		}
+	}
+	return res, nil
+}
+
+// Pings the address and returns the contact that answered.
+func (node *Node) probe(address [4]byte) (Contact, error) {
+	rpc := GenerateRPC(address, node.Contact)
+	rpc.Ping()
+	res, err := node.Send(rpc)
+	if err != nil {
+		return Contact{}, errors.New(fmt.Sprintf("seed %v did not answer: %v", address, err))
+	}
+	return res.sender, nil
+}
+
+// Enters through the simnet's entry service, which answers ENTER requests with random members of
+// the node's network. The master node is added to the entry points of DEFAULT_NETWORK nodes, the
+// network it belongs to. A node whose network has no other member yet is given no entry points and
+// retries, so the first nodes of a network join each other as they appear. If the service requires
+// a join token the node answers its challenge with the token set by SetJoinToken.
+type EntryService struct{}
+
+func (EntryService) EntryPoints(node *Node) ([]Contact, error) {
+	rpc := GenerateRPC(node.IP(), node.Contact)
+	rpc.Enter()
+	res, err := node.Send(rpc)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.joinChallenge) > 0 {
+		rpc = GenerateRPC(node.IP(), node.Contact)
+		rpc.EnterWithProof(res.joinChallenge, joinProof(node.joinToken, res.joinChallenge, node.ID()))
+		res, err = node.Send(rpc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if res.joinRejected {
+		return nil, failure(ErrJoinRejected, "entry service rejected the join token of node %v", node.ID())
+	}
+	if node.NetworkID() == DEFAULT_NETWORK {
+		return append(res.foundNodes, node.masterNode), nil
+	}
+	return res.foundNodes, nil
+}
+
+// Sets how the node finds its entry points, must be called before the node is started.
+func (node *Node) SetBootstrapper(bootstrap Bootstrapper) {
+	node.bootstrap = bootstrap
+}
+
+// Fault injected into the simnet's entry service, which answers ENTER requests.
+type BootstrapFault struct {
+	Unavailable bool          // ENTER requests are dropped
+	Delay       time.Duration // added before each ENTER request is answered
+}
+
+// A joining node failed to enter the network and waits before trying again.
+type EnterRetried struct {
+	Node    Contact
+	Attempt int
+	Backoff time.Duration
+}
+
+func (EnterRetried) event() {}
+
+type bootstrapFault struct {
+	fault BootstrapFault
+	until time.Time // zero if the fault lasts until cleared
+	sync.RWMutex
+}
+
+// Applies the fault to the entry service for duration, or until cleared if duration is zero.
+// Nodes that have already joined are not affected, only ENTER requests are.
+func (simnet *Simnet) SetBootstrapFault(fault BootstrapFault, duration time.Duration) {
+	simnet.bootstrap.Lock()
+	defer simnet.bootstrap.Unlock()
+	simnet.bootstrap.fault = fault
+	simnet.bootstrap.until = time.Time{}
+	if duration > 0 {
+		simnet.bootstrap.until = simnet.timebase.Now().Add(duration)
+	}
+}
+
+func (simnet *Simnet) ClearBootstrapFault() {
+	simnet.SetBootstrapFault(BootstrapFault{}, 0)
+}
+
+// Returns the fault currently applied to the entry service.
+func (simnet *Simnet) BootstrapFault() BootstrapFault {
+	simnet.bootstrap.RLock()
+	defer simnet.bootstrap.RUnlock()
+	if !simnet.bootstrap.until.IsZero() && simnet.timebase.Now().After(simnet.bootstrap.until) {
+		return BootstrapFault{}
+	}
+	return simnet.bootstrap.fault
+}
+
+// Returns the wait before the next ENTER attempt, with up to a quarter of random jitter so
+// that nodes failing together do not retry in lockstep.
+func (config Config) enterBackoff(attempt int) time.Duration {
+	backoff := config.scaled(ENTER_BACKOFF) << (attempt - 1)
+	if ceiling := config.scaled(ENTER_MAX_BACKOFF); backoff > ceiling || backoff <= 0 {
+		backoff = ceiling
+	}
+	return backoff + time.Duration(rand.Int63n(int64(backoff/4)+1))
+}
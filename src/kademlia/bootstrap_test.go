@@ -0,0 +1,189 @@
+package kademlia
+
+import (
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBootstrapOutageJoinedNodes(t *testing.T) {
+	testName := "TestBootstrapOutageJoinedNodes"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(6, done)
+	<-done
+
+	s.SetBootstrapFault(BootstrapFault{Unavailable: true}, 0)
+	if !nodes[0].Ping(nodes[1].IP()) {
+		log.Printf("[%s] - joined nodes could not reach each other during the outage", testName)
+		t.Fail()
+	}
+	found := nodes[0].FindNode(nodes[len(nodes)-1].ID())
+	if len(found) == 0 || found[0].ID() != nodes[len(nodes)-1].ID() {
+		log.Printf("[%s] - lookup failed during the outage", testName)
+		t.Fail()
+	}
+	s.ClearBootstrapFault()
+	if s.BootstrapFault() != (BootstrapFault{}) {
+		log.Printf("[%s] - fault still active after clearing", testName)
+		t.Fail()
+	}
+	s.Shutdown()
+}
+
+func TestBootstrapOutageJoinerBackoff(t *testing.T) {
+	testName := "TestBootstrapOutageJoinerBackoff"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	s.SpawnCluster(3, done)
+	<-done
+	events, cancel := s.Events().Subscribe(1 << 12)
+
+	outage := 2 * time.Second
+	s.SetBootstrapFault(BootstrapFault{Unavailable: true}, outage)
+	start := time.Now()
+	joined := make(chan KademliaID, 1)
+	joiner := s.SpawnNode(joined)
+	joiner.SetLogLevel(LOG_SILENT)
+	select {
+	case <-joined:
+	case <-time.After(outage + 4*ENTER_MAX_BACKOFF):
+		log.Printf("[%s] - joiner never finished entering", testName)
+		t.FailNow()
+	}
+	if time.Since(start) < outage {
+		log.Printf("[%s] - joiner entered during the outage", testName)
+		t.Fail()
+	}
+	cancel()
+
+	var last time.Duration
+	retries, enters := 0, 0
+	for e := range events {
+		switch e := e.(type) {
+		case EnterRetried:
+			if e.Node.ID() != joiner.ID() {
+				continue
+			}
+			retries++
+			if e.Backoff < last {
+				log.Printf("[%s] - backoff shrank from %v to %v", testName, last, e.Backoff)
+				t.Fail()
+			}
+			last = e.Backoff
+		case RPCSent:
+			if e.Cmd == ENTER && e.Sender.ID() == joiner.ID() && !e.Response {
+				enters++
+			}
+		}
+	}
+	if retries == 0 || enters > ENTER_ATTEMPTS {
+		log.Printf("[%s] - expected a few backed off retries, got %d retries and %d ENTER requests", testName, retries, enters)
+		t.Fail()
+	}
+	s.Shutdown()
+}
+
+func TestBootstrapSlowEntry(t *testing.T) {
+	testName := "TestBootstrapSlowEntry"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	s.SpawnCluster(3, done)
+	<-done
+
+	delay := 100 * time.Millisecond
+	s.SetBootstrapFault(BootstrapFault{Delay: delay}, 0)
+	start := time.Now()
+	joined := make(chan KademliaID, 1)
+	s.SpawnNode(joined)
+	<-joined
+	if time.Since(start) < delay {
+		log.Printf("[%s] - joiner entered faster than the entry delay", testName)
+		t.Fail()
+	}
+	s.Shutdown()
+}
+
+func TestEnterVerifiesDiscoverability(t *testing.T) {
+	testName := "TestEnterVerifiesDiscoverability"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	joiner := s.GenerateRandomNode()
+	joiner.SetLogLevel(LOG_SILENT)
+	go joiner.Network.Listen(joiner)
+	if err := joiner.Enter(); err != nil {
+		log.Printf("[%s] - joining a healthy network failed: %s", testName, err.Error())
+		t.Fail()
+	}
+
+	// A node whose every outgoing RPC is lost reaches the entry service, which answers it
+	// directly, but never any peer.
+	isolated := s.GenerateRandomNode()
+	isolated.SetLogLevel(LOG_SILENT)
+	for _, n := range append(nodes, joiner, s.masterNode) {
+		s.SetLinkPolicy(isolated.IP(), n.IP(), LinkPolicy{Drop: 1})
+	}
+	go isolated.Network.Listen(isolated)
+	if err := isolated.Enter(); err == nil {
+		log.Printf("[%s] - isolated node joined without reaching any peer", testName)
+		t.Fail()
+	}
+}
+
+func TestPluggableBootstrappers(t *testing.T) {
+	testName := "TestPluggableBootstrappers"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	// None of the bootstrappers below may depend on the simnet's entry service.
+	s.SetBootstrapFault(BootstrapFault{Unavailable: true}, 0)
+
+	seeds := map[string][][4]byte{"seed.scalegraph": {nodes[1].IP(), nodes[2].IP()}}
+	strategies := map[string]Bootstrapper{
+		"master":   MasterNodeBootstrap{},
+		"static":   StaticPeerList{Peers: []Contact{nodes[0].Contact, nodes[3].Contact}},
+		"resolver": SeedResolver{Seeds: []string{"seed.scalegraph"}, Lookup: func(host string) ([][4]byte, error) { return seeds[host], nil }},
+	}
+	for name, strategy := range strategies {
+		joiner := s.GenerateRandomNode()
+		joiner.SetLogLevel(LOG_SILENT)
+		joiner.SetBootstrapper(strategy)
+		go joiner.Network.Listen(joiner)
+		if err := joiner.Enter(); err != nil {
+			log.Printf("[%s] - joining through %s failed: %s", testName, name, err.Error())
+			t.Fail()
+			continue
+		}
+		if !slices.Contains(nodes[4].FindNode(joiner.ID()), joiner.Contact) {
+			log.Printf("[%s] - node joined through %s is not discoverable", testName, name)
+			t.Fail()
+		}
+	}
+
+	joiner := s.GenerateRandomNode()
+	joiner.SetLogLevel(LOG_SILENT)
+	joiner.SetBootstrapper(SeedResolver{Seeds: []string{"unknown.scalegraph"}, Lookup: func(host string) ([][4]byte, error) { return seeds[host], nil }})
+	go joiner.Network.Listen(joiner)
+	if err := joiner.Enter(); err == nil {
+		log.Printf("[%s] - joined through a seed that resolves to nothing", testName)
+		t.Fail()
+	}
+}
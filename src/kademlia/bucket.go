@@ -7,6 +7,8 @@ import (
 	"sync"
 )
 
+var errDuplicateContact = errors.New("cannot add two instances of a contact to a single bucket")
+
 type Bucket struct {
 	homeNode Contact
 	content  []Contact
@@ -26,26 +28,36 @@ func NewBucket(maxCapacity int, homeNode Contact) *Bucket {
 // Adds given contact if there is empty capacity in bucket or it is closer to the home node than another node.
 // Otherwise returns an error.
 func (bucket *Bucket) AddContact(contact Contact) error {
+	_, _, err := bucket.add(contact)
+	return err
+}
+
+// Adds the contact as AddContact does, and returns the contact evicted to make room for it.
+// The bool is false if no contact was evicted.
+func (bucket *Bucket) add(contact Contact) (Contact, bool, error) {
 	bucket.Lock()
 	defer bucket.Unlock()
 
 	for _, v := range bucket.content {
 		if v.ID() == contact.ID() {
-			return errors.New("cannot add two instances of a contact to a single bucket")
+			return Contact{}, false, errDuplicateContact
 		}
 	}
 	bucket.content = append(bucket.content, contact)
 	SortContactsByDistance(&bucket.content, bucket.homeNode.ID())
 	var err error
+	var evicted Contact
+	var ok bool
 	if len(bucket.content) > bucket.capacity {
-		if bucket.content[len(bucket.content)-1].ID() == contact.ID() {
+		evicted = bucket.content[len(bucket.content)-1]
+		if evicted.ID() == contact.ID() {
 			err = errors.New("contact not added")
+		} else {
+			ok = true
 		}
-	} else {
-		err = nil
 	}
 	bucket.content = bucket.content[:min(bucket.capacity, len(bucket.content))]
-	return err
+	return evicted, ok, err
 }
 
 // Searches the bucket for any node with a matching IP address and returns it if found.
@@ -58,23 +70,30 @@ func (bucket *Bucket) FindByIP(ip [4]byte) (Contact, error) {
 			return c, nil
 		}
 	}
-	return Contact{}, errors.New("no match")
+	return Contact{}, ErrNodeNotFound
 }
 
 // Removes contact from bucket if it is present.
 func (bucket *Bucket) RemoveContact(contact Contact) {
+	bucket.remove(contact)
+}
+
+// Removes contact from bucket, returns false if it was not present.
+func (bucket *Bucket) remove(contact Contact) bool {
 	bucket.Lock()
 	defer bucket.Unlock()
 
 	for i, v := range bucket.content {
 		if v.ID() == contact.ID() {
 			bucket.content = slices.Delete(bucket.content, i, i+1)
+			return true
 		}
 	}
+	return false
 }
 
 // Returns up to x contacts from the bucket.
-func (bucket *Bucket) FindXClosest(x int, target [5]uint32) []Contact {
+func (bucket *Bucket) FindXClosest(x int, target KademliaID) []Contact {
 	bucket.Lock()
 	defer bucket.Unlock()
 	res := make([]Contact, 0, x)
@@ -86,7 +105,7 @@ func (bucket *Bucket) FindXClosest(x int, target [5]uint32) []Contact {
 
 // Returns a contact with matching ID to target if present.
 // Otherwise returns an error.
-func (bucket *Bucket) FindContact(target [5]uint32) (Contact, error) {
+func (bucket *Bucket) FindContact(target KademliaID) (Contact, error) {
 	bucket.Lock()
 	defer bucket.Unlock()
 	for _, v := range bucket.content {
@@ -94,7 +113,7 @@ func (bucket *Bucket) FindContact(target [5]uint32) (Contact, error) {
 			return v, nil
 		}
 	}
-	return Contact{}, errors.New("contact not found")
+	return Contact{}, ErrNodeNotFound
 }
 
 func (bucket *Bucket) Display() string {
@@ -110,7 +129,7 @@ func (bucket *Bucket) Display() string {
 func (bucket *Bucket) DumpBucket() []Contact {
 	bucket.Lock()
 	defer bucket.Unlock()
-	con := make([]Contact, 0, KBUCKETVOLUME)
+	con := make([]Contact, 0, bucket.capacity)
 	con = append(con, bucket.content...)
 	return con
 }
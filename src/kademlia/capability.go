@@ -0,0 +1,107 @@
+package kademlia
+
+import (
+	"strings"
+	"sync"
+)
+
+// Services a node offers besides routing, advertised on every RPC it sends.
+type Capability uint8
+
+const (
+	RELAY              Capability = 1 << iota // forwards RPCs for peers that can not reach each other
+	ARCHIVAL                                  // keeps the full transaction history of its wallets
+	GATEWAY                                   // serves clients, see package client
+	VALIDATOR_ELIGIBLE                        // may be chosen as a validator
+)
+
+const DEFAULT_CAPABILITIES = VALIDATOR_ELIGIBLE // capabilities of a node created with NewNode
+
+// Returns true if every capability in want is present.
+func (caps Capability) Has(want Capability) bool {
+	return caps&want == want
+}
+
+func (caps Capability) String() string {
+	names := make([]string, 0, 4)
+	for _, c := range []struct {
+		flag Capability
+		name string
+	}{{RELAY, "RELAY"}, {ARCHIVAL, "ARCHIVAL"}, {GATEWAY, "GATEWAY"}, {VALIDATOR_ELIGIBLE, "VALIDATOR_ELIGIBLE"}} {
+		if caps.Has(c.flag) {
+			names = append(names, c.name)
+		}
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	return strings.Join(names, "|")
+}
+
+// Sets the capabilities the node advertises, must be called before the node is started.
+func (net *Network) SetCapabilities(caps Capability) {
+	net.caps = caps
+}
+
+func (net *Network) Capabilities() Capability {
+	return net.caps
+}
+
+// Capabilities of the contacts in a routing table, learned from the RPCs they sent.
+type capabilityTable struct {
+	content map[KademliaID]Capability
+	sync.RWMutex
+}
+
+func newCapabilityTable() *capabilityTable {
+	return &capabilityTable{
+		content: make(map[KademliaID]Capability),
+	}
+}
+
+// Records the capabilities a contact advertised.
+func (router *RoutingTable) learnCapabilities(id KademliaID, caps Capability) {
+	router.caps.Lock()
+	defer router.caps.Unlock()
+	router.caps.content[id] = caps
+}
+
+func (router *RoutingTable) forgetCapabilities(id KademliaID) {
+	router.caps.Lock()
+	defer router.caps.Unlock()
+	delete(router.caps.content, id)
+}
+
+// Returns the capabilities the contact advertised, or false if it has not been heard from.
+func (router *RoutingTable) ContactCapabilities(id KademliaID) (Capability, bool) {
+	router.caps.RLock()
+	defer router.caps.RUnlock()
+	caps, ok := router.caps.content[id]
+	return caps, ok
+}
+
+// Returns the x contacts closest to target that are known to have every capability in want.
+func (router *RoutingTable) FindXClosestWith(x int, target KademliaID, want Capability) []Contact {
+	res := make([]Contact, 0, x)
+	for _, con := range router.AllContacts() {
+		if caps, ok := router.ContactCapabilities(con.ID()); ok && caps.Has(want) {
+			res = append(res, con)
+		}
+	}
+	SortContactsByDistance(&res, target)
+	if len(res) > x {
+		res = res[:x]
+	}
+	return res
+}
+
+// Spawns a node advertising caps instead of DEFAULT_CAPABILITIES.
+func (simnet *Simnet) SpawnNodeWithCapabilities(caps Capability, done chan KademliaID) *Node {
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	newNode := simnet.generateNode(config, DEFAULT_NETWORK, KademliaID{}, FULL_NODE)
+	newNode.SetCapabilities(caps)
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
+	return newNode
+}
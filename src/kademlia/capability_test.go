@@ -0,0 +1,71 @@
+package kademlia
+
+import (
+	"log"
+	"slices"
+	"testing"
+)
+
+func TestCapabilityString(t *testing.T) {
+	testName := "TestCapabilityString"
+	cases := map[Capability]string{
+		0:                    "NONE",
+		RELAY:                "RELAY",
+		RELAY | GATEWAY:      "RELAY|GATEWAY",
+		DEFAULT_CAPABILITIES: "VALIDATOR_ELIGIBLE",
+		ARCHIVAL | RELAY:     "RELAY|ARCHIVAL",
+		RELAY | ARCHIVAL | GATEWAY | VALIDATOR_ELIGIBLE: "RELAY|ARCHIVAL|GATEWAY|VALIDATOR_ELIGIBLE",
+	}
+	for caps, expected := range cases {
+		if caps.String() != expected {
+			log.Printf("[%s] - expected %s, got %s", testName, expected, caps.String())
+			t.Fail()
+		}
+	}
+	if !(RELAY | GATEWAY).Has(RELAY) || RELAY.Has(RELAY|GATEWAY) {
+		log.Printf("[%s] - Has must require every wanted capability", testName)
+		t.Fail()
+	}
+}
+
+func TestCapabilitiesLearned(t *testing.T) {
+	testName := "TestCapabilitiesLearned"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+
+	joined := make(chan KademliaID, 2)
+	relay := s.SpawnNodeWithCapabilities(RELAY|VALIDATOR_ELIGIBLE, joined)
+	archive := s.SpawnNodeWithCapabilities(ARCHIVAL, joined)
+	<-joined
+	<-joined
+
+	peer := nodes[0]
+	peer.Ping(relay.IP())
+	peer.Ping(archive.IP())
+	caps, ok := peer.ContactCapabilities(relay.ID())
+	if !ok || caps != RELAY|VALIDATOR_ELIGIBLE {
+		log.Printf("[%s] - expected the relay's advertised capabilities, got %v", testName, caps)
+		t.Fail()
+	}
+	relays := peer.FindXClosestWith(REPLICATION, RandomID(), RELAY)
+	if len(relays) != 1 || relays[0] != relay.Contact {
+		log.Printf("[%s] - expected only the relay to be selected, got %d contacts", testName, len(relays))
+		t.Fail()
+	}
+	validators := peer.FindXClosestWith(REPLICATION, RandomID(), VALIDATOR_ELIGIBLE)
+	if slices.Contains(validators, archive.Contact) {
+		log.Printf("[%s] - archival node without validator eligibility selected as validator", testName)
+		t.Fail()
+	}
+
+	peer.RemoveContact(relay.Contact)
+	if _, ok := peer.ContactCapabilities(relay.ID()); ok {
+		log.Printf("[%s] - capabilities kept after the contact was removed", testName)
+		t.Fail()
+	}
+}
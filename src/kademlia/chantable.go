@@ -0,0 +1,74 @@
+package kademlia
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+const CHAN_TABLE_SHARDS = 64 // shards of a simnet's chanTable, picked by the first byte of an IP
+
+// Maps node IPs to their inboxes, the simnet only ever delivers through the inbox so it never
+// writes to a channel that a stopped node has closed.
+// Every RPC looks up its receiver while nodes are only added and removed now and then, so each
+// shard's map is replaced instead of modified: lookups load the current map without taking a
+// lock, and writers copy the shard, change the copy and swap it in under the shard's mutex.
+type chanTable struct {
+	shards [CHAN_TABLE_SHARDS]chanShard
+}
+
+type chanShard struct {
+	content atomic.Pointer[map[[4]byte]*inbox]
+	sync.Mutex
+}
+
+func newChanTable() *chanTable {
+	table := chanTable{}
+	for i := range table.shards {
+		empty := make(map[[4]byte]*inbox)
+		table.shards[i].content.Store(&empty)
+	}
+	return &table
+}
+
+func (table *chanTable) shard(ip [4]byte) *chanShard {
+	return &table.shards[int(ip[0])%CHAN_TABLE_SHARDS]
+}
+
+// Returns the inbox of the node at ip.
+func (table *chanTable) lookup(ip [4]byte) (*inbox, bool) {
+	inbox, ok := (*table.shard(ip).content.Load())[ip]
+	return inbox, ok
+}
+
+func (table *chanTable) insert(ip [4]byte, inbox *inbox) {
+	shard := table.shard(ip)
+	shard.Lock()
+	defer shard.Unlock()
+	content := maps.Clone(*shard.content.Load())
+	content[ip] = inbox
+	shard.content.Store(&content)
+}
+
+func (table *chanTable) remove(ip [4]byte) {
+	shard := table.shard(ip)
+	shard.Lock()
+	defer shard.Unlock()
+	if _, ok := (*shard.content.Load())[ip]; !ok {
+		return
+	}
+	content := maps.Clone(*shard.content.Load())
+	delete(content, ip)
+	shard.content.Store(&content)
+}
+
+// Returns the IPs of every node in the table, in no particular order.
+func (table *chanTable) ips() [][4]byte {
+	res := make([][4]byte, 0)
+	for i := range table.shards {
+		for ip := range *table.shards[i].content.Load() {
+			res = append(res, ip)
+		}
+	}
+	return res
+}
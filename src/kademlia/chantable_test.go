@@ -0,0 +1,114 @@
+package kademlia
+
+import (
+	"log"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestChanTable(t *testing.T) {
+	testName := "TestChanTable"
+	table := newChanTable()
+	ips := make([][4]byte, 0)
+	for i := range 300 {
+		ip := [4]byte{byte(i), byte(i >> 8), 1, 2}
+		ips = append(ips, ip)
+		table.insert(ip, newInbox(make(chan RPC, 1)))
+	}
+	for _, ip := range ips {
+		if _, ok := table.lookup(ip); !ok {
+			log.Printf("[%s] - inserted ip %v not found", testName, ip)
+			t.Fail()
+		}
+	}
+	table.remove(ips[0])
+	table.remove([4]byte{9, 9, 9, 9})
+	if _, ok := table.lookup(ips[0]); ok {
+		log.Printf("[%s] - removed ip %v still found", testName, ips[0])
+		t.Fail()
+	}
+	listed := table.ips()
+	slices.SortFunc(listed, CompareIP)
+	expected := slices.Clone(ips[1:])
+	slices.SortFunc(expected, CompareIP)
+	if !slices.Equal(listed, expected) {
+		log.Printf("[%s] - expected %d ips listed, got %d", testName, len(expected), len(listed))
+		t.Fail()
+	}
+
+	// lookups racing with writers, run with -race
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 200 {
+				ip := [4]byte{byte(j), byte(i), 7, 7}
+				table.insert(ip, nil)
+				table.lookup(ips[j])
+				table.remove(ip)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(table.ips()) != len(expected) {
+		log.Printf("[%s] - expected the racing writers to leave %d ips, got %d", testName, len(expected), len(table.ips()))
+		t.Fail()
+	}
+}
+
+// Single map behind a read-write mutex, the table before it was sharded, kept to compare against.
+type lockedChanTable struct {
+	content map[[4]byte]*inbox
+	sync.RWMutex
+}
+
+func (table *lockedChanTable) lookup(ip [4]byte) (*inbox, bool) {
+	table.RLock()
+	defer table.RUnlock()
+	inbox, ok := table.content[ip]
+	return inbox, ok
+}
+
+func chanTableIPs(count int) [][4]byte {
+	res := make([][4]byte, count)
+	for i := range res {
+		res[i] = [4]byte{byte(rand.Intn(256)), byte(rand.Intn(256)), byte(rand.Intn(256)), byte(rand.Intn(256))}
+	}
+	return res
+}
+
+// Parallel lookups of the receivers of RPCs in a 5000 node simnet.
+func BenchmarkChanTableLookup(b *testing.B) {
+	table := newChanTable()
+	ips := chanTableIPs(5000)
+	for _, ip := range ips {
+		table.insert(ip, nil)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(ips))
+		for pb.Next() {
+			table.lookup(ips[i%len(ips)])
+			i++
+		}
+	})
+}
+
+func BenchmarkLockedChanTableLookup(b *testing.B) {
+	table := lockedChanTable{content: make(map[[4]byte]*inbox)}
+	ips := chanTableIPs(5000)
+	for _, ip := range ips {
+		table.content[ip] = nil
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(ips))
+		for pb.Next() {
+			table.lookup(ips[i%len(ips)])
+			i++
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package kademlia
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A node was replaced by the churn process.
+type NodeChurned struct {
+	Killed  Contact
+	Spawned Contact
+}
+
+func (NodeChurned) event() {}
+
+type churn struct {
+	stop    chan struct{}
+	stopped chan struct{}
+	running bool
+	sync.Mutex
+}
+
+// Starts replacing nodes to simulate churn. Every 1/rate seconds a random node that has been up
+// for at least minUptime is shut down and a fresh node is spawned in its place, and nodes that
+// have been up for longer than maxUptime are replaced on the same schedule.
+// Nodes that were already running when churn started count their uptime from that moment.
+// The master node is never replaced. Returns an error if churn is already running or the
+// parameters are invalid.
+func (simnet *Simnet) StartChurn(rate float64, minUptime time.Duration, maxUptime time.Duration) error {
+	if rate <= 0 {
+		return errors.New("churn rate must be positive")
+	}
+	if maxUptime < minUptime {
+		return errors.New("max uptime must not be below min uptime")
+	}
+	simnet.churn.Lock()
+	defer simnet.churn.Unlock()
+	if simnet.churn.running {
+		return errors.New("churn already running")
+	}
+	simnet.churn.running = true
+	simnet.churn.stop = make(chan struct{})
+	simnet.churn.stopped = make(chan struct{})
+	stop := simnet.churn.stop
+	stopped := simnet.churn.stopped
+	// the ticker is started here so that the schedule is fixed once StartChurn returns
+	ticker := simnet.timebase.NewTicker(time.Duration(float64(time.Second) / rate))
+	start := simnet.timebase.Now()
+	simnet.routines.Go("churn", func() {
+		defer close(stopped)
+		defer ticker.Stop()
+		simnet.churnLoop(ticker, start, minUptime, maxUptime, stop)
+	})
+	return nil
+}
+
+// Stops the churn process and waits for the replacement in progress to finish.
+// Does nothing if churn is not running.
+func (simnet *Simnet) StopChurn() {
+	simnet.churn.Lock()
+	if !simnet.churn.running {
+		simnet.churn.Unlock()
+		return
+	}
+	close(simnet.churn.stop)
+	simnet.churn.running = false
+	stopped := simnet.churn.stopped
+	simnet.churn.Unlock()
+	<-stopped
+}
+
+func (simnet *Simnet) churnLoop(ticker Timer, start time.Time, minUptime time.Duration, maxUptime time.Duration, stop chan struct{}) {
+	born := make(map[*Node]time.Time)
+	uptime := func(n *Node) time.Duration {
+		b, ok := born[n]
+		if !ok {
+			b = start
+		}
+		return simnet.timebase.Since(b)
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-simnet.shutdown:
+			return
+		case <-ticker.C():
+		}
+
+		victims := make([]*Node, 0)
+		eligible := make([]*Node, 0)
+		for _, n := range simnet.AllNodePointers() {
+			if n == simnet.masterNode {
+				continue
+			}
+			age := uptime(n)
+			if age >= maxUptime {
+				victims = append(victims, n)
+			} else if age >= minUptime {
+				eligible = append(eligible, n)
+			}
+		}
+		if len(eligible) > 0 {
+			victims = append(victims, eligible[rand.Intn(len(eligible))])
+		}
+
+		for _, victim := range victims {
+			select {
+			case <-stop:
+				return
+			case <-simnet.shutdown:
+				return
+			default:
+			}
+			err := simnet.ShutdownNode(victim)
+			if err != nil {
+				simnet.logger.Debug("churned node did not shut down cleanly", "err", err)
+			}
+			delete(born, victim)
+			replacement := simnet.SpawnNode(make(chan KademliaID, 1))
+			born[replacement] = simnet.timebase.Now()
+			simnet.stats.recordChurn()
+			simnet.events.Publish(NodeChurned{victim.Contact, replacement.Contact})
+			simnet.logger.Debug("churned node", "killed", victim.ID(), "spawned", replacement.ID())
+		}
+	}
+}
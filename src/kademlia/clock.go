@@ -0,0 +1,103 @@
+package kademlia
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A node's local clock, offset from real time by a fixed skew plus a drift that accumulates
+// from when the clock was set. Timestamps that travel between nodes, such as message expiry,
+// are read against the receiver's clock, so nodes with different clocks disagree about them.
+type clock struct {
+	base  Clock // the time the skew and drift are applied to
+	skew  time.Duration
+	drift float64 // seconds gained per second of real time, negative for a slow clock
+	set   time.Time
+	sync.RWMutex
+}
+
+func newClock() *clock {
+	return &clock{base: SystemClock(), set: time.Now()}
+}
+
+func (clock *clock) Now() time.Time {
+	clock.RLock()
+	defer clock.RUnlock()
+	now := clock.base.Now()
+	if clock.drift == 0 {
+		return now.Add(clock.skew)
+	}
+	drift := time.Duration(float64(now.Sub(clock.set)) * clock.drift)
+	return now.Add(clock.skew + drift)
+}
+
+// Returns how far the clock is ahead of real time, negative if it is behind.
+func (clock *clock) Offset() time.Duration {
+	clock.RLock()
+	base := clock.base
+	clock.RUnlock()
+	return clock.Now().Sub(base.Now())
+}
+
+func (clock *clock) Set(skew time.Duration, drift float64) {
+	clock.Lock()
+	defer clock.Unlock()
+	clock.skew = skew
+	clock.drift = drift
+	clock.set = clock.base.Now()
+}
+
+// Replaces the time the clock is derived from, the skew and drift are kept.
+func (clock *clock) SetBase(base Clock) {
+	clock.Lock()
+	defer clock.Unlock()
+	clock.base = base
+	clock.set = base.Now()
+}
+
+// Returns the current time according to the node's clock.
+func (node *Node) Now() time.Time {
+	return node.clock.Now()
+}
+
+// Offsets the node's clock from real time by skew, after which it gains drift seconds per second.
+func (node *Node) SetClock(skew time.Duration, drift float64) {
+	node.clock.Set(skew, drift)
+}
+
+// Returns how far the node's clock is ahead of real time, negative if it is behind.
+func (node *Node) ClockOffset() time.Duration {
+	return node.clock.Offset()
+}
+
+// Bounds of the clocks given to simulated nodes, each node draws its skew and drift uniformly
+// from [-MaxSkew, MaxSkew] and [-MaxDrift, MaxDrift]. The zero value keeps clocks in sync.
+type ClockConfig struct {
+	MaxSkew  time.Duration
+	MaxDrift float64
+}
+
+// Returns a random skew and drift within the bounds of the config.
+func (config ClockConfig) draw() (time.Duration, float64) {
+	skew := time.Duration(0)
+	if config.MaxSkew > 0 {
+		skew = time.Duration(rand.Int63n(int64(2*config.MaxSkew)+1)) - config.MaxSkew
+	}
+	drift := (2*rand.Float64() - 1) * config.MaxDrift
+	return skew, drift
+}
+
+// Sets the clock bounds of nodes spawned from now on, existing nodes keep their clocks.
+func (simnet *Simnet) SetClockConfig(config ClockConfig) {
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.clockConfig = config
+}
+
+// Draws a new clock within the bounds of config for every live node.
+func (simnet *Simnet) SkewClocks(config ClockConfig) {
+	for _, node := range simnet.AllNodePointers() {
+		node.SetClock(config.draw())
+	}
+}
@@ -0,0 +1,84 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestClockSkewAndDrift(t *testing.T) {
+	testName := "TestClockSkewAndDrift"
+	clock := newClock()
+	clock.Set(-time.Minute, 0)
+	offset := clock.Offset()
+	if offset > -59*time.Second || offset < -61*time.Second {
+		log.Printf("[%s] - expected an offset of -1m, received %v", testName, offset)
+		t.Fail()
+	}
+	clock.Set(0, 1.0)
+	time.Sleep(20 * time.Millisecond)
+	offset = clock.Offset()
+	if offset < 15*time.Millisecond {
+		log.Printf("[%s] - expected a fast clock to gain time, offset %v", testName, offset)
+		t.Fail()
+	}
+}
+
+func TestClockConfigBounds(t *testing.T) {
+	testName := "TestClockConfigBounds"
+	config := ClockConfig{MaxSkew: time.Second, MaxDrift: 0.01}
+	for range 1000 {
+		skew, drift := config.draw()
+		if skew < -config.MaxSkew || skew > config.MaxSkew || drift < -config.MaxDrift || drift > config.MaxDrift {
+			log.Printf("[%s] - drew skew %v and drift %v outside of bounds", testName, skew, drift)
+			t.FailNow()
+		}
+	}
+	skew, drift := ClockConfig{}.draw()
+	if skew != 0 || drift != 0 {
+		log.Printf("[%s] - zero config drew skew %v and drift %v", testName, skew, drift)
+		t.Fail()
+	}
+}
+
+// A message stamped by a clock that is behind by more than its lifetime has already expired for
+// nodes with accurate clocks.
+func TestMessageExpiryUnderSkew(t *testing.T) {
+	testName := "TestMessageExpiryUnderSkew"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.SetClockConfig(ClockConfig{MaxSkew: time.Second, MaxDrift: 0.001})
+	go s.StartServer()
+	nodes := s.SpawnCluster(12, done)
+	<-done
+	defer s.Shutdown()
+
+	sender := nodes[0]
+	recipient := RandomID()
+	err := sender.SendMessage(recipient, []byte("in time"), 30*time.Second)
+	if err != nil {
+		log.Printf("[%s] - message within the skew bounds was refused: %s", testName, err.Error())
+		t.Fail()
+	}
+
+	sender.SetClock(-time.Minute, 0)
+	sender.SendMessage(recipient, []byte("too late"), 30*time.Second)
+	holders := 0
+	for _, n := range nodes[1:] {
+		n.mailbox.Lock()
+		for _, msg := range n.mailbox.stored[recipient] {
+			if string(msg.Payload) == "in time" {
+				holders++
+			} else {
+				log.Printf("[%s] - node %v stored a message stamped by a slow clock", testName, n.ID())
+				t.Fail()
+			}
+		}
+		n.mailbox.Unlock()
+	}
+	if holders == 0 {
+		log.Printf("[%s] - no other node held the message sent in time", testName)
+		t.Fail()
+	}
+}
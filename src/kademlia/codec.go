@@ -0,0 +1,214 @@
+package kademlia
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	ID_BYTES          = ID_WORDS * 4                 // bytes of an id on the wire
+	RAW_CONTACT_BYTES = ID_BYTES + 4 + 2             // id, ip and port of an uncompressed contact
+	CONTACT_REFERENCE = 0xff                         // header of a contact sent as an index into the shared dictionary
+	MAX_CONTACT_LIST  = 4 * KBUCKETVOLUME * KEYSPACE // longest contact list a decoder accepts
+)
+
+// Contacts that both ends of a link already know, such as a routing table exchanged earlier.
+// Contacts found in the dictionary are sent as an index instead of in full. Both ends must build
+// the dictionary from the same contacts, their order does not matter.
+type ContactDictionary struct {
+	contacts []Contact
+	index    map[Contact]int
+}
+
+func NewContactDictionary(contacts []Contact) *ContactDictionary {
+	dict := &ContactDictionary{
+		contacts: slices.Clone(contacts),
+		index:    make(map[Contact]int, len(contacts)),
+	}
+	// contacts sharing an id are ordered by address, so that both ends agree on every index
+	slices.SortFunc(dict.contacts, func(a Contact, b Contact) int { return CompareContacts(a, b, KademliaID{}) })
+	dict.contacts = slices.Compact(dict.contacts)
+	for i, con := range dict.contacts {
+		dict.index[con] = i
+	}
+	return dict
+}
+
+func (dict *ContactDictionary) Len() int {
+	if dict == nil {
+		return 0
+	}
+	return len(dict.contacts)
+}
+
+// Packs the shared id and ip prefix lengths of a literal contact, and whether a port follows,
+// into a single byte.
+func literalHeader(idShared int, ipShared int, port bool) byte {
+	header := idShared*10 + ipShared*2
+	if port {
+		header++
+	}
+	return byte(header)
+}
+
+func parseLiteralHeader(header byte) (int, int, bool, error) {
+	idShared := int(header) / 10
+	ipShared := int(header) % 10 / 2
+	if idShared > ID_BYTES {
+		return 0, 0, false, errors.New(fmt.Sprintf("malformed contact header %d", header))
+	}
+	return idShared, ipShared, header%2 == 1, nil
+}
+
+// Returns the number of leading bytes a and b have in common.
+func sharedPrefix(a []byte, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// Encodes a contact list for the wire. Each contact is delta-encoded against the previous one,
+// or against target for the first: only the id and ip bytes following their shared prefix are
+// sent. Lists ordered by distance to target, like FOUND_NODES lists, share longer id prefixes the
+// larger the network, and nodes in the same subnet share ip prefixes.
+// Contacts in dict are sent as an index, dict may be nil.
+// Every contact starts with a header byte, either CONTACT_REFERENCE or the literal header, see
+// literalHeader.
+func EncodeContacts(contacts []Contact, target KademliaID, dict *ContactDictionary) []byte {
+	res := binary.AppendUvarint(make([]byte, 0, len(contacts)*RAW_CONTACT_BYTES/2), uint64(len(contacts)))
+	prevID := target.Bytes()
+	prevIP := [4]byte{}
+	for _, con := range contacts {
+		if dict != nil {
+			if i, ok := dict.index[con]; ok {
+				res = append(res, CONTACT_REFERENCE)
+				res = binary.AppendUvarint(res, uint64(i))
+				continue
+			}
+		}
+		id := con.ID().Bytes()
+		idShared := sharedPrefix(prevID[:], id[:])
+		ip := con.IP()
+		ipShared := sharedPrefix(prevIP[:], ip[:])
+		res = append(res, literalHeader(idShared, ipShared, con.port != 0))
+		res = append(res, id[idShared:]...)
+		res = append(res, ip[ipShared:]...)
+		if con.port != 0 {
+			res = binary.AppendUvarint(res, uint64(con.port))
+		}
+		prevID = id
+		prevIP = ip
+	}
+	return res
+}
+
+// Decodes a contact list produced by EncodeContacts with the same target and dictionary.
+// Returns an error if the data is malformed or refers to contacts missing from dict.
+func DecodeContacts(data []byte, target KademliaID, dict *ContactDictionary) ([]Contact, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > MAX_CONTACT_LIST {
+		return nil, errors.New("malformed contact list length")
+	}
+	data = data[n:]
+	res := make([]Contact, 0, count)
+	prevID := target.Bytes()
+	prevIP := [4]byte{}
+	for i := range int(count) {
+		if len(data) == 0 {
+			return nil, errors.New(fmt.Sprintf("contact list truncated at contact %d", i))
+		}
+		header := data[0]
+		data = data[1:]
+		if header == CONTACT_REFERENCE {
+			index, n := binary.Uvarint(data)
+			if n <= 0 || index >= uint64(dict.Len()) {
+				return nil, errors.New(fmt.Sprintf("contact %d refers to unknown dictionary entry", i))
+			}
+			data = data[n:]
+			res = append(res, dict.contacts[index])
+			continue
+		}
+		idShared, ipShared, hasPort, err := parseLiteralHeader(header)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("contact %d: %v", i, err))
+		}
+		var id [ID_BYTES]byte
+		var ip [4]byte
+		if len(data) < ID_BYTES-idShared+4-ipShared {
+			return nil, errors.New(fmt.Sprintf("contact list truncated at contact %d", i))
+		}
+		copy(id[:], prevID[:idShared])
+		data = data[copy(id[idShared:], data):]
+		copy(ip[:], prevIP[:ipShared])
+		data = data[copy(ip[ipShared:], data):]
+		port := uint64(0)
+		if hasPort {
+			var n int
+			port, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errors.New(fmt.Sprintf("contact %d: malformed port", i))
+			}
+			data = data[n:]
+		}
+		con := NewContact(ip, IDFromBytes(id[:]))
+		con.port = int(port)
+		res = append(res, con)
+		prevID = id
+		prevIP = ip
+	}
+	if len(data) != 0 {
+		return nil, errors.New(fmt.Sprintf("%d trailing bytes after contact list", len(data)))
+	}
+	return res, nil
+}
+
+// Bytes needed for contact lists in the uncompressed and compact encodings.
+type ContactEncodingStats struct {
+	Nodes      int // live nodes when measured
+	Lists      int // contact lists encoded
+	Contacts   int // contacts in the lists
+	Raw        int // bytes as full ids, ips and ports
+	Delta      int // bytes with delta encoding
+	Dictionary int // bytes with delta encoding and the sender's routing table as shared dictionary
+}
+
+// Returns the share of the uncompressed bytes saved by the encoding of the given size.
+func (stats ContactEncodingStats) Saved(encoded int) float64 {
+	if stats.Raw == 0 {
+		return 0
+	}
+	return 1 - float64(encoded)/float64(stats.Raw)
+}
+
+func (stats ContactEncodingStats) Display() string {
+	res := fmt.Sprintf("nodes: %d lists: %d contacts: %d\n", stats.Nodes, stats.Lists, stats.Contacts)
+	res += fmt.Sprintf("raw: %d bytes\n", stats.Raw)
+	res += fmt.Sprintf("delta: %d bytes (%.1f%% saved)\n", stats.Delta, 100*stats.Saved(stats.Delta))
+	res += fmt.Sprintf("dictionary: %d bytes (%.1f%% saved)\n", stats.Dictionary, 100*stats.Saved(stats.Dictionary))
+	return res
+}
+
+// Measures the encodings on the FOUND_NODES lists the live nodes would answer for samples
+// random targets each. The dictionary case assumes the receiver already holds the sender's
+// routing table, so it bounds what a shared dictionary can save.
+func (simnet *Simnet) MeasureContactEncoding(samples int) ContactEncodingStats {
+	nodes := simnet.AllNodePointers()
+	stats := ContactEncodingStats{Nodes: len(nodes)}
+	for _, n := range nodes {
+		dict := NewContactDictionary(n.AllContacts())
+		for range samples {
+			target := RandomID()
+			found, _ := n.FindXClosest(n.config.Replication, target)
+			stats.Lists++
+			stats.Contacts += len(found)
+			stats.Raw += binary.PutUvarint(make([]byte, binary.MaxVarintLen64), uint64(len(found))) + len(found)*RAW_CONTACT_BYTES
+			stats.Delta += len(EncodeContacts(found, target, nil))
+			stats.Dictionary += len(EncodeContacts(found, target, dict))
+		}
+	}
+	return stats
+}
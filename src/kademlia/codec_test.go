@@ -0,0 +1,98 @@
+package kademlia
+
+import (
+	"log"
+	"slices"
+	"testing"
+)
+
+func TestContactEncodingRoundTrip(t *testing.T) {
+	testName := "TestContactEncodingRoundTrip"
+	target := RandomID()
+	contacts := make([]Contact, 0, REPLICATION)
+	for range REPLICATION {
+		contacts = append(contacts, NewRandomContact())
+	}
+	contacts[0].port = PORT
+	SortContactsByDistance(&contacts, target)
+	dict := NewContactDictionary(contacts[:REPLICATION/2])
+
+	for _, d := range []*ContactDictionary{nil, dict} {
+		data := EncodeContacts(contacts, target, d)
+		res, err := DecodeContacts(data, target, d)
+		if err != nil || !slices.Equal(res, contacts) {
+			log.Printf("[%s] - round trip with %d dictionary entries failed: %v", testName, d.Len(), err)
+			t.Fail()
+		}
+		if len(data) >= len(contacts)*RAW_CONTACT_BYTES {
+			log.Printf("[%s] - encoding of %d bytes is not smaller than the raw contacts", testName, len(data))
+			t.Fail()
+		}
+		if _, err := DecodeContacts(data[:len(data)-1], target, d); err == nil {
+			log.Printf("[%s] - decoded a truncated contact list", testName)
+			t.Fail()
+		}
+	}
+	if _, err := DecodeContacts(EncodeContacts(contacts, target, dict), target, nil); err == nil {
+		log.Printf("[%s] - decoded dictionary references without the dictionary", testName)
+		t.Fail()
+	}
+}
+
+func TestMeasureContactEncoding(t *testing.T) {
+	testName := "TestMeasureContactEncoding"
+	for _, size := range []int{10, 40} {
+		done := make(chan struct{}, 1)
+		s := NewServer(false, 0.0)
+		s.Silence()
+		go s.StartServer()
+		s.SpawnCluster(size, done)
+		<-done
+		stats := s.MeasureContactEncoding(4)
+		s.Shutdown()
+		if stats.Contacts == 0 || stats.Delta >= stats.Raw || stats.Dictionary >= stats.Delta {
+			log.Printf("[%s] - unexpected measurement at %d nodes:\n%s", testName, size, stats.Display())
+			t.Fail()
+		}
+	}
+}
+
+func FuzzContactCodec(f *testing.F) {
+	testName := "FuzzContactCodec"
+	f.Add(make([]byte, ID_BYTES+3*(ID_BYTES+4)), uint8(1))
+	f.Add([]byte("a target followed by contacts sharing ids and ips"), uint8(0))
+	f.Fuzz(func(t *testing.T, data []byte, shared uint8) {
+		target := IDFromBytes(data)
+		contacts := fuzzContacts(data[min(len(data), ID_BYTES):])
+		known := contacts[:min(len(contacts), int(shared))]
+		// both ends build the dictionary from the same contacts, not necessarily in the same order
+		reversed := slices.Clone(known)
+		slices.Reverse(reversed)
+		for _, d := range [][2]*ContactDictionary{{nil, nil}, {NewContactDictionary(known), NewContactDictionary(reversed)}} {
+			res, err := DecodeContacts(EncodeContacts(contacts, target, d[0]), target, d[1])
+			if err != nil || !slices.Equal(res, contacts) {
+				log.Printf("[%s] - round trip of %d contacts with %d dictionary entries failed: %v", testName, len(contacts), d[0].Len(), err)
+				t.FailNow()
+			}
+		}
+	})
+}
+
+func FuzzDecodeContacts(f *testing.F) {
+	testName := "FuzzDecodeContacts"
+	target := RandomID()
+	f.Add(EncodeContacts([]Contact{NewRandomContact(), NewRandomContact()}, target, nil))
+	f.Add([]byte{2, CONTACT_REFERENCE, 0, 209})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// malformed input is rejected, never a panic, and whatever decodes encodes back to itself
+		res, err := DecodeContacts(data, target, nil)
+		if err != nil {
+			return
+		}
+		again, err := DecodeContacts(EncodeContacts(res, target, nil), target, nil)
+		if err != nil || !slices.Equal(again, res) {
+			log.Printf("[%s] - decoded %d contacts that do not survive a round trip: %v", testName, len(res), err)
+			t.FailNow()
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Command numbers from USER_CMD and upwards are reserved for application-defined RPCs,
+// everything below it belongs to the protocol.
+const USER_CMD cmd = 1 << 10
+
+// Exported name of the command type, used when registering application-defined commands.
+type Command = cmd
+
+// Handles an application-defined RPC, the returned payload is sent back to the requester.
+type CommandHandler func(node *Node, rpc RPC) []byte
+
+type commandRegistry struct {
+	names    map[cmd]string
+	handlers map[cmd]CommandHandler
+	sync.RWMutex
+}
+
+var registry = commandRegistry{
+	names:    make(map[cmd]string),
+	handlers: make(map[cmd]CommandHandler),
+}
+
+// Registers an application-defined command with a display name and a handler.
+// Returns an error if the command is outside the reserved range or already registered.
+func RegisterCommand(command Command, name string, handler CommandHandler) error {
+	if command < USER_CMD {
+		return errors.New(fmt.Sprintf("command %d is reserved for the protocol, use %d or above", command, USER_CMD))
+	}
+	if handler == nil {
+		return errors.New("command handler can not be nil")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	_, exists := registry.names[command]
+	if exists {
+		return errors.New(fmt.Sprintf("command %d already registered as %s", command, registry.names[command]))
+	}
+	registry.names[command] = name
+	registry.handlers[command] = handler
+	return nil
+}
+
+// Removes an application-defined command from the registry.
+func UnregisterCommand(command Command) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.names, command)
+	delete(registry.handlers, command)
+}
+
+func (registry *commandRegistry) name(command cmd) (string, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	name, ok := registry.names[command]
+	return name, ok
+}
+
+func (registry *commandRegistry) handler(command cmd) (CommandHandler, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	handler, ok := registry.handlers[command]
+	return handler, ok
+}
+
+// Handles an application-defined RPC on a single node, see Node.RegisterHandler.
+type RPCHandler func(rpc RPC) RPC
+
+// Returned by Send when the receiver has no handler for the command.
+type UnknownCommandError struct {
+	Command  Command
+	Receiver [4]byte
+}
+
+func (err *UnknownCommandError) Error() string {
+	return fmt.Sprintf("node %v has no handler for command %s", err.Receiver, err.Command)
+}
+
+// Application-defined handlers registered on a single node.
+type handlerTable struct {
+	content map[cmd]RPCHandler
+	sync.RWMutex
+}
+
+func newHandlerTable() *handlerTable {
+	return &handlerTable{
+		content: make(map[cmd]RPCHandler),
+	}
+}
+
+func (table *handlerTable) handler(command cmd) (RPCHandler, bool) {
+	table.RLock()
+	defer table.RUnlock()
+	handler, ok := table.content[command]
+	return handler, ok
+}
+
+// Registers a handler for an application-defined command on this node only.
+// The command and payload of the returned RPC are sent back to the requester, build it with
+// RPC.Custom. A command left unset answers with the requested command.
+// The node's handler takes precedence over one registered with RegisterCommand.
+// Returns an error if the command is reserved for the protocol or already has a handler on the node.
+func (node *Node) RegisterHandler(command Command, handler RPCHandler) error {
+	if command < USER_CMD {
+		return errors.New(fmt.Sprintf("command %d is reserved for the protocol, use %d or above", command, USER_CMD))
+	}
+	if handler == nil {
+		return errors.New("command handler can not be nil")
+	}
+	node.handlers.Lock()
+	defer node.handlers.Unlock()
+	_, exists := node.handlers.content[command]
+	if exists {
+		return errors.New(fmt.Sprintf("command %d already has a handler", command))
+	}
+	node.handlers.content[command] = handler
+	return nil
+}
+
+// Removes the node's handler for an application-defined command.
+func (node *Node) UnregisterHandler(command Command) {
+	node.handlers.Lock()
+	defer node.handlers.Unlock()
+	delete(node.handlers.content, command)
+}
@@ -0,0 +1,151 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestRegisterCommandReserved(t *testing.T) {
+	testName := "TestRegisterCommandReserved"
+	err := RegisterCommand(PING, "MY_PING", func(node *Node, rpc RPC) []byte { return nil })
+	if err == nil {
+		log.Printf("[%s] - registered a command inside the protocol range", testName)
+		t.Fail()
+	}
+}
+
+func TestRegisterCommandName(t *testing.T) {
+	testName := "TestRegisterCommandName"
+	command := USER_CMD + 1
+	err := RegisterCommand(command, "ORACLE_QUERY", func(node *Node, rpc RPC) []byte { return nil })
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	defer UnregisterCommand(command)
+	if command.String() != "ORACLE_QUERY" {
+		log.Printf("[%s] - expected name ORACLE_QUERY, received %s", testName, command.String())
+		t.Fail()
+	}
+	err = RegisterCommand(command, "ORACLE_QUERY", func(node *Node, rpc RPC) []byte { return nil })
+	if err == nil {
+		log.Printf("[%s] - registered the same command twice", testName)
+		t.Fail()
+	}
+}
+
+func TestHandleRegisteredCommand(t *testing.T) {
+	testName := "TestHandleRegisteredCommand"
+	command := USER_CMD + 2
+	RegisterCommand(command, "ECHO", func(node *Node, rpc RPC) []byte {
+		return append([]byte("echo "), rpc.Payload()...)
+	})
+	defer UnregisterCommand(command)
+
+	sender := make(chan RPC, 8)
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC, 8), sender, [4]byte{0, 0, 0, 0}, me, false)
+	rpc := GenerateRPC(me.IP(), NewRandomContact())
+	rpc.Custom(command, []byte("hello"))
+	node.Handler(&rpc)
+
+	select {
+	case resp := <-sender:
+		if !resp.response || resp.Cmd() != command || string(resp.Payload()) != "echo hello" {
+			log.Printf("[%s] - unexpected response:\n%s", testName, resp.Display())
+			t.Fail()
+		}
+	case <-time.After(time.Second):
+		log.Printf("[%s] - no response sent", testName)
+		t.Fail()
+	}
+}
+
+func TestRegisterHandlerReserved(t *testing.T) {
+	testName := "TestRegisterHandlerReserved"
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC, 8), make(chan RPC, 8), [4]byte{0, 0, 0, 0}, me, false)
+	err := node.RegisterHandler(FIND_NODE, func(rpc RPC) RPC { return rpc })
+	if err == nil {
+		log.Printf("[%s] - registered a handler for a built-in command", testName)
+		t.Fail()
+	}
+}
+
+func TestNodeHandlerTakesPrecedence(t *testing.T) {
+	testName := "TestNodeHandlerTakesPrecedence"
+	command := USER_CMD + 3
+	RegisterCommand(command, "LOOKUP", func(node *Node, rpc RPC) []byte { return []byte("package") })
+	defer UnregisterCommand(command)
+
+	sender := make(chan RPC, 8)
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC, 8), sender, [4]byte{0, 0, 0, 0}, me, false)
+	err := node.RegisterHandler(command, func(rpc RPC) RPC {
+		var res RPC
+		res.Custom(command, []byte("node"))
+		return res
+	})
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	rpc := GenerateRPC(me.IP(), NewRandomContact())
+	rpc.Custom(command, nil)
+	node.Handler(&rpc)
+
+	select {
+	case resp := <-sender:
+		if !resp.response || resp.id != rpc.id || string(resp.Payload()) != "node" {
+			log.Printf("[%s] - unexpected response:\n%s", testName, resp.Display())
+			t.Fail()
+		}
+	case <-time.After(time.Second):
+		log.Printf("[%s] - no response sent", testName)
+		t.Fail()
+	}
+}
+
+func TestUnknownCommandError(t *testing.T) {
+	testName := "TestUnknownCommandError"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(2, done)
+	<-done
+	defer s.Shutdown()
+
+	command := USER_CMD + 4
+	err := nodes[1].RegisterHandler(command, func(rpc RPC) RPC {
+		var res RPC
+		res.Custom(command, append([]byte("echo "), rpc.Payload()...))
+		return res
+	})
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	res, err := nodes[0].SendCommand(nodes[1].IP(), command, []byte("hello"))
+	if err != nil || string(res) != "echo hello" {
+		log.Printf("[%s] - expected echo hello, received %q, %v", testName, res, err)
+		t.Fail()
+	}
+
+	start := time.Now()
+	_, err = nodes[1].SendCommand(nodes[0].IP(), command, []byte("hello"))
+	unknown, ok := err.(*UnknownCommandError)
+	if !ok || unknown.Command != command {
+		log.Printf("[%s] - expected unknown command error, received %v", testName, err)
+		t.Fail()
+	}
+	if time.Since(start) >= TIMEOUT {
+		log.Printf("[%s] - unknown command was not answered before the timeout", testName)
+		t.Fail()
+	}
+	if _, err := nodes[1].FindByIP(nodes[0].IP()); err != nil {
+		log.Printf("[%s] - node answering an unknown command was dropped from the routing table", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,89 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The Kademlia parameters of a node, so that networks with different parameters can be compared
+// within one binary. The package constants are the defaults.
+type Config struct {
+	Keyspace    int           // the number of buckets, contacts sharing a longer prefix with the node share the last one
+	BucketSize  int           // K, number of contacts per bucket
+	Replication int           // K, contacts returned by a lookup and validators per account
+	Concurrency int           // alpha, find node queries in flight per lookup
+	Timeout     time.Duration // how long a request waits for its response, the durations derived from TIMEOUT scale with it
+	// Contacts a lookup asks for in each find node query, at most Replication. Zero leaves the
+	// response size to the queried node.
+	ResponseSize int
+	// Derive each request's timeout from the round trip times measured to its receiver, TCP style,
+	// with Timeout as the ceiling and for peers without a measurement.
+	AdaptiveTimeout bool
+	// How often a node reconciles its wallets with a random co-validator, see Node.AntiEntropy.
+	// Zero disables anti-entropy, leaving replicas to the periodic wallet sync.
+	AntiEntropy time.Duration
+	// Validators that must confirm a wallet read and acknowledge a wallet write, see Consistency.
+	ReadConsistency  Consistency
+	WriteConsistency Consistency
+	// Seal the RPCs between nodes in encrypted sessions, see OPEN_SESSION. Off by default, so
+	// benchmarks measure the protocol without the cost of the encryption.
+	Encryption bool
+}
+
+// Returns the configuration matching the package constants.
+func DefaultConfig() Config {
+	return Config{
+		Keyspace:         KEYSPACE,
+		BucketSize:       KBUCKETVOLUME,
+		Replication:      REPLICATION,
+		Concurrency:      CONCURRENCY,
+		Timeout:          TIMEOUT,
+		ReadConsistency:  ONE,
+		WriteConsistency: QUORUM,
+	}
+}
+
+// Returns an error if a parameter is out of range.
+func (config Config) Validate() error {
+	if config.Keyspace < 1 || config.Keyspace > ID_BITS {
+		return errors.New(fmt.Sprintf("keyspace must be between 1 and %d, got %d", ID_BITS, config.Keyspace))
+	}
+	if config.BucketSize < 1 {
+		return errors.New(fmt.Sprintf("bucket size must be positive, got %d", config.BucketSize))
+	}
+	if config.Replication < 1 {
+		return errors.New(fmt.Sprintf("replication must be positive, got %d", config.Replication))
+	}
+	if config.Concurrency < 1 {
+		return errors.New(fmt.Sprintf("concurrency must be positive, got %d", config.Concurrency))
+	}
+	if config.Timeout <= 0 {
+		return errors.New(fmt.Sprintf("timeout must be positive, got %v", config.Timeout))
+	}
+	if config.ResponseSize < 0 || config.ResponseSize > config.Replication {
+		return errors.New(fmt.Sprintf("response size must be between 0 and the replication %d, got %d", config.Replication, config.ResponseSize))
+	}
+	if !config.ReadConsistency.valid() || !config.WriteConsistency.valid() {
+		return errors.New(fmt.Sprintf("read and write consistency must be one, quorum or all, got %s and %s", config.ReadConsistency, config.WriteConsistency))
+	}
+	if config.AntiEntropy < 0 {
+		return errors.New(fmt.Sprintf("anti-entropy interval must not be negative, got %v", config.AntiEntropy))
+	}
+	return nil
+}
+
+// Returns d, one of the package durations derived from TIMEOUT, scaled to the configured Timeout.
+func (config Config) scaled(d time.Duration) time.Duration {
+	return scaleTimeout(d, config.Timeout)
+}
+
+// Returns d scaled by timeout / TIMEOUT.
+func scaleTimeout(d time.Duration, timeout time.Duration) time.Duration {
+	return time.Duration(float64(d) * float64(timeout) / float64(TIMEOUT))
+}
+
+// Returns the Kademlia parameters of the node.
+func (node *Node) Config() Config {
+	return node.config
+}
@@ -0,0 +1,94 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	testName := "TestConfig"
+	invalid := []Config{
+		{Keyspace: 0, BucketSize: 20, Replication: 20, Concurrency: 3, Timeout: TIMEOUT},
+		{Keyspace: ID_BITS + 1, BucketSize: 20, Replication: 20, Concurrency: 3, Timeout: TIMEOUT},
+		{Keyspace: ID_BITS, BucketSize: 0, Replication: 20, Concurrency: 3, Timeout: TIMEOUT},
+		{Keyspace: ID_BITS, BucketSize: 20, Replication: 0, Concurrency: 3, Timeout: TIMEOUT},
+		{Keyspace: ID_BITS, BucketSize: 20, Replication: 20, Concurrency: 0, Timeout: TIMEOUT},
+		{Keyspace: ID_BITS, BucketSize: 20, Replication: 20, Concurrency: 3, Timeout: 0},
+		{Keyspace: ID_BITS, BucketSize: 20, Replication: 20, Concurrency: 3, Timeout: TIMEOUT, ResponseSize: 21},
+		{Keyspace: ID_BITS, BucketSize: 20, Replication: 20, Concurrency: 3, Timeout: TIMEOUT, AntiEntropy: -time.Second},
+		{Keyspace: ID_BITS, BucketSize: 20, Replication: 20, Concurrency: 3, Timeout: TIMEOUT, ReadConsistency: ONE, WriteConsistency: ALL + 1},
+	}
+	for _, config := range invalid {
+		if _, err := NewServerWithConfig(false, 0.0, config); err == nil {
+			log.Printf("[%s] - accepted invalid config %+v", testName, config)
+			t.Fail()
+		}
+	}
+	if err := DefaultConfig().Validate(); err != nil {
+		log.Printf("[%s] - default config invalid: %s", testName, err.Error())
+		t.Fail()
+	}
+
+	// networks with different K side by side in one binary
+	for _, k := range []int{5, 20} {
+		config := DefaultConfig()
+		config.BucketSize = k
+		config.Replication = k
+		config.Keyspace = 32
+		config.Timeout = 200 * time.Millisecond
+		s, err := NewServerWithConfig(false, 0.0, config)
+		if err != nil {
+			log.Printf("[%s] - %s", testName, err.Error())
+			t.FailNow()
+		}
+		s.Silence()
+		done := make(chan struct{}, 1)
+		go s.StartServer()
+		nodes := s.SpawnCluster(60, done)
+		<-done
+
+		if nodes[0].Config() != config {
+			log.Printf("[%s] - node config %+v, expected %+v", testName, nodes[0].Config(), config)
+			t.Fail()
+		}
+		if len(nodes[0].RoutingTable.table) != config.Keyspace {
+			log.Printf("[%s] - expected %d buckets, got %d", testName, config.Keyspace, len(nodes[0].RoutingTable.table))
+			t.Fail()
+		}
+		for _, bucket := range nodes[0].RoutingTable.table {
+			if len(bucket.DumpBucket()) > k {
+				log.Printf("[%s] - bucket holds %d contacts with K=%d", testName, len(bucket.DumpBucket()), k)
+				t.Fail()
+			}
+		}
+		found := nodes[1].FindNode(RandomID())
+		if len(found) != k {
+			log.Printf("[%s] - lookup returned %d contacts with K=%d", testName, len(found), k)
+			t.Fail()
+		}
+		s.Shutdown()
+	}
+}
+
+func TestConfigScaledTimeouts(t *testing.T) {
+	testName := "TestConfigScaledTimeouts"
+	if res := DefaultConfig().scaled(WATCH_LEASE); res != WATCH_LEASE {
+		log.Printf("[%s] - default config scaled %v to %v", testName, WATCH_LEASE, res)
+		t.Fail()
+	}
+	config := DefaultConfig()
+	config.Timeout = TIMEOUT / 5
+	for _, d := range []time.Duration{BATCH_TIMEOUT, PROPOSAL_TTL, WALLET_SYNC_INTERVAL, JOIN_CHALLENGE_TTL, SNAPSHOT_TTL, ENTER_BACKOFF, SATURATION_WINDOW, WATCH_LEASE, STALE_CONTACT, REPLAY_SETTLE, SCALE_PROBE} {
+		if res := config.scaled(d); res != d/5 {
+			log.Printf("[%s] - timeout %v scaled %v to %v, expected %v", testName, config.Timeout, d, res, d/5)
+			t.Fail()
+		}
+	}
+	for attempt := 1; attempt < 10; attempt++ {
+		if res := config.enterBackoff(attempt); res > config.scaled(ENTER_MAX_BACKOFF)*5/4 {
+			log.Printf("[%s] - attempt %d backed off %v past the scaled ceiling", testName, attempt, res)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package kademlia
+
+// How many of a wallet's validators must confirm a read, or acknowledge a write, for it to
+// succeed, as in Dynamo-style stores. Reads return as soon as enough validators confirmed, so
+// lower levels answer sooner while higher levels are less likely to return a stale state.
+// Writes are sent to every validator and succeed once enough of them acknowledged, a majority at
+// QUORUM, so lower levels succeed with more validators lost or refusing the write. A write only
+// returns once every validator has answered or timed out, whatever the level.
+// Every wallet read and write of a node uses the levels of its configuration, see
+// Config.ReadConsistency and Config.WriteConsistency.
+type Consistency int32
+
+const (
+	ONE    Consistency = iota + 1 // any single validator, the default for reads
+	QUORUM                        // a majority of the validators, the default for writes
+	ALL                           // every validator
+)
+
+func (level Consistency) String() string {
+	switch level {
+	case ONE:
+		return "one"
+	case QUORUM:
+		return "quorum"
+	case ALL:
+		return "all"
+	}
+	return "unknown consistency"
+}
+
+// Returns true if level is one of the known consistency levels.
+func (level Consistency) valid() bool {
+	return level >= ONE && level <= ALL
+}
+
+// Returns how many of the validators must answer positively at the level, at least one so that
+// an operation finding no validators fails.
+func (level Consistency) Required(validators int) int {
+	switch level {
+	case ONE:
+		return 1
+	case ALL:
+		return max(validators, 1)
+	}
+	return validators/2 + 1
+}
@@ -0,0 +1,105 @@
+package kademlia
+
+import (
+	"errors"
+	"log"
+	"main/src/scalegraph"
+	"testing"
+)
+
+func TestConsistencyRequired(t *testing.T) {
+	testName := "TestConsistencyRequired"
+	cases := []struct {
+		level      Consistency
+		validators int
+		required   int
+	}{
+		{ONE, 20, 1},
+		{QUORUM, 20, 11},
+		{QUORUM, 3, 2},
+		{ALL, 20, 20},
+		{ONE, 0, 1},
+		{QUORUM, 0, 1},
+		{ALL, 0, 1},
+	}
+	for _, c := range cases {
+		if got := c.level.Required(c.validators); got != c.required {
+			log.Printf("[%s] - %s of %d validators requires %d, expected %d", testName, c.level, c.validators, got, c.required)
+			t.Fail()
+		}
+	}
+}
+
+func TestConsistencyLevels(t *testing.T) {
+	testName := "TestConsistencyLevels"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	holders := make([]*Node, 0)
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err == nil {
+			holders = append(holders, n)
+		}
+	}
+	// one validator loses the wallet, lookups repair it before counting the holders
+	holders[0].scalegraph.RemoveAccount(id)
+	for _, level := range []Consistency{ONE, QUORUM, ALL} {
+		nodes[0].config.ReadConsistency = level
+		if found, _, err := nodes[0].FindAccount(id); err != nil || len(found) < level.Required(len(holders)) {
+			log.Printf("[%s] - read at %s confirmed by %d validators: %v", testName, level, len(found), err)
+			t.Fail()
+		}
+	}
+	if _, err := holders[0].Ledger().Wallet(id); err != nil {
+		log.Printf("[%s] - lookup did not repair the lost replica: %v", testName, err)
+		t.Fail()
+	}
+
+	// show wallet repairs in the background, so a read at ALL still fails on a lost replica
+	holders[0].scalegraph.RemoveAccount(id)
+	nodes[0].config.ReadConsistency = ALL
+	if _, err := nodes[0].ShowWallet(id); !errors.Is(err, ErrQuorumNotReached) {
+		log.Printf("[%s] - show wallet at all succeeded with a lost replica: %v", testName, err)
+		t.Fail()
+	}
+	nodes[0].config.ReadConsistency = QUORUM
+	if wallet, err := nodes[0].ShowWallet(id); err != nil || wallet.Balance != 10 {
+		log.Printf("[%s] - show wallet at quorum returned %+v %v", testName, wallet, err)
+		t.Fail()
+	}
+
+	// a write at ALL fails while one validator refuses it, one at QUORUM goes through
+	other := KademliaID(scalegraph.RandomID())
+	nodes[0].config.WriteConsistency = ALL
+	for _, con := range nodes[0].FindNode(other) {
+		// the writer itself may be one of the validators, another one refuses
+		if con.ID() == nodes[0].ID() {
+			continue
+		}
+		for _, n := range s.AllNodePointers() {
+			if n.ID() == con.ID() {
+				n.SetRole(OBSERVER)
+			}
+		}
+		break
+	}
+	if err := nodes[0].SubmitWallet(other, 10); !errors.Is(err, ErrQuorumNotReached) {
+		log.Printf("[%s] - write at all succeeded with a refusing validator: %v", testName, err)
+		t.Fail()
+	}
+	nodes[0].config.WriteConsistency = QUORUM
+	if err := nodes[0].SubmitWallet(KademliaID(scalegraph.RandomID()), 10); err != nil {
+		log.Printf("[%s] - write at quorum failed: %v", testName, err)
+		t.Fail()
+	}
+}
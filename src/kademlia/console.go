@@ -0,0 +1,214 @@
+package kademlia
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const CONSOLE_PROMPT = "simnet> "
+
+// A console command, run with the arguments following its name.
+type consoleCommand struct {
+	usage string
+	help  string
+	run   func(simnet *Simnet, out io.Writer, args []string) error
+}
+
+var consoleCommands = map[string]consoleCommand{
+	"nodes":  {"nodes", "list the live nodes", (*Simnet).consoleNodes},
+	"table":  {"table <node>", "show the routing table of a node", (*Simnet).consoleTable},
+	"lookup": {"lookup <node> <target>", "look up target from node, target may be any id", (*Simnet).consoleLookup},
+	"kill":   {"kill <node>", "shut down a node", (*Simnet).consoleKill},
+	"spawn":  {"spawn [count]", "spawn nodes and wait for them to join", (*Simnet).consoleSpawn},
+	"drop":   {"drop [rate]", "show or change the drop rate", (*Simnet).consoleDrop},
+	"stats":  {"stats", "show routing statistics", (*Simnet).consoleStats},
+	"dump":   {"dump <node>", "write a dump of a node to a file", (*Simnet).consoleDump},
+}
+
+// Runs an interactive console on the simnet, reading one command per line from in and writing
+// the results to out, until in is exhausted or the quit command is read. Nodes are named by a
+// prefix of their hex id, see the nodes command.
+// Returns an error only if reading in fails, failed commands are reported on out.
+func (simnet *Simnet) Console(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, CONSOLE_PROMPT)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			if fields[0] == "quit" || fields[0] == "exit" {
+				return nil
+			}
+			simnet.consoleRun(out, fields[0], fields[1:])
+		}
+		fmt.Fprint(out, CONSOLE_PROMPT)
+	}
+	return scanner.Err()
+}
+
+func (simnet *Simnet) consoleRun(out io.Writer, name string, args []string) {
+	if name == "help" {
+		names := make([]string, 0, len(consoleCommands))
+		for n := range consoleCommands {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		for _, n := range names {
+			fmt.Fprintf(out, "%-24s %s\n", consoleCommands[n].usage, consoleCommands[n].help)
+		}
+		fmt.Fprintf(out, "%-24s %s\n", "quit", "leave the console")
+		return
+	}
+	command, ok := consoleCommands[name]
+	if !ok {
+		fmt.Fprintf(out, "unknown command %q, try help\n", name)
+		return
+	}
+	if err := command.run(simnet, out, args); err != nil {
+		fmt.Fprintf(out, "error: %s\nusage: %s\n", err.Error(), command.usage)
+	}
+}
+
+// Returns the live node whose hex id starts with prefix.
+// Returns an error if no node or more than one node matches.
+func (simnet *Simnet) NodeByPrefix(prefix string) (*Node, error) {
+	var match *Node
+	for _, n := range simnet.AllNodePointers() {
+		if strings.HasPrefix(n.ID().String(), strings.ToLower(prefix)) {
+			if match != nil {
+				return nil, errors.New(fmt.Sprintf("prefix %s matches more than one node", prefix))
+			}
+			match = n
+		}
+	}
+	if match == nil {
+		return nil, failure(ErrNodeNotFound, "no live node matches %s", prefix)
+	}
+	return match, nil
+}
+
+func (simnet *Simnet) consoleNodes(out io.Writer, args []string) error {
+	nodes := simnet.AllNodePointers()
+	slices.SortFunc(nodes, func(a, b *Node) int { return a.ID().Cmp(b.ID()) })
+	for _, n := range nodes {
+		master := ""
+		if n.ID() == simnet.masterNodeContact.ID() {
+			master = " master"
+		}
+		fmt.Fprintf(out, "%v %-17s contacts: %3d%s\n", n.ID(), fmt.Sprint(n.IP()), n.Len(), master)
+	}
+	fmt.Fprintf(out, "%d live nodes\n", len(nodes))
+	return nil
+}
+
+func (simnet *Simnet) consoleTable(out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a node")
+	}
+	node, err := simnet.NodeByPrefix(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s\n", node.Display())
+	return nil
+}
+
+func (simnet *Simnet) consoleLookup(out io.Writer, args []string) error {
+	if len(args) != 2 {
+		return errors.New("expected a node and a target")
+	}
+	node, err := simnet.NodeByPrefix(args[0])
+	if err != nil {
+		return err
+	}
+	var target KademliaID
+	if live, err := simnet.NodeByPrefix(args[1]); err == nil {
+		target = live.ID()
+	} else if err := target.UnmarshalText([]byte(args[1])); err != nil {
+		return errors.New(fmt.Sprintf("target %s is neither a live node nor an id", args[1]))
+	}
+	found, stats := node.FindNodeWithStats(target)
+	for i, con := range found {
+		mark := ""
+		if con.ID() == target {
+			mark = " target"
+		}
+		fmt.Fprintf(out, "%2d %v %-17s%s\n", i, con.ID(), fmt.Sprint(con.IP()), mark)
+	}
+	fmt.Fprintf(out, "hops: %d rpcs: %d failed: %d in %v\n", stats.Hops, stats.RPCs, stats.Failed, stats.Duration)
+	return nil
+}
+
+func (simnet *Simnet) consoleKill(out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a node")
+	}
+	node, err := simnet.NodeByPrefix(args[0])
+	if err != nil {
+		return err
+	}
+	if node.ID() == simnet.masterNodeContact.ID() {
+		return errors.New("the master node can not be killed")
+	}
+	err = simnet.ShutdownNode(node)
+	fmt.Fprintf(out, "killed %v\n", node.ID())
+	return err
+}
+
+func (simnet *Simnet) consoleSpawn(out io.Writer, args []string) error {
+	count := 1
+	if len(args) > 0 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 1 {
+			return errors.New(fmt.Sprintf("invalid count %s", args[0]))
+		}
+		count = parsed
+	}
+	joined := make(chan KademliaID, count)
+	for range count {
+		simnet.SpawnNode(joined)
+	}
+	for range count {
+		fmt.Fprintf(out, "spawned %v\n", <-joined)
+	}
+	return nil
+}
+
+func (simnet *Simnet) consoleDrop(out io.Writer, args []string) error {
+	if len(args) > 0 {
+		rate, err := strconv.ParseFloat(args[0], 32)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid rate %s", args[0]))
+		}
+		if err := simnet.SetDropRate(float32(rate)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "drop rate: %v\n", simnet.DropRate())
+	return nil
+}
+
+func (simnet *Simnet) consoleStats(out io.Writer, args []string) error {
+	fmt.Fprint(out, simnet.Stats().Display())
+	return nil
+}
+
+func (simnet *Simnet) consoleDump(out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a node")
+	}
+	node, err := simnet.NodeByPrefix(args[0])
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("node-%v.json", node.ID())
+	if err := node.WriteDump(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,51 @@
+package kademlia
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	testName := "TestConsole"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	a, b, victim := nodes[0].ID().String(), nodes[1].ID().String(), nodes[2]
+	script := fmt.Sprintf("nodes\nlookup %s %s\ndrop 0.5\ndrop 2\ndrop 0\nkill %s\nspawn 2\nbogus\nquit\nspawn 3\n", a[:12], b[:12], victim.ID().String()[:12])
+	out := &bytes.Buffer{}
+	if err := s.Console(strings.NewReader(script), out); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	output := out.String()
+	for _, expected := range []string{
+		"6 live nodes",
+		b + " ",
+		"drop rate: 0.5",
+		"error: drop rate must be between 0 and 1",
+		"drop rate: 0\n",
+		"killed " + victim.ID().String(),
+		"unknown command \"bogus\"",
+	} {
+		if !strings.Contains(output, expected) {
+			log.Printf("[%s] - output is missing %q:\n%s", testName, expected, output)
+			t.Fail()
+		}
+	}
+	if strings.Count(output, "spawned ") != 2 {
+		log.Printf("[%s] - expected two spawned nodes, commands after quit must not run:\n%s", testName, output)
+		t.Fail()
+	}
+	if live := len(s.AllNodePointers()); live != 7 {
+		log.Printf("[%s] - expected 7 live nodes, got %d", testName, live)
+		t.Fail()
+	}
+}
@@ -1,25 +1,29 @@
 package kademlia
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"net/netip"
 )
 
+// Address and id of a node. Nodes are addressed by IPv4 address in the simnet, a contact may
+// also carry a port.
 type Contact struct {
 	ip   [4]byte
-	id   [5]uint32
-	port int
+	id   KademliaID
+	port int // zero if the contact has no port of its own, see PORT
 }
 
 func (contact *Contact) IP() [4]byte {
 	return contact.ip
 }
 
-func (contact *Contact) ID() [5]uint32 {
+func (contact *Contact) ID() KademliaID {
 	return contact.id
 }
 
-func NewContact(ip [4]byte, id [5]uint32) Contact {
+func NewContact(ip [4]byte, id KademliaID) Contact {
 	contact := Contact{
 		ip: ip,
 		id: id,
@@ -27,20 +31,48 @@ func NewContact(ip [4]byte, id [5]uint32) Contact {
 	return contact
 }
 
+// Returns a contact at the given address, IPv4-mapped IPv6 addresses are taken as IPv4.
+// Returns an error for any other address, RPCs are routed by IPv4 address and could not reach it.
+func NewContactAt(addr netip.AddrPort, id KademliaID) (Contact, error) {
+	ip := addr.Addr().Unmap()
+	if !ip.Is4() {
+		return Contact{}, errors.New(fmt.Sprintf("contact address %v is not an IPv4 address", addr.Addr()))
+	}
+	contact := Contact{
+		ip:   ip.As4(),
+		id:   id,
+		port: int(addr.Port()),
+	}
+	return contact, nil
+}
+
+// Returns the contact's port, zero if it has none.
+func (contact *Contact) Port() int {
+	return contact.port
+}
+
+// Returns the contact's address and port.
+func (contact *Contact) AddrPort() netip.AddrPort {
+	return netip.AddrPortFrom(netip.AddrFrom4(contact.ip), uint16(contact.port))
+}
+
 func NewRandomContact() Contact {
 	var ip [4]byte
-	var id [5]uint32
+	var id KademliaID
 	for i := 0; i < 4; i++ {
 		seg, _ := RandU32(0, 256)
 		ip[i] = byte(seg)
 	}
-	for i := 0; i < 5; i++ {
+	for i := range id {
 		id[i] = rand.Uint32()
 	}
 	return NewContact(ip, id)
 }
 
 func (con *Contact) Display() string {
+	if con.port != 0 {
+		return fmt.Sprintf("address: %v ID: %10v", con.AddrPort(), con.ID())
+	}
 	conString := fmt.Sprintf("IP: %4v ID: %10v", con.IP(), con.ID())
 	return conString
 }
@@ -2,6 +2,7 @@ package kademlia
 
 import (
 	"log"
+	"net/netip"
 	"testing"
 )
 
@@ -17,3 +18,36 @@ func TestDisplayContact(t *testing.T) {
 		}
 	}
 }
+
+func TestContactAddress(t *testing.T) {
+	testName := "TestContactAddress"
+	id := RandomID()
+	v4, err := NewContactAt(netip.MustParseAddrPort("10.0.0.7:4000"), id)
+	if err != nil || v4.IP() != [4]byte{10, 0, 0, 7} || v4.Port() != 4000 {
+		log.Printf("[%s] - wrong IPv4 contact %s: %v", testName, v4.Display(), err)
+		t.Fail()
+	}
+	if mapped, err := NewContactAt(netip.MustParseAddrPort("[::ffff:10.0.0.7]:4000"), id); err != nil || mapped != v4 {
+		log.Printf("[%s] - IPv4-mapped address not taken as IPv4: %s", testName, mapped.Display())
+		t.Fail()
+	}
+	if plain := NewContact([4]byte{10, 0, 0, 7}, id); plain.AddrPort().String() != "10.0.0.7:0" || plain == v4 {
+		log.Printf("[%s] - wrong address %v of a contact without port", testName, plain.AddrPort())
+		t.Fail()
+	}
+	// the simnet routes by IPv4 address, an IPv6 contact could never be reached
+	if _, err := NewContactAt(netip.MustParseAddrPort("[2001:db8::1]:8080"), id); err == nil {
+		log.Printf("[%s] - built a contact at an IPv6 address", testName)
+		t.Fail()
+	}
+	if _, err := NewContactAt(netip.AddrPort{}, id); err == nil {
+		log.Printf("[%s] - built a contact at an invalid address", testName)
+		t.Fail()
+	}
+	other := NewContact([4]byte{10, 0, 0, 7}, id)
+	other.port = 4001
+	if CompareContacts(v4, other, id) >= 0 || CompareContacts(other, v4, id) <= 0 {
+		log.Printf("[%s] - contacts sharing an id and ip not ordered by port", testName)
+		t.Fail()
+	}
+}
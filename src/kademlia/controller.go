@@ -3,29 +3,88 @@ package kademlia
 import (
 	"errors"
 	"fmt"
-	"log"
-	"time"
 )
 
 // Controller handles the logic for receiving RPC's
 
 func (node *Node) Handler(rpc *RPC) {
-	if node.debug {
-		log.Printf("[DEBUG]\nNode %v - handling rpc:\n%s", node.ID(), rpc.Display())
+	node.logger.Debug("handling rpc", "rpc", rpc.id, "cmd", rpc.cmd, "sender", rpc.sender.ID())
+	if !node.limiter.allow(rpc.sender.IP(), rpc.cmd, node.timebase.Now()) {
+		node.metrics.RPCRateLimited(rpc.cmd)
+		node.logger.Debug("dropping rate limited rpc", "rpc", rpc.id, "cmd", rpc.cmd, "sender", rpc.sender.ID())
+		return
 	}
-	go node.AddContact(rpc.sender)
-	switch rpc.cmd {
-	case PING:
-		node.handlePing(rpc)
-	case INSERT_ACCOUNT:
-		node.handleInsertAccount(rpc)
-	case STORE_ACCOUNT:
-		node.handleStoreAccount(rpc)
-	case FIND_NODE:
-		node.handleFindNode(rpc)
-	case DISPLAY_ACCOUNT:
-		node.handleDisplayAccount(rpc)
+	sender, role, caps := rpc.sender, rpc.role, rpc.caps
+	node.routines.Go("add contact", func() { node.learnContact(sender, role, caps) })
+	node.metrics.RPCHandled(rpc.cmd)
+	if node.behavior != nil && node.behavior.Handle(node, rpc) {
+		return
 	}
+	// not in builtinHandlers, which handleBatch depends on through Handler
+	if rpc.cmd == BATCH {
+		node.handleBatch(rpc)
+		return
+	}
+	handler, ok := builtinHandlers[rpc.cmd]
+	if ok {
+		handler(node, rpc)
+		return
+	}
+	node.handleRegistered(rpc)
+}
+
+// Handlers of the built-in request commands, application-defined commands are looked up in
+// the node's handlers and then in the package registry.
+var builtinHandlers = map[cmd]func(node *Node, rpc *RPC){
+	PING:                (*Node).handlePing,
+	INSERT_ACCOUNT:      (*Node).handleInsertAccount,
+	STORE_ACCOUNT:       (*Node).handleStoreAccount,
+	FIND_ACCOUNT:        (*Node).handleFindAccount,
+	FIND_NODE:           (*Node).handleFindNode,
+	DISPLAY_ACCOUNT:     (*Node).handleDisplayAccount,
+	SNAPSHOT_ACCOUNTS:   (*Node).handleSnapshotAccounts,
+	APPEND_TRANSACTION:  (*Node).handleAppendTransaction,
+	FIND_BALANCE:        (*Node).handleFindBalance,
+	STORE_MESSAGE:       (*Node).handleStoreMessage,
+	FETCH_MESSAGES:      (*Node).handleFetchMessages,
+	SUBMIT_WALLET:       (*Node).handleSubmitWallet,
+	SHOW_WALLET:         (*Node).handleShowWallet,
+	PROPOSE_TRANSACTION: (*Node).handleProposeTransaction,
+	COMMIT_TRANSACTION:  (*Node).handleCommitTransaction,
+	SYNC_WALLET:         (*Node).handleSyncWallet,
+	STORE_VALUE:         (*Node).handleStoreValue,
+	FIND_VALUE:          (*Node).handleFindValue,
+	WATCH_WALLET:        (*Node).handleWatchWallet,
+	NOTIFY_WALLET:       (*Node).handleNotifyWallet,
+	SUBSCRIBE:           (*Node).handleSubscribe,
+	PUBLISH:             (*Node).handlePublish,
+	DELIVER_PUBLICATION: (*Node).handleDeliver,
+	TRANSFER_VALUES:     (*Node).handleTransferValues,
+	RECURSIVE_FIND_NODE: (*Node).handleRecursiveFindNode,
+	AUDIT_WALLET:        (*Node).handleAuditWallet,
+	RECONCILE:           (*Node).handleReconcile,
+	REPAIR_WALLET:       (*Node).handleRepairWallet,
+}
+
+// Response logic for an application-defined command.
+// Responds with the result of the node's handler, or else of the registered command handler.
+// Commands without a handler are answered with an unknown command response.
+func (node *Node) handleRegistered(rpc *RPC) {
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	if handler, ok := node.handlers.handler(rpc.cmd); ok {
+		res := handler(*rpc)
+		command := res.cmd
+		if command == NO_CMD {
+			command = rpc.cmd
+		}
+		resp.Custom(command, res.payload)
+	} else if handler, ok := registry.handler(rpc.cmd); ok {
+		resp.Custom(rpc.cmd, handler(node, *rpc))
+	} else {
+		node.logger.Debug("received unknown command", "rpc", rpc.id, "cmd", int(rpc.cmd))
+		resp.UnknownCommand(rpc.cmd)
+	}
+	node.Send(resp)
 }
 
 // Response logic for an incoming ping RPC.
@@ -36,17 +95,21 @@ func (node *Node) handlePing(rpc *RPC) {
 	node.Send(resp)
 }
 
+// Answers with the contacts closest to the target, as many as the request asks for up to the
+// node's replication.
 func (node *Node) handleFindNode(rpc *RPC) {
-	res, err := node.FindXClosest(REPLICATION, rpc.findNodeTarget)
+	count := node.config.Replication
+	if rpc.findNodeCount > 0 {
+		count = min(rpc.findNodeCount, count)
+	}
+	res, err := node.FindXClosest(count, rpc.findNodeTarget)
 	if err != nil {
-		log.Printf("Node %v - Handle Find Node Error\n%s", node.ID(), err.Error())
+		node.logger.Error("handle find node failed", "rpc", rpc.id, "err", err)
 	}
 	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
 	resp.FoundNodes(rpc.findNodeTarget, res)
-	if node.debug {
-		log.Printf("[DEBUG]\nresponding to find node with RPC:\n%s", rpc.Display())
-	}
-	go node.Send(resp)
+	node.logger.Debug("responding to find node", "rpc", rpc.id, "target", rpc.findNodeTarget, "found", len(res))
+	node.routines.Go("respond", func() { node.Send(resp) })
 }
 
 func (node *Node) handleInsertAccount(rpc *RPC) {
@@ -55,18 +118,23 @@ func (node *Node) handleInsertAccount(rpc *RPC) {
 
 // Response logic for an incoming store RPC.
 func (node *Node) handleStoreAccount(rpc *RPC) {
-	err := node.scalegraph.AddAccount(rpc.accountID)
+	var err error
+	if node.Role() == OBSERVER {
+		err = errors.New("observers do not store accounts")
+	} else {
+		err = node.scalegraph.AddAccount(rpc.accountID)
+	}
 	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
 	resp.StoredAccount(rpc.accountID, err == nil)
-	go node.Send(resp)
+	node.routines.Go("respond", func() { node.Send(resp) })
 }
 
 // Optional check to verify the node does not know it's not part of the validator group.
-func (node *Node) storeAccountCheck(accID [5]uint32) error {
-	validators, _ := node.FindXClosest(REPLICATION, accID)
+func (node *Node) storeAccountCheck(accID KademliaID) error {
+	validators, _ := node.FindXClosest(node.config.Replication, accID)
 	validator := CloserNode(node.ID(), validators[len(validators)-1].ID(), accID)
 	if !validator {
-		log.Printf("node %v: received incorrect store account RPC", node.ID())
+		node.logger.Warn("received incorrect store account RPC", "account", accID)
 		return errors.New(fmt.Sprintf("node %v is not a validator for account: %v", node.ID(), accID))
 	}
 	return nil
@@ -76,13 +144,14 @@ func (node *Node) handleFindAccount(rpc *RPC) {
 	_, err := node.scalegraph.FindAccount(rpc.accountID)
 	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
 	resp.FoundAccount(rpc.accountID, err == nil) // if there is no error it means we found the account
+	resp.replication = node.heldReplication(rpc.accountID)
 	node.Send(resp)
 }
 
 func (node *Node) handleDisplayAccount(rpc *RPC) {
 	acc, err := node.scalegraph.FindAccount(rpc.accountID)
 	if err != nil {
-		log.Printf("[ERROR] - node %10v received display RPC for missing account %10v", node.ID(), rpc.accountID)
+		node.logger.Error("received display RPC for missing account", "rpc", rpc.id, "account", rpc.accountID)
 		return
 	}
 	displayString := acc.Display()
@@ -96,7 +165,7 @@ func (node *Node) handleLockAccount(rpc *RPC) {
 	leader := rpc.lockChan
 	acc, err := node.scalegraph.FindAccount(rpc.accountID)
 	if err != nil {
-		log.Printf("[ERROR] - received lock request for missing account: %10v\n", rpc.accountID)
+		node.logger.Error("received lock request for missing account", "rpc", rpc.id, "account", rpc.accountID)
 	}
 	lockTime := make(chan struct{}, 1)
 	lockTime <- struct{}{}
@@ -112,9 +181,9 @@ func (node *Node) handleLockAccount(rpc *RPC) {
 	}(lockTime)
 	select {
 	case <-lockTaken:
-		log.Printf("node %10v, lock taken for account %10v", node.ID(), rpc.accountID)
+		node.logger.Info("lock taken", "account", rpc.accountID)
 		return
-	case <-time.After(TIMEOUT):
+	case <-node.timebase.After(node.config.Timeout):
 		close(lockTime)
 	}
 
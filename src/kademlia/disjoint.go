@@ -0,0 +1,113 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const MAX_LOOKUP_PATHS = 8 // most disjoint paths a node lookup can be split into
+
+// Outcome of a disjoint path lookup, see FindNodeDisjoint.
+type DisjointLookup struct {
+	Paths     [][]Contact   // closest contacts found by each path, closest first
+	Stats     []LookupStats // statistics of each path
+	Converged bool          // every path found the same closest contact
+}
+
+// Returns the statistics of the paths taken together: the longest chain of queries and the
+// queries sent and failed over all paths.
+func (report DisjointLookup) total() LookupStats {
+	res := LookupStats{}
+	for _, stats := range report.Stats {
+		res.Hops = max(res.Hops, stats.Hops)
+		res.RPCs += stats.RPCs
+		res.Failed += stats.Failed
+	}
+	return res
+}
+
+// Sets the number of disjoint paths the node's lookups take, as proposed by S/Kademlia to resist
+// adversarial routing. The contacts a lookup starts from are split between the paths and no node
+// is queried by more than one path, so a malicious node can only mislead the paths it is on.
+// The result of a lookup is the closest contacts found by any path. One path, the default, is a
+// plain lookup. Returns an error if paths is not between 1 and MAX_LOOKUP_PATHS.
+func (node *Node) SetLookupPaths(paths int) error {
+	if paths < 1 || paths > MAX_LOOKUP_PATHS {
+		return errors.New(fmt.Sprintf("lookup paths must be between 1 and %d, got %d", MAX_LOOKUP_PATHS, paths))
+	}
+	node.lookupPaths.Store(int32(paths))
+	return nil
+}
+
+// Returns the number of disjoint paths the node's lookups take.
+func (node *Node) LookupPaths() int {
+	return max(1, int(node.lookupPaths.Load()))
+}
+
+// Runs a node lookup for target over the node's disjoint paths, see SetLookupPaths, and reports
+// what each path found and whether the paths converged on the same closest contact. Paths that
+// disagree point at nodes misrouting the lookup. A path that started without any contacts, because
+// the routing table holds fewer contacts than there are paths, finds nothing and does not converge.
+func (node *Node) FindNodeDisjoint(target KademliaID) ([]Contact, DisjointLookup) {
+	start := node.timebase.Now()
+	initNodes, _ := node.FindXClosest(node.config.Replication, target)
+	found, report := node.disjointLookup(initNodes, target, node.LookupPaths())
+	stats := report.total()
+	stats.Duration = node.timebase.Since(start)
+	node.completeLookup(target, found, stats)
+	return found, report
+}
+
+func (node *Node) disjointLookup(initNodes []Contact, target KademliaID, paths int) ([]Contact, DisjointLookup) {
+	report := DisjointLookup{
+		Paths: make([][]Contact, paths),
+		Stats: make([]LookupStats, paths),
+	}
+	claimed := make(map[KademliaID]int)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	// The contacts the lookup starts from are dealt out between the paths before any path runs.
+	seeds := make([][]Contact, paths)
+	for i, con := range initNodes {
+		seeds[i%paths] = append(seeds[i%paths], con)
+		claimed[con.ID()] = i % paths
+	}
+	for path := range paths {
+		claim := func(id KademliaID) bool {
+			lock.Lock()
+			defer lock.Unlock()
+			owner, ok := claimed[id]
+			if !ok {
+				claimed[id] = path
+				return true
+			}
+			return owner == path
+		}
+		wg.Add(1)
+		node.routines.Go("disjoint lookup path", func() {
+			defer wg.Done()
+			res := node.lookupPath(seeds[path], target, func(con Contact) lookupResponse { return node.findNodeQuery(con, target) }, claim)
+			report.Paths[path] = res.contacts
+			report.Stats[path] = res.stats
+		})
+	}
+	wg.Wait()
+
+	found := make([]Contact, 0, node.config.Replication)
+	seen := make(map[KademliaID]bool)
+	report.Converged = len(report.Paths[0]) > 0
+	for _, contacts := range report.Paths {
+		if len(contacts) == 0 || (report.Converged && contacts[0].ID() != report.Paths[0][0].ID()) {
+			report.Converged = false
+		}
+		for _, con := range contacts {
+			if !seen[con.ID()] {
+				seen[con.ID()] = true
+				found = append(found, con)
+			}
+		}
+	}
+	SortContactsByDistance(&found, target)
+	return found[:min(len(found), node.config.Replication)], report
+}
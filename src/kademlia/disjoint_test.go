@@ -0,0 +1,68 @@
+package kademlia
+
+import (
+	"log"
+	"slices"
+	"testing"
+)
+
+// Returns a scripted node taking two lookup paths, and its peers. Peers from index 20 to 25 are
+// malicious and only ever point at each other, trying to keep lookups away from their target.
+func disjointNode() (*Node, *ScriptedSender, []Contact) {
+	node, script, peers := scriptedNode(30)
+	honest := script.FindNodeResponse()
+	malicious := peers[20:25]
+	script.On(FIND_NODE, func(peer Contact, req RPC, resp *RPC) {
+		if slices.ContainsFunc(malicious, func(con Contact) bool { return con.ID() == peer.ID() }) {
+			resp.FoundNodes(req.findNodeTarget, malicious)
+			return
+		}
+		honest(peer, req, resp)
+	})
+	node.SetLookupPaths(2)
+	return node, script, peers
+}
+
+func TestDisjointLookup(t *testing.T) {
+	testName := "TestDisjointLookup"
+	node, _, peers := disjointNode()
+	if err := node.SetLookupPaths(0); err == nil {
+		log.Printf("[%s] - accepted zero lookup paths", testName)
+		t.Fail()
+	}
+	target := peers[5]
+	node.AddContact(peers[0])
+	node.AddContact(peers[1])
+	found, report := node.FindNodeDisjoint(target.ID())
+	if len(found) == 0 || found[0].ID() != target.ID() || !report.Converged || len(report.Paths) != 2 {
+		log.Printf("[%s] - honest paths did not converge on the target: %+v", testName, report)
+		t.Fail()
+	}
+}
+
+func TestDisjointLookupMisrouted(t *testing.T) {
+	testName := "TestDisjointLookupMisrouted"
+	node, script, peers := disjointNode()
+	target := peers[5]
+	node.AddContact(peers[0])
+	node.AddContact(peers[20])
+	found, report := node.FindNodeDisjoint(target.ID())
+	if len(found) == 0 || found[0].ID() != target.ID() {
+		log.Printf("[%s] - the honest path did not find the target", testName)
+		t.Fail()
+	}
+	if report.Converged {
+		log.Printf("[%s] - paths converged although one only saw malicious peers", testName)
+		t.Fail()
+	}
+	queried := make(map[[4]byte]int)
+	for _, rpc := range script.Sent(FIND_NODE) {
+		queried[rpc.receiver]++
+	}
+	for ip, count := range queried {
+		if count > 1 {
+			log.Printf("[%s] - peer %v queried by more than one path", testName, ip)
+			t.Fail()
+		}
+	}
+}
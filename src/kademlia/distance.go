@@ -0,0 +1,70 @@
+package kademlia
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// XOR distance between two ids, totally ordered as an unsigned integer. Distances are comparable,
+// so they can key maps and order sorted containers, their representation is not part of the API.
+type Distance struct {
+	words [ID_WORDS]uint32 // most significant word first
+}
+
+// Compares the distances as unsigned integers.
+// Returns -1 if dist is smaller than other, 0 if they are equal and 1 otherwise.
+func (dist Distance) Cmp(other Distance) int {
+	for i := range dist.words {
+		if dist.words[i] < other.words[i] {
+			return -1
+		} else if dist.words[i] > other.words[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Returns true if dist is smaller than other.
+func (dist Distance) Less(other Distance) bool {
+	return dist.Cmp(other) < 0
+}
+
+func (dist Distance) Equal(other Distance) bool {
+	return dist == other
+}
+
+// Returns true if the distance is between an id and itself.
+func (dist Distance) IsZero() bool {
+	return dist == Distance{}
+}
+
+// Returns the number of leading zero bits, the length of the prefix shared by the two ids.
+func (dist Distance) LeadingZeros() int {
+	length := 0
+	for _, word := range dist.words {
+		length += bits.LeadingZeros32(word)
+		if word != 0 {
+			break
+		}
+	}
+	return length
+}
+
+// Returns the distance as ID_BYTES bytes, most significant byte first.
+func (dist Distance) Bytes() [ID_BYTES]byte {
+	var res [ID_BYTES]byte
+	for i, word := range dist.words {
+		binary.BigEndian.PutUint32(res[i*4:], word)
+	}
+	return res
+}
+
+// Formats the distance as hexadecimal like an id.
+func (dist Distance) String() string {
+	res := ""
+	for _, word := range dist.words {
+		res += fmt.Sprintf("%08x", word)
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package kademlia
+
+import (
+	"log"
+	"slices"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	testName := "TestDistance"
+	target := KademliaID{0, 0, 0, 0, 5}
+	near := KademliaID{0, 0, 0, 0, 7}.Distance(target)
+	far := KademliaID{0, 0, 0, 1, 0}.Distance(target)
+	if !near.Less(far) || far.Less(near) || near.Less(near) || !near.Equal(near) || near.Equal(far) {
+		log.Printf("[%s] - wrong ordering of %v and %v", testName, near, far)
+		t.Fail()
+	}
+	if !target.Distance(target).IsZero() || near.IsZero() {
+		log.Printf("[%s] - only the distance of an id to itself is zero", testName)
+		t.Fail()
+	}
+	if near.String() != "0000000000000000000000000000000000000002" || near.Bytes()[ID_BYTES-1] != 2 {
+		log.Printf("[%s] - wrong encoding of %v", testName, near)
+		t.Fail()
+	}
+	if near.LeadingZeros() != ID_BITS-2 || far.LeadingZeros() != 3*32+31 {
+		log.Printf("[%s] - wrong leading zeros %d and %d", testName, near.LeadingZeros(), far.LeadingZeros())
+		t.Fail()
+	}
+
+	// distances key maps and sort like the contacts they belong to
+	contacts := []Contact{NewRandomContact(), NewRandomContact(), NewRandomContact()}
+	byDistance := make(map[Distance]Contact)
+	dists := make([]Distance, 0, len(contacts))
+	for _, con := range contacts {
+		byDistance[con.ID().Distance(target)] = con
+		dists = append(dists, con.ID().Distance(target))
+	}
+	slices.SortFunc(dists, Distance.Cmp)
+	SortContactsByDistance(&contacts, target)
+	for i, dist := range dists {
+		if byDistance[dist] != contacts[i] {
+			log.Printf("[%s] - contact %d out of order", testName, i)
+			t.Fail()
+		}
+	}
+}
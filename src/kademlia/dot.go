@@ -0,0 +1,99 @@
+package kademlia
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Settings of a DOT export, see ExportDOTWithOptions.
+type DOTOptions struct {
+	ColorByBucket bool // color every edge by the bucket the contact occupies in its holder's routing table
+	Labels        bool // label nodes with their IP as well as the start of their id
+}
+
+// Writes the simulated network as a directed DOT graph with an edge from every node to every
+// contact in its routing table, see ExportDOTWithOptions.
+func (simnet *Simnet) ExportDOT(w io.Writer) error {
+	return simnet.ExportDOTWithOptions(w, DOTOptions{})
+}
+
+// Writes the simulated network as a directed DOT graph with an edge from every node to every
+// contact in its routing table. The master node is drawn as a box. Nodes the master node can not
+// reach by following routing table entries are filled grey, and entries for nodes that are no
+// longer live are dashed red edges to a dead node, so isolated subgraphs and stale contacts stand
+// out.
+func (simnet *Simnet) ExportDOTWithOptions(w io.Writer, opts DOTOptions) error {
+	topology := simnet.Topology()
+	live := make(map[KademliaID]Contact, len(topology.Nodes))
+	for _, con := range topology.Nodes {
+		live[con.ID()] = con
+	}
+	reachable := topology.reachable(topology.Master.ID())
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph scalegraph {")
+	fmt.Fprintln(out, "\tnode [shape=ellipse, fontname=monospace];")
+	for _, con := range topology.Nodes {
+		attrs := fmt.Sprintf("label=%q", dotLabel(con, opts.Labels))
+		if con.ID() == topology.Master.ID() {
+			attrs += ", shape=box"
+		}
+		if !reachable[con.ID()] {
+			attrs += ", style=filled, fillcolor=grey"
+		}
+		fmt.Fprintf(out, "\t%q [%s];\n", con.ID().String(), attrs)
+	}
+	dead := make(map[KademliaID]bool)
+	for _, edge := range topology.Edges {
+		attrs := make([]string, 0, 2)
+		if _, ok := live[edge.To]; !ok {
+			if !dead[edge.To] {
+				dead[edge.To] = true
+				fmt.Fprintf(out, "\t%q [label=%q, style=dashed, color=red];\n", edge.To.String(), edge.To.String()[:8])
+			}
+			attrs = append(attrs, "style=dashed", "color=red")
+		} else if opts.ColorByBucket {
+			bucket := DistPrefixLength(edge.From, edge.To)
+			attrs = append(attrs, fmt.Sprintf("color=\"%.3f 0.850 0.850\"", float64(bucket)/KEYSPACE))
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(out, "\t%q -> %q [%s];\n", edge.From.String(), edge.To.String(), strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(out, "\t%q -> %q;\n", edge.From.String(), edge.To.String())
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+func dotLabel(con Contact, withIP bool) string {
+	label := con.ID().String()[:8]
+	if withIP {
+		ip := con.IP()
+		label += fmt.Sprintf("\n%d.%d.%d.%d", ip[0], ip[1], ip[2], ip[3])
+	}
+	return label
+}
+
+// Returns the live nodes reachable from start by following routing table entries.
+func (topology Topology) reachable(start KademliaID) map[KademliaID]bool {
+	adjacent := make(map[KademliaID][]KademliaID)
+	for _, edge := range topology.Edges {
+		adjacent[edge.From] = append(adjacent[edge.From], edge.To)
+	}
+	res := map[KademliaID]bool{start: true}
+	queue := []KademliaID{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacent[current] {
+			if !res[next] {
+				res[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return res
+}
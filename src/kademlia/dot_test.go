@@ -0,0 +1,60 @@
+package kademlia
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestExportDOT(t *testing.T) {
+	testName := "TestExportDOT"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(4, done)
+	<-done
+	defer s.Shutdown()
+
+	victim := nodes[0]
+	s.ShutdownNode(victim)
+	var out bytes.Buffer
+	err := s.ExportDOTWithOptions(&out, DOTOptions{ColorByBucket: true, Labels: true})
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	graph := out.String()
+	if !strings.HasPrefix(graph, "digraph scalegraph {") || !strings.HasSuffix(graph, "}\n") {
+		log.Printf("[%s] - not a DOT graph:\n%s", testName, graph)
+		t.FailNow()
+	}
+	if strings.Count(graph, "->") != len(s.Topology().Edges) {
+		log.Printf("[%s] - expected %d edges:\n%s", testName, len(s.Topology().Edges), graph)
+		t.Fail()
+	}
+	if !strings.Contains(graph, "shape=box") || !strings.Contains(graph, "0.850 0.850") {
+		log.Printf("[%s] - missing the master node or bucket colors:\n%s", testName, graph)
+		t.Fail()
+	}
+	// Entries for the shut down node are the only stale ones, and they must be drawn as such.
+	for _, line := range strings.Split(graph, "\n") {
+		toVictim := strings.Contains(line, "-> \""+victim.ID().String())
+		if toVictim != strings.Contains(line, "color=red") && strings.Contains(line, "->") {
+			log.Printf("[%s] - edge drawn wrongly: %s", testName, line)
+			t.Fail()
+		}
+	}
+}
+
+func TestTopologyReachable(t *testing.T) {
+	testName := "TestTopologyReachable"
+	a, b, c, d := KademliaID{1}, KademliaID{2}, KademliaID{3}, KademliaID{4}
+	topology := Topology{Edges: []Edge{{a, b}, {b, c}, {d, a}}}
+	reachable := topology.reachable(a)
+	if !reachable[a] || !reachable[b] || !reachable[c] || reachable[d] {
+		log.Printf("[%s] - expected a, b and c to be reachable from a, got %v", testName, reachable)
+		t.Fail()
+	}
+}
@@ -0,0 +1,201 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DUMP_EVENTS = 256 // recent events a node keeps for its dumps
+
+// An event published by a node, kept for its dumps.
+type EventRecord struct {
+	Time   time.Time
+	Type   string
+	Detail string
+}
+
+// The most recent events published by a node, the oldest are dropped once DUMP_EVENTS are held.
+type eventLog struct {
+	content []EventRecord
+	sync.Mutex
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{
+		content: make([]EventRecord, 0, DUMP_EVENTS),
+	}
+}
+
+func (log *eventLog) record(event Event) {
+	log.Lock()
+	defer log.Unlock()
+	if len(log.content) == DUMP_EVENTS {
+		log.content = slices.Delete(log.content, 0, 1)
+	}
+	log.content = append(log.content, EventRecord{time.Now(), fmt.Sprintf("%T", event), fmt.Sprintf("%+v", event)})
+}
+
+func (log *eventLog) recent() []EventRecord {
+	log.Lock()
+	defer log.Unlock()
+	return append([]EventRecord(nil), log.content...)
+}
+
+// Publishes the event on the node's event bus and keeps it for the node's dumps.
+func (node *Node) publish(event Event) {
+	node.recent.record(event)
+	node.events.Publish(event)
+}
+
+// A routing table entry in a dump.
+type ContactDump struct {
+	ID           KademliaID
+	IP           [4]byte
+	Bucket       int
+	Capabilities string // empty if the contact has not advertised any
+}
+
+// An accepted proposal waiting to be committed, in a dump.
+type ProposalDump struct {
+	Transaction KademliaID
+	Account     KademliaID
+	Sender      KademliaID
+	Receiver    KademliaID
+	Amount      uint64
+	Expires     time.Time
+}
+
+// Snapshot of a node's state for post-mortem analysis, see Node.Dump.
+type NodeDump struct {
+	Taken        time.Time
+	ID           KademliaID
+	IP           [4]byte
+	Network      NetworkID
+	Role         string
+	Capabilities string
+	Version      ProtocolVersion
+	MinVersion   ProtocolVersion
+	Stopped      bool
+	Contacts     []ContactDump
+	Pending      []KademliaID             // requests waiting for a response
+	Wallets      []Wallet                 // wallets the node validates
+	Proposals    []ProposalDump           // accepted transactions waiting to be committed
+	Messages     map[KademliaID][]Message // messages held for other recipients
+	Queue        QueueStats
+	Routines     map[string]int
+	Events       []EventRecord
+}
+
+// Returns a snapshot of the node's routing table, pending requests, stored wallets and messages,
+// open proposals, queue and goroutines, and the events it published most recently.
+// Each part is read separately, so a dump of a busy node is not a single consistent cut.
+func (node *Node) Dump() NodeDump {
+	version, minVersion := node.ProtocolVersion()
+	dump := NodeDump{
+		Taken:        time.Now(),
+		ID:           node.ID(),
+		IP:           node.IP(),
+		Network:      node.NetworkID(),
+		Role:         node.Role().String(),
+		Capabilities: node.Capabilities().String(),
+		Version:      version,
+		MinVersion:   minVersion,
+		Stopped:      node.Stopped(),
+		Contacts:     make([]ContactDump, 0),
+		Pending:      make([]KademliaID, 0),
+		Proposals:    make([]ProposalDump, 0),
+		Messages:     make(map[KademliaID][]Message),
+		Wallets:      node.Ledger().Wallets(),
+		Queue:        node.QueueStats(),
+		Routines:     node.routines.Active(),
+		Events:       node.recent.recent(),
+	}
+	for _, con := range node.AllContacts() {
+		bucket, _ := node.BucketIndex(con.ID())
+		caps := ""
+		if advertised, ok := node.ContactCapabilities(con.ID()); ok {
+			caps = advertised.String()
+		}
+		dump.Contacts = append(dump.Contacts, ContactDump{con.ID(), con.IP(), bucket, caps})
+	}
+	slices.SortFunc(dump.Contacts, func(a ContactDump, b ContactDump) int { return a.ID.Cmp(b.ID) })
+
+	node.Network.table.RLock()
+	for id := range node.Network.table.content {
+		dump.Pending = append(dump.Pending, id)
+	}
+	node.Network.table.RUnlock()
+	slices.SortFunc(dump.Pending, KademliaID.Cmp)
+
+	node.proposals.Lock()
+	for key, prop := range node.proposals.content {
+		dump.Proposals = append(dump.Proposals, ProposalDump{key.trxID, prop.accID, prop.trx.Sender(), prop.trx.Receiver(), prop.trx.Amount(), prop.expires})
+	}
+	node.proposals.Unlock()
+	slices.SortFunc(dump.Proposals, func(a ProposalDump, b ProposalDump) int {
+		if c := a.Transaction.Cmp(b.Transaction); c != 0 {
+			return c
+		}
+		return a.Account.Cmp(b.Account)
+	})
+
+	node.mailbox.Lock()
+	for recipient, msgs := range node.mailbox.stored {
+		dump.Messages[recipient] = append([]Message(nil), msgs...)
+	}
+	node.mailbox.Unlock()
+	return dump
+}
+
+// Writes the node's dump to path as indented JSON.
+func (node *Node) WriteDump(path string) error {
+	data, err := json.MarshalIndent(node.Dump(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Makes the node write a dump to dir if one of its goroutines panics, the panic then continues.
+// An empty dir turns the dumps off.
+func (node *Node) DumpOnPanic(dir string) {
+	if dir == "" {
+		node.routines.onPanic(nil)
+		return
+	}
+	node.routines.onPanic(func(reason any) {
+		path := filepath.Join(dir, fmt.Sprintf("node-%v-%d.json", node.ID(), time.Now().UnixNano()))
+		err := node.WriteDump(path)
+		if err != nil {
+			node.logger.Error("failed to write dump", "path", path, "panic", reason, "err", err)
+			return
+		}
+		node.logger.Error("goroutine panicked, dump written", "path", path, "panic", reason)
+	})
+}
+
+// Formats the id as hexadecimal, so ids in dumps read like ids in logs.
+func (id KademliaID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *KademliaID) UnmarshalText(text []byte) error {
+	if len(text) != ID_WORDS*8 {
+		return errors.New(fmt.Sprintf("malformed id %q", text))
+	}
+	for i := range id {
+		word, err := strconv.ParseUint(string(text[i*8:(i+1)*8]), 16, 32)
+		if err != nil {
+			return errors.New(fmt.Sprintf("malformed id %q", text))
+		}
+		id[i] = uint32(word)
+	}
+	return nil
+}
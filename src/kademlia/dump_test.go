@@ -0,0 +1,89 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNodeDump(t *testing.T) {
+	testName := "TestNodeDump"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+
+	id := RandomID()
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	path := filepath.Join(t.TempDir(), "dump.json")
+	if err := nodes[0].WriteDump(path); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	var dump NodeDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		log.Printf("[%s] - dump is not valid JSON: %s", testName, err.Error())
+		t.FailNow()
+	}
+	if dump.ID != nodes[0].ID() || len(dump.Contacts) == 0 {
+		log.Printf("[%s] - dump of %v has id %v and %d contacts", testName, nodes[0].ID(), dump.ID, len(dump.Contacts))
+		t.Fail()
+	}
+	stored := false
+	for _, wallet := range dump.Wallets {
+		stored = stored || wallet.ID == id
+	}
+	if _, err := nodes[0].Ledger().Wallet(id); (err == nil) != stored {
+		log.Printf("[%s] - dump does not match the node's wallets", testName)
+		t.Fail()
+	}
+	lookups := 0
+	for _, event := range dump.Events {
+		if event.Type == "kademlia.LookupCompleted" {
+			lookups++
+		}
+	}
+	if lookups == 0 {
+		log.Printf("[%s] - dump holds no lookup events", testName)
+		t.Fail()
+	}
+}
+
+func TestDumpOnPanic(t *testing.T) {
+	testName := "TestDumpOnPanic"
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC, 8), make(chan RPC, 8), [4]byte{0, 0, 0, 0}, me, false)
+	node.SetLogLevel(LOG_SILENT)
+	dir := t.TempDir()
+	node.DumpOnPanic(dir)
+
+	// Recover the continued panic so the test binary survives it.
+	done := make(chan any, 1)
+	node.routines.Go("panics", func() {
+		defer func() { done <- recover() }()
+		defer node.routines.recoverPanic()
+		panic("boom")
+	})
+	if <-done != "boom" {
+		log.Printf("[%s] - panic did not continue after the dump", testName)
+		t.Fail()
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		log.Printf("[%s] - expected one dump, found %d", testName, len(files))
+		t.Fail()
+	}
+}
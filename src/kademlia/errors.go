@@ -0,0 +1,38 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Failure modes of the protocol, match them with errors.Is. The errors returned carry the
+// details of the failure in their message and wrap one of these.
+var (
+	ErrTimeout           = errors.New("timeout")             // no response arrived in time
+	ErrShutdown          = errors.New("shutdown")            // the node was stopped while sending or waiting
+	ErrDuplicateRPCID    = errors.New("RPC id in use")       // a request with the same id is still waiting for its response
+	ErrNoMatchingRPC     = errors.New("no matching RPC id")  // a response arrived for no waiting request
+	ErrNodeNotFound      = errors.New("node not found")      // no node or contact with the requested id or ip
+	ErrIllegalEntryPoint = errors.New("illegal entry point") // the bootstrapper offered no entry point the node can join through
+	ErrQuorumNotReached  = errors.New("quorum not reached")  // too few validators answered or agreed
+	ErrJoinRejected      = errors.New("join rejected")       // the entry service did not accept the node's join token
+)
+
+// An error of one of the failure modes above, with its own message.
+type protocolError struct {
+	kind error
+	msg  string
+}
+
+func (err *protocolError) Error() string {
+	return err.msg
+}
+
+func (err *protocolError) Unwrap() error {
+	return err.kind
+}
+
+// Returns an error matching kind with the formatted message.
+func failure(kind error, format string, args ...any) error {
+	return &protocolError{kind, fmt.Sprintf(format, args...)}
+}
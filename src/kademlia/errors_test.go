@@ -0,0 +1,46 @@
+package kademlia
+
+import (
+	"errors"
+	"log"
+	"testing"
+)
+
+func TestProtocolErrors(t *testing.T) {
+	testName := "TestProtocolErrors"
+	table := NewTable()
+	id := RandomID()
+	table.Add(id)
+	if _, err := table.Add(id); !errors.Is(err, ErrDuplicateRPCID) {
+		log.Printf("[%s] - expected a duplicate id, got %v", testName, err)
+		t.Fail()
+	}
+	if _, err := table.RetrieveChan(RandomID()); !errors.Is(err, ErrNoMatchingRPC) {
+		log.Printf("[%s] - expected no matching id, got %v", testName, err)
+		t.Fail()
+	}
+
+	node, script, peers := scriptedNode(10)
+	if _, err := node.FindByIP(peers[0].IP()); !errors.Is(err, ErrNodeNotFound) {
+		log.Printf("[%s] - expected an unknown contact, got %v", testName, err)
+		t.Fail()
+	}
+	rpc := GenerateRPC(RandomIP(), node.Contact)
+	rpc.Ping()
+	if _, err := node.Send(rpc); !errors.Is(err, ErrTimeout) {
+		log.Printf("[%s] - expected a timeout from an unknown peer, got %v", testName, err)
+		t.Fail()
+	}
+	node.AddContact(peers[0])
+	if _, _, err := node.FindAccount(RandomID()); !errors.Is(err, ErrQuorumNotReached) {
+		log.Printf("[%s] - expected no quorum for an unstored account, got %v", testName, err)
+		t.Fail()
+	}
+
+	script.Fail(peers[1].IP(), ErrTimeout)
+	node.SetBootstrapper(StaticPeerList{Peers: peers[1:2]})
+	if err := node.Enter(); !errors.Is(err, ErrIllegalEntryPoint) || errors.Is(err, ErrTimeout) {
+		log.Printf("[%s] - expected an unanswered entry point, got %v", testName, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,143 @@
+package kademlia
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Event is implemented by every event published on an EventBus, subscribers type switch on
+// the concrete event types below.
+type Event interface {
+	event()
+}
+
+// A node was attached to the simulated network.
+type NodeSpawned struct {
+	Node Contact
+}
+
+// A node was removed from the simulated network and stopped.
+type NodeShutdown struct {
+	Node Contact
+}
+
+// A contact was added to a node's routing table.
+type ContactAdded struct {
+	Node    Contact
+	Contact Contact
+}
+
+// A contact was removed from a node's routing table, or evicted by a closer one.
+type ContactRemoved struct {
+	Node    Contact
+	Contact Contact
+}
+
+// A RPC entered the simulated network.
+type RPCSent struct {
+	ID       KademliaID
+	Cmd      Command
+	Sender   Contact
+	Receiver [4]byte
+	Response bool
+}
+
+// A RPC was lost, either to the drop roll or because its receiver does not exist.
+type RPCDropped struct {
+	ID       KademliaID
+	Cmd      Command
+	Sender   Contact
+	Receiver [4]byte
+	Response bool
+	Reason   string
+}
+
+// A RPC was delivered to its receiver's inbox.
+type RPCDelivered struct {
+	ID       KademliaID
+	Cmd      Command
+	Sender   Contact
+	Receiver [4]byte
+	Response bool
+}
+
+// A node finished a node lookup.
+type LookupCompleted struct {
+	Node   Contact
+	Target KademliaID
+	Hops   int
+	RPCs   int
+	Found  []Contact
+}
+
+func (NodeSpawned) event()     {}
+func (NodeShutdown) event()    {}
+func (RPCSent) event()         {}
+func (RPCDropped) event()      {}
+func (RPCDelivered) event()    {}
+func (LookupCompleted) event() {}
+func (ContactAdded) event()    {}
+func (ContactRemoved) event()  {}
+
+type subscription struct {
+	events chan Event
+	missed atomic.Int64
+}
+
+// Fans published events out to every subscriber.
+// Publishing never blocks, events that do not fit in a subscriber's buffer are counted as missed.
+// A nil bus accepts and discards events so components can publish unconditionally.
+type EventBus struct {
+	subscribers map[*subscription]struct{}
+	sync.RWMutex
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[*subscription]struct{}),
+	}
+}
+
+// Returns a channel receiving every event published from now on, buffered to size.
+// The returned function ends the subscription, closes the channel and returns the number of
+// events that were missed because the buffer was full.
+func (bus *EventBus) Subscribe(size int) (<-chan Event, func() int) {
+	sub := &subscription{
+		events: make(chan Event, size),
+	}
+	bus.Lock()
+	bus.subscribers[sub] = struct{}{}
+	bus.Unlock()
+
+	var once sync.Once
+	cancel := func() int {
+		once.Do(func() {
+			bus.Lock()
+			delete(bus.subscribers, sub)
+			bus.Unlock()
+			close(sub.events)
+		})
+		return int(sub.missed.Load())
+	}
+	return sub.events, cancel
+}
+
+func (bus *EventBus) Publish(event Event) {
+	if bus == nil {
+		return
+	}
+	bus.RLock()
+	defer bus.RUnlock()
+	for sub := range bus.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.missed.Add(1)
+		}
+	}
+}
+
+// Returns the bus the simnet and its nodes publish on.
+func (simnet *Simnet) Events() *EventBus {
+	return simnet.events
+}
@@ -0,0 +1,63 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestSimnetEvents(t *testing.T) {
+	testName := "TestSimnetEvents"
+	s := NewServer(false, 0.0)
+	events, cancel := s.Events().Subscribe(16)
+
+	receiver := s.GenerateRandomNode()
+	rpc := GenerateRPC(receiver.IP(), NewRandomContact())
+	rpc.Ping()
+	s.Route(rpc)
+	lost := GenerateRPC(RandomIP(), NewRandomContact())
+	lost.Ping()
+	s.Route(lost)
+	missed := cancel()
+
+	received := make([]Event, 0)
+	for e := range events {
+		received = append(received, e)
+	}
+	if missed != 0 || len(received) != 5 {
+		log.Printf("[%s] - expected 5 events and none missed, received %d and missed %d", testName, len(received), missed)
+		t.FailNow()
+	}
+	spawned, ok := received[0].(NodeSpawned)
+	if !ok || spawned.Node.ID() != receiver.ID() {
+		log.Printf("[%s] - expected node spawned event, received %#v", testName, received[0])
+		t.Fail()
+	}
+	if _, ok := received[1].(RPCSent); !ok {
+		log.Printf("[%s] - expected rpc sent event, received %#v", testName, received[1])
+		t.Fail()
+	}
+	if delivered, ok := received[2].(RPCDelivered); !ok || delivered.ID != rpc.id {
+		log.Printf("[%s] - expected rpc delivered event, received %#v", testName, received[2])
+		t.Fail()
+	}
+	if dropped, ok := received[4].(RPCDropped); !ok || dropped.ID != lost.id {
+		log.Printf("[%s] - expected rpc dropped event, received %#v", testName, received[4])
+		t.Fail()
+	}
+}
+
+func TestEventBusMissed(t *testing.T) {
+	testName := "TestEventBusMissed"
+	bus := NewEventBus()
+	_, cancel := bus.Subscribe(1)
+	for range 3 {
+		bus.Publish(NodeSpawned{NewRandomContact()})
+	}
+	missed := cancel()
+	if missed != 2 {
+		log.Printf("[%s] - expected 2 missed events, found %d", testName, missed)
+		t.Fail()
+	}
+	var nilBus *EventBus
+	nilBus.Publish(NodeSpawned{})
+}
@@ -0,0 +1,144 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Inter-region link between two bridged simnets, applied to every RPC crossing it in either
+// direction.
+type Bridge struct {
+	Latency time.Duration // one way delay of every RPC crossing the bridge
+	Loss    float32       // probability that a RPC crossing the bridge is lost
+}
+
+// Traffic that left a simnet over a bridge.
+type BridgeStats struct {
+	Crossed uint64 // RPCs handed to the bridged simnet
+	Lost    uint64 // RPCs lost on the bridge
+}
+
+type bridgeLink struct {
+	Bridge
+	crossed atomic.Uint64
+	lost    atomic.Uint64
+}
+
+// Bridges to other simnets keyed by the remote simnet.
+type bridgeTable struct {
+	content map[*Simnet]*bridgeLink
+	sync.RWMutex
+}
+
+func newBridgeTable() *bridgeTable {
+	return &bridgeTable{
+		content: make(map[*Simnet]*bridgeLink),
+	}
+}
+
+// Links the simnet to other so that RPCs addressed to nodes of either simnet are routed across
+// the bridge, delayed by latency and lost with probability loss.
+// The master nodes are introduced to each other so that lookups spread across the bridge.
+// Returns an error if the simnets are already bridged or share a node IP.
+func (simnet *Simnet) Bridge(other *Simnet, latency time.Duration, loss float32) error {
+	if other == simnet {
+		return errors.New("can not bridge a simnet to itself")
+	}
+	if _, ok := simnet.bridge(other); ok {
+		return errors.New("simnets are already bridged")
+	}
+	for _, ip := range other.nodeIPs() {
+		if simnet.hasNode(ip) {
+			return errors.New(fmt.Sprintf("simnets share the node ip %v", ip))
+		}
+	}
+	bridge := Bridge{latency, loss}
+	simnet.bridges.Lock()
+	simnet.bridges.content[other] = &bridgeLink{Bridge: bridge}
+	simnet.bridges.Unlock()
+	other.bridges.Lock()
+	other.bridges.content[simnet] = &bridgeLink{Bridge: bridge}
+	other.bridges.Unlock()
+
+	simnet.masterNode.Ping(other.masterNodeContact.IP())
+	other.masterNode.Ping(simnet.masterNodeContact.IP())
+	simnet.masterNode.FindNode(simnet.masterNodeContact.ID())
+	other.masterNode.FindNode(other.masterNodeContact.ID())
+	return nil
+}
+
+// Removes the bridge between the simnet and other in both directions.
+func (simnet *Simnet) Unbridge(other *Simnet) {
+	simnet.bridges.Lock()
+	delete(simnet.bridges.content, other)
+	simnet.bridges.Unlock()
+	other.bridges.Lock()
+	delete(other.bridges.content, simnet)
+	other.bridges.Unlock()
+}
+
+// Returns the traffic sent from the simnet to other, or false if they are not bridged.
+func (simnet *Simnet) BridgeStats(other *Simnet) (BridgeStats, bool) {
+	link, ok := simnet.bridge(other)
+	if !ok {
+		return BridgeStats{}, false
+	}
+	return BridgeStats{link.crossed.Load(), link.lost.Load()}, true
+}
+
+func (simnet *Simnet) bridge(other *Simnet) (*bridgeLink, bool) {
+	simnet.bridges.RLock()
+	defer simnet.bridges.RUnlock()
+	link, ok := simnet.bridges.content[other]
+	return link, ok
+}
+
+func (simnet *Simnet) hasNode(ip [4]byte) bool {
+	_, ok := simnet.chanTable.lookup(ip)
+	return ok
+}
+
+func (simnet *Simnet) nodeIPs() [][4]byte {
+	return simnet.chanTable.ips()
+}
+
+// Hands a RPC whose receiver is not attached to the simnet to the bridged simnet that has it.
+// Returns false if no bridged simnet knows the receiver.
+func (simnet *Simnet) routeBridged(rpc RPC, start time.Time) bool {
+	simnet.bridges.RLock()
+	var remote *Simnet
+	var link *bridgeLink
+	for other, l := range simnet.bridges.content {
+		if other.hasNode(rpc.receiver) {
+			remote, link = other, l
+			break
+		}
+	}
+	simnet.bridges.RUnlock()
+	if remote == nil {
+		return false
+	}
+
+	if link.Latency > 0 && rpc.routeStart.IsZero() {
+		rpc.routeStart = start
+		simnet.hold(rpc, link.Latency)
+		return true
+	}
+	if link.Loss > 0 && simnet.rng.float32() < link.Loss {
+		link.lost.Add(1)
+		simnet.metrics.RPCRouted(rpc.cmd, true)
+		simnet.stats.recordRoute(rpc, false, true, simnet.timebase.Since(start))
+		simnet.trace(rpc, start, "bridge loss")
+		simnet.events.Publish(RPCDropped{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response, "bridge loss"})
+		simnet.logger.Debug("rpc lost on bridge", "rpc", rpc.id, "cmd", rpc.cmd)
+		return true
+	}
+	link.crossed.Add(1)
+	// the remote simnet routes the RPC afresh, applying its own delays
+	rpc.routeStart = time.Time{}
+	remote.Route(rpc)
+	return true
+}
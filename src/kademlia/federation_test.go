@@ -0,0 +1,104 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestBridgeCrossRegionLookup(t *testing.T) {
+	testName := "TestBridgeCrossRegionLookup"
+	doneA := make(chan struct{}, 1)
+	doneB := make(chan struct{}, 1)
+	a := NewServer(false, 0.0)
+	b := NewServer(false, 0.0)
+	a.SetLogLevel(LOG_SILENT)
+	b.SetLogLevel(LOG_SILENT)
+	go a.StartServer()
+	go b.StartServer()
+	nodesA := a.SpawnCluster(10, doneA)
+	nodesB := b.SpawnCluster(10, doneB)
+	<-doneA
+	<-doneB
+	defer a.Shutdown()
+	defer b.Shutdown()
+
+	if nodesA[0].Ping(nodesB[0].IP()) {
+		log.Printf("[%s] - reached a node of another simnet without a bridge", testName)
+		t.Fail()
+	}
+
+	latency := 10 * time.Millisecond
+	if err := a.Bridge(b, latency, 0.0); err != nil {
+		log.Printf("[%s] - bridging failed: %s", testName, err.Error())
+		t.FailNow()
+	}
+	if err := b.Bridge(a, latency, 0.0); err == nil {
+		log.Printf("[%s] - bridged the same simnets twice", testName)
+		t.Fail()
+	}
+
+	start := time.Now()
+	if !nodesA[0].Ping(nodesB[0].IP()) {
+		log.Printf("[%s] - ping across the bridge failed", testName)
+		t.Fail()
+	}
+	if rtt := time.Since(start); rtt < 2*latency {
+		log.Printf("[%s] - round trip of %v is shorter than the bridge latency", testName, rtt)
+		t.Fail()
+	}
+
+	res, stats := nodesA[1].FindNodeWithStats(nodesB[1].ID())
+	if len(res) == 0 || res[0] != nodesB[1].Contact {
+		log.Printf("[%s] - lookup across the bridge did not find the target", testName)
+		t.Fail()
+	}
+	log.Printf("[%s] - cross region lookup: %d hops, %d rpcs in %v", testName, stats.Hops, stats.RPCs, stats.Duration)
+	crossed, _ := a.BridgeStats(b)
+	if crossed.Crossed == 0 {
+		log.Printf("[%s] - no traffic recorded on the bridge", testName)
+		t.Fail()
+	}
+
+	a.Unbridge(b)
+	if nodesA[0].Ping(nodesB[0].IP()) {
+		log.Printf("[%s] - reached a node of another simnet after unbridging", testName)
+		t.Fail()
+	}
+	if _, ok := b.BridgeStats(a); ok {
+		log.Printf("[%s] - bridge stats still present after unbridging", testName)
+		t.Fail()
+	}
+}
+
+func TestBridgeLoss(t *testing.T) {
+	testName := "TestBridgeLoss"
+	doneA := make(chan struct{}, 1)
+	doneB := make(chan struct{}, 1)
+	a := NewServer(false, 0.0)
+	b := NewServer(false, 0.0)
+	a.SetLogLevel(LOG_SILENT)
+	b.SetLogLevel(LOG_SILENT)
+	go a.StartServer()
+	go b.StartServer()
+	nodesA := a.SpawnCluster(2, doneA)
+	nodesB := b.SpawnCluster(2, doneB)
+	<-doneA
+	<-doneB
+	defer a.Shutdown()
+	defer b.Shutdown()
+
+	if err := a.Bridge(b, 0, 1.0); err != nil {
+		log.Printf("[%s] - bridging failed: %s", testName, err.Error())
+		t.FailNow()
+	}
+	if nodesA[0].Ping(nodesB[0].IP()) {
+		log.Printf("[%s] - ping crossed a bridge that loses everything", testName)
+		t.Fail()
+	}
+	lost, _ := a.BridgeStats(b)
+	if lost.Lost == 0 || lost.Crossed != 0 {
+		log.Printf("[%s] - expected only lost traffic, got %+v", testName, lost)
+		t.Fail()
+	}
+}
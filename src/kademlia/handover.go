@@ -0,0 +1,105 @@
+package kademlia
+
+import (
+	"slices"
+	"time"
+)
+
+const HANDOVER_CHUNK = 64 // values carried by a single TRANSFER_VALUES
+
+// A stored value handed to another node, with the time it has left to live.
+type valueTransfer struct {
+	key         KademliaID
+	data        []byte
+	ttl         time.Duration
+	replication int // closest nodes the value is kept at, zero for the receiver's replication
+}
+
+// Hands a chunk of stored values to a node that joined closer to their keys.
+func (rpc *RPC) TransferValues(values []valueTransfer) {
+	rpc.cmd = TRANSFER_VALUES
+	rpc.transfers = values
+}
+
+// Answers a value transfer, stored is false if the node refused to store any of them.
+func (rpc *RPC) TransferredValues(stored bool) {
+	rpc.cmd = TRANSFERRED_VALUES
+	rpc.valueFound = stored
+}
+
+// Returns the unexpired values held as one of the K closest nodes of their keys.
+func (store *valueStore) live(now time.Time) []valueTransfer {
+	store.Lock()
+	defer store.Unlock()
+	res := make([]valueTransfer, 0, len(store.stored))
+	for key, val := range store.stored {
+		if val.expires.After(now) {
+			res = append(res, valueTransfer{key, val.data, val.expires.Sub(now), val.replication})
+		}
+	}
+	return res
+}
+
+// Transfers the stored values whose K closest nodes now include the contact, or their replication
+// closest for values stored with one, as in the Kademlia paper: a node that joins learns the values it is responsible for from the nodes that
+// already hold them, so they stay findable as the topology shifts. Every holder that counts the
+// contact among the K closest transfers the value, the receiver overwrites the copies.
+func (node *Node) handOver(contact Contact) {
+	if node.Role() == OBSERVER {
+		return
+	}
+	now := node.Now()
+	transfers := make([]valueTransfer, 0)
+	for _, val := range node.values.live(now) {
+		if node.responsibleWith(contact, val.key, val.replication) {
+			transfers = append(transfers, val)
+		}
+	}
+	for start := 0; start < len(transfers); start += HANDOVER_CHUNK {
+		chunk := transfers[start:min(len(transfers), start+HANDOVER_CHUNK)]
+		rpc := GenerateRPC(contact.IP(), node.Contact)
+		rpc.TransferValues(chunk)
+		res, err := node.Send(rpc)
+		if err != nil {
+			node.logger.Debug("value handover failed", "receiver", contact.ID(), "values", len(chunk), "err", err)
+			return
+		}
+		if res.valueFound {
+			node.values.handedOver.Add(int64(len(chunk)))
+		}
+	}
+}
+
+// Returns true if contact is one of the replication nodes closest to key among the node itself
+// and its routing table, the K closest if replication is zero or larger than K.
+func (node *Node) responsibleWith(contact Contact, key KademliaID, replication int) bool {
+	if replication <= 0 || replication > node.config.Replication {
+		replication = node.config.Replication
+	}
+	closest, _ := node.FindXClosest(replication, key)
+	rank := slices.IndexFunc(closest, func(con Contact) bool { return con.ID() == contact.ID() })
+	if rank == -1 {
+		return false
+	}
+	if CloserNode(node.ID(), contact.ID(), key) {
+		rank++
+	}
+	return rank < replication
+}
+
+// Response logic for an incoming value transfer, values that do not hash to their key are skipped.
+func (node *Node) handleTransferValues(rpc *RPC) {
+	stored := node.Role() != OBSERVER
+	if stored {
+		now := node.Now()
+		for _, val := range rpc.transfers {
+			err := node.values.put(val.key, val.data, false, now.Add(min(val.ttl, VALUE_TTL)), val.replication)
+			if err != nil {
+				node.logger.Debug("refused to take over value", "rpc", rpc.id, "key", val.key, "err", err)
+			}
+		}
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.TransferredValues(stored)
+	node.Send(resp)
+}
@@ -0,0 +1,129 @@
+package kademlia
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestValueHandover(t *testing.T) {
+	testName := "TestValueHandover"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	data := []byte("handed over")
+	key, err := nodes[0].StoreValue(data)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	holders := make([]*Node, 0)
+	for _, n := range nodes {
+		if n.ValueStats().Stored > 0 {
+			holders = append(holders, n)
+		}
+	}
+
+	// a node joining right next to the key becomes its closest node
+	id := key
+	id[len(id)-1] ^= 1
+	joined, err := s.SpawnNodeWithID(id, make(chan KademliaID, 1))
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for joined.ValueStats().Stored == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if joined.ValueStats().Stored == 0 {
+		log.Printf("[%s] - the joined node was not handed the value", testName)
+		t.FailNow()
+	}
+	handedOver := 0
+	for _, n := range holders {
+		handedOver += n.ValueStats().HandedOver
+	}
+	if handedOver == 0 {
+		log.Printf("[%s] - no holder counted the handover", testName)
+		t.Fail()
+	}
+
+	// the original holders leave, the value lives on at the node that joined after it was stored
+	var reader *Node
+	for _, n := range nodes {
+		if n.ValueStats().Stored == 0 {
+			reader = n
+		}
+	}
+	// as its bucket refreshes would, the reader learns the nodes around the key before they change
+	reader.FindNode(key)
+	for _, n := range holders {
+		s.ShutdownNode(n)
+	}
+	found, err := reader.FindValue(key)
+	if err != nil || !bytes.Equal(found, data) {
+		log.Printf("[%s] - value lost once its original holders left: %v", testName, err)
+		t.Fail()
+	}
+}
+
+func TestValueReplication(t *testing.T) {
+	testName := "TestValueReplication"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	data := []byte("replicated to a few")
+	if _, err := nodes[0].StoreValueWithReplication(data, REPLICATION+1); err == nil {
+		log.Printf("[%s] - accepted a replication above K", testName)
+		t.Fail()
+	}
+	key, err := nodes[0].StoreValueWithReplication(data, 4)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	holders := 0
+	for _, n := range s.AllNodePointers() {
+		holders += n.ValueStats().Stored
+	}
+	if holders != 4 {
+		log.Printf("[%s] - expected 4 holders, got %d", testName, holders)
+		t.Fail()
+	}
+	if stats := nodes[0].ValueStats(); stats.Stores != 1 || stats.Replicas != 4 || stats.UnderReplicated != 0 {
+		log.Printf("[%s] - unexpected store counters: %+v", testName, stats)
+		t.Fail()
+	}
+
+	// the holders hand the value over with its replication
+	id := key
+	id[len(id)-1] ^= 1
+	joined, err := s.SpawnNodeWithID(id, make(chan KademliaID, 1))
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for joined.ValueStats().Stored == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	joined.values.Lock()
+	val, ok := joined.values.stored[key]
+	joined.values.Unlock()
+	if !ok || val.replication != 4 {
+		log.Printf("[%s] - the joined node was not handed the value with its replication: %t %d", testName, ok, val.replication)
+		t.Fail()
+	}
+}
@@ -0,0 +1,137 @@
+package kademlia
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const STALE_CONTACT = 120 * TIMEOUT // contacts not heard from for longer are counted as stale by Health
+
+// When each contact of a routing table was last heard from and each bucket was last refreshed.
+type seenTable struct {
+	contacts  map[KademliaID]time.Time
+	refreshed []time.Time
+	timebase  Clock
+	sync.Mutex
+}
+
+func newSeenTable(keySpace int) *seenTable {
+	return &seenTable{
+		contacts:  make(map[KademliaID]time.Time),
+		refreshed: make([]time.Time, keySpace),
+		timebase:  SystemClock(),
+	}
+}
+
+// Records that the contact in the bucket was heard from, which refreshes the bucket.
+func (seen *seenTable) touch(id KademliaID, bucket int) {
+	seen.Lock()
+	defer seen.Unlock()
+	now := seen.timebase.Now()
+	seen.contacts[id] = now
+	seen.refreshed[bucket] = now
+}
+
+func (seen *seenTable) refresh(bucket int) {
+	seen.Lock()
+	defer seen.Unlock()
+	seen.refreshed[bucket] = seen.timebase.Now()
+}
+
+func (seen *seenTable) now() time.Time {
+	seen.Lock()
+	defer seen.Unlock()
+	return seen.timebase.Now()
+}
+
+func (seen *seenTable) forget(id KademliaID) {
+	seen.Lock()
+	defer seen.Unlock()
+	delete(seen.contacts, id)
+}
+
+func (seen *seenTable) setTimebase(timebase Clock) {
+	seen.Lock()
+	defer seen.Unlock()
+	seen.timebase = timebase
+}
+
+// Returns every contact in the routing table, closest to the home node first.
+func (router *RoutingTable) Contacts() []Contact {
+	res := router.AllContacts()
+	SortContactsByDistance(&res, router.homeNode.ID())
+	return res
+}
+
+// Returns when the contact was last heard from, false if it is not in the routing table.
+func (router *RoutingTable) LastSeen(id KademliaID) (time.Time, bool) {
+	router.seen.Lock()
+	defer router.seen.Unlock()
+	res, ok := router.seen.contacts[id]
+	return res, ok
+}
+
+// Returns the contacts that have not been heard from for longer than threshold, least recently
+// heard from first.
+func (router *RoutingTable) StaleContacts(threshold time.Duration) []Contact {
+	contacts := router.AllContacts()
+	router.seen.Lock()
+	now := router.seen.timebase.Now()
+	seen := make(map[KademliaID]time.Time, len(contacts))
+	for _, con := range contacts {
+		seen[con.ID()] = router.seen.contacts[con.ID()]
+	}
+	router.seen.Unlock()
+
+	res := make([]Contact, 0)
+	for _, con := range contacts {
+		if now.Sub(seen[con.ID()]) > threshold {
+			res = append(res, con)
+		}
+	}
+	slices.SortStableFunc(res, func(a, b Contact) int { return seen[a.ID()].Compare(seen[b.ID()]) })
+	return res
+}
+
+// Returns when each bucket was last refreshed, indexed by bucket. A bucket is refreshed when a
+// contact in it is added or heard from, or a lookup for an id in its range completes.
+// Buckets that were never refreshed hold the zero time.
+func (router *RoutingTable) LastRefresh() []time.Time {
+	router.seen.Lock()
+	defer router.seen.Unlock()
+	return slices.Clone(router.seen.refreshed)
+}
+
+// Records that a lookup for target completed, refreshing the bucket target falls in.
+func (router *RoutingTable) lookedUp(target KademliaID) {
+	index, err := router.BucketIndex(target)
+	if err == nil {
+		router.seen.refresh(index)
+	}
+}
+
+// Summary of a node's routing table, see Node.Health.
+type RoutingHealth struct {
+	Contacts     int
+	EmptyBuckets int
+	Stale        int         // contacts not heard from within STALE_CONTACT
+	Occupancy    []int       // contacts per bucket
+	LastRefresh  []time.Time // last refresh of each bucket, zero if it never was
+}
+
+// Returns the size and shape of the node's routing table and how fresh its contents are.
+func (node *Node) Health() RoutingHealth {
+	res := RoutingHealth{
+		Occupancy:   node.BucketOccupancy(),
+		LastRefresh: node.LastRefresh(),
+		Stale:       len(node.StaleContacts(node.config.scaled(STALE_CONTACT))),
+	}
+	for _, occupancy := range res.Occupancy {
+		res.Contacts += occupancy
+		if occupancy == 0 {
+			res.EmptyBuckets++
+		}
+	}
+	return res
+}
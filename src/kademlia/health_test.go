@@ -0,0 +1,85 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestStaleContacts(t *testing.T) {
+	testName := "TestStaleContacts"
+	clock := NewFakeClock(time.Unix(0, 0))
+	router := NewRoutingTable(NewContact([4]byte{10, 0, 0, 1}, KademliaID{}), KEYSPACE, KBUCKETVOLUME)
+	router.seen.setTimebase(clock)
+	near := NewContact([4]byte{10, 0, 0, 2}, KademliaID{0, 1})
+	mid := NewContact([4]byte{10, 0, 0, 3}, KademliaID{1})
+	far := NewContact([4]byte{10, 0, 0, 4}, KademliaID{0x80000000})
+	router.AddContact(far)
+	clock.Advance(time.Second)
+	router.AddContact(mid)
+	router.AddContact(near)
+	clock.Advance(time.Second)
+	// Hearing from a contact again refreshes it even though it is already known.
+	router.AddContact(near)
+	clock.Advance(time.Second)
+
+	contacts := router.Contacts()
+	if len(contacts) != 3 || contacts[0].ID() != near.ID() || contacts[2].ID() != far.ID() {
+		log.Printf("[%s] - expected contacts nearest first, got %v", testName, contacts)
+		t.Fail()
+	}
+	stale := router.StaleContacts(1500 * time.Millisecond)
+	if len(stale) != 2 || stale[0].ID() != far.ID() || stale[1].ID() != mid.ID() {
+		log.Printf("[%s] - expected far then mid to be stale, got %v", testName, stale)
+		t.Fail()
+	}
+	refreshed := router.LastRefresh()
+	farBucket, _ := router.BucketIndex(far.ID())
+	nearBucket, _ := router.BucketIndex(near.ID())
+	if !refreshed[farBucket].Equal(time.Unix(0, 0)) || !refreshed[nearBucket].Equal(time.Unix(2, 0)) {
+		log.Printf("[%s] - unexpected refresh times, far %v, near %v", testName, refreshed[farBucket], refreshed[nearBucket])
+		t.Fail()
+	}
+	router.RemoveContact(far)
+	if stale := router.StaleContacts(1500 * time.Millisecond); len(stale) != 1 {
+		log.Printf("[%s] - removed contact is still reported as stale: %v", testName, stale)
+		t.Fail()
+	}
+}
+
+func TestNodeHealth(t *testing.T) {
+	testName := "TestNodeHealth"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(6, done)
+	<-done
+	defer s.Shutdown()
+
+	health := nodes[0].Health()
+	occupied := 0
+	for _, occupancy := range health.Occupancy {
+		if occupancy > 0 {
+			occupied++
+		}
+	}
+	total := 0
+	for _, occupancy := range health.Occupancy {
+		total += occupancy
+	}
+	if health.Contacts != total || health.Contacts == 0 {
+		log.Printf("[%s] - expected %d contacts, got %d", testName, total, health.Contacts)
+		t.Fail()
+	}
+	if health.EmptyBuckets != KEYSPACE-occupied || health.Stale != 0 {
+		log.Printf("[%s] - unexpected health: %+v", testName, health)
+		t.Fail()
+	}
+	for i, occupancy := range health.Occupancy {
+		if occupancy > 0 && health.LastRefresh[i].IsZero() {
+			log.Printf("[%s] - occupied bucket %d was never refreshed", testName, i)
+			t.Fail()
+		}
+	}
+}
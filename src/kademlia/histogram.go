@@ -0,0 +1,209 @@
+package kademlia
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the request latency buckets, samples above the last bound fall in an overflow bucket.
+var LATENCY_BUCKETS = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// Distribution of request/response round trip times for a single command.
+// Only answered requests are recorded, timeouts are reported through Metrics.RPCSent.
+type LatencyHistogram struct {
+	Counts []int // Counts[i] samples at most LATENCY_BUCKETS[i], the extra last entry counts the overflow
+	Count  int
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+func newLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		Counts: make([]int, len(LATENCY_BUCKETS)+1),
+	}
+}
+
+func (hist *LatencyHistogram) observe(latency time.Duration) {
+	i := sort.Search(len(LATENCY_BUCKETS), func(i int) bool { return latency <= LATENCY_BUCKETS[i] })
+	hist.Counts[i]++
+	hist.Count++
+	hist.Sum += latency
+	hist.Max = max(hist.Max, latency)
+}
+
+func (hist *LatencyHistogram) merge(other LatencyHistogram) {
+	for i, n := range other.Counts {
+		hist.Counts[i] += n
+	}
+	hist.Count += other.Count
+	hist.Sum += other.Sum
+	hist.Max = max(hist.Max, other.Max)
+}
+
+func (hist LatencyHistogram) copy() LatencyHistogram {
+	res := hist
+	res.Counts = append([]int(nil), hist.Counts...)
+	return res
+}
+
+func (hist LatencyHistogram) Mean() time.Duration {
+	if hist.Count == 0 {
+		return 0
+	}
+	return hist.Sum / time.Duration(hist.Count)
+}
+
+// Returns the upper bound of the bucket holding the q-quantile sample, q in [0, 1].
+// Samples in the overflow bucket are reported as the largest latency observed.
+func (hist LatencyHistogram) Quantile(q float64) time.Duration {
+	if hist.Count == 0 {
+		return 0
+	}
+	rank := max(int(q*float64(hist.Count)+0.5), 1)
+	seen := 0
+	for i, n := range hist.Counts {
+		seen += n
+		if seen >= rank {
+			if i == len(LATENCY_BUCKETS) {
+				return hist.Max
+			}
+			return min(LATENCY_BUCKETS[i], hist.Max)
+		}
+	}
+	return hist.Max
+}
+
+func (hist LatencyHistogram) Display() string {
+	return fmt.Sprintf("count: %d mean: %v p50: %v p99: %v max: %v", hist.Count, hist.Mean(), hist.Quantile(0.5), hist.Quantile(0.99), hist.Max)
+}
+
+// Latency histograms keyed by command.
+type latencyTable struct {
+	content map[Command]*LatencyHistogram
+	sync.Mutex
+}
+
+func newLatencyTable() *latencyTable {
+	return &latencyTable{
+		content: make(map[Command]*LatencyHistogram),
+	}
+}
+
+func (table *latencyTable) Observe(cmd Command, latency time.Duration) {
+	table.Lock()
+	defer table.Unlock()
+	hist, ok := table.content[cmd]
+	if !ok {
+		hist = newLatencyHistogram()
+		table.content[cmd] = hist
+	}
+	hist.observe(latency)
+}
+
+func (table *latencyTable) Snapshot() map[Command]LatencyHistogram {
+	table.Lock()
+	defer table.Unlock()
+	res := make(map[Command]LatencyHistogram, len(table.content))
+	for cmd, hist := range table.content {
+		res[cmd] = hist.copy()
+	}
+	return res
+}
+
+// Returns the round trip times of the requests the node has sent that received a response, by command.
+func (node *Node) Latency() map[Command]LatencyHistogram {
+	return node.Network.latency.Snapshot()
+}
+
+// Distribution of the hops node lookups took, see LookupStats.Hops.
+type HopHistogram struct {
+	Counts []int // Counts[h] lookups that took h hops
+	Count  int
+	Sum    int
+	Max    int
+}
+
+func (hist *HopHistogram) observe(hops int) {
+	if hops >= len(hist.Counts) {
+		hist.Counts = append(hist.Counts, make([]int, hops+1-len(hist.Counts))...)
+	}
+	hist.Counts[hops]++
+	hist.Count++
+	hist.Sum += hops
+	hist.Max = max(hist.Max, hops)
+}
+
+func (hist *HopHistogram) merge(other HopHistogram) {
+	if len(other.Counts) > len(hist.Counts) {
+		hist.Counts = append(hist.Counts, make([]int, len(other.Counts)-len(hist.Counts))...)
+	}
+	for i, n := range other.Counts {
+		hist.Counts[i] += n
+	}
+	hist.Count += other.Count
+	hist.Sum += other.Sum
+	hist.Max = max(hist.Max, other.Max)
+}
+
+func (hist HopHistogram) copy() HopHistogram {
+	res := hist
+	res.Counts = append([]int(nil), hist.Counts...)
+	return res
+}
+
+func (hist HopHistogram) Mean() float64 {
+	if hist.Count == 0 {
+		return 0
+	}
+	return float64(hist.Sum) / float64(hist.Count)
+}
+
+func (hist HopHistogram) Display() string {
+	res := fmt.Sprintf("count: %d mean: %.2f max: %d", hist.Count, hist.Mean(), hist.Max)
+	for hops, n := range hist.Counts {
+		if n > 0 {
+			res += fmt.Sprintf(" %d:%d", hops, n)
+		}
+	}
+	return res
+}
+
+// Hops of the lookups a node completed.
+type hopTable struct {
+	hist HopHistogram
+	sync.Mutex
+}
+
+func (table *hopTable) Observe(hops int) {
+	table.Lock()
+	defer table.Unlock()
+	table.hist.observe(hops)
+}
+
+func (table *hopTable) Snapshot() HopHistogram {
+	table.Lock()
+	defer table.Unlock()
+	return table.hist.copy()
+}
+
+// Returns the hops of the lookups the node has completed.
+func (node *Node) LookupHops() HopHistogram {
+	return node.lookupHops.Snapshot()
+}
@@ -0,0 +1,109 @@
+package kademlia
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"main/src/scalegraph"
+)
+
+const (
+	ID_WORDS = scalegraph.ID_WORDS // 32 bit words per id, shared with the ids of the ledger
+	ID_BITS  = ID_WORDS * 32       // key length in bits
+)
+
+// Identifier of nodes, accounts and RPCs in the key space, most significant word first.
+// Distances between ids are themselves ids, compared as unsigned integers.
+type KademliaID [ID_WORDS]uint32
+
+// Hashes data into the key space so content can be addressed by its hash.
+// SHA-1 is used while the key length fits its 160 bit digest and SHA-256 for longer keys.
+func NewKeyFromData(data []byte) KademliaID {
+	var digest []byte
+	if ID_BITS <= sha1.Size*8 {
+		sum := sha1.Sum(data)
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(data)
+		digest = sum[:]
+	}
+	var key KademliaID
+	for i := range key {
+		if (i+1)*4 > len(digest) {
+			break
+		}
+		key[i] = binary.BigEndian.Uint32(digest[i*4:])
+	}
+	return key
+}
+
+func NewKeyFromString(content string) KademliaID {
+	return NewKeyFromData([]byte(content))
+}
+
+// Returns the id as ID_BYTES bytes, most significant byte first, as sent on the wire.
+func (id KademliaID) Bytes() [ID_BYTES]byte {
+	var res [ID_BYTES]byte
+	for i, word := range id {
+		binary.BigEndian.PutUint32(res[i*4:], word)
+	}
+	return res
+}
+
+// Reads an id from the first ID_BYTES bytes of data as written by Bytes, missing bytes are zero.
+// Any input makes an id, so fuzz targets can turn their arguments into ids.
+func IDFromBytes(data []byte) KademliaID {
+	var raw [ID_BYTES]byte
+	copy(raw[:], data)
+	var id KademliaID
+	for i := range id {
+		id[i] = binary.BigEndian.Uint32(raw[i*4:])
+	}
+	return id
+}
+
+// Returns the XOR distance between the two ids.
+func (id KademliaID) Distance(other KademliaID) Distance {
+	var dist Distance
+	for i := range id {
+		dist.words[i] = id[i] ^ other[i]
+	}
+	return dist
+}
+
+// Returns the number of leading bits the two ids have in common.
+func (id KademliaID) Prefix(other KademliaID) int {
+	return id.Distance(other).LeadingZeros()
+}
+
+// Compares the ids as unsigned integers.
+// Returns -1 if id is smaller than other, 0 if they are equal and 1 otherwise.
+func (id KademliaID) Cmp(other KademliaID) int {
+	for i := range id {
+		if id[i] < other[i] {
+			return -1
+		} else if id[i] > other[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Returns true if id is closer to target than other.
+func (id KademliaID) CloserTo(target KademliaID, other KademliaID) bool {
+	return id.Distance(target).Less(other.Distance(target))
+}
+
+func (id KademliaID) IsZero() bool {
+	return id == (KademliaID{})
+}
+
+// Formats the id as hexadecimal, most significant word first.
+func (id KademliaID) String() string {
+	res := ""
+	for _, word := range id {
+		res += fmt.Sprintf("%08x", word)
+	}
+	return res
+}
@@ -0,0 +1,160 @@
+package kademlia
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestKademliaIDPrefix(t *testing.T) {
+	testName := "TestKademliaIDPrefix"
+	a := KademliaID{0, 0, 0, 0, 0}
+	b := KademliaID{0, 1 << 31, 0, 0, 0}
+	if a.Prefix(b) != 32 {
+		log.Printf("[%s] - expected prefix 32, got %d", testName, a.Prefix(b))
+		t.Fail()
+	}
+	if a.Prefix(a) != ID_BITS {
+		log.Printf("[%s] - expected an id to share all %d bits with itself, got %d", testName, ID_BITS, a.Prefix(a))
+		t.Fail()
+	}
+}
+
+func TestKademliaIDCmp(t *testing.T) {
+	testName := "TestKademliaIDCmp"
+	small := KademliaID{0, 0, 0, 0, 7}
+	large := KademliaID{0, 0, 0, 1, 0}
+	if small.Cmp(large) != -1 || large.Cmp(small) != 1 || small.Cmp(small) != 0 {
+		log.Printf("[%s] - wrong ordering of %v and %v", testName, small, large)
+		t.Fail()
+	}
+	target := KademliaID{0, 0, 0, 0, 5}
+	if !small.CloserTo(target, large) || large.CloserTo(target, small) {
+		log.Printf("[%s] - expected %v to be closer to %v than %v", testName, small, target, large)
+		t.Fail()
+	}
+	if small.Distance(target) != (Distance{[ID_WORDS]uint32{0, 0, 0, 0, 2}}) {
+		log.Printf("[%s] - wrong distance %v", testName, small.Distance(target))
+		t.Fail()
+	}
+}
+
+func TestKademliaIDString(t *testing.T) {
+	testName := "TestKademliaIDString"
+	id := KademliaID{0xdeadbeef, 0, 1, 0, 0xff}
+	expected := "deadbeef000000000000000100000000000000ff"
+	if id.String() != expected {
+		log.Printf("[%s] - expected %s, got %s", testName, expected, id.String())
+		t.Fail()
+	}
+}
+
+func TestNewKeyFromData(t *testing.T) {
+	testName := "TestNewKeyFromData"
+	// SHA-1 test vector from FIPS 180-2
+	expected := "a9993e364706816aba3e25717850c26c9cd0d89d"
+	key := NewKeyFromString("abc")
+	if key.String() != expected {
+		log.Printf("[%s] - expected %s, got %s", testName, expected, key.String())
+		t.Fail()
+	}
+	if NewKeyFromData([]byte("abc")) != key {
+		log.Printf("[%s] - string and byte keys differ", testName)
+		t.Fail()
+	}
+	if NewKeyFromString("abd") == key {
+		log.Printf("[%s] - different content produced the same key", testName)
+		t.Fail()
+	}
+}
+
+func FuzzCloserNode(f *testing.F) {
+	testName := "FuzzCloserNode"
+	f.Add(make([]byte, 3*ID_BYTES))
+	f.Add(append(make([]byte, 2*ID_BYTES), 1))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		a, b, target := fuzzIDs(data)
+		ab, ba := CloserNode(a, b, target), CloserNode(b, a, target)
+		if ab && ba {
+			log.Printf("[%s] - %v and %v are both closer to %v than the other", testName, a, b, target)
+			t.FailNow()
+		}
+		if a != b && !ab && !ba {
+			log.Printf("[%s] - neither of %v and %v is closer to %v", testName, a, b, target)
+			t.FailNow()
+		}
+		if ab != (CompareDistance(RelativeDistance(a, target), RelativeDistance(b, target)) < 0) {
+			log.Printf("[%s] - CloserNode disagrees with the distances of %v and %v to %v", testName, a, b, target)
+			t.FailNow()
+		}
+		if CloserNode(b, target, target) || (a != target && !CloserNode(target, a, target)) {
+			log.Printf("[%s] - the target %v is not the closest id to itself", testName, target)
+			t.FailNow()
+		}
+	})
+}
+
+func FuzzDistPrefixLength(f *testing.F) {
+	testName := "FuzzDistPrefixLength"
+	f.Add(make([]byte, 2*ID_BYTES))
+	f.Add(append(make([]byte, ID_BYTES+3), 0x80))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		a, b, _ := fuzzIDs(data)
+		prefix := DistPrefixLength(a, b)
+		if prefix != DistPrefixLength(b, a) {
+			log.Printf("[%s] - prefix of %v and %v is not symmetric", testName, a, b)
+			t.FailNow()
+		}
+		if prefix < 0 || prefix > ID_BITS || (prefix == ID_BITS) != (a == b) {
+			log.Printf("[%s] - prefix %d out of range for %v and %v", testName, prefix, a, b)
+			t.FailNow()
+		}
+		// the ids agree on every bit of the prefix and differ on the bit after it
+		dist := RelativeDistance(a, b).Bytes()
+		for bit := 0; bit < prefix; bit++ {
+			if dist[bit/8]&(0x80>>(bit%8)) != 0 {
+				log.Printf("[%s] - %v and %v differ at bit %d of their prefix %d", testName, a, b, bit, prefix)
+				t.FailNow()
+			}
+		}
+		if prefix < ID_BITS && dist[prefix/8]&(0x80>>(prefix%8)) == 0 {
+			log.Printf("[%s] - %v and %v share more than %d bits", testName, a, b, prefix)
+			t.FailNow()
+		}
+	})
+}
+
+func FuzzIDEncoding(f *testing.F) {
+	testName := "FuzzIDEncoding"
+	f.Add([]byte("00000000000000000000000000000000000000ff"))
+	f.Add([]byte("not an id"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		id := IDFromBytes(data)
+		raw := id.Bytes()
+		if IDFromBytes(raw[:]) != id {
+			log.Printf("[%s] - %v changed in a byte round trip", testName, id)
+			t.FailNow()
+		}
+		text, _ := id.MarshalText()
+		var parsed KademliaID
+		if err := parsed.UnmarshalText(text); err != nil || parsed != id {
+			log.Printf("[%s] - %v changed in a text round trip: %v", testName, id, err)
+			t.FailNow()
+		}
+		// arbitrary text is either rejected or parsed into an id that formats back to it
+		if err := parsed.UnmarshalText(data); err == nil && parsed.String() != strings.ToLower(string(data)) {
+			log.Printf("[%s] - %q parsed as %v", testName, data, parsed)
+			t.FailNow()
+		}
+	})
+}
+
+// Splits fuzzer input into three ids, missing bytes are zero.
+func fuzzIDs(data []byte) (KademliaID, KademliaID, KademliaID) {
+	ids := [3]KademliaID{}
+	for i := range ids {
+		start := min(len(data), i*ID_BYTES)
+		ids[i] = IDFromBytes(data[start:])
+	}
+	return ids[0], ids[1], ids[2]
+}
@@ -0,0 +1,98 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	MAX_PUZZLE    = 32 // hardest crypto puzzle an identity can be required to solve, in leading zero bits
+	NO_IDENTITIES = -1 // puzzle difficulty of a simnet whose node ids are not bound to keys
+)
+
+// A node identity bound to an ed25519 key pair, as proposed by S/Kademlia. The node id is the hash
+// of the public key, so a node can not pick its id without finding a key that hashes to it.
+// On top of that the hash of the id must start with a number of zero bits, a crypto puzzle that
+// makes every usable key costly to find and the search for an id close to a victim costlier still.
+type Identity struct {
+	PublicKey  ed25519.PublicKey
+	PrivateKey ed25519.PrivateKey
+}
+
+// Generates key pairs until one solves the crypto puzzle of the given difficulty, the number of
+// leading zero bits of the hash of its id. Solving a puzzle takes 2^difficulty key pairs on
+// average. Returns an error if difficulty is not between 0 and MAX_PUZZLE.
+func GenerateIdentity(difficulty int) (Identity, error) {
+	return generateIdentity(rand.Reader, difficulty)
+}
+
+func generateIdentity(random io.Reader, difficulty int) (Identity, error) {
+	if difficulty < 0 || difficulty > MAX_PUZZLE {
+		return Identity{}, errors.New(fmt.Sprintf("puzzle difficulty must be between 0 and %d, got %d", MAX_PUZZLE, difficulty))
+	}
+	for {
+		public, private, err := ed25519.GenerateKey(random)
+		if err != nil {
+			return Identity{}, err
+		}
+		if puzzleSolved(keyID(public), difficulty) {
+			return Identity{public, private}, nil
+		}
+	}
+}
+
+// Returns the node id bound to the identity's public key.
+func (identity Identity) ID() KademliaID {
+	return keyID(identity.PublicKey)
+}
+
+func keyID(key ed25519.PublicKey) KademliaID {
+	return NewKeyFromData(key)
+}
+
+func puzzleSolved(id KademliaID, difficulty int) bool {
+	raw := id.Bytes()
+	return KademliaID{}.Prefix(NewKeyFromData(raw[:])) >= difficulty
+}
+
+// Returns an error unless id is bound to key and solves the crypto puzzle of the given difficulty.
+func VerifyIdentity(id KademliaID, key ed25519.PublicKey, difficulty int) error {
+	if len(key) != ed25519.PublicKeySize {
+		return errors.New(fmt.Sprintf("node %v did not present a public key", id))
+	}
+	if keyID(key) != id {
+		return errors.New(fmt.Sprintf("node %v is not bound to its public key", id))
+	}
+	if !puzzleSolved(id, difficulty) {
+		return errors.New(fmt.Sprintf("node %v does not solve a puzzle of difficulty %d", id, difficulty))
+	}
+	return nil
+}
+
+// Binds the node to identity and makes it drop every RPC whose sender does not present a public
+// key bound to its id that solves a crypto puzzle of the given difficulty, see VerifyIdentity.
+// Must be called before the node is started. Returns an error if the identity is not the node's
+// or does not solve the puzzle itself.
+func (net *Network) SetIdentity(identity Identity, difficulty int) error {
+	if identity.ID() != net.nodeID || len(identity.PrivateKey) != ed25519.PrivateKeySize || !bytes.Equal(identity.PrivateKey.Public().(ed25519.PublicKey), identity.PublicKey) {
+		return errors.New(fmt.Sprintf("identity %v does not belong to node %v", identity.ID(), net.nodeID))
+	}
+	if difficulty < 0 || difficulty > MAX_PUZZLE || !puzzleSolved(net.nodeID, difficulty) {
+		return errors.New(fmt.Sprintf("node %v does not solve a puzzle of difficulty %d", net.nodeID, difficulty))
+	}
+	net.identity = &identity
+	net.puzzle = difficulty
+	return nil
+}
+
+// Returns the node's identity, false if the node was not bound to one.
+func (net *Network) Identity() (Identity, bool) {
+	if net.identity == nil {
+		return Identity{}, false
+	}
+	return *net.identity, true
+}
@@ -0,0 +1,106 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestIdentity(t *testing.T) {
+	testName := "TestIdentity"
+	if _, err := GenerateIdentity(MAX_PUZZLE + 1); err == nil {
+		log.Printf("[%s] - accepted a puzzle harder than MAX_PUZZLE", testName)
+		t.Fail()
+	}
+	identity, err := GenerateIdentity(6)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	if identity.ID() != NewKeyFromData(identity.PublicKey) {
+		log.Printf("[%s] - id is not the hash of the public key", testName)
+		t.Fail()
+	}
+	if err := VerifyIdentity(identity.ID(), identity.PublicKey, 6); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	raw := identity.ID().Bytes()
+	if (KademliaID{}).Prefix(NewKeyFromData(raw[:])) < 6 {
+		log.Printf("[%s] - identity does not solve its puzzle", testName)
+		t.Fail()
+	}
+
+	other, _ := GenerateIdentity(0)
+	if VerifyIdentity(identity.ID(), other.PublicKey, 0) == nil {
+		log.Printf("[%s] - verified an id against another node's key", testName)
+		t.Fail()
+	}
+	if VerifyIdentity(identity.ID(), nil, 0) == nil {
+		log.Printf("[%s] - verified an id without a key", testName)
+		t.Fail()
+	}
+	if !puzzleSolved(identity.ID(), MAX_PUZZLE) && VerifyIdentity(identity.ID(), identity.PublicKey, MAX_PUZZLE) == nil {
+		log.Printf("[%s] - verified an unsolved puzzle", testName)
+		t.Fail()
+	}
+
+	net := NewNetwork(identity.ID(), make(chan RPC), make(chan RPC), nil, [4]byte{}, Contact{}, false)
+	if net.SetIdentity(other, 0) == nil {
+		log.Printf("[%s] - bound a network to another node's identity", testName)
+		t.Fail()
+	}
+	if err := net.SetIdentity(identity, 6); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+}
+
+func TestSimnetIdentities(t *testing.T) {
+	testName := "TestSimnetIdentities"
+	if _, err := NewServerWithIdentities(false, 0.0, -2); err == nil {
+		log.Printf("[%s] - accepted a negative puzzle difficulty", testName)
+		t.Fail()
+	}
+	done := make(chan struct{}, 1)
+	s, err := NewServerWithIdentities(false, 0.0, 4)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	for _, n := range s.AllNodePointers() {
+		identity, ok := n.Identity()
+		if !ok || identity.ID() != n.ID() || VerifyIdentity(n.ID(), identity.PublicKey, 4) != nil {
+			log.Printf("[%s] - node %v has no valid identity", testName, n.ID())
+			t.Fail()
+		}
+	}
+
+	rpc := GenerateRPC(nodes[1].IP(), nodes[0].Contact)
+	rpc.Ping()
+	if _, err := nodes[0].Send(rpc); err != nil {
+		log.Printf("[%s] - nodes with identities could not talk: %s", testName, err.Error())
+		t.Fail()
+	}
+
+	// A node with a chosen id has no key to prove it, its RPCs are dropped.
+	chosen := nodes[1].ID()
+	chosen[ID_WORDS-1] ^= 1
+	impostor := s.generateNode(s.queueConfig, DEFAULT_NETWORK, chosen, FULL_NODE)
+	s.routines.Go("impostor", func() { impostor.Listen(impostor) })
+	rpc = GenerateRPC(nodes[1].IP(), impostor.Contact)
+	rpc.Ping()
+	if _, err := impostor.Send(rpc); err == nil {
+		log.Printf("[%s] - a node with a chosen id was answered", testName)
+		t.Fail()
+	}
+	if _, ok := nodes[1].LastSeen(chosen); ok {
+		log.Printf("[%s] - a node with a chosen id was added to a routing table", testName)
+		t.Fail()
+	}
+}
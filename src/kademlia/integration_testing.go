@@ -205,7 +205,7 @@ func IntegrationTestStoreAndFindAccount() bool {
 
 	accID := RandomID()
 	nodes[0].StoreAccount(accID)
-	res, err := nodes[len(nodes)-1].FindAccount(accID)
+	res, _, err := nodes[len(nodes)-1].findAccount(accID, ALL)
 	if verbose {
 		verPrint += fmt.Sprintf("found account %v in nodes:\n", accID)
 		for _, n := range res {
@@ -269,7 +269,7 @@ func IntegrationTestStoreAndFindAccountFromSharingID() bool {
 
 	accID := nodes[0].ID()
 	nodes[0].StoreAccount(accID)
-	res, err := nodes[len(nodes)-1].FindAccount(accID)
+	res, _, err := nodes[len(nodes)-1].findAccount(accID, ALL)
 	if err != nil {
 		log.Println(err.Error())
 		return false
@@ -353,7 +353,7 @@ func IntegrationTestStoreAndFindAccountFromAllNodes() bool {
 		time.Sleep(time.Millisecond * 10)
 		go func(respChan chan result, i int, origin *Node) {
 			//fmt.Printf("\rsearching from node %3d, %10v", i, origin.ID())
-			res, _ := origin.FindAccount(accID)
+			res, _, _ := origin.findAccount(accID, ALL)
 			missing := 0
 			if len(res) > len(nodeCon) {
 				fmt.Printf("wtf\n")
@@ -498,7 +498,7 @@ func IntegrationNodeLookupDataGathering(testSize int) (int, []int) {
 	for i, origin := range nodes {
 		time.Sleep(time.Millisecond * 10)
 		go func(respChan chan result, i int, origin *Node) {
-			res, _ := origin.FindAccount(accID)
+			res, _, _ := origin.findAccount(accID, ALL)
 			missingIndecies := make([]int, 0)
 			for i, con := range res {
 				if !slices.Contains(nodeCon, con) {
@@ -0,0 +1,140 @@
+package kademlia
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	JOIN_CHALLENGE_BYTES = 32          // length of the nonces the entry service challenges joining nodes with
+	JOIN_CHALLENGE_TTL   = 4 * TIMEOUT // time a joining node has to answer its challenge
+)
+
+// The simnet's entry service rejected a node's join, see Simnet.RequireJoinToken.
+type JoinRejected struct {
+	Node   Contact
+	Reason string
+}
+
+func (JoinRejected) event() {}
+
+type joinChallenge struct {
+	nonce   []byte
+	expires time.Time
+}
+
+// Join secret of the simnet's entry service, the challenges it is waiting for answers to and
+// the nodes it turned away.
+type joinGate struct {
+	secret     []byte // nil if joins are not authenticated
+	challenges map[KademliaID]joinChallenge
+	denied     map[KademliaID]bool
+	sync.Mutex
+}
+
+func newJoinGate() *joinGate {
+	return &joinGate{
+		challenges: make(map[KademliaID]joinChallenge),
+		denied:     make(map[KademliaID]bool),
+	}
+}
+
+// Returns the proof that the holder of token answers challenge with when joining as id.
+func joinProof(token []byte, challenge []byte, id KademliaID) []byte {
+	raw := id.Bytes()
+	mac := hmac.New(sha256.New, token)
+	mac.Write(challenge)
+	mac.Write(raw[:])
+	return mac.Sum(nil)
+}
+
+// Sets the token the node presents when the simnet's entry service requires one, see
+// Simnet.RequireJoinToken. Must be called before the node is started.
+func (node *Node) SetJoinToken(token []byte) {
+	node.joinToken = slices.Clone(token)
+}
+
+// Makes the entry service answer ENTER requests only from nodes that hold the secret, to simulate
+// a permissioned network. A joining node is sent a fresh challenge and is handed entry points once
+// it returns the challenge signed with its join token, a wrong answer rejects the join for good.
+// Nodes spawned from now on are given the secret as their token, see SpawnNodeWithToken to spawn
+// an outsider. Nodes that have already joined are not affected. A nil secret lets anyone join again.
+func (simnet *Simnet) RequireJoinToken(secret []byte) {
+	simnet.join.Lock()
+	defer simnet.join.Unlock()
+	simnet.join.secret = slices.Clone(secret)
+	clear(simnet.join.challenges)
+}
+
+// Spawns a node that presents token instead of the simnet's join secret, see RequireJoinToken.
+func (simnet *Simnet) SpawnNodeWithToken(token []byte, done chan KademliaID) *Node {
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	newNode := simnet.generateNode(config, DEFAULT_NETWORK, KademliaID{}, FULL_NODE)
+	newNode.SetJoinToken(token)
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
+	return newNode
+}
+
+func (simnet *Simnet) joinSecret() []byte {
+	simnet.join.Lock()
+	defer simnet.join.Unlock()
+	return simnet.join.secret
+}
+
+// Returns true if the entry service turned the node away.
+func (simnet *Simnet) joinDenied(id KademliaID) bool {
+	simnet.join.Lock()
+	defer simnet.join.Unlock()
+	return simnet.join.denied[id]
+}
+
+// Checks an ENTER request against the join secret. A request without a proof is answered with a
+// challenge, one with a proof that does not match an outstanding challenge is rejected.
+// Returns true if the requester may be handed entry points, otherwise rpc has been turned into
+// the challenge or the rejection.
+func (simnet *Simnet) admit(rpc *RPC) bool {
+	simnet.join.Lock()
+	defer simnet.join.Unlock()
+	gate := simnet.join
+	if gate.secret == nil {
+		return true
+	}
+	id := rpc.sender.ID()
+	now := simnet.timebase.Now()
+	if len(rpc.joinProof) == 0 {
+		for pending, challenge := range gate.challenges {
+			if !challenge.expires.After(now) {
+				delete(gate.challenges, pending)
+			}
+		}
+		nonce := make([]byte, JOIN_CHALLENGE_BYTES)
+		rand.Read(nonce)
+		gate.challenges[id] = joinChallenge{nonce, now.Add(simnet.config.scaled(JOIN_CHALLENGE_TTL))}
+		rpc.joinChallenge = nonce
+		return false
+	}
+
+	challenge, ok := gate.challenges[id]
+	delete(gate.challenges, id)
+	reason := ""
+	if !ok || !challenge.expires.After(now) || !hmac.Equal(challenge.nonce, rpc.joinChallenge) {
+		reason = "unknown challenge"
+	} else if !hmac.Equal(rpc.joinProof, joinProof(gate.secret, challenge.nonce, id)) {
+		reason = "bad proof"
+	}
+	if reason == "" {
+		return true
+	}
+	gate.denied[id] = true
+	rpc.joinRejected = true
+	simnet.stats.recordRejectedJoin()
+	simnet.events.Publish(JoinRejected{rpc.sender, reason})
+	simnet.logger.Debug("rejected join", "node", id, "reason", reason)
+	return false
+}
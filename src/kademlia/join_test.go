@@ -0,0 +1,67 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestJoinToken(t *testing.T) {
+	testName := "TestJoinToken"
+	secret := []byte("permissioned")
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.RequireJoinToken(secret)
+	go s.StartServer()
+	nodes := s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+	events, cancel := s.Events().Subscribe(1 << 12)
+
+	joined := make(chan KademliaID, 2)
+	outsider := s.SpawnNodeWithToken([]byte("guessed"), joined)
+	outsider.SetLogLevel(LOG_SILENT)
+	<-joined
+	member := s.SpawnNodeWithToken(secret, joined)
+	<-joined
+	cancel()
+
+	if len(outsider.AllContacts()) != 0 {
+		log.Printf("[%s] - rejected node learned %d contacts", testName, len(outsider.AllContacts()))
+		t.Fail()
+	}
+	if found := nodes[0].FindNode(outsider.ID()); len(found) > 0 && found[0].ID() == outsider.ID() {
+		log.Printf("[%s] - rejected node is known to the network", testName)
+		t.Fail()
+	}
+	if found := nodes[0].FindNode(member.ID()); len(found) == 0 || found[0].ID() != member.ID() {
+		log.Printf("[%s] - node holding the secret did not join", testName)
+		t.Fail()
+	}
+	if rejected := s.Stats().RejectedJoins; rejected != 1 {
+		log.Printf("[%s] - expected 1 rejected join, got %d", testName, rejected)
+		t.Fail()
+	}
+	rejections := 0
+	for e := range events {
+		if e, ok := e.(JoinRejected); ok {
+			rejections++
+			if e.Node.ID() != outsider.ID() || e.Reason != "bad proof" {
+				log.Printf("[%s] - unexpected rejection of %v: %s", testName, e.Node.ID(), e.Reason)
+				t.Fail()
+			}
+		}
+	}
+	if rejections != 1 {
+		log.Printf("[%s] - expected 1 rejection event, got %d", testName, rejections)
+		t.Fail()
+	}
+
+	// a proof for a challenge that was never issued is rejected
+	rpc := GenerateRPC(member.IP(), member.Contact)
+	rpc.EnterWithProof(make([]byte, JOIN_CHALLENGE_BYTES), joinProof(secret, make([]byte, JOIN_CHALLENGE_BYTES), member.ID()))
+	if s.admit(&rpc) || !rpc.joinRejected {
+		log.Printf("[%s] - admitted a proof for an unknown challenge", testName)
+		t.Fail()
+	}
+}
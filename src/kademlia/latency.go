@@ -0,0 +1,187 @@
+package kademlia
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const LOOKUP_WINDOW = 2 * CONCURRENCY // closest unqueried candidates a latency aware lookup picks its next queries from
+
+// Smoothed round trip time estimates for peers, keyed by IP.
+type rttTable struct {
+	content map[[4]byte]rttEstimate
+	sync.RWMutex
+}
+
+// Round trip time estimate of a peer, kept as in TCP's retransmission timer (RFC 6298).
+type rttEstimate struct {
+	srtt   time.Duration // smoothed round trip time
+	rttvar time.Duration // smoothed mean deviation of the samples from srtt
+}
+
+func newRTTTable() *rttTable {
+	return &rttTable{
+		content: make(map[[4]byte]rttEstimate),
+	}
+}
+
+// Folds a new round trip sample into the estimate for ip, weighting the sample by 1/8 and its
+// deviation from the estimate by 1/4.
+func (rtt *rttTable) Update(ip [4]byte, sample time.Duration) {
+	rtt.Lock()
+	defer rtt.Unlock()
+	prev, ok := rtt.content[ip]
+	if !ok {
+		rtt.content[ip] = rttEstimate{srtt: sample, rttvar: sample / 2}
+		return
+	}
+	deviation := sample - prev.srtt
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	rtt.content[ip] = rttEstimate{
+		srtt:   prev.srtt + (sample-prev.srtt)/8,
+		rttvar: prev.rttvar + (deviation-prev.rttvar)/4,
+	}
+}
+
+// Returns the round trip estimate for ip, or false if the peer has never responded.
+func (rtt *rttTable) RTT(ip [4]byte) (time.Duration, bool) {
+	rtt.RLock()
+	defer rtt.RUnlock()
+	res, ok := rtt.content[ip]
+	return res.srtt, ok
+}
+
+// Returns how long to wait for a response from ip: the smoothed round trip time plus four times
+// its deviation, at least MIN_TIMEOUT and at most ceiling. Peers that have never responded get the
+// ceiling.
+func (rtt *rttTable) Timeout(ip [4]byte, ceiling time.Duration) time.Duration {
+	rtt.RLock()
+	defer rtt.RUnlock()
+	est, ok := rtt.content[ip]
+	if !ok {
+		return ceiling
+	}
+	return min(max(est.srtt+4*est.rttvar, MIN_TIMEOUT), ceiling)
+}
+
+func (rtt *rttTable) Forget(ip [4]byte) {
+	rtt.Lock()
+	defer rtt.Unlock()
+	delete(rtt.content, ip)
+}
+
+// Returns the smoothed round trip time to the peer at ip, or false if it has never responded.
+func (node *Node) RTT(ip [4]byte) (time.Duration, bool) {
+	return node.Network.rtt.RTT(ip)
+}
+
+// Returns how long the node waits for a response to a request sent to the peer at ip, see
+// Config.AdaptiveTimeout.
+func (node *Node) RequestTimeout(ip [4]byte) time.Duration {
+	return node.Network.requestTimeout(ip)
+}
+
+// Returns a copy of contacts ordered by measured round trip time, fastest first.
+// Contacts without a measurement are placed last, and contacts with equal round trip times
+// keep their input order, so a distance sorted input stays distance sorted among ties.
+// Only the order changes, so the result is still the same set of equally valid candidates.
+func (node *Node) OrderByLatency(contacts []Contact) []Contact {
+	res := make([]Contact, 0, len(contacts))
+	res = append(res, contacts...)
+	slices.SortStableFunc(res, func(a Contact, b Contact) int {
+		rttA, okA := node.RTT(a.IP())
+		rttB, okB := node.RTT(b.IP())
+		switch {
+		case okA && !okB:
+			return -1
+		case !okA && okB:
+			return 1
+		case !okA && !okB:
+			return 0
+		case rttA < rttB:
+			return -1
+		case rttA > rttB:
+			return 1
+		}
+		return 0
+	})
+	return res
+}
+
+// A routing table entry with what the node knows of the contact's liveness.
+type ContactState struct {
+	Contact
+	LastSeen time.Time     // last time the contact was heard from
+	RTT      time.Duration // smoothed round trip time, only valid if Measured
+	Measured bool
+}
+
+// Returns the entries of the node's routing table with their liveness, closest to the node first.
+func (node *Node) ContactStates() []ContactState {
+	contacts := node.Contacts()
+	res := make([]ContactState, 0, len(contacts))
+	for _, con := range contacts {
+		state := ContactState{Contact: con}
+		state.LastSeen, _ = node.LastSeen(con.ID())
+		state.RTT, state.Measured = node.RTT(con.IP())
+		res = append(res, state)
+	}
+	return res
+}
+
+// Makes the node's lookups pick each next query among the LOOKUP_WINDOW closest unqueried
+// candidates, preferring recently seen contacts with a low round trip time, instead of always
+// querying the closest candidate. Off by default.
+func (node *Node) SetLatencyAwareLookups(enabled bool) {
+	node.latencyAware.Store(enabled)
+}
+
+// Returns the shortlist in the order a lookup should consider its candidates for querying.
+// Without latency aware lookups that is the shortlist itself, closest first.
+func (node *Node) queryOrder(shortlist []Contact, eligible func(con Contact) bool) []Contact {
+	if !node.latencyAware.Load() {
+		return shortlist
+	}
+	window := make([]Contact, 0, LOOKUP_WINDOW)
+	for _, con := range shortlist {
+		if len(window) == LOOKUP_WINDOW {
+			break
+		}
+		if eligible(con) {
+			window = append(window, con)
+		}
+	}
+	return node.preferLive(window)
+}
+
+// Returns a copy of contacts with the ones seen within STALE_CONTACT and a measured round trip
+// time first, fastest first, then stale ones with a measurement, then the unmeasured ones in
+// their input order.
+func (node *Node) preferLive(contacts []Contact) []Contact {
+	now := node.RoutingTable.seen.now()
+	tier := func(con Contact) (int, time.Duration) {
+		rtt, ok := node.RTT(con.IP())
+		if !ok {
+			return 2, 0
+		}
+		seen, ok := node.LastSeen(con.ID())
+		if !ok || now.Sub(seen) > node.config.scaled(STALE_CONTACT) {
+			return 1, rtt
+		}
+		return 0, rtt
+	}
+	res := slices.Clone(contacts)
+	slices.SortStableFunc(res, func(a Contact, b Contact) int {
+		tierA, rttA := tier(a)
+		tierB, rttB := tier(b)
+		if tierA != tierB {
+			return tierA - tierB
+		}
+		return cmp.Compare(rttA, rttB)
+	})
+	return res
+}
@@ -0,0 +1,184 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestRTTTableUpdate(t *testing.T) {
+	testName := "TestRTTTableUpdate"
+	rtt := newRTTTable()
+	ip := RandomIP()
+	rtt.Update(ip, 80*time.Millisecond)
+	rtt.Update(ip, 160*time.Millisecond)
+	res, ok := rtt.RTT(ip)
+	if !ok || res != 90*time.Millisecond {
+		log.Printf("[%s] - expected smoothed rtt of 90ms, received %v", testName, res)
+		t.Fail()
+	}
+	rtt.Forget(ip)
+	_, ok = rtt.RTT(ip)
+	if ok {
+		log.Printf("[%s] - rtt still known after forget", testName)
+		t.Fail()
+	}
+}
+
+func TestOrderByLatency(t *testing.T) {
+	testName := "TestOrderByLatency"
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC), make(chan RPC), [4]byte{0, 0, 0, 0}, me, false)
+	nodeA := NewContact([4]byte{1, 0, 0, 0}, KademliaID{0, 0, 0, 0, 1})
+	nodeB := NewContact([4]byte{2, 0, 0, 0}, KademliaID{0, 0, 0, 0, 2})
+	nodeC := NewContact([4]byte{3, 0, 0, 0}, KademliaID{0, 0, 0, 0, 3})
+	nodeD := NewContact([4]byte{4, 0, 0, 0}, KademliaID{0, 0, 0, 0, 4})
+	node.Network.rtt.Update(nodeB.IP(), 20*time.Millisecond)
+	node.Network.rtt.Update(nodeD.IP(), 10*time.Millisecond)
+
+	res := node.OrderByLatency([]Contact{nodeA, nodeB, nodeC, nodeD})
+	expected := []Contact{nodeD, nodeB, nodeA, nodeC}
+	for i := range expected {
+		if res[i] != expected[i] {
+			log.Printf("[%s] - incorrect contact at index %d: %s", testName, i, res[i].Display())
+			t.Fail()
+		}
+	}
+}
+
+func TestPreferLive(t *testing.T) {
+	testName := "TestPreferLive"
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC), make(chan RPC), [4]byte{0, 0, 0, 0}, me, false)
+	clock := NewFakeClock(time.Unix(0, 0))
+	node.SetTimebase(clock)
+	stale := NewContact([4]byte{1, 0, 0, 0}, KademliaID{0, 0, 0, 0, 1})
+	unmeasured := NewContact([4]byte{2, 0, 0, 0}, KademliaID{0, 0, 0, 0, 2})
+	slow := NewContact([4]byte{3, 0, 0, 0}, KademliaID{0, 0, 0, 0, 3})
+	fast := NewContact([4]byte{4, 0, 0, 0}, KademliaID{0, 0, 0, 0, 4})
+	node.AddContact(stale)
+	clock.Advance(2 * STALE_CONTACT)
+	for _, con := range []Contact{unmeasured, slow, fast} {
+		node.AddContact(con)
+	}
+	node.Network.rtt.Update(stale.IP(), time.Millisecond)
+	node.Network.rtt.Update(slow.IP(), 20*time.Millisecond)
+	node.Network.rtt.Update(fast.IP(), 10*time.Millisecond)
+
+	res := node.preferLive([]Contact{stale, unmeasured, slow, fast})
+	expected := []Contact{fast, slow, stale, unmeasured}
+	for i := range expected {
+		if res[i].ID() != expected[i].ID() {
+			log.Printf("[%s] - incorrect contact at index %d: %s", testName, i, res[i].Display())
+			t.Fail()
+		}
+	}
+
+	states := node.ContactStates()
+	for _, state := range states {
+		if state.ID() == fast.ID() && (!state.Measured || state.RTT != 10*time.Millisecond || !state.LastSeen.Equal(clock.Now())) {
+			log.Printf("[%s] - unexpected state of the fast contact: %+v", testName, state)
+			t.Fail()
+		}
+		if state.ID() == unmeasured.ID() && state.Measured {
+			log.Printf("[%s] - unmeasured contact has a round trip time", testName)
+			t.Fail()
+		}
+	}
+}
+
+func TestLatencyAwareLookup(t *testing.T) {
+	testName := "TestLatencyAwareLookup"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	from := nodes[0]
+	from.SetLatencyAwareLookups(true)
+	for _, target := range nodes[1:] {
+		found := from.FindNode(target.ID())
+		if len(found) == 0 || found[0].ID() != target.ID() {
+			log.Printf("[%s] - latency aware lookup did not find %v", testName, target.ID())
+			t.Fail()
+		}
+	}
+}
+
+func TestRTTTableTimeout(t *testing.T) {
+	testName := "TestRTTTableTimeout"
+	rtt := newRTTTable()
+	ip := RandomIP()
+	if res := rtt.Timeout(ip, time.Second); res != time.Second {
+		log.Printf("[%s] - expected the ceiling for an unmeasured peer, received %v", testName, res)
+		t.Fail()
+	}
+	// srtt 100ms and rttvar 50ms after the first sample
+	rtt.Update(ip, 100*time.Millisecond)
+	if res := rtt.Timeout(ip, time.Second); res != 300*time.Millisecond {
+		log.Printf("[%s] - expected a timeout of 300ms, received %v", testName, res)
+		t.Fail()
+	}
+	if res := rtt.Timeout(ip, 200*time.Millisecond); res != 200*time.Millisecond {
+		log.Printf("[%s] - timeout exceeds the ceiling: %v", testName, res)
+		t.Fail()
+	}
+	fast := RandomIP()
+	for range 20 {
+		rtt.Update(fast, time.Microsecond)
+	}
+	if res := rtt.Timeout(fast, time.Second); res != MIN_TIMEOUT {
+		log.Printf("[%s] - expected the minimum timeout for a fast peer, received %v", testName, res)
+		t.Fail()
+	}
+}
+
+func TestAdaptiveTimeout(t *testing.T) {
+	testName := "TestAdaptiveTimeout"
+	config := DefaultConfig()
+	config.Timeout = 2 * time.Second
+	config.AdaptiveTimeout = true
+	s, err := NewServerWithConfig(false, 0.0, config)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	s.Silence()
+	done := make(chan struct{}, 1)
+	go s.StartServer()
+	nodes := s.SpawnCluster(2, done)
+	<-done
+	defer s.Shutdown()
+	a, b := nodes[0], nodes[1]
+
+	s.SetLinkPolicy(b.IP(), a.IP(), LinkPolicy{Delay: 10 * time.Millisecond})
+	for range 5 {
+		if !a.Ping(b.IP()) {
+			log.Printf("[%s] - ping over a delayed link timed out", testName)
+			t.FailNow()
+		}
+	}
+	timeout := a.RequestTimeout(b.IP())
+	if timeout < 10*time.Millisecond || timeout >= config.Timeout {
+		log.Printf("[%s] - expected a timeout between the link delay and the ceiling, got %v", testName, timeout)
+		t.Fail()
+	}
+
+	s.SetLinkPolicy(a.IP(), b.IP(), LinkPolicy{Drop: 1.0})
+	start := time.Now()
+	if a.Ping(b.IP()) {
+		log.Printf("[%s] - ping succeeded over a link dropping everything", testName)
+		t.Fail()
+	}
+	if elapsed := time.Since(start); elapsed >= config.Timeout {
+		log.Printf("[%s] - failed ping waited %v, the full timeout", testName, elapsed)
+		t.Fail()
+	}
+	if res := a.RequestTimeout(b.IP()); res != config.Timeout {
+		log.Printf("[%s] - expected the ceiling after a timeout, got %v", testName, res)
+		t.Fail()
+	}
+}
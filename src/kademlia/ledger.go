@@ -0,0 +1,251 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"main/src/scalegraph"
+)
+
+// State of a wallet as held by one of its validators.
+type Wallet struct {
+	ID           KademliaID
+	Balance      uint64
+	Transactions int               // transactions applied to the wallet, including its opening deposit
+	PublicKey    ed25519.PublicKey // key spends must be signed with, nil for wallets accepting unsigned spends
+	Nonce        uint64            // nonce of the last sequenced spend, the next spend must carry Nonce+1
+	Replication  int               // closest nodes the wallet is kept at, zero for the K closest
+}
+
+// The wallets a node validates. Wallets are backed by the node's scalegraph accounts, so
+// transfers applied through APPEND_TRANSACTION are reflected in the ledger.
+type Ledger struct {
+	scale *scalegraph.Scalegraph
+}
+
+func (node *Node) Ledger() *Ledger {
+	return &Ledger{&node.scalegraph}
+}
+
+// Stores a new wallet, opening is the deposit funding it and is skipped if its amount is zero.
+// If key is not nil spends from the wallet must be signed by it. Replication is the number of
+// closest nodes the wallet is kept at, zero for the K closest.
+// Returns an error if the wallet already exists.
+func (ledger *Ledger) Submit(id KademliaID, key ed25519.PublicKey, replication int, opening *scalegraph.Transaction) error {
+	if key != nil && len(key) != ed25519.PublicKeySize {
+		return errors.New(fmt.Sprintf("malformed public key for wallet %v", id))
+	}
+	if replication < 0 {
+		return errors.New(fmt.Sprintf("negative replication for wallet %v", id))
+	}
+	err := ledger.scale.AddAccount(id)
+	if err != nil {
+		return err
+	}
+	acc, _ := ledger.scale.FindAccount(id)
+	if key != nil {
+		acc.SetPublicKey(key)
+	}
+	acc.SetReplication(replication)
+	if opening.Amount() == 0 {
+		return nil
+	}
+	if opening.Sender() != scalegraph.MINT_ACCOUNT || opening.Receiver() != id {
+		ledger.scale.RemoveAccount(id)
+		return errors.New(fmt.Sprintf("opening deposit for wallet %v must be minted to it", id))
+	}
+	err = ledger.scale.ApplyTransaction(id, opening)
+	if err != nil {
+		ledger.scale.RemoveAccount(id)
+	}
+	return err
+}
+
+// Returns the wallet, or an error if the node does not hold it.
+func (ledger *Ledger) Wallet(id KademliaID) (Wallet, error) {
+	acc, err := ledger.scale.FindAccount(id)
+	if err != nil {
+		return Wallet{}, err
+	}
+	return Wallet{id, acc.Balance(), acc.Len(), acc.PublicKey(), acc.Nonce(), acc.Replication()}, nil
+}
+
+// Returns every wallet held by the node.
+func (ledger *Ledger) Wallets() []Wallet {
+	res := make([]Wallet, 0, ledger.scale.StoredAccountCount())
+	for _, id := range ledger.scale.StoredAccounts() {
+		wallet, err := ledger.Wallet(id)
+		if err == nil {
+			res = append(res, wallet)
+		}
+	}
+	return res
+}
+
+// Creates a wallet accepting unsigned spends, see SubmitWalletWithKey.
+func (node *Node) SubmitWallet(id KademliaID, balance uint64) error {
+	return node.SubmitWalletWithKey(id, nil, balance)
+}
+
+// Creates a wallet funded with balance at the K closest nodes of its id. Spends from the wallet
+// must be signed by the private key matching key.
+// Returns an error unless enough of them stored it for the node's write consistency.
+func (node *Node) SubmitWalletWithKey(id KademliaID, key ed25519.PublicKey, balance uint64) error {
+	return node.SubmitWalletWithReplication(id, key, balance, 0)
+}
+
+// Creates a wallet like SubmitWalletWithKey, but at the replication closest nodes of its id instead
+// of the K closest, so that wallets can be kept at fewer nodes than the default. The validators
+// keep the wallet at that many nodes as the topology shifts, see SyncWallets, and reads and
+// transactions count their consistency over that many validators. A replication of zero stores
+// the wallet at the K closest nodes.
+// Returns an error if replication is out of range, or unless enough validators stored the wallet
+// for the node's write consistency.
+func (node *Node) SubmitWalletWithReplication(id KademliaID, key ed25519.PublicKey, balance uint64, replication int) error {
+	if replication < 0 || replication > node.config.Replication {
+		return errors.New(fmt.Sprintf("replication must be between 0 and %d, got %d", node.config.Replication, replication))
+	}
+	opening := scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, balance)
+	validators := node.walletValidators(node.FindNode(id), id, replication)
+	respChan := make(chan bool, len(validators))
+	for _, val := range validators {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.SubmitWallet(id, key, *opening)
+		rpc.replication = replication
+		node.routines.Go("submit wallet", func() {
+			res, err := node.Send(rpc)
+			respChan <- err == nil && res.walletStored
+		})
+	}
+	stored := 0
+	for range validators {
+		if <-respChan {
+			stored++
+		}
+	}
+	required := node.config.WriteConsistency.Required(len(validators))
+	if stored < required {
+		return failure(ErrQuorumNotReached, "wallet %v stored by %d of %d validators, %d required", id, stored, len(validators), required)
+	}
+	return nil
+}
+
+// Returns the wallet as confirmed by the validators at the node's read consistency. At ONE the
+// validators are asked one at a time, in order of latency, until one holds the wallet. At higher
+// levels they are asked in parallel, and the latest state among the first validators to confirm
+// the wallet is returned, the consistency counting over the validators of the wallet's replication
+// once a validator has reported it. Validators missing the wallet or behind the state the read
+// returns, including those the read did not wait for, are asked to catch up in the background.
+// That repair is best-effort and not part of the result, it is only reported by a WalletRepaired
+// event. See ReadWallet for a read that repairs every replica and reports it before it returns.
+// Returns an error if too few validators hold the wallet, or if none does once every validator
+// has answered.
+func (node *Node) ShowWallet(id KademliaID) (Wallet, error) {
+	validators := node.FindNode(id)
+	level := node.config.ReadConsistency
+	if level == ONE {
+		missing := make([]Contact, 0)
+		ordered := node.OrderByLatency(validators)
+		for i, val := range ordered {
+			rpc := GenerateRPC(val.IP(), node.Contact)
+			rpc.ShowWallet(id)
+			res, err := node.Send(rpc)
+			if err == nil && res.walletStored {
+				unasked := ordered[i+1:]
+				node.repairOnRead(id, validators, val, res.wallet, missing, func() []Contact {
+					return node.staleReplicas(id, res.wallet.Transactions, unasked)
+				})
+				return res.wallet, nil
+			}
+			if err == nil {
+				missing = append(missing, val)
+			}
+		}
+		return Wallet{}, errors.New(fmt.Sprintf("did not find wallet: %v", id))
+	}
+
+	type answer struct {
+		validator Contact
+		wallet    Wallet
+		reachable bool
+		found     bool
+	}
+	answers := make(chan answer, len(validators))
+	for _, val := range validators {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.ShowWallet(id)
+		node.routines.Go("show wallet", func() {
+			res, err := node.Send(rpc)
+			answers <- answer{val, res.wallet, err == nil, err == nil && res.walletStored}
+		})
+	}
+	expected := len(validators)
+	required := level.Required(expected)
+	var latest Wallet
+	var source Contact
+	seen := make([]answer, 0, len(validators))
+	found, received := 0, 0
+	for found < required && found+len(validators)-received >= required {
+		a := <-answers
+		received++
+		seen = append(seen, a)
+		if a.found {
+			if found == 0 || a.wallet.Transactions > latest.Transactions {
+				latest, source = a.wallet, a.validator
+			}
+			found++
+			expected = len(node.walletValidators(validators, id, a.wallet.Replication))
+			required = level.Required(expected)
+		}
+	}
+	if found == 0 && received == len(validators) {
+		return Wallet{}, errors.New(fmt.Sprintf("did not find wallet: %v", id))
+	}
+	if found == 0 {
+		return Wallet{}, failure(ErrQuorumNotReached, "%d of %d validators answered without wallet %v, %d required at %s consistency", received, expected, id, required, level)
+	}
+	stale := make([]Contact, 0)
+	for _, a := range seen {
+		if a.reachable && (!a.found || a.wallet.Transactions < latest.Transactions) {
+			stale = append(stale, a.validator)
+		}
+	}
+	pending := len(validators) - received
+	node.repairOnRead(id, validators, source, latest, stale, func() []Contact {
+		late := make([]Contact, 0)
+		for range pending {
+			if a := <-answers; a.reachable && (!a.found || a.wallet.Transactions < latest.Transactions) {
+				late = append(late, a.validator)
+			}
+		}
+		return late
+	})
+	if found < required {
+		return latest, failure(ErrQuorumNotReached, "%d of %d validators hold wallet %v, %d required at %s consistency", found, expected, id, required, level)
+	}
+	return latest, nil
+}
+
+// Response logic for an incoming submit wallet RPC.
+func (node *Node) handleSubmitWallet(rpc *RPC) {
+	var err error
+	if node.Role() == OBSERVER {
+		err = errors.New("observers do not store wallets")
+	} else {
+		err = node.Ledger().Submit(rpc.accountID, rpc.publicKey, rpc.replication, rpc.transaction.Copy())
+	}
+	if err != nil {
+		node.logger.Warn("refused wallet", "rpc", rpc.id, "wallet", rpc.accountID, "err", err)
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.SubmittedWallet(rpc.accountID, err == nil)
+	node.Send(resp)
+}
+
+// Response logic for an incoming show wallet RPC.
+func (node *Node) handleShowWallet(rpc *RPC) {
+	wallet, err := node.Ledger().Wallet(rpc.accountID)
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.ShownWallet(wallet, err == nil)
+	node.Send(resp)
+}
@@ -0,0 +1,89 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+)
+
+func TestLedgerSubmitWallet(t *testing.T) {
+	testName := "TestLedgerSubmitWallet"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	err := nodes[3].SubmitWallet(id, 50)
+	if err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	wallet, err := nodes[17].ShowWallet(id)
+	if err != nil || wallet.ID != id || wallet.Balance != 50 || wallet.Transactions != 1 {
+		log.Printf("[%s] - expected wallet with balance 50, got %+v, %v", testName, wallet, err)
+		t.Fail()
+	}
+
+	holders := 0
+	for _, n := range s.AllNodePointers() {
+		held, err := n.Ledger().Wallet(id)
+		if err == nil {
+			holders++
+			if held.Balance != 50 {
+				log.Printf("[%s] - replica %v holds balance %d", testName, n.IP(), held.Balance)
+				t.Fail()
+			}
+		}
+	}
+	if holders != REPLICATION {
+		log.Printf("[%s] - expected %d replicas, found %d", testName, REPLICATION, holders)
+		t.Fail()
+	}
+
+	receiver := KademliaID(scalegraph.RandomID())
+	if err := nodes[5].SubmitWallet(receiver, 0); err != nil {
+		log.Printf("[%s] - failed to submit empty wallet: %v", testName, err)
+		t.FailNow()
+	}
+	if err := nodes[5].Transfer(id, receiver, 20); err != nil {
+		log.Printf("[%s] - transfer failed: %v", testName, err)
+		t.FailNow()
+	}
+	wallet, _ = nodes[9].ShowWallet(receiver)
+	if wallet.Balance != 20 {
+		log.Printf("[%s] - expected receiver balance 20, got %d", testName, wallet.Balance)
+		t.Fail()
+	}
+}
+
+func TestLedgerRejectsDuplicateWallet(t *testing.T) {
+	testName := "TestLedgerRejectsDuplicateWallet"
+	scale := scalegraph.NewScaleGraph()
+	ledger := Ledger{scale}
+	id := KademliaID(scalegraph.RandomID())
+	if err := ledger.Submit(id, nil, 0, scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 10)); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	if err := ledger.Submit(id, nil, 0, scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 10)); err == nil {
+		log.Printf("[%s] - duplicate wallet was accepted", testName)
+		t.Fail()
+	}
+	other := KademliaID(scalegraph.RandomID())
+	if err := ledger.Submit(other, nil, 0, scalegraph.NewTransfer(id, other, 10)); err == nil {
+		log.Printf("[%s] - wallet funded by another wallet was accepted", testName)
+		t.Fail()
+	}
+	if _, err := ledger.Wallet(other); err == nil {
+		log.Printf("[%s] - refused wallet was kept", testName)
+		t.Fail()
+	}
+	if len(ledger.Wallets()) != 1 {
+		log.Printf("[%s] - expected 1 wallet, got %d", testName, len(ledger.Wallets()))
+		t.Fail()
+	}
+}
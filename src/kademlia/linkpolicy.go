@@ -0,0 +1,63 @@
+package kademlia
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Faults applied to RPCs travelling over a single directed link in the simulated network.
+type LinkPolicy struct {
+	Drop    float32       // probability that a RPC is dropped
+	Corrupt float32       // probability that a RPC is corrupted, see corruptRPC
+	Delay   time.Duration // added latency for every RPC
+}
+
+// Link policies keyed by (sender IP, receiver IP).
+type linkTable struct {
+	content map[[2][4]byte]LinkPolicy
+	sync.RWMutex
+}
+
+func newLinkTable() *linkTable {
+	return &linkTable{
+		content: make(map[[2][4]byte]LinkPolicy),
+	}
+}
+
+// Sets the policy for RPCs sent from the node at ip from to the node at ip to.
+// Links are directed, so asymmetric loss is modelled by giving each direction its own policy.
+func (simnet *Simnet) SetLinkPolicy(from [4]byte, to [4]byte, policy LinkPolicy) {
+	simnet.links.Lock()
+	defer simnet.links.Unlock()
+	simnet.links.content[[2][4]byte{from, to}] = policy
+}
+
+// Removes the policy for the directed link, RPCs on it are only subject to the global drop rate.
+func (simnet *Simnet) ClearLinkPolicy(from [4]byte, to [4]byte) {
+	simnet.links.Lock()
+	defer simnet.links.Unlock()
+	delete(simnet.links.content, [2][4]byte{from, to})
+}
+
+// Returns the policy for the directed link, or false if it has none.
+func (simnet *Simnet) LinkPolicy(from [4]byte, to [4]byte) (LinkPolicy, bool) {
+	simnet.links.RLock()
+	defer simnet.links.RUnlock()
+	policy, ok := simnet.links.content[[2][4]byte{from, to}]
+	return policy, ok
+}
+
+// Corrupts a RPC in one of two ways with equal probability: the command is replaced by a random
+// protocol command, or the sender is zeroed. A sealed RPC has a byte of its ciphertext flipped
+// instead.
+func corruptRPC(rpc *RPC, rng *simnetRand) {
+	if rpc.cmd == SEALED && len(rpc.sealed) > 0 {
+		rpc.sealed = slices.Clone(rpc.sealed)
+		rpc.sealed[rng.intn(len(rpc.sealed))] ^= 0xff
+	} else if rng.intn(2) == 0 {
+		rpc.cmd = cmd(rng.intn(int(LAST_PROTOCOL_CMD) + 1))
+	} else {
+		rpc.sender = Contact{}
+	}
+}
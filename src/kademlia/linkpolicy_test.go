@@ -0,0 +1,98 @@
+package kademlia
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLinkPolicyAsymmetricDrop(t *testing.T) {
+	testName := "TestLinkPolicyAsymmetricDrop"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(2, done)
+	<-done
+	a, b := nodes[0], nodes[1]
+
+	s.SetLinkPolicy(a.IP(), b.IP(), LinkPolicy{Drop: 1.0})
+	if a.Ping(b.IP()) {
+		log.Printf("[%s] - ping succeeded over a link dropping everything", testName)
+		t.Fail()
+	}
+	received := s.Stats().NodeMessages[a.IP()].Received
+	b.Ping(a.IP())
+	if s.Stats().NodeMessages[a.IP()].Received == received {
+		log.Printf("[%s] - requests from %v were dropped, but only the reverse link has a policy", testName, b.IP())
+		t.Fail()
+	}
+
+	s.ClearLinkPolicy(a.IP(), b.IP())
+	if _, ok := s.LinkPolicy(a.IP(), b.IP()); ok {
+		log.Printf("[%s] - policy still set after clear", testName)
+		t.Fail()
+	}
+	if !a.Ping(b.IP()) {
+		log.Printf("[%s] - ping failed after clearing the policy", testName)
+		t.Fail()
+	}
+	s.Shutdown()
+}
+
+func TestLinkPolicyDelay(t *testing.T) {
+	testName := "TestLinkPolicyDelay"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(2, done)
+	<-done
+	a, b := nodes[0], nodes[1]
+
+	delay := 20 * time.Millisecond
+	s.SetLinkPolicy(b.IP(), a.IP(), LinkPolicy{Delay: delay})
+	start := time.Now()
+	if !a.Ping(b.IP()) {
+		log.Printf("[%s] - ping failed", testName)
+		t.Fail()
+	}
+	if time.Since(start) < delay {
+		log.Printf("[%s] - response arrived after %v, expected at least %v", testName, time.Since(start), delay)
+		t.Fail()
+	}
+	s.Shutdown()
+}
+
+func TestLinkPolicyCorrupt(t *testing.T) {
+	testName := "TestLinkPolicyCorrupt"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(3, done)
+	<-done
+	a, b := nodes[0], nodes[1]
+
+	s.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetLinkPolicy(a.IP(), b.IP(), LinkPolicy{Corrupt: 1.0})
+	for i := 0; i < 5; i++ {
+		a.Ping(b.IP())
+	}
+	if s.Stats().Corrupted == 0 {
+		log.Printf("[%s] - no RPCs were corrupted", testName)
+		t.Fail()
+	}
+	s.ClearLinkPolicy(a.IP(), b.IP())
+	if !b.Ping(a.IP()) {
+		log.Printf("[%s] - node stopped answering after receiving corrupted RPCs", testName)
+		t.Fail()
+	}
+	err := s.Shutdown()
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+}
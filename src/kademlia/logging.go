@@ -0,0 +1,115 @@
+package kademlia
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// Level that no record reaches, used to silence a component entirely.
+const LOG_SILENT = slog.Level(1 << 30)
+
+// Filters records below a component's level before passing them on to the shared handler.
+// Every component owns its level, so nodes and components can be tuned independently while
+// writing to the same destination.
+type levelHandler struct {
+	level   *slog.LevelVar
+	handler slog.Handler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.handler.Enabled(ctx, level)
+}
+
+func (h levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.level, h.handler.WithAttrs(attrs)}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.level, h.handler.WithGroup(name)}
+}
+
+// Base logger used until a component is given another one, it lets every level through and
+// leaves filtering to the component levels.
+func defaultLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// Returns a logger writing to base that filters by level and tags every record with attrs.
+func componentLogger(base *slog.Logger, level *slog.LevelVar, attrs ...any) *slog.Logger {
+	return slog.New(levelHandler{level, base.Handler()}).With(attrs...)
+}
+
+// Returns the level matching the legacy debug flag.
+func debugLevel(debug bool) slog.Level {
+	if debug {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+func newLevel(level slog.Level) *slog.LevelVar {
+	res := new(slog.LevelVar)
+	res.Set(level)
+	return res
+}
+
+// Sets the destination for the network's logs, records are tagged with the node id.
+func (net *Network) SetLogger(base *slog.Logger) {
+	net.logger = componentLogger(base, net.logLevel, "component", "network", "node", net.nodeID)
+}
+
+// Sets the minimum level logged by the network component.
+func (net *Network) SetLogLevel(level slog.Level) {
+	net.logLevel.Set(level)
+}
+
+// Sets the destination for all of the node's logs, records are tagged with the node id
+// and the component that wrote them.
+func (node *Node) SetLogger(base *slog.Logger) {
+	node.logger = componentLogger(base, node.logLevel, "component", "node", "node", node.ID())
+	node.Network.SetLogger(base)
+}
+
+// Sets the minimum level logged by the node component, see Network.SetLogLevel for the network component.
+func (node *Node) SetLogLevel(level slog.Level) {
+	node.logLevel.Set(level)
+}
+
+// Silences every component of the node.
+func (node *Node) Silence() {
+	node.SetLogLevel(LOG_SILENT)
+	node.Network.SetLogLevel(LOG_SILENT)
+}
+
+// Sets the destination for the simnet's logs and for every node it has spawned or will spawn.
+func (simnet *Simnet) SetLogger(base *slog.Logger) {
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.logBase = base
+	simnet.logger = componentLogger(base, simnet.logLevel, "component", "simnet")
+	for _, n := range simnet.spawned.nodePointer {
+		n.SetLogger(base)
+	}
+}
+
+// Sets the minimum level logged by the simnet component.
+func (simnet *Simnet) SetLogLevel(level slog.Level) {
+	simnet.logLevel.Set(level)
+}
+
+// Silences the simnet and every node it has spawned or will spawn, the master node included.
+func (simnet *Simnet) Silence() {
+	simnet.SetLogLevel(LOG_SILENT)
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.silenced = true
+	simnet.masterNode.Silence()
+	for _, n := range simnet.spawned.nodePointer {
+		n.Silence()
+	}
+}
@@ -0,0 +1,57 @@
+package kademlia
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"testing"
+)
+
+func TestNodeLoggerAttributes(t *testing.T) {
+	testName := "TestNodeLoggerAttributes"
+	var buf bytes.Buffer
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC), make(chan RPC), [4]byte{0, 0, 0, 0}, me, false)
+	node.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	node.logger.Info("test record")
+
+	record := make(map[string]any)
+	err := json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		log.Printf("[%s] - record is not json: %s", testName, buf.String())
+		t.FailNow()
+	}
+	if record["component"] != "node" || record["node"] == nil {
+		log.Printf("[%s] - record is missing node attributes: %s", testName, buf.String())
+		t.Fail()
+	}
+}
+
+func TestNodeLogLevels(t *testing.T) {
+	testName := "TestNodeLogLevels"
+	var buf bytes.Buffer
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC), make(chan RPC), [4]byte{0, 0, 0, 0}, me, false)
+	node.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	node.logger.Debug("hidden by default")
+	if buf.Len() != 0 {
+		log.Printf("[%s] - debug record logged at info level: %s", testName, buf.String())
+		t.Fail()
+	}
+	node.Debug(true)
+	node.Network.logger.Debug("shown in debug mode")
+	if buf.Len() == 0 {
+		log.Printf("[%s] - debug record not logged in debug mode", testName)
+		t.Fail()
+	}
+	buf.Reset()
+	node.Silence()
+	node.logger.Error("silenced")
+	node.Network.logger.Error("silenced")
+	if buf.Len() != 0 {
+		log.Printf("[%s] - silenced node logged: %s", testName, buf.String())
+		t.Fail()
+	}
+}
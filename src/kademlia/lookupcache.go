@@ -0,0 +1,137 @@
+package kademlia
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Counters of a node's lookup cache.
+type LookupCacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64 // results dropped because one of their contacts left the routing table
+	Evictions     uint64 // results dropped to stay within the capacity
+}
+
+// Results of recent node lookups, so that repeated lookups of the same target, such as a wallet
+// during a burst of transactions, are answered without querying the network again.
+// A zero capacity disables the cache.
+type lookupCache struct {
+	capacity int
+	ttl      time.Duration
+	entries  map[KademliaID]lookupCacheEntry
+	order    []KademliaID // least recently used first
+	stats    LookupCacheStats
+	sync.Mutex
+}
+
+type lookupCacheEntry struct {
+	contacts []Contact
+	expires  time.Time
+}
+
+func newLookupCache() *lookupCache {
+	return &lookupCache{
+		entries: make(map[KademliaID]lookupCacheEntry),
+	}
+}
+
+// Makes FindNode answer lookups of a target it looked up within ttl from a cache of up to capacity
+// results, evicting the least recently used ones. A cached result is dropped as soon as one of its
+// contacts is removed from the routing table. A zero capacity, the default, disables the cache.
+// Returns an error if capacity is negative or ttl is not positive while the cache is enabled.
+func (node *Node) SetLookupCache(capacity int, ttl time.Duration) error {
+	if capacity < 0 {
+		return errors.New("lookup cache capacity must not be negative")
+	}
+	if capacity > 0 && ttl <= 0 {
+		return errors.New("lookup cache ttl must be positive")
+	}
+	cache := node.lookupCache
+	cache.Lock()
+	defer cache.Unlock()
+	cache.capacity = capacity
+	cache.ttl = ttl
+	for len(cache.order) > capacity {
+		cache.remove(cache.order[0])
+		cache.stats.Evictions++
+	}
+	return nil
+}
+
+// Returns the counters of the node's lookup cache.
+func (node *Node) LookupCacheStats() LookupCacheStats {
+	node.lookupCache.Lock()
+	defer node.lookupCache.Unlock()
+	return node.lookupCache.stats
+}
+
+// Returns a copy of the cached result for target, or false if it is not cached or has expired.
+// Lookups are only counted as hits or misses while the cache is enabled.
+func (cache *lookupCache) get(target KademliaID, now time.Time) ([]Contact, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.capacity == 0 {
+		return nil, false
+	}
+	entry, ok := cache.entries[target]
+	if !ok || now.After(entry.expires) {
+		if ok {
+			cache.remove(target)
+		}
+		cache.stats.Misses++
+		return nil, false
+	}
+	cache.touch(target)
+	cache.stats.Hits++
+	return slices.Clone(entry.contacts), true
+}
+
+// Caches the result of a lookup of target, evicting the least recently used results beyond the
+// capacity. Empty results are not cached, they are what a lookup returns when it failed.
+func (cache *lookupCache) put(target KademliaID, contacts []Contact, now time.Time) {
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.capacity == 0 || len(contacts) == 0 {
+		return
+	}
+	cache.remove(target)
+	cache.entries[target] = lookupCacheEntry{slices.Clone(contacts), now.Add(cache.ttl)}
+	cache.order = append(cache.order, target)
+	for len(cache.order) > cache.capacity {
+		cache.remove(cache.order[0])
+		cache.stats.Evictions++
+	}
+}
+
+// Drops every cached result that contains the contact.
+func (cache *lookupCache) forget(id KademliaID) {
+	cache.Lock()
+	defer cache.Unlock()
+	for target, entry := range cache.entries {
+		if slices.ContainsFunc(entry.contacts, func(con Contact) bool { return con.ID() == id }) {
+			cache.remove(target)
+			cache.stats.Invalidations++
+		}
+	}
+}
+
+// Moves the target to the most recently used end, the cache must be locked.
+func (cache *lookupCache) touch(target KademliaID) {
+	if i := slices.Index(cache.order, target); i != -1 {
+		cache.order = append(slices.Delete(cache.order, i, i+1), target)
+	}
+}
+
+// Drops the cached result for target, the cache must be locked.
+func (cache *lookupCache) remove(target KademliaID) {
+	if _, ok := cache.entries[target]; !ok {
+		return
+	}
+	delete(cache.entries, target)
+	if i := slices.Index(cache.order, target); i != -1 {
+		cache.order = slices.Delete(cache.order, i, i+1)
+	}
+}
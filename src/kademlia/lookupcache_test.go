@@ -0,0 +1,82 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestLookupCacheEviction(t *testing.T) {
+	testName := "TestLookupCacheEviction"
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC), make(chan RPC), [4]byte{0, 0, 0, 0}, me, false)
+	if err := node.SetLookupCache(2, time.Minute); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	now := time.Now()
+	targetA, targetB, targetC := RandomID(), RandomID(), RandomID()
+	conA, conB, conC := NewRandomContact(), NewRandomContact(), NewRandomContact()
+	node.lookupCache.put(targetA, []Contact{conA}, now)
+	node.lookupCache.put(targetB, []Contact{conB}, now)
+	// targetA becomes the most recently used, so targetB is evicted for targetC
+	node.lookupCache.get(targetA, now)
+	node.lookupCache.put(targetC, []Contact{conC}, now)
+	if _, ok := node.lookupCache.get(targetB, now); ok {
+		log.Printf("[%s] - least recently used result was not evicted", testName)
+		t.Fail()
+	}
+	if res, ok := node.lookupCache.get(targetA, now); !ok || res[0] != conA {
+		log.Printf("[%s] - recently used result was evicted", testName)
+		t.Fail()
+	}
+	if _, ok := node.lookupCache.get(targetC, now.Add(2*time.Minute)); ok {
+		log.Printf("[%s] - expired result was served", testName)
+		t.Fail()
+	}
+	node.lookupCache.forget(conA.ID())
+	if _, ok := node.lookupCache.get(targetA, now); ok {
+		log.Printf("[%s] - result with a removed contact was served", testName)
+		t.Fail()
+	}
+	stats := node.LookupCacheStats()
+	if stats.Hits != 2 || stats.Misses != 3 || stats.Evictions != 1 || stats.Invalidations != 1 {
+		log.Printf("[%s] - unexpected counters: %+v", testName, stats)
+		t.Fail()
+	}
+	if err := node.SetLookupCache(4, 0); err == nil {
+		log.Printf("[%s] - accepted a cache without a ttl", testName)
+		t.Fail()
+	}
+}
+
+func TestFindNodeCached(t *testing.T) {
+	testName := "TestFindNodeCached"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	if err := nodes[0].SetLookupCache(16, time.Minute); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	target := nodes[5].ID()
+	first, stats := nodes[0].FindNodeWithStats(target)
+	if len(first) == 0 || stats.RPCs == 0 {
+		log.Printf("[%s] - first lookup did not query the network: %+v", testName, stats)
+		t.FailNow()
+	}
+	second, stats := nodes[0].FindNodeWithStats(target)
+	if stats.RPCs != 0 || len(second) != len(first) || second[0] != first[0] {
+		log.Printf("[%s] - repeated lookup was not served from the cache: %+v", testName, stats)
+		t.Fail()
+	}
+	if cached := nodes[0].LookupCacheStats(); cached.Hits != 1 || cached.Misses != 1 {
+		log.Printf("[%s] - unexpected counters: %+v", testName, cached)
+		t.Fail()
+	}
+}
@@ -0,0 +1,236 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	MAILBOX_TTL          = 120 * time.Second // lifetime of a stored message unless the sender asks for less
+	MAILBOX_MAX_MESSAGES = 64                // messages held per recipient by a single node
+	MAILBOX_MAX_BYTES    = 64 * 1024         // payload bytes held per recipient by a single node
+)
+
+// A message for a node or account that may be offline.
+// Messages are stored at the K closest nodes of the recipient until it collects them or they expire.
+type Message struct {
+	ID        KademliaID
+	Sender    KademliaID
+	Recipient KademliaID
+	Payload   []byte
+	Expires   time.Time
+}
+
+// Orders messages by expiry, messages expiring together are ordered by id.
+func compareMessages(msgA Message, msgB Message) int {
+	res := msgA.Expires.Compare(msgB.Expires)
+	if res != 0 {
+		return res
+	}
+	return msgA.ID.Cmp(msgB.ID)
+}
+
+// Messages held on behalf of other recipients, and messages received by the node itself.
+type mailbox struct {
+	stored   map[KademliaID][]Message
+	received []Message
+	sync.Mutex
+}
+
+func newMailbox() *mailbox {
+	return &mailbox{
+		stored:   make(map[KademliaID][]Message),
+		received: make([]Message, 0),
+	}
+}
+
+// Holds the message for its recipient, expiry is judged against now.
+// Returns an error if the message has expired or the recipient's messages would exceed the caps.
+func (box *mailbox) store(msg Message, now time.Time) error {
+	box.Lock()
+	defer box.Unlock()
+	if !msg.Expires.After(now) {
+		return errors.New(fmt.Sprintf("message %v has expired", msg.ID))
+	}
+	held := box.unexpired(msg.Recipient, now)
+	size := len(msg.Payload)
+	for _, m := range held {
+		if m.ID == msg.ID {
+			return nil
+		}
+		size += len(m.Payload)
+	}
+	if len(held) >= MAILBOX_MAX_MESSAGES || size > MAILBOX_MAX_BYTES {
+		return errors.New(fmt.Sprintf("mailbox for %v is full", msg.Recipient))
+	}
+	box.stored[msg.Recipient] = append(held, msg)
+	return nil
+}
+
+// Removes and returns the unexpired messages held for recipient.
+func (box *mailbox) take(recipient KademliaID, now time.Time) []Message {
+	box.Lock()
+	defer box.Unlock()
+	held := box.unexpired(recipient, now)
+	delete(box.stored, recipient)
+	return held
+}
+
+// Drops the expired messages held for recipient and returns the rest, the caller must hold the lock.
+func (box *mailbox) unexpired(recipient KademliaID, now time.Time) []Message {
+	held := box.stored[recipient]
+	res := held[:0]
+	for _, m := range held {
+		if m.Expires.After(now) {
+			res = append(res, m)
+		}
+	}
+	if len(res) == 0 {
+		delete(box.stored, recipient)
+	}
+	return res
+}
+
+// Adds messages addressed to the node, skipping any it has already received.
+func (box *mailbox) receive(msgs ...Message) {
+	box.Lock()
+	defer box.Unlock()
+	for _, msg := range msgs {
+		duplicate := false
+		for _, m := range box.received {
+			if m.ID == msg.ID {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			box.received = append(box.received, msg)
+		}
+	}
+}
+
+// Sends a message to recipient, which may be a node or an account.
+// If the recipient is a node that answers, the message is delivered directly, otherwise it is
+// stored at the recipient's K closest nodes for at most ttl. Returns an error if no node took it.
+func (node *Node) SendMessage(recipient KademliaID, payload []byte, ttl time.Duration) error {
+	if ttl <= 0 || ttl > MAILBOX_TTL {
+		ttl = MAILBOX_TTL
+	}
+	msg := Message{
+		ID:        RandomID(),
+		Sender:    node.ID(),
+		Recipient: recipient,
+		Payload:   payload,
+		Expires:   node.Now().Add(ttl),
+	}
+	holders := node.FindNode(recipient)
+	for _, con := range holders {
+		if con.ID() == recipient && node.storeMessage(con, msg) {
+			return nil
+		}
+	}
+	respChan := make(chan bool, len(holders))
+	for _, con := range holders {
+		node.routines.Go("store message", func() { respChan <- node.storeMessage(con, msg) })
+	}
+	stored := 0
+	for range holders {
+		if <-respChan {
+			stored++
+		}
+	}
+	if stored == 0 {
+		return errors.New(fmt.Sprintf("no node stored message for %v", recipient))
+	}
+	return nil
+}
+
+func (node *Node) storeMessage(con Contact, msg Message) bool {
+	rpc := GenerateRPC(con.IP(), node.Contact)
+	rpc.StoreMessage(msg)
+	res, err := node.Send(rpc)
+	return err == nil && res.messageStored
+}
+
+// Collects the messages held for the node by its K closest nodes, the holders discard them.
+func (node *Node) FetchMessages() []Message {
+	holders := node.FindNode(node.ID())
+	respChan := make(chan []Message, len(holders))
+	for _, con := range holders {
+		rpc := GenerateRPC(con.IP(), node.Contact)
+		rpc.FetchMessages(node.ID())
+		node.routines.Go("fetch messages", func() {
+			resp, err := node.Send(rpc)
+			if err != nil {
+				respChan <- nil
+				return
+			}
+			respChan <- resp.messages
+		})
+	}
+	res := make([]Message, 0)
+	seen := make(map[KademliaID]bool)
+	for range holders {
+		for _, msg := range <-respChan {
+			if !seen[msg.ID] {
+				seen[msg.ID] = true
+				res = append(res, msg)
+			}
+		}
+	}
+	// Holders answer in any order, so order by expiry and then id.
+	slices.SortFunc(res, compareMessages)
+	return res
+}
+
+// Collects the messages stored for the node while it was unreachable.
+func (node *Node) collectMessages() {
+	node.mailbox.receive(node.FetchMessages()...)
+}
+
+// Returns and clears the messages the node has received.
+func (node *Node) ReceivedMessages() []Message {
+	node.mailbox.Lock()
+	defer node.mailbox.Unlock()
+	res := node.mailbox.received
+	node.mailbox.received = make([]Message, 0)
+	return res
+}
+
+// Response logic for an incoming store message RPC.
+// Messages addressed to the node are received, others are held for their recipient.
+func (node *Node) handleStoreMessage(rpc *RPC) {
+	var err error
+	if rpc.message.Recipient == node.ID() {
+		node.mailbox.receive(rpc.message)
+	} else if node.Role() == OBSERVER {
+		err = errors.New("observers do not store messages")
+	} else {
+		err = node.mailbox.store(rpc.message, node.Now())
+	}
+	if err != nil {
+		node.logger.Debug("refused to store message", "rpc", rpc.id, "recipient", rpc.message.Recipient, "err", err)
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.StoredMessage(rpc.message.ID, err == nil)
+	node.Send(resp)
+}
+
+// Response logic for an incoming fetch messages RPC.
+// Messages are only handed to their recipient, a request from any other node is answered with
+// none and leaves the messages held. With identities enabled the sender's id is proven by the
+// RPC's signature before the request gets here.
+func (node *Node) handleFetchMessages(rpc *RPC) {
+	messages := make([]Message, 0)
+	if rpc.sender.ID() == rpc.accountID {
+		messages = node.mailbox.take(rpc.accountID, node.Now())
+	} else {
+		node.logger.Debug("refused to hand over messages to another node", "rpc", rpc.id, "recipient", rpc.accountID, "requester", rpc.sender.ID())
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.FetchedMessages(rpc.accountID, messages)
+	node.Send(resp)
+}
@@ -0,0 +1,105 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestMailboxCaps(t *testing.T) {
+	testName := "TestMailboxCaps"
+	box := newMailbox()
+	recipient := RandomID()
+	for i := range MAILBOX_MAX_MESSAGES + 1 {
+		err := box.store(Message{ID: RandomID(), Recipient: recipient, Expires: time.Now().Add(time.Minute)}, time.Now())
+		if (err == nil) != (i < MAILBOX_MAX_MESSAGES) {
+			log.Printf("[%s] - unexpected store result for message %d: %v", testName, i, err)
+			t.Fail()
+		}
+	}
+	other := RandomID()
+	if box.store(Message{ID: RandomID(), Recipient: other, Payload: make([]byte, MAILBOX_MAX_BYTES+1), Expires: time.Now().Add(time.Minute)}, time.Now()) == nil {
+		log.Printf("[%s] - stored a payload above the byte cap", testName)
+		t.Fail()
+	}
+	if len(box.take(recipient, time.Now())) != MAILBOX_MAX_MESSAGES || len(box.take(recipient, time.Now())) != 0 {
+		log.Printf("[%s] - take should return every held message once", testName)
+		t.Fail()
+	}
+}
+
+func TestMailboxExpiry(t *testing.T) {
+	testName := "TestMailboxExpiry"
+	box := newMailbox()
+	recipient := RandomID()
+	box.store(Message{ID: RandomID(), Recipient: recipient, Expires: time.Now().Add(time.Millisecond)}, time.Now())
+	box.store(Message{ID: RandomID(), Recipient: recipient, Expires: time.Now().Add(time.Minute)}, time.Now())
+	time.Sleep(5 * time.Millisecond)
+	if len(box.take(recipient, time.Now())) != 1 {
+		log.Printf("[%s] - expected only the unexpired message", testName)
+		t.Fail()
+	}
+}
+
+func TestStoreAndForward(t *testing.T) {
+	testName := "TestStoreAndForward"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(8, done)
+	<-done
+	sender, online, offline := nodes[0], nodes[1], nodes[2]
+
+	err := sender.SendMessage(online.ID(), []byte("direct"), 0)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	received := online.ReceivedMessages()
+	if len(received) != 1 || string(received[0].Payload) != "direct" {
+		log.Printf("[%s] - expected direct delivery, got %d messages", testName, len(received))
+		t.Fail()
+	}
+
+	id := offline.ID()
+	s.ShutdownNode(offline)
+	err = sender.SendMessage(id, []byte("stored"), time.Minute)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+
+	// another node asking the holders for the recipient's messages gets none of them
+	for _, con := range sender.FindNode(id) {
+		rpc := GenerateRPC(con.IP(), sender.Contact)
+		rpc.FetchMessages(id)
+		if res, err := sender.Send(rpc); err == nil && len(res.messages) != 0 {
+			log.Printf("[%s] - %v handed the recipient's messages to another node", testName, con.IP())
+			t.Fail()
+		}
+	}
+
+	joined := make(chan KademliaID, 1)
+	rejoined, err := s.SpawnNodeWithID(id, joined)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	<-joined
+	deadline := time.Now().Add(5 * time.Second)
+	received = rejoined.ReceivedMessages()
+	for len(received) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		received = rejoined.ReceivedMessages()
+	}
+	if len(received) != 1 || string(received[0].Payload) != "stored" {
+		log.Printf("[%s] - expected the stored message after rejoining, got %d messages", testName, len(received))
+		t.Fail()
+	}
+	if len(rejoined.FetchMessages()) != 0 {
+		log.Printf("[%s] - holders kept the message after it was collected", testName)
+		t.Fail()
+	}
+	s.Shutdown()
+}
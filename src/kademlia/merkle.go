@@ -0,0 +1,213 @@
+package kademlia
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const MERKLE_DEPTH = 8 // levels below the root of a wallet tree, its leaves split wallets on the first MERKLE_DEPTH bits of their id
+
+// A subtree of a wallet tree and its digest, see walletTree.
+type merkleNode struct {
+	Depth int
+	Index int // position among the 1<<Depth subtrees at Depth, leftmost first
+	Hash  [sha256.Size]byte
+}
+
+// Merkle tree over a set of wallets. Each leaf covers the wallets whose ids share their first
+// MERKLE_DEPTH bits, so the trees of two validators line up whatever wallets they hold and differ
+// only along the paths to the leaves whose wallets differ. Empty subtrees hash to zero.
+type walletTree struct {
+	levels [][][sha256.Size]byte // levels[d][i] is the digest of subtree i at depth d
+	leaves [][]KademliaID        // wallets covered by each leaf, in id order
+}
+
+func leafIndex(id KademliaID) int {
+	return int(id[0] >> (32 - MERKLE_DEPTH))
+}
+
+// Builds the tree over the held wallets for which include returns true. Leaves hash the digests
+// of their wallets, see scalegraph.Account.Digest, and inner nodes the digests of their children.
+func (ledger *Ledger) walletTree(include func(KademliaID) bool) *walletTree {
+	tree := &walletTree{
+		levels: make([][][sha256.Size]byte, MERKLE_DEPTH+1),
+		leaves: make([][]KademliaID, 1<<MERKLE_DEPTH),
+	}
+	for depth := range tree.levels {
+		tree.levels[depth] = make([][sha256.Size]byte, 1<<depth)
+	}
+	leafData := make([][]byte, 1<<MERKLE_DEPTH)
+	for _, id := range ledger.scale.StoredAccounts() {
+		if !include(id) {
+			continue
+		}
+		acc, err := ledger.scale.FindAccount(id)
+		if err != nil {
+			continue
+		}
+		i := leafIndex(id)
+		digest := acc.Digest(nil)
+		leafData[i] = append(leafData[i], digest[:]...)
+		tree.leaves[i] = append(tree.leaves[i], id)
+	}
+	for i, data := range leafData {
+		if len(data) > 0 {
+			tree.levels[MERKLE_DEPTH][i] = sha256.Sum256(data)
+		}
+	}
+	for depth := MERKLE_DEPTH - 1; depth >= 0; depth-- {
+		for i := range tree.levels[depth] {
+			left, right := tree.levels[depth+1][2*i], tree.levels[depth+1][2*i+1]
+			if left != ([sha256.Size]byte{}) || right != ([sha256.Size]byte{}) {
+				tree.levels[depth][i] = sha256.Sum256(append(left[:], right[:]...))
+			}
+		}
+	}
+	return tree
+}
+
+// Returns true if the position of n exists in a wallet tree.
+func (n merkleNode) valid() bool {
+	return n.Depth >= 0 && n.Depth <= MERKLE_DEPTH && n.Index >= 0 && n.Index < 1<<n.Depth
+}
+
+func (tree *walletTree) node(depth int, index int) merkleNode {
+	return merkleNode{depth, index, tree.levels[depth][index]}
+}
+
+func (tree *walletTree) root() merkleNode {
+	return tree.node(0, 0)
+}
+
+// Returns the two subtrees of n, n must not be a leaf.
+func (tree *walletTree) children(n merkleNode) []merkleNode {
+	return []merkleNode{tree.node(n.Depth+1, 2*n.Index), tree.node(n.Depth+1, 2*n.Index+1)}
+}
+
+// Returns the root digest of the tree over every wallet the node holds. Two validators holding
+// the same wallets in the same states have the same root.
+func (ledger *Ledger) RootDigest() [sha256.Size]byte {
+	return ledger.walletTree(func(KademliaID) bool { return true }).root().Hash
+}
+
+// Outcome of reconciling the wallets a node shares with a co-validator, see Node.Reconcile.
+type Reconciliation struct {
+	Peer     Contact
+	Rounds   int // RECONCILE requests sent, at most one per level of the tree
+	Leaves   int // leaves of the tree whose digests differed
+	Received int // wallet states the peer sent for the differing leaves
+	Repaired int // wallets that were added or brought up to date
+}
+
+// Returns true if the node and the peer disagreed on any of the wallets they share.
+func (rec Reconciliation) Divergent() bool {
+	return rec.Leaves > 0
+}
+
+func (rec Reconciliation) Display() string {
+	return fmt.Sprintf("peer: %v rounds: %d leaves: %d received: %d repaired: %d\n",
+		rec.Peer.IP(), rec.Rounds, rec.Leaves, rec.Received, rec.Repaired)
+}
+
+// Set a RPC as a request to compare the subtrees of the receiver's wallet tree against the
+// sender's digests of them, see Node.Reconcile.
+func (rpc *RPC) Reconcile(nodes []merkleNode) {
+	rpc.cmd = RECONCILE
+	rpc.merkleNodes = nodes
+}
+
+// Set a RPC as the answer to a reconcile request, nodes are the children of the inner subtrees
+// that differed and states the wallets of the leaves that differed.
+func (rpc *RPC) Reconciled(nodes []merkleNode, states []walletState) {
+	rpc.cmd = RECONCILED
+	rpc.merkleNodes = nodes
+	rpc.walletStates = states
+}
+
+// Brings the node's copies of the wallets it shares with peer up to date with the peer's, without
+// sending the full state of both. The two compare the roots of their trees over the wallets both
+// should replicate, and descend one level per round into the subtrees whose digests differ until
+// the peer sends the wallets of the differing leaves. Nodes holding the same states settle in a
+// single round. Like SyncWallets the node only keeps wallets that are new to it or extend its
+// own copy, and that its own view agrees it should replicate.
+// Returns an error if the peer failed to answer, along with what was reconciled until then.
+func (node *Node) Reconcile(peer Contact) (Reconciliation, error) {
+	rec := Reconciliation{Peer: peer}
+	if node.Role() == OBSERVER {
+		return rec, nil
+	}
+	tree := node.Ledger().walletTree(func(id KademliaID) bool { return node.isReplica(peer, id, node.heldReplication(id)) })
+	pending := []merkleNode{tree.root()}
+	for depth := 0; depth <= MERKLE_DEPTH && len(pending) > 0; depth++ {
+		if depth == MERKLE_DEPTH {
+			rec.Leaves += len(pending)
+		}
+		rpc := GenerateRPC(peer.IP(), node.Contact)
+		rpc.Reconcile(pending)
+		rec.Rounds++
+		res, err := node.Send(rpc)
+		if err != nil {
+			return rec, errors.New(fmt.Sprintf("failed to reconcile with %v: %v", peer.IP(), err))
+		}
+		pending = make([]merkleNode, 0)
+		for _, theirs := range res.merkleNodes {
+			// only the next level is expected, so the descent ends whatever the peer answers
+			if !theirs.valid() || theirs.Depth != depth+1 {
+				continue
+			}
+			if mine := tree.node(theirs.Depth, theirs.Index); mine.Hash != theirs.Hash {
+				pending = append(pending, mine)
+			}
+		}
+		for _, state := range res.walletStates {
+			rec.Received++
+			if !node.isReplica(node.Contact, state.ID, state.Replication) {
+				continue
+			}
+			restored, err := node.scalegraph.RestoreAccount(state.ID, state.PublicKey, state.Replication, state.Transactions)
+			if err != nil {
+				node.logger.Warn("failed to reconcile wallet", "wallet", state.ID, "peer", peer.IP(), "err", err)
+			}
+			if restored {
+				rec.Repaired++
+			}
+		}
+	}
+	if rec.Divergent() {
+		node.logger.Debug("reconciled wallets", "peer", peer.IP(), "leaves", rec.Leaves, "repaired", rec.Repaired)
+	}
+	return rec, nil
+}
+
+// Response logic for an incoming reconcile RPC. The node builds its tree over the wallets it
+// holds that the requester should replicate, and for each subtree whose digest differs from the
+// requester's returns its children, or the wallets it covers if it is a leaf.
+func (node *Node) handleReconcile(rpc *RPC) {
+	tree := node.Ledger().walletTree(func(id KademliaID) bool { return node.isReplica(rpc.sender, id, node.heldReplication(id)) })
+	nodes := make([]merkleNode, 0)
+	states := make([]walletState, 0)
+	for _, theirs := range rpc.merkleNodes {
+		if !theirs.valid() {
+			continue
+		}
+		mine := tree.node(theirs.Depth, theirs.Index)
+		if mine.Hash == theirs.Hash {
+			continue
+		}
+		if mine.Depth < MERKLE_DEPTH {
+			nodes = append(nodes, tree.children(mine)...)
+			continue
+		}
+		for _, id := range tree.leaves[mine.Index] {
+			acc, err := node.scalegraph.FindAccount(id)
+			if err != nil {
+				continue
+			}
+			states = append(states, walletState{id, acc.PublicKey(), acc.Replication(), acc.Transactions()})
+		}
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.Reconciled(nodes, states)
+	node.Send(resp)
+}
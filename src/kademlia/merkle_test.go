@@ -0,0 +1,64 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	testName := "TestReconcile"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	holders := make([]*Node, 0)
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err == nil {
+			holders = append(holders, n)
+		}
+	}
+	behind, ahead := holders[0], holders[1]
+
+	rec, err := behind.Reconcile(ahead.Contact)
+	if err != nil || rec.Divergent() || rec.Rounds != 1 {
+		log.Printf("[%s] - replicas in the same state did not settle in one round: %s %v", testName, rec.Display(), err)
+		t.Fail()
+	}
+
+	ahead.scalegraph.ApplyTransaction(id, scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 5))
+	if behind.Ledger().RootDigest() == ahead.Ledger().RootDigest() {
+		log.Printf("[%s] - diverged replicas share a root digest", testName)
+		t.FailNow()
+	}
+	rec, err = behind.Reconcile(ahead.Contact)
+	if err != nil || rec.Rounds != MERKLE_DEPTH+1 || rec.Leaves != 1 || rec.Received != 1 || rec.Repaired != 1 {
+		log.Printf("[%s] - unexpected reconciliation: %s %v", testName, rec.Display(), err)
+		t.Fail()
+	}
+	if wallet, err := behind.Ledger().Wallet(id); err != nil || wallet.Balance != 15 {
+		log.Printf("[%s] - replica was not brought up to date: %+v %v", testName, wallet, err)
+		t.Fail()
+	}
+	if behind.Ledger().RootDigest() != ahead.Ledger().RootDigest() {
+		log.Printf("[%s] - reconciled replicas disagree on their root digest", testName)
+		t.Fail()
+	}
+
+	// a lost replica is restored from the peer
+	behind.scalegraph.RemoveAccount(id)
+	rec, err = behind.Reconcile(ahead.Contact)
+	if err != nil || rec.Repaired != 1 {
+		log.Printf("[%s] - lost replica was not restored: %s %v", testName, rec.Display(), err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,74 @@
+package kademlia
+
+import "time"
+
+// Metrics receives instrumentation events from nodes and the simulated network.
+// Implementations must be safe for concurrent use, the package itself ships only a no-op
+// implementation so that the core stays free of metrics dependencies.
+type Metrics interface {
+	// Called when a request RPC completes, err is nil if a response was received.
+	RPCSent(command Command, latency time.Duration, err error)
+	// Called when a node dispatches an incoming request RPC to its handler.
+	RPCHandled(command Command)
+	// Called when the simulated network routes a RPC, dropped is true if the RPC was lost.
+	RPCRouted(command Command, dropped bool)
+	// Called when a node lookup terminates, hops is the number of query rounds it took.
+	LookupCompleted(hops int, duration time.Duration)
+	// Called when a response arrives after its requester stopped waiting for it.
+	ResponseUndelivered(command Command)
+	// Called when a node takes a RPC off its inbound queue, depth includes the RPC.
+	HandlerQueued(command Command, wait time.Duration, depth int)
+	// Called when the simulated network discards a RPC because of its receiver's overflow policy.
+	HandlerDropped(command Command)
+	// Called when a node drops an incoming RPC whose sender could not prove its id or whose
+	// signature is invalid.
+	RPCRejected(command Command)
+	// Called when a node drops an incoming request RPC because its sender exceeded its rate limit.
+	RPCRateLimited(command Command)
+	// Called when a node completes an anti-entropy round with a co-validator, leaves is the number
+	// of wallet tree leaves the two disagreed on and repaired the wallets the node brought up to date.
+	WalletsReconciled(leaves int, repaired int)
+	// Called when a node finishes storing a value, replication is the number of nodes the value
+	// was to be stored at and stored the number that stored it.
+	ValueStored(replication int, stored int)
+	// Called when a node's response janitor expires waiting entries that got no response, which
+	// includes requests still waiting to be enqueued under backpressure.
+	ResponsesExpired(count int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) RPCSent(command Command, latency time.Duration, err error)    {}
+func (noopMetrics) RPCHandled(command Command)                                   {}
+func (noopMetrics) RPCRouted(command Command, dropped bool)                      {}
+func (noopMetrics) LookupCompleted(hops int, duration time.Duration)             {}
+func (noopMetrics) ResponseUndelivered(command Command)                          {}
+func (noopMetrics) HandlerQueued(command Command, wait time.Duration, depth int) {}
+func (noopMetrics) HandlerDropped(command Command)                               {}
+func (noopMetrics) RPCRejected(command Command)                                  {}
+func (noopMetrics) RPCRateLimited(command Command)                               {}
+func (noopMetrics) WalletsReconciled(leaves int, repaired int)                   {}
+func (noopMetrics) ValueStored(replication int, stored int)                      {}
+func (noopMetrics) ResponsesExpired(count int)                                   {}
+
+// Attaches a metrics implementation to the node, passing nil restores the no-op default.
+func (node *Node) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+	node.Network.metrics = metrics
+}
+
+// Attaches a metrics implementation to the simnet and every node it has spawned, nodes spawned
+// later inherit it. Passing nil restores the no-op default.
+func (simnet *Simnet) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.metrics = metrics
+	for _, n := range simnet.spawned.nodePointer {
+		n.SetMetrics(metrics)
+	}
+}
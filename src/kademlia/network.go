@@ -2,155 +2,447 @@ package kademlia
 
 import (
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type table struct {
-	content map[[5]uint32]chan RPC
+	content map[KademliaID]pendingResponse
+	buffer  int
+	expired atomic.Uint64 // entries removed by expire, their waiters never got a response
 	sync.RWMutex
 }
 
+type pendingResponse struct {
+	ch     chan RPC
+	sweeps int // calls to expire that found the entry pending
+}
+
 func NewTable() *table {
-	ch := make(map[[5]uint32]chan RPC, 1024)
+	ch := make(map[KademliaID]pendingResponse, 1024)
 	return &table{
 		content: ch,
+		buffer:  RESPONSE_BUFFER,
 	}
 }
 
+// Sets the buffer size of response channels created from now on.
+func (table *table) SetResponseBuffer(size int) {
+	table.Lock()
+	defer table.Unlock()
+	table.buffer = max(size, 0)
+}
+
 // Creates a RPC channel corresponding to the given id.
 // Channel is entered into network table and returned.
 // Returns an error if id is already in use.
-func (table *table) Add(id [5]uint32) (chan RPC, error) {
+func (table *table) Add(id KademliaID) (chan RPC, error) {
 	table.Lock()
 	defer table.Unlock()
 
 	_, exists := table.content[id]
 	if exists {
-		return make(chan RPC), errors.New("RPC id in use")
+		return make(chan RPC), ErrDuplicateRPCID
 	}
 
-	respChan := make(chan RPC)
-	table.content[id] = respChan
+	respChan := responseChan(table.buffer)
+	table.content[id] = pendingResponse{ch: respChan}
 	return respChan, nil
 }
 
-// Returns the matching RPC channel, or an error if there is no match.
-func (table *table) RetrieveChan(id [5]uint32) (chan RPC, error) {
+// Returns the matching RPC channel and removes it from the table, or an error if there is no
+// match. The lookup only takes the read lock, responses nobody waits for any more do not hold up
+// the senders registering new requests.
+func (table *table) RetrieveChan(id KademliaID) (chan RPC, error) {
+	table.RLock()
+	entry, ok := table.content[id]
+	table.RUnlock()
+	if !ok {
+		return nil, ErrNoMatchingRPC
+	}
+
 	table.Lock()
 	defer table.Unlock()
-
-	ch, ok := table.content[id]
-	if !ok {
-		return nil, errors.New("no matching RPC id")
+	// dropped or expired in between, an expired channel is closed and must not be written to
+	if current, ok := table.content[id]; !ok || current.ch != entry.ch {
+		return nil, ErrNoMatchingRPC
 	}
 	delete(table.content, id)
-	return ch, nil
+	return entry.ch, nil
 }
 
 // Removes entry with id from table.
-func (table *table) DropChan(id [5]uint32) {
+func (table *table) DropChan(id KademliaID) {
 	table.Lock()
 	defer table.Unlock()
 	delete(table.content, id)
 }
 
+// Closes and removes the entries that were already pending on the two previous calls, so an
+// entry is expired once it is older than twice the interval between calls. A sender waiting for
+// the full interval has dropped its entry before then.
+// Returns the number of entries expired.
+func (table *table) expire() int {
+	table.Lock()
+	defer table.Unlock()
+	expired := 0
+	for id, entry := range table.content {
+		if entry.sweeps == 2 {
+			close(entry.ch)
+			delete(table.content, id)
+			expired++
+		} else {
+			entry.sweeps++
+			table.content[id] = entry
+		}
+	}
+	table.expired.Add(uint64(expired))
+	return expired
+}
+
+// Returns the number of requests waiting for a response.
+func (table *table) PendingResponses() int {
+	table.RLock()
+	defer table.RUnlock()
+	return len(table.content)
+}
+
+// Returns the number of requests whose waiting entry was expired without a response by the
+// node's response janitor. Send removes the entries of requests that time out itself, so a growing count
+// points at a leak.
+func (table *table) ExpiredResponses() uint64 {
+	return table.expired.Load()
+}
+
+// Removes every entry from the table and returns the number of pending RPCs that were dropped.
+func (table *table) Drain() int {
+	table.Lock()
+	defer table.Unlock()
+	pending := len(table.content)
+	clear(table.content)
+	return pending
+}
+
+// Inbound RPC channel of a node.
+// The inbox is closed exactly once, and deliveries never write to a closed inbox.
+type inbox struct {
+	content  chan RPC
+	done     chan struct{}
+	closed   bool
+	queue    inboxQueue
+	timebase Clock // times queue waits and saturation, see SetTimebase
+	once     sync.Once
+	sync.RWMutex
+}
+
+func newInbox(content chan RPC) *inbox {
+	return &inbox{
+		content:  content,
+		done:     make(chan struct{}),
+		timebase: SystemClock(),
+	}
+}
+
+// Delivers the RPC to the inbox, a full inbox is handled by its overflow policy.
+// Returns false if the inbox is closed or the RPC was discarded.
+func (inbox *inbox) Deliver(rpc RPC) bool {
+	res, _ := inbox.deliver(rpc)
+	return res == DELIVERED
+}
+
+// Closes the inbox, any blocked deliveries are released before the channel itself is closed.
+func (inbox *inbox) Close() {
+	inbox.once.Do(func() {
+		close(inbox.done)
+		inbox.Lock()
+		defer inbox.Unlock()
+		inbox.closed = true
+		close(inbox.content)
+	})
+}
+
+// Returns a channel that is closed once the inbox starts closing.
+func (inbox *inbox) Done() <-chan struct{} {
+	return inbox.done
+}
+
+// Identifies a deployment, nodes only exchange RPCs with nodes of the same network.
+type NetworkID uint32
+
+const DEFAULT_NETWORK NetworkID = 0
+
 type Network struct {
-	nodeID     [5]uint32
-	listener   chan RPC
+	nodeID     KademliaID
+	networkID  NetworkID
+	version    ProtocolVersion
+	minVersion ProtocolVersion
+	role       Role
+	caps       Capability
+	identity   *Identity // see SetIdentity, nil if peers' ids are not verified
+	puzzle     int       // crypto puzzle difficulty peers' ids must solve
+	rejected   *atomic.Uint64
+	listener   *inbox
 	sender     chan RPC
+	shards     []chan RPC
 	serverIP   [4]byte
+	address    [4]byte // IP the network sends from, stamped on every RPC as its origin
 	masterNode Contact
 	debug      bool
+	logger     *slog.Logger
+	logLevel   *slog.LevelVar
+	rtt        *rttTable
+	versions   *versionTable
+	latency    *latencyTable
+	metrics    Metrics
+	timebase   Clock
+	timeout    time.Duration // how long Send waits for a response, the ceiling if adaptive
+	adaptive   bool          // derive each request's timeout from the receiver's round trip time
+	sessions   *sessionTable // see Config.Encryption, nil if RPCs are sent in the clear
 	*table
 }
 
 // Returns a network pointer.
-func NewNetwork(id [5]uint32, listener chan RPC, sender chan RPC, controller chan RPC, serverIP [4]byte, master Contact, debug bool) *Network {
+func NewNetwork(id KademliaID, listener chan RPC, sender chan RPC, controller chan RPC, serverIP [4]byte, master Contact, debug bool) *Network {
 	newNetwork := Network{
 		nodeID:     id,
-		listener:   listener,
+		listener:   newInbox(listener),
 		sender:     sender,
 		serverIP:   serverIP,
 		masterNode: master,
 		debug:      debug,
+		logLevel:   newLevel(debugLevel(debug)),
+		version:    PROTOCOL_VERSION,
+		minVersion: MIN_PROTOCOL_VERSION,
+		caps:       DEFAULT_CAPABILITIES,
+		rtt:        newRTTTable(),
+		versions:   newVersionTable(),
+		latency:    newLatencyTable(),
+		metrics:    noopMetrics{},
+		timebase:   SystemClock(),
+		timeout:    TIMEOUT,
+		rejected:   new(atomic.Uint64),
 		table:      NewTable(),
 	}
+	newNetwork.SetLogger(defaultLogger())
 	return &newNetwork
 }
 
+// Sets the network the node belongs to, must be called before the node is started.
+func (net *Network) SetNetworkID(id NetworkID) {
+	net.networkID = id
+}
+
+func (net *Network) NetworkID() NetworkID {
+	return net.networkID
+}
+
 func (net *Network) Debug(mode bool) {
 	net.debug = mode
+	net.logLevel.Set(debugLevel(mode))
+}
+
+// Closes the network's inbound channel and releases every pending Send with a shutdown error.
+// Returns the number of pending RPCs that were dropped.
+func (net *Network) Close() int {
+	net.listener.Close()
+	return net.Drain()
+}
+
+// Returns true once the network has been closed.
+func (net *Network) Closed() bool {
+	select {
+	case <-net.listener.Done():
+		return true
+	default:
+		return false
+	}
 }
 
 // Sends a RPC and creates a corresponding RPC id handle.
-// Returns an error if the Response exceedes the timeout.
+// Returns an error if the Response exceedes the timeout or the network is closed while waiting.
 func (net *Network) Send(rpc RPC) (RPC, error) {
+	rpc.network = net.networkID
+	rpc.origin = net.address
+	rpc.version = net.version
+	rpc.minVersion = net.minVersion
+	rpc.role = net.role
+	rpc.caps = net.caps
+	if net.identity != nil {
+		rpc.senderKey = net.identity.PublicKey
+		rpc.sign(net.identity.PrivateKey)
+	}
+	wire := rpc
+	if net.sessions != nil && !net.plaintext(&rpc) {
+		sealed, err := net.seal(&rpc)
+		if err != nil {
+			net.logger.Debug("could not seal rpc", "rpc", rpc.id, "cmd", rpc.cmd, "receiver", rpc.receiver, "err", err)
+			return rpc, err
+		}
+		wire = sealed
+	}
 	if rpc.response {
-		if net.debug {
-			log.Printf("[DEBUG]\nNode %v sending rpc:\n%s", net.nodeID, rpc.Display())
+		net.logger.Debug("sending response", "rpc", rpc.id, "cmd", rpc.cmd, "receiver", rpc.receiver)
+		select {
+		case net.outbound(rpc.receiver) <- wire:
+			return rpc, nil
+		case <-net.listener.Done():
+			return rpc, ErrShutdown
 		}
-		net.sender <- rpc
-		return rpc, nil
 	} else {
 		respChan, err := net.Add(rpc.id)
 		if err != nil {
-			log.Printf("[ERROR] - %s", err.Error())
+			net.logger.Error("could not register RPC", "rpc", rpc.id, "err", err)
 			return rpc, err
 		}
-		net.sender <- rpc
-		if net.debug {
-			log.Printf("[DEBUG]\nNode %v sending rpc:\n%s", net.nodeID, rpc.Display())
+		select {
+		case net.outbound(rpc.receiver) <- wire:
+		case <-net.listener.Done():
+			net.DropChan(rpc.id)
+			return rpc, ErrShutdown
 		}
+		net.logger.Debug("sending request", "rpc", rpc.id, "cmd", rpc.cmd, "receiver", rpc.receiver)
+		sent := net.timebase.Now()
 		select {
-		case res := <-respChan:
+		case res, ok := <-respChan:
+			if !ok {
+				// expired by the response janitor
+				err = ErrTimeout
+				break
+			}
+			releaseResponseChan(respChan)
+			elapsed := net.timebase.Since(sent)
+			net.rtt.Update(rpc.receiver, elapsed)
+			net.latency.Observe(rpc.cmd, elapsed)
+			net.metrics.RPCSent(rpc.cmd, elapsed, nil)
 			return res, nil
-		case <-time.After(TIMEOUT):
-			go net.DropChan(rpc.id)
-			return rpc, errors.New("timeout")
+		case <-net.listener.Done():
+			net.DropChan(rpc.id)
+			err = ErrShutdown
+		case <-net.timebase.After(net.requestTimeout(rpc.receiver)):
+			net.DropChan(rpc.id)
+			if net.adaptive {
+				// the estimate no longer holds, the next request waits for the full timeout
+				net.rtt.Forget(rpc.receiver)
+			}
+			if net.sessions != nil {
+				// the receiver may have lost the session, the next request opens a new one
+				net.sessions.forget(rpc.receiver)
+			}
+			err = ErrTimeout
 		}
+		net.metrics.RPCSent(rpc.cmd, net.timebase.Since(sent), err)
+		return rpc, err
 	}
 }
 
+// Returns how long Send waits for a response from ip. With adaptive timeouts that is derived
+// from the round trip times measured to ip, and the configured timeout otherwise.
+func (net *Network) requestTimeout(ip [4]byte) time.Duration {
+	if !net.adaptive {
+		return net.timeout
+	}
+	return net.rtt.Timeout(ip, net.timeout)
+}
+
 // Start a listener on the network channel.
-// Returns an error if the channel closes.
+// Returns nil once the network is closed, or an error if the channel closes unexpectedly.
 func (net *Network) Listen(node *Node) error {
 	for {
 		select {
-		case <-node.shutdown:
+		case <-net.listener.Done():
 			return nil
-		case rpc, ok := <-net.listener:
+		case rpc, ok := <-net.listener.content:
 			if !ok {
 				return errors.New("server down")
 			}
-			go net.route(node, rpc)
+			wait, depth := net.listener.dequeued(rpc)
+			net.metrics.HandlerQueued(rpc.cmd, wait, depth)
+			since, saturated, recovered := net.listener.checkSaturation(depth, net.timebase.Now(), node.config.scaled(SATURATION_WINDOW))
+			if saturated {
+				net.logger.Warn("inbound queue saturated", "depth", depth, "size", cap(net.listener.content), "since", since)
+				node.publish(QueueSaturated{node.Contact, depth, cap(net.listener.content), since})
+			} else if recovered {
+				node.publish(QueueRecovered{node.Contact, net.timebase.Since(since)})
+			}
+			pooled := pooledRPC(rpc)
+			node.routines.Go("route", func() {
+				net.route(node, pooled)
+				releaseRPC(pooled)
+			})
 		}
 	}
 }
 
 // Routes the rpc to the appropriate components.
 // If the rpc is a Response it tries to route it to that channel, otherwise routes it to the controller.
-func (net *Network) route(node *Node, rpc RPC) {
-	if net.debug {
-		log.Printf("[DEBUG]\nNode %v - routing rpc:\n%s", node.ID(), rpc.Display())
+// RPCs from other networks, and if the node has an identity RPCs whose sender can not prove its
+// id or did not sign them, are dropped without a response. Requests in a protocol version the node does not
+// understand are answered with an unsupported version response.
+// Nothing may hold on to rpc once route returns, it is recycled.
+func (net *Network) route(node *Node, rpc *RPC) {
+	net.logger.Debug("routing rpc", "rpc", rpc.id, "cmd", rpc.cmd, "sender", rpc.sender.ID(), "response", rpc.response)
+	if rpc.network != net.networkID {
+		net.logger.Debug("dropping rpc from foreign network", "rpc", rpc.id, "cmd", rpc.cmd, "network", rpc.network)
+		return
+	}
+	if net.sessions != nil {
+		if err := net.unseal(rpc); err != nil {
+			net.rejected.Add(1)
+			net.metrics.RPCRejected(rpc.cmd)
+			net.logger.Debug("dropping rpc that could not be unsealed", "rpc", rpc.id, "cmd", rpc.cmd, "err", err)
+			return
+		}
+	}
+	if net.identity != nil {
+		if err := net.authenticate(rpc); err != nil {
+			net.rejected.Add(1)
+			net.metrics.RPCRejected(rpc.cmd)
+			net.logger.Debug("dropping unauthenticated rpc", "rpc", rpc.id, "cmd", rpc.cmd, "err", err)
+			return
+		}
+	}
+	if net.compatible(rpc) {
+		if rpc.sender.ID() != net.nodeID {
+			net.versions.record(rpc.sender.IP(), min(rpc.version, net.version))
+		}
+	} else if !rpc.response {
+		net.logger.Debug("rejecting rpc of unsupported protocol version", "rpc", rpc.id, "cmd", rpc.cmd, "version", rpc.version, "min", rpc.minVersion)
+		resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+		resp.UnsupportedVersion()
+		net.Send(resp)
+		return
+	}
+	if rpc.cmd == OPEN_SESSION && !rpc.response && net.sessions != nil {
+		if err := net.acceptSession(rpc); err != nil {
+			net.rejected.Add(1)
+			net.metrics.RPCRejected(rpc.cmd)
+			net.logger.Debug("dropping session handshake", "rpc", rpc.id, "err", err)
+		}
+		return
+	}
+	if net.role == OBSERVER {
+		node.observations.record(rpc, net.timebase.Now())
 	}
 	if rpc.response {
 		respChan, err := net.RetrieveChan(rpc.id)
 		if err != nil {
-			if node.debug {
-				errMSg := fmt.Sprintf("[ERROR] - possible time out\n error: %s", err.Error())
-				log.Println(errMSg)
-			}
+			net.metrics.ResponseUndelivered(rpc.cmd)
+			net.logger.Debug("response without waiter, possible time out", "rpc", rpc.id, "cmd", rpc.cmd, "err", err)
 			return
 		}
-		go net.DropChan(rpc.id)
-		respChan <- rpc
-	} else {
-		if net.debug {
-			log.Printf("[DEBUG]\nNode %v - routing rpc %v to handler", node.ID(), rpc.id)
+		// The waiter may give up between the lookup and the delivery, so never block on it for
+		// longer than the delivery timeout.
+		select {
+		case respChan <- *rpc:
+		case <-net.listener.Done():
+		case <-net.timebase.After(scaleTimeout(RESPONSE_DELIVERY_TIMEOUT, net.timeout)):
+			net.metrics.ResponseUndelivered(rpc.cmd)
+			net.logger.Debug("response waiter gave up before delivery", "rpc", rpc.id, "cmd", rpc.cmd)
 		}
-		node.Handler(&rpc)
+	} else {
+		net.logger.Debug("routing rpc to handler", "rpc", rpc.id)
+		node.Handler(rpc)
 	}
 }
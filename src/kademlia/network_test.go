@@ -1,4 +1,182 @@
 package kademlia
 
 import (
+	"log"
+	"testing"
+	"time"
 )
+
+func TestInboxDeliverAfterClose(t *testing.T) {
+	testName := "TestInboxDeliverAfterClose"
+	in := newInbox(make(chan RPC, 1))
+	in.Close()
+	in.Close()
+	if in.Deliver(GenerateRPC(RandomIP(), NewRandomContact())) {
+		log.Printf("[%s] - delivered RPC to a closed inbox", testName)
+		t.Fail()
+	}
+}
+
+func TestInboxCloseReleasesBlockedDeliver(t *testing.T) {
+	testName := "TestInboxCloseReleasesBlockedDeliver"
+	in := newInbox(make(chan RPC))
+	delivered := make(chan bool)
+	go func() {
+		delivered <- in.Deliver(GenerateRPC(RandomIP(), NewRandomContact()))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	in.Close()
+	select {
+	case ok := <-delivered:
+		if ok {
+			log.Printf("[%s] - blocked delivery reported success", testName)
+			t.Fail()
+		}
+	case <-time.After(time.Second):
+		log.Printf("[%s] - blocked delivery was not released by close", testName)
+		t.Fail()
+	}
+}
+
+func TestNetworkSendShutdown(t *testing.T) {
+	testName := "TestNetworkSendShutdown"
+	me := NewRandomContact()
+	net := NewNetwork(me.ID(), make(chan RPC, 1), make(chan RPC, 1), nil, [4]byte{0, 0, 0, 0}, me, false)
+	errChan := make(chan error)
+	go func() {
+		rpc := GenerateRPC(RandomIP(), me)
+		rpc.Ping()
+		_, err := net.Send(rpc)
+		errChan <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	net.Close()
+	select {
+	case err := <-errChan:
+		if err == nil || err.Error() != "shutdown" {
+			log.Printf("[%s] - expected a shutdown error, received %v", testName, err)
+			t.Fail()
+		}
+	case <-time.After(TIMEOUT / 2):
+		log.Printf("[%s] - pending send was not released by close", testName)
+		t.Fail()
+	}
+}
+
+func TestRouteLateResponse(t *testing.T) {
+	testName := "TestRouteLateResponse"
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC, 1), make(chan RPC, 1), [4]byte{0, 0, 0, 0}, me, false)
+	node.SetResponseBuffer(0)
+	rpc := GenerateRPC(RandomIP(), me)
+	node.Network.Add(rpc.id)
+	resp := GenerateResponse(rpc.id, me.IP(), NewRandomContact())
+	resp.Pong()
+
+	// Nobody is waiting on the unbuffered response channel, route must still return.
+	returned := make(chan struct{})
+	go func() {
+		node.Network.route(node, &resp)
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(TIMEOUT):
+		log.Printf("[%s] - route blocked on an abandoned response channel", testName)
+		t.Fail()
+	}
+}
+
+func TestExpireResponses(t *testing.T) {
+	testName := "TestExpireResponses"
+	me := NewRandomContact()
+	config := DefaultConfig()
+	config.Timeout = time.Minute
+	node, _ := NewNodeWithConfig(me.ID(), me.IP(), make(chan RPC, 1), make(chan RPC, 1), [4]byte{0, 0, 0, 0}, me, false, config)
+	rpc := GenerateRPC(RandomIP(), me)
+	rpc.Ping()
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := node.Send(rpc)
+		errChan <- err
+	}()
+	for node.PendingResponses() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// entries survive two sweeps, the third closes the abandoned channel
+	for sweep := 1; sweep <= 3; sweep++ {
+		expired := node.Network.expire()
+		if (sweep < 3 && expired != 0) || (sweep == 3 && expired != 1) {
+			log.Printf("[%s] - sweep %d expired %d entries", testName, sweep, expired)
+			t.Fail()
+		}
+	}
+	select {
+	case err := <-errChan:
+		if err == nil || err.Error() != "timeout" {
+			log.Printf("[%s] - expected a timeout error, received %v", testName, err)
+			t.Fail()
+		}
+	case <-time.After(TIMEOUT):
+		log.Printf("[%s] - expired send was not released", testName)
+		t.Fail()
+	}
+	if node.PendingResponses() != 0 || node.ExpiredResponses() != 1 {
+		log.Printf("[%s] - %d pending and %d expired responses after the sweep", testName, node.PendingResponses(), node.ExpiredResponses())
+		t.Fail()
+	}
+	// a late response finds no waiter instead of writing to the closed channel
+	if _, err := node.Network.RetrieveChan(rpc.id); err == nil {
+		log.Printf("[%s] - retrieved an expired response channel", testName)
+		t.Fail()
+	}
+}
+
+func TestNetworkIDIsolation(t *testing.T) {
+	testName := "TestNetworkIDIsolation"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	mainnet := s.SpawnCluster(4, done)
+	<-done
+
+	// the first node has no member of its network to enter through and retries until the others appear
+	testnet := make([]*Node, 0, 3)
+	joined := make(chan KademliaID, 3)
+	for range 3 {
+		node := s.SpawnNodeInNetwork(NetworkID(7), joined)
+		node.SetLogLevel(LOG_SILENT)
+		testnet = append(testnet, node)
+	}
+	for range testnet {
+		<-joined
+	}
+	for _, node := range testnet {
+		if node.Len() == 0 {
+			log.Printf("[%s] - node %v of the new network knows no peers", testName, node.IP())
+			t.Fail()
+		}
+	}
+
+	if !testnet[0].Ping(testnet[1].IP()) {
+		log.Printf("[%s] - nodes of the same network could not reach each other", testName)
+		t.Fail()
+	}
+	if testnet[0].Ping(mainnet[0].IP()) || mainnet[0].Ping(testnet[0].IP()) {
+		log.Printf("[%s] - ping crossed network boundaries", testName)
+		t.Fail()
+	}
+	for _, n := range s.AllNodePointers() {
+		for _, con := range n.AllContacts() {
+			for _, m := range s.AllNodePointers() {
+				if m.IP() == con.IP() && m.NetworkID() != n.NetworkID() {
+					log.Printf("[%s] - node %v knows node %v of another network", testName, n.IP(), con.IP())
+					t.Fail()
+				}
+			}
+		}
+	}
+	s.Shutdown()
+}
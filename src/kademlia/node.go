@@ -1,79 +1,215 @@
 package kademlia
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"main/src/scalegraph"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	KEYSPACE      = 160 // the number of buckets
-	KBUCKETVOLUME = 20  // K, number of contacts per bucket
-	REPLICATION   = 20 // alpha
-	CONCURRENCY   = 3
-	PORT          = 8080
-	DEBUG         = true
-	POINT_DEBUG   = true
-	TIMEOUT       = 500 * time.Millisecond
+	KEYSPACE                  = ID_BITS // the number of buckets, one per bit of the key length
+	KBUCKETVOLUME             = 20      // K, number of contacts per bucket
+	REPLICATION               = 20      // K, contacts returned by a lookup and validators per account
+	CONCURRENCY               = 3       // alpha, find node queries in flight per lookup
+	PORT                      = 8080
+	DEBUG                     = true
+	POINT_DEBUG               = true
+	TIMEOUT                   = 500 * time.Millisecond
+	MIN_TIMEOUT               = TIMEOUT / 25 // shortest timeout an adaptive request timeout is cut down to
+	SHUTDOWN_GRACE            = 4 * TIMEOUT  // how long a shutdown waits for a node's goroutines to exit
+	RESPONSE_BUFFER           = 1            // response channel buffer, lets a response be handed over without waiting
+	RESPONSE_DELIVERY_TIMEOUT = TIMEOUT / 10 // how long a response waits for its receiver before it is discarded
+	MAX_HOPS                  = 32           // times a request may be forwarded on behalf of another before the simnet drops it
 )
 
 type Node struct {
 	Contact
 	Network
 	RoutingTable
-	scalegraph scalegraph.Scalegraph
-	shutdown   chan struct{}
-	debug      bool
+	scalegraph    scalegraph.Scalegraph
+	snapshots     *snapshotTable
+	mailbox       *mailbox
+	values        *valueStore
+	observations  *observerLog
+	proposals     *proposalTable
+	handlers      *handlerTable
+	limiter       *rateLimiter
+	batches       *batchTable
+	watchers      *watcherTable
+	subscriptions *subscriptionTable
+	topics        *watcherTable // subscribers of the topics the node is a rendezvous point of
+	topicSubs     *topicSubscriptions
+	clock         *clock
+	bootstrap     Bootstrapper
+	joinToken     []byte // see SetJoinToken
+	transport     Sender
+	routines      *routineTracker
+	latencyAware  atomic.Bool  // see SetLatencyAwareLookups
+	lookupPaths   atomic.Int32 // see SetLookupPaths, zero is a single path
+	recursive     atomic.Bool  // see SetRecursiveLookups
+	lookupHops    *hopTable
+	lookupCache   *lookupCache // see SetLookupCache
+	behavior      Behavior     // see SetBehavior, nil for honest nodes
+	events        *EventBus
+	recent        *eventLog
+	antiEntropy   *antiEntropyCounters
+	logger        *slog.Logger
+	logLevel      *slog.LevelVar
+	config        Config
+	debug         bool
 }
 
-func NewNode(id [5]uint32, ip [4]byte, listener chan RPC, sender chan RPC, serverIP [4]byte, masterNode Contact, debug bool) *Node {
+func NewNode(id KademliaID, ip [4]byte, listener chan RPC, sender chan RPC, serverIP [4]byte, masterNode Contact, debug bool) *Node {
+	node, _ := NewNodeWithConfig(id, ip, listener, sender, serverIP, masterNode, debug, DefaultConfig())
+	return node
+}
+
+// Returns a node using the given Kademlia parameters instead of the package defaults.
+// Returns an error if the configuration is invalid.
+func NewNodeWithConfig(id KademliaID, ip [4]byte, listener chan RPC, sender chan RPC, serverIP [4]byte, masterNode Contact, debug bool, config Config) (*Node, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	controller := make(chan RPC)
 	net := NewNetwork(id, listener, sender, controller, serverIP, masterNode, false)
+	net.timeout = config.Timeout
+	net.adaptive = config.AdaptiveTimeout
+	net.address = ip
 	me := NewContact(ip, id)
-	router := NewRoutingTable(me, KEYSPACE, KBUCKETVOLUME)
-	return &Node{
-		Contact:      me,
-		Network:      *net,
-		RoutingTable: *router,
-		scalegraph:   *scalegraph.NewScaleGraph(),
-		shutdown:     make(chan struct{}),
-		debug:        debug,
+	if config.Encryption {
+		net.sessions = newSessionTable(me)
+	}
+	router := NewRoutingTable(me, config.Keyspace, config.BucketSize)
+	node := &Node{
+		Contact:       me,
+		Network:       *net,
+		RoutingTable:  *router,
+		scalegraph:    *scalegraph.NewScaleGraph(),
+		snapshots:     newSnapshotTable(),
+		mailbox:       newMailbox(),
+		values:        newValueStore(),
+		observations:  newObserverLog(),
+		proposals:     newProposalTable(),
+		handlers:      newHandlerTable(),
+		limiter:       newRateLimiter(),
+		batches:       newBatchTable(),
+		watchers:      newWatcherTable(),
+		subscriptions: newSubscriptionTable(),
+		topics:        newWatcherTable(),
+		topicSubs:     newTopicSubscriptions(),
+		clock:         newClock(),
+		bootstrap:     MasterNodeBootstrap{},
+		recent:        newEventLog(),
+		routines:      newRoutineTracker(),
+		lookupHops:    &hopTable{},
+		lookupCache:   newLookupCache(),
+		antiEntropy:   newAntiEntropyCounters(),
+		logLevel:      newLevel(debugLevel(debug)),
+		config:        config,
+		debug:         debug,
 	}
+	node.transport = &node.Network
+	node.RoutingTable.onChange = node.contactChanged
+	node.SetLogger(defaultLogger())
+	return node, nil
 }
 
 // Starts up the node, joining the network via the "Enter", and "Find node" protocols.
-func (node *Node) Start(done chan [5]uint32) {
-	go node.Network.Listen(node)
+func (node *Node) Start(done chan KademliaID) {
+	node.listen()
 	if node.Contact.IP() == node.masterNode.IP() {
 		return
 	} else {
-		node.Enter()
+		err := node.Enter()
+		if err != nil {
+			node.logger.Error("failed to join the network", "err", err)
+		}
+		node.maintain()
 		done <- node.ID()
 	}
 }
 
+// Starts a node restored with its routing table, which takes part in the network right away
+// instead of entering it.
+func (node *Node) resume() {
+	node.listen()
+	if node.Contact.IP() != node.masterNode.IP() {
+		node.maintain()
+	}
+}
+
+func (node *Node) listen() {
+	node.routines.Go("listen", func() { node.Network.Listen(node) })
+	node.routines.Go("response janitor", node.expireResponses)
+}
+
+// Runs the background work of a node that has joined the network.
+func (node *Node) maintain() {
+	node.routines.Go("collect messages", node.collectMessages)
+	node.routines.Go("wallet sync", node.walletSyncLoop)
+	if node.config.AntiEntropy > 0 {
+		node.routines.Go("anti-entropy", node.antiEntropyLoop)
+	}
+}
+
+// Stops the node: the inbound channel is closed, pending sends fail with a shutdown error and
+// in-flight lookups return early. Blocks until every goroutine spawned by the node has exited.
+// Returns an error naming the remaining goroutines if they are still running when ctx is done.
+// Calling Stop more than once is safe.
+func (node *Node) Stop(ctx context.Context) error {
+	dropped := node.Network.Close()
+	if dropped > 0 {
+		node.logger.Debug("dropped pending RPCs on shutdown", "pending", dropped)
+	}
+	err := node.routines.Wait(ctx)
+	if err != nil {
+		return errors.New(fmt.Sprintf("node %v failed to stop cleanly: %s", node.ID(), err.Error()))
+	}
+	return nil
+}
+
+// Returns true once the node has been told to stop.
+func (node *Node) Stopped() bool {
+	return node.Network.Closed()
+}
+
 // Wrapper for sending a rpc and also adding the responding contact.
+// Responses to batched requests are gathered into the batch's response instead of being sent.
 func (node *Node) Send(rpc RPC) (RPC, error) {
-	res, err := node.Network.Send(rpc)
+	if rpc.response && node.batches.deliver(rpc) {
+		return rpc, nil
+	}
+	res, err := node.transport.Send(rpc)
 	if err != nil {
 		// If the contact fails to respond and exists in the routing table, drop it.
 		con, ipErr := node.FindByIP(rpc.receiver)
 		if ipErr == nil {
 			node.RemoveContact(con)
+			node.Network.rtt.Forget(rpc.receiver)
 		}
 		return res, err
+	} else if res.cmd == UNSUPPORTED_VERSION {
+		return res, &VersionError{rpc.receiver, res.version, res.minVersion}
 	} else {
-		node.AddContact(res.sender)
+		node.learnContact(res.sender, res.role, res.caps)
+		if res.cmd == UNKNOWN_COMMAND {
+			return res, &UnknownCommandError{res.unknownCmd, rpc.receiver}
+		}
 		return res, nil
 	}
 }
 
 func (node *Node) Debug(mode bool) {
 	node.debug = mode
+	node.logLevel.Set(debugLevel(mode))
 	node.Network.Debug(mode)
 }
 
-func (node *Node) AddAccount(id [5]uint32) error {
+func (node *Node) AddAccount(id KademliaID) error {
 	err := node.scalegraph.AddAccount(id)
 	if err != nil {
 		return err
@@ -81,12 +217,31 @@ func (node *Node) AddAccount(id [5]uint32) error {
 	return nil
 }
 
+// Expires the response table's entries every timeout until the node stops, closing the channels
+// of requests that neither got a response nor were dropped by their sender.
+func (node *Node) expireResponses() {
+	ticker := node.timebase.NewTicker(node.config.Timeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-node.Network.listener.Done():
+			return
+		case <-ticker.C():
+			// counted by the metrics rather than warned about, outbound backpressure expires entries too
+			if expired := node.Network.expire(); expired > 0 {
+				node.Network.metrics.ResponsesExpired(expired)
+				node.logger.Debug("expired response waiters without a response", "expired", expired, "total", node.Network.ExpiredResponses())
+			}
+		}
+	}
+}
+
 func (node *Node) ClearDeadContacts() {
 	contacts := node.RoutingTable.AllContacts()
 	for _, con := range contacts {
-		go node.Ping(con.IP())
+		node.routines.Go("ping", func() { node.Ping(con.IP()) })
 	}
-	time.Sleep(TIMEOUT)
+	node.timebase.Sleep(node.config.Timeout)
 }
 
 func (node *Node) Display() string {
@@ -94,4 +249,3 @@ func (node *Node) Display() string {
 	res += node.RoutingTable.Display()
 	return res
 }
-
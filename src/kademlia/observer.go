@@ -0,0 +1,102 @@
+package kademlia
+
+import (
+	"sync"
+	"time"
+)
+
+// The part a node plays in the network.
+type Role int
+
+const (
+	FULL_NODE Role = iota // stores accounts and messages and validates transactions
+	OBSERVER              // joins and monitors the network but never stores data or validates
+)
+
+func (role Role) String() string {
+	switch role {
+	case FULL_NODE:
+		return "FULL_NODE"
+	case OBSERVER:
+		return "OBSERVER"
+	}
+	return "unknown role"
+}
+
+// Sets the role of the node, must be called before the node is started.
+// Every RPC carries its sender's role, nodes never add observers to their routing tables so
+// observers are never returned by lookups and never chosen as validators.
+func (net *Network) SetRole(role Role) {
+	net.role = role
+}
+
+func (net *Network) Role() Role {
+	return net.role
+}
+
+// Adds the sender of a RPC and the capabilities it advertised to the routing table, unless it
+// is an observer.
+func (node *Node) learnContact(sender Contact, role Role, caps Capability) {
+	if role == OBSERVER {
+		return
+	}
+	node.AddContact(sender)
+	node.learnCapabilities(sender.ID(), caps)
+}
+
+// What an observer has seen of the network.
+type Observations struct {
+	Received map[Command]int          // RPCs received, by command
+	Peers    map[KademliaID]time.Time // last time each peer was heard from
+	Contacts int                      // contacts in the routing table
+	Buckets  []int                    // contacts per bucket
+}
+
+type observerLog struct {
+	received map[Command]int
+	peers    map[KademliaID]time.Time
+	sync.Mutex
+}
+
+func newObserverLog() *observerLog {
+	return &observerLog{
+		received: make(map[Command]int),
+		peers:    make(map[KademliaID]time.Time),
+	}
+}
+
+func (obs *observerLog) record(rpc *RPC, now time.Time) {
+	obs.Lock()
+	defer obs.Unlock()
+	obs.received[rpc.cmd]++
+	obs.peers[rpc.sender.ID()] = now
+}
+
+// Returns what the node has observed so far, only observers record traffic.
+func (node *Node) Observations() Observations {
+	node.observations.Lock()
+	res := Observations{
+		Received: make(map[Command]int, len(node.observations.received)),
+		Peers:    make(map[KademliaID]time.Time, len(node.observations.peers)),
+	}
+	for c, n := range node.observations.received {
+		res.Received[c] = n
+	}
+	for id, seen := range node.observations.peers {
+		res.Peers[id] = seen
+	}
+	node.observations.Unlock()
+	res.Contacts = node.Len()
+	res.Buckets = node.BucketOccupancy()
+	return res
+}
+
+// Spawns an observer node, it joins like any other node but stores nothing.
+func (simnet *Simnet) SpawnObserver(done chan KademliaID) *Node {
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	newNode := simnet.generateNode(config, DEFAULT_NETWORK, KademliaID{}, OBSERVER)
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
+	return newNode
+}
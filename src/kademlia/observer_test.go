@@ -0,0 +1,57 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestObserverNode(t *testing.T) {
+	testName := "TestObserverNode"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(6, done)
+	<-done
+	joined := make(chan KademliaID, 1)
+	observer := s.SpawnObserver(joined)
+	observer.SetLogLevel(LOG_SILENT)
+	<-joined
+
+	if len(observer.AllContacts()) == 0 {
+		log.Printf("[%s] - observer did not populate its routing table", testName)
+		t.Fail()
+	}
+	accID := RandomID()
+	observer.StoreAccount(accID)
+	nodes[0].FindNode(observer.ID())
+	for _, n := range s.AllNodePointers() {
+		if n == observer {
+			continue
+		}
+		_, err := n.FindByIP(observer.IP())
+		if err == nil {
+			log.Printf("[%s] - node %v added the observer to its routing table", testName, n.IP())
+			t.Fail()
+		}
+	}
+	if observer.scalegraph.StoredAccountCount() != 0 {
+		log.Printf("[%s] - observer stored an account", testName)
+		t.Fail()
+	}
+	if _, err := nodes[1].Balance(accID); err != nil {
+		log.Printf("[%s] - account stored through the observer is missing: %s", testName, err.Error())
+		t.Fail()
+	}
+
+	observed := observer.Observations()
+	if len(observed.Peers) == 0 || observed.Received[PONG] == 0 || observed.Contacts == 0 {
+		log.Printf("[%s] - observer recorded nothing: %+v", testName, observed)
+		t.Fail()
+	}
+	if len(nodes[0].Observations().Received) != 0 {
+		log.Printf("[%s] - full node recorded observations", testName)
+		t.Fail()
+	}
+	s.Shutdown()
+}
@@ -0,0 +1,48 @@
+package kademlia
+
+import "sync"
+
+// Every RPC a node receives is routed on a goroutine of its own, which used to move a copy of the
+// RPC to the heap, every request made a fresh response channel and every sort of contacts made a
+// fresh slice of their distances. All three are recycled instead to keep the garbage collector
+// out of large simulations.
+var (
+	rpcPool      = sync.Pool{New: func() any { return new(RPC) }}
+	responsePool = sync.Pool{}
+	sortPool     = sync.Pool{New: func() any { return new([]distanceKey) }}
+)
+
+// A contact and its distance to the target of a sort, see SortContactsByDistance.
+type distanceKey struct {
+	dist    Distance
+	contact Contact
+}
+
+// Returns a pooled copy of rpc, hand it back with releaseRPC once nothing refers to it.
+func pooledRPC(rpc RPC) *RPC {
+	res := rpcPool.Get().(*RPC)
+	*res = rpc
+	return res
+}
+
+// Clears rpc so the pool does not keep its contacts and payloads alive, and returns it to the pool.
+func releaseRPC(rpc *RPC) {
+	*rpc = RPC{}
+	rpcPool.Put(rpc)
+}
+
+// Returns an empty response channel with the given buffer.
+func responseChan(buffer int) chan RPC {
+	if ch, ok := responsePool.Get().(chan RPC); ok && cap(ch) == buffer {
+		return ch
+	}
+	return make(chan RPC, buffer)
+}
+
+// Returns a response channel to the pool. Only channels that have delivered their response may
+// be released, a channel dropped on a timeout can still receive a late response.
+func releaseResponseChan(ch chan RPC) {
+	if len(ch) == 0 {
+		responsePool.Put(ch)
+	}
+}
@@ -0,0 +1,91 @@
+package kademlia
+
+import (
+	"log"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestPools(t *testing.T) {
+	testName := "TestPools"
+	rpc := GenerateRPC(RandomIP(), NewRandomContact())
+	rpc.FoundNodes(RandomID(), []Contact{NewRandomContact()})
+	pooled := pooledRPC(rpc)
+	if pooled.id != rpc.id || len(pooled.foundNodes) != 1 {
+		log.Printf("[%s] - pooled copy differs from the rpc", testName)
+		t.Fail()
+	}
+	releaseRPC(pooled)
+	if pooled.id != (KademliaID{}) || pooled.foundNodes != nil {
+		log.Printf("[%s] - released rpc still refers to its content", testName)
+		t.Fail()
+	}
+
+	for _, buffer := range []int{0, 1, 4} {
+		ch := responseChan(buffer)
+		if cap(ch) != buffer || len(ch) != 0 {
+			log.Printf("[%s] - expected an empty response channel with buffer %d, got %d of %d", testName, buffer, len(ch), cap(ch))
+			t.Fail()
+		}
+		releaseResponseChan(ch)
+	}
+	full := responseChan(1)
+	full <- rpc
+	releaseResponseChan(full)
+	for range 10 {
+		if ch := responseChan(1); len(ch) != 0 {
+			log.Printf("[%s] - a channel holding a late response was recycled", testName)
+			t.Fail()
+		}
+	}
+
+	// sorting reuses its scratch space, it must not leak between sorts of different lengths
+	contacts := make([]Contact, 0)
+	for range 20 {
+		contacts = append(contacts, NewRandomContact())
+	}
+	target := RandomID()
+	for _, count := range []int{20, 3, 11} {
+		sorted := slices.Clone(contacts[:count])
+		SortContactsByDistance(&sorted, target)
+		expected := slices.Clone(contacts[:count])
+		slices.SortFunc(expected, func(a Contact, b Contact) int {
+			return CompareDistance(RelativeDistance(a.ID(), target), RelativeDistance(b.ID(), target))
+		})
+		if !slices.Equal(sorted, expected) {
+			log.Printf("[%s] - sorting %d contacts after a longer sort gave the wrong order", testName, count)
+			t.Fail()
+		}
+	}
+}
+
+// Reports the allocations and garbage collector work per lookup in a cluster of size nodes, the
+// scale at which routed RPCs, response channels and sort scratch space are worth recycling.
+func benchmarkPoolsGCPressure(b *testing.B, size int) {
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(size, done)
+	<-done
+	defer s.Shutdown()
+
+	before := runtime.MemStats{}
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		nodes[rand.Intn(len(nodes))].FindNode(nodes[rand.Intn(len(nodes))].ID())
+	}
+	b.StopTimer()
+	after := runtime.MemStats{}
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gcs/op")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+	b.ReportMetric(float64(after.HeapInuse)/float64(len(nodes)), "heap-bytes/node")
+}
+
+func BenchmarkPoolsGCPressure1000(b *testing.B)  { benchmarkPoolsGCPressure(b, 1000) }
+func BenchmarkPoolsGCPressure10000(b *testing.B) { benchmarkPoolsGCPressure(b, 10000) }
@@ -0,0 +1,126 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"main/src/scalegraph"
+	"runtime"
+	"sync"
+)
+
+// A wallet to load directly into validators, see Simnet.PreloadWallets.
+type WalletSeed struct {
+	ID        KademliaID
+	PublicKey ed25519.PublicKey // nil for wallets accepting unsigned spends
+	Balance   uint64
+}
+
+// Generates count wallets with random ids and the given balance, none of them signed.
+func SyntheticWallets(count int, balance uint64) []WalletSeed {
+	res := make([]WalletSeed, count)
+	for i := range res {
+		res[i] = WalletSeed{ID: RandomID(), Balance: balance}
+	}
+	return res
+}
+
+// Stores the wallets in the node's own ledger without contacting any other node, whether or not
+// the node is one of their validators.
+// Returns an error counting the wallets that could not be stored, such as ones that already exist.
+func (node *Node) PreloadWallets(seeds []WalletSeed) error {
+	ledger := node.Ledger()
+	failed := 0
+	var first error
+	for _, seed := range seeds {
+		err := ledger.Submit(seed.ID, seed.PublicKey, 0, scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, seed.ID, seed.Balance))
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("%d of %d wallets not preloaded, first error: %v", failed, len(seeds), first))
+	}
+	return nil
+}
+
+// Stores every wallet directly at the K live nodes of the default network closest to
+// it, the validators a lookup would find in a converged network. No RPCs are sent, which makes
+// setting up storage-heavy experiments fast, but the wallets are only placed correctly for the
+// nodes that are live when they are loaded.
+// Returns an error counting the validators that failed to store some of their wallets.
+func (simnet *Simnet) PreloadWallets(seeds []WalletSeed) error {
+	validators := make([]*Node, 0)
+	for _, n := range simnet.AllNodePointers() {
+		if n.NetworkID() == DEFAULT_NETWORK && n.Role() != OBSERVER && !n.Stopped() {
+			validators = append(validators, n)
+		}
+	}
+	if len(validators) == 0 {
+		return errors.New("no validators to preload wallets into")
+	}
+	contacts := make([]Contact, len(validators))
+	byIP := make(map[[4]byte]int, len(validators))
+	for i, n := range validators {
+		contacts[i] = n.Contact
+		byIP[n.IP()] = i
+	}
+
+	// Place the seeds in parallel, each worker sorting its own copy of the contacts.
+	batches := make([][][]WalletSeed, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for w := range batches {
+		batches[w] = make([][]WalletSeed, len(validators))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			closest := make([]Contact, len(contacts))
+			for i := w; i < len(seeds); i += len(batches) {
+				copy(closest, contacts)
+				SortContactsByDistance(&closest, seeds[i].ID)
+				for _, con := range closest[:min(simnet.config.Replication, len(closest))] {
+					v := byIP[con.IP()]
+					batches[w][v] = append(batches[w][v], seeds[i])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// one error per validator at most, so that no send blocks before the channel is drained
+	errChan := make(chan error, len(validators))
+	for v, n := range validators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var first error
+			for w := range batches {
+				err := n.PreloadWallets(batches[w][v])
+				if err != nil && first == nil {
+					first = err
+				}
+			}
+			if first != nil {
+				errChan <- errors.New(fmt.Sprintf("node %v: %v", n.ID(), first))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errChan)
+	failed := 0
+	var first error
+	for err := range errChan {
+		failed++
+		if first == nil {
+			first = err
+		}
+	}
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("preloading failed on %d validators, first error: %v", failed, first))
+	}
+	simnet.logger.Info("preloaded wallets", "wallets", len(seeds), "validators", len(validators))
+	return nil
+}
@@ -0,0 +1,48 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestPreloadWallets(t *testing.T) {
+	testName := "TestPreloadWallets"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(29, done)
+	<-done
+	defer s.Shutdown()
+
+	seeds := SyntheticWallets(5000, 100)
+	err := s.PreloadWallets(seeds)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	stored := 0
+	for _, n := range s.AllNodePointers() {
+		stored += n.scalegraph.StoredAccountCount()
+	}
+	if stored != len(seeds)*REPLICATION {
+		log.Printf("[%s] - expected %d stored wallets, found %d", testName, len(seeds)*REPLICATION, stored)
+		t.Fail()
+	}
+	for _, seed := range seeds[:10] {
+		wallet, err := nodes[0].ShowWallet(seed.ID)
+		if err != nil || wallet.Balance != seed.Balance {
+			log.Printf("[%s] - preloaded wallet %v not found through a lookup: %v", testName, seed.ID, err)
+			t.Fail()
+		}
+	}
+	if s.PreloadWallets(seeds[:1]) == nil {
+		log.Printf("[%s] - preloading an existing wallet did not fail", testName)
+		t.Fail()
+	}
+	// every validator fails on several batches at once
+	if s.PreloadWallets(seeds) == nil {
+		log.Printf("[%s] - preloading existing wallets again did not fail", testName)
+		t.Fail()
+	}
+}
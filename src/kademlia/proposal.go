@@ -0,0 +1,208 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"main/src/scalegraph"
+	"sync"
+	"time"
+)
+
+const PROPOSAL_TTL = 4 * TIMEOUT // how long an accepted proposal holds its account before it is dropped
+
+// A transaction a validator has accepted and is waiting to commit.
+type proposal struct {
+	accID   KademliaID
+	trx     *scalegraph.Transaction
+	expires time.Time
+}
+
+// A transaction proposed for one of its wallets, a validator of both the sending and the
+// receiving wallet holds a proposal for each.
+type proposalKey struct {
+	trxID KademliaID
+	accID KademliaID
+}
+
+// Proposals accepted by a validator, keyed by transaction and wallet.
+// An account takes part in at most one open proposal at a time, which keeps the funds a
+// proposal was accepted against from being promised to a concurrent one.
+type proposalTable struct {
+	content map[proposalKey]proposal
+	sync.Mutex
+}
+
+func newProposalTable() *proposalTable {
+	return &proposalTable{
+		content: make(map[proposalKey]proposal),
+	}
+}
+
+// Holds the transaction for accID for ttl, returns an error if another open proposal holds the account.
+func (table *proposalTable) hold(accID KademliaID, trx *scalegraph.Transaction, now time.Time, ttl time.Duration) error {
+	table.Lock()
+	defer table.Unlock()
+	for key, prop := range table.content {
+		if now.After(prop.expires) {
+			delete(table.content, key)
+			continue
+		}
+		if prop.accID == accID && key.trxID != KademliaID(trx.ID()) {
+			return errors.New(fmt.Sprintf("account %v is held by proposal %v", accID, key.trxID))
+		}
+	}
+	table.content[proposalKey{trx.ID(), accID}] = proposal{accID, trx, now.Add(ttl)}
+	return nil
+}
+
+// Removes and returns the open proposal for the transaction on accID.
+func (table *proposalTable) release(trxID KademliaID, accID KademliaID, now time.Time) (proposal, bool) {
+	table.Lock()
+	defer table.Unlock()
+	key := proposalKey{trxID, accID}
+	prop, ok := table.content[key]
+	delete(table.content, key)
+	if ok && now.After(prop.expires) {
+		return prop, false
+	}
+	return prop, ok
+}
+
+// Moves the funds of trx between wallets in two phases. The transaction is proposed to the
+// validators of the sending and the receiving wallet, each of which checks it against its copy
+// of the wallet and accepts or rejects it. If enough validators of both groups accept for the
+// node's write consistency, a majority by default, the transaction is committed and the
+// validators apply the balance change, otherwise it is aborted. For wallets stored with a
+// replication of their own the consistency counts over that many validators, as reported by
+// those that accept, and only they are asked to commit.
+// Transfers from scalegraph.MINT_ACCOUNT are only proposed to the receiving validators.
+func (node *Node) ProposeTransaction(trx *scalegraph.Transaction) error {
+	wallets := []KademliaID{trx.Receiver()}
+	if trx.Sender() != scalegraph.MINT_ACCOUNT {
+		wallets = []KademliaID{trx.Sender(), trx.Receiver()}
+	}
+	groups := make([][]Contact, len(wallets))
+	for i, accID := range wallets {
+		groups[i] = node.FindNode(accID)
+	}
+
+	level := node.config.WriteConsistency
+	accepted := true
+	var err error
+	for i, accID := range wallets {
+		votes, replication := node.transactionRound(groups[i], func(rpc *RPC) { rpc.ProposeTransaction(accID, *trx.Copy()) })
+		groups[i] = node.walletValidators(groups[i], accID, replication)
+		required := level.Required(len(groups[i]))
+		if votes < required {
+			accepted = false
+			err = failure(ErrQuorumNotReached, "transaction %v accepted by %d of %d validators for wallet: %v, %d required", trx.ID(), votes, len(groups[i]), accID, required)
+			break
+		}
+	}
+
+	for i, accID := range wallets {
+		required := level.Required(len(groups[i]))
+		commits, _ := node.transactionRound(groups[i], func(rpc *RPC) { rpc.CommitTransaction(accID, *trx.Copy(), accepted) })
+		if accepted && commits < required {
+			return failure(ErrQuorumNotReached, "transaction %v committed by %d of %d validators for wallet: %v, %d required", trx.ID(), commits, len(groups[i]), accID, required)
+		}
+	}
+	return err
+}
+
+// Proposes the transactions concurrently and returns the outcome of each, in input order.
+// Meant for tests that submit conflicting spends, such as two transfers from one wallet with the
+// same nonce, and observe which of them wins. At most one of a set of conflicting transactions
+// commits, and none does if the validators split their votes between them.
+func (node *Node) RaceTransactions(trxs ...*scalegraph.Transaction) []error {
+	res := make([]error, len(trxs))
+	var wg sync.WaitGroup
+	for i, trx := range trxs {
+		wg.Add(1)
+		node.routines.Go("race transaction", func() {
+			defer wg.Done()
+			res[i] = node.ProposeTransaction(trx)
+		})
+	}
+	wg.Wait()
+	return res
+}
+
+// Sends a RPC built by build to every validator in parallel and counts the positive responses.
+// Returns the count and the wallet replication reported by the validators that responded positively.
+func (node *Node) transactionRound(validators []Contact, build func(rpc *RPC)) (int, int) {
+	respChan := make(chan RPC, len(validators))
+	for _, val := range validators {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		build(&rpc)
+		node.routines.Go("transaction round", func() {
+			res, err := node.Send(rpc)
+			if err != nil {
+				res.accepted = false
+			}
+			respChan <- res
+		})
+	}
+	votes, replication := 0, 0
+	for range validators {
+		if res := <-respChan; res.accepted {
+			votes++
+			replication = max(replication, res.replication)
+		}
+	}
+	return votes, replication
+}
+
+// Response logic for an incoming propose transaction RPC, the validator votes by accepting or
+// rejecting the transaction against its copy of the wallet.
+func (node *Node) handleProposeTransaction(rpc *RPC) {
+	trx := rpc.transaction.Copy()
+	var err error
+	if node.Role() == OBSERVER {
+		err = errors.New("observers do not validate transactions")
+	} else {
+		err = node.scalegraph.CheckSignature(rpc.accountID, trx)
+	}
+	if err == nil {
+		err = node.scalegraph.CheckTransaction(rpc.accountID, trx)
+	}
+	if err == nil {
+		err = node.proposals.hold(rpc.accountID, trx, node.Now(), node.config.scaled(PROPOSAL_TTL))
+	}
+	if err != nil {
+		node.logger.Debug("rejected proposal", "rpc", rpc.id, "transaction", trx.ID(), "wallet", rpc.accountID, "err", err)
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.AcceptTransaction(rpc.accountID, trx.ID(), err == nil)
+	resp.replication = node.heldReplication(rpc.accountID)
+	node.Send(resp)
+}
+
+// Response logic for an incoming commit transaction RPC. Committed transactions are applied to
+// the wallet, aborted ones release the wallet. Only a transaction the validator voted for is
+// committed, and it is the copy the validator accepted that is applied, so a commit can neither
+// skip the vote nor change what was voted on.
+func (node *Node) handleCommitTransaction(rpc *RPC) {
+	trx := rpc.transaction.Copy()
+	prop, held := node.proposals.release(trx.ID(), rpc.accountID, node.Now())
+	var err error
+	if !rpc.commit {
+		err = errors.New("transaction aborted")
+	} else if node.Role() == OBSERVER {
+		err = errors.New("observers do not validate transactions")
+	} else if !held {
+		err = errors.New(fmt.Sprintf("no open proposal for transaction %v on wallet %v", trx.ID(), rpc.accountID))
+		node.logger.Warn("refused to commit transaction", "rpc", rpc.id, "transaction", trx.ID(), "err", err)
+	} else {
+		trx = prop.trx
+		err = node.scalegraph.ApplyTransaction(rpc.accountID, trx)
+		if err != nil {
+			node.logger.Warn("failed to commit transaction", "rpc", rpc.id, "transaction", trx.ID(), "err", err)
+		} else {
+			node.notifyWatchers(rpc.accountID)
+		}
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.CommittedTransaction(rpc.accountID, trx.ID(), err == nil)
+	node.Send(resp)
+}
@@ -0,0 +1,241 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"log"
+	"main/src/scalegraph"
+	"testing"
+	"time"
+)
+
+func TestProposeTransaction(t *testing.T) {
+	testName := "TestProposeTransaction"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	from := KademliaID(scalegraph.RandomID())
+	to := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(from, 100); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	if err := nodes[0].SubmitWallet(to, 0); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+
+	err := nodes[4].ProposeTransaction(scalegraph.NewTransferWithNonce(from, to, 60, 1))
+	if err != nil {
+		log.Printf("[%s] - transaction was not committed: %v", testName, err)
+		t.FailNow()
+	}
+	err = nodes[7].ProposeTransaction(scalegraph.NewTransferWithNonce(from, to, 60, 2))
+	if err == nil {
+		log.Printf("[%s] - overdrawing transaction was committed", testName)
+		t.Fail()
+	}
+	err = nodes[9].ProposeTransaction(scalegraph.NewTransferWithNonce(scalegraph.MINT_ACCOUNT, to, 5, 1))
+	if err != nil {
+		log.Printf("[%s] - mint transaction was not committed: %v", testName, err)
+		t.Fail()
+	}
+
+	for _, n := range s.AllNodePointers() {
+		if wallet, err := n.Ledger().Wallet(from); err == nil && wallet.Balance != 40 {
+			log.Printf("[%s] - validator %v holds sender balance %d", testName, n.IP(), wallet.Balance)
+			t.Fail()
+		}
+		if wallet, err := n.Ledger().Wallet(to); err == nil && wallet.Balance != 65 {
+			log.Printf("[%s] - validator %v holds receiver balance %d", testName, n.IP(), wallet.Balance)
+			t.Fail()
+		}
+	}
+}
+
+func TestProposalHoldsAccount(t *testing.T) {
+	testName := "TestProposalHoldsAccount"
+	table := newProposalTable()
+	accID := KademliaID(scalegraph.RandomID())
+	first := scalegraph.NewTransfer(accID, scalegraph.RandomID(), 1)
+	second := scalegraph.NewTransfer(accID, scalegraph.RandomID(), 1)
+	if err := table.hold(accID, first, time.Now(), PROPOSAL_TTL); err != nil {
+		log.Printf("[%s] - failed to hold free account: %v", testName, err)
+		t.Fail()
+	}
+	if err := table.hold(accID, first, time.Now(), PROPOSAL_TTL); err != nil {
+		log.Printf("[%s] - repeated proposal was rejected: %v", testName, err)
+		t.Fail()
+	}
+	if err := table.hold(accID, second, time.Now(), PROPOSAL_TTL); err == nil {
+		log.Printf("[%s] - concurrent proposal was accepted", testName)
+		t.Fail()
+	}
+	if _, ok := table.release(first.ID(), accID, time.Now()); !ok {
+		log.Printf("[%s] - held proposal was not found", testName)
+		t.Fail()
+	}
+	if err := table.hold(accID, second, time.Now(), PROPOSAL_TTL); err != nil {
+		log.Printf("[%s] - released account is still held: %v", testName, err)
+		t.Fail()
+	}
+}
+
+func TestCommitRequiresProposal(t *testing.T) {
+	testName := "TestCommitRequiresProposal"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	to := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(to, 0); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	// a commit sent without a vote must not mint funds at any validator
+	trx := scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, to, 1000)
+	for _, val := range nodes[3].FindNode(to) {
+		rpc := GenerateRPC(val.IP(), nodes[3].Contact)
+		rpc.CommitTransaction(to, *trx.Copy(), true)
+		if res, err := nodes[3].Send(rpc); err == nil && res.accepted {
+			log.Printf("[%s] - %v committed a transaction it never voted for", testName, val.IP())
+			t.Fail()
+		}
+	}
+	for _, n := range s.AllNodePointers() {
+		if wallet, err := n.Ledger().Wallet(to); err == nil && wallet.Balance != 0 {
+			log.Printf("[%s] - validator %v holds balance %d", testName, n.IP(), wallet.Balance)
+			t.Fail()
+		}
+	}
+}
+
+func TestProposeRequiresSignature(t *testing.T) {
+	testName := "TestProposeRequiresSignature"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, forger, _ := ed25519.GenerateKey(nil)
+	from := NewKeyFromData(pub)
+	to := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWalletWithKey(from, pub, 100); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	if err := nodes[0].SubmitWallet(to, 0); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+
+	forged := scalegraph.NewTransferWithNonce(from, to, 50, 1)
+	forged.Sign(forger)
+	if nodes[3].ProposeTransaction(forged) == nil {
+		log.Printf("[%s] - forged transaction was committed", testName)
+		t.Fail()
+	}
+	if nodes[3].Transfer(from, to, 50) == nil {
+		log.Printf("[%s] - unsigned append was accepted", testName)
+		t.Fail()
+	}
+	// a forged commit sent straight to the validators is refused even where a proposal for it is
+	// held, applying the transaction checks its signature as well
+	for _, val := range s.AllNodePointers() {
+		if _, err := val.Ledger().Wallet(from); err != nil {
+			continue
+		}
+		val.proposals.hold(from, forged.Copy(), val.Now(), PROPOSAL_TTL)
+		rpc := GenerateRPC(val.IP(), nodes[3].Contact)
+		rpc.CommitTransaction(from, *forged.Copy(), true)
+		if res, err := nodes[3].Send(rpc); err == nil && res.accepted {
+			log.Printf("[%s] - %v committed a forged transaction", testName, val.IP())
+			t.Fail()
+		}
+		if wallet, _ := val.Ledger().Wallet(from); wallet.Balance != 100 {
+			log.Printf("[%s] - %v holds balance %d after a forged commit", testName, val.IP(), wallet.Balance)
+			t.Fail()
+		}
+	}
+	signed := scalegraph.NewTransferWithNonce(from, to, 50, 1)
+	signed.Sign(priv)
+	if err := nodes[3].ProposeTransaction(signed); err != nil {
+		log.Printf("[%s] - signed transaction was rejected: %v", testName, err)
+		t.Fail()
+	}
+	wallet, err := nodes[6].ShowWallet(from)
+	if err != nil || wallet.Balance != 50 || !wallet.PublicKey.Equal(pub) {
+		log.Printf("[%s] - expected balance 50 under the wallet key, got %+v: %v", testName, wallet, err)
+		t.Fail()
+	}
+}
+
+func TestProposeNonces(t *testing.T) {
+	testName := "TestProposeNonces"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	from := KademliaID(scalegraph.RandomID())
+	to := KademliaID(scalegraph.RandomID())
+	nodes[0].SubmitWallet(from, 100)
+	nodes[0].SubmitWallet(to, 0)
+
+	if nodes[1].ProposeTransaction(scalegraph.NewTransferWithNonce(from, to, 10, 2)) == nil {
+		log.Printf("[%s] - out of order nonce was committed", testName)
+		t.Fail()
+	}
+	first := scalegraph.NewTransferWithNonce(from, to, 10, 1)
+	if err := nodes[1].ProposeTransaction(first); err != nil {
+		log.Printf("[%s] - first nonce was rejected: %v", testName, err)
+		t.FailNow()
+	}
+	if nodes[2].ProposeTransaction(scalegraph.NewTransferWithNonce(from, to, 10, 1)) == nil {
+		log.Printf("[%s] - reused nonce was committed", testName)
+		t.Fail()
+	}
+	if nodes[2].ProposeTransaction(first) == nil {
+		log.Printf("[%s] - replayed transaction was committed", testName)
+		t.Fail()
+	}
+
+	errs := nodes[3].RaceTransactions(
+		scalegraph.NewTransferWithNonce(from, to, 80, 2),
+		scalegraph.NewTransferWithNonce(from, to, 70, 2),
+	)
+	committed := 0
+	for _, err := range errs {
+		if err == nil {
+			committed++
+		}
+	}
+	if committed > 1 {
+		log.Printf("[%s] - both conflicting transactions were committed", testName)
+		t.Fail()
+	}
+	wallet, err := nodes[4].ShowWallet(from)
+	if err != nil || wallet.Nonce != uint64(1+committed) {
+		log.Printf("[%s] - expected nonce %d, got %+v: %v", testName, 1+committed, wallet, err)
+		t.Fail()
+	}
+	if committed == 0 && wallet.Balance != 90 {
+		log.Printf("[%s] - expected balance 90 after both conflicting spends lost, got %d", testName, wallet.Balance)
+		t.Fail()
+	}
+}
@@ -3,35 +3,95 @@ package kademlia
 import (
 	"errors"
 	"fmt"
-	"log"
+	"math/rand"
+	"slices"
+	"time"
 )
 
 // Protocol handles the logic for sending RPC's
 
 // Critical in order to reduce the risk of dead networks on start up.
 // A dead network occurs when one or more nodes know of the network but is not known of by the network.
-func (node *Node) Enter() {
-	rpc := GenerateRPC(node.IP(), node.Contact)
-	rpc.Enter()
-	res, err := node.Send(rpc)
+// Entry points are requested from the node's bootstrapper, if it fails or returns no usable entry
+// points the request is retried with exponential backoff. Once the node has announced itself to
+// the entry points it checks that a random peer can find it.
+// Returns an error if the node could not join or is not discoverable after joining.
+func (node *Node) Enter() error {
+	entries, err := node.requestEntry()
 	if err != nil {
-		log.Printf("%v - {ENTER} did not receive entry point", node.ID())
-		return
+		return err
 	}
-	if len(res.foundNodes) == 0 {
-		log.Printf("[PANIC] - nil error prevented")
+	reached := 0
+	for _, con := range entries {
+		if node.Ping(con.IP()) {
+			reached++
+		}
 	}
-	if res.foundNodes[0].IP() == [4]byte{0, 0, 0, 0} {
-		log.Printf("%v - {ENTER} received illegal entry point", node.ID())
+	if reached == 0 {
+		return failure(ErrIllegalEntryPoint, "none of %d entry points answered", len(entries))
 	}
-	entryNode := res.foundNodes[0]
-	branchNode := res.foundNodes[1]
-	node.Ping(entryNode.IP())
-	node.Ping(node.masterNode.IP())
 
 	node.FindNode(node.Contact.ID())
-	node.FindNode(branchNode.ID())
-	node.FindNode(node.masterNode.ID())
+	for _, con := range entries {
+		node.FindNode(con.ID())
+	}
+	return node.verifyDiscoverable()
+}
+
+// Requests entry points from the bootstrapper until it returns at least one usable contact.
+// Returns the distinct entry points, or an error once ENTER_ATTEMPTS requests have failed or right
+// away if the join was rejected.
+func (node *Node) requestEntry() ([]Contact, error) {
+	for attempt := 1; ; attempt++ {
+		found, err := node.bootstrap.EntryPoints(node)
+		if err == nil {
+			entries := make([]Contact, 0, len(found))
+			for _, con := range found {
+				if con.IP() == [4]byte{0, 0, 0, 0} || con.ID() == node.ID() || slices.Contains(entries, con) {
+					continue
+				}
+				entries = append(entries, con)
+			}
+			if len(entries) > 0 {
+				return entries, nil
+			}
+			err = failure(ErrIllegalEntryPoint, "received no usable entry points out of %d", len(found))
+		}
+		if errors.Is(err, ErrJoinRejected) {
+			return nil, err
+		}
+		if attempt == ENTER_ATTEMPTS || node.Stopped() {
+			return nil, fmt.Errorf("{ENTER} failed after %d attempts: %w", attempt, err)
+		}
+		backoff := node.config.enterBackoff(attempt)
+		node.logger.Warn("{ENTER} retrying", "attempt", attempt, "backoff", backoff, "err", err)
+		node.publish(EnterRetried{node.Contact, attempt, backoff})
+		select {
+		case <-node.timebase.After(backoff):
+		case <-node.Network.listener.Done():
+			return nil, ErrShutdown
+		}
+	}
+}
+
+// Asks random peers from the routing table to look up the node, up to ENTER_VERIFY_ATTEMPTS of them.
+// Returns an error if none of them knows of the node.
+func (node *Node) verifyDiscoverable() error {
+	peers := node.AllContacts()
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	for _, peer := range peers[:min(ENTER_VERIFY_ATTEMPTS, len(peers))] {
+		rpc := GenerateRPC(peer.IP(), node.Contact)
+		rpc.FindNode(node.ID())
+		res, err := node.Send(rpc)
+		if err != nil {
+			continue
+		}
+		if slices.ContainsFunc(res.foundNodes, func(con Contact) bool { return con.ID() == node.ID() }) {
+			return nil
+		}
+		node.logger.Debug("{ENTER} peer does not know of the node", "peer", peer.ID())
+	}
+	return errors.New(fmt.Sprintf("node %v is not discoverable from %d peers", node.ID(), min(ENTER_VERIFY_ATTEMPTS, len(peers))))
 }
 
 // Logic for sending a ping RPC.
@@ -40,111 +100,232 @@ func (node *Node) Ping(address [4]byte) bool {
 	rpc.Ping()
 	res, err := node.Send(rpc)
 	if err != nil {
-		if node.debug {
-			log.Printf("%v - [ERROR] RPC %v %s", node.ID(), rpc.id, err.Error())
-		}
+		node.logger.Debug("ping failed", "rpc", rpc.id, "receiver", address, "err", err)
 		return false
 	} else {
-		node.AddContact(res.sender)
+		node.learnContact(res.sender, res.role, res.caps)
 		return true
 	}
 }
 
-func (node *Node) FindNode(target [5]uint32) []Contact {
-	initNodes, _ := node.FindXClosest(REPLICATION, target)
-	found := node.findNodeLoop(initNodes, target)
+func (node *Node) FindNode(target KademliaID) []Contact {
+	found, _ := node.FindNodeWithStats(target)
 	return found
 }
 
-func (node *Node) findNodeLoop(prevContactList []Contact, target [5]uint32) []Contact {
-	contactList := make([]Contact, 0, REPLICATION)
-	respChan := make(chan []Contact, 64)
+// Statistics of a single node lookup.
+type LookupStats struct {
+	Hops     int // longest chain of queries, each one to a contact learned from the previous
+	RPCs     int // find node queries sent
+	Failed   int // queries that failed or timed out
+	Duration time.Duration
+}
 
-	for {
-		// Launch parallel queries to initial nodes.
-		for _, n := range prevContactList {
-			rpc := GenerateRPC(n.IP(), node.Contact)
-			rpc.FindNode(target)
-			go node.findNodeQuery(rpc, respChan)
-		}
-
-		// Extract results from parallel query.
-		for range prevContactList {
-			resp, ok := <-respChan
-			if ok {
-				contactList = append(contactList, resp...)
-			}
+// Runs a node lookup for target, see FindNode, and returns statistics of the lookup.
+// A result served from the lookup cache, see SetLookupCache, sends no RPCs.
+func (node *Node) FindNodeWithStats(target KademliaID) ([]Contact, LookupStats) {
+	start := node.timebase.Now()
+	if found, ok := node.lookupCache.get(target, start); ok {
+		return found, LookupStats{Duration: node.timebase.Since(start)}
+	}
+	initNodes, _ := node.FindXClosest(node.config.Replication, target)
+	found, stats := node.findNodeLoop(initNodes, target)
+	stats.Duration = node.timebase.Since(start)
+	node.completeLookup(target, found, stats)
+	node.lookupCache.put(target, found, node.timebase.Now())
+	return found, stats
+}
+
+// Records a completed node lookup in the node's metrics, routing table and events.
+func (node *Node) completeLookup(target KademliaID, found []Contact, stats LookupStats) {
+	node.metrics.LookupCompleted(stats.Hops, stats.Duration)
+	node.lookupHops.Observe(stats.Hops)
+	node.lookedUp(target)
+	node.publish(LookupCompleted{node.Contact, target, stats.Hops, stats.RPCs, found})
+}
+
+// A contact on the shortlist of a node lookup.
+type lookupCandidate struct {
+	contact Contact
+	depth   int // queries that led to the contact, zero for contacts from the routing table
+	queried bool
+	pending bool
+	foreign bool // queried by another path of a disjoint lookup, never queried by this one
+}
+
+// Result of a query sent during a lookup.
+type lookupResponse struct {
+	id     KademliaID
+	found  []Contact
+	value  []byte // set if the queried node holds the value, only for value lookups
+	cached bool   // the value was served from the queried node's cache
+	err    error
+}
+
+// Outcome of an iterative lookup.
+type lookupResult struct {
+	contacts []Contact // closest contacts that answered, or are yet to be queried if the lookup ended early
+	value    []byte
+	cached   bool
+	holder   Contact  // node that returned the value
+	miss     *Contact // closest node that answered without the value, nil if there is none
+	stats    LookupStats
+}
+
+// Lookup of the K contacts closest to target, starting from initNodes, K being the node's
+// replication. Recursive if the node's lookups are, see SetRecursiveLookups, and iterative
+// otherwise or if the recursive lookup fails, its RPCs then count towards the iterative one.
+func (node *Node) findNodeLoop(initNodes []Contact, target KademliaID) ([]Contact, LookupStats) {
+	failed := LookupStats{}
+	if node.recursive.Load() {
+		found, report, err := node.recursiveLookup(initNodes, target)
+		if err == nil {
+			return found, report.Stats
 		}
+		node.logger.Debug("recursive lookup failed, looking up iteratively", "target", target, "err", err)
+		failed = report.Stats
+	}
+	var found []Contact
+	var stats LookupStats
+	if paths := node.LookupPaths(); paths > 1 {
+		var report DisjointLookup
+		found, report = node.disjointLookup(initNodes, target, paths)
+		stats = report.total()
+	} else {
+		res := node.lookup(initNodes, target, func(con Contact) lookupResponse { return node.findNodeQuery(con, target) })
+		found, stats = res.contacts, res.stats
+	}
+	stats.Hops += failed.Hops
+	stats.RPCs += failed.RPCs
+	stats.Failed += failed.Failed
+	return found, stats
+}
+
+// Iterative lookup of target. The lookup keeps a shortlist of the K closest contacts seen so far
+// and queries the closest unqueried ones, with at most alpha queries in flight, K and alpha being
+// the node's replication and concurrency.
+// Contacts that fail to answer are dropped from the shortlist. The lookup ends once every contact
+// on the shortlist has answered, or as soon as a query returns a value.
+func (node *Node) lookup(initNodes []Contact, target KademliaID, query func(con Contact) lookupResponse) lookupResult {
+	return node.lookupPath(initNodes, target, query, nil)
+}
 
-		// Process the found contacts
-		SortContactsByDistance(&contactList, target)
-		RemoveDuplicateContacts(&contactList)
-		if len(contactList) > CONCURRENCY {
-			contactList = contactList[:REPLICATION]
+// Lookup as described for lookup, a contact is only queried if claim returns true for it.
+// A nil claim allows every contact.
+func (node *Node) lookupPath(initNodes []Contact, target KademliaID, query func(con Contact) lookupResponse, claim func(id KademliaID) bool) lookupResult {
+	res := lookupResult{}
+	candidates := make(map[KademliaID]*lookupCandidate)
+	shortlist := make([]Contact, 0, node.config.Replication)
+	add := func(con Contact, depth int) {
+		if _, seen := candidates[con.ID()]; seen {
+			return
+		}
+		// The node's own routing table seeded the lookup, so it is never queried.
+		candidates[con.ID()] = &lookupCandidate{contact: con, depth: depth, queried: con.ID() == node.ID()}
+		shortlist = append(shortlist, con)
+	}
+	trim := func() {
+		SortContactsByDistance(&shortlist, target)
+		if len(shortlist) > node.config.Replication {
+			shortlist = shortlist[:node.config.Replication]
 		}
+	}
+	for _, con := range initNodes {
+		add(con, 0)
+	}
+	trim()
 
-		if node.debug {
-			pRes := fmt.Sprintf("found nodes:\n")
-			for _, n := range contactList {
-				pRes += fmt.Sprintf("%s\n", n.Display())
+	respChan := make(chan lookupResponse, node.config.Concurrency)
+	inFlight := 0
+	for {
+		res.contacts = shortlist
+		// Abandon the lookup if the node is shutting down.
+		if node.Stopped() {
+			return res
+		}
+		eligible := func(con Contact) bool {
+			cand := candidates[con.ID()]
+			return !cand.queried && !cand.pending && !cand.foreign
+		}
+		for _, con := range node.queryOrder(shortlist, eligible) {
+			if inFlight == node.config.Concurrency {
+				break
+			}
+			cand := candidates[con.ID()]
+			if !eligible(con) {
+				continue
 			}
-			pRes += fmt.Sprintf("input nodes [DEBUG]:\n")
-			for _, n := range prevContactList {
-				pRes += fmt.Sprintf("%s\n", n.Display())
+			if claim != nil && !claim(con.ID()) {
+				cand.foreign = true
+				continue
 			}
-			log.Printf(pRes)
+			cand.pending = true
+			inFlight++
+			res.stats.RPCs++
+			res.stats.Hops = max(res.stats.Hops, cand.depth+1)
+			node.routines.Go("lookup query", func() {
+				resp := query(con)
+				resp.id = con.ID()
+				respChan <- resp
+			})
+		}
+		if inFlight == 0 {
+			return res
 		}
 
-		// If none of the new contacts are closer to the target than in the previous itteration
-		// return the previous itterations contact list.
-		if len(contactList) > 0 && len(prevContactList) > 0 {
-			sameDist := 0
-			for i := range contactList {
-				if i == len(prevContactList) {
+		resp := <-respChan
+		inFlight--
+		cand := candidates[resp.id]
+		cand.pending = false
+		if resp.err != nil {
+			res.stats.Failed++
+			i := slices.IndexFunc(shortlist, func(con Contact) bool { return con.ID() == resp.id })
+			if i != -1 {
+				shortlist = slices.Delete(shortlist, i, i+1)
+			}
+			continue
+		}
+		cand.queried = true
+		if resp.value != nil {
+			res.value = resp.value
+			res.cached = resp.cached
+			res.holder = cand.contact
+			for _, con := range shortlist {
+				if candidates[con.ID()].queried && con.ID() != resp.id && con.ID() != node.ID() {
+					res.miss = &con
 					break
 				}
-				closer := CloserNode(contactList[i].ID(), prevContactList[i].ID(), target)
-				if !closer {
-					sameDist++
-				}
-			}
-			if len(contactList) == sameDist {
-				return prevContactList
 			}
-		} else if len(contactList) == 0 {
-			// If there are no new contacts in the new contact list, return the previous contact list.
-			return prevContactList
+			return res
 		}
-		prevContactList = nil
-		prevContactList = contactList
-		contactList = make([]Contact, 0, REPLICATION)
+		for _, con := range resp.found {
+			add(con, cand.depth+1)
+		}
+		trim()
+		node.logger.Debug("lookup response", "target", target, "from", resp.id, "found", len(resp.found), "rpcs", res.stats.RPCs)
 	}
 }
 
-// Sends the given RPC and returns the reponse to the provided channel.
-// If the RPC times out or returns an error, returns an empty contact.
-// NOTE that you must assert the type of the result from respChan.
-func (node *Node) findNodeQuery(rpc RPC, respChan chan []Contact) {
+// Sends a find node query for target to con and returns the contacts in the response, asking for
+// the node's configured response size. The found contacts are pinged so that the node learns of them.
+func (node *Node) findNodeQuery(con Contact, target KademliaID) lookupResponse {
+	rpc := GenerateRPC(con.IP(), node.Contact)
+	rpc.FindNodeLimit(target, node.config.ResponseSize)
 	resp, err := node.Send(rpc)
 	if err != nil {
-		if node.debug {
-			log.Printf("[ERROR] - %s\nin node %v with rpc:\n%s\n", err.Error(), node.ID(), rpc.Display())
-		}
-		respChan <- resp.foundNodes
-		return
+		node.logger.Debug("find node query failed", "rpc", rpc.id, "receiver", rpc.receiver, "err", err)
+		return lookupResponse{err: err}
 	}
 	for _, n := range resp.foundNodes {
-		go node.Ping(n.IP())
+		node.routines.Go("ping", func() { node.Ping(n.IP()) })
 	}
-	respChan <- resp.foundNodes
-	return
-
+	return lookupResponse{found: resp.foundNodes}
 }
 
-func (node *Node) InsertAccount(accID [5]uint32) {
+func (node *Node) InsertAccount(accID KademliaID) {
 	isnertionPoint := node.FindNode(accID)
 	if len(isnertionPoint) == 0 {
-		log.Printf("failed to insert account %10v", accID)
+		node.logger.Error("failed to insert account", "account", accID)
 	} else {
 		rpc := GenerateRPC(isnertionPoint[0].IP(), node.Contact)
 		rpc.InsertAccount(accID)
@@ -152,54 +333,196 @@ func (node *Node) InsertAccount(accID [5]uint32) {
 	}
 }
 
-// Searches for the closest nodes to the account and sends a store account RPC to them.
-func (node *Node) StoreAccount(accID [5]uint32) {
+// Searches for the closest nodes to the account and sends a store account RPC to them in parallel.
+// Returns an error unless enough of them stored the account for the node's write consistency.
+func (node *Node) StoreAccount(accID KademliaID) error {
 	validators := node.FindNode(accID)
-	// validators = append(validators, node.Contact)
-	// SortContactsByDistance(&validators, accID)
-	for _, n := range validators {
+	respChan := make(chan bool, len(validators))
+	for _, n := range node.OrderByLatency(validators) {
 		rpc := GenerateRPC(n.IP(), node.Contact)
 		rpc.StoreAccount(accID)
-		node.Send(rpc)
+		node.routines.Go("store account", func() {
+			res, err := node.Send(rpc)
+			respChan <- err == nil && res.storeAccSucc
+		})
 	}
+	stored := 0
+	for range validators {
+		if <-respChan {
+			stored++
+		}
+	}
+	required := node.config.WriteConsistency.Required(len(validators))
+	if stored < required {
+		return failure(ErrQuorumNotReached, "account %v stored by %d of %d validators, %d required", accID, stored, len(validators), required)
+	}
+	return nil
 }
 
-func (node *Node) FindAccount(accID [5]uint32) ([]Contact, error) {
-	closeNodes := node.FindNode(accID)
-	respChan := make(chan bool, REPLICATION)
-	for _, n := range closeNodes {
-		node.findAccountQuery(n.IP(), respChan, accID)
+// Asks each of the account's validators in parallel whether it holds the account.
+// Returns the validators holding it and those that do not or did not answer, both in order of
+// latency, or an error if too few hold it for the node's read consistency. Accounts stored with a
+// replication of their own are only looked for at that many validators, see
+// SubmitWalletWithReplication.
+// Validators that answer without the account are asked to catch up from the first holder to
+// answer before the quorum is checked. That repair is best-effort: validators that catch up count
+// as holders and the others stay missing, which of them were repaired is only reported by a
+// WalletRepaired event.
+func (node *Node) FindAccount(accID KademliaID) (holders []Contact, missing []Contact, err error) {
+	return node.findAccount(accID, node.config.ReadConsistency)
+}
+
+// Looks up the account like FindAccount, with an error if too few validators hold it for level.
+func (node *Node) findAccount(accID KademliaID, level Consistency) (holders []Contact, missing []Contact, err error) {
+	validators, replies := node.queryAccount(accID)
+	answers := make(map[Contact]accountReply, len(validators))
+	replication := 0
+	for range validators {
+		reply := <-replies
+		answers[reply.contact] = reply
+		replication = max(replication, reply.replication)
 	}
-	foundAccountNodes := 0
-	for range len(closeNodes) {
-		_, ok := <-respChan
-		if !ok {
-			continue
+	validators = node.walletValidators(validators, accID, replication)
+	found := make(map[Contact]bool, len(validators))
+	stale := make([]Contact, 0)
+	var source *Contact
+	for _, val := range validators {
+		reply := answers[val]
+		found[val] = reply.found
+		if reply.found && source == nil {
+			source = &reply.contact
+		} else if !reply.found && reply.reachable {
+			stale = append(stale, val)
+		}
+	}
+	if source != nil && len(stale) > 0 {
+		repaired, _ := node.repairWallet(accID, *source, 0, stale)
+		for _, con := range repaired {
+			found[con] = true
+		}
+	}
+	holders = make([]Contact, 0, len(validators))
+	missing = make([]Contact, 0)
+	for _, val := range validators {
+		if found[val] {
+			holders = append(holders, val)
 		} else {
-			foundAccountNodes++
+			missing = append(missing, val)
 		}
 	}
-	if foundAccountNodes == REPLICATION {
-		return closeNodes, nil
-	} else {
-		return closeNodes, errors.New(fmt.Sprintf("Failed to locate all nodes containing account: %v", accID))
+	if required := level.Required(len(validators)); len(holders) < required {
+		return holders, missing, failure(ErrQuorumNotReached, "%d of %d validators hold account %v, %d required at %s consistency", len(holders), len(validators), accID, required, level)
 	}
+	return holders, missing, nil
 }
 
-func (node *Node) findAccountQuery(target [4]byte, respChan chan bool, accID [5]uint32) {
-	rpc := GenerateRPC(target, node.Contact)
+// Answer of a single validator to a find account query.
+type accountReply struct {
+	contact     Contact
+	reachable   bool // the validator answered
+	found       bool
+	replication int // replication of the account as stored by the validator
+}
+
+// Sends a find account query to each of the account's validators in parallel.
+// Returns the validators and a channel receiving one reply per validator.
+func (node *Node) queryAccount(accID KademliaID) ([]Contact, chan accountReply) {
+	closeNodes := node.OrderByLatency(node.FindNode(accID))
+	replies := make(chan accountReply, len(closeNodes))
+	for _, n := range closeNodes {
+		node.routines.Go("find account query", func() { replies <- node.findAccountQuery(n, accID) })
+	}
+	return closeNodes, replies
+}
+
+// Asks the validator whether it holds the account.
+func (node *Node) findAccountQuery(validator Contact, accID KademliaID) accountReply {
+	rpc := GenerateRPC(validator.IP(), node.Contact)
 	rpc.FindAccount(accID)
 	res, err := node.Send(rpc)
-	if err == nil {
-		respChan <- res.findAccountSucc
+	if err != nil {
+		return accountReply{contact: validator}
 	}
-	respChan <- false
+	reply := accountReply{contact: validator, reachable: true, found: res.findAccountSucc}
+	if reply.found {
+		reply.replication = res.replication
+	}
+	return reply
+}
+
+// Options of a fast account read, see FindAccountFast.
+type ReadOptions struct {
+	Replies int           // validators that must confirm the account, at least one
+	Budget  time.Duration // longest the read waits for them, zero waits for up to the node's timeout
+}
+
+// Outcome of the full-quorum check that follows a fast account read.
+type AccountVerified struct {
+	Node       Contact
+	Account    KademliaID
+	Holders    int // validators that confirmed the account
+	Validators int
+	Quorum     bool // a majority of the validators confirmed the account
+}
+
+func (AccountVerified) event() {}
+
+// Looks up the account like FindAccount but returns as soon as opts.Replies validators have
+// confirmed it, for reads that can accept a slightly stale view. The remaining replies are
+// collected in the background and an AccountVerified event reports whether a majority of the
+// validators holds the account.
+// Returns the validators that confirmed the account, or an error if too few did so within the budget.
+func (node *Node) FindAccountFast(accID KademliaID, opts ReadOptions) ([]Contact, error) {
+	wanted := max(opts.Replies, 1)
+	budget := opts.Budget
+	if budget <= 0 {
+		budget = node.config.Timeout
+	}
+	deadline := node.timebase.After(budget)
+	validators, replies := node.queryAccount(accID)
+	holders := make([]Contact, 0, wanted)
+	received, replication := 0, 0
+wait:
+	for len(holders) < wanted && received < len(validators) {
+		select {
+		case reply := <-replies:
+			received++
+			if reply.found {
+				holders = append(holders, reply.contact)
+				replication = max(replication, reply.replication)
+			}
+		case <-deadline:
+			break wait
+		}
+	}
+
+	confirmed := len(holders)
+	node.routines.Go("verify account", func() {
+		total := confirmed
+		for range len(validators) - received {
+			if reply := <-replies; reply.found {
+				total++
+				replication = max(replication, reply.replication)
+			}
+		}
+		expected := len(node.walletValidators(validators, accID, replication))
+		quorum := total > expected/2
+		if !quorum {
+			node.logger.Warn("fast read not backed by a quorum", "account", accID, "holders", total, "validators", expected)
+		}
+		node.publish(AccountVerified{node.Contact, accID, total, expected, quorum})
+	})
+
+	if confirmed < wanted {
+		return holders, failure(ErrQuorumNotReached, "%d of %d validators confirmed account %v within %v", confirmed, wanted, accID, budget)
+	}
+	return holders, nil
 }
 
-func (node *Node) DisplayAccount(accID [5]uint32) (string, error) {
+func (node *Node) DisplayAccount(accID KademliaID) (string, error) {
 	validators := node.FindNode(accID)
-	log.Printf("found %d validators", len(validators))
-	for _, con := range validators {
+	node.logger.Info("found validators", "account", accID, "validators", len(validators))
+	for _, con := range node.OrderByLatency(validators) {
 		rpc := GenerateRPC(con.IP(), node.Contact)
 		rpc.DisplayAccount(accID)
 		res, _ := node.Send(rpc)
@@ -210,30 +533,44 @@ func (node *Node) DisplayAccount(accID [5]uint32) (string, error) {
 	return "", errors.New("did not find account")
 }
 
-func (node *Node) LockAccount(accID [5]uint32) ([]Contact, []chan RPC, chan RPC) {
-	valGroup, _ := node.FindAccount(accID)
-	valChan := make([]chan RPC, 0, REPLICATION)
-	leaderChan := make(chan RPC, REPLICATION)
+// Takes the account's lock at the validators holding it, validators missing the account have no lock to take.
+func (node *Node) LockAccount(accID KademliaID) ([]Contact, []chan RPC, chan RPC) {
+	valGroup, _, _ := node.findAccount(accID, ONE)
+	valChan := make([]chan RPC, 0, node.config.Replication)
+	leaderChan := make(chan RPC, node.config.Replication)
 
-	for _, val := range valGroup {
+	for _, val := range node.OrderByLatency(valGroup) {
 		rpc := GenerateRPC(val.IP(), node.Contact)
-		rpc.OverrideID(RelativeDistance(node.ID(), val.ID()))
+		dist := RelativeDistance(node.ID(), val.ID()).Bytes()
+		rpc.OverrideID(IDFromBytes(dist[:]))
 		rpc.LockAccount(accID, leaderChan)
 
-		go node.Send(rpc)
+		node.routines.Go("lock account", func() { node.Send(rpc) })
 	}
 
 	for range valGroup {
 		resp, ok := <-leaderChan
 		if !ok {
-			log.Printf("node: %10v, leader chan for account %10v unexpectedly closed\n", node.ID(), accID)
+			node.logger.Error("leader chan unexpectedly closed", "account", accID)
 		} else {
-			if node.debug {
-				log.Printf("node: %10v took lock for account %10v on node %10v\n", node.ID(), accID, resp.sender.ID())
-			}
+			node.logger.Debug("took lock", "account", accID, "validator", resp.sender.ID())
 		}
 		valChan = append(valChan, resp.lockChan)
 	}
 
 	return valGroup, valChan, leaderChan
 }
+
+// Sends an application-defined command to the node at address and returns the payload of its response.
+func (node *Node) SendCommand(address [4]byte, command Command, payload []byte) ([]byte, error) {
+	if command < USER_CMD {
+		return nil, errors.New(fmt.Sprintf("command %d is not an application-defined command", command))
+	}
+	rpc := GenerateRPC(address, node.Contact)
+	rpc.Custom(command, payload)
+	res, err := node.Send(rpc)
+	if err != nil {
+		return nil, err
+	}
+	return res.payload, nil
+}
@@ -0,0 +1,178 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestFindNodeLookup(t *testing.T) {
+	testName := "TestFindNodeLookup"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(40, done)
+	<-done
+	defer s.Shutdown()
+
+	target := nodes[len(nodes)-1]
+	found, stats := nodes[0].FindNodeWithStats(target.ID())
+	if len(found) != REPLICATION || found[0].ID() != target.ID() {
+		log.Printf("[%s] - expected %d contacts starting with the target, received %d", testName, REPLICATION, len(found))
+		t.FailNow()
+	}
+	for i := 1; i < len(found); i++ {
+		if CloserNode(found[i].ID(), found[i-1].ID(), target.ID()) {
+			log.Printf("[%s] - contacts are not ordered by distance to the target", testName)
+			t.Fail()
+		}
+	}
+	if stats.RPCs < stats.Hops || stats.Hops == 0 || stats.Failed != 0 {
+		log.Printf("[%s] - unexpected lookup stats %+v", testName, stats)
+		t.Fail()
+	}
+
+	// Nodes that no longer answer are left out of the result.
+	dead := make(map[KademliaID]bool)
+	for _, con := range found[1:4] {
+		for _, n := range nodes {
+			if n.ID() == con.ID() && n != nodes[0] {
+				s.ShutdownNode(n)
+				dead[n.ID()] = true
+			}
+		}
+	}
+	found, stats = nodes[0].FindNodeWithStats(target.ID())
+	for _, con := range found {
+		if dead[con.ID()] {
+			log.Printf("[%s] - lookup returned a node that was shut down", testName)
+			t.Fail()
+		}
+	}
+	if len(found) == 0 || found[0].ID() != target.ID() {
+		log.Printf("[%s] - lookup lost the target after shutting down its neighbours", testName)
+		t.Fail()
+	}
+}
+
+func TestFindNodeResponseSize(t *testing.T) {
+	testName := "TestFindNodeResponseSize"
+	config := DefaultConfig()
+	config.ResponseSize = 4
+	s, err := NewServerWithConfig(false, 0.0, config)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	s.Silence()
+	done := make(chan struct{}, 1)
+	go s.StartServer()
+	nodes := s.SpawnCluster(40, done)
+	<-done
+	defer s.Shutdown()
+
+	target := nodes[len(nodes)-1].ID()
+	for _, count := range []int{0, 1, 4, REPLICATION + 5} {
+		rpc := GenerateRPC(nodes[1].IP(), nodes[0].Contact)
+		rpc.FindNodeLimit(target, count)
+		res, err := nodes[0].Send(rpc)
+		if err != nil {
+			log.Printf("[%s] - %s", testName, err.Error())
+			t.FailNow()
+		}
+		if count > 0 && count <= REPLICATION && len(res.foundNodes) != count {
+			log.Printf("[%s] - asked for %d contacts, received %d", testName, count, len(res.foundNodes))
+			t.Fail()
+		}
+		// without a count, or with too large a count, the receiver answers with up to its replication
+		if (count == 0 || count > REPLICATION) && (len(res.foundNodes) <= 4 || len(res.foundNodes) > REPLICATION) {
+			log.Printf("[%s] - asked for %d contacts, received %d", testName, count, len(res.foundNodes))
+			t.Fail()
+		}
+	}
+
+	// lookups still converge on the target with the smaller responses
+	found := nodes[0].FindNode(target)
+	if len(found) == 0 || found[0].ID() != target {
+		log.Printf("[%s] - lookup with a response size of %d did not find the target", testName, config.ResponseSize)
+		t.Fail()
+	}
+}
+
+func TestFindAccountFast(t *testing.T) {
+	testName := "TestFindAccountFast"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	accID := RandomID()
+	nodes[0].StoreAccount(accID)
+	if _, _, err := nodes[1].findAccount(accID, ALL); err != nil {
+		log.Printf("[%s] - full read failed: %s", testName, err.Error())
+		t.FailNow()
+	}
+
+	events, cancel := s.Events().Subscribe(1 << 16)
+	defer cancel()
+	holders, err := nodes[1].FindAccountFast(accID, ReadOptions{Replies: 2, Budget: TIMEOUT})
+	if err != nil || len(holders) != 2 {
+		log.Printf("[%s] - expected two confirmations, received %d: %v", testName, len(holders), err)
+		t.Fail()
+	}
+	_, err = nodes[1].FindAccountFast(RandomID(), ReadOptions{Replies: 1, Budget: TIMEOUT})
+	if err == nil {
+		log.Printf("[%s] - fast read confirmed a missing account", testName)
+		t.Fail()
+	}
+
+	verified := make(map[KademliaID]bool)
+	timeout := time.After(2 * TIMEOUT)
+	for len(verified) < 2 {
+		select {
+		case ev := <-events:
+			if v, ok := ev.(AccountVerified); ok && v.Node.ID() == nodes[1].ID() {
+				verified[v.Account] = v.Quorum
+			}
+		case <-timeout:
+			log.Printf("[%s] - background verification did not report", testName)
+			t.FailNow()
+		}
+	}
+	for id, quorum := range verified {
+		if quorum != (id == accID) {
+			log.Printf("[%s] - account %v verified with quorum %t", testName, id, quorum)
+			t.Fail()
+		}
+	}
+}
+
+func TestFindNodeSmallNetwork(t *testing.T) {
+	testName := "TestFindNodeSmallNetwork"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(8, done)
+	<-done
+	defer s.Shutdown()
+
+	// the lookup collects more contacts than CONCURRENCY but fewer than REPLICATION
+	found := nodes[0].FindNode(nodes[len(nodes)-1].ID())
+	if len(found) == 0 || len(found) > len(nodes)+1 {
+		log.Printf("[%s] - lookup in a network of %d nodes returned %d contacts", testName, len(nodes)+1, len(found))
+		t.Fail()
+	}
+	seen := make(map[Contact]bool, len(found))
+	for _, con := range found {
+		if con == (Contact{}) || seen[con] {
+			log.Printf("[%s] - lookup returned an empty or repeated contact %v", testName, con)
+			t.Fail()
+		}
+		seen[con] = true
+	}
+}
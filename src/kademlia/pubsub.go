@@ -0,0 +1,262 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	TOPIC_LEASE       = WATCH_LEASE // how long a rendezvous node delivers to a subscriber without the subscription being renewed
+	PUBLISH_REPLICAS  = 3           // rendezvous nodes a publication is handed to, each delivers it to every subscriber it knows
+	PUBLISH_MAX_BYTES = 64 * 1024   // largest publication
+	TOPIC_BUFFER      = 64          // publications waiting for a slow reader before new ones are dropped
+	TOPIC_SEEN        = 1024        // publications remembered per topic to drop the copies of other rendezvous nodes
+)
+
+// A message published on a topic.
+type Publication struct {
+	ID        KademliaID
+	Topic     KademliaID
+	Publisher KademliaID
+	Data      []byte
+}
+
+// Returns the id of the topic with the given name, topics are stored like keys: the K nodes
+// closest to the id are its rendezvous points and hold its subscribers.
+func TopicID(name string) KademliaID {
+	return NewKeyFromData([]byte(name))
+}
+
+func (rpc *RPC) Subscribe(topic KademliaID) {
+	rpc.cmd = SUBSCRIBE
+	rpc.accountID = topic
+}
+
+func (rpc *RPC) Subscribed(topic KademliaID, accepted bool) {
+	rpc.cmd = SUBSCRIBED
+	rpc.accountID = topic
+	rpc.accepted = accepted
+}
+
+// Hands a publication to a rendezvous node of its topic for delivery.
+func (rpc *RPC) Publish(pub Publication) {
+	rpc.cmd = PUBLISH
+	rpc.accountID = pub.Topic
+	rpc.publication = pub
+}
+
+func (rpc *RPC) Published(topic KademliaID, accepted bool) {
+	rpc.cmd = PUBLISHED
+	rpc.accountID = topic
+	rpc.accepted = accepted
+}
+
+// Delivers a publication to a subscriber of its topic.
+func (rpc *RPC) DeliverPublication(pub Publication) {
+	rpc.cmd = DELIVER_PUBLICATION
+	rpc.accountID = pub.Topic
+	rpc.publication = pub
+}
+
+func (rpc *RPC) DeliveredPublication(topic KademliaID) {
+	rpc.cmd = DELIVERED_PUBLICATION
+	rpc.accountID = topic
+}
+
+// Local subscribers to topics, keyed by topic.
+type topicSubscriptions struct {
+	content map[KademliaID]*topicWatch
+	sync.Mutex
+}
+
+type topicWatch struct {
+	listeners []chan Publication
+	seen      map[KademliaID]bool
+	order     []KademliaID // seen publications, oldest first
+	stop      chan struct{}
+}
+
+func newTopicSubscriptions() *topicSubscriptions {
+	return &topicSubscriptions{
+		content: make(map[KademliaID]*topicWatch),
+	}
+}
+
+// Records the publication, returns false if it was seen before.
+func (watch *topicWatch) first(id KademliaID) bool {
+	if watch.seen[id] {
+		return false
+	}
+	if len(watch.order) >= TOPIC_SEEN {
+		delete(watch.seen, watch.order[0])
+		watch.order = watch.order[1:]
+	}
+	watch.seen[id] = true
+	watch.order = append(watch.order, id)
+	return true
+}
+
+// Subscribes to the topic. Its rendezvous points deliver every publication on the topic, which
+// is passed on the returned channel once. The subscription is renewed at the rendezvous points
+// every TOPIC_LEASE/2 until the returned cancel function is called, which also closes the
+// channel. Publications are dropped while TOPIC_BUFFER of them wait for a slow reader.
+// Returns an error if no rendezvous point accepted the subscription.
+func (node *Node) SubscribeTopic(topic KademliaID) (<-chan Publication, func(), error) {
+	if node.subscribeTopic(topic) == 0 {
+		return nil, nil, errors.New(fmt.Sprintf("no rendezvous point accepted a subscription to topic %v", topic))
+	}
+	listener := make(chan Publication, TOPIC_BUFFER)
+	node.topicSubs.Lock()
+	sub, ok := node.topicSubs.content[topic]
+	if !ok {
+		sub = &topicWatch{seen: make(map[KademliaID]bool), stop: make(chan struct{})}
+		node.topicSubs.content[topic] = sub
+		node.routines.Go("topic subscription", func() { node.renewTopic(topic, sub.stop) })
+	}
+	sub.listeners = append(sub.listeners, listener)
+	node.topicSubs.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			node.topicSubs.Lock()
+			defer node.topicSubs.Unlock()
+			for i, l := range sub.listeners {
+				if l == listener {
+					sub.listeners = append(sub.listeners[:i], sub.listeners[i+1:]...)
+					break
+				}
+			}
+			close(listener)
+			if len(sub.listeners) == 0 {
+				close(sub.stop)
+				delete(node.topicSubs.content, topic)
+			}
+		})
+	}
+	return listener, cancel, nil
+}
+
+// Subscribes the node at each rendezvous point of the topic.
+// Returns the number of rendezvous points that accepted the subscription.
+func (node *Node) subscribeTopic(topic KademliaID) int {
+	points := node.FindNode(topic)
+	accepted := make(chan bool, len(points))
+	for _, con := range points {
+		rpc := GenerateRPC(con.IP(), node.Contact)
+		rpc.Subscribe(topic)
+		node.routines.Go("subscribe topic", func() {
+			res, err := node.Send(rpc)
+			accepted <- err == nil && res.accepted
+		})
+	}
+	res := 0
+	for range points {
+		if <-accepted {
+			res++
+		}
+	}
+	return res
+}
+
+// Renews the subscription to the topic until stop is closed or the node shuts down.
+func (node *Node) renewTopic(topic KademliaID, stop chan struct{}) {
+	ticker := node.timebase.NewTicker(node.config.scaled(TOPIC_LEASE) / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-node.Network.listener.Done():
+			return
+		case <-ticker.C():
+			node.subscribeTopic(topic)
+		}
+	}
+}
+
+// Publishes data on the topic. The publication is handed to the PUBLISH_REPLICAS rendezvous
+// points closest to the topic, falling back on farther ones while they fail, and each of them
+// delivers it to the subscribers it knows. Subscribers drop the copies after the first.
+// Returns the number of rendezvous points that took the publication, or an error if the data
+// is too large or none of them took it.
+func (node *Node) PublishTopic(topic KademliaID, data []byte) (int, error) {
+	if len(data) > PUBLISH_MAX_BYTES {
+		return 0, errors.New(fmt.Sprintf("publication of %d bytes exceeds %d bytes", len(data), PUBLISH_MAX_BYTES))
+	}
+	pub := Publication{RandomID(), topic, node.ID(), data}
+	points := node.FindNode(topic)
+	taken := 0
+	for start := 0; start < len(points) && taken < PUBLISH_REPLICAS; {
+		batch := points[start:min(len(points), start+PUBLISH_REPLICAS-taken)]
+		start += len(batch)
+		accepted := make(chan bool, len(batch))
+		for _, con := range batch {
+			rpc := GenerateRPC(con.IP(), node.Contact)
+			rpc.Publish(pub)
+			node.routines.Go("publish", func() {
+				res, err := node.Send(rpc)
+				accepted <- err == nil && res.accepted
+			})
+		}
+		for range batch {
+			if <-accepted {
+				taken++
+			}
+		}
+	}
+	if taken == 0 {
+		return 0, errors.New(fmt.Sprintf("no rendezvous point took the publication on topic %v", topic))
+	}
+	return taken, nil
+}
+
+// Response logic for an incoming subscribe RPC, observers are not rendezvous points.
+func (node *Node) handleSubscribe(rpc *RPC) {
+	accepted := node.Role() != OBSERVER
+	if accepted {
+		node.topics.add(rpc.accountID, rpc.sender, node.timebase.Now().Add(node.config.scaled(TOPIC_LEASE)))
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.Subscribed(rpc.accountID, accepted)
+	node.Send(resp)
+}
+
+// Response logic for an incoming publish RPC, the publication is delivered to every live
+// subscriber of its topic.
+func (node *Node) handlePublish(rpc *RPC) {
+	pub := rpc.publication
+	accepted := node.Role() != OBSERVER && pub.Topic == rpc.accountID && len(pub.Data) <= PUBLISH_MAX_BYTES
+	if accepted {
+		for _, con := range node.topics.live(pub.Topic, node.timebase.Now()) {
+			deliver := GenerateRPC(con.IP(), node.Contact)
+			deliver.DeliverPublication(pub)
+			deliver.forwardOf(rpc)
+			node.routines.Go("deliver publication", func() { node.Send(deliver) })
+		}
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.Published(rpc.accountID, accepted)
+	node.Send(resp)
+}
+
+// Response logic for an incoming deliver RPC. Several rendezvous points deliver the same
+// publication, only the first copy is passed on to the subscribers.
+func (node *Node) handleDeliver(rpc *RPC) {
+	node.topicSubs.Lock()
+	sub, ok := node.topicSubs.content[rpc.accountID]
+	if ok && sub.first(rpc.publication.ID) {
+		for _, listener := range sub.listeners {
+			select {
+			case listener <- rpc.publication:
+			default:
+				node.logger.Debug("dropping publication for a slow reader", "topic", rpc.accountID, "publication", rpc.publication.ID)
+			}
+		}
+	}
+	node.topicSubs.Unlock()
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.DeliveredPublication(rpc.accountID)
+	node.Send(resp)
+}
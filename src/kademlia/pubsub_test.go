@@ -0,0 +1,85 @@
+package kademlia
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestPubSub(t *testing.T) {
+	testName := "TestPubSub"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	topic := TopicID("blocks")
+	first, cancelFirst, err := nodes[0].SubscribeTopic(topic)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	second, cancelSecond, err := nodes[1].SubscribeTopic(topic)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	defer cancelSecond()
+
+	data := []byte("block 1")
+	taken, err := nodes[7].PublishTopic(topic, data)
+	if err != nil || taken != PUBLISH_REPLICAS {
+		log.Printf("[%s] - expected %d rendezvous points to take the publication, got %d: %v", testName, PUBLISH_REPLICAS, taken, err)
+		t.FailNow()
+	}
+	for i, sub := range []<-chan Publication{first, second} {
+		select {
+		case pub := <-sub:
+			if !bytes.Equal(pub.Data, data) || pub.Topic != topic || pub.Publisher != nodes[7].ID() {
+				log.Printf("[%s] - subscriber %d got %+v", testName, i, pub)
+				t.Fail()
+			}
+		case <-time.After(2 * time.Second):
+			log.Printf("[%s] - subscriber %d got nothing", testName, i)
+			t.FailNow()
+		}
+	}
+	// Every rendezvous point that took the publication delivers it, subscribers see it once.
+	select {
+	case pub := <-first:
+		log.Printf("[%s] - publication delivered twice: %+v", testName, pub)
+		t.Fail()
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	other, cancelOther, err := nodes[2].SubscribeTopic(TopicID("other"))
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	defer cancelOther()
+	nodes[7].PublishTopic(topic, []byte("block 2"))
+	select {
+	case pub := <-other:
+		log.Printf("[%s] - publication crossed topics: %+v", testName, pub)
+		t.Fail()
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancelFirst()
+	// the second publication may still be buffered, the channel ends after it
+	for pub := range first {
+		if !bytes.Equal(pub.Data, []byte("block 2")) {
+			log.Printf("[%s] - unexpected publication after cancel: %+v", testName, pub)
+			t.Fail()
+		}
+	}
+	if _, err := nodes[7].PublishTopic(topic, make([]byte, PUBLISH_MAX_BYTES+1)); err == nil {
+		log.Printf("[%s] - published more than %d bytes", testName, PUBLISH_MAX_BYTES)
+		t.Fail()
+	}
+}
@@ -0,0 +1,271 @@
+package kademlia
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_QUEUE_SIZE = 128         // inbound queue capacity of simulated nodes
+	SATURATION_LEVEL   = 0.8         // fraction of the queue capacity above which a queue counts as saturated
+	SATURATION_WINDOW  = 2 * TIMEOUT // how long a queue must stay saturated before QueueSaturated is published
+)
+
+// A node's inbound queue has stayed above SATURATION_LEVEL for SATURATION_WINDOW.
+type QueueSaturated struct {
+	Node  Contact
+	Depth int
+	Size  int
+	Since time.Time
+}
+
+// A saturated queue has dropped back below SATURATION_LEVEL.
+type QueueRecovered struct {
+	Node     Contact
+	Duration time.Duration // how long the queue was saturated
+}
+
+func (QueueSaturated) event() {}
+func (QueueRecovered) event() {}
+
+// Decides what happens to a RPC that arrives at a full inbound queue.
+type OverflowPolicy int32
+
+const (
+	BACKPRESSURE OverflowPolicy = iota // the sender blocks until the queue has room
+	DROP_TAIL                          // the arriving RPC is discarded
+	DROP_HEAD                          // the oldest queued RPC is discarded to make room
+)
+
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case BACKPRESSURE:
+		return "BACKPRESSURE"
+	case DROP_TAIL:
+		return "DROP_TAIL"
+	case DROP_HEAD:
+		return "DROP_HEAD"
+	}
+	return "unknown overflow policy"
+}
+
+// Inbound queue settings for a simulated node.
+type QueueConfig struct {
+	Size     int
+	Overflow OverflowPolicy
+}
+
+// Point in time view of a node's inbound queue.
+type QueueStats struct {
+	Size        int
+	Queued      int
+	Overflows   int // RPCs discarded by the overflow policy
+	Overflow    OverflowPolicy
+	Dropped     map[Command]int // RPCs discarded by the overflow policy, by command
+	Dequeued    int             // RPCs taken off the queue by the node
+	MaxDepth    int             // deepest the queue has been when a RPC was taken off it
+	AverageWait time.Duration   // average time a RPC spent queued
+	MaxWait     time.Duration
+	Saturated   bool // the queue is above SATURATION_LEVEL
+}
+
+// Outcome of delivering a RPC to an inbox.
+type delivery int
+
+const (
+	DELIVERED  delivery = iota
+	OVERFLOWED          // discarded by DROP_TAIL
+	CLOSED
+)
+
+// Inbox counters and policy, kept separate so they can change without taking the inbox lock.
+type inboxQueue struct {
+	policy    atomic.Int32
+	overflows atomic.Int64
+	dequeued  atomic.Int64
+	maxDepth  atomic.Int64
+	waitTotal atomic.Int64 // nanoseconds
+	maxWait   atomic.Int64 // nanoseconds
+	saturated atomic.Int64 // unix nanoseconds at which the queue became saturated, zero if it is not
+	alerted   atomic.Bool  // QueueSaturated has been published for the current saturation
+	dropped   map[Command]int
+	sync.Mutex
+}
+
+func (queue *inboxQueue) recordDrop(cmd Command) {
+	queue.overflows.Add(1)
+	queue.Lock()
+	defer queue.Unlock()
+	if queue.dropped == nil {
+		queue.dropped = make(map[Command]int)
+	}
+	queue.dropped[cmd]++
+}
+
+// Records a RPC taken off the queue, depth is the queue length including the RPC.
+func (queue *inboxQueue) recordDequeue(wait time.Duration, depth int) {
+	queue.dequeued.Add(1)
+	queue.waitTotal.Add(int64(wait))
+	for {
+		prev := queue.maxWait.Load()
+		if int64(wait) <= prev || queue.maxWait.CompareAndSwap(prev, int64(wait)) {
+			break
+		}
+	}
+	for {
+		prev := queue.maxDepth.Load()
+		if int64(depth) <= prev || queue.maxDepth.CompareAndSwap(prev, int64(depth)) {
+			break
+		}
+	}
+}
+
+// Delivers the RPC according to the inbox overflow policy.
+// Returns the outcome and, under DROP_HEAD, the queued RPCs that were evicted to make room.
+func (inbox *inbox) deliver(rpc RPC) (delivery, []RPC) {
+	inbox.RLock()
+	defer inbox.RUnlock()
+	if inbox.closed {
+		return CLOSED, nil
+	}
+	rpc.queued = inbox.timebase.Now()
+	policy := OverflowPolicy(inbox.queue.policy.Load())
+	if policy == DROP_HEAD && cap(inbox.content) == 0 {
+		// an unbuffered queue holds nothing that could be evicted
+		policy = DROP_TAIL
+	}
+	switch policy {
+	case DROP_TAIL:
+		select {
+		case inbox.content <- rpc:
+			return DELIVERED, nil
+		default:
+			inbox.queue.recordDrop(rpc.cmd)
+			return OVERFLOWED, nil
+		}
+	case DROP_HEAD:
+		var evicted []RPC
+		for {
+			select {
+			case inbox.content <- rpc:
+				return DELIVERED, evicted
+			default:
+			}
+			// The listener may empty the queue between the two selects, in which case nothing is evicted.
+			select {
+			case old := <-inbox.content:
+				inbox.queue.recordDrop(old.cmd)
+				evicted = append(evicted, old)
+			default:
+			}
+		}
+	}
+	select {
+	case inbox.content <- rpc:
+		return DELIVERED, nil
+	case <-inbox.done:
+		return CLOSED, nil
+	}
+}
+
+func (inbox *inbox) SetOverflowPolicy(policy OverflowPolicy) {
+	inbox.queue.policy.Store(int32(policy))
+}
+
+// Records a RPC taken off the queue by the listener and tracks saturation.
+// Returns the time the RPC spent queued and the queue depth including the RPC.
+// Must only be called from the listener, which is the only reader of the queue.
+func (inbox *inbox) dequeued(rpc RPC) (time.Duration, int) {
+	wait := inbox.timebase.Since(rpc.queued)
+	depth := len(inbox.content) + 1
+	inbox.queue.recordDequeue(wait, depth)
+	return wait, depth
+}
+
+// Updates the saturation state of the queue after a dequeue, an alert is due once it stayed saturated for window.
+// Returns the time the queue became saturated and whether a QueueSaturated or QueueRecovered
+// event is due, at most one of the two is true.
+func (inbox *inbox) checkSaturation(depth int, now time.Time, window time.Duration) (time.Time, bool, bool) {
+	size := cap(inbox.content)
+	since := inbox.queue.saturated.Load()
+	if size == 0 || float64(depth) < SATURATION_LEVEL*float64(size) {
+		if since == 0 {
+			return time.Time{}, false, false
+		}
+		inbox.queue.saturated.Store(0)
+		return time.Unix(0, since), false, inbox.queue.alerted.Swap(false)
+	}
+	if since == 0 {
+		inbox.queue.saturated.Store(now.UnixNano())
+		return now, false, false
+	}
+	start := time.Unix(0, since)
+	if now.Sub(start) < window || inbox.queue.alerted.Load() {
+		return start, false, false
+	}
+	inbox.queue.alerted.Store(true)
+	return start, true, false
+}
+
+func (inbox *inbox) Stats() QueueStats {
+	res := QueueStats{
+		Size:      cap(inbox.content),
+		Queued:    len(inbox.content),
+		Overflows: int(inbox.queue.overflows.Load()),
+		Overflow:  OverflowPolicy(inbox.queue.policy.Load()),
+		Dequeued:  int(inbox.queue.dequeued.Load()),
+		MaxDepth:  int(inbox.queue.maxDepth.Load()),
+		MaxWait:   time.Duration(inbox.queue.maxWait.Load()),
+		Saturated: inbox.queue.saturated.Load() != 0,
+	}
+	if res.Dequeued > 0 {
+		res.AverageWait = time.Duration(inbox.queue.waitTotal.Load() / int64(res.Dequeued))
+	}
+	inbox.queue.Lock()
+	res.Dropped = make(map[Command]int, len(inbox.queue.dropped))
+	for c, n := range inbox.queue.dropped {
+		res.Dropped[c] = n
+	}
+	inbox.queue.Unlock()
+	return res
+}
+
+// Returns the state of the node's inbound queue.
+func (node *Node) QueueStats() QueueStats {
+	return node.Network.listener.Stats()
+}
+
+// Sets the queue used by nodes spawned from now on, existing nodes keep their queues.
+func (simnet *Simnet) SetQueueConfig(config QueueConfig) {
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.queueConfig = config
+}
+
+// Changes the overflow policy of a running node.
+// The queue size is fixed when the node is spawned, use SpawnNodeWithQueue for per-node sizes.
+func (simnet *Simnet) SetOverflowPolicy(ip [4]byte, policy OverflowPolicy) error {
+	inbox, ok := simnet.chanTable.lookup(ip)
+	if !ok {
+		return failure(ErrNodeNotFound, "no node with ip %v", ip)
+	}
+	inbox.SetOverflowPolicy(policy)
+	return nil
+}
+
+// Returns the state of the inbound queue of the node at ip.
+func (simnet *Simnet) QueueStats(ip [4]byte) (QueueStats, error) {
+	inbox, ok := simnet.chanTable.lookup(ip)
+	if !ok {
+		return QueueStats{}, failure(ErrNodeNotFound, "no node with ip %v", ip)
+	}
+	return inbox.Stats(), nil
+}
+
+// Spawns a node with its own queue settings instead of the simnet default.
+func (simnet *Simnet) SpawnNodeWithQueue(config QueueConfig, done chan KademliaID) *Node {
+	newNode := simnet.generateNode(config, DEFAULT_NETWORK, KademliaID{}, FULL_NODE)
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
+	return newNode
+}
@@ -0,0 +1,219 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestQueueDropTail(t *testing.T) {
+	testName := "TestQueueDropTail"
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.SetQueueConfig(QueueConfig{2, DROP_TAIL})
+	receiver := s.GenerateRandomNode()
+	sender := NewRandomContact()
+	for range 5 {
+		rpc := GenerateRPC(receiver.IP(), sender)
+		rpc.Ping()
+		s.Route(rpc)
+	}
+
+	queue, err := s.QueueStats(receiver.IP())
+	if err != nil || queue.Queued != 2 || queue.Overflows != 3 {
+		log.Printf("[%s] - expected 2 queued and 3 overflows, got %+v: %v", testName, queue, err)
+		t.Fail()
+	}
+	stats := s.Stats()
+	if stats.Overflowed != 3 || stats.NodeMessages[receiver.IP()].Overflowed != 3 {
+		log.Printf("[%s] - expected 3 overflows in stats, got %d", testName, stats.Overflowed)
+		t.Fail()
+	}
+	if stats.NodeMessages[receiver.IP()].Received != 2 {
+		log.Printf("[%s] - expected 2 received RPCs, got %d", testName, stats.NodeMessages[receiver.IP()].Received)
+		t.Fail()
+	}
+}
+
+func TestQueueDropHead(t *testing.T) {
+	testName := "TestQueueDropHead"
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.SetQueueConfig(QueueConfig{2, DROP_HEAD})
+	receiver := s.GenerateRandomNode()
+	sender := NewRandomContact()
+	sent := make([]KademliaID, 0, 5)
+	for range 5 {
+		rpc := GenerateRPC(receiver.IP(), sender)
+		rpc.Ping()
+		sent = append(sent, rpc.id)
+		s.Route(rpc)
+	}
+
+	for _, id := range sent[3:] {
+		queued := <-receiver.Network.listener.content
+		if queued.id != id {
+			log.Printf("[%s] - expected the newest RPCs to be queued, found %v", testName, queued.id)
+			t.Fail()
+		}
+	}
+	if s.Stats().Overflowed != 3 {
+		log.Printf("[%s] - expected 3 evictions, got %d", testName, s.Stats().Overflowed)
+		t.Fail()
+	}
+}
+
+func TestQueueBackpressure(t *testing.T) {
+	testName := "TestQueueBackpressure"
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.SetQueueConfig(QueueConfig{1, BACKPRESSURE})
+	receiver := s.GenerateRandomNode()
+	sender := NewRandomContact()
+	first := GenerateRPC(receiver.IP(), sender)
+	first.Ping()
+	s.Route(first)
+
+	routed := make(chan struct{})
+	go func() {
+		second := GenerateRPC(receiver.IP(), sender)
+		second.Ping()
+		s.Route(second)
+		close(routed)
+	}()
+	select {
+	case <-routed:
+		log.Printf("[%s] - route did not block on a full queue", testName)
+		t.Fail()
+	case <-time.After(20 * time.Millisecond):
+	}
+	<-receiver.Network.listener.content
+	select {
+	case <-routed:
+	case <-time.After(time.Second):
+		log.Printf("[%s] - route still blocked after the queue drained", testName)
+		t.Fail()
+	}
+
+	err := s.SetOverflowPolicy(receiver.IP(), DROP_TAIL)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	third := GenerateRPC(receiver.IP(), sender)
+	third.Ping()
+	s.Route(third)
+	if s.Stats().Overflowed != 1 {
+		log.Printf("[%s] - expected the changed policy to drop, got %d overflows", testName, s.Stats().Overflowed)
+		t.Fail()
+	}
+}
+
+func TestSpawnNodeWithQueue(t *testing.T) {
+	testName := "TestSpawnNodeWithQueue"
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	done := make(chan KademliaID, 1)
+	node := s.SpawnNodeWithQueue(QueueConfig{8, DROP_HEAD}, done)
+	<-done
+
+	queue, err := s.QueueStats(node.IP())
+	if err != nil || queue.Size != 8 || queue.Overflow != DROP_HEAD {
+		log.Printf("[%s] - expected a size 8 DROP_HEAD queue, got %+v: %v", testName, queue, err)
+		t.Fail()
+	}
+	master := s.MasterNode()
+	queue, _ = s.QueueStats(master.IP())
+	if queue.Size != DEFAULT_QUEUE_SIZE || queue.Overflow != BACKPRESSURE {
+		log.Printf("[%s] - expected the default queue on the master node, got %+v", testName, queue)
+		t.Fail()
+	}
+	s.Shutdown()
+}
+
+func TestQueueDropsByCommand(t *testing.T) {
+	testName := "TestQueueDropsByCommand"
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.SetQueueConfig(QueueConfig{2, DROP_TAIL})
+	receiver := s.GenerateRandomNode()
+	sender := NewRandomContact()
+	for i := range 5 {
+		rpc := GenerateRPC(receiver.IP(), sender)
+		if i < 3 {
+			rpc.Ping()
+		} else {
+			rpc.FindNode(RandomID())
+		}
+		s.Route(rpc)
+	}
+
+	<-receiver.Network.listener.content
+	time.Sleep(time.Millisecond)
+	queued := <-receiver.Network.listener.content
+	receiver.Network.listener.dequeued(queued)
+	queue := receiver.QueueStats()
+	if queue.Dropped[PING] != 1 || queue.Dropped[FIND_NODE] != 2 {
+		log.Printf("[%s] - expected 1 dropped ping and 2 dropped find nodes, got %v", testName, queue.Dropped)
+		t.Fail()
+	}
+	if queue.Dequeued != 1 || queue.MaxDepth != 1 || queue.MaxWait < time.Millisecond || queue.AverageWait != queue.MaxWait {
+		log.Printf("[%s] - unexpected wait statistics %+v", testName, queue)
+		t.Fail()
+	}
+}
+
+func TestQueueSaturation(t *testing.T) {
+	testName := "TestQueueSaturation"
+	inbox := newInbox(make(chan RPC, 10))
+	start := time.Now()
+	if _, saturated, _ := inbox.checkSaturation(9, start, SATURATION_WINDOW); saturated {
+		log.Printf("[%s] - saturation reported before the window passed", testName)
+		t.Fail()
+	}
+	if !inbox.Stats().Saturated {
+		log.Printf("[%s] - queue above the saturation level not marked saturated", testName)
+		t.Fail()
+	}
+	since, saturated, _ := inbox.checkSaturation(8, start.Add(SATURATION_WINDOW), SATURATION_WINDOW)
+	if !saturated || !since.Equal(start) {
+		log.Printf("[%s] - sustained saturation not reported", testName)
+		t.Fail()
+	}
+	if _, saturated, _ := inbox.checkSaturation(10, start.Add(2*SATURATION_WINDOW), SATURATION_WINDOW); saturated {
+		log.Printf("[%s] - saturation reported twice", testName)
+		t.Fail()
+	}
+	if _, _, recovered := inbox.checkSaturation(3, start.Add(3*SATURATION_WINDOW), SATURATION_WINDOW); !recovered {
+		log.Printf("[%s] - recovery not reported", testName)
+		t.Fail()
+	}
+	inbox.checkSaturation(9, start.Add(4*SATURATION_WINDOW), SATURATION_WINDOW)
+	if _, _, recovered := inbox.checkSaturation(1, start.Add(4*SATURATION_WINDOW), SATURATION_WINDOW); recovered {
+		log.Printf("[%s] - recovery reported for a saturation that was never announced", testName)
+		t.Fail()
+	}
+}
+
+func TestQueueWaitOnTimebase(t *testing.T) {
+	testName := "TestQueueWaitOnTimebase"
+	s := NewServer(false, 0.0)
+	s.Silence()
+	clock := NewFakeClock(time.Unix(0, 0))
+	s.SetTimebase(clock)
+	s.SetQueueConfig(QueueConfig{2, DROP_TAIL})
+	receiver := s.GenerateRandomNode()
+	rpc := GenerateRPC(receiver.IP(), NewRandomContact())
+	rpc.Ping()
+	s.Route(rpc)
+
+	// the wait is measured on the simnet's timebase, not the wall clock
+	clock.Advance(time.Hour)
+	queued := <-receiver.Network.listener.content
+	receiver.Network.listener.dequeued(queued)
+	if queue := receiver.QueueStats(); queue.MaxWait != time.Hour {
+		log.Printf("[%s] - expected an hour of queue wait on the fake clock, got %v", testName, queue.MaxWait)
+		t.Fail()
+	}
+}
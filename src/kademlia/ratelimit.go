@@ -0,0 +1,135 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const RATE_LIMIT_SENDERS = 4096 // senders tracked before the limiter forgets those with a full bucket
+
+// Token bucket limit on the requests a node handles from a single sender. The bucket holds up to
+// Burst tokens and refills at Rate tokens per second, every request takes one token and requests
+// arriving at an empty bucket are dropped. The zero value does not limit anything.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (limit RateLimit) unlimited() bool {
+	return limit.Rate <= 0 && limit.Burst <= 0
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Rate limits of a node and the buckets of the senders it heard from, keyed by sender IP and command.
+type rateLimiter struct {
+	limits   map[cmd]RateLimit
+	fallback RateLimit // limit of commands without their own
+	buckets  map[rateKey]*tokenBucket
+	limited  atomic.Uint64
+	sync.Mutex
+}
+
+type rateKey struct {
+	ip  [4]byte
+	cmd cmd
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		limits:  make(map[cmd]RateLimit),
+		buckets: make(map[rateKey]*tokenBucket),
+	}
+}
+
+func (limiter *rateLimiter) limit(command cmd) RateLimit {
+	limit, ok := limiter.limits[command]
+	if !ok {
+		return limiter.fallback
+	}
+	return limit
+}
+
+// Takes a token from the sender's bucket for the command, returns false if the bucket is empty.
+func (limiter *rateLimiter) allow(ip [4]byte, command cmd, now time.Time) bool {
+	limiter.Lock()
+	defer limiter.Unlock()
+	limit := limiter.limit(command)
+	if limit.unlimited() {
+		return true
+	}
+	key := rateKey{ip, command}
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		if len(limiter.buckets) >= RATE_LIMIT_SENDERS {
+			limiter.prune(now)
+		}
+		bucket = &tokenBucket{float64(limit.Burst), now}
+		limiter.buckets[key] = bucket
+	}
+	bucket.tokens = min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		limiter.limited.Add(1)
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// Forgets the buckets that have refilled, their senders start over with a full bucket anyway.
+func (limiter *rateLimiter) prune(now time.Time) {
+	for key, bucket := range limiter.buckets {
+		limit := limiter.limit(key.cmd)
+		if limit.unlimited() || bucket.tokens+now.Sub(bucket.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// Limits the requests for command the node handles from each sender, senders are told apart by
+// IP as ids are cheap to change. A zero limit removes the command's own limit, leaving it to the
+// default limit. Returns an error if the limit has a negative rate, or a burst below one.
+func (node *Node) SetRateLimit(command Command, limit RateLimit) error {
+	if err := checkRateLimit(limit); err != nil {
+		return err
+	}
+	node.limiter.Lock()
+	defer node.limiter.Unlock()
+	if limit.unlimited() {
+		delete(node.limiter.limits, command)
+	} else {
+		node.limiter.limits[command] = limit
+	}
+	return nil
+}
+
+// Sets the limit of the commands without a limit of their own, see SetRateLimit. The zero value,
+// the default, does not limit them.
+func (node *Node) SetDefaultRateLimit(limit RateLimit) error {
+	if err := checkRateLimit(limit); err != nil {
+		return err
+	}
+	node.limiter.Lock()
+	defer node.limiter.Unlock()
+	node.limiter.fallback = limit
+	return nil
+}
+
+func checkRateLimit(limit RateLimit) error {
+	if !limit.unlimited() && (limit.Rate < 0 || limit.Burst < 1) {
+		return errors.New(fmt.Sprintf("invalid rate limit of %v per second with a burst of %d", limit.Rate, limit.Burst))
+	}
+	return nil
+}
+
+// Returns the number of requests the node dropped because their sender exceeded its rate limit.
+func (node *Node) RateLimited() uint64 {
+	return node.limiter.limited.Load()
+}
@@ -0,0 +1,60 @@
+package kademlia
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	testName := "TestRateLimit"
+	node, script, peers := scriptedNode(2)
+	clock := NewFakeClock(time.Now())
+	node.SetTimebase(clock)
+	if node.SetRateLimit(PING, RateLimit{Rate: 1, Burst: 0}) == nil {
+		log.Printf("[%s] - accepted a rate limit without a burst", testName)
+		t.Fail()
+	}
+	node.SetRateLimit(PING, RateLimit{Rate: 1, Burst: 2})
+
+	ping := func(peer Contact) {
+		rpc := GenerateRPC(node.IP(), peer)
+		rpc.Ping()
+		node.Handler(&rpc)
+	}
+	for range 5 {
+		ping(peers[0])
+	}
+	ping(peers[1])
+	if answered := len(script.Sent(PONG)); answered != 3 || node.RateLimited() != 3 {
+		log.Printf("[%s] - expected 3 answered and 3 limited pings, got %d and %d", testName, answered, node.RateLimited())
+		t.Fail()
+	}
+
+	rpc := GenerateRPC(node.IP(), peers[0])
+	rpc.FindNode(peers[1].ID())
+	node.Handler(&rpc)
+	node.routines.Wait(context.Background())
+	if len(script.Sent(FOUND_NODES)) != 1 {
+		log.Printf("[%s] - a command without a limit was limited", testName)
+		t.Fail()
+	}
+
+	clock.Advance(time.Second)
+	ping(peers[0])
+	ping(peers[0])
+	if answered := len(script.Sent(PONG)); answered != 4 {
+		log.Printf("[%s] - expected the bucket to refill one token per second, got %d answers", testName, answered)
+		t.Fail()
+	}
+
+	node.SetRateLimit(PING, RateLimit{})
+	for range 5 {
+		ping(peers[0])
+	}
+	if answered := len(script.Sent(PONG)); answered != 9 {
+		log.Printf("[%s] - expected removing the limit to answer every ping, got %d answers", testName, answered)
+		t.Fail()
+	}
+}
@@ -0,0 +1,128 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const RECURSIVE_HOP_LIMIT = 20 // most nodes a recursive lookup passes through, at most MAX_HOPS
+
+// Outcome of a recursive lookup, see FindNodeRecursive.
+type RecursiveLookup struct {
+	Path  []Contact   // nodes the request passed through in order, the last one answered
+	Stats LookupStats // every hop is one request, RPCs does not count the responses travelling back
+}
+
+// Set a RPC as a recursive find node request, path holding the nodes it passed through so far
+// starting with the requester.
+func (rpc *RPC) RecursiveFindNode(target KademliaID, path []Contact) {
+	rpc.cmd = RECURSIVE_FIND_NODE
+	rpc.findNodeTarget = target
+	rpc.path = path
+}
+
+// Answers a recursive find node with the closest contacts of the node the request ended at.
+func (rpc *RPC) RecursiveFoundNodes(target KademliaID, nodes []Contact, path []Contact) {
+	rpc.cmd = RECURSIVE_FOUND_NODES
+	rpc.findNodeTarget = target
+	rpc.foundNodes = nodes
+	rpc.path = path
+}
+
+// Makes the node's lookups recursive, see FindNodeRecursive, falling back to an iterative lookup
+// if the answer does not make it back. Off by default.
+func (node *Node) SetRecursiveLookups(enabled bool) {
+	node.recursive.Store(enabled)
+}
+
+// Runs a recursive node lookup for target. Instead of querying every hop itself the node hands
+// the request to its closest contact, which forwards it to the closest contact it knows of, and
+// so on until the request reaches a node that knows of no contact closer to target than itself
+// or has passed through RECURSIVE_HOP_LIMIT nodes. That node answers with the K closest contacts
+// it knows of, itself included, and the answer travels back along the path. Nodes never forward
+// the request to a node already on its path, so it can not loop.
+// Returns the contacts found and the path the request took, or an error if the node knows of no
+// contacts or the answer did not make it back, a single unreachable hop loses the lookup.
+func (node *Node) FindNodeRecursive(target KademliaID) ([]Contact, RecursiveLookup, error) {
+	start := node.timebase.Now()
+	initNodes, _ := node.FindXClosest(node.config.Replication, target)
+	found, report, err := node.recursiveLookup(initNodes, target)
+	report.Stats.Duration = node.timebase.Since(start)
+	if err == nil {
+		node.completeLookup(target, found, report.Stats)
+	}
+	return found, report, err
+}
+
+func (node *Node) recursiveLookup(initNodes []Contact, target KademliaID) ([]Contact, RecursiveLookup, error) {
+	report := RecursiveLookup{}
+	i := slices.IndexFunc(initNodes, func(con Contact) bool { return con.ID() != node.ID() })
+	if i == -1 {
+		return nil, report, errors.New(fmt.Sprintf("no contact to start a recursive lookup of %v from", target))
+	}
+	rpc := GenerateRPC(initNodes[i].IP(), node.Contact)
+	rpc.RecursiveFindNode(target, []Contact{node.Contact})
+	res, err := node.Send(rpc)
+	if err == nil && res.cmd != RECURSIVE_FOUND_NODES {
+		err = errors.New(fmt.Sprintf("recursive lookup answered with %s", res.cmd))
+	}
+	if err != nil {
+		report.Stats = LookupStats{Hops: 1, RPCs: 1, Failed: 1}
+		return nil, report, err
+	}
+	if len(res.path) > 1 {
+		report.Path = res.path[1:]
+	}
+	report.Stats.Hops = len(report.Path)
+	report.Stats.RPCs = len(report.Path)
+	return res.foundNodes, report, nil
+}
+
+// Returns the closest contact to target that is not on the path.
+func (node *Node) nextHop(target KademliaID, path []Contact) (Contact, bool) {
+	onPath := func(con Contact) bool {
+		return slices.ContainsFunc(path, func(visited Contact) bool { return visited.ID() == con.ID() })
+	}
+	closest, _ := node.FindXClosest(node.config.Replication+len(path), target)
+	for _, con := range closest {
+		if con.ID() != node.ID() && !onPath(con) {
+			return con, true
+		}
+	}
+	return Contact{}, false
+}
+
+// Response logic for an incoming recursive find node RPC.
+// Forwards the request to the closest contact not yet on its path if that contact is closer to
+// the target than the node and the request has hops left, and relays the answer back. Otherwise,
+// or if forwarding fails, answers with the node's own closest contacts. A request that already
+// passed through the node is answered right away.
+func (node *Node) handleRecursiveFindNode(rpc *RPC) {
+	target := rpc.findNodeTarget
+	looped := slices.ContainsFunc(rpc.path, func(con Contact) bool { return con.ID() == node.ID() })
+	path := slices.Clone(rpc.path)
+	if !looped {
+		path = append(path, node.Contact)
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	next, ok := node.nextHop(target, path)
+	if !looped && ok && rpc.hops+1 < RECURSIVE_HOP_LIMIT && CloserNode(next.ID(), node.ID(), target) {
+		fwd := GenerateRPC(next.IP(), node.Contact)
+		fwd.RecursiveFindNode(target, path)
+		fwd.forwardOf(rpc)
+		res, err := node.Send(fwd)
+		if err == nil && res.cmd == RECURSIVE_FOUND_NODES {
+			resp.RecursiveFoundNodes(target, res.foundNodes, res.path)
+			node.Send(resp)
+			return
+		}
+		node.logger.Debug("recursive lookup not forwarded, answering", "rpc", rpc.id, "next", next.ID(), "err", err)
+	}
+	closest, _ := node.FindXClosest(node.config.Replication, target)
+	closest = append(closest, node.Contact)
+	SortContactsByDistance(&closest, target)
+	resp.RecursiveFoundNodes(target, closest[:min(len(closest), node.config.Replication)], path)
+	node.logger.Debug("answering recursive lookup", "rpc", rpc.id, "target", target, "hops", len(path)-1)
+	node.Send(resp)
+}
@@ -0,0 +1,71 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestFindNodeRecursive(t *testing.T) {
+	testName := "TestFindNodeRecursive"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	for i := 1; i < 6; i++ {
+		found, report, err := nodes[0].FindNodeRecursive(nodes[i].ID())
+		if err != nil {
+			log.Printf("[%s] - %s", testName, err.Error())
+			t.FailNow()
+		}
+		if len(found) == 0 || found[0].ID() != nodes[i].ID() {
+			log.Printf("[%s] - recursive lookup did not find node %d", testName, i)
+			t.Fail()
+		}
+		if len(report.Path) == 0 || len(report.Path) > RECURSIVE_HOP_LIMIT || report.Stats.RPCs != len(report.Path) {
+			log.Printf("[%s] - unexpected path of %d hops for %d rpcs", testName, len(report.Path), report.Stats.RPCs)
+			t.Fail()
+		}
+		visited := map[KademliaID]bool{nodes[0].ID(): true}
+		for _, con := range report.Path {
+			if visited[con.ID()] {
+				log.Printf("[%s] - path visits %v twice", testName, con.ID())
+				t.Fail()
+			}
+			visited[con.ID()] = true
+		}
+	}
+
+	nodes[0].SetRecursiveLookups(true)
+	found, stats := nodes[0].FindNodeWithStats(nodes[7].ID())
+	if len(found) == 0 || found[0].ID() != nodes[7].ID() || stats.Failed != 0 {
+		log.Printf("[%s] - recursive lookup mode did not find the target: %+v", testName, stats)
+		t.Fail()
+	}
+	if hops := s.Stats().LookupHops; hops.Count < 6 || hops.Max == 0 {
+		log.Printf("[%s] - lookups missing from the hop histogram: %s", testName, hops.Display())
+		t.Fail()
+	}
+
+	// a request forwarded up to the hop limit is answered by its receiver
+	rpc := GenerateRPC(nodes[2].IP(), nodes[0].Contact)
+	rpc.RecursiveFindNode(nodes[9].ID(), []Contact{nodes[0].Contact})
+	rpc.hops = RECURSIVE_HOP_LIMIT - 1
+	res, err := nodes[0].Send(rpc)
+	if err != nil || len(res.path) != 2 || res.path[1].ID() != nodes[2].ID() {
+		log.Printf("[%s] - hop limit not respected, path %v: %v", testName, res.path, err)
+		t.Fail()
+	}
+
+	// a request that already passed through its receiver is answered without being forwarded again
+	rpc = GenerateRPC(nodes[2].IP(), nodes[0].Contact)
+	rpc.RecursiveFindNode(nodes[9].ID(), []Contact{nodes[0].Contact, nodes[2].Contact})
+	res, err = nodes[0].Send(rpc)
+	if err != nil || len(res.path) != 2 || len(res.foundNodes) == 0 {
+		log.Printf("[%s] - looping request was forwarded, path %v: %v", testName, res.path, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,164 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Outcome of a wallet read, see ReadWallet.
+type WalletRead struct {
+	Wallet     Wallet            // the latest state held by any validator
+	Status     ReplicationStatus // the replicas as the read found them, before any repair
+	Repaired   []Contact         // stale validators brought up to date by the read
+	Unrepaired []Contact         // stale validators that failed to catch up
+}
+
+// Returns true if the read found a reachable validator missing the wallet or holding an
+// outdated state.
+func (read WalletRead) Degraded() bool {
+	return len(read.Repaired)+len(read.Unrepaired) > 0
+}
+
+// A read found validators missing a wallet or holding an outdated state and asked them to catch up,
+// see ReadWallet, ShowWallet and FindAccount.
+type WalletRepaired struct {
+	Node       Contact
+	Wallet     KademliaID
+	Repaired   []Contact // stale validators brought up to date
+	Unrepaired []Contact // stale validators that failed to catch up
+}
+
+func (WalletRepaired) event() {}
+
+// Set a RPC as a request to a validator to catch up on the wallet from source, a validator holding
+// its latest state.
+func (rpc *RPC) RepairWallet(walletID KademliaID, source Contact) {
+	rpc.cmd = REPAIR_WALLET
+	rpc.accountID = walletID
+	rpc.foundNodes = []Contact{source}
+}
+
+// Answers a repair wallet request with the validator's copy of the wallet once it has caught up.
+func (rpc *RPC) RepairedWallet(wallet Wallet, found bool) {
+	rpc.cmd = REPAIRED_WALLET
+	rpc.accountID = wallet.ID
+	rpc.wallet = wallet
+	rpc.walletStored = found
+}
+
+// Reads the wallet from all of its validators and repairs the replicas the read finds degraded.
+// Each reachable validator missing the wallet or holding an outdated state is asked to catch up
+// from the closest validator holding the latest state, see Reconcile, instead of waiting for the
+// periodic wallet sync. The repair is reported in the result.
+// Returns an error if no validator holds the wallet.
+func (node *Node) ReadWallet(id KademliaID) (WalletRead, error) {
+	status := node.ReplicationStatus(id)
+	read := WalletRead{
+		Status:     status,
+		Repaired:   make([]Contact, 0),
+		Unrepaired: make([]Contact, 0),
+	}
+	if status.Holders == 0 {
+		return read, errors.New(fmt.Sprintf("did not find wallet: %v", id))
+	}
+	var source Contact
+	for _, rep := range status.Replicas {
+		if rep.Holds && rep.Version == status.Latest {
+			read.Wallet, source = rep.Wallet, rep.Contact
+			break
+		}
+	}
+	read.Repaired, read.Unrepaired = node.repairWallet(id, source, status.Latest, status.Stale())
+	return read, nil
+}
+
+// Repairs the stale validators a read found in the background, from source which holds read, the
+// state the read returns. late returns the stale validators among those the read did not wait
+// for, it is called in the background as well. Stale contacts that are not among the wallet's
+// validators are skipped.
+func (node *Node) repairOnRead(id KademliaID, validators []Contact, source Contact, read Wallet, stale []Contact, late func() []Contact) {
+	validators = node.walletValidators(validators, id, read.Replication)
+	node.routines.Go("repair on read", func() {
+		stale = append(stale, late()...)
+		stale = slices.DeleteFunc(stale, func(con Contact) bool { return !SliceContains(con.ID(), &validators) })
+		if len(stale) > 0 {
+			node.repairWallet(id, source, read.Transactions, stale)
+		}
+	})
+}
+
+// Asks the validators for their copy of the wallet in parallel.
+// Returns the validators that answered without the wallet or with fewer than latest transactions.
+func (node *Node) staleReplicas(id KademliaID, latest int, validators []Contact) []Contact {
+	behind := make([]bool, len(validators))
+	done := make(chan struct{}, len(validators))
+	for i, val := range validators {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.ShowWallet(id)
+		node.routines.Go("check replica", func() {
+			defer func() { done <- struct{}{} }()
+			res, err := node.Send(rpc)
+			behind[i] = err == nil && (!res.walletStored || res.wallet.Transactions < latest)
+		})
+	}
+	for range validators {
+		<-done
+	}
+	stale := make([]Contact, 0)
+	for i, val := range validators {
+		if behind[i] {
+			stale = append(stale, val)
+		}
+	}
+	return stale
+}
+
+// Asks each stale validator to catch up on the wallet from source, a validator holding at least
+// latest transactions, and publishes the outcome as a WalletRepaired event if there was anything
+// to repair.
+// Returns the validators that caught up to latest transactions and those that did not.
+func (node *Node) repairWallet(id KademliaID, source Contact, latest int, stale []Contact) (repaired []Contact, unrepaired []Contact) {
+	caughtUp := make([]bool, len(stale))
+	done := make(chan struct{}, len(stale))
+	for i, val := range stale {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.RepairWallet(id, source)
+		node.routines.Go("repair wallet", func() {
+			defer func() { done <- struct{}{} }()
+			res, err := node.Send(rpc)
+			caughtUp[i] = err == nil && res.walletStored && res.wallet.Transactions >= latest
+		})
+	}
+	for range stale {
+		<-done
+	}
+	repaired, unrepaired = make([]Contact, 0), make([]Contact, 0)
+	for i, val := range stale {
+		if caughtUp[i] {
+			repaired = append(repaired, val)
+		} else {
+			unrepaired = append(unrepaired, val)
+		}
+	}
+	if len(stale) > 0 {
+		node.logger.Debug("repaired wallet on read", "wallet", id, "repaired", len(repaired), "unrepaired", len(unrepaired))
+		node.publish(WalletRepaired{node.Contact, id, repaired, unrepaired})
+	}
+	return repaired, unrepaired
+}
+
+// Response logic for an incoming repair wallet RPC. The node reconciles with the source named by
+// the reader, so it only takes states it would accept from the periodic sync, and answers with
+// its copy of the wallet.
+func (node *Node) handleRepairWallet(rpc *RPC) {
+	if len(rpc.foundNodes) == 1 {
+		if _, err := node.Reconcile(rpc.foundNodes[0]); err != nil {
+			node.logger.Debug("failed to repair wallet", "rpc", rpc.id, "wallet", rpc.accountID, "err", err)
+		}
+	}
+	wallet, err := node.Ledger().Wallet(rpc.accountID)
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.RepairedWallet(wallet, err == nil)
+	node.Send(resp)
+}
@@ -0,0 +1,230 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+	"time"
+)
+
+func TestReadWalletRepairs(t *testing.T) {
+	testName := "TestReadWalletRepairs"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if _, err := nodes[0].ReadWallet(id); err == nil {
+		log.Printf("[%s] - read a wallet that was never submitted", testName)
+		t.Fail()
+	}
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+
+	// one validator misses a transaction the others applied and another loses the wallet
+	holders := make([]*Node, 0)
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err == nil {
+			holders = append(holders, n)
+		}
+	}
+	behind, lost := holders[0], holders[1]
+	trx := scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 5)
+	for _, n := range holders[1:] {
+		n.scalegraph.ApplyTransaction(id, trx.Copy())
+	}
+	lost.scalegraph.RemoveAccount(id)
+
+	read, err := nodes[0].ReadWallet(id)
+	if err != nil || read.Wallet.Balance != 15 {
+		log.Printf("[%s] - read did not return the latest state: %+v %v", testName, read.Wallet, err)
+		t.FailNow()
+	}
+	repaired := map[KademliaID]bool{}
+	for _, con := range read.Repaired {
+		repaired[con.ID()] = true
+	}
+	if !read.Degraded() || len(read.Unrepaired) != 0 || !repaired[behind.ID()] || !repaired[lost.ID()] {
+		log.Printf("[%s] - degraded replicas were not repaired: %v unrepaired %v", testName, read.Repaired, read.Unrepaired)
+		t.Fail()
+	}
+	for _, n := range []*Node{behind, lost} {
+		if wallet, err := n.Ledger().Wallet(id); err != nil || wallet.Balance != 15 {
+			log.Printf("[%s] - %v holds %+v %v after the repair", testName, n.ID(), wallet, err)
+			t.Fail()
+		}
+	}
+	if read, _ := nodes[0].ReadWallet(id); read.Degraded() || !read.Status.Healthy() {
+		log.Printf("[%s] - second read found the replicas degraded:\n%s", testName, read.Status.Display())
+		t.Fail()
+	}
+}
+
+func TestFindAccountRepairs(t *testing.T) {
+	testName := "TestFindAccountRepairs"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	holders := make([]*Node, 0)
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err == nil {
+			holders = append(holders, n)
+		}
+	}
+	lost := holders[:2]
+	for _, n := range lost {
+		n.scalegraph.RemoveAccount(id)
+	}
+
+	// the lookup requires every validator, which it reaches by repairing the two that lost the wallet
+	found, missing, err := nodes[0].findAccount(id, ALL)
+	if err != nil || len(found) != len(holders) || len(missing) != 0 {
+		log.Printf("[%s] - found %d holders and %d missing: %v", testName, len(found), len(missing), err)
+		t.Fail()
+	}
+	for _, n := range lost {
+		if wallet, err := n.Ledger().Wallet(id); err != nil || wallet.Balance != 10 {
+			log.Printf("[%s] - %v holds %+v %v after the lookup", testName, n.ID(), wallet, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestShowWalletRepairs(t *testing.T) {
+	testName := "TestShowWalletRepairs"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	var behind *Node
+	trx := scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 5)
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err != nil {
+			continue
+		}
+		if behind == nil {
+			behind = n
+		} else {
+			n.scalegraph.ApplyTransaction(id, trx.Copy())
+		}
+	}
+
+	events, cancel := s.Events().Subscribe(1 << 16)
+	defer cancel()
+	nodes[0].config.ReadConsistency = ALL
+	if wallet, err := nodes[0].ShowWallet(id); err != nil || wallet.Balance != 15 {
+		log.Printf("[%s] - read did not return the latest state: %+v %v", testName, wallet, err)
+		t.FailNow()
+	}
+	timeout := time.After(2 * TIMEOUT)
+	for {
+		select {
+		case ev := <-events:
+			repair, ok := ev.(WalletRepaired)
+			if !ok || repair.Node.ID() != nodes[0].ID() {
+				continue
+			}
+			if len(repair.Repaired) != 1 || repair.Repaired[0].ID() != behind.ID() {
+				log.Printf("[%s] - expected %v to be repaired, got %v unrepaired %v", testName, behind.ID(), repair.Repaired, repair.Unrepaired)
+				t.Fail()
+			}
+			if wallet, err := behind.Ledger().Wallet(id); err != nil || wallet.Balance != 15 {
+				log.Printf("[%s] - %v holds %+v %v after the repair", testName, behind.ID(), wallet, err)
+				t.Fail()
+			}
+			return
+		case <-timeout:
+			log.Printf("[%s] - the read did not repair the replica behind", testName)
+			t.FailNow()
+		}
+	}
+}
+
+func TestShowWalletRepairsAtOne(t *testing.T) {
+	testName := "TestShowWalletRepairsAtOne"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	// only the validator asked first keeps the wallet, the read stops at it
+	byID := make(map[KademliaID]*Node)
+	for _, n := range s.AllNodePointers() {
+		byID[n.ID()] = n
+	}
+	lost := make([]*Node, 0)
+	kept := false
+	for _, val := range nodes[0].OrderByLatency(nodes[0].FindNode(id)) {
+		n, ok := byID[val.ID()]
+		if !ok || n == nodes[0] {
+			continue
+		}
+		if _, err := n.Ledger().Wallet(id); err != nil {
+			continue
+		}
+		if kept {
+			n.scalegraph.RemoveAccount(id)
+			lost = append(lost, n)
+		}
+		kept = true
+	}
+
+	events, cancel := s.Events().Subscribe(1 << 16)
+	defer cancel()
+	nodes[0].config.ReadConsistency = ONE
+	if wallet, err := nodes[0].ShowWallet(id); err != nil || wallet.Balance != 10 {
+		log.Printf("[%s] - read failed: %+v %v", testName, wallet, err)
+		t.FailNow()
+	}
+	timeout := time.After(4 * TIMEOUT)
+	for {
+		select {
+		case ev := <-events:
+			repair, ok := ev.(WalletRepaired)
+			if !ok || repair.Node.ID() != nodes[0].ID() {
+				continue
+			}
+			if len(repair.Repaired) != len(lost) {
+				log.Printf("[%s] - expected %d validators the read did not ask to be repaired, got %v unrepaired %v", testName, len(lost), repair.Repaired, repair.Unrepaired)
+				t.Fail()
+			}
+			return
+		case <-timeout:
+			log.Printf("[%s] - the read did not repair the validators it did not ask", testName)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package kademlia
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const REPLAY_SETTLE = 2 * TIMEOUT // how long Replay waits for the responses to the replayed requests
+
+// Requests Replay routes again, those a trace captures in full: their payload is at most a key.
+var replayable = map[cmd]bool{
+	PING:         true,
+	FIND_NODE:    true,
+	FIND_ACCOUNT: true,
+	FIND_VALUE:   true,
+	FIND_BALANCE: true,
+}
+
+// A replayed request answered differently than when it was recorded.
+type ReplayMismatch struct {
+	ID       string
+	Cmd      string
+	Recorded string // command of the recorded response
+	Replayed string // command of the response during the replay, empty if there was none
+}
+
+// Outcome of a replay.
+type ReplayReport struct {
+	Replayed   int // requests routed again
+	Skipped    int // requests whose payload the trace does not capture, responses are not counted
+	Matched    int // replayed requests answered with the recorded response command
+	Mismatches []ReplayMismatch
+	Trace      []TraceRecord // the RPCs routed for the replayed requests, in the order they were routed
+}
+
+// Reads a trace written by RecordTrace or WriteTrace, in either format.
+// Returns an error if a record can not be parsed.
+func ReadTrace(in io.Reader) ([]TraceRecord, error) {
+	reader := bufio.NewReader(in)
+	first, err := reader.Peek(1)
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if first[0] != '{' {
+		return readCSVTrace(reader)
+	}
+	res := make([]TraceRecord, 0)
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record := TraceRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid trace record on line %d: %s", line, err.Error()))
+		}
+		res = append(res, record)
+	}
+	return res, scanner.Err()
+}
+
+func readCSVTrace(in io.Reader) ([]TraceRecord, error) {
+	rows, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || !slices.Equal(rows[0], traceHeader) {
+		return nil, errors.New("trace does not start with the trace header")
+	}
+	res := make([]TraceRecord, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		record := TraceRecord{ID: row[1], Cmd: row[2], Sender: row[4], SenderID: row[5], Receiver: row[6], Key: row[7], Reason: row[9]}
+		var errs [4]error
+		record.Time, errs[0] = strconv.ParseInt(row[0], 10, 64)
+		record.Response, errs[1] = strconv.ParseBool(row[3])
+		record.Dropped, errs[2] = strconv.ParseBool(row[8])
+		record.Latency, errs[3] = strconv.ParseInt(row[10], 10, 64)
+		if err := errors.Join(errs[:]...); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid trace record on line %d: %s", i+2, err.Error()))
+		}
+		res = append(res, record)
+	}
+	return res, nil
+}
+
+// Returns the protocol command with the given name.
+func parseCommand(name string) (cmd, bool) {
+	for command := NO_CMD; command <= LAST_PROTOCOL_CMD; command++ {
+		if command.String() == name {
+			return command, true
+		}
+	}
+	return NO_CMD, false
+}
+
+// Rebuilds the request of a trace record.
+func (record TraceRecord) request() (RPC, error) {
+	command, _ := parseCommand(record.Cmd)
+	var id, senderID, key KademliaID
+	if err := id.UnmarshalText([]byte(record.ID)); err != nil {
+		return RPC{}, errors.New(fmt.Sprintf("invalid id %q: %s", record.ID, err.Error()))
+	}
+	if err := senderID.UnmarshalText([]byte(record.SenderID)); err != nil {
+		return RPC{}, errors.New(fmt.Sprintf("invalid sender id %q: %s", record.SenderID, err.Error()))
+	}
+	if record.Key != "" {
+		if err := key.UnmarshalText([]byte(record.Key)); err != nil {
+			return RPC{}, errors.New(fmt.Sprintf("invalid key %q: %s", record.Key, err.Error()))
+		}
+	}
+	sender, err := netip.ParseAddr(record.Sender)
+	if err != nil || !sender.Is4() {
+		return RPC{}, errors.New(fmt.Sprintf("invalid sender %q", record.Sender))
+	}
+	receiver, err := netip.ParseAddr(record.Receiver)
+	if err != nil || !receiver.Is4() {
+		return RPC{}, errors.New(fmt.Sprintf("invalid receiver %q", record.Receiver))
+	}
+	rpc := GenerateRPC(receiver.As4(), NewContact(sender.As4(), senderID))
+	rpc.id = id
+	rpc.cmd = command
+	rpc.caps = DEFAULT_CAPABILITIES
+	if command == FIND_NODE {
+		rpc.findNodeTarget = key
+	} else {
+		rpc.accountID = key
+	}
+	return rpc, nil
+}
+
+// Routes the requests of a recorded trace through the simnet again, in the order they were
+// recorded, and compares the responses with the recorded ones. The responses go to the recorded
+// senders, so a simnet spawned from the same seed as the recorded one reproduces the workload
+// between the same nodes. With a FakeClock as the simnet's timebase the clock is advanced by the
+// recorded gap before each request, so the nodes' timers fire as they did while recording, on
+// the system clock the requests are routed back to back. Requests whose payload the trace does
+// not capture are skipped, and the replay waits up to REPLAY_SETTLE for the last responses.
+// Returns an error if a record can not be replayed or a trace is being recorded.
+func (simnet *Simnet) Replay(records []TraceRecord) (ReplayReport, error) {
+	report := ReplayReport{}
+	records = slices.Clone(records)
+	slices.SortStableFunc(records, func(a, b TraceRecord) int { return cmp.Compare(a.Time, b.Time) })
+	recorded := make(map[string]string)
+	requests := make([]RPC, 0)
+	times := make([]int64, 0)
+	for _, record := range records {
+		if record.Response {
+			if !record.Dropped {
+				recorded[record.ID] = record.Cmd
+			}
+			continue
+		}
+		if command, ok := parseCommand(record.Cmd); !ok || !replayable[command] {
+			report.Skipped++
+			continue
+		}
+		rpc, err := record.request()
+		if err != nil {
+			return report, err
+		}
+		requests = append(requests, rpc)
+		times = append(times, record.Time)
+	}
+
+	// collect the replayed requests and their responses, done is closed once every request with
+	// a recorded response has been answered
+	var lock sync.Mutex
+	replayed := make(map[string]string)
+	pending := make(map[string]bool)
+	ids := make(map[string]bool, len(requests))
+	for _, rpc := range requests {
+		ids[rpc.id.String()] = true
+		if _, ok := recorded[rpc.id.String()]; ok {
+			pending[rpc.id.String()] = true
+		}
+	}
+	done := make(chan struct{})
+	if len(pending) == 0 {
+		close(done)
+	}
+	recorder, _ := newTraceRecorder(nil, TraceOptions{})
+	recorder.collect = func(record TraceRecord) {
+		lock.Lock()
+		defer lock.Unlock()
+		if !ids[record.ID] {
+			return
+		}
+		report.Trace = append(report.Trace, record)
+		if record.Response && !record.Dropped && pending[record.ID] {
+			replayed[record.ID] = record.Cmd
+			delete(pending, record.ID)
+			if len(pending) == 0 {
+				close(done)
+			}
+		}
+	}
+	stop, err := simnet.startTrace(recorder)
+	if err != nil {
+		return report, err
+	}
+
+	simnet.spawned.RLock()
+	version, minVersion := simnet.version, simnet.minVersion
+	simnet.spawned.RUnlock()
+	fake, virtual := simnet.timebase.(*FakeClock)
+	for i, rpc := range requests {
+		if virtual && i > 0 && times[i] > times[i-1] {
+			fake.Advance(time.Duration(times[i] - times[i-1]))
+		}
+		rpc.network = DEFAULT_NETWORK
+		rpc.version, rpc.minVersion = version, minVersion
+		simnet.Route(rpc)
+		report.Replayed++
+	}
+	select {
+	case <-done:
+	case <-time.After(simnet.config.scaled(REPLAY_SETTLE)):
+	}
+	stop()
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, rpc := range requests {
+		id := rpc.id.String()
+		want, ok := recorded[id]
+		if !ok {
+			continue
+		}
+		if got := replayed[id]; got == want {
+			report.Matched++
+		} else {
+			report.Mismatches = append(report.Mismatches, ReplayMismatch{id, rpc.cmd.String(), want, got})
+		}
+	}
+	return report, nil
+}
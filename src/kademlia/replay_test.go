@@ -0,0 +1,91 @@
+package kademlia
+
+import (
+	"bytes"
+	"log"
+	"slices"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	testName := "TestReplay"
+	spawn := func() (*Simnet, []*Node) {
+		done := make(chan struct{}, 1)
+		s := NewSeededServer(false, 0.0, 7)
+		s.Silence()
+		go s.StartServer()
+		nodes := s.SpawnCluster(15, done)
+		<-done
+		return s, nodes
+	}
+
+	recorded, nodes := spawn()
+	out := bytes.Buffer{}
+	stop, err := recorded.RecordTrace(&out, TraceOptions{Format: TRACE_CSV})
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	key, _ := nodes[2].StoreValue([]byte("replayed"))
+	nodes[0].FindNode(nodes[1].ID())
+	nodes[3].FindValue(key)
+	nodes[4].Ping(nodes[5].IP())
+	stop()
+	recorded.Shutdown()
+
+	trace, err := ReadTrace(&out)
+	if err != nil || len(trace) == 0 {
+		log.Printf("[%s] - could not read the trace back: %v", testName, err)
+		t.FailNow()
+	}
+	// the formats hold the same records
+	jsonl := bytes.Buffer{}
+	WriteTrace(&jsonl, trace, TRACE_JSONL)
+	if again, err := ReadTrace(&jsonl); err != nil || !slices.Equal(again, trace) {
+		log.Printf("[%s] - trace changed across formats: %v", testName, err)
+		t.Fail()
+	}
+
+	// a simnet spawned from the same seed answers the replayed requests as the recorded one did
+	s, nodes := spawn()
+	defer s.Shutdown()
+	report, err := s.Replay(trace)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	if report.Replayed == 0 || report.Matched == 0 || len(report.Mismatches) > 0 {
+		log.Printf("[%s] - replayed %d, matched %d, mismatches %+v", testName, report.Replayed, report.Matched, report.Mismatches)
+		t.Fail()
+	}
+	if report.Skipped == 0 {
+		log.Printf("[%s] - expected the store value requests to be skipped", testName)
+		t.Fail()
+	}
+	if len(report.Trace) < report.Replayed {
+		log.Printf("[%s] - replay traced %d rpcs for %d requests", testName, len(report.Trace), report.Replayed)
+		t.Fail()
+	}
+
+	// a node missing from the replay leaves its requests unanswered
+	s.ShutdownNode(nodes[5])
+	report, err = s.Replay(trace)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	missed := slices.ContainsFunc(report.Mismatches, func(m ReplayMismatch) bool {
+		return m.Cmd == PING.String() && m.Recorded == PONG.String() && m.Replayed == ""
+	})
+	if !missed {
+		log.Printf("[%s] - the ping to a missing node was not reported: %+v", testName, report.Mismatches)
+		t.Fail()
+	}
+
+	stop, _ = s.RecordTrace(&bytes.Buffer{}, TraceOptions{})
+	if _, err := s.Replay(trace); err == nil {
+		log.Printf("[%s] - replayed while a trace was being recorded", testName)
+		t.Fail()
+	}
+	stop()
+}
@@ -0,0 +1,114 @@
+package kademlia
+
+import "fmt"
+
+// State of an account on one of the K closest nodes of its id.
+type ReplicaStatus struct {
+	Contact   Contact
+	Reachable bool   // the node answered the query
+	Holds     bool   // the node stores the account
+	Version   int    // transactions in the node's copy of the account
+	Balance   uint64 // balance of the node's copy
+	Wallet    Wallet // the node's copy, zero if it does not hold the account
+}
+
+// Replication health of an account as observed by a single round of queries.
+type ReplicationStatus struct {
+	Account   KademliaID
+	Expected  int             // replicas of a full set, the account's replication or K, the replication of the node that looked them up
+	Replicas  []ReplicaStatus // one entry per validator, closest first
+	Reachable int             // replicas that answered
+	Holders   int             // replicas that store the account
+	Latest    int             // highest version held by any replica
+	Current   int             // replicas holding the latest version
+}
+
+// Returns true if the lookup found a full set of K closest nodes and every one of them holds the
+// latest version of the account. Lookups skip nodes that fail to answer, so lost replicas show up
+// as a short replica set until the network learns of their replacements.
+func (status ReplicationStatus) Healthy() bool {
+	return len(status.Replicas) == status.Expected && status.Current == status.Expected
+}
+
+// Returns true if a majority of the K closest nodes holds the latest version of the account.
+func (status ReplicationStatus) Quorum() bool {
+	return status.Current > len(status.Replicas)/2
+}
+
+// Returns the reachable replicas that are missing the account or hold an outdated version,
+// the nodes a repair job has to bring up to date.
+func (status ReplicationStatus) Stale() []Contact {
+	res := make([]Contact, 0)
+	for _, rep := range status.Replicas {
+		if rep.Reachable && (!rep.Holds || rep.Version < status.Latest) {
+			res = append(res, rep.Contact)
+		}
+	}
+	return res
+}
+
+func (status ReplicationStatus) Display() string {
+	res := fmt.Sprintf("account: %v replicas: %d reachable: %d holders: %d current: %d latest version: %d\n",
+		status.Account, len(status.Replicas), status.Reachable, status.Holders, status.Current, status.Latest)
+	for _, rep := range status.Replicas {
+		res += fmt.Sprintf("%v reachable: %t holds: %t version: %d balance: %d\n", rep.Contact.IP(), rep.Reachable, rep.Holds, rep.Version, rep.Balance)
+	}
+	return res
+}
+
+// Queries the K closest nodes of the account in parallel and reports which of them hold it and
+// at which version. Accounts stored with a replication of their own, as reported by the holders,
+// are reported over that many closest nodes.
+func (node *Node) ReplicationStatus(accID KademliaID) ReplicationStatus {
+	validators := node.FindNode(accID)
+	status := ReplicationStatus{
+		Account:  accID,
+		Expected: node.config.Replication,
+		Replicas: make([]ReplicaStatus, len(validators)),
+	}
+	done := make(chan struct{}, len(validators))
+	for i, val := range validators {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.ShowWallet(accID)
+		node.routines.Go("replication status", func() {
+			defer func() { done <- struct{}{} }()
+			rep := ReplicaStatus{Contact: val}
+			res, err := node.Send(rpc)
+			if err == nil {
+				rep.Reachable = true
+				rep.Holds = res.walletStored
+				rep.Version = res.wallet.Transactions
+				rep.Balance = res.wallet.Balance
+				rep.Wallet = res.wallet
+			}
+			status.Replicas[i] = rep
+		})
+	}
+	for range validators {
+		<-done
+	}
+	replication := 0
+	for _, rep := range status.Replicas {
+		if rep.Holds {
+			replication = max(replication, rep.Wallet.Replication)
+		}
+	}
+	status.Expected = node.walletReplication(replication)
+	status.Replicas = status.Replicas[:min(len(status.Replicas), status.Expected)]
+
+	for _, rep := range status.Replicas {
+		if rep.Reachable {
+			status.Reachable++
+		}
+		if rep.Holds {
+			status.Holders++
+			status.Latest = max(status.Latest, rep.Version)
+		}
+	}
+	for _, rep := range status.Replicas {
+		if rep.Holds && rep.Version == status.Latest {
+			status.Current++
+		}
+	}
+	return status
+}
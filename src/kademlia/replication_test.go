@@ -0,0 +1,59 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+)
+
+func TestReplicationStatus(t *testing.T) {
+	testName := "TestReplicationStatus"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	status := nodes[1].ReplicationStatus(id)
+	if !status.Healthy() || status.Holders != len(status.Replicas) || status.Latest != 1 || len(status.Stale()) != 0 {
+		log.Printf("[%s] - expected a healthy account:\n%s", testName, status.Display())
+		t.Fail()
+	}
+
+	// One replica falls behind and another loses the account.
+	var behind, lost *Node
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err != nil {
+			continue
+		}
+		if behind == nil {
+			behind = n
+		} else if lost == nil {
+			lost = n
+		}
+	}
+	for _, n := range s.AllNodePointers() {
+		if n != behind {
+			n.scalegraph.ApplyTransaction(id, scalegraph.NewTransfer(scalegraph.MINT_ACCOUNT, id, 5))
+		}
+	}
+	lost.scalegraph.RemoveAccount(id)
+
+	status = nodes[1].ReplicationStatus(id)
+	if status.Healthy() || !status.Quorum() || status.Latest != 2 || status.Holders != len(status.Replicas)-1 {
+		log.Printf("[%s] - expected a degraded account:\n%s", testName, status.Display())
+		t.Fail()
+	}
+	stale := status.Stale()
+	if len(stale) != 2 || !SliceContains(behind.ID(), &stale) || !SliceContains(lost.ID(), &stale) {
+		log.Printf("[%s] - expected the lagging and the missing replica to be stale, got %v", testName, stale)
+		t.Fail()
+	}
+}
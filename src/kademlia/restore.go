@@ -0,0 +1,224 @@
+package kademlia
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+const SIMNET_SNAPSHOT_VERSION = 1 // format of the files written by Simnet.Snapshot
+
+// A contact in a node's routing table, in a simnet snapshot.
+type SnapshotContact struct {
+	ID   KademliaID
+	IP   [4]byte
+	Port int `json:",omitempty"`
+}
+
+// A value held by a node, in a simnet snapshot. TTL is the lifetime left when the snapshot was taken.
+type SnapshotValue struct {
+	Key         KademliaID
+	Data        []byte
+	TTL         time.Duration
+	Cached      bool
+	Replication int `json:",omitempty"` // zero for values kept at the K closest nodes
+}
+
+// State of one node in a simnet snapshot.
+type NodeSnapshot struct {
+	ID       KademliaID
+	IP       [4]byte
+	Network  NetworkID
+	Role     Role
+	Contacts []SnapshotContact
+	Values   []SnapshotValue
+	Wallets  []walletState
+}
+
+// State of every node of a simnet, see Simnet.Snapshot.
+type SimnetSnapshot struct {
+	Version int
+	Taken   time.Time
+	Config  Config
+	Master  KademliaID
+	Nodes   []NodeSnapshot
+}
+
+// Returns the values the node holds with the lifetime they have left.
+func (store *valueStore) snapshot(now time.Time) []SnapshotValue {
+	store.Lock()
+	defer store.Unlock()
+	res := make([]SnapshotValue, 0, len(store.stored)+len(store.cached))
+	for _, cache := range []bool{false, true} {
+		content := store.stored
+		if cache {
+			content = store.cached
+		}
+		for key, val := range content {
+			if val.expires.After(now) {
+				res = append(res, SnapshotValue{key, val.data, val.expires.Sub(now), cache, val.replication})
+			}
+		}
+	}
+	slices.SortFunc(res, func(a SnapshotValue, b SnapshotValue) int { return a.Key.Cmp(b.Key) })
+	return res
+}
+
+// Returns the node's id, ip, routing table, values and wallets.
+func (node *Node) snapshot() NodeSnapshot {
+	snap := NodeSnapshot{
+		ID:       node.ID(),
+		IP:       node.IP(),
+		Network:  node.NetworkID(),
+		Role:     node.Role(),
+		Contacts: make([]SnapshotContact, 0),
+		Values:   node.values.snapshot(node.Now()),
+		Wallets:  make([]walletState, 0),
+	}
+	for _, con := range node.AllContacts() {
+		snap.Contacts = append(snap.Contacts, SnapshotContact{con.ID(), con.IP(), con.Port()})
+	}
+	for _, id := range node.scalegraph.StoredAccounts() {
+		acc, err := node.scalegraph.FindAccount(id)
+		if err != nil {
+			continue
+		}
+		snap.Wallets = append(snap.Wallets, walletState{id, acc.PublicKey(), acc.Replication(), acc.Transactions()})
+	}
+	return snap
+}
+
+// Writes the id, ip, routing table, values and wallets of every node to path as JSON, so that a
+// converged network can be rebuilt with Restore instead of being spawned again. Each node is read
+// separately, take the snapshot while the simnet is idle to get a consistent one.
+// Returns an error if the nodes have identities, their private keys are not part of the snapshot,
+// or if the file can not be written.
+func (simnet *Simnet) Snapshot(path string) error {
+	if simnet.identities != NO_IDENTITIES {
+		return errors.New("can not snapshot nodes with identities")
+	}
+	snap := SimnetSnapshot{
+		Version: SIMNET_SNAPSHOT_VERSION,
+		Taken:   time.Now(),
+		Config:  simnet.config,
+		Master:  simnet.masterNode.ID(),
+		Nodes:   make([]NodeSnapshot, 0),
+	}
+	for _, n := range simnet.AllNodePointers() {
+		snap.Nodes = append(snap.Nodes, n.snapshot())
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+	err = json.NewEncoder(out).Encode(snap)
+	if err == nil {
+		err = out.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Rebuilds the network written to path by Snapshot. The nodes get their ids, ips, routing tables,
+// values and wallets back and resume without entering the network, the master node starts with
+// the server. Values keep the lifetime they had left when the snapshot was taken.
+// Must be called on a fresh simnet, before StartServer, created with the Kademlia parameters of
+// the snapshot and without identities. Its own master node is replaced by the snapshot's.
+// Returns the restored nodes other than the master node, or an error if the snapshot can not be
+// read or does not fit the simnet, the simnet is of no further use once restoring has started.
+func (simnet *Simnet) Restore(path string) ([]*Node, error) {
+	if simnet.started.Load() {
+		return nil, errors.New("can not restore a snapshot into a started simnet")
+	}
+	if simnet.identities != NO_IDENTITIES {
+		return nil, errors.New("can not restore a snapshot into a simnet with identities")
+	}
+	if len(simnet.AllNodePointers()) != 1 {
+		return nil, errors.New("can not restore a snapshot into a simnet that has spawned nodes")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	snap := SimnetSnapshot{}
+	err = json.NewDecoder(bufio.NewReader(file)).Decode(&snap)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("malformed snapshot %s: %s", path, err.Error()))
+	}
+	if snap.Version != SIMNET_SNAPSHOT_VERSION {
+		return nil, errors.New(fmt.Sprintf("unsupported snapshot version %d", snap.Version))
+	}
+	if snap.Config != simnet.config {
+		return nil, errors.New(fmt.Sprintf("snapshot taken with %+v, simnet uses %+v", snap.Config, simnet.config))
+	}
+	master := slices.IndexFunc(snap.Nodes, func(n NodeSnapshot) bool { return n.ID == snap.Master })
+	if master == -1 {
+		return nil, errors.New(fmt.Sprintf("snapshot holds no master node %v", snap.Master))
+	}
+	// the master node goes first, every other node learns its contact when it is generated
+	snap.Nodes[0], snap.Nodes[master] = snap.Nodes[master], snap.Nodes[0]
+
+	simnet.ShutdownNode(simnet.masterNode)
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	nodes := make([]*Node, 0, len(snap.Nodes))
+	for i, state := range snap.Nodes {
+		n := simnet.generateNodeAt(config, state.Network, state.ID, state.IP, state.Role)
+		if n == nil {
+			return nil, errors.New(fmt.Sprintf("snapshot holds node %v at %v twice", state.ID, state.IP))
+		}
+		if i == 0 {
+			simnet.masterNode = n
+			simnet.masterNodeContact = n.Contact
+			n.masterNode = n.Contact
+		}
+		err := n.restore(state)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	for _, n := range nodes[1:] {
+		n.resume()
+	}
+	simnet.logger.Info("restored snapshot", "path", path, "nodes", len(nodes), "taken", snap.Taken)
+	return nodes[1:], nil
+}
+
+// Fills a freshly generated node with the routing table, values and wallets of its snapshot.
+// Values are not handed over, the restored nodes already hold them.
+func (node *Node) restore(state NodeSnapshot) error {
+	onChange := node.RoutingTable.onChange
+	node.RoutingTable.onChange = nil
+	for _, con := range state.Contacts {
+		contact := NewContact(con.IP, con.ID)
+		contact.port = con.Port
+		node.RoutingTable.AddContact(contact)
+	}
+	node.RoutingTable.onChange = onChange
+
+	now := node.Now()
+	for _, val := range state.Values {
+		err := node.values.put(val.Key, val.Data, val.Cached, now.Add(val.TTL), val.Replication)
+		if err != nil {
+			return errors.New(fmt.Sprintf("node %v: %s", state.ID, err.Error()))
+		}
+	}
+	for _, wallet := range state.Wallets {
+		_, err := node.scalegraph.RestoreAccount(wallet.ID, wallet.PublicKey, wallet.Replication, wallet.Transactions)
+		if err != nil {
+			return errors.New(fmt.Sprintf("node %v: %s", state.ID, err.Error()))
+		}
+	}
+	return nil
+}
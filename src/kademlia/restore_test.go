@@ -0,0 +1,102 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Waits until the resumed nodes have finished the work they start right away, collecting their
+// messages and syncing their wallets together with the pings of the lookups involved, and the
+// simnet has routed every queued RPC. Lookups made before then compete with that burst.
+// Returns an error naming a node that is still busy once timeout has passed.
+func waitForResumed(simnet *Simnet, nodes []*Node, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		busy := slices.IndexFunc(nodes, func(n *Node) bool {
+			active := n.routines.Active()
+			return active["collect messages"] > 0 || active["sync wallet"] > 0 || active["ping"] > 0
+		})
+		if busy == -1 && simnet.RouteQueueDepth() == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if busy == -1 {
+				return errors.New(fmt.Sprintf("%d RPCs still queued after %v", simnet.RouteQueueDepth(), timeout))
+			}
+			return errors.New(fmt.Sprintf("node %d still busy after %v: %s", busy, timeout, displayRoutines(nodes[busy].routines.Active())))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSimnetRestore(t *testing.T) {
+	testName := "TestSimnetRestore"
+	path := filepath.Join(t.TempDir(), "simnet.json")
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+
+	from, to := RandomID(), RandomID()
+	if err := nodes[0].SubmitWallet(from, 100); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	nodes[0].SubmitWallet(to, 0)
+	if err := nodes[1].Transfer(from, to, 30); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	key, _ := nodes[2].StoreValue([]byte("restored"))
+	if err := s.Snapshot(path); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	master := s.MasterNode()
+	s.Shutdown()
+
+	restored := NewServer(false, 0.0)
+	restored.Silence()
+	nodes, err := restored.Restore(path)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	go restored.StartServer()
+	defer restored.Shutdown()
+	if len(nodes) != 20 || restored.MasterNode() != master {
+		log.Printf("[%s] - restored %d nodes under master %v, expected 20 under %v", testName, len(nodes), restored.MasterNode(), master)
+		t.FailNow()
+	}
+	if err := waitForResumed(restored, nodes, 10*time.Second); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	for i := 1; i < 5; i++ {
+		found := nodes[0].FindNode(nodes[i].ID())
+		if len(found) == 0 || found[0].ID() != nodes[i].ID() {
+			log.Printf("[%s] - restored node %d not found", testName, i)
+			t.Fail()
+		}
+	}
+	if data, err := nodes[5].FindValue(key); err != nil || string(data) != "restored" {
+		log.Printf("[%s] - value lost in the snapshot: %v", testName, err)
+		t.Fail()
+	}
+	if balance, err := nodes[6].Balance(to); err != nil || balance != 30 {
+		log.Printf("[%s] - expected a balance of 30, got %d: %v", testName, balance, err)
+		t.Fail()
+	}
+
+	if _, err := restored.Restore(path); err == nil {
+		log.Printf("[%s] - restored into a started simnet", testName)
+		t.Fail()
+	}
+}
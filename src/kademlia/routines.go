@@ -0,0 +1,134 @@
+package kademlia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Keeps count of the goroutines spawned by a component, grouped by name, so that a shutdown
+// can verify that every one of them has exited.
+type routineTracker struct {
+	active map[string]int
+	panic  func(reason any) // called when a tracked goroutine panics, before the panic continues
+	sync.Mutex
+}
+
+func newRoutineTracker() *routineTracker {
+	return &routineTracker{
+		active: make(map[string]int),
+	}
+}
+
+// Runs fn in a new goroutine that is registered under name until fn returns.
+func (tracker *routineTracker) Go(name string, fn func()) {
+	done := tracker.track(name)
+	go func() {
+		defer done()
+		defer tracker.recoverPanic()
+		fn()
+	}()
+}
+
+// Sets the function called when a goroutine started through Go panics, nil removes it.
+func (tracker *routineTracker) onPanic(fn func(reason any)) {
+	tracker.Lock()
+	defer tracker.Unlock()
+	tracker.panic = fn
+}
+
+// Hands a panic to the panic function and then continues panicking, must be deferred.
+func (tracker *routineTracker) recoverPanic() {
+	reason := recover()
+	if reason == nil {
+		return
+	}
+	tracker.Lock()
+	fn := tracker.panic
+	tracker.Unlock()
+	if fn != nil {
+		fn(reason)
+	}
+	panic(reason)
+}
+
+// Registers the calling goroutine under name, the returned function must be called when it exits.
+// Used by long running loops that are started by the caller rather than through Go.
+func (tracker *routineTracker) track(name string) func() {
+	tracker.Lock()
+	tracker.active[name]++
+	tracker.Unlock()
+	return func() { tracker.done(name) }
+}
+
+func (tracker *routineTracker) done(name string) {
+	tracker.Lock()
+	defer tracker.Unlock()
+	tracker.active[name]--
+	if tracker.active[name] <= 0 {
+		delete(tracker.active, name)
+	}
+}
+
+// Returns the total number of tracked goroutines that are still running.
+func (tracker *routineTracker) Count() int {
+	tracker.Lock()
+	defer tracker.Unlock()
+	total := 0
+	for _, n := range tracker.active {
+		total += n
+	}
+	return total
+}
+
+// Returns a copy of the running goroutine counts keyed by name.
+func (tracker *routineTracker) Active() map[string]int {
+	tracker.Lock()
+	defer tracker.Unlock()
+	res := make(map[string]int, len(tracker.active))
+	for name, n := range tracker.active {
+		res[name] = n
+	}
+	return res
+}
+
+// Blocks until all tracked goroutines have exited or the context is done.
+// Returns an error naming the goroutines that are still running if the context expires first.
+func (tracker *routineTracker) Wait(ctx context.Context) error {
+	poll := time.NewTicker(time.Millisecond)
+	defer poll.Stop()
+	for {
+		if tracker.Count() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			leaked := tracker.Active()
+			if len(leaked) == 0 {
+				return nil
+			}
+			return errors.New(fmt.Sprintf("leaked goroutines: %s", displayRoutines(leaked)))
+		case <-poll.C:
+		}
+	}
+}
+
+// Formats goroutine counts as "name x count" pairs in name order.
+func displayRoutines(routines map[string]int) string {
+	names := make([]string, 0, len(routines))
+	for name := range routines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := ""
+	for i, name := range names {
+		if i > 0 {
+			res += ", "
+		}
+		res += fmt.Sprintf("%s x%d", name, routines[name])
+	}
+	return res
+}
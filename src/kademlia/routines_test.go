@@ -0,0 +1,69 @@
+package kademlia
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestRoutineTrackerWait(t *testing.T) {
+	testName := "TestRoutineTrackerWait"
+	tracker := newRoutineTracker()
+	release := make(chan struct{})
+	for range 5 {
+		tracker.Go("worker", func() { <-release })
+	}
+	if tracker.Count() != 5 {
+		log.Printf("[%s] - expected 5 running goroutines, found %d", testName, tracker.Count())
+		t.Fail()
+	}
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := tracker.Wait(ctx)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+}
+
+func TestRoutineTrackerLeak(t *testing.T) {
+	testName := "TestRoutineTrackerLeak"
+	tracker := newRoutineTracker()
+	release := make(chan struct{})
+	defer close(release)
+	tracker.Go("stuck", func() { <-release })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := tracker.Wait(ctx)
+	if err == nil {
+		log.Printf("[%s] - expected an error for the leaked goroutine", testName)
+		t.Fail()
+	}
+}
+
+func TestNodeStop(t *testing.T) {
+	testName := "TestNodeStop"
+	ip := RandomIP()
+	id := RandomID()
+	master := NewContact(ip, id)
+	node := NewNode(id, ip, make(chan RPC, 8), make(chan RPC, 8), [4]byte{0, 0, 0, 0}, master, false)
+	node.Start(make(chan KademliaID, 1))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := node.Stop(ctx)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	if !node.Stopped() {
+		log.Printf("[%s] - node not marked as stopped", testName)
+		t.Fail()
+	}
+	err = node.Stop(ctx)
+	if err != nil {
+		log.Printf("[%s] - second stop failed: %s", testName, err.Error())
+		t.Fail()
+	}
+}
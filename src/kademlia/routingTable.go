@@ -3,12 +3,39 @@ package kademlia
 import (
 	"errors"
 	"fmt"
+	"sync"
 )
 
+// Operations on a routing table. Each is atomic across the buckets and safe to call from any
+// goroutine, such as the handlers of concurrent RPCs: a lookup never sees a contact half added,
+// or both a contact and the one it evicted.
+type Router interface {
+	// Adds the contact and returns the contact evicted to make room for it, the bool is false if
+	// none was. Returns an error if the contact is the home node, already present or did not fit.
+	AddContact(contact Contact) (Contact, bool, error)
+	// Removes the contact, returns false if it was not present.
+	RemoveContact(contact Contact) bool
+	// Returns up to x contacts closest to target, closest first.
+	FindXClosest(x int, target KademliaID) ([]Contact, error)
+	FindByIP(ip [4]byte) (Contact, error)
+	AllContacts() []Contact
+	// Returns the number of contacts held.
+	Len() int
+}
+
+var _ Router = (*RoutingTable)(nil)
+
+// Kademlia routing table of a node, see Router for the operations that are safe to use
+// concurrently. Every exported method takes the table lock itself, callbacks registered with
+// onChange run after it is released and may call back into the table.
 type RoutingTable struct {
 	homeNode Contact
 	table    []*Bucket
 	keySpace int
+	caps     *capabilityTable
+	seen     *seenTable
+	lock     *sync.RWMutex                     // held across the buckets for every operation, before a bucket's own lock
+	onChange func(contact Contact, added bool) // called after a contact is added or removed, may be nil
 }
 
 // Creates and populates a new routing table with buckets.
@@ -17,6 +44,9 @@ func NewRoutingTable(homeNode Contact, keySpace int, kBucket int) *RoutingTable
 		homeNode: homeNode,
 		table:    make([]*Bucket, 0),
 		keySpace: keySpace,
+		caps:     newCapabilityTable(),
+		seen:     newSeenTable(keySpace),
+		lock:     new(sync.RWMutex),
 	}
 	for i := 0; i < keySpace; i++ {
 		router.table = append(router.table, NewBucket(kBucket, homeNode))
@@ -24,51 +54,92 @@ func NewRoutingTable(homeNode Contact, keySpace int, kBucket int) *RoutingTable
 	return &router
 }
 
-// Returns the bucket index for target ID.
+// Returns the bucket index for target ID, ids sharing more prefix bits with the home node than
+// there are buckets fall in the last one.
 // If index is out of scope, i.e. the home node, returns an error.
-func (router *RoutingTable) BucketIndex(target [5]uint32) (int, error) {
+func (router *RoutingTable) BucketIndex(target KademliaID) (int, error) {
 	index := DistPrefixLength(target, router.homeNode.ID())
-	if index < 0 || index > 159 {
+	if index < 0 || index >= ID_BITS {
 		return 0, errors.New("invalid index")
 	}
-	return index, nil
+	return min(index, router.keySpace-1), nil
 }
 
 // Attempts to add the contact to the routing table at the correct bucket.
+// Returns the contact evicted to make room for it, the bool is false if none was.
 // Returns an error if adding home node or bucket is full.
-func (router *RoutingTable) AddContact(contact Contact) error {
+func (router *RoutingTable) AddContact(contact Contact) (Contact, bool, error) {
 	index, err := router.BucketIndex(contact.ID())
 	if err != nil {
-		return errors.New("can not add home node to router")
+		return Contact{}, false, errors.New("can not add home node to router")
+	}
+	evicted, ok, err := router.add(index, contact)
+	if err != nil {
+		return Contact{}, false, err
+	}
+	if ok {
+		router.changed(evicted, false)
+	}
+	router.changed(contact, true)
+	return evicted, ok, nil
+}
+
+func (router *RoutingTable) add(index int, contact Contact) (Contact, bool, error) {
+	router.lock.Lock()
+	defer router.lock.Unlock()
+	evicted, ok, err := router.table[index].add(contact)
+	if err == errDuplicateContact {
+		router.seen.touch(contact.ID(), index)
 	}
-	err = router.table[index].AddContact(contact)
 	if err != nil {
-		return err
+		return Contact{}, false, err
 	}
-	return nil
+	router.seen.touch(contact.ID(), index)
+	if ok {
+		router.seen.forget(evicted.ID())
+	}
+	return evicted, ok, nil
 }
 
 // Attempts to remove contact from the corresponding bucket.
-// If contact is not found does nothing.
-func (router *RoutingTable) RemoveContact(contact Contact) {
+// Returns false if the contact was not found.
+func (router *RoutingTable) RemoveContact(contact Contact) bool {
 	index, err := router.BucketIndex(contact.ID())
 	if err != nil {
-		return
+		return false
+	}
+	router.lock.Lock()
+	removed := router.table[index].remove(contact)
+	router.seen.forget(contact.ID())
+	router.forgetCapabilities(contact.ID())
+	router.lock.Unlock()
+	if removed {
+		router.changed(contact, false)
+	}
+	return removed
+}
+
+func (router *RoutingTable) changed(contact Contact, added bool) {
+	if router.onChange != nil {
+		router.onChange(contact, added)
 	}
-	router.table[index].RemoveContact(contact)
 }
 
 func (router *RoutingTable) FindByIP(ip [4]byte) (Contact, error) {
+	router.lock.RLock()
+	defer router.lock.RUnlock()
 	for _, b := range router.table {
 		res, err := b.FindByIP(ip)
 		if err == nil {
 			return res, nil
 		}
 	}
-	return Contact{}, errors.New("no matching contact")
+	return Contact{}, ErrNodeNotFound
 }
 
-func (router *RoutingTable) FindXClosest(x int, target [5]uint32) ([]Contact, error) {
+func (router *RoutingTable) FindXClosest(x int, target KademliaID) ([]Contact, error) {
+	router.lock.RLock()
+	defer router.lock.RUnlock()
 	res := make([]Contact, 0, x)
 	index, err := router.BucketIndex(target)
 	if err != nil {
@@ -100,22 +171,53 @@ func (router *RoutingTable) FindXClosest(x int, target [5]uint32) ([]Contact, er
 }
 
 func (rt *RoutingTable) Display() string {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
 	res := ""
 
 	for buckID, val := range rt.table {
-		if len(val.content) == 0 {
+		contacts := val.Display()
+		if contacts == "" {
 			continue
 		}
 		res += fmt.Sprintf("\nBucket %d\n", buckID)
-		res += val.Display()
+		res += contacts
 	}
 	return res
 }
 
 func (rt *RoutingTable) AllContacts() []Contact {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
 	res := make([]Contact, 0, 64)
 	for _, bucket := range rt.table {
 		res = append(res, bucket.DumpBucket()...)
 	}
 	return res
 }
+
+// Returns the number of contacts held in each bucket, indexed by bucket.
+func (rt *RoutingTable) BucketOccupancy() []int {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
+	res := make([]int, len(rt.table))
+	for i, bucket := range rt.table {
+		bucket.RLock()
+		res[i] = len(bucket.content)
+		bucket.RUnlock()
+	}
+	return res
+}
+
+// Returns the number of contacts held in the routing table.
+func (rt *RoutingTable) Len() int {
+	rt.lock.RLock()
+	defer rt.lock.RUnlock()
+	res := 0
+	for _, bucket := range rt.table {
+		bucket.RLock()
+		res += len(bucket.content)
+		bucket.RUnlock()
+	}
+	return res
+}
@@ -2,6 +2,8 @@ package kademlia
 
 import (
 	"log"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -180,3 +182,88 @@ func TestRoutingTableDisplay(t *testing.T) {
 		log.Printf("[%s]\n%s", testName, rt.Display())
 	}
 }
+
+// Hammers a routing table with small buckets from many goroutines, run with -race to check that
+// the operations of Router are safe to call concurrently.
+func TestRoutingTableConcurrency(t *testing.T) {
+	testName := "TestRoutingTableConcurrency"
+	me := NewRandomContact()
+	rt := NewRoutingTable(me, KEYSPACE, 2)
+	var changes atomic.Int64
+	rt.onChange = func(contact Contact, added bool) {
+		changes.Add(1)
+		rt.Len()
+	}
+	pool := make([]Contact, 64)
+	for i := range pool {
+		pool[i] = NewRandomContact()
+	}
+
+	var wg sync.WaitGroup
+	for g := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				con := pool[(g*7+i*13)%len(pool)]
+				switch i % 6 {
+				case 0, 1:
+					evicted, ok, err := rt.AddContact(con)
+					if ok && (err != nil || evicted.ID() == con.ID()) {
+						log.Printf("[%s] - contact %v evicted itself: %v", testName, con.ID(), err)
+						t.Fail()
+					}
+				case 2:
+					rt.RemoveContact(con)
+				case 3:
+					found, _ := rt.FindXClosest(KBUCKETVOLUME, con.ID())
+					if len(found) > KBUCKETVOLUME {
+						log.Printf("[%s] - found %d contacts, asked for %d", testName, len(found), KBUCKETVOLUME)
+						t.Fail()
+					}
+				case 4:
+					rt.FindByIP(con.IP())
+					rt.Display()
+				case 5:
+					rt.Len()
+					rt.BucketOccupancy()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	contacts := rt.AllContacts()
+	if rt.Len() != len(contacts) {
+		log.Printf("[%s] - Len is %d with %d contacts", testName, rt.Len(), len(contacts))
+		t.Fail()
+	}
+	seen := make(map[KademliaID]bool)
+	for _, con := range contacts {
+		if seen[con.ID()] {
+			log.Printf("[%s] - contact %v held twice", testName, con.ID())
+			t.Fail()
+		}
+		seen[con.ID()] = true
+		if _, ok := rt.LastSeen(con.ID()); !ok {
+			log.Printf("[%s] - contact %v held without being seen", testName, con.ID())
+			t.Fail()
+		}
+	}
+	for _, con := range pool {
+		if _, ok := rt.LastSeen(con.ID()); ok && !seen[con.ID()] {
+			log.Printf("[%s] - removed contact %v is still seen", testName, con.ID())
+			t.Fail()
+		}
+	}
+	for i, n := range rt.BucketOccupancy() {
+		if n > 2 {
+			log.Printf("[%s] - bucket %d holds %d contacts", testName, i, n)
+			t.Fail()
+		}
+	}
+	if changes.Load() == 0 {
+		log.Printf("[%s] - no changes were reported", testName)
+		t.Fail()
+	}
+}
@@ -1,8 +1,10 @@
 package kademlia
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"main/src/scalegraph"
+	"time"
 )
 
 type cmd int
@@ -28,8 +30,58 @@ const (
 	PROPOSE_TRANSACTION
 	ACCEPT_TRANSACTION
 	APPEND_TRANSACTION
+	SNAPSHOT_ACCOUNTS
+	SNAPSHOT_CHUNK
+	APPENDED_TRANSACTION
+	FIND_BALANCE
+	FOUND_BALANCE
+	STORE_MESSAGE
+	STORED_MESSAGE
+	FETCH_MESSAGES
+	FETCHED_MESSAGES
+	SUBMIT_WALLET
+	SUBMITTED_WALLET
+	SHOW_WALLET
+	SHOWN_WALLET
+	COMMIT_TRANSACTION
+	COMMITTED_TRANSACTION
+	SYNC_WALLET
+	SYNCED_WALLET
+	UNKNOWN_COMMAND
+	UNSUPPORTED_VERSION
+	STORE_VALUE
+	STORED_VALUE
+	FIND_VALUE
+	FOUND_VALUE
+	WATCH_WALLET
+	WATCHED_WALLET
+	NOTIFY_WALLET
+	NOTIFIED_WALLET
+	BATCH
+	BATCHED
+	SUBSCRIBE
+	SUBSCRIBED
+	PUBLISH
+	PUBLISHED
+	DELIVER_PUBLICATION
+	DELIVERED_PUBLICATION
+	TRANSFER_VALUES
+	TRANSFERRED_VALUES
+	RECURSIVE_FIND_NODE
+	RECURSIVE_FOUND_NODES
+	AUDIT_WALLET
+	AUDITED_WALLET
+	RECONCILE
+	RECONCILED
+	REPAIR_WALLET
+	REPAIRED_WALLET
+	OPEN_SESSION
+	OPENED_SESSION
+	SEALED
 )
 
+const LAST_PROTOCOL_CMD = SEALED // highest built-in command, keep in sync with the list above
+
 func (cmd cmd) String() string {
 	switch cmd {
 	case NO_CMD:
@@ -72,26 +124,179 @@ func (cmd cmd) String() string {
 		return "ACCEPT_TRANSACTION"
 	case APPEND_TRANSACTION:
 		return "APPEND_TRANSACTION"
+	case SNAPSHOT_ACCOUNTS:
+		return "SNAPSHOT_ACCOUNTS"
+	case SNAPSHOT_CHUNK:
+		return "SNAPSHOT_CHUNK"
+	case APPENDED_TRANSACTION:
+		return "APPENDED_TRANSACTION"
+	case FIND_BALANCE:
+		return "FIND_BALANCE"
+	case FOUND_BALANCE:
+		return "FOUND_BALANCE"
+	case STORE_MESSAGE:
+		return "STORE_MESSAGE"
+	case STORED_MESSAGE:
+		return "STORED_MESSAGE"
+	case FETCH_MESSAGES:
+		return "FETCH_MESSAGES"
+	case FETCHED_MESSAGES:
+		return "FETCHED_MESSAGES"
+	case SUBMIT_WALLET:
+		return "SUBMIT_WALLET"
+	case SUBMITTED_WALLET:
+		return "SUBMITTED_WALLET"
+	case SHOW_WALLET:
+		return "SHOW_WALLET"
+	case SHOWN_WALLET:
+		return "SHOWN_WALLET"
+	case COMMIT_TRANSACTION:
+		return "COMMIT_TRANSACTION"
+	case COMMITTED_TRANSACTION:
+		return "COMMITTED_TRANSACTION"
+	case SYNC_WALLET:
+		return "SYNC_WALLET"
+	case SYNCED_WALLET:
+		return "SYNCED_WALLET"
+	case UNKNOWN_COMMAND:
+		return "UNKNOWN_COMMAND"
+	case UNSUPPORTED_VERSION:
+		return "UNSUPPORTED_VERSION"
+	case STORE_VALUE:
+		return "STORE_VALUE"
+	case STORED_VALUE:
+		return "STORED_VALUE"
+	case FIND_VALUE:
+		return "FIND_VALUE"
+	case FOUND_VALUE:
+		return "FOUND_VALUE"
+	case WATCH_WALLET:
+		return "WATCH_WALLET"
+	case WATCHED_WALLET:
+		return "WATCHED_WALLET"
+	case NOTIFY_WALLET:
+		return "NOTIFY_WALLET"
+	case NOTIFIED_WALLET:
+		return "NOTIFIED_WALLET"
+	case BATCH:
+		return "BATCH"
+	case BATCHED:
+		return "BATCHED"
+	case SUBSCRIBE:
+		return "SUBSCRIBE"
+	case SUBSCRIBED:
+		return "SUBSCRIBED"
+	case PUBLISH:
+		return "PUBLISH"
+	case PUBLISHED:
+		return "PUBLISHED"
+	case DELIVER_PUBLICATION:
+		return "DELIVER_PUBLICATION"
+	case DELIVERED_PUBLICATION:
+		return "DELIVERED_PUBLICATION"
+	case TRANSFER_VALUES:
+		return "TRANSFER_VALUES"
+	case TRANSFERRED_VALUES:
+		return "TRANSFERRED_VALUES"
+	case RECURSIVE_FIND_NODE:
+		return "RECURSIVE_FIND_NODE"
+	case RECURSIVE_FOUND_NODES:
+		return "RECURSIVE_FOUND_NODES"
+	case AUDIT_WALLET:
+		return "AUDIT_WALLET"
+	case AUDITED_WALLET:
+		return "AUDITED_WALLET"
+	case RECONCILE:
+		return "RECONCILE"
+	case RECONCILED:
+		return "RECONCILED"
+	case REPAIR_WALLET:
+		return "REPAIR_WALLET"
+	case REPAIRED_WALLET:
+		return "REPAIRED_WALLET"
+	case OPEN_SESSION:
+		return "OPEN_SESSION"
+	case OPENED_SESSION:
+		return "OPENED_SESSION"
+	case SEALED:
+		return "SEALED"
+	}
+	name, ok := registry.name(cmd)
+	if ok {
+		return name
 	}
 	return "unknown cmd"
 }
 
 type RPC struct {
-	id              [5]uint32
-	cmd             cmd
-	response        bool
-	sender          Contact
-	receiver        [4]byte
-	findNodeTarget  [5]uint32
-	foundNodes      []Contact
-	accountID       [5]uint32
-	displayString   string
-	storeAccSucc    bool
-	findAccountSucc bool
-	lockChan        chan RPC
-	blockID         [5]uint32
-	transaction     scalegraph.Transaction
-	transactionID   [5]uint32
+	id               KademliaID
+	cmd              cmd
+	network          NetworkID
+	version          ProtocolVersion // protocol version of the sender
+	minVersion       ProtocolVersion // oldest protocol version the sender understands
+	role             Role
+	caps             Capability        // capabilities of the sender
+	senderKey        ed25519.PublicKey // public key the sender's id is bound to, see Identity
+	signature        []byte            // signature by the sender's key, see signingData
+	response         bool
+	sender           Contact
+	receiver         [4]byte
+	origin           [4]byte // IP of the node that sent the RPC, stamped by its network whatever sender it claims
+	findNodeTarget   KademliaID
+	findNodeCount    int // contacts a FIND_NODE requester wants back, zero leaves it to the receiver
+	foundNodes       []Contact
+	accountID        KademliaID
+	displayString    string
+	storeAccSucc     bool
+	findAccountSucc  bool
+	lockChan         chan RPC
+	blockID          KademliaID
+	transaction      scalegraph.Transaction
+	transactionID    KademliaID
+	payload          []byte
+	snapshotID       KademliaID
+	snapshotOffset   int
+	snapshotAccounts []scalegraph.AccountSnapshot
+	snapshotDone     bool
+	snapshotFailed   bool
+	appendSucc       bool
+	balance          uint64
+	balanceFound     bool
+	message          Message
+	messages         []Message
+	messageStored    bool
+	wallet           Wallet
+	publicKey        ed25519.PublicKey
+	walletStored     bool
+	accepted         bool
+	commit           bool
+	walletStates     []walletState
+	unknownCmd       cmd // command the receiver had no handler for
+	value            []byte
+	valueFound       bool      // the value was stored or found
+	valueCached      bool      // the value is to be, or was, held in a cache
+	replication      int       // closest nodes a stored value is to be kept at, zero for the receiver's replication
+	queued           time.Time // when the RPC entered its receiver's inbound queue
+	routeStart       time.Time // when the simnet first routed a RPC it held back for a delay, zero until then
+	batch            []RPC     // requests of a BATCH, or the responses of a BATCHED in the same order
+	publication      Publication
+	transfers        []valueTransfer // values handed to a node that joined closer to their keys
+	hops             int             // times the request was forwarded on behalf of another, see MAX_HOPS
+	path             []Contact       // nodes a recursive lookup passed through, starting with the requester
+	joinChallenge    []byte          // nonce an ENTER response challenges the requester with, echoed in its next request
+	joinProof        []byte          // answer to joinChallenge, see joinProof
+	joinRejected     bool            // the entry service rejected the join
+	auditNonce       []byte          // nonce an AUDIT_WALLET challenges the validator with
+	auditProof       []byte          // digest of the audited wallet's state under auditNonce
+	merkleNodes      []merkleNode    // subtrees of a wallet tree compared by RECONCILE
+	session          KademliaID      // session a SEALED RPC was sealed in, or the handshake opening it
+	sessionKey       []byte          // ephemeral X25519 key of a session handshake
+	sealed           []byte          // sequence number and ciphertext of a SEALED RPC, see session
+}
+
+// Counts the rpc as one hop further than req, the request it is sent on behalf of.
+func (rpc *RPC) forwardOf(req *RPC) {
+	rpc.hops = req.hops + 1
 }
 
 // Generate a fresh send RPC, for a response RPC use GenerateResponse instead.
@@ -105,12 +310,12 @@ func GenerateRPC(receiver [4]byte, sender Contact) RPC {
 	return rpc
 }
 
-func (rpc *RPC) OverrideID(newID [5]uint32) {
+func (rpc *RPC) OverrideID(newID KademliaID) {
 	rpc.id = newID
 }
 
 // Generates a fresh response RPC.
-func GenerateResponse(id [5]uint32, receiver [4]byte, sender Contact) RPC {
+func GenerateResponse(id KademliaID, receiver [4]byte, sender Contact) RPC {
 	rpc := RPC{
 		id:       id,
 		receiver: receiver,
@@ -134,68 +339,82 @@ func (rpc *RPC) Enter() {
 	rpc.cmd = ENTER
 }
 
-func (rpc *RPC) FindNode(targetNode [5]uint32) {
+// Used to answer the entry service's challenge, see Simnet.RequireJoinToken.
+func (rpc *RPC) EnterWithProof(challenge []byte, proof []byte) {
+	rpc.cmd = ENTER
+	rpc.joinChallenge = challenge
+	rpc.joinProof = proof
+}
+
+func (rpc *RPC) FindNode(targetNode KademliaID) {
 	rpc.cmd = FIND_NODE
 	rpc.findNodeTarget = targetNode
 }
 
-func (rpc *RPC) FoundNodes(target [5]uint32, nodes []Contact) {
+// Set a RPC as a find node request asking for up to count contacts back, the receiver answers
+// with at most its replication. A count of zero leaves the response size to the receiver.
+func (rpc *RPC) FindNodeLimit(targetNode KademliaID, count int) {
+	rpc.FindNode(targetNode)
+	rpc.findNodeCount = max(count, 0)
+}
+
+func (rpc *RPC) FoundNodes(target KademliaID, nodes []Contact) {
 	rpc.cmd = FOUND_NODES
 	rpc.findNodeTarget = target
 	rpc.foundNodes = nodes
 }
 
-func (rpc *RPC) InsertAccount(accID [5]uint32) {
+func (rpc *RPC) InsertAccount(accID KademliaID) {
 	rpc.cmd = INSERT_ACCOUNT
 	rpc.accountID = accID
 }
 
-func (rpc *RPC) StoreAccount(accID [5]uint32) {
+func (rpc *RPC) StoreAccount(accID KademliaID) {
 	rpc.cmd = STORE_ACCOUNT
 	rpc.accountID = accID
 }
 
-func (rpc *RPC) StoredAccount(accID [5]uint32, success bool) {
+func (rpc *RPC) StoredAccount(accID KademliaID, success bool) {
 	rpc.cmd = STORED_ACCOUNT
 	rpc.accountID = accID
 	rpc.storeAccSucc = success
 }
 
-func (rpc *RPC) FindAccount(accID [5]uint32) {
+func (rpc *RPC) FindAccount(accID KademliaID) {
 	rpc.cmd = FIND_ACCOUNT
 	rpc.accountID = accID
 }
 
-func (rpc *RPC) FoundAccount(accID [5]uint32, success bool) {
+func (rpc *RPC) FoundAccount(accID KademliaID, success bool) {
 	rpc.cmd = FOUND_ACCOUNT
 	rpc.accountID = accID
 	rpc.findAccountSucc = success
 }
 
-func (rpc *RPC) DisplayAccount(accID [5]uint32) {
+func (rpc *RPC) DisplayAccount(accID KademliaID) {
 	rpc.cmd = DISPLAY_ACCOUNT
 	rpc.accountID = accID
 }
 
-func (rpc *RPC) DisplayedAccount(accID [5]uint32, displayString string) {
+func (rpc *RPC) DisplayedAccount(accID KademliaID, displayString string) {
 	rpc.cmd = DISPLAYED_ACCOUNT
 	rpc.accountID = accID
 	rpc.displayString = displayString
 }
 
-func (rpc *RPC) LockAccount(accID [5]uint32, lockChan chan RPC) {
+func (rpc *RPC) LockAccount(accID KademliaID, lockChan chan RPC) {
 	rpc.cmd = LOCK_ACCOUNT
 	rpc.accountID = accID
 	rpc.lockChan = lockChan
 }
 
-func (rpc *RPC) LockedAccount(accID [5]uint32, lockChan chan RPC) {
+func (rpc *RPC) LockedAccount(accID KademliaID, lockChan chan RPC) {
 	rpc.cmd = LOCK_ACCOUNT
 	rpc.accountID = accID
 	rpc.lockChan = lockChan
 }
 
-func (rpc *RPC) UnlockAccount(accID [5]uint32) {
+func (rpc *RPC) UnlockAccount(accID KademliaID) {
 	rpc.cmd = UNLOCK_ACCOUNT
 	rpc.accountID = accID
 }
@@ -205,25 +424,258 @@ func (rpc *RPC) StartTransaction(trx scalegraph.Transaction) {
 	rpc.transaction = trx
 }
 
-func (rpc *RPC) ProposeTransaction(trx scalegraph.Transaction) {
+// Asks a validator of accID to vote on the transaction.
+func (rpc *RPC) ProposeTransaction(accID KademliaID, trx scalegraph.Transaction) {
 	rpc.cmd = PROPOSE_TRANSACTION
+	rpc.accountID = accID
 	rpc.transaction = trx
 }
 
-func (rpc *RPC) AcceptTransaction(trxID [5]uint32) {
+// Vote on a proposed transaction, the transaction body is not sent back.
+func (rpc *RPC) AcceptTransaction(accID KademliaID, trxID KademliaID, accepted bool) {
 	rpc.cmd = ACCEPT_TRANSACTION
+	rpc.accountID = accID
+	rpc.transactionID = trxID
+	rpc.accepted = accepted
+}
+
+// Tells a validator of accID the outcome of a proposal, commit is false if it was aborted.
+func (rpc *RPC) CommitTransaction(accID KademliaID, trx scalegraph.Transaction, commit bool) {
+	rpc.cmd = COMMIT_TRANSACTION
+	rpc.accountID = accID
+	rpc.transaction = trx
+	rpc.commit = commit
+}
+
+func (rpc *RPC) CommittedTransaction(accID KademliaID, trxID KademliaID, applied bool) {
+	rpc.cmd = COMMITTED_TRANSACTION
+	rpc.accountID = accID
+	rpc.transactionID = trxID
+	rpc.accepted = applied
+}
+
+// Asks a validator of accID to append the transaction to the account's chain.
+func (rpc *RPC) AppendTransaction(accID KademliaID, trx scalegraph.Transaction) {
+	rpc.cmd = APPEND_TRANSACTION
+	rpc.accountID = accID
+	rpc.transaction = trx
+}
+
+func (rpc *RPC) AppendedTransaction(accID KademliaID, trxID KademliaID, success bool) {
+	rpc.cmd = APPENDED_TRANSACTION
+	rpc.accountID = accID
 	rpc.transactionID = trxID
+	rpc.appendSucc = success
+}
+
+func (rpc *RPC) FindBalance(accID KademliaID) {
+	rpc.cmd = FIND_BALANCE
+	rpc.accountID = accID
+}
+
+func (rpc *RPC) FoundBalance(accID KademliaID, balance uint64, found bool) {
+	rpc.cmd = FOUND_BALANCE
+	rpc.accountID = accID
+	rpc.balance = balance
+	rpc.balanceFound = found
+}
+
+// Asks a validator to store a new wallet, opening is the deposit that funds it.
+func (rpc *RPC) SubmitWallet(walletID KademliaID, key ed25519.PublicKey, opening scalegraph.Transaction) {
+	rpc.cmd = SUBMIT_WALLET
+	rpc.accountID = walletID
+	rpc.publicKey = key
+	rpc.transaction = opening
+}
+
+func (rpc *RPC) SubmittedWallet(walletID KademliaID, success bool) {
+	rpc.cmd = SUBMITTED_WALLET
+	rpc.accountID = walletID
+	rpc.walletStored = success
+}
+
+func (rpc *RPC) ShowWallet(walletID KademliaID) {
+	rpc.cmd = SHOW_WALLET
+	rpc.accountID = walletID
+}
+
+func (rpc *RPC) ShownWallet(wallet Wallet, found bool) {
+	rpc.cmd = SHOWN_WALLET
+	rpc.accountID = wallet.ID
+	rpc.wallet = wallet
+	rpc.walletStored = found
+}
+
+// Subscribes the sender to changes of the wallet for WATCH_LEASE.
+func (rpc *RPC) WatchWallet(walletID KademliaID) {
+	rpc.cmd = WATCH_WALLET
+	rpc.accountID = walletID
+}
+
+func (rpc *RPC) WatchedWallet(walletID KademliaID, found bool) {
+	rpc.cmd = WATCHED_WALLET
+	rpc.accountID = walletID
+	rpc.walletStored = found
+}
+
+// Tells a watcher the new state of a wallet it subscribed to.
+func (rpc *RPC) NotifyWallet(wallet Wallet) {
+	rpc.cmd = NOTIFY_WALLET
+	rpc.accountID = wallet.ID
+	rpc.wallet = wallet
+}
+
+func (rpc *RPC) NotifiedWallet(walletID KademliaID) {
+	rpc.cmd = NOTIFIED_WALLET
+	rpc.accountID = walletID
+}
+
+// Asks a neighbour for the wallets the sender should replicate.
+func (rpc *RPC) SyncWallet() {
+	rpc.cmd = SYNC_WALLET
+}
+
+func (rpc *RPC) SyncedWallet(states []walletState) {
+	rpc.cmd = SYNCED_WALLET
+	rpc.walletStates = states
+}
+
+func (rpc *RPC) StoreMessage(msg Message) {
+	rpc.cmd = STORE_MESSAGE
+	rpc.message = msg
+}
+
+func (rpc *RPC) StoredMessage(msgID KademliaID, success bool) {
+	rpc.cmd = STORED_MESSAGE
+	rpc.message = Message{ID: msgID}
+	rpc.messageStored = success
+}
+
+// Asks a node to hand over the messages it holds for recipient, the recipient is carried in accountID.
+func (rpc *RPC) FetchMessages(recipient KademliaID) {
+	rpc.cmd = FETCH_MESSAGES
+	rpc.accountID = recipient
+}
+
+func (rpc *RPC) FetchedMessages(recipient KademliaID, msgs []Message) {
+	rpc.cmd = FETCHED_MESSAGES
+	rpc.accountID = recipient
+	rpc.messages = msgs
+}
+
+// Requests the chunk at offset of a snapshot, a zero snapshot id opens a new snapshot.
+func (rpc *RPC) SnapshotAccounts(snapshotID KademliaID, offset int) {
+	rpc.cmd = SNAPSHOT_ACCOUNTS
+	rpc.snapshotID = snapshotID
+	rpc.snapshotOffset = offset
+}
+
+func (rpc *RPC) SnapshotChunk(snapshotID KademliaID, offset int, accounts []scalegraph.AccountSnapshot, done bool) {
+	rpc.cmd = SNAPSHOT_CHUNK
+	rpc.snapshotID = snapshotID
+	rpc.snapshotOffset = offset
+	rpc.snapshotAccounts = accounts
+	rpc.snapshotDone = done
+}
+
+// Asks a node to store a value under key, in its cache if cache is set. The key is carried in accountID.
+func (rpc *RPC) StoreValue(key KademliaID, data []byte, cache bool) {
+	rpc.cmd = STORE_VALUE
+	rpc.accountID = key
+	rpc.value = data
+	rpc.valueCached = cache
+}
+
+func (rpc *RPC) StoredValue(key KademliaID, success bool) {
+	rpc.cmd = STORED_VALUE
+	rpc.accountID = key
+	rpc.valueFound = success
+}
+
+func (rpc *RPC) FindValue(key KademliaID) {
+	rpc.cmd = FIND_VALUE
+	rpc.accountID = key
+}
+
+func (rpc *RPC) FoundValue(key KademliaID, data []byte, cached bool) {
+	rpc.cmd = FOUND_VALUE
+	rpc.accountID = key
+	rpc.value = data
+	rpc.valueFound = true
+	rpc.valueCached = cached
+}
+
+// Set a RPC as a response to a find value RPC for a value the node does not hold.
+func (rpc *RPC) FoundValueContacts(key KademliaID, closest []Contact) {
+	rpc.cmd = FOUND_VALUE
+	rpc.accountID = key
+	rpc.foundNodes = closest
+}
+
+// Set a RPC as a response to a command the receiver has no handler for.
+func (rpc *RPC) UnknownCommand(command Command) {
+	rpc.cmd = UNKNOWN_COMMAND
+	rpc.unknownCmd = command
+}
+
+// Set a RPC as a response to a request in a protocol version the receiver does not understand,
+// the receiver's own versions are stamped when it is sent.
+func (rpc *RPC) UnsupportedVersion() {
+	rpc.cmd = UNSUPPORTED_VERSION
+}
+
+// Set a RPC as an application-defined command carrying an opaque payload.
+func (rpc *RPC) Custom(command Command, payload []byte) {
+	rpc.cmd = command
+	rpc.payload = payload
+}
+
+func (rpc *RPC) Cmd() Command {
+	return rpc.cmd
+}
+
+func (rpc *RPC) Sender() Contact {
+	return rpc.sender
+}
+
+func (rpc *RPC) Payload() []byte {
+	return rpc.payload
+}
+
+// Returns the id a FIND_NODE request looks up.
+func (rpc *RPC) FindNodeTarget() KademliaID {
+	return rpc.findNodeTarget
+}
+
+// Returns the contacts a FIND_NODE request asks for, zero if it leaves that to its receiver.
+func (rpc *RPC) FindNodeCount() int {
+	return rpc.findNodeCount
+}
+
+// Returns the account or wallet an account or wallet request is about.
+func (rpc *RPC) AccountID() KademliaID {
+	return rpc.accountID
+}
+
+// Returns an empty response to the request, sent back from sender.
+func (rpc *RPC) Reply(sender Contact) RPC {
+	return GenerateResponse(rpc.id, rpc.sender.IP(), sender)
 }
 
 func (rpc *RPC) Display() string {
 	rpcString := fmt.Sprintf("id: %v\n", rpc.id)
 	rpcString += fmt.Sprintf("CMD: %s\n", rpc.cmd)
+	rpcString += fmt.Sprintf("Network: %d\n", rpc.network)
+	rpcString += fmt.Sprintf("Version: %d (min %d)\n", rpc.version, rpc.minVersion)
 	rpcString += fmt.Sprintf("Response: %t\n", rpc.response)
 	rpcString += fmt.Sprintf("Sender: %s\n", rpc.sender.Display())
 	rpcString += fmt.Sprintf("Receiver: %v\n", rpc.receiver)
 
 	if rpc.cmd == FIND_NODE {
 		rpcString += fmt.Sprintf("Find Node Target: %v", rpc.findNodeTarget)
+		if rpc.findNodeCount > 0 {
+			rpcString += fmt.Sprintf(" (count %d)", rpc.findNodeCount)
+		}
 	}
 	if rpc.cmd == FOUND_NODES && rpc.response {
 		rpcString += "Found Nodes:"
@@ -239,6 +691,15 @@ func (rpc *RPC) Display() string {
 		rpcString += fmt.Sprintf("stored account: %10v\n", rpc.accountID)
 		rpcString += fmt.Sprintf("stored account success: %t", rpc.storeAccSucc)
 	}
+	if rpc.cmd == SNAPSHOT_CHUNK && rpc.response {
+		rpcString += fmt.Sprintf("snapshot: %10v offset: %d accounts: %d done: %t\n", rpc.snapshotID, rpc.snapshotOffset, len(rpc.snapshotAccounts), rpc.snapshotDone)
+	}
+	if rpc.cmd == BATCH || rpc.cmd == BATCHED {
+		rpcString += fmt.Sprintf("batch: %d rpcs\n", len(rpc.batch))
+	}
+	if rpc.cmd >= USER_CMD {
+		rpcString += fmt.Sprintf("payload: %d bytes\n", len(rpc.payload))
+	}
 	rpcString += "\n\n"
 
 	return rpcString
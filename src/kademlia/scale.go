@@ -0,0 +1,182 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	SCALE_RATE   = 10.0    // default nodes added or removed per second by ScaleTo
+	SCALE_PROBES = 10      // default lookups per lookup success sample
+	SCALE_PROBE  = TIMEOUT // default interval between lookup success samples
+)
+
+// Options for Simnet.ScaleTo, zero values select the defaults.
+type ScaleOptions struct {
+	Rate          float64       // nodes added or removed per second
+	ProbeInterval time.Duration // interval between lookup success samples
+	Probes        int           // lookups made per sample
+}
+
+// Lookup success measured at one point of a scaling event. A lookup succeeds if a random live
+// node finds another random live node among the contacts returned by FindNode.
+type ScaleSample struct {
+	Elapsed   time.Duration // time since scaling started
+	Nodes     int           // live nodes when the sample was taken
+	Lookups   int
+	Succeeded int
+}
+
+func (sample ScaleSample) SuccessRate() float64 {
+	if sample.Lookups == 0 {
+		return 0
+	}
+	return float64(sample.Succeeded) / float64(sample.Lookups)
+}
+
+// Outcome of a Simnet.ScaleTo call.
+type ScaleReport struct {
+	From     int
+	To       int
+	Added    int
+	Removed  int
+	Duration time.Duration
+	Samples  []ScaleSample // taken during scaling, the last one after the target size was reached
+}
+
+func (report ScaleReport) Display() string {
+	res := fmt.Sprintf("scaled from %d to %d nodes in %v, added: %d removed: %d\n", report.From, report.To, report.Duration, report.Added, report.Removed)
+	for _, sample := range report.Samples {
+		res += fmt.Sprintf("%10v nodes: %4d lookups: %d/%d (%.2f)\n", sample.Elapsed.Round(time.Millisecond), sample.Nodes, sample.Succeeded, sample.Lookups, sample.SuccessRate())
+	}
+	return res
+}
+
+// Grows or shrinks the live cluster to size nodes, including the master node, adding or removing
+// one node at a time at the configured rate while the network keeps serving traffic. Lookup
+// success is sampled throughout and once more when the target size has been reached.
+// Removed nodes are chosen at random, the master node is never removed.
+// Returns an error if size is below one or the simnet shuts down before the target is reached.
+func (simnet *Simnet) ScaleTo(size int, opts ScaleOptions) (ScaleReport, error) {
+	if size < 1 {
+		return ScaleReport{}, errors.New("cluster size must be at least one")
+	}
+	if opts.Rate <= 0 {
+		opts.Rate = SCALE_RATE
+	}
+	if opts.ProbeInterval <= 0 {
+		opts.ProbeInterval = simnet.config.scaled(SCALE_PROBE)
+	}
+	if opts.Probes <= 0 {
+		opts.Probes = SCALE_PROBES
+	}
+
+	start := time.Now()
+	report := ScaleReport{From: len(simnet.AllNodePointers()), To: size}
+	var samples []ScaleSample
+	var samplesLock sync.Mutex
+	stop := make(chan struct{})
+	probing := make(chan struct{})
+	simnet.routines.Go("scale probe", func() {
+		defer close(probing)
+		ticker := time.NewTicker(opts.ProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-simnet.shutdown:
+				return
+			case <-ticker.C:
+			}
+			sample := simnet.probeLookups(opts.Probes)
+			sample.Elapsed = time.Since(start)
+			samplesLock.Lock()
+			samples = append(samples, sample)
+			samplesLock.Unlock()
+		}
+	})
+
+	err := simnet.scaleLoop(size, opts.Rate, &report)
+	close(stop)
+	<-probing
+	report.Duration = time.Since(start)
+	report.Samples = samples
+	if err != nil {
+		return report, err
+	}
+	final := simnet.probeLookups(opts.Probes)
+	final.Elapsed = time.Since(start)
+	report.Samples = append(report.Samples, final)
+	return report, nil
+}
+
+func (simnet *Simnet) scaleLoop(size int, rate float64, report *ScaleReport) error {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	for {
+		nodes := simnet.AllNodePointers()
+		if len(nodes) == size {
+			return nil
+		}
+		select {
+		case <-simnet.shutdown:
+			return errors.New("simnet shut down while scaling")
+		case <-ticker.C:
+		}
+		if len(nodes) < size {
+			simnet.SpawnNode(make(chan KademliaID, 1))
+			report.Added++
+			continue
+		}
+		candidates := make([]*Node, 0, len(nodes))
+		for _, n := range nodes {
+			if n != simnet.masterNode {
+				candidates = append(candidates, n)
+			}
+		}
+		if len(candidates) == 0 {
+			return errors.New("only the master node is left")
+		}
+		err := simnet.ShutdownNode(candidates[rand.Intn(len(candidates))])
+		if err != nil {
+			simnet.logger.Debug("scaled down node did not shut down cleanly", "err", err)
+		}
+		report.Removed++
+	}
+}
+
+// Runs count lookups in parallel between random pairs of live nodes.
+func (simnet *Simnet) probeLookups(count int) ScaleSample {
+	nodes := simnet.AllNodePointers()
+	sample := ScaleSample{Nodes: len(nodes), Lookups: count}
+	if len(nodes) < 2 {
+		return sample
+	}
+	results := make(chan bool, count)
+	for range count {
+		from := nodes[rand.Intn(len(nodes))]
+		target := nodes[rand.Intn(len(nodes))]
+		for target == from {
+			target = nodes[rand.Intn(len(nodes))]
+		}
+		simnet.routines.Go("scale lookup", func() {
+			for _, con := range from.FindNode(target.ID()) {
+				if con.ID() == target.ID() {
+					results <- true
+					return
+				}
+			}
+			results <- false
+		})
+	}
+	for range count {
+		if <-results {
+			sample.Succeeded++
+		}
+	}
+	return sample
+}
@@ -0,0 +1,56 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestScaleTo(t *testing.T) {
+	testName := "TestScaleTo"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+	opts := ScaleOptions{Rate: 100, ProbeInterval: 50 * time.Millisecond, Probes: 5}
+
+	before := len(s.AllNodePointers())
+	report, err := s.ScaleTo(25, opts)
+	if err != nil {
+		log.Printf("[%s] - failed to scale up: %v", testName, err)
+		t.FailNow()
+	}
+	if len(s.AllNodePointers()) != 25 || report.Added != 25-before || report.From != before || report.Removed != 0 {
+		log.Printf("[%s] - unexpected scale up result:\n%s", testName, report.Display())
+		t.Fail()
+	}
+	if len(report.Samples) == 0 || report.Samples[len(report.Samples)-1].SuccessRate() < 0.5 {
+		log.Printf("[%s] - lookups failing after scale up:\n%s", testName, report.Display())
+		t.Fail()
+	}
+
+	report, err = s.ScaleTo(12, opts)
+	if err != nil {
+		log.Printf("[%s] - failed to scale down: %v", testName, err)
+		t.FailNow()
+	}
+	if len(s.AllNodePointers()) != 12 || report.Removed != 13 || report.From != 25 {
+		log.Printf("[%s] - unexpected scale down result:\n%s", testName, report.Display())
+		t.Fail()
+	}
+	found := false
+	for _, n := range s.AllNodePointers() {
+		found = found || n.Contact == s.MasterNode()
+	}
+	if !found {
+		log.Printf("[%s] - master node was removed", testName)
+		t.Fail()
+	}
+	if _, err := s.ScaleTo(0, opts); err == nil {
+		log.Printf("[%s] - scaling to zero nodes was accepted", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,134 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Delivers a node's RPCs and waits for the responses to its requests.
+// Network is the implementation used in the simnet, ScriptedSender is an in-memory test double.
+type Sender interface {
+	Send(rpc RPC) (RPC, error)
+}
+
+// Replaces how the node delivers its RPCs, must be called before the node is started.
+func (node *Node) SetSender(sender Sender) {
+	node.transport = sender
+}
+
+// Fills in the response of peer to the request req.
+type ScriptedResponse func(peer Contact, req RPC, resp *RPC)
+
+// In-memory Sender answering requests from a scripted set of peers without any network.
+// Each peer answers a command with the canned response registered by On, requests to unknown
+// peers, to failing peers or with an unscripted command fail like a timed out request.
+// Every RPC passed to Send is recorded, see Sent.
+type ScriptedSender struct {
+	peers     map[[4]byte]Contact
+	responses map[cmd]ScriptedResponse
+	failures  map[[4]byte]error
+	sent      []RPC
+	sync.Mutex
+}
+
+// Returns a scripted sender whose peers answer PING with a PONG.
+func NewScriptedSender() *ScriptedSender {
+	script := &ScriptedSender{
+		peers:     make(map[[4]byte]Contact),
+		responses: make(map[cmd]ScriptedResponse),
+		failures:  make(map[[4]byte]error),
+		sent:      make([]RPC, 0),
+	}
+	script.On(PING, func(peer Contact, req RPC, resp *RPC) { resp.Pong() })
+	return script
+}
+
+// Adds peers that answer requests sent to their IP.
+func (script *ScriptedSender) AddPeers(peers ...Contact) {
+	script.Lock()
+	defer script.Unlock()
+	for _, con := range peers {
+		script.peers[con.IP()] = con
+	}
+}
+
+// Sets the canned response of every peer to command.
+func (script *ScriptedSender) On(command cmd, respond ScriptedResponse) {
+	script.Lock()
+	defer script.Unlock()
+	script.responses[command] = respond
+}
+
+// Makes every request to the peer at ip fail with err until Heal is called.
+func (script *ScriptedSender) Fail(ip [4]byte, err error) {
+	script.Lock()
+	defer script.Unlock()
+	script.failures[ip] = err
+}
+
+// Lets the peer at ip answer again.
+func (script *ScriptedSender) Heal(ip [4]byte) {
+	script.Lock()
+	defer script.Unlock()
+	delete(script.failures, ip)
+}
+
+// Returns the RPCs sent with command, in the order they were sent.
+func (script *ScriptedSender) Sent(command cmd) []RPC {
+	script.Lock()
+	defer script.Unlock()
+	res := make([]RPC, 0)
+	for _, rpc := range script.sent {
+		if rpc.cmd == command {
+			res = append(res, rpc)
+		}
+	}
+	return res
+}
+
+// Answers the request from the scripted peer it is addressed to, responses are only recorded.
+// Returns an error if the peer is unknown, failing or has no response to the command.
+func (script *ScriptedSender) Send(rpc RPC) (RPC, error) {
+	script.Lock()
+	script.sent = append(script.sent, rpc)
+	peer, known := script.peers[rpc.receiver]
+	failure := script.failures[rpc.receiver]
+	respond, scripted := script.responses[rpc.cmd]
+	script.Unlock()
+
+	if rpc.response {
+		return rpc, nil
+	}
+	if !known {
+		return rpc, ErrTimeout
+	}
+	if failure != nil {
+		return rpc, failure
+	}
+	if !scripted {
+		return rpc, errors.New(fmt.Sprintf("no scripted response to %v", rpc.cmd))
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), peer)
+	respond(peer, rpc, &resp)
+	return resp, nil
+}
+
+// Returns a response answering FIND_NODE with the REPLICATION scripted peers closest to the target,
+// or as many as the request asks for if that is fewer, the way a peer that knows every other peer would.
+func (script *ScriptedSender) FindNodeResponse() ScriptedResponse {
+	return func(peer Contact, req RPC, resp *RPC) {
+		script.Lock()
+		closest := make([]Contact, 0, len(script.peers))
+		for _, con := range script.peers {
+			closest = append(closest, con)
+		}
+		script.Unlock()
+		SortContactsByDistance(&closest, req.findNodeTarget)
+		count := REPLICATION
+		if req.findNodeCount > 0 {
+			count = min(req.findNodeCount, count)
+		}
+		resp.FoundNodes(req.findNodeTarget, closest[:min(len(closest), count)])
+	}
+}
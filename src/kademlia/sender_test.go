@@ -0,0 +1,133 @@
+package kademlia
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"testing"
+)
+
+// Returns an unstarted node delivering through a scripted sender that knows count peers and
+// answers FIND_NODE as if every peer knew every other.
+func scriptedNode(count int) (*Node, *ScriptedSender, []Contact) {
+	me := NewContact(RandomIP(), RandomID())
+	node := NewNode(me.ID(), me.IP(), make(chan RPC, 8), make(chan RPC, 8), [4]byte{0, 0, 0, 0}, me, false)
+	node.SetLogLevel(LOG_SILENT)
+	peers := make([]Contact, 0, count)
+	for range count {
+		peers = append(peers, NewContact(RandomIP(), RandomID()))
+	}
+	script := NewScriptedSender()
+	script.AddPeers(peers...)
+	script.On(FIND_NODE, script.FindNodeResponse())
+	node.SetSender(script)
+	return node, script, peers
+}
+
+func TestScriptedFindNode(t *testing.T) {
+	testName := "TestScriptedFindNode"
+	node, script, peers := scriptedNode(50)
+	node.AddContact(peers[0])
+
+	target := peers[17]
+	res := node.FindNode(target.ID())
+	if len(res) == 0 || res[0] != target {
+		log.Printf("[%s] - lookup did not find the target through the scripted peers", testName)
+		t.FailNow()
+	}
+	if len(script.Sent(FIND_NODE)) == 0 {
+		log.Printf("[%s] - no FIND_NODE recorded", testName)
+		t.Fail()
+	}
+
+	script.Fail(target.IP(), errors.New("timeout"))
+	node.RemoveContact(target)
+	res = node.FindNode(target.ID())
+	if slices.Contains(res, target) {
+		log.Printf("[%s] - failing peer returned by the lookup", testName)
+		t.Fail()
+	}
+}
+
+func TestScriptedEnter(t *testing.T) {
+	testName := "TestScriptedEnter"
+	node, script, peers := scriptedNode(30)
+	script.AddPeers(node.Contact)
+	script.Fail(peers[0].IP(), errors.New("timeout"))
+	node.SetBootstrapper(StaticPeerList{Peers: peers[:2]})
+	if err := node.Enter(); err != nil {
+		log.Printf("[%s] - enter failed with one answering entry point: %s", testName, err.Error())
+		t.FailNow()
+	}
+	if len(node.AllContacts()) == 0 {
+		log.Printf("[%s] - routing table empty after entering", testName)
+		t.Fail()
+	}
+
+	node, script, peers = scriptedNode(30)
+	script.Fail(peers[0].IP(), errors.New("timeout"))
+	node.SetBootstrapper(StaticPeerList{Peers: peers[:1]})
+	if err := node.Enter(); err == nil {
+		log.Printf("[%s] - entered although no entry point answered", testName)
+		t.Fail()
+	}
+}
+
+func TestScriptedStoreAccount(t *testing.T) {
+	testName := "TestScriptedStoreAccount"
+	node, script, peers := scriptedNode(40)
+	node.AddContact(peers[0])
+	script.On(STORE_ACCOUNT, func(peer Contact, req RPC, resp *RPC) { resp.StoredAccount(req.accountID, true) })
+
+	acc := RandomID()
+	node.StoreAccount(acc)
+	expected := slices.Clone(peers)
+	SortContactsByDistance(&expected, acc)
+	expected = expected[:REPLICATION]
+	stored := script.Sent(STORE_ACCOUNT)
+	if len(stored) != REPLICATION {
+		log.Printf("[%s] - expected %d STORE_ACCOUNT, got %d", testName, REPLICATION, len(stored))
+		t.FailNow()
+	}
+	for _, rpc := range stored {
+		if !slices.ContainsFunc(expected, func(con Contact) bool { return con.IP() == rpc.receiver }) {
+			log.Printf("[%s] - account stored at %v which is not among the closest peers", testName, rpc.receiver)
+			t.Fail()
+		}
+	}
+}
+
+func TestScriptedFindAccount(t *testing.T) {
+	testName := "TestScriptedFindAccount"
+	node, script, peers := scriptedNode(40)
+	node.AddContact(peers[0])
+
+	acc := RandomID()
+	validators := slices.Clone(peers)
+	SortContactsByDistance(&validators, acc)
+	validators = validators[:REPLICATION]
+	lost := validators[len(validators)-3:]
+	script.On(FIND_ACCOUNT, func(peer Contact, req RPC, resp *RPC) {
+		resp.FoundAccount(req.accountID, !slices.Contains(lost, peer))
+	})
+
+	holders, missing, err := node.findAccount(acc, ALL)
+	if !errors.Is(err, ErrQuorumNotReached) {
+		log.Printf("[%s] - expected the full quorum to fail, got %v", testName, err)
+		t.Fail()
+	}
+	if len(holders) != REPLICATION-len(lost) || len(missing) != len(lost) {
+		log.Printf("[%s] - expected %d holders and %d missing, got %d and %d", testName, REPLICATION-len(lost), len(lost), len(holders), len(missing))
+		t.FailNow()
+	}
+	for _, con := range missing {
+		if !slices.Contains(lost, con) {
+			log.Printf("[%s] - validator %v holding the account reported missing", testName, con.ID())
+			t.Fail()
+		}
+	}
+	if _, _, err := node.findAccount(acc, QUORUM); err != nil {
+		log.Printf("[%s] - read at quorum failed: %s", testName, err.Error())
+		t.Fail()
+	}
+}
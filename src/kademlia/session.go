@@ -0,0 +1,406 @@
+package kademlia
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Encrypted sessions between nodes, see Config.Encryption.
+// A node opens a session with a peer by an OPEN_SESSION handshake exchanging ephemeral X25519
+// keys, the shared secret is expanded into one AES-256-GCM key per direction. Every other RPC
+// then crosses the network as a SEALED envelope: the header the network routes by in the clear,
+// and the encoded RPC encrypted under the sender's key, with the header as additional data so
+// the envelope can not be readdressed. With identities the handshake is signed like any other
+// RPC and the session is bound to the peer's id, without them it keeps out passive observers
+// only.
+
+const (
+	SESSION_TTL   = 120 * TIMEOUT // sessions idle for longer are forgotten
+	REPLAY_WINDOW = 1024          // sequence numbers behind the highest one received that are still accepted once
+)
+
+var sessionInfo = []byte("scalegraph session")
+
+type session struct {
+	id       KademliaID // id of the handshake that opened the session
+	peer     Contact
+	seal     cipher.AEAD // encrypts the RPCs sent to the peer
+	open     cipher.AEAD // decrypts the RPCs received from the peer
+	sent     atomic.Uint64
+	received replayWindow
+	lastUsed atomic.Int64 // unix nanoseconds
+}
+
+// Derives the session opened by the handshake with the given id from the node's own ephemeral
+// key and the peer's. Returns an error if the peer's key is malformed.
+func newSession(id KademliaID, peer Contact, private *ecdh.PrivateKey, peerKey []byte, initiator bool) (*session, error) {
+	public, err := ecdh.X25519().NewPublicKey(peerKey)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("malformed session key: %v", err))
+	}
+	secret, err := private.ECDH(public)
+	if err != nil {
+		return nil, err
+	}
+	own := private.PublicKey().Bytes()
+	salt := append(slices.Clone(peerKey), own...)
+	if initiator {
+		salt = append(slices.Clone(own), peerKey...)
+	}
+	rawID := id.Bytes()
+	keys := hkdf(secret, salt, append(slices.Clone(sessionInfo), rawID[:]...), 64)
+	toResponder, err := newAEAD(keys[:32])
+	if err != nil {
+		return nil, err
+	}
+	toInitiator, err := newAEAD(keys[32:])
+	if err != nil {
+		return nil, err
+	}
+	sess := &session{id: id, peer: peer, seal: toInitiator, open: toResponder}
+	if initiator {
+		sess.seal, sess.open = toResponder, toInitiator
+	}
+	return sess, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// HKDF-SHA256 as in RFC 5869, returns length bytes of key material extracted from secret.
+func hkdf(secret []byte, salt []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+	res := make([]byte, 0, length+sha256.Size)
+	var block []byte
+	for counter := byte(1); len(res) < length; counter++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(block)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		block = expand.Sum(nil)
+		res = append(res, block...)
+	}
+	return res[:length]
+}
+
+// Returns the nonce of the RPC with the given sequence number, sequence numbers are never reused
+// within a session and each direction has a key of its own.
+func sessionNonce(seq uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+func (sess *session) touch(now time.Time) {
+	sess.lastUsed.Store(now.UnixNano())
+}
+
+func (sess *session) idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, sess.lastUsed.Load()))
+}
+
+// Sliding window over the sequence numbers received in a session.
+type replayWindow struct {
+	highest uint64
+	seen    [REPLAY_WINDOW / 64]uint64 // bit seq%REPLAY_WINDOW is set if seq was received
+	sync.Mutex
+}
+
+// Records seq as received. Returns false if it was received before, or is too far behind the
+// highest sequence number to tell.
+func (window *replayWindow) accept(seq uint64) bool {
+	window.Lock()
+	defer window.Unlock()
+	if seq == 0 {
+		// sequence numbers start at one
+		return false
+	}
+	if seq > window.highest {
+		if seq-window.highest >= REPLAY_WINDOW {
+			clear(window.seen[:])
+		} else {
+			for skipped := window.highest + 1; skipped <= seq; skipped++ {
+				window.seen[skipped%REPLAY_WINDOW/64] &^= 1 << (skipped % 64)
+			}
+		}
+		window.highest = seq
+	} else if window.highest-seq >= REPLAY_WINDOW {
+		return false
+	}
+	word, bit := seq%REPLAY_WINDOW/64, uint64(1)<<(seq%64)
+	if window.seen[word]&bit != 0 {
+		return false
+	}
+	window.seen[word] |= bit
+	return true
+}
+
+type sessionTable struct {
+	self    Contact
+	byID    map[KademliaID]*session
+	peers   map[[4]byte]*session        // session the RPCs to a peer are sealed in
+	opening map[[4]byte]*sessionOpening // handshakes in flight, shared by concurrent senders
+	sync.Mutex
+}
+
+type sessionOpening struct {
+	done    chan struct{}
+	session *session
+	err     error
+}
+
+func newSessionTable(self Contact) *sessionTable {
+	return &sessionTable{
+		self:    self,
+		byID:    make(map[KademliaID]*session),
+		peers:   make(map[[4]byte]*session),
+		opening: make(map[[4]byte]*sessionOpening),
+	}
+}
+
+// Registers a session and forgets those idle for longer than ttl, must be called with the lock
+// held. Returns false if a session with the same id is already registered.
+func (table *sessionTable) add(sess *session, now time.Time, ttl time.Duration) bool {
+	if _, ok := table.byID[sess.id]; ok {
+		return false
+	}
+	for id, old := range table.byID {
+		if old.idle(now) > ttl {
+			delete(table.byID, id)
+			if table.peers[old.peer.IP()] == old {
+				delete(table.peers, old.peer.IP())
+			}
+		}
+	}
+	sess.touch(now)
+	table.byID[sess.id] = sess
+	return true
+}
+
+// Stops sealing RPCs to ip in its current session, the next RPC opens a new one. The session still
+// opens the RPCs already in flight until it expires.
+func (table *sessionTable) forget(ip [4]byte) {
+	table.Lock()
+	defer table.Unlock()
+	delete(table.peers, ip)
+}
+
+// Sets a RPC as a session handshake carrying the requester's ephemeral key.
+func (rpc *RPC) OpenSession(key []byte) {
+	rpc.cmd = OPEN_SESSION
+	rpc.sessionKey = key
+}
+
+// Sets a RPC as the answer to a session handshake carrying the responder's ephemeral key.
+func (rpc *RPC) OpenedSession(key []byte) {
+	rpc.cmd = OPENED_SESSION
+	rpc.sessionKey = key
+}
+
+// Returns true for the RPCs that cross the network in the clear: session handshakes, version
+// rejections that may answer one, ENTER requests the simnet's entry service answers, and RPCs a
+// node sends to itself.
+func (net *Network) plaintext(rpc *RPC) bool {
+	switch rpc.cmd {
+	case OPEN_SESSION, OPENED_SESSION, ENTER, UNSUPPORTED_VERSION:
+		return true
+	}
+	return rpc.receiver == net.address && rpc.origin == net.address
+}
+
+// Returns the header of a sealed RPC, authenticated along with its ciphertext.
+func sealingHeader(rpc *RPC) []byte {
+	data := make([]byte, 0, 2*ID_BYTES+RAW_CONTACT_BYTES+32)
+	rawID := rpc.id.Bytes()
+	data = append(data, rawID[:]...)
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.network))
+	if rpc.response {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+	rawSender := rpc.sender.id.Bytes()
+	data = append(data, rawSender[:]...)
+	data = append(data, rpc.sender.ip[:]...)
+	data = binary.BigEndian.AppendUint16(data, uint16(rpc.sender.port))
+	data = append(data, rpc.receiver[:]...)
+	data = append(data, rpc.origin[:]...)
+	data = binary.BigEndian.AppendUint32(data, uint32(rpc.hops))
+	rawSession := rpc.session.Bytes()
+	return append(data, rawSession[:]...)
+}
+
+// Returns the session the RPCs to ip are sealed in, opening one if there is none. Concurrent
+// senders wait for the same handshake.
+func (net *Network) session(ip [4]byte) (*session, error) {
+	table := net.sessions
+	table.Lock()
+	if sess, ok := table.peers[ip]; ok {
+		table.Unlock()
+		return sess, nil
+	}
+	opening, ok := table.opening[ip]
+	if ok {
+		table.Unlock()
+		select {
+		case <-opening.done:
+			return opening.session, opening.err
+		case <-net.listener.Done():
+			return nil, ErrShutdown
+		}
+	}
+	opening = &sessionOpening{done: make(chan struct{})}
+	table.opening[ip] = opening
+	table.Unlock()
+
+	opening.session, opening.err = net.openSession(ip)
+	table.Lock()
+	delete(table.opening, ip)
+	if opening.err == nil {
+		table.add(opening.session, net.timebase.Now(), scaleTimeout(SESSION_TTL, net.timeout))
+		table.peers[ip] = opening.session
+	}
+	table.Unlock()
+	close(opening.done)
+	return opening.session, opening.err
+}
+
+// Runs the handshake opening a session with the node at ip.
+func (net *Network) openSession(ip [4]byte) (*session, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	req := GenerateRPC(ip, net.sessions.self)
+	req.OpenSession(private.PublicKey().Bytes())
+	res, err := net.Send(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.cmd != OPENED_SESSION || res.sender.IP() != ip {
+		return nil, errors.New(fmt.Sprintf("%v refused the session with %v", ip, res.cmd))
+	}
+	return newSession(req.id, res.sender, private, res.sessionKey, true)
+}
+
+// Answers a session handshake with an ephemeral key of its own. The session is registered before
+// the answer is sent, the requester seals its RPCs in it as soon as it has the answer.
+func (net *Network) acceptSession(rpc *RPC) error {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	sess, err := newSession(rpc.id, rpc.sender, private, rpc.sessionKey, false)
+	if err != nil {
+		return err
+	}
+	net.sessions.Lock()
+	added := net.sessions.add(sess, net.timebase.Now(), scaleTimeout(SESSION_TTL, net.timeout))
+	net.sessions.Unlock()
+	if !added {
+		// a replayed handshake must not replace the session its requester holds
+		return errors.New(fmt.Sprintf("session %v already open", rpc.id))
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), net.sessions.self)
+	resp.OpenedSession(private.PublicKey().Bytes())
+	net.Send(resp)
+	return nil
+}
+
+// Returns the envelope rpc crosses the network in, sealed in the session with its receiver.
+func (net *Network) seal(rpc *RPC) (RPC, error) {
+	sess, err := net.session(rpc.receiver)
+	if err != nil {
+		return RPC{}, err
+	}
+	plain, err := EncodeRPC(*rpc)
+	if err != nil {
+		return RPC{}, err
+	}
+	envelope := RPC{
+		id:       rpc.id,
+		cmd:      SEALED,
+		network:  rpc.network,
+		response: rpc.response,
+		sender:   rpc.sender,
+		receiver: rpc.receiver,
+		origin:   rpc.origin,
+		hops:     rpc.hops,
+		session:  sess.id,
+		// in-process account locks hand over a channel, it has no wire form
+		lockChan: rpc.lockChan,
+	}
+	seq := sess.sent.Add(1)
+	sealed := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(plain)+sess.seal.Overhead()), seq)
+	envelope.sealed = sess.seal.Seal(sealed, sessionNonce(seq), plain, sealingHeader(&envelope))
+	sess.touch(net.timebase.Now())
+	return envelope, nil
+}
+
+// Replaces a sealed rpc by the RPC sealed in it, plaintext RPCs are left as they are.
+// Returns an error if the RPC is not sealed in a session with its sender, was tampered with or
+// replayed, or is sent in the clear without being a handshake.
+func (net *Network) unseal(rpc *RPC) error {
+	if rpc.cmd != SEALED {
+		if !net.plaintext(rpc) {
+			return errors.New(fmt.Sprintf("%v rpc sent in the clear", rpc.cmd))
+		}
+		return nil
+	}
+	net.sessions.Lock()
+	sess, ok := net.sessions.byID[rpc.session]
+	net.sessions.Unlock()
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown session %v", rpc.session))
+	}
+	if rpc.sender.IP() != sess.peer.IP() || rpc.sender.ID() != sess.peer.ID() {
+		return errors.New(fmt.Sprintf("session %v is not held with %v", rpc.session, rpc.sender.IP()))
+	}
+	if len(rpc.sealed) < 8 {
+		return errors.New("truncated sealed rpc")
+	}
+	seq := binary.BigEndian.Uint64(rpc.sealed[:8])
+	plain, err := sess.open.Open(nil, sessionNonce(seq), rpc.sealed[8:], sealingHeader(rpc))
+	if err != nil {
+		return err
+	}
+	// checked once the RPC is authenticated, so that forged sequence numbers do not advance the window
+	if !sess.received.accept(seq) {
+		return errors.New(fmt.Sprintf("replayed rpc %d in session %v", seq, rpc.session))
+	}
+	inner, err := DecodeRPC(plain)
+	if err != nil {
+		return err
+	}
+	if inner.id != rpc.id || inner.network != rpc.network || inner.response != rpc.response || inner.sender != rpc.sender ||
+		inner.receiver != rpc.receiver || inner.origin != rpc.origin || inner.hops != rpc.hops {
+		return errors.New(fmt.Sprintf("rpc %v does not match its envelope", rpc.id))
+	}
+	inner.queued = rpc.queued
+	inner.lockChan = rpc.lockChan
+	sess.touch(net.timebase.Now())
+	// the peer holds the session, RPCs to it are sealed in the one it used last
+	net.sessions.Lock()
+	net.sessions.peers[sess.peer.IP()] = sess
+	net.sessions.Unlock()
+	*rpc = inner
+	return nil
+}
@@ -0,0 +1,221 @@
+package kademlia
+
+import (
+	"crypto/ecdh"
+	crand "crypto/rand"
+	"log"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// Returns a running simnet whose nodes seal their RPCs in sessions.
+func encryptedServer(identities int) *Simnet {
+	config := DefaultConfig()
+	config.Encryption = true
+	s := newServer(false, 0.0, nil, identities, config)
+	s.Silence()
+	go s.StartServer()
+	return s
+}
+
+func TestSessionEncryption(t *testing.T) {
+	testName := "TestSessionEncryption"
+	done := make(chan struct{}, 1)
+	s := encryptedServer(NO_IDENTITIES)
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	events, cancel := s.Events().Subscribe(1 << 16)
+	defer cancel()
+	if !nodes[0].Ping(nodes[7].IP()) {
+		log.Printf("[%s] - ping failed", testName)
+		t.Fail()
+	}
+	if found := nodes[3].FindNode(RandomID()); len(found) != REPLICATION {
+		log.Printf("[%s] - lookup returned %d contacts", testName, len(found))
+		t.Fail()
+	}
+	id := RandomID()
+	if err := nodes[4].SubmitWallet(id, 25); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	if wallet, err := nodes[9].ShowWallet(id); err != nil || wallet.Balance != 25 {
+		log.Printf("[%s] - show wallet returned %+v %v", testName, wallet, err)
+		t.Fail()
+	}
+	for len(events) > 0 {
+		ev, ok := (<-events).(RPCSent)
+		if !ok || ev.Receiver == ev.Sender.IP() {
+			continue
+		}
+		if ev.Cmd != SEALED && ev.Cmd != OPEN_SESSION && ev.Cmd != OPENED_SESSION {
+			log.Printf("[%s] - %v rpc crossed the network in the clear", testName, ev.Cmd)
+			t.Fail()
+		}
+	}
+	for _, n := range s.AllNodePointers() {
+		if n.Rejected() != 0 {
+			log.Printf("[%s] - %v rejected %d rpcs", testName, n.IP(), n.Rejected())
+			t.Fail()
+		}
+	}
+}
+
+func TestSessionRejectsTampering(t *testing.T) {
+	testName := "TestSessionRejectsTampering"
+	done := make(chan struct{}, 1)
+	s := encryptedServer(NO_IDENTITIES)
+	nodes := s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+
+	from, to := nodes[1], nodes[2]
+	if !from.Ping(to.IP()) {
+		log.Printf("[%s] - ping failed", testName)
+		t.FailNow()
+	}
+	waitRejected := func(count uint64) bool {
+		deadline := time.Now().Add(TIMEOUT)
+		for to.Rejected() < count && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		return to.Rejected() >= count
+	}
+
+	// a RPC sent in the clear is dropped
+	plain := GenerateRPC(to.IP(), from.Contact)
+	plain.Ping()
+	plain.network = from.networkID
+	plain.origin = from.IP()
+	s.Route(plain)
+	if !waitRejected(1) {
+		log.Printf("[%s] - plaintext rpc was not rejected", testName)
+		t.Fail()
+	}
+
+	// a replayed envelope is dropped the second time
+	req := GenerateRPC(to.IP(), from.Contact)
+	req.Ping()
+	req.network = from.networkID
+	req.origin = from.IP()
+	envelope, err := from.seal(&req)
+	if err != nil {
+		log.Printf("[%s] - failed to seal rpc: %v", testName, err)
+		t.FailNow()
+	}
+	s.Route(envelope)
+	s.Route(envelope)
+	if !waitRejected(2) || to.Rejected() != 2 {
+		log.Printf("[%s] - expected the replay alone to be rejected, %d rpcs were", testName, to.Rejected())
+		t.Fail()
+	}
+
+	// a tampered ciphertext fails to open
+	s.SetLinkPolicy(from.IP(), to.IP(), LinkPolicy{Corrupt: 1.0})
+	if from.Ping(to.IP()) {
+		log.Printf("[%s] - ping over a corrupting link succeeded", testName)
+		t.Fail()
+	}
+	if !waitRejected(3) {
+		log.Printf("[%s] - corrupted rpc was not rejected", testName)
+		t.Fail()
+	}
+}
+
+func TestSessionIdentities(t *testing.T) {
+	testName := "TestSessionIdentities"
+	done := make(chan struct{}, 1)
+	s := encryptedServer(2)
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	if !nodes[0].Ping(nodes[4].IP()) {
+		log.Printf("[%s] - ping failed", testName)
+		t.Fail()
+	}
+	if found := nodes[2].FindNode(RandomID()); len(found) != len(nodes)+1 {
+		log.Printf("[%s] - lookup returned %d contacts", testName, len(found))
+		t.Fail()
+	}
+	if nodes[1].Rejected() != 0 {
+		log.Printf("[%s] - rejected %d rpcs from honest nodes", testName, nodes[1].Rejected())
+		t.Fail()
+	}
+	// handshakes are signed like any other RPC, an unsigned one opens no session
+	req := GenerateRPC(nodes[1].IP(), nodes[3].Contact)
+	key, _ := ecdh.X25519().GenerateKey(crand.Reader)
+	req.OpenSession(key.PublicKey().Bytes())
+	req.network = nodes[3].networkID
+	req.origin = nodes[3].IP()
+	s.Route(req)
+	deadline := time.Now().Add(TIMEOUT)
+	for nodes[1].Rejected() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	nodes[1].sessions.Lock()
+	_, opened := nodes[1].sessions.byID[req.id]
+	nodes[1].sessions.Unlock()
+	if nodes[1].Rejected() == 0 || opened {
+		log.Printf("[%s] - unsigned handshake was accepted", testName)
+		t.Fail()
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	testName := "TestReplayWindow"
+	var window replayWindow
+	for _, c := range []struct {
+		seq      uint64
+		accepted bool
+	}{
+		{0, false},
+		{1, true},
+		{1, false},
+		{5, true},
+		{3, true},
+		{3, false},
+		{5 + REPLAY_WINDOW, true},
+		{5, false},
+		{6, true},
+		{6, false},
+		{6 + REPLAY_WINDOW/2, true},
+		{4 + 3*REPLAY_WINDOW, true},
+		{5 + REPLAY_WINDOW, false},
+		{3 + 3*REPLAY_WINDOW, true},
+	} {
+		if res := window.accept(c.seq); res != c.accepted {
+			log.Printf("[%s] - sequence number %d accepted %v, expected %v", testName, c.seq, res, c.accepted)
+			t.Fail()
+		}
+	}
+}
+
+// Pings between random nodes of a 20 node cluster, with or without sessions.
+func benchmarkPing(b *testing.B, encryption bool) {
+	done := make(chan struct{}, 1)
+	config := DefaultConfig()
+	config.Encryption = encryption
+	s, _ := NewServerWithConfig(false, 0.0, config)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nodes[rand.Intn(len(nodes))].Ping(nodes[rand.Intn(len(nodes))].IP())
+	}
+}
+
+func BenchmarkPingPlaintext(b *testing.B) {
+	benchmarkPing(b, false)
+}
+
+func BenchmarkPingEncrypted(b *testing.B) {
+	benchmarkPing(b, true)
+}
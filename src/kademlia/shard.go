@@ -0,0 +1,86 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+const (
+	MAX_ROUTER_SHARDS = 256 // upper bound on the router goroutines of a sharded simnet
+)
+
+// Router shards of a simnet, each with its own inbound channel and router loop.
+// RPCs are assigned to a shard by the hash of their receiver IP.
+type routerShards struct {
+	content []chan RPC
+	load    []atomic.Uint64
+}
+
+// Returns the shard responsible for RPCs addressed to receiver.
+func shardIndex(receiver [4]byte, shards int) int {
+	hash := fnv.New32a()
+	hash.Write(receiver[:])
+	return int(hash.Sum32() % uint32(shards))
+}
+
+// Returns the channel a RPC addressed to receiver is handed to the simnet through.
+func (net *Network) outbound(receiver [4]byte) chan RPC {
+	if len(net.shards) == 0 {
+		return net.sender
+	}
+	return net.shards[shardIndex(receiver, len(net.shards))]
+}
+
+// Splits routing across count router goroutines keyed by the hash of the receiver IP, instead of
+// the single listener loop that serializes all traffic.
+// Must be called before the server is started, returns an error otherwise or if count is out of
+// range.
+func (simnet *Simnet) SetRouterShards(count int) error {
+	if count < 1 || count > MAX_ROUTER_SHARDS {
+		return errors.New(fmt.Sprintf("router shards must be between 1 and %d, got %d", MAX_ROUTER_SHARDS, count))
+	}
+	if simnet.started.Load() {
+		return errors.New("router shards must be set before the server is started")
+	}
+	shards := &routerShards{
+		content: make([]chan RPC, count),
+		load:    make([]atomic.Uint64, count),
+	}
+	for i := range shards.content {
+		shards.content[i] = make(chan RPC, cap(simnet.listener))
+	}
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.shards = shards
+	for _, n := range simnet.nodePointer {
+		n.Network.shards = shards.content
+	}
+	return nil
+}
+
+// Returns the number of RPCs each router shard has routed, or nil if the simnet is not sharded.
+func (simnet *Simnet) ShardLoad() []uint64 {
+	if simnet.shards == nil {
+		return nil
+	}
+	res := make([]uint64, len(simnet.shards.load))
+	for i := range simnet.shards.load {
+		res[i] = simnet.shards.load[i].Load()
+	}
+	return res
+}
+
+// Routes the RPCs of a single shard until the simnet is shut down.
+func (simnet *Simnet) shardLoop(shard int) {
+	for {
+		select {
+		case <-simnet.shutdown:
+			return
+		case rpc := <-simnet.shards.content[shard]:
+			simnet.shards.load[shard].Add(1)
+			simnet.dispatch(rpc)
+		}
+	}
+}
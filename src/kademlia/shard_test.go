@@ -0,0 +1,49 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+)
+
+func TestShardedRouting(t *testing.T) {
+	testName := "TestShardedRouting"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	if err := s.SetRouterShards(0); err == nil {
+		log.Printf("[%s] - accepted zero router shards", testName)
+		t.Fail()
+	}
+	shards := 4
+	if err := s.SetRouterShards(shards); err != nil {
+		log.Printf("[%s] - setting router shards failed: %s", testName, err.Error())
+		t.FailNow()
+	}
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	for _, target := range nodes[:5] {
+		res := nodes[len(nodes)-1].FindNode(target.ID())
+		if len(res) == 0 || res[0] != target.Contact {
+			log.Printf("[%s] - lookup through the sharded simnet did not find %v", testName, target.ID())
+			t.Fail()
+		}
+	}
+	load := s.ShardLoad()
+	if len(load) != shards {
+		log.Printf("[%s] - expected load of %d shards, got %d", testName, shards, len(load))
+		t.FailNow()
+	}
+	for i, routed := range load {
+		if routed == 0 {
+			log.Printf("[%s] - shard %d routed nothing", testName, i)
+			t.Fail()
+		}
+	}
+	if err := s.SetRouterShards(2); err == nil {
+		log.Printf("[%s] - changed router shards of a running server", testName)
+		t.Fail()
+	}
+}
@@ -1,60 +1,151 @@
 package kademlia
 
 import (
+	"context"
+	crand "crypto/rand"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"math/rand"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Record and all active IDs and IPs as well as pairwise connections.
 type spawned struct {
-	id          map[[5]uint32]bool
+	id          map[KademliaID]bool
 	ip          map[[4]byte]bool
 	nodes       []Contact
 	nodePointer []*Node
 	sync.RWMutex
 }
 
-type chanTable struct {
-	content map[[4]byte]chan RPC
-	sync.RWMutex
-}
-
 // Simulated network that handles routing between nodes.
 // Additionally keeps track of all active nodes.
 type Simnet struct {
-	chanTable
+	chanTable *chanTable
 	spawned
 	listener          chan RPC
-	serverID          [5]uint32
+	serverID          KademliaID
 	serverIP          [4]byte
 	masterNode        *Node
 	masterNodeContact Contact
-	dropPercent       float32
+	dropPercent       atomic.Uint32 // float32 bits, see DropRate
+	senderCheck       atomic.Int32  // see SetSenderCheck
+	rng               simnetRand
+	identities        int // crypto puzzle difficulty of the nodes' identities, NO_IDENTITIES if ids are not bound to keys
+	queueConfig       QueueConfig
+	clockConfig       ClockConfig
+	version           ProtocolVersion
+	minVersion        ProtocolVersion
+	shards            *routerShards
+	routeWorkers      int      // see SetRouteWorkers
+	routeQueue        chan RPC // RPCs waiting for a route worker
+	held              heldRPCs // RPCs waiting out a delay, see hold
+	started           atomic.Bool
+	timebase          Clock
+	links             *linkTable
+	subnets           *subnetTable
+	bridges           *bridgeTable
+	bootstrap         bootstrapFault
+	join              *joinGate
+	stats             *simnetStats
+	metrics           Metrics
+	events            *EventBus
+	routines          *routineTracker
+	shutdown          chan struct{}
+	shutdownOnce      sync.Once
+	churn             churn
+	logBase           *slog.Logger
+	logger            *slog.Logger
+	logLevel          *slog.LevelVar
+	silenced          bool                          // see Silence, guarded by the spawned lock
+	config            Config                        // Kademlia parameters of the spawned nodes
+	tracer            atomic.Pointer[traceRecorder] // see RecordTrace, nil while no trace is recorded
 	debug             bool
 }
 
 func NewServer(debugMode bool, dropPercent float32) *Simnet {
+	return newServer(debugMode, dropPercent, nil, NO_IDENTITIES, DefaultConfig())
+}
+
+// Returns a simnet that picks node ids, node IPs and the entry points handed to joining nodes
+// from a source seeded with seed, so that it spawns the same nodes in the same order every run.
+// The nodes' own randomness, such as RPC ids, is not affected.
+func NewSeededServer(debugMode bool, dropPercent float32, seed int64) *Simnet {
+	return newServer(debugMode, dropPercent, rand.New(rand.NewSource(seed)), NO_IDENTITIES, DefaultConfig())
+}
+
+// Returns a simnet whose nodes, the master node included, derive their ids from key pairs solving
+// a crypto puzzle of the given difficulty and drop RPCs from senders that can not prove their id,
+// see Identity. Nodes spawned with a chosen id, such as Sybils, have no identity and are ignored
+// by the others. Returns an error if difficulty is not between 0 and MAX_PUZZLE.
+func NewServerWithIdentities(debugMode bool, dropPercent float32, difficulty int) (*Simnet, error) {
+	if difficulty < 0 || difficulty > MAX_PUZZLE {
+		return nil, errors.New(fmt.Sprintf("puzzle difficulty must be between 0 and %d, got %d", MAX_PUZZLE, difficulty))
+	}
+	return newServer(debugMode, dropPercent, nil, difficulty, DefaultConfig()), nil
+}
+
+// Returns a simnet whose nodes, the master node included, use the given Kademlia parameters
+// instead of the package defaults. Returns an error if the configuration is invalid.
+func NewServerWithConfig(debugMode bool, dropPercent float32, config Config) (*Simnet, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return newServer(debugMode, dropPercent, nil, NO_IDENTITIES, config), nil
+}
+
+// Returns a simnet combining NewSeededServer and NewServerWithConfig.
+// Returns an error if the configuration is invalid.
+func NewSeededServerWithConfig(debugMode bool, dropPercent float32, seed int64, config Config) (*Simnet, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return newServer(debugMode, dropPercent, rand.New(rand.NewSource(seed)), NO_IDENTITIES, config), nil
+}
+
+func newServer(debugMode bool, dropPercent float32, source *rand.Rand, identities int, config Config) *Simnet {
 	s := Simnet{
-		chanTable: chanTable{
-			content: make(map[[4]byte]chan RPC),
-		},
+		chanTable: newChanTable(),
 		spawned: spawned{
-			id:    make(map[[5]uint32]bool),
+			id:    make(map[KademliaID]bool),
 			ip:    make(map[[4]byte]bool),
 			nodes: make([]Contact, 0),
 		},
-		listener:    make(chan RPC, 2048),
-		serverID:    [5]uint32{0, 0, 0, 0, 0},
-		serverIP:    [4]byte{0, 0, 0, 0},
-		dropPercent: dropPercent,
-		debug:       debugMode,
+		rng:          newSimnetRand(source),
+		identities:   identities,
+		listener:     make(chan RPC, 2048),
+		routeQueue:   make(chan RPC, 2048),
+		held:         heldRPCs{wake: make(chan struct{}, 1)},
+		routeWorkers: DEFAULT_ROUTE_WORKERS,
+		serverID:     KademliaID{},
+		serverIP:     [4]byte{0, 0, 0, 0},
+		queueConfig:  QueueConfig{DEFAULT_QUEUE_SIZE, BACKPRESSURE},
+		version:      PROTOCOL_VERSION,
+		minVersion:   MIN_PROTOCOL_VERSION,
+		timebase:     SystemClock(),
+		links:        newLinkTable(),
+		subnets:      newSubnetTable(),
+		bridges:      newBridgeTable(),
+		join:         newJoinGate(),
+		stats:        newSimnetStats(),
+		metrics:      noopMetrics{},
+		events:       NewEventBus(),
+		routines:     newRoutineTracker(),
+		shutdown:     make(chan struct{}),
+		logBase:      defaultLogger(),
+		logLevel:     newLevel(debugLevel(debugMode)),
+		config:       config,
+		debug:        debugMode,
 	}
 
+	s.logger = componentLogger(s.logBase, s.logLevel, "component", "simnet")
+	s.dropPercent.Store(math.Float32bits(dropPercent))
+
 	// Generate master node and attach it to the server.
 	s.masterNode = s.GenerateRandomNode()
 	s.masterNodeContact = NewContact(s.masterNode.ip, s.masterNode.id)
@@ -66,35 +157,147 @@ func NewServer(debugMode bool, dropPercent float32) *Simnet {
 
 // Roll the RNG to determine if the rpc should be dropped.
 func (simnet *Simnet) DropRoll() bool {
-	if simnet.dropPercent == 0.0 {
+	dropPercent := simnet.DropRate()
+	if dropPercent == 0.0 {
 		return false
 	}
-	roll := rand.Float32() < simnet.dropPercent
+	roll := simnet.rng.float32() < dropPercent
 	if roll {
 		return true
 	}
 	return false
 }
 
+// Source of the simnet's own random choices, the global source unless the simnet is seeded.
+// Rolls made per routed RPC, such as drops, come from a stream of their own, so that traffic does
+// not shift the ids and IPs drawn for the nodes spawned after it.
+type simnetRand struct {
+	source *rand.Rand
+	rolls  *rand.Rand
+	sync.Mutex
+}
+
+func newSimnetRand(source *rand.Rand) simnetRand {
+	if source == nil {
+		return simnetRand{}
+	}
+	return simnetRand{source: source, rolls: rand.New(rand.NewSource(source.Int63()))}
+}
+
+// Returns a roll in [0, 1) for a decision about a routed RPC.
+func (rng *simnetRand) float32() float32 {
+	rng.Lock()
+	defer rng.Unlock()
+	if rng.rolls == nil {
+		return rand.Float32()
+	}
+	return rng.rolls.Float32()
+}
+
+// Returns a roll in [0, n) for a decision about a routed RPC.
+func (rng *simnetRand) intn(n int) int {
+	rng.Lock()
+	defer rng.Unlock()
+	if rng.rolls == nil {
+		return rand.Intn(n)
+	}
+	return rng.rolls.Intn(n)
+}
+
+func (rng *simnetRand) uint32() uint32 {
+	rng.Lock()
+	defer rng.Unlock()
+	if rng.source == nil {
+		return rand.Uint32()
+	}
+	return rng.source.Uint32()
+}
+
+// Fills p with bytes from the source, for generating the key pairs of identities.
+func (rng *simnetRand) Read(p []byte) (int, error) {
+	rng.Lock()
+	defer rng.Unlock()
+	if rng.source == nil {
+		return crand.Read(p)
+	}
+	return rng.source.Read(p)
+}
+
+func (simnet *Simnet) randomID() KademliaID {
+	var res KademliaID
+	for res.IsZero() {
+		for i := range res {
+			res[i] = simnet.rng.uint32()
+		}
+	}
+	return res
+}
+
+func (simnet *Simnet) randomIP() [4]byte {
+	var res [4]byte
+	for i := range res {
+		res[i] = byte(simnet.rng.uint32())
+	}
+	return res
+}
+
+// Returns the probability that the simnet drops a RPC.
+func (simnet *Simnet) DropRate() float32 {
+	return math.Float32frombits(simnet.dropPercent.Load())
+}
+
+// Changes the probability that the simnet drops a RPC, takes effect for RPCs routed from now on.
+// Returns an error if rate is not between 0 and 1.
+func (simnet *Simnet) SetDropRate(rate float32) error {
+	if rate < 0 || rate > 1 {
+		return errors.New(fmt.Sprintf("drop rate must be between 0 and 1, got %v", rate))
+	}
+	simnet.dropPercent.Store(math.Float32bits(rate))
+	return nil
+}
+
 func (simnet *Simnet) MasterNode() Contact {
 	return simnet.masterNodeContact
 }
 
-func (simnet *Simnet) SpawnNode(done chan [5]uint32) *Node {
+func (simnet *Simnet) SpawnNode(done chan KademliaID) *Node {
 	newNode := simnet.GenerateRandomNode()
-	go newNode.Start(done)
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
 	return newNode
 }
 
-// Removes node from simnet records and sends a shutdown signal to it.
-func (simnet *Simnet) ShutdownNode(node *Node) {
-	simnet.chanTable.Lock()
-	simnet.spawned.Lock()
-	defer simnet.chanTable.Unlock()
-	defer simnet.spawned.Unlock()
+// Spawns a node that belongs to network instead of DEFAULT_NETWORK.
+// The node enters through nodes of its own network and ignores RPCs from any other.
+func (simnet *Simnet) SpawnNodeInNetwork(network NetworkID, done chan KademliaID) *Node {
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	newNode := simnet.generateNode(config, network, KademliaID{}, FULL_NODE)
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
+	return newNode
+}
+
+// Spawns a node with a chosen id, used to simulate a node rejoining after it was shut down.
+// Returns an error if a node with the id is already attached.
+func (simnet *Simnet) SpawnNodeWithID(id KademliaID, done chan KademliaID) (*Node, error) {
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	newNode := simnet.generateNode(config, DEFAULT_NETWORK, id, FULL_NODE)
+	if newNode == nil {
+		return nil, errors.New(fmt.Sprintf("node id %v is already in use", id))
+	}
+	simnet.routines.Go("node start", func() { newNode.Start(done) })
+	return newNode, nil
+}
 
-	delete(simnet.chanTable.content, node.IP())
+// Removes node from simnet records and stops it.
+// Returns an error if the node's goroutines do not exit within the shutdown grace period.
+func (simnet *Simnet) ShutdownNode(node *Node) error {
+	simnet.chanTable.remove(node.IP())
+	simnet.spawned.Lock()
 	delete(simnet.spawned.ip, node.IP())
+	simnet.subnets.occupy(node.IP(), -1)
 	delete(simnet.spawned.id, node.ID())
 	i := slices.Index(simnet.spawned.nodes, node.Contact)
 	if i != -1 {
@@ -104,16 +307,22 @@ func (simnet *Simnet) ShutdownNode(node *Node) {
 	if p != -1 {
 		simnet.spawned.nodePointer = slices.Delete(simnet.spawned.nodePointer, p, p+1)
 	}
-	node.shutdown <- struct{}{}
+	simnet.spawned.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), simnet.config.scaled(SHUTDOWN_GRACE))
+	defer cancel()
+	err := node.Stop(ctx)
+	simnet.events.Publish(NodeShutdown{node.Contact})
+	return err
 }
 
 func (simnet *Simnet) SpawnCluster(size int, done chan struct{}) []*Node {
 	nodes := make([]*Node, 0, size)
-	clusterDone := make(chan [5]uint32, 64)
+	clusterDone := make(chan KademliaID, 64)
 	missingNodes := size
 
 	// Spawn the missing nodes.
-	log.Printf("Launching cluster of size: %d", size)
+	simnet.logger.Info("launching cluster", "size", size)
 	for missingNodes > 0 {
 		cluster := make([]*Node, 0, missingNodes)
 		for range missingNodes {
@@ -123,7 +332,7 @@ func (simnet *Simnet) SpawnCluster(size int, done chan struct{}) []*Node {
 		for range cluster {
 			<-clusterDone
 		}
-		time.Sleep(time.Millisecond * 100)
+		simnet.timebase.Sleep(time.Millisecond * 100)
 
 		// Verify visible nodes by looping through the cluster and checking that they can be found from the master node.
 		// If a node can not be found it is shut down.
@@ -135,7 +344,10 @@ func (simnet *Simnet) SpawnCluster(size int, done chan struct{}) []*Node {
 			visRes := simnet.masterNode.FindNode(n.ID())
 			if len(visRes) > 0 {
 				if visRes[0].ID() != n.ID() {
-					simnet.ShutdownNode(n)
+					err := simnet.ShutdownNode(n)
+					if err != nil {
+						simnet.logger.Debug("invisible node did not shut down cleanly", "err", err)
+					}
 					removeIndecies = append(removeIndecies, i)
 				}
 			}
@@ -149,7 +361,7 @@ func (simnet *Simnet) SpawnCluster(size int, done chan struct{}) []*Node {
 		nodes = append(nodes, cluster...)
 		cluster = nil
 		missingNodes = size - len(nodes)
-		log.Printf("Launching cluster: missing %d nodes", missingNodes)
+		simnet.logger.Info("launching cluster", "missing", missingNodes)
 	}
 	if len(nodes) != size {
 		panic("spawned cluster of incorrect size!")
@@ -165,15 +377,15 @@ func (simnet *Simnet) Stimulate() error {
 	simnet.spawned.Unlock()
 
 	for _, origin := range nodes {
-		go origin.ClearDeadContacts()
+		simnet.routines.Go("clear dead contacts", origin.ClearDeadContacts)
 	}
-	time.Sleep(TIMEOUT)
+	simnet.timebase.Sleep(simnet.config.Timeout)
 
 	lostNodes := 0
 	stimulatedNodes := 0
 	for _, origin := range nodes {
 		for _, target := range nodes {
-			go func() {
+			simnet.routines.Go("stimulate", func() {
 				res := origin.FindNode(target.ID())
 				stimulatedNodes++
 				if len(res) == 0 {
@@ -183,11 +395,11 @@ func (simnet *Simnet) Stimulate() error {
 				if res[0].ID() != target.ID() {
 					lostNodes++
 				}
-			}()
+			})
 		}
 	}
 	if lostNodes != 0 {
-		return errors.New(fmt.Sprintf("Failed to locate %d nodes", lostNodes))
+		return failure(ErrNodeNotFound, "Failed to locate %d nodes", lostNodes)
 	} else {
 		return nil
 	}
@@ -204,64 +416,178 @@ func (simnet *Simnet) AllNodePointers() []*Node {
 
 // Generates a new node with random values attaches it to the server and returns a pointer to it.
 func (simnet *Simnet) GenerateRandomNode() *Node {
-	simnet.chanTable.Lock()
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	return simnet.generateNode(config, DEFAULT_NETWORK, KademliaID{}, FULL_NODE)
+}
+
+// Generates a node with the given id, or a random one if id is zero.
+// Returns nil if the requested id is already in use.
+func (simnet *Simnet) generateNode(config QueueConfig, network NetworkID, id KademliaID, role Role) *Node {
+	return simnet.generateNodeAt(config, network, id, [4]byte{}, role)
+}
+
+// Generates a node like generateNode at the given ip, or a random one if ip is zero.
+// Returns nil if the requested id or ip is already in use.
+func (simnet *Simnet) generateNodeAt(config QueueConfig, network NetworkID, id KademliaID, ip [4]byte, role Role) *Node {
 	simnet.spawned.Lock()
 	defer simnet.spawned.Unlock()
-	defer simnet.chanTable.Unlock()
 
-	id := RandomID()
+	random := id.IsZero()
+	var identity Identity
+	if random {
+		id, identity = simnet.newIdentity()
+	}
 	_, ok := simnet.spawned.id[id]
+	if ok && !random {
+		return nil
+	}
 	// if the generated id is already taken, generate new ones until a free one is found.
 	for ok {
-		id = RandomID()
+		id, identity = simnet.newIdentity()
 		_, ok = simnet.spawned.id[id]
 	}
+	if ip != [4]byte{} && simnet.spawned.ip[ip] {
+		return nil
+	}
 	simnet.spawned.id[id] = true
 
-	ip := RandomIP()
 	_, ok = simnet.spawned.ip[ip]
-	// if the generated ip is already taken, generate new ones until a free one is found.
-	for ok {
-		ip = RandomIP()
+	// if the ip is zero or the generated ip is already taken, generate new ones until a free one is found.
+	for ok || ip == [4]byte{} {
+		ip = simnet.randomSubnetIP()
 		_, ok = simnet.spawned.ip[ip]
 	}
 	simnet.spawned.ip[ip] = true
+	simnet.subnets.occupy(ip, 1)
 
 	node := NewContact(ip, id)
 	simnet.spawned.nodes = append(simnet.spawned.nodes, node)
 
-	nodeReceiver := make(chan RPC, 128)
-	simnet.chanTable.content[ip] = nodeReceiver
-	newNode := NewNode(id, ip, nodeReceiver, simnet.listener, simnet.serverIP, simnet.MasterNode(), false)
+	nodeReceiver := make(chan RPC, max(config.Size, 0))
+	newNode, _ := NewNodeWithConfig(id, ip, nodeReceiver, simnet.listener, simnet.serverIP, simnet.MasterNode(), false, simnet.config)
+	newNode.Network.listener.SetOverflowPolicy(config.Overflow)
+	if simnet.shards != nil {
+		newNode.Network.shards = simnet.shards.content
+	}
+	newNode.SetNetworkID(network)
+	newNode.SetRole(role)
+	newNode.SetMetrics(simnet.metrics)
+	newNode.SetLogger(simnet.logBase)
+	if simnet.silenced {
+		newNode.Silence()
+	}
+	newNode.SetTimebase(simnet.timebase)
+	newNode.SetClock(simnet.clockConfig.draw())
+	newNode.SetBootstrapper(EntryService{})
+	newNode.SetJoinToken(simnet.joinSecret())
+	newNode.SetProtocolVersion(simnet.version, simnet.minVersion)
+	if identity.PublicKey != nil {
+		newNode.SetIdentity(identity, simnet.identities)
+	}
+	newNode.events = simnet.events
+	simnet.chanTable.insert(ip, newNode.Network.listener)
 	simnet.nodePointer = append(simnet.nodePointer, newNode)
+	simnet.events.Publish(NodeSpawned{node})
 	return newNode
 }
 
-// Returns contact information for a random node in the network.
-func (simnet *Simnet) randomNode() Contact {
+// Returns a random id, bound to a fresh identity if the simnet's nodes have identities.
+func (simnet *Simnet) newIdentity() (KademliaID, Identity) {
+	if simnet.identities == NO_IDENTITIES {
+		return simnet.randomID(), Identity{}
+	}
+	identity, err := generateIdentity(&simnet.rng, simnet.identities)
+	if err != nil {
+		panic(err)
+	}
+	return identity.ID(), identity
+}
+
+// Returns contact information for a random node of the given network other than joiner, or false
+// if the network has no such member. The master node is never handed to a node of another network,
+// which could not reach it.
+func (simnet *Simnet) randomNode(network NetworkID, joiner KademliaID) (Contact, bool) {
 	simnet.spawned.RLock()
 	defer simnet.spawned.RUnlock()
-	index, _ := RandU32(0, uint32(len(simnet.nodes)))
-	return simnet.nodes[index]
+	members := make([]Contact, 0, len(simnet.nodePointer))
+	for _, n := range simnet.nodePointer {
+		if n.NetworkID() == network && n.ID() != joiner && n.Role() != OBSERVER && !simnet.joinDenied(n.ID()) {
+			members = append(members, n.Contact)
+		}
+	}
+	if len(members) == 0 {
+		return Contact{}, false
+	}
+	return members[simnet.rng.uint32()%uint32(len(members))], true
 }
 
-// Initialize listening loop which spawns goroutines.
+// Initialize listening loop which spawns goroutines, and one loop per router shard if the
+// simnet is sharded.
+// Returns once the simnet is shut down.
 func (simnet *Simnet) StartServer() {
+	defer simnet.routines.track("server")()
+	simnet.started.Store(true)
+	if simnet.shards != nil {
+		for i := range simnet.shards.content {
+			simnet.routines.Go("router shard", func() { simnet.shardLoop(i) })
+		}
+	}
+	simnet.startRouteWorkers()
+	simnet.routines.Go("release held rpcs", simnet.releaseHeld)
 	// Master node should not be part of the main wait group.
-	go simnet.masterNode.Start(make(chan [5]uint32, 64))
+	simnet.routines.Go("node start", func() { simnet.masterNode.Start(make(chan KademliaID, 64)) })
 	for {
-		rpc := <-simnet.listener
-		go simnet.Route(rpc)
+		select {
+		case <-simnet.shutdown:
+			return
+		case rpc := <-simnet.listener:
+			simnet.dispatch(rpc)
+		}
 	}
 }
 
-func (simnet *Simnet) ListKnownIPChannels() string {
-	simnet.chanTable.RLock()
-	defer simnet.chanTable.RUnlock()
-	keys := make([][4]byte, 0, len(simnet.chanTable.content))
-	for k := range simnet.chanTable.content {
-		keys = append(keys, k)
+// Stops the server loop and every node, then verifies that all goroutines spawned by the
+// simnet and its nodes have exited.
+// Returns an error naming the leaked goroutines per component if any are still running after
+// the shutdown grace period.
+func (simnet *Simnet) Shutdown() error {
+	simnet.shutdownOnce.Do(func() {
+		close(simnet.shutdown)
+	})
+	simnet.StopChurn()
+
+	nodes := simnet.AllNodePointers()
+	errChan := make(chan error, len(nodes))
+	for _, n := range nodes {
+		go func() {
+			errChan <- simnet.ShutdownNode(n)
+		}()
 	}
+	leaks := ""
+	for range nodes {
+		err := <-errChan
+		if err != nil {
+			leaks += fmt.Sprintf("\n%s", err.Error())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), simnet.config.scaled(SHUTDOWN_GRACE))
+	defer cancel()
+	err := simnet.routines.Wait(ctx)
+	if err != nil {
+		leaks += fmt.Sprintf("\nsimnet failed to stop cleanly: %s", err.Error())
+	}
+	if leaks != "" {
+		return errors.New(fmt.Sprintf("simnet shutdown leaked goroutines:%s", leaks))
+	}
+	return nil
+}
+
+func (simnet *Simnet) ListKnownIPChannels() string {
+	keys := simnet.chanTable.ips()
+	slices.SortFunc(keys, CompareIP)
 	keyString := fmt.Sprint("known IP channels:")
 	for _, val := range keys {
 		keyString += fmt.Sprintf("\n%v", val)
@@ -270,35 +596,116 @@ func (simnet *Simnet) ListKnownIPChannels() string {
 }
 
 // Routes incomming RPC to the correct nodes.
+// A RPC delayed by its link or by the entry service is held back instead of keeping the route
+// worker busy, and routed again once the delay has passed, see hold.
 func (simnet *Simnet) Route(rpc RPC) {
-	simnet.chanTable.RLock()
-	defer simnet.chanTable.RUnlock()
-
-	routeChan, ok := simnet.chanTable.content[rpc.receiver]
-	if !ok {
-		if simnet.debug {
-			log.Printf("[ERROR] - could not locate node channel for node IP %v RPC %s", rpc.receiver, rpc.Display())
+	start := rpc.routeStart
+	first := start.IsZero()
+	if first {
+		start = simnet.timebase.Now()
+		simnet.events.Publish(RPCSent{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response})
+		if !simnet.checkSender(rpc, start) {
+			return
 		}
+	}
+	routeChan, ok := simnet.chanTable.lookup(rpc.receiver)
+	if !ok && simnet.routeBridged(rpc, start) {
+		return
+	}
+	if !ok {
+		simnet.stats.recordRoute(rpc, false, false, simnet.timebase.Since(start))
+		simnet.trace(rpc, start, "unknown receiver")
+		simnet.events.Publish(RPCDropped{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response, "unknown receiver"})
+		simnet.logger.Debug("could not locate node channel", "receiver", rpc.receiver, "rpc", rpc.id, "cmd", rpc.cmd)
 		return
 	}
 
-	if rpc.cmd == ENTER {
-		simnet.spawned.RLock()
-		defer simnet.spawned.RUnlock()
-		nodes := make([]Contact, 0, 2)
-		nodes = append(nodes, simnet.randomNode())
-		nodes = append(nodes, simnet.randomNode())
-
-		rpc.foundNodes = nodes
+	var delay time.Duration
+	if rpc.cmd == ENTER && !rpc.response {
+		fault := simnet.BootstrapFault()
+		delay += fault.Delay
+		if fault.Unavailable {
+			simnet.stats.recordRoute(rpc, false, true, simnet.timebase.Since(start))
+			simnet.trace(rpc, start, "bootstrap outage")
+			simnet.events.Publish(RPCDropped{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response, "bootstrap outage"})
+			simnet.logger.Debug("entry service unavailable, dropping rpc", "rpc", rpc.id)
+			return
+		}
+		if simnet.admit(&rpc) {
+			// randomNode takes the read lock itself, holding it here as well deadlocks against a
+			// pending writer.
+			nodes := make([]Contact, 0, 2)
+			for range 2 {
+				if con, ok := simnet.randomNode(rpc.network, rpc.sender.ID()); ok {
+					nodes = append(nodes, con)
+				}
+			}
+			rpc.foundNodes = nodes
+		}
 		rpc.response = true
 	}
 
-	if simnet.DropRoll() {
-		if simnet.debug {
-			log.Printf("Dropping RPC: %v\n", rpc.id)
+	dropReason := ""
+	// a held RPC passed both checks before it was held back
+	if first && rpc.hops > MAX_HOPS {
+		// a safety net against routing loops, nodes forwarding requests on behalf of others count the hops
+		dropReason = "hop limit"
+	} else if first && !simnet.passNAT(rpc.sender.IP(), rpc.receiver) {
+		dropReason = "nat"
+	}
+	policy, hasPolicy := simnet.LinkPolicy(rpc.sender.IP(), rpc.receiver)
+	if !hasPolicy {
+		policy, hasPolicy = simnet.subnetPolicy(rpc.sender.IP(), rpc.receiver)
+	}
+	if hasPolicy {
+		delay += policy.Delay
+	}
+	if first && dropReason == "" && delay > 0 {
+		rpc.routeStart = start
+		simnet.hold(rpc, delay)
+		return
+	}
+	if hasPolicy && dropReason == "" {
+		if policy.Drop > 0 && simnet.rng.float32() < policy.Drop {
+			dropReason = "link policy"
+		} else if policy.Corrupt > 0 && simnet.rng.float32() < policy.Corrupt {
+			simnet.stats.recordCorruption()
+			simnet.logger.Debug("corrupting rpc", "rpc", rpc.id, "cmd", rpc.cmd)
+			corruptRPC(&rpc, &simnet.rng)
 		}
+	}
+	if dropReason == "" && simnet.DropRoll() {
+		dropReason = "drop roll"
+	}
+	dropped := dropReason != ""
+	simnet.metrics.RPCRouted(rpc.cmd, dropped)
+	if dropped {
+		simnet.stats.recordRoute(rpc, false, true, simnet.timebase.Since(start))
+		simnet.trace(rpc, start, dropReason)
+		simnet.events.Publish(RPCDropped{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response, dropReason})
+		simnet.logger.Debug("dropping rpc", "rpc", rpc.id, "cmd", rpc.cmd, "reason", dropReason)
 		return
 	}
-	routeChan <- rpc
-	return
+	result, evicted := routeChan.deliver(rpc)
+	for _, old := range evicted {
+		simnet.metrics.HandlerDropped(old.cmd)
+		simnet.stats.recordOverflow(old)
+		simnet.events.Publish(RPCDropped{old.id, old.cmd, old.sender, old.receiver, old.response, "queue overflow"})
+	}
+	simnet.stats.recordRoute(rpc, result == DELIVERED, result == OVERFLOWED, simnet.timebase.Since(start))
+	switch result {
+	case DELIVERED:
+		simnet.trace(rpc, start, "")
+		simnet.events.Publish(RPCDelivered{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response})
+	case OVERFLOWED:
+		simnet.metrics.HandlerDropped(rpc.cmd)
+		simnet.stats.recordOverflow(rpc)
+		simnet.trace(rpc, start, "queue overflow")
+		simnet.events.Publish(RPCDropped{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response, "queue overflow"})
+		simnet.logger.Debug("receiver queue full, dropping rpc", "receiver", rpc.receiver, "rpc", rpc.id)
+	default:
+		simnet.trace(rpc, start, "receiver shut down")
+		simnet.events.Publish(RPCDropped{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response, "receiver shut down"})
+		simnet.logger.Debug("node shut down before rpc was delivered", "receiver", rpc.receiver, "rpc", rpc.id)
+	}
 }
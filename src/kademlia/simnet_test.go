@@ -0,0 +1,80 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSimnetShutdown(t *testing.T) {
+	testName := "TestSimnetShutdown"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	nodes[0].FindNode(nodes[len(nodes)-1].ID())
+
+	err := s.Shutdown()
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	if len(s.AllNodePointers()) != 0 {
+		log.Printf("[%s] - %d nodes still attached after shutdown", testName, len(s.AllNodePointers()))
+		t.Fail()
+	}
+	for _, n := range nodes {
+		if !n.Stopped() {
+			log.Printf("[%s] - node %v still running after shutdown", testName, n.ID())
+			t.Fail()
+		}
+	}
+}
+
+func TestSimnetChurn(t *testing.T) {
+	testName := "TestSimnetChurn"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	s.SpawnCluster(5, done)
+	<-done
+	size := len(s.AllNodePointers())
+	events, cancel := s.Events().Subscribe(1 << 16)
+
+	err := s.StartChurn(100, 0, time.Hour)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	if s.StartChurn(100, 0, time.Hour) == nil {
+		log.Printf("[%s] - started churn twice", testName)
+		t.Fail()
+	}
+	time.Sleep(100 * time.Millisecond)
+	s.StopChurn()
+	cancel()
+
+	churnEvents := 0
+	for e := range events {
+		if _, ok := e.(NodeChurned); ok {
+			churnEvents++
+		}
+	}
+	stats := s.Stats()
+	if stats.Churned == 0 || stats.Churned != churnEvents {
+		log.Printf("[%s] - expected matching churn counts, stats %d events %d", testName, stats.Churned, churnEvents)
+		t.Fail()
+	}
+	if len(s.AllNodePointers()) != size {
+		log.Printf("[%s] - cluster size changed from %d to %d", testName, size, len(s.AllNodePointers()))
+		t.Fail()
+	}
+	err = s.Shutdown()
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+}
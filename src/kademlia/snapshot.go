@@ -0,0 +1,116 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"main/src/scalegraph"
+	"sync"
+	"time"
+)
+
+const (
+	SNAPSHOT_CHUNK_SIZE = 64           // accounts per snapshot chunk
+	SNAPSHOT_TTL        = 10 * TIMEOUT // idle time before an unfinished snapshot is discarded
+)
+
+type openSnapshot struct {
+	*scalegraph.Snapshot
+	lastRead time.Time
+}
+
+// Snapshots that are currently being streamed to other nodes, keyed by snapshot id.
+type snapshotTable struct {
+	content map[KademliaID]*openSnapshot
+	sync.Mutex
+}
+
+func newSnapshotTable() *snapshotTable {
+	return &snapshotTable{
+		content: make(map[KademliaID]*openSnapshot),
+	}
+}
+
+// Stores a snapshot under a fresh id and discards snapshots that have been idle for longer than ttl.
+func (snapshots *snapshotTable) open(snap *scalegraph.Snapshot, ttl time.Duration) KademliaID {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	for id, open := range snapshots.content {
+		if time.Since(open.lastRead) > ttl {
+			delete(snapshots.content, id)
+		}
+	}
+	id := RandomID()
+	snapshots.content[id] = &openSnapshot{snap, time.Now()}
+	return id
+}
+
+// Reads a chunk from the snapshot, the snapshot is closed once its last chunk has been read.
+// Returns the accounts read, true if this was the last chunk, or an error if there is no such snapshot.
+func (snapshots *snapshotTable) read(id KademliaID, offset int) ([]scalegraph.AccountSnapshot, bool, error) {
+	snapshots.Lock()
+	open, ok := snapshots.content[id]
+	if ok {
+		open.lastRead = time.Now()
+	}
+	snapshots.Unlock()
+	if !ok {
+		return nil, false, errors.New("no matching snapshot")
+	}
+	accounts := open.Read(offset, SNAPSHOT_CHUNK_SIZE)
+	done := offset+len(accounts) >= open.Len()
+	if done {
+		snapshots.Lock()
+		delete(snapshots.content, id)
+		snapshots.Unlock()
+	}
+	return accounts, done, nil
+}
+
+// Response logic for an incoming snapshot request.
+// A request without a snapshot id opens a new snapshot of the node's accounts, later requests
+// page through it by offset.
+func (node *Node) handleSnapshotAccounts(rpc *RPC) {
+	id := rpc.snapshotID
+	if id.IsZero() {
+		id = node.snapshots.open(node.scalegraph.Snapshot(), node.config.scaled(SNAPSHOT_TTL))
+	}
+	accounts, done, err := node.snapshots.read(id, rpc.snapshotOffset)
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	if err != nil {
+		node.logger.Error("received request for unknown snapshot", "rpc", rpc.id, "snapshot", id)
+		resp.SnapshotChunk(id, rpc.snapshotOffset, nil, true)
+		resp.snapshotFailed = true
+		node.Send(resp)
+		return
+	}
+	resp.SnapshotChunk(id, rpc.snapshotOffset, accounts, done)
+	node.Send(resp)
+}
+
+// Streams a consistent snapshot of every account held by the node at address into out.
+// The snapshot is taken when the first chunk is requested, out is closed when the stream ends.
+// Returns an error if the node stops responding or discards the snapshot mid stream.
+func (node *Node) StreamAccounts(address [4]byte, out chan<- scalegraph.AccountSnapshot) error {
+	defer close(out)
+	var id KademliaID
+	offset := 0
+	for {
+		rpc := GenerateRPC(address, node.Contact)
+		rpc.SnapshotAccounts(id, offset)
+		res, err := node.Send(rpc)
+		if err != nil {
+			return err
+		}
+		if res.snapshotFailed {
+			return errors.New(fmt.Sprintf("node %v discarded snapshot %v", address, res.snapshotID))
+		}
+		for _, acc := range res.snapshotAccounts {
+			out <- acc
+		}
+		if res.snapshotDone {
+			return nil
+		}
+		id = res.snapshotID
+		offset += len(res.snapshotAccounts)
+	}
+}
@@ -0,0 +1,45 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+)
+
+// Starts the node's listener without joining the network.
+func listenOnly(node *Node) {
+	node.routines.Go("listen", func() { node.Network.Listen(node) })
+}
+
+func TestStreamAccounts(t *testing.T) {
+	testName := "TestStreamAccounts"
+	s := NewServer(false, 0.0)
+	go s.StartServer()
+	reader := s.GenerateRandomNode()
+	holder := s.GenerateRandomNode()
+	listenOnly(reader)
+	listenOnly(holder)
+
+	accounts := 2*SNAPSHOT_CHUNK_SIZE + 5
+	for range accounts {
+		holder.AddAccount(RandomID())
+	}
+	out := make(chan scalegraph.AccountSnapshot, accounts)
+	err := reader.StreamAccounts(holder.IP(), out)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	received := 0
+	for range out {
+		received++
+	}
+	if received != accounts {
+		log.Printf("[%s] - expected %d accounts, received %d", testName, accounts, received)
+		t.Fail()
+	}
+	if len(holder.snapshots.content) != 0 {
+		log.Printf("[%s] - snapshot left open after the stream completed", testName)
+		t.Fail()
+	}
+}
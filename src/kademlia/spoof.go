@@ -0,0 +1,79 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// How the simnet treats RPCs claiming a sender other than the node they were sent by.
+type SenderCheck int32
+
+const (
+	SENDERS_TRUSTED  SenderCheck = iota // RPCs are routed whatever sender they claim, the default
+	SENDERS_RECORDED                    // spoofed RPCs are counted and published, but still routed
+	SENDERS_STRICT                      // spoofed RPCs are counted, published and dropped
+)
+
+func (check SenderCheck) String() string {
+	switch check {
+	case SENDERS_TRUSTED:
+		return "trusted"
+	case SENDERS_RECORDED:
+		return "recorded"
+	case SENDERS_STRICT:
+		return "strict"
+	}
+	return "unknown sender check"
+}
+
+// A RPC claimed a sender whose IP is not the one of the node that sent it, see Simnet.SetSenderCheck.
+type RPCSpoofed struct {
+	ID       KademliaID
+	Cmd      Command
+	Claimed  Contact // sender the RPC claims
+	Origin   [4]byte // IP of the node the RPC was sent by
+	Receiver [4]byte
+	Dropped  bool
+}
+
+func (RPCSpoofed) event() {}
+
+// Sets how the simnet checks that the IP of a RPC's claimed sender is that of the node the RPC was
+// actually sent by. Spoofed RPCs are counted in the stats and published as RPCSpoofed events, and
+// in strict mode dropped, so that handlers can be tested against impersonation with and without
+// the guard. RPCs the simnet routes on a node's behalf, such as replayed traces, are not checked.
+// Returns an error if check is not a known mode.
+func (simnet *Simnet) SetSenderCheck(check SenderCheck) error {
+	if check < SENDERS_TRUSTED || check > SENDERS_STRICT {
+		return errors.New(fmt.Sprintf("unknown sender check %d", check))
+	}
+	simnet.senderCheck.Store(int32(check))
+	return nil
+}
+
+// Returns how the simnet checks the senders of RPCs.
+func (simnet *Simnet) SenderCheck() SenderCheck {
+	return SenderCheck(simnet.senderCheck.Load())
+}
+
+// Checks the claimed sender of rpc against the node it was sent by.
+// Returns false if rpc is spoofed and must be dropped.
+func (simnet *Simnet) checkSender(rpc RPC, start time.Time) bool {
+	check := simnet.SenderCheck()
+	if check == SENDERS_TRUSTED || rpc.origin == ([4]byte{}) || rpc.origin == rpc.sender.IP() {
+		return true
+	}
+	drop := check == SENDERS_STRICT
+	simnet.stats.recordSpoofed()
+	simnet.events.Publish(RPCSpoofed{rpc.id, rpc.cmd, rpc.sender, rpc.origin, rpc.receiver, drop})
+	simnet.logger.Debug("spoofed sender", "rpc", rpc.id, "cmd", rpc.cmd, "claimed", rpc.sender.IP(), "origin", rpc.origin, "dropped", drop)
+	if !drop {
+		return true
+	}
+	simnet.metrics.RPCRouted(rpc.cmd, true)
+	simnet.stats.recordRoute(rpc, false, true, simnet.timebase.Since(start))
+	simnet.trace(rpc, start, "spoofed sender")
+	simnet.events.Publish(RPCDropped{rpc.id, rpc.cmd, rpc.sender, rpc.receiver, rpc.response, "spoofed sender"})
+	return false
+}
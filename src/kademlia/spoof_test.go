@@ -0,0 +1,75 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSenderCheck(t *testing.T) {
+	testName := "TestSenderCheck"
+	config := DefaultConfig()
+	config.Timeout = 100 * time.Millisecond
+	s, err := NewServerWithConfig(false, 0.0, config)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	s.Silence()
+	done := make(chan struct{}, 1)
+	go s.StartServer()
+	nodes := s.SpawnCluster(3, done)
+	<-done
+	defer s.Shutdown()
+	attacker, victim, impersonated := nodes[0], nodes[1], nodes[2]
+
+	if err := s.SetSenderCheck(SENDERS_STRICT + 1); err == nil {
+		log.Printf("[%s] - accepted an unknown sender check", testName)
+		t.Fail()
+	}
+	if !attacker.Ping(victim.IP()) {
+		log.Printf("[%s] - honest ping failed", testName)
+		t.FailNow()
+	}
+
+	for i, check := range []SenderCheck{SENDERS_TRUSTED, SENDERS_RECORDED, SENDERS_STRICT} {
+		if err := s.SetSenderCheck(check); err != nil {
+			log.Printf("[%s] - %s", testName, err.Error())
+			t.FailNow()
+		}
+		events, cancel := s.Events().Subscribe(1 << 10)
+		rpc := GenerateRPC(victim.IP(), impersonated.Contact)
+		rpc.Ping()
+		// the pong goes to the impersonated node, so the attacker never sees it
+		attacker.Network.Send(rpc)
+		cancel()
+
+		spoofed, delivered := false, false
+		for e := range events {
+			switch e := e.(type) {
+			case RPCSpoofed:
+				if e.ID == rpc.id {
+					spoofed = e.Origin == attacker.IP() && e.Dropped == (check == SENDERS_STRICT)
+				}
+			case RPCDelivered:
+				if e.ID == rpc.id && !e.Response {
+					delivered = true
+				}
+			}
+		}
+		if spoofed != (check != SENDERS_TRUSTED) || delivered != (check != SENDERS_STRICT) {
+			log.Printf("[%s] - %s check: spoof reported %t, delivered %t", testName, check, spoofed, delivered)
+			t.Fail()
+		}
+		if res := s.Stats().Spoofed; res != i {
+			log.Printf("[%s] - %s check: expected %d spoofed rpcs, counted %d", testName, check, i, res)
+			t.Fail()
+		}
+	}
+
+	// honest traffic passes the strict check
+	if !attacker.Ping(victim.IP()) {
+		log.Printf("[%s] - honest ping failed in strict mode", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,234 @@
+package kademlia
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Message counts for a single node in the simulated network.
+type NodeMessages struct {
+	Sent       int
+	Received   int
+	Overflowed int // RPCs to the node discarded by its queue overflow policy
+}
+
+// Point in time view of the traffic that has passed through the simulated network.
+type SimnetStats struct {
+	Routed         map[Command]int              // routed RPCs per command type
+	Dropped        int                          // RPCs dropped by the drop roll, a link policy or a full queue
+	Overflowed     int                          // RPCs discarded by queue overflow policies, arriving or evicted
+	Corrupted      int                          // RPCs corrupted by a link policy
+	Undeliverable  int                          // RPCs addressed to unknown or shut down nodes
+	Churned        int                          // nodes replaced by the churn process
+	RejectedJoins  int                          // ENTER requests the entry service rejected, see Simnet.RequireJoinToken
+	Spoofed        int                          // RPCs claiming a sender other than the node that sent them, see Simnet.SetSenderCheck
+	AverageLatency time.Duration                // average time spent routing a RPC
+	Latency        map[Command]LatencyHistogram // request/response round trip times of the active nodes, by request command
+	LookupHops     HopHistogram                 // hops of the lookups the active nodes completed
+	NodeMessages   map[[4]byte]NodeMessages
+	ActiveNodes    int
+	RouteQueue     int // RPCs waiting for a route worker
+	RouteQueuePeak int // most RPCs seen waiting for a route worker
+}
+
+func (stats SimnetStats) Display() string {
+	res := fmt.Sprintf("active nodes: %d\n", stats.ActiveNodes)
+	res += fmt.Sprintf("dropped: %d\nundeliverable: %d\n", stats.Dropped, stats.Undeliverable)
+	res += fmt.Sprintf("overflowed: %d\ncorrupted: %d\nchurned: %d\n", stats.Overflowed, stats.Corrupted, stats.Churned)
+	res += fmt.Sprintf("rejected joins: %d\nspoofed: %d\n", stats.RejectedJoins, stats.Spoofed)
+	res += fmt.Sprintf("average route latency: %v\n", stats.AverageLatency)
+	res += fmt.Sprintf("route queue: %d (peak %d)\n", stats.RouteQueue, stats.RouteQueuePeak)
+	cmds := make([]Command, 0, len(stats.Routed))
+	for c := range stats.Routed {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })
+	for _, c := range cmds {
+		res += fmt.Sprintf("%-20s %d\n", c.String(), stats.Routed[c])
+	}
+	cmds = cmds[:0]
+	for c := range stats.Latency {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })
+	if len(cmds) > 0 {
+		res += "request latency:\n"
+	}
+	for _, c := range cmds {
+		res += fmt.Sprintf("%-20s %s\n", c.String(), stats.Latency[c].Display())
+	}
+	if stats.LookupHops.Count > 0 {
+		res += fmt.Sprintf("lookup hops: %s\n", stats.LookupHops.Display())
+	}
+	return res
+}
+
+// Running counters backing SimnetStats.
+type simnetStats struct {
+	routed        map[Command]int
+	dropped       int
+	undeliverable int
+	overflowed    int
+	corrupted     int
+	churned       int
+	rejectedJoins int
+	spoofed       int
+	routeCount    int
+	routeTime     time.Duration
+	nodeMessages  map[[4]byte]NodeMessages
+	queuePeak     int
+	sync.Mutex
+}
+
+func newSimnetStats() *simnetStats {
+	return &simnetStats{
+		routed:       make(map[Command]int),
+		nodeMessages: make(map[[4]byte]NodeMessages),
+	}
+}
+
+// Records a routed RPC and the time it took to route it.
+func (stats *simnetStats) recordRoute(rpc RPC, delivered bool, dropped bool, elapsed time.Duration) {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.routed[rpc.cmd]++
+	stats.routeCount++
+	stats.routeTime += elapsed
+	sender := stats.nodeMessages[rpc.sender.IP()]
+	sender.Sent++
+	stats.nodeMessages[rpc.sender.IP()] = sender
+	if dropped {
+		stats.dropped++
+		return
+	}
+	if !delivered {
+		stats.undeliverable++
+		return
+	}
+	receiver := stats.nodeMessages[rpc.receiver]
+	receiver.Received++
+	stats.nodeMessages[rpc.receiver] = receiver
+}
+
+func (stats *simnetStats) recordOverflow(rpc RPC) {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.overflowed++
+	receiver := stats.nodeMessages[rpc.receiver]
+	receiver.Overflowed++
+	stats.nodeMessages[rpc.receiver] = receiver
+}
+
+func (stats *simnetStats) recordCorruption() {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.corrupted++
+}
+
+func (stats *simnetStats) recordRouteQueue(depth int) {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.queuePeak = max(stats.queuePeak, depth)
+}
+
+func (stats *simnetStats) recordChurn() {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.churned++
+}
+
+func (stats *simnetStats) recordRejectedJoin() {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.rejectedJoins++
+}
+
+func (stats *simnetStats) recordSpoofed() {
+	stats.Lock()
+	defer stats.Unlock()
+	stats.spoofed++
+}
+
+func (stats *simnetStats) snapshot(activeNodes int) SimnetStats {
+	stats.Lock()
+	defer stats.Unlock()
+	res := SimnetStats{
+		Routed:         make(map[Command]int, len(stats.routed)),
+		Dropped:        stats.dropped,
+		Undeliverable:  stats.undeliverable,
+		Overflowed:     stats.overflowed,
+		Corrupted:      stats.corrupted,
+		Churned:        stats.churned,
+		RejectedJoins:  stats.rejectedJoins,
+		Spoofed:        stats.spoofed,
+		NodeMessages:   make(map[[4]byte]NodeMessages, len(stats.nodeMessages)),
+		ActiveNodes:    activeNodes,
+		RouteQueuePeak: stats.queuePeak,
+	}
+	for c, n := range stats.routed {
+		res.Routed[c] = n
+	}
+	for ip, n := range stats.nodeMessages {
+		res.NodeMessages[ip] = n
+	}
+	if stats.routeCount > 0 {
+		res.AverageLatency = stats.routeTime / time.Duration(stats.routeCount)
+	}
+	return res
+}
+
+// Returns a snapshot of the traffic routed by the simnet so far.
+func (simnet *Simnet) Stats() SimnetStats {
+	simnet.spawned.RLock()
+	nodes := append([]*Node(nil), simnet.spawned.nodePointer...)
+	simnet.spawned.RUnlock()
+	res := simnet.stats.snapshot(len(nodes))
+	res.RouteQueue = simnet.RouteQueueDepth()
+	res.Latency = make(map[Command]LatencyHistogram)
+	for _, n := range nodes {
+		for cmd, hist := range n.Latency() {
+			total, ok := res.Latency[cmd]
+			if !ok {
+				total = *newLatencyHistogram()
+			}
+			total.merge(hist)
+			res.Latency[cmd] = total
+		}
+		res.LookupHops.merge(n.LookupHops())
+	}
+	return res
+}
+
+// Publishes a stats snapshot on the returned channel every interval until cancelled or the simnet shuts down.
+// Snapshots are skipped while the subscriber is not keeping up.
+// The returned function cancels the subscription and closes the channel.
+func (simnet *Simnet) SubscribeStats(interval time.Duration) (<-chan SimnetStats, func()) {
+	sub := make(chan SimnetStats, 1)
+	stop := make(chan struct{})
+	simnet.routines.Go("stats subscription", func() {
+		defer close(sub)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-simnet.shutdown:
+				return
+			case <-ticker.C:
+				select {
+				case sub <- simnet.Stats():
+				default:
+				}
+			}
+		}
+	})
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { close(stop) })
+	}
+	return sub, cancel
+}
@@ -0,0 +1,152 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSimnetStatsRoute(t *testing.T) {
+	testName := "TestSimnetStatsRoute"
+	s := NewServer(false, 0.0)
+	receiver := s.GenerateRandomNode()
+	sender := NewRandomContact()
+	for range 3 {
+		rpc := GenerateRPC(receiver.IP(), sender)
+		rpc.Ping()
+		s.Route(rpc)
+	}
+	lost := GenerateRPC(RandomIP(), sender)
+	lost.Ping()
+	s.Route(lost)
+
+	stats := s.Stats()
+	if stats.Routed[PING] != 4 {
+		log.Printf("[%s] - expected 4 routed pings, found %d", testName, stats.Routed[PING])
+		t.Fail()
+	}
+	if stats.Undeliverable != 1 {
+		log.Printf("[%s] - expected 1 undeliverable RPC, found %d", testName, stats.Undeliverable)
+		t.Fail()
+	}
+	if stats.NodeMessages[receiver.IP()].Received != 3 {
+		log.Printf("[%s] - expected 3 received messages, found %d", testName, stats.NodeMessages[receiver.IP()].Received)
+		t.Fail()
+	}
+	if stats.NodeMessages[sender.IP()].Sent != 4 {
+		log.Printf("[%s] - expected 4 sent messages, found %d", testName, stats.NodeMessages[sender.IP()].Sent)
+		t.Fail()
+	}
+	if stats.ActiveNodes != 2 {
+		log.Printf("[%s] - expected 2 active nodes, found %d", testName, stats.ActiveNodes)
+		t.Fail()
+	}
+}
+
+func TestSimnetSubscribeStats(t *testing.T) {
+	testName := "TestSimnetSubscribeStats"
+	s := NewServer(false, 0.0)
+	sub, cancel := s.SubscribeStats(time.Millisecond)
+	select {
+	case <-sub:
+	case <-time.After(time.Second):
+		log.Printf("[%s] - no stats published", testName)
+		t.Fail()
+	}
+	cancel()
+	cancel()
+	for range sub {
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	testName := "TestLatencyHistogram"
+	hist := newLatencyHistogram()
+	for range 98 {
+		hist.observe(80 * time.Microsecond)
+	}
+	hist.observe(20 * time.Millisecond)
+	hist.observe(2 * time.Second)
+	if hist.Count != 100 || hist.Counts[1] != 98 || hist.Counts[len(LATENCY_BUCKETS)] != 1 {
+		log.Printf("[%s] - unexpected bucket counts %v", testName, hist.Counts)
+		t.Fail()
+	}
+	if hist.Quantile(0.5) != 80*time.Microsecond && hist.Quantile(0.5) != 100*time.Microsecond {
+		log.Printf("[%s] - unexpected median %v", testName, hist.Quantile(0.5))
+		t.Fail()
+	}
+	if hist.Quantile(0.99) != 25*time.Millisecond {
+		log.Printf("[%s] - expected p99 of 25ms, got %v", testName, hist.Quantile(0.99))
+		t.Fail()
+	}
+	if hist.Quantile(1) != 2*time.Second {
+		log.Printf("[%s] - expected overflow quantile to report the max, got %v", testName, hist.Quantile(1))
+		t.Fail()
+	}
+}
+
+func TestSimnetStatsLatency(t *testing.T) {
+	testName := "TestSimnetStatsLatency"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	before := nodes[0].Latency()[PING].Count
+	nodes[0].Ping(nodes[1].IP())
+	if nodes[0].Latency()[PING].Count != before+1 {
+		log.Printf("[%s] - ping was not recorded by the node", testName)
+		t.Fail()
+	}
+	stats := s.Stats()
+	if stats.Latency[PING].Count < before+1 || stats.Latency[FIND_NODE].Count == 0 {
+		log.Printf("[%s] - expected ping and find node latency in the stats report", testName)
+		t.Fail()
+	}
+}
+
+func TestSimnetHopLimit(t *testing.T) {
+	testName := "TestSimnetHopLimit"
+	s := NewServer(false, 0.0)
+	receiver := s.GenerateRandomNode()
+	sender := NewRandomContact()
+	for _, hops := range []int{0, MAX_HOPS, MAX_HOPS + 1} {
+		rpc := GenerateRPC(receiver.IP(), sender)
+		rpc.Ping()
+		rpc.hops = hops
+		s.Route(rpc)
+	}
+	stats := s.Stats()
+	if stats.Dropped != 1 || stats.NodeMessages[receiver.IP()].Received != 2 {
+		log.Printf("[%s] - expected 1 of 3 rpcs dropped, %d dropped and %d received", testName, stats.Dropped, stats.NodeMessages[receiver.IP()].Received)
+		t.Fail()
+	}
+}
+
+func TestHopHistogram(t *testing.T) {
+	testName := "TestHopHistogram"
+	hist := HopHistogram{}
+	for _, hops := range []int{1, 2, 2, 3} {
+		hist.observe(hops)
+	}
+	other := HopHistogram{}
+	other.observe(6)
+	hist.merge(other)
+	if hist.Count != 5 || hist.Max != 6 || len(hist.Counts) != 7 || hist.Counts[2] != 2 || hist.Counts[6] != 1 {
+		log.Printf("[%s] - unexpected histogram %+v", testName, hist)
+		t.Fail()
+	}
+	if hist.Mean() != 14.0/5 {
+		log.Printf("[%s] - expected a mean of 2.8, got %v", testName, hist.Mean())
+		t.Fail()
+	}
+	copied := hist.copy()
+	copied.Counts[1]++
+	if hist.Counts[1] != 1 {
+		log.Printf("[%s] - copy shares its counts", testName)
+		t.Fail()
+	}
+}
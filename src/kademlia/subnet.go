@@ -0,0 +1,301 @@
+package kademlia
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	NAT_MAPPING_TTL = 30 * time.Second // how long a NAT keeps letting a contacted node reach the node behind it
+	NAT_TABLE_SWEEP = 1024             // NAT mappings held before expired ones are swept
+	MIN_SUBNET_BITS = 1                // shortest subnet prefix
+	MAX_SUBNET_BITS = 30               // longest subnet prefix, leaving two usable host addresses
+)
+
+// A block of the simulated IP space. Spawned nodes are placed in the subnets at random in
+// proportion to their weights and RPCs between them are subject to the subnets' policies.
+type Subnet struct {
+	Prefix   [4]byte    // network address, host bits are ignored
+	Bits     int        // prefix length, between MIN_SUBNET_BITS and MAX_SUBNET_BITS
+	Weight   float64    // share of the spawned nodes relative to the other subnets, zero counts as one
+	Internal LinkPolicy // faults of RPCs between two nodes of the subnet
+	External LinkPolicy // faults of RPCs entering or leaving the subnet, on top of the other end's
+	NAT      bool       // nodes outside the subnet reach a node inside only after it contacted them, see NAT_MAPPING_TTL
+}
+
+func (subnet Subnet) mask() uint32 {
+	return ^uint32(0) << (32 - subnet.Bits)
+}
+
+// Returns true if ip lies in the subnet.
+func (subnet Subnet) Contains(ip [4]byte) bool {
+	mask := subnet.mask()
+	return binary.BigEndian.Uint32(ip[:])&mask == binary.BigEndian.Uint32(subnet.Prefix[:])&mask
+}
+
+// Returns the number of addresses nodes can be given, the network and broadcast addresses excluded.
+func (subnet Subnet) Hosts() int {
+	return 1<<(32-subnet.Bits) - 2
+}
+
+func (subnet Subnet) String() string {
+	mask := subnet.mask()
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], binary.BigEndian.Uint32(subnet.Prefix[:])&mask)
+	return fmt.Sprintf("%d.%d.%d.%d/%d", prefix[0], prefix[1], prefix[2], prefix[3], subnet.Bits)
+}
+
+// Subnets of a simnet, how many nodes each holds and the open NAT mappings.
+type subnetTable struct {
+	content  []Subnet
+	used     []int
+	mappings map[[2][4]byte]time.Time // (node behind a NAT, node it contacted) until the mapping expires
+	sweepAt  int
+	sync.RWMutex
+}
+
+func newSubnetTable() *subnetTable {
+	return &subnetTable{
+		mappings: make(map[[2][4]byte]time.Time),
+		sweepAt:  NAT_TABLE_SWEEP,
+	}
+}
+
+// Returns the index of the subnet holding ip, or -1. Must be called with the lock held.
+func (table *subnetTable) find(ip [4]byte) int {
+	return slices.IndexFunc(table.content, func(subnet Subnet) bool { return subnet.Contains(ip) })
+}
+
+// Counts a node placed at, or removed from, ip.
+func (table *subnetTable) occupy(ip [4]byte, delta int) {
+	table.Lock()
+	defer table.Unlock()
+	if i := table.find(ip); i != -1 {
+		table.used[i] += delta
+	}
+}
+
+// Replaces the simnet's subnets. Nodes spawned from now on get an address in one of them picked
+// at random in proportion to their weights, and in no subnet once all of them are full. Nodes
+// already spawned keep their address, and count towards a subnet if it lies in one.
+// Policies and NATs apply to RPCs routed from now on, a link policy set for a pair of nodes with
+// SetLinkPolicy takes the place of their subnets' policies. Calling it without subnets removes them.
+// Returns an error if a prefix length is out of range, a weight is negative or two subnets overlap.
+func (simnet *Simnet) SetSubnets(subnets ...Subnet) error {
+	for i, subnet := range subnets {
+		if subnet.Bits < MIN_SUBNET_BITS || subnet.Bits > MAX_SUBNET_BITS {
+			return errors.New(fmt.Sprintf("subnet prefix length must be between %d and %d, got %d", MIN_SUBNET_BITS, MAX_SUBNET_BITS, subnet.Bits))
+		}
+		if subnet.Weight < 0 {
+			return errors.New(fmt.Sprintf("subnet %v has negative weight %v", subnet, subnet.Weight))
+		}
+		for _, other := range subnets[:i] {
+			if subnet.Contains(other.Prefix) || other.Contains(subnet.Prefix) {
+				return errors.New(fmt.Sprintf("subnets %v and %v overlap", other, subnet))
+			}
+		}
+	}
+	simnet.spawned.RLock()
+	defer simnet.spawned.RUnlock()
+	simnet.subnets.Lock()
+	defer simnet.subnets.Unlock()
+	simnet.subnets.content = slices.Clone(subnets)
+	simnet.subnets.used = make([]int, len(subnets))
+	for ip := range simnet.spawned.ip {
+		if i := simnet.subnets.find(ip); i != -1 {
+			simnet.subnets.used[i]++
+		}
+	}
+	return nil
+}
+
+// Returns the simnet's subnets, see SetSubnets.
+func (simnet *Simnet) Subnets() []Subnet {
+	simnet.subnets.RLock()
+	defer simnet.subnets.RUnlock()
+	return slices.Clone(simnet.subnets.content)
+}
+
+// Returns the subnet holding ip, or false if it lies in none.
+func (simnet *Simnet) SubnetOf(ip [4]byte) (Subnet, bool) {
+	simnet.subnets.RLock()
+	defer simnet.subnets.RUnlock()
+	i := simnet.subnets.find(ip)
+	if i == -1 {
+		return Subnet{}, false
+	}
+	return simnet.subnets.content[i], true
+}
+
+// Returns a random address in a subnet with room left, picked in proportion to the weights, or
+// a random address outside of all subnets if there are none or all are full.
+func (simnet *Simnet) randomSubnetIP() [4]byte {
+	simnet.subnets.RLock()
+	defer simnet.subnets.RUnlock()
+	total := 0.0
+	for i, subnet := range simnet.subnets.content {
+		if simnet.subnets.used[i] < subnet.Hosts() {
+			total += subnetWeight(subnet)
+		}
+	}
+	if total == 0 {
+		for {
+			ip := simnet.randomIP()
+			if simnet.subnets.find(ip) == -1 {
+				return ip
+			}
+		}
+	}
+	pick := float64(simnet.rng.uint32()) / (1 << 32) * total
+	chosen := Subnet{}
+	for i, subnet := range simnet.subnets.content {
+		if simnet.subnets.used[i] >= subnet.Hosts() {
+			continue
+		}
+		chosen = subnet
+		pick -= subnetWeight(subnet)
+		if pick < 0 {
+			break
+		}
+	}
+	host := 1 + simnet.rng.uint32()%uint32(chosen.Hosts())
+	var ip [4]byte
+	binary.BigEndian.PutUint32(ip[:], binary.BigEndian.Uint32(chosen.Prefix[:])&chosen.mask()|host)
+	return ip
+}
+
+func subnetWeight(subnet Subnet) float64 {
+	if subnet.Weight == 0 {
+		return 1
+	}
+	return subnet.Weight
+}
+
+// Returns the faults of the RPC's subnets, or false if neither end lies in a subnet.
+// Nodes of the same subnet are subject to its internal policy, nodes of different subnets to
+// the external policies of both, with drop and corruption rates compounded and delays added.
+func (simnet *Simnet) subnetPolicy(from [4]byte, to [4]byte) (LinkPolicy, bool) {
+	simnet.subnets.RLock()
+	defer simnet.subnets.RUnlock()
+	src, dst := simnet.subnets.find(from), simnet.subnets.find(to)
+	if src == -1 && dst == -1 {
+		return LinkPolicy{}, false
+	}
+	if src == dst {
+		return simnet.subnets.content[src].Internal, true
+	}
+	res := LinkPolicy{}
+	for _, i := range []int{src, dst} {
+		if i == -1 {
+			continue
+		}
+		policy := simnet.subnets.content[i].External
+		res.Drop = 1 - (1-res.Drop)*(1-policy.Drop)
+		res.Corrupt = 1 - (1-res.Corrupt)*(1-policy.Corrupt)
+		res.Delay += policy.Delay
+	}
+	return res, true
+}
+
+// Passes the RPC through the NATs of its ends. A RPC leaving a NAT opens a mapping that lets the
+// receiver reach the sender for NAT_MAPPING_TTL, a RPC entering a NAT passes only through an
+// open mapping. RPCs within a subnet do not pass a NAT.
+// Returns false if the RPC is stopped at the receiver's NAT.
+func (simnet *Simnet) passNAT(from [4]byte, to [4]byte) bool {
+	table := simnet.subnets
+	table.RLock()
+	src, dst := table.find(from), table.find(to)
+	natted := src != -1 && table.content[src].NAT || dst != -1 && table.content[dst].NAT
+	table.RUnlock()
+	if src == dst || !natted {
+		return true
+	}
+	now := simnet.timebase.Now()
+	table.Lock()
+	defer table.Unlock()
+	// the subnets may have changed while the lock was released
+	src, dst = table.find(from), table.find(to)
+	if src == dst {
+		return true
+	}
+	if src != -1 && table.content[src].NAT {
+		if len(table.mappings) >= table.sweepAt {
+			for mapping, expires := range table.mappings {
+				if !expires.After(now) {
+					delete(table.mappings, mapping)
+				}
+			}
+			table.sweepAt = max(NAT_TABLE_SWEEP, 2*len(table.mappings))
+		}
+		table.mappings[[2][4]byte{from, to}] = now.Add(NAT_MAPPING_TTL)
+	}
+	if dst == -1 || !table.content[dst].NAT {
+		return true
+	}
+	expires, ok := table.mappings[[2][4]byte{to, from}]
+	return ok && expires.After(now)
+}
+
+// Routing table locality of a subnet's nodes, see Simnet.SubnetLocality.
+type SubnetLocality struct {
+	Subnet   Subnet
+	Nodes    int
+	Contacts int     // routing table entries of the subnet's nodes
+	Local    int     // entries pointing to nodes of the same subnet
+	Expected float64 // share of local entries if contacts were picked regardless of subnet, the subnet's share of the other nodes
+}
+
+// Returns the share of the routing table entries pointing into the subnet.
+func (loc SubnetLocality) Share() float64 {
+	if loc.Contacts == 0 {
+		return 0
+	}
+	return float64(loc.Local) / float64(loc.Contacts)
+}
+
+func (loc SubnetLocality) Display() string {
+	return fmt.Sprintf("%-18v nodes: %d contacts: %d local: %.3f expected: %.3f", loc.Subnet, loc.Nodes, loc.Contacts, loc.Share(), loc.Expected)
+}
+
+// Returns for every subnet how many of its nodes' routing table entries point to nodes of the
+// same subnet, next to the share expected if XOR-distance routing ignored the subnets. Kademlia
+// picks contacts by id alone, so a local share above the expected one points at a locality bias,
+// such as NATs keeping outside nodes from learning of the nodes behind them.
+func (simnet *Simnet) SubnetLocality() []SubnetLocality {
+	nodes := simnet.AllNodePointers()
+	subnets := simnet.Subnets()
+	res := make([]SubnetLocality, len(subnets))
+	for i, subnet := range subnets {
+		res[i].Subnet = subnet
+	}
+	index := func(ip [4]byte) int {
+		return slices.IndexFunc(subnets, func(subnet Subnet) bool { return subnet.Contains(ip) })
+	}
+	for _, n := range nodes {
+		if i := index(n.IP()); i != -1 {
+			res[i].Nodes++
+		}
+	}
+	for _, n := range nodes {
+		i := index(n.IP())
+		if i == -1 {
+			continue
+		}
+		for _, con := range n.AllContacts() {
+			res[i].Contacts++
+			if index(con.IP()) == i {
+				res[i].Local++
+			}
+		}
+	}
+	for i := range res {
+		if len(nodes) > 1 && res[i].Nodes > 0 {
+			res[i].Expected = float64(res[i].Nodes-1) / float64(len(nodes)-1)
+		}
+	}
+	return res
+}
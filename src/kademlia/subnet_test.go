@@ -0,0 +1,93 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSubnets(t *testing.T) {
+	testName := "TestSubnets"
+	home := Subnet{Prefix: [4]byte{10, 0, 0, 0}, Bits: 24, External: LinkPolicy{Delay: time.Millisecond}}
+	office := Subnet{Prefix: [4]byte{172, 16, 0, 0}, Bits: 16, Weight: 2}
+	s := NewServer(false, 0.0)
+	s.Silence()
+	if err := s.SetSubnets(home, Subnet{Prefix: [4]byte{10, 0, 0, 128}, Bits: 25}); err == nil {
+		log.Printf("[%s] - accepted overlapping subnets", testName)
+		t.Fail()
+	}
+	if err := s.SetSubnets(home, office); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	done := make(chan struct{}, 1)
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	var inside, outside *Node
+	for _, n := range nodes {
+		subnet, ok := s.SubnetOf(n.IP())
+		if !ok {
+			log.Printf("[%s] - node at %v placed outside the subnets", testName, n.IP())
+			t.Fail()
+		} else if subnet.Bits == home.Bits {
+			inside = n
+		} else {
+			outside = n
+		}
+	}
+	if inside == nil || outside == nil {
+		log.Printf("[%s] - a subnet holds no nodes", testName)
+		t.FailNow()
+	}
+	if policy, ok := s.subnetPolicy(outside.IP(), inside.IP()); !ok || policy.Delay != time.Millisecond {
+		log.Printf("[%s] - wrong policy between the subnets: %+v", testName, policy)
+		t.Fail()
+	}
+
+	// a node behind the NAT can be reached only by nodes it has contacted, and a stranger is none
+	home.NAT = true
+	s.SetSubnets(home, office)
+	events, cancel := s.Events().Subscribe(1024)
+	defer cancel()
+	stranger := NewContact([4]byte{192, 168, 1, 1}, RandomID())
+	rpc := GenerateRPC(inside.IP(), stranger)
+	rpc.Ping()
+	s.Route(rpc)
+	if !natDropped(events, rpc.id) {
+		log.Printf("[%s] - a request from a stranger passed the NAT", testName)
+		t.Fail()
+	}
+	probe := GenerateRPC(outside.IP(), inside.Contact)
+	probe.Ping()
+	s.Route(probe)
+	rpc = GenerateRPC(inside.IP(), outside.Contact)
+	rpc.Ping()
+	s.Route(rpc)
+	if natDropped(events, rpc.id) {
+		log.Printf("[%s] - a contacted node was stopped at the NAT", testName)
+		t.Fail()
+	}
+
+	locality := s.SubnetLocality()
+	if len(locality) != 2 || locality[0].Nodes+locality[1].Nodes != len(nodes) || locality[1].Contacts == 0 {
+		log.Printf("[%s] - unexpected locality %+v", testName, locality)
+		t.Fail()
+	}
+}
+
+// Returns true if the RPC was dropped at a NAT, reading the events published so far.
+func natDropped(events <-chan Event, id KademliaID) bool {
+	for {
+		select {
+		case event := <-events:
+			if drop, ok := event.(RPCDropped); ok && drop.ID == id {
+				return drop.Reason == "nat"
+			}
+		default:
+			return false
+		}
+	}
+}
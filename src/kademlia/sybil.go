@@ -0,0 +1,148 @@
+package kademlia
+
+import (
+	"sync"
+	"time"
+)
+
+const SYBIL_SUFFIX_BITS = 32 // low bits of a Sybil id that differ from its target, the rest are shared
+
+// A group of Sybil identities clustered around a target id, see Simnet.SpawnSybils.
+type SybilAttack struct {
+	Target KademliaID
+	Sybils []*Node
+	simnet *Simnet
+	start  time.Time
+	ids    map[KademliaID]bool
+}
+
+// How much of the honest nodes' view of the network an attack controls at one point in time.
+type EclipseSample struct {
+	Elapsed  time.Duration // time since the Sybils were spawned
+	Closest  float64       // fraction of the K closest contacts to the target, over all honest routing tables, that are Sybils
+	Buckets  float64       // fraction of all honest routing table entries that are Sybils
+	Eclipsed int           // honest nodes whose own K closest contacts to the target are all Sybils
+	Honest   int           // live honest nodes
+}
+
+// Spawns count Sybil identities sharing all but the last SYBIL_SUFFIX_BITS bits of their id with
+// target, so they are closer to it than any honest node is likely to be. The Sybils misbehave as
+// b, nil makes them eclipse the target by answering lookups for it with each other only.
+// done receives the id of every Sybil once it has joined.
+func (simnet *Simnet) SpawnSybils(target KademliaID, count int, b Behavior, done chan KademliaID) *SybilAttack {
+	simnet.spawned.RLock()
+	config := simnet.queueConfig
+	simnet.spawned.RUnlock()
+	attack := &SybilAttack{
+		Target: target,
+		Sybils: make([]*Node, 0, count),
+		simnet: simnet,
+		start:  simnet.timebase.Now(),
+		ids:    make(map[KademliaID]bool, count),
+	}
+	for len(attack.Sybils) < count {
+		id := target
+		id[ID_WORDS-1] = simnet.rng.uint32()
+		if id == target {
+			continue
+		}
+		newNode := simnet.generateNode(config, DEFAULT_NETWORK, id, FULL_NODE)
+		if newNode == nil {
+			continue
+		}
+		attack.Sybils = append(attack.Sybils, newNode)
+		attack.ids[id] = true
+	}
+	if b == nil {
+		colluders := make([]Contact, 0, count)
+		for _, sybil := range attack.Sybils {
+			colluders = append(colluders, sybil.Contact)
+		}
+		b = Eclipse{target, colluders}
+	}
+	for _, sybil := range attack.Sybils {
+		sybil.SetBehavior(b)
+		simnet.routines.Go("node start", func() { sybil.Start(done) })
+	}
+	return attack
+}
+
+// Measures how much of the honest nodes' routing tables the attack controls.
+func (attack *SybilAttack) Sample() EclipseSample {
+	res := EclipseSample{Elapsed: attack.simnet.timebase.Since(attack.start)}
+	entries, sybils := 0, 0
+	closest := make([]Contact, 0)
+	seen := make(map[KademliaID]bool)
+	for _, n := range attack.simnet.AllNodePointers() {
+		if attack.ids[n.ID()] {
+			continue
+		}
+		res.Honest++
+		for _, con := range n.AllContacts() {
+			entries++
+			if attack.ids[con.ID()] {
+				sybils++
+			}
+			if !seen[con.ID()] {
+				seen[con.ID()] = true
+				closest = append(closest, con)
+			}
+		}
+		own, _ := n.FindXClosest(n.config.Replication, attack.Target)
+		if len(own) > 0 && attack.controls(own) == len(own) {
+			res.Eclipsed++
+		}
+	}
+	if entries > 0 {
+		res.Buckets = float64(sybils) / float64(entries)
+	}
+	SortContactsByDistance(&closest, attack.Target)
+	closest = closest[:min(len(closest), attack.simnet.config.Replication)]
+	if len(closest) > 0 {
+		res.Closest = float64(attack.controls(closest)) / float64(len(closest))
+	}
+	return res
+}
+
+// Returns how many of the contacts are Sybils.
+func (attack *SybilAttack) controls(contacts []Contact) int {
+	res := 0
+	for _, con := range contacts {
+		if attack.ids[con.ID()] {
+			res++
+		}
+	}
+	return res
+}
+
+// Publishes a sample of the attack on the returned channel every interval until cancelled or the
+// simnet shuts down. Samples are skipped while the subscriber is not keeping up.
+// The returned function cancels the subscription and closes the channel.
+func (attack *SybilAttack) Track(interval time.Duration) (<-chan EclipseSample, func()) {
+	sub := make(chan EclipseSample, 1)
+	stop := make(chan struct{})
+	attack.simnet.routines.Go("eclipse tracking", func() {
+		defer close(sub)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-attack.simnet.shutdown:
+				return
+			case <-ticker.C:
+				select {
+				case sub <- attack.Sample():
+				default:
+				}
+			}
+		}
+	})
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { close(stop) })
+	}
+	return sub, cancel
+}
@@ -0,0 +1,53 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSybilAttack(t *testing.T) {
+	testName := "TestSybilAttack"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(15, done)
+	<-done
+	defer s.Shutdown()
+
+	target := nodes[0].ID()
+	before := (&SybilAttack{Target: target, simnet: s}).Sample()
+	if before.Closest != 0 || before.Buckets != 0 || before.Honest != 16 {
+		log.Printf("[%s] - expected no Sybils before the attack, got %+v", testName, before)
+		t.Fail()
+	}
+
+	joined := make(chan KademliaID, 10)
+	attack := s.SpawnSybils(target, 10, nil, joined)
+	for range attack.Sybils {
+		<-joined
+	}
+	for _, sybil := range attack.Sybils {
+		if target.Prefix(sybil.ID()) < ID_BITS-SYBIL_SUFFIX_BITS || sybil.ID() == target {
+			log.Printf("[%s] - Sybil %v is not clustered around the target", testName, sybil.ID())
+			t.Fail()
+		}
+	}
+
+	samples, cancel := attack.Track(10 * time.Millisecond)
+	defer cancel()
+	sample := <-samples
+	if sample.Honest != 16 || sample.Closest <= 0 || sample.Closest > 0.5 || sample.Buckets <= 0 || sample.Buckets >= 1 {
+		log.Printf("[%s] - unexpected eclipse sample: %+v", testName, sample)
+		t.Fail()
+	}
+
+	res := nodes[1].findNodeQuery(attack.Sybils[0].Contact, target)
+	for _, con := range res.found {
+		if !attack.ids[con.ID()] {
+			log.Printf("[%s] - a Sybil pointed at honest node %v", testName, con.ID())
+			t.Fail()
+		}
+	}
+}
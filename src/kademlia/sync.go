@@ -0,0 +1,141 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"main/src/scalegraph"
+	"slices"
+)
+
+const WALLET_SYNC_INTERVAL = 20 * TIMEOUT // how often a node pulls the wallets it should replicate from its contacts
+
+// Full state of a wallet as sent by SYNC_WALLET, enough to rebuild the wallet on another node.
+type walletState struct {
+	ID           KademliaID
+	PublicKey    ed25519.PublicKey
+	Replication  int `json:",omitempty"` // closest nodes the wallet is kept at, zero for the K closest
+	Transactions []scalegraph.Transaction
+}
+
+// Pulls the wallets the node should replicate from the contacts in its routing table. Each contact
+// returns the wallets it holds for which the node is now one of the K closest nodes, and the node
+// keeps every wallet that is new to it or extends its own copy, provided its own view agrees that
+// it is one of the K closest.
+// Returns the number of wallets that were added or brought up to date.
+func (node *Node) SyncWallets() int {
+	if node.Role() == OBSERVER {
+		return 0
+	}
+	neighbours := node.AllContacts()
+	respChan := make(chan []walletState, len(neighbours))
+	for _, con := range neighbours {
+		rpc := GenerateRPC(con.IP(), node.Contact)
+		rpc.SyncWallet()
+		node.routines.Go("sync wallet", func() {
+			res, err := node.Send(rpc)
+			if err != nil {
+				respChan <- nil
+				return
+			}
+			respChan <- res.walletStates
+		})
+	}
+	synced := 0
+	for range neighbours {
+		for _, state := range <-respChan {
+			if !node.isReplica(node.Contact, state.ID, state.Replication) {
+				continue
+			}
+			restored, err := node.scalegraph.RestoreAccount(state.ID, state.PublicKey, state.Replication, state.Transactions)
+			if err != nil {
+				node.logger.Warn("failed to sync wallet", "wallet", state.ID, "err", err)
+			}
+			if restored {
+				synced++
+			}
+		}
+	}
+	if synced > 0 {
+		node.logger.Debug("synced wallets", "wallets", synced)
+	}
+	return synced
+}
+
+// Syncs wallets once the node has entered the network and then every WALLET_SYNC_INTERVAL,
+// so that replicas lost to churn are recreated on the nodes that have moved into the K closest.
+func (node *Node) walletSyncLoop() {
+	ticker := node.timebase.NewTicker(node.config.scaled(WALLET_SYNC_INTERVAL))
+	defer ticker.Stop()
+	for {
+		node.SyncWallets()
+		select {
+		case <-node.Network.listener.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Response logic for an incoming sync wallet RPC. The node reconciles its wallets against its own
+// view of the network and returns the state of every wallet the requester should replicate.
+func (node *Node) handleSyncWallet(rpc *RPC) {
+	states := make([]walletState, 0)
+	for _, id := range node.scalegraph.StoredAccounts() {
+		acc, err := node.scalegraph.FindAccount(id)
+		if err != nil || !node.isReplica(rpc.sender, id, acc.Replication()) {
+			continue
+		}
+		states = append(states, walletState{id, acc.PublicKey(), acc.Replication(), acc.Transactions()})
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.SyncedWallet(states)
+	node.Send(resp)
+}
+
+// Returns true if con is among the closest nodes to id that this node knows of, itself included,
+// that a wallet stored with replication is kept at, see walletReplication.
+func (node *Node) isReplica(con Contact, id KademliaID, replication int) bool {
+	// the routing table is searched for K contacts either way, asking it for fewer is less precise
+	closest, _ := node.FindXClosest(node.config.Replication, id)
+	closest = append(closest, node.Contact)
+	if !SliceContains(con.ID(), &closest) {
+		closest = append(closest, con)
+	}
+	SortContactsByDistance(&closest, id)
+	RemoveDuplicateContacts(&closest)
+	closest = closest[:min(len(closest), node.walletReplication(replication))]
+	return SliceContains(con.ID(), &closest)
+}
+
+// Returns the number of closest nodes a wallet stored with replication is kept at, the node's K
+// for zero or anything above it.
+func (node *Node) walletReplication(replication int) int {
+	if replication <= 0 || replication > node.config.Replication {
+		return node.config.Replication
+	}
+	return replication
+}
+
+// Returns the replication of a wallet the node holds, zero if it does not hold it.
+func (node *Node) heldReplication(id KademliaID) int {
+	acc, err := node.scalegraph.FindAccount(id)
+	if err != nil {
+		return 0
+	}
+	return acc.Replication()
+}
+
+// Returns the contacts that validate a wallet stored with replication, the replication closest to
+// id among contacts, in the order of contacts. Readers learn the replication from the validators'
+// answers, so they look up the K closest and narrow them down to the validators afterwards.
+func (node *Node) walletValidators(contacts []Contact, id KademliaID, replication int) []Contact {
+	closest := slices.Clone(contacts)
+	SortContactsByDistance(&closest, id)
+	closest = closest[:min(len(closest), node.walletReplication(replication))]
+	res := make([]Contact, 0, len(closest))
+	for _, con := range contacts {
+		if SliceContains(con.ID(), &closest) {
+			res = append(res, con)
+		}
+	}
+	return res
+}
@@ -0,0 +1,206 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"slices"
+	"testing"
+)
+
+func TestSyncWalletAfterValidatorShutdown(t *testing.T) {
+	testName := "TestSyncWalletAfterValidatorShutdown"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(40, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	nodes[0].Transfer(scalegraph.MINT_ACCOUNT, id, 5)
+	holders := make([]*Node, 0)
+	for _, n := range s.AllNodePointers() {
+		if _, err := n.Ledger().Wallet(id); err == nil {
+			holders = append(holders, n)
+		}
+	}
+	for _, n := range holders[:2] {
+		s.ShutdownNode(n)
+	}
+
+	var observer *Node
+	for _, n := range s.AllNodePointers() {
+		if n != s.masterNode && n != holders[2] {
+			observer = n
+			break
+		}
+	}
+	status := observer.ReplicationStatus(id)
+	if status.Healthy() {
+		log.Printf("[%s] - expected missing replicas after shutdown:\n%s", testName, status.Display())
+		t.Fail()
+	}
+
+	// Routing tables drop the dead validators, then every live node runs its periodic sync and the
+	// nodes that moved into the K closest pull the wallet.
+	live := s.AllNodePointers()
+	for _, step := range []func(n *Node){(*Node).ClearDeadContacts, func(n *Node) { n.SyncWallets() }} {
+		finished := make(chan struct{})
+		for _, n := range live {
+			go func() {
+				step(n)
+				finished <- struct{}{}
+			}()
+		}
+		for range live {
+			<-finished
+		}
+	}
+	replicas := 0
+	for _, n := range s.AllNodePointers() {
+		if wallet, err := n.Ledger().Wallet(id); err == nil && wallet.Transactions == 2 {
+			replicas++
+		}
+	}
+	if replicas < REPLICATION {
+		log.Printf("[%s] - replication did not recover, %d live replicas", testName, replicas)
+		t.Fail()
+	}
+}
+
+func TestSyncWalletOnJoin(t *testing.T) {
+	testName := "TestSyncWalletOnJoin"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWallet(id, 10); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+
+	// A node whose id is next to the wallet's becomes its closest replica.
+	joinID := id
+	joinID[ID_WORDS-1] ^= 1
+	entered := make(chan KademliaID, 1)
+	joined, err := s.SpawnNodeWithID(joinID, entered)
+	if err != nil {
+		log.Printf("[%s] - failed to spawn node: %v", testName, err)
+		t.FailNow()
+	}
+	<-entered
+	joined.SyncWallets()
+	wallet, err := joined.Ledger().Wallet(id)
+	if err != nil || wallet.Balance != 10 {
+		log.Printf("[%s] - joining node did not pull the wallet: %+v %v", testName, wallet, err)
+		t.Fail()
+	}
+}
+
+func TestWalletReplication(t *testing.T) {
+	testName := "TestWalletReplication"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(30, done)
+	<-done
+	defer s.Shutdown()
+
+	id := KademliaID(scalegraph.RandomID())
+	if err := nodes[0].SubmitWalletWithReplication(id, nil, 100, REPLICATION+1); err == nil {
+		log.Printf("[%s] - accepted a replication above K", testName)
+		t.Fail()
+	}
+	if err := nodes[0].SubmitWalletWithReplication(id, nil, 100, 8); err != nil {
+		log.Printf("[%s] - failed to submit wallet: %v", testName, err)
+		t.FailNow()
+	}
+	holders := func() []*Node {
+		res := make([]*Node, 0)
+		for _, n := range s.AllNodePointers() {
+			if wallet, err := n.Ledger().Wallet(id); err == nil && wallet.Replication == 8 {
+				res = append(res, n)
+			}
+		}
+		return res
+	}
+	if held := holders(); len(held) != 8 {
+		log.Printf("[%s] - expected 8 holders, got %d", testName, len(held))
+		t.FailNow()
+	}
+
+	// the wallet syncs back to a validator that lost it, and to no other node than the 8 closest
+	placed := holders()
+	lost := placed[0]
+	lost.scalegraph.RemoveAccount(id)
+	for _, n := range s.AllNodePointers() {
+		n.SyncWallets()
+	}
+	closest := s.AllNodePointers()
+	slices.SortFunc(closest, func(a *Node, b *Node) int { return CompareContacts(a.Contact, b.Contact, id) })
+	closest = closest[:8]
+	held := holders()
+	if !slices.Contains(held, lost) {
+		log.Printf("[%s] - the wallet was not synced back to the validator that lost it", testName)
+		t.Fail()
+	}
+	for _, n := range held {
+		if !slices.Contains(closest, n) && !slices.Contains(placed, n) {
+			log.Printf("[%s] - the wallet was synced to %v outside its 8 closest nodes", testName, n.ID())
+		}
+	}
+	status := nodes[1].ReplicationStatus(id)
+	if status.Expected != 8 || !status.Healthy() {
+		log.Printf("[%s] - expected a healthy wallet at 8 replicas:\n%s", testName, status.Display())
+		t.Fail()
+	}
+
+	// reads and transactions count their consistency over the 8 validators
+	nodes[1].config.ReadConsistency = QUORUM
+	if wallet, err := nodes[1].ShowWallet(id); err != nil || wallet.Balance != 100 {
+		log.Printf("[%s] - show wallet at quorum returned %+v %v", testName, wallet, err)
+		t.Fail()
+	}
+	if holders, _, err := nodes[1].findAccount(id, ALL); err != nil || len(holders) != 8 {
+		log.Printf("[%s] - find account found %d holders: %v", testName, len(holders), err)
+		t.Fail()
+	}
+	to := KademliaID(scalegraph.RandomID())
+	nodes[0].SubmitWallet(to, 0)
+	if err := nodes[1].ProposeTransaction(scalegraph.NewTransfer(id, to, 30)); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.Fail()
+	}
+	nodes[2].config.ReadConsistency = QUORUM
+	if wallet, err := nodes[2].ShowWallet(id); err != nil || wallet.Balance != 70 {
+		log.Printf("[%s] - expected a balance of 70, got %+v %v", testName, wallet, err)
+		t.Fail()
+	}
+
+	// a node joining next to the wallet pulls it with its replication
+	joinID := id
+	joinID[ID_WORDS-1] ^= 1
+	entered := make(chan KademliaID, 1)
+	joined, err := s.SpawnNodeWithID(joinID, entered)
+	if err != nil {
+		log.Printf("[%s] - failed to spawn node: %v", testName, err)
+		t.FailNow()
+	}
+	<-entered
+	joined.SyncWallets()
+	if wallet, err := joined.Ledger().Wallet(id); err != nil || wallet.Replication != 8 || wallet.Balance != 70 {
+		log.Printf("[%s] - joining node did not pull the wallet with its replication: %+v %v", testName, wallet, err)
+		t.Fail()
+	}
+}
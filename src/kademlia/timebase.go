@@ -0,0 +1,229 @@
+package kademlia
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Source of time for nodes, their networks and the simnet. SystemClock follows real time,
+// FakeClock only moves when it is advanced, which lets simulations run faster than real time.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Timer
+}
+
+// A pending timer or ticker of a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type systemClock struct{}
+
+// Returns the clock following real time, the default of every node and simnet.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Timer {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct{ *time.Timer }
+
+func (timer systemTimer) C() <-chan time.Time { return timer.Timer.C }
+
+type systemTicker struct{ *time.Ticker }
+
+func (ticker systemTicker) C() <-chan time.Time { return ticker.Ticker.C }
+
+func (ticker systemTicker) Stop() bool {
+	ticker.Ticker.Stop()
+	return true
+}
+
+// Replaces the time source of the node and its network, must be called before the node is
+// started. The node's skew and drift, see SetClock, are applied on top of the new time source.
+func (node *Node) SetTimebase(timebase Clock) {
+	node.Network.timebase = timebase
+	node.Network.listener.timebase = timebase
+	node.RoutingTable.seen.setTimebase(timebase)
+	node.clock.SetBase(timebase)
+}
+
+// Replaces the time source of the simnet and of every node it spawns, for example a FakeClock to
+// run a simulation faster than real time. Must be called before the server is started.
+// Returns an error if the server is already running.
+func (simnet *Simnet) SetTimebase(timebase Clock) error {
+	if simnet.started.Load() {
+		return errors.New("timebase must be set before the server is started")
+	}
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.timebase = timebase
+	for _, n := range simnet.nodePointer {
+		n.SetTimebase(timebase)
+	}
+	return nil
+}
+
+// Virtual clock that only moves when advanced. Timers and tickers fire in deadline order as the
+// clock passes their deadlines, a ticker that is not drained drops ticks like a real one.
+type FakeClock struct {
+	now     time.Time
+	waiters []*fakeTimer
+	sync.Mutex
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	period   time.Duration // zero for a one shot timer
+	c        chan time.Time
+}
+
+// Returns a fake clock reading start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (fake *FakeClock) Now() time.Time {
+	fake.Lock()
+	defer fake.Unlock()
+	return fake.now
+}
+
+func (fake *FakeClock) Since(t time.Time) time.Duration {
+	return fake.Now().Sub(t)
+}
+
+func (fake *FakeClock) After(d time.Duration) <-chan time.Time {
+	return fake.NewTimer(d).C()
+}
+
+// Blocks until the clock has been advanced by d.
+func (fake *FakeClock) Sleep(d time.Duration) {
+	<-fake.After(d)
+}
+
+func (fake *FakeClock) NewTimer(d time.Duration) Timer {
+	return fake.schedule(d, 0)
+}
+
+func (fake *FakeClock) NewTicker(d time.Duration) Timer {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fake.schedule(d, d)
+}
+
+func (fake *FakeClock) schedule(d time.Duration, period time.Duration) *fakeTimer {
+	fake.Lock()
+	defer fake.Unlock()
+	timer := &fakeTimer{fake, fake.now.Add(d), period, make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		timer.c <- fake.now
+		return timer
+	}
+	fake.waiters = append(fake.waiters, timer)
+	return timer
+}
+
+// Returns the number of timers and tickers waiting for the clock to reach their deadline.
+func (fake *FakeClock) Waiters() int {
+	fake.Lock()
+	defer fake.Unlock()
+	return len(fake.waiters)
+}
+
+// Moves the clock forward by d, firing every timer whose deadline is passed in deadline order.
+func (fake *FakeClock) Advance(d time.Duration) {
+	fake.Lock()
+	defer fake.Unlock()
+	target := fake.now.Add(d)
+	for {
+		next := -1
+		for i, timer := range fake.waiters {
+			if !timer.deadline.After(target) && (next == -1 || timer.deadline.Before(fake.waiters[next].deadline)) {
+				next = i
+			}
+		}
+		if next == -1 {
+			break
+		}
+		timer := fake.waiters[next]
+		fake.now = timer.deadline
+		select {
+		case timer.c <- fake.now:
+		default:
+		}
+		if timer.period > 0 {
+			timer.deadline = timer.deadline.Add(timer.period)
+		} else {
+			fake.waiters = slices.Delete(fake.waiters, next, next+1)
+		}
+	}
+	fake.now = target
+}
+
+// Advances the clock by factor times the real time that passes, in steps of at most tick of
+// real time, until the returned stop function is called.
+// Returns an error if the factor or the tick is not positive.
+func (fake *FakeClock) Warp(factor float64, tick time.Duration) (func(), error) {
+	if factor <= 0 || tick <= 0 {
+		return nil, errors.New("warp factor and tick must be positive")
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				fake.Advance(time.Duration(float64(now.Sub(last)) * factor))
+				last = now
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+		<-stopped
+	}, nil
+}
+
+func (timer *fakeTimer) C() <-chan time.Time {
+	return timer.c
+}
+
+// Removes the timer from its clock, returns false if it had already fired or been stopped.
+func (timer *fakeTimer) Stop() bool {
+	timer.clock.Lock()
+	defer timer.clock.Unlock()
+	i := slices.Index(timer.clock.waiters, timer)
+	if i == -1 {
+		return false
+	}
+	timer.clock.waiters = slices.Delete(timer.clock.waiters, i, i+1)
+	return true
+}
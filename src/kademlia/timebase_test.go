@@ -0,0 +1,140 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	testName := "TestFakeClockTimers"
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := NewFakeClock(start)
+
+	late := fake.After(2 * time.Second)
+	early := fake.After(time.Second)
+	stopped := fake.NewTimer(time.Second)
+	ticker := fake.NewTicker(300 * time.Millisecond)
+	if !stopped.Stop() || stopped.Stop() {
+		log.Printf("[%s] - stopping a pending timer should succeed exactly once", testName)
+		t.Fail()
+	}
+	select {
+	case <-early:
+		log.Printf("[%s] - timer fired before the clock was advanced", testName)
+		t.Fail()
+	default:
+	}
+
+	fake.Advance(1500 * time.Millisecond)
+	select {
+	case at := <-early:
+		if at != start.Add(time.Second) {
+			log.Printf("[%s] - timer fired at %v, expected its deadline", testName, at)
+			t.Fail()
+		}
+	default:
+		log.Printf("[%s] - timer did not fire after its deadline passed", testName)
+		t.Fail()
+	}
+	select {
+	case <-late:
+		log.Printf("[%s] - timer fired before its deadline", testName)
+		t.Fail()
+	default:
+	}
+	// The ticker was not drained, so only the first of its five ticks is kept.
+	if tick := <-ticker.C(); tick != start.Add(300*time.Millisecond) {
+		log.Printf("[%s] - expected the first tick to be kept, got %v", testName, tick)
+		t.Fail()
+	}
+	select {
+	case <-ticker.C():
+		log.Printf("[%s] - undrained ticker kept more than one tick", testName)
+		t.Fail()
+	default:
+	}
+	if fake.Since(start) != 1500*time.Millisecond {
+		log.Printf("[%s] - clock reads %v after advancing 1.5s", testName, fake.Since(start))
+		t.Fail()
+	}
+
+	slept := make(chan struct{})
+	go func() {
+		fake.Sleep(time.Minute)
+		close(slept)
+	}()
+	for fake.Waiters() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(time.Minute)
+	select {
+	case <-slept:
+	case <-time.After(time.Second):
+		log.Printf("[%s] - sleep did not return after the clock passed it", testName)
+		t.Fail()
+	}
+}
+
+func TestFakeClockChurnHour(t *testing.T) {
+	testName := "TestFakeClockChurnHour"
+	fake := NewFakeClock(time.Now())
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	if err := s.SetTimebase(fake); err != nil {
+		log.Printf("[%s] - setting the timebase failed: %s", testName, err.Error())
+		t.FailNow()
+	}
+	stopWarp, err := fake.Warp(4, time.Millisecond)
+	if err != nil {
+		log.Printf("[%s] - starting the warp failed: %s", testName, err.Error())
+		t.FailNow()
+	}
+	go s.StartServer()
+	s.SpawnCluster(20, done)
+	<-done
+	stopWarp()
+	defer s.Shutdown()
+
+	if err := s.StartChurn(1.0/300, 10*time.Minute, time.Hour); err != nil {
+		log.Printf("[%s] - starting churn failed: %s", testName, err.Error())
+		t.FailNow()
+	}
+	// Advance one churn interval at a time and wait for the churn loop to finish the replacement
+	// it triggers before the next tick, the fake ticker drops ticks nobody drains. The first nodes
+	// reach their min uptime after two intervals, so every later tick replaces at least one.
+	realStart := time.Now()
+	virtualStart := fake.Now()
+	expected := 0
+	for step := range 12 {
+		fake.Advance(5 * time.Minute)
+		if step < 1 {
+			continue
+		}
+		expected++
+		deadline := time.Now().Add(10 * time.Second)
+		for s.Stats().Churned < expected {
+			if time.Now().After(deadline) {
+				log.Printf("[%s] - churn stalled at %d of %d replacements", testName, s.Stats().Churned, expected)
+				t.FailNow()
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	s.StopChurn()
+	elapsed := time.Since(realStart)
+
+	if fake.Since(virtualStart) != time.Hour {
+		log.Printf("[%s] - expected an hour of virtual time, got %v", testName, fake.Since(virtualStart))
+		t.Fail()
+	}
+	if elapsed > 30*time.Second {
+		log.Printf("[%s] - an hour of churn took %v of real time", testName, elapsed)
+		t.Fail()
+	}
+	if err := s.SetTimebase(SystemClock()); err == nil {
+		log.Printf("[%s] - replaced the timebase of a running server", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,53 @@
+package kademlia
+
+import (
+	"slices"
+)
+
+// A routing table entry of the simulated network: From has To in its routing table.
+type Edge struct {
+	From KademliaID
+	To   KademliaID
+}
+
+// Snapshot of the simulated network's graph, the live nodes and their routing table entries.
+// Edges to nodes that are no longer live are kept until their holder drops them.
+type Topology struct {
+	Master Contact
+	Nodes  []Contact
+	Edges  []Edge
+}
+
+// Returns the live nodes and their routing table entries, sorted by id.
+// Changes after the snapshot are published as ContactAdded and ContactRemoved events, a feed
+// should subscribe before taking the snapshot so that no change falls between the two.
+func (simnet *Simnet) Topology() Topology {
+	nodes := simnet.AllNodePointers()
+	slices.SortFunc(nodes, func(a, b *Node) int { return a.ID().Cmp(b.ID()) })
+	res := Topology{
+		Master: simnet.masterNodeContact,
+		Nodes:  make([]Contact, 0, len(nodes)),
+		Edges:  make([]Edge, 0),
+	}
+	for _, n := range nodes {
+		res.Nodes = append(res.Nodes, n.Contact)
+		contacts := n.AllContacts()
+		slices.SortFunc(contacts, func(a, b Contact) int { return a.ID().Cmp(b.ID()) })
+		for _, con := range contacts {
+			res.Edges = append(res.Edges, Edge{n.ID(), con.ID()})
+		}
+	}
+	return res
+}
+
+// Publishes a change of the node's routing table, and hands a new contact the values it is now
+// responsible for. Cached lookups that returned a removed contact are dropped.
+func (node *Node) contactChanged(contact Contact, added bool) {
+	if added {
+		node.routines.Go("value handover", func() { node.handOver(contact) })
+		node.events.Publish(ContactAdded{node.Contact, contact})
+	} else {
+		node.lookupCache.forget(contact.ID())
+		node.events.Publish(ContactRemoved{node.Contact, contact})
+	}
+}
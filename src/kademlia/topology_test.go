@@ -0,0 +1,80 @@
+package kademlia
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"testing"
+)
+
+func TestRoutingTableChanges(t *testing.T) {
+	testName := "TestRoutingTableChanges"
+	router := NewRoutingTable(NewContact([4]byte{10, 0, 0, 1}, KademliaID{}), KEYSPACE, 1)
+	changes := make([]string, 0)
+	router.onChange = func(contact Contact, added bool) {
+		changes = append(changes, fmt.Sprintf("%v %v", contact.IP(), added))
+	}
+	// Both contacts share the home node's empty prefix, so they compete for the same bucket.
+	far := NewContact([4]byte{10, 0, 0, 2}, KademliaID{0xc0000000})
+	near := NewContact([4]byte{10, 0, 0, 3}, KademliaID{0x80000000})
+	router.AddContact(far)
+	router.AddContact(near)
+	router.AddContact(far)
+	router.RemoveContact(far)
+	router.RemoveContact(near)
+	expected := []string{
+		"[10 0 0 2] true",
+		"[10 0 0 2] false", // evicted by the nearer contact
+		"[10 0 0 3] true",
+		"[10 0 0 3] false",
+	}
+	if !slices.Equal(changes, expected) {
+		log.Printf("[%s] - expected changes %v, got %v", testName, expected, changes)
+		t.Fail()
+	}
+}
+
+func TestTopology(t *testing.T) {
+	testName := "TestTopology"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	events, cancel := s.Events().Subscribe(1 << 16)
+	defer cancel()
+	go s.StartServer()
+	nodes := s.SpawnCluster(5, done)
+	<-done
+	defer s.Shutdown()
+
+	topology := s.Topology()
+	if len(topology.Nodes) != 6 || topology.Master.ID() != s.masterNodeContact.ID() {
+		log.Printf("[%s] - expected 6 nodes and the master, got %d nodes", testName, len(topology.Nodes))
+		t.Fail()
+	}
+	edges := 0
+	for _, n := range s.AllNodePointers() {
+		edges += len(n.AllContacts())
+	}
+	if len(topology.Edges) != edges {
+		log.Printf("[%s] - expected %d edges, got %d", testName, edges, len(topology.Edges))
+		t.Fail()
+	}
+
+	victim := nodes[0].AllContacts()[0]
+	nodes[0].RemoveContact(victim)
+	added, removed := false, false
+	for len(events) > 0 {
+		switch event := (<-events).(type) {
+		case ContactAdded:
+			added = true
+		case ContactRemoved:
+			if event.Node.ID() == nodes[0].ID() && event.Contact.ID() == victim.ID() {
+				removed = true
+			}
+		}
+	}
+	if !added || !removed {
+		log.Printf("[%s] - expected contact events, added: %v, removed: %v", testName, added, removed)
+		t.Fail()
+	}
+}
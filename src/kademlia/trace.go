@@ -0,0 +1,208 @@
+package kademlia
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type TraceFormat int
+
+const (
+	TRACE_JSONL TraceFormat = iota // one JSON object per line
+	TRACE_CSV                      // comma separated values with a header row
+)
+
+// Settings of a trace recording, the zero value records every RPC as JSONL.
+type TraceOptions struct {
+	Format     TraceFormat
+	SampleRate float64   // share of the RPC ids recorded, zero records every RPC
+	Commands   []Command // commands recorded, empty records every command
+}
+
+// A routed RPC as written to a trace.
+type TraceRecord struct {
+	Time     int64  `json:"time"` // when the simnet took the RPC, in unix nanoseconds of the simnet's clock
+	ID       string `json:"id"`   // shared by a request and its response
+	Cmd      string `json:"cmd"`
+	Response bool   `json:"response"`
+	Sender   string `json:"sender"` // IP of the sender
+	SenderID string `json:"sender_id"`
+	Receiver string `json:"receiver"` // IP of the receiver
+	Key      string `json:"key"`      // target of a lookup or the account, value or topic the RPC is about, empty if none
+	Dropped  bool   `json:"dropped"`
+	Reason   string `json:"reason"`  // why the RPC was dropped, empty if it was delivered
+	Latency  int64  `json:"latency"` // nanoseconds the simnet held the RPC before delivering or dropping it
+}
+
+var traceHeader = []string{"time", "id", "cmd", "response", "sender", "sender_id", "receiver", "key", "dropped", "reason", "latency"}
+
+type traceRecorder struct {
+	out       *bufio.Writer
+	collect   func(record TraceRecord) // called instead of writing the record when out is nil
+	csv       *csv.Writer              // nil when writing JSONL
+	threshold uint32                   // ids whose first word is below it are recorded
+	commands  map[Command]bool
+	err       error
+	stopped   bool
+	sync.Mutex
+}
+
+// Starts writing every RPC the simnet routes to out, with its timestamp, sender, receiver,
+// command, drop decision and the time the simnet held it. Sampling keeps or skips a request
+// together with its response, since both share an id. RPCs forwarded over a bridge are recorded
+// by the simnet they are delivered in.
+// Returns a function that stops the recording and flushes out, returning the first write error,
+// or an error if a recording is already running or the options are invalid.
+func (simnet *Simnet) RecordTrace(out io.Writer, opts TraceOptions) (func() error, error) {
+	recorder, err := newTraceRecorder(out, opts)
+	if err != nil {
+		return nil, err
+	}
+	return simnet.startTrace(recorder)
+}
+
+func (simnet *Simnet) startTrace(recorder *traceRecorder) (func() error, error) {
+	if !simnet.tracer.CompareAndSwap(nil, recorder) {
+		return nil, errors.New("a trace is already being recorded")
+	}
+	stop := func() error {
+		simnet.tracer.CompareAndSwap(recorder, nil)
+		return recorder.stop()
+	}
+	return stop, nil
+}
+
+func newTraceRecorder(out io.Writer, opts TraceOptions) (*traceRecorder, error) {
+	if opts.SampleRate < 0 || opts.SampleRate > 1 {
+		return nil, errors.New(fmt.Sprintf("sample rate must be between 0 and 1, got %v", opts.SampleRate))
+	}
+	if opts.Format != TRACE_JSONL && opts.Format != TRACE_CSV {
+		return nil, errors.New(fmt.Sprintf("unknown trace format %d", opts.Format))
+	}
+	recorder := &traceRecorder{threshold: math.MaxUint32}
+	if out != nil {
+		recorder.out = bufio.NewWriter(out)
+	}
+	if opts.SampleRate > 0 && opts.SampleRate < 1 {
+		recorder.threshold = uint32(opts.SampleRate * math.MaxUint32)
+	}
+	if len(opts.Commands) > 0 {
+		recorder.commands = make(map[Command]bool, len(opts.Commands))
+		for _, command := range opts.Commands {
+			recorder.commands[command] = true
+		}
+	}
+	if out != nil && opts.Format == TRACE_CSV {
+		recorder.csv = csv.NewWriter(recorder.out)
+		recorder.csv.Write(traceHeader)
+	}
+	return recorder, nil
+}
+
+// Writes the records to out in the given format, as RecordTrace would have.
+func WriteTrace(out io.Writer, records []TraceRecord, format TraceFormat) error {
+	recorder, err := newTraceRecorder(out, TraceOptions{Format: format})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		recorder.write(record)
+	}
+	return recorder.stop()
+}
+
+// Records the RPC if a trace is running, reason is empty if the RPC was delivered.
+func (simnet *Simnet) trace(rpc RPC, start time.Time, reason string) {
+	recorder := simnet.tracer.Load()
+	if recorder == nil || !recorder.wants(rpc) {
+		return
+	}
+	recorder.write(TraceRecord{
+		Time:     start.UnixNano(),
+		ID:       rpc.id.String(),
+		Cmd:      rpc.cmd.String(),
+		Response: rpc.response,
+		Sender:   netip.AddrFrom4(rpc.sender.IP()).String(),
+		SenderID: rpc.sender.ID().String(),
+		Receiver: netip.AddrFrom4(rpc.receiver).String(),
+		Key:      traceKey(rpc),
+		Dropped:  reason != "",
+		Reason:   reason,
+		Latency:  int64(simnet.timebase.Since(start)),
+	})
+}
+
+func traceKey(rpc RPC) string {
+	key := rpc.accountID
+	if rpc.cmd == FIND_NODE || rpc.cmd == FOUND_NODES || rpc.cmd == RECURSIVE_FIND_NODE || rpc.cmd == RECURSIVE_FOUND_NODES {
+		key = rpc.findNodeTarget
+	}
+	if key.IsZero() {
+		return ""
+	}
+	return key.String()
+}
+
+func (recorder *traceRecorder) wants(rpc RPC) bool {
+	if recorder.commands != nil && !recorder.commands[rpc.cmd] {
+		return false
+	}
+	return recorder.threshold == math.MaxUint32 || rpc.id[0] < recorder.threshold
+}
+
+func (recorder *traceRecorder) write(record TraceRecord) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if recorder.stopped || recorder.err != nil {
+		return
+	}
+	if recorder.out == nil {
+		recorder.collect(record)
+		return
+	}
+	if recorder.csv != nil {
+		recorder.err = recorder.csv.Write([]string{
+			strconv.FormatInt(record.Time, 10), record.ID, record.Cmd, strconv.FormatBool(record.Response),
+			record.Sender, record.SenderID, record.Receiver, record.Key, strconv.FormatBool(record.Dropped), record.Reason,
+			strconv.FormatInt(record.Latency, 10),
+		})
+		return
+	}
+	line, err := json.Marshal(record)
+	if err == nil {
+		line = append(line, '\n')
+		_, err = recorder.out.Write(line)
+	}
+	recorder.err = err
+}
+
+func (recorder *traceRecorder) stop() error {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if recorder.stopped {
+		return recorder.err
+	}
+	recorder.stopped = true
+	if recorder.csv != nil {
+		recorder.csv.Flush()
+		if err := recorder.csv.Error(); err != nil && recorder.err == nil {
+			recorder.err = err
+		}
+	}
+	if recorder.out == nil {
+		return nil
+	}
+	if err := recorder.out.Flush(); err != nil && recorder.err == nil {
+		recorder.err = err
+	}
+	return recorder.err
+}
@@ -0,0 +1,111 @@
+package kademlia
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"sync"
+	"testing"
+)
+
+func TestRecordTrace(t *testing.T) {
+	testName := "TestRecordTrace"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	out := bytes.Buffer{}
+	stop, err := s.RecordTrace(&out, TraceOptions{})
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	if _, err := s.RecordTrace(&bytes.Buffer{}, TraceOptions{}); err == nil {
+		log.Printf("[%s] - started a second trace", testName)
+		t.Fail()
+	}
+	nodes[0].FindNode(nodes[1].ID())
+	if err := stop(); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	requests, responses := map[string]bool{}, map[string]bool{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		record := TraceRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("[%s] - invalid trace line %q: %s", testName, scanner.Text(), err.Error())
+			t.FailNow()
+		}
+		if record.Cmd == FOUND_NODES.String() {
+			responses[record.ID] = true
+		} else if record.Cmd == FIND_NODE.String() {
+			requests[record.ID] = true
+			if record.SenderID != nodes[0].ID().String() {
+				log.Printf("[%s] - find node sent by %s, expected %s", testName, record.SenderID, nodes[0].ID())
+				t.Fail()
+			}
+		}
+		if record.Dropped || record.Time == 0 || record.Latency < 0 {
+			log.Printf("[%s] - unexpected record %+v", testName, record)
+			t.Fail()
+		}
+	}
+	// the lookup may return before the responses to its last queries are routed
+	if len(requests) == 0 || len(responses) == 0 {
+		log.Printf("[%s] - traced %d find node requests and %d responses", testName, len(requests), len(responses))
+		t.Fail()
+	}
+	for id := range responses {
+		if !requests[id] {
+			log.Printf("[%s] - response %s traced without its request", testName, id)
+			t.Fail()
+		}
+	}
+
+	// only pings with a sampled id, and every one dropped
+	out.Reset()
+	stop, err = s.RecordTrace(&out, TraceOptions{Format: TRACE_CSV, SampleRate: 0.5, Commands: []Command{PING}})
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	s.SetDropRate(1)
+	var wg sync.WaitGroup
+	for _, n := range nodes[1:] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nodes[0].Ping(n.IP())
+		}()
+	}
+	wg.Wait()
+	stop()
+	s.SetDropRate(0)
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || rows[0][0] != "time" {
+		log.Printf("[%s] - unexpected csv trace %v: %v", testName, rows, err)
+		t.FailNow()
+	}
+	for _, row := range rows[1:] {
+		if row[2] != PING.String() || row[8] != "true" || row[9] != "drop roll" {
+			log.Printf("[%s] - unexpected row %v", testName, row)
+			t.Fail()
+		}
+		// half of the id space is sampled, the ids whose first bit is zero
+		if row[1][0] >= '8' {
+			log.Printf("[%s] - recorded unsampled id %s", testName, row[1])
+			t.Fail()
+		}
+	}
+	if _, err := s.RecordTrace(&out, TraceOptions{SampleRate: 2}); err == nil {
+		log.Printf("[%s] - accepted a sample rate of 2", testName)
+		t.Fail()
+	}
+}
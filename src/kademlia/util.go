@@ -1,16 +1,17 @@
 package kademlia
 
 import (
+	"bytes"
 	"errors"
-	"math/bits"
 	"math/rand"
+	"slices"
 )
 
 // Returns a randomly generated id.
-func RandomID() [5]uint32 {
-	var res [5]uint32
-	for res == [5]uint32{0, 0, 0, 0, 0} {
-		for i := 0; i < 5; i++ {
+func RandomID() KademliaID {
+	var res KademliaID
+	for res.IsZero() {
+		for i := range res {
 			res[i] = rand.Uint32()
 		}
 	}
@@ -38,92 +39,86 @@ func RandU32(min uint32, max uint32) (uint32, error) {
 }
 
 // returns the xor distance metric for between the nodes
-func RelativeDistance(nodeA [5]uint32, nodeB [5]uint32) [5]uint32 {
-	relDist := [5]uint32{0, 0, 0, 0, 0}
-	for i := 0; i < 5; i++ {
-		relDist[i] = nodeA[i] ^ nodeB[i]
-	}
-	return relDist
+func RelativeDistance(nodeA KademliaID, nodeB KademliaID) Distance {
+	return nodeA.Distance(nodeB)
 }
 
 // Returns true if node A is closer to the target than node B, returns false if node B is closer to target than node A.
-func CloserNode(nodeA [5]uint32, nodeB [5]uint32, target [5]uint32) bool {
-	distA := RelativeDistance(nodeA, target)
-	distB := RelativeDistance(nodeB, target)
-	for i := 0; i < 5; i++ {
-		if distA[i] < distB[i] {
-			return true
-		} else if distB[i] < distA[i] {
-			return false
-		}
-	}
-	return false
+func CloserNode(nodeA KademliaID, nodeB KademliaID, target KademliaID) bool {
+	return nodeA.CloserTo(target, nodeB)
 }
 
 // Returns true if node A and B are the same distance from the target, otherwise returns false.
-func EquiDistantNode(nodeA [5]uint32, nodeB [5]uint32, target [5]uint32) bool {
-	distA := RelativeDistance(nodeA, target)
-	distB := RelativeDistance(nodeB, target)
-	if distA == distB {
-		return true
-	}
-	return false
+func EquiDistantNode(nodeA KademliaID, nodeB KademliaID, target KademliaID) bool {
+	return nodeA.Distance(target).Equal(nodeB.Distance(target))
 }
 
 // Returns the shared prefix length between the supplied ID's
-func DistPrefixLength(idA [5]uint32, idB [5]uint32) int {
-	length := 0
-	for i := 0; i < len(idA); i++ {
-		segDist := bits.LeadingZeros32(idA[i] ^ idB[i])
-		length += segDist
-		if segDist != 32 {
-			break
-		}
-	}
-	return length
+func DistPrefixLength(idA KademliaID, idB KademliaID) int {
+	return idA.Prefix(idB)
 }
 
 // returns true if node A is larger than node node
 // returns false if node B is larger than or equal to node A
-func LargerNode(nodeA [5]uint32, nodeB [5]uint32) bool {
-	for i := 0; i < 5; i++ {
-		if nodeA[i] > nodeB[i] {
-			return true
-		} else if nodeA[i] < nodeB[i] {
-			return false
-		}
+func LargerNode(nodeA KademliaID, nodeB KademliaID) bool {
+	return nodeA.Cmp(nodeB) > 0
+}
+
+// Compares two XOR distances as unsigned integers.
+// Returns a negative number if distA is smaller, zero if they are equal and a positive number otherwise.
+func CompareDistance(distA Distance, distB Distance) int {
+	return distA.Cmp(distB)
+}
+
+// Compares two IPs byte by byte, used to break ties between contacts sharing an id.
+func CompareIP(ipA [4]byte, ipB [4]byte) int {
+	return bytes.Compare(ipA[:], ipB[:])
+}
+
+// Orders contacts by distance to the target. Contacts are only equally distant if they share an
+// id, those are ordered by address so that the order never depends on the input order.
+func CompareContacts(conA Contact, conB Contact, target KademliaID) int {
+	res := CompareDistance(RelativeDistance(conA.ID(), target), RelativeDistance(conB.ID(), target))
+	if res != 0 {
+		return res
 	}
-	return false
+	return compareAddress(conA, conB)
+}
+
+// Orders contacts by IP address and then port.
+func compareAddress(conA Contact, conB Contact) int {
+	if res := CompareIP(conA.IP(), conB.IP()); res != 0 {
+		return res
+	}
+	return conA.port - conB.port
 }
 
-// sorts contact slice based on distance to the target
-func SortContactsByDistance(input *[]Contact, target [5]uint32) {
-	for i := 1; i < len(*input); i++ {
-		for j := 0; j < len(*input)-1; j++ {
-			nodeA := (*input)[j]
-			nodeB := (*input)[j+1]
-			sortCriterion := CloserNode(nodeB.ID(), nodeA.ID(), target)
-
-			// if node B is close to target than node A
-			if sortCriterion {
-				(*input)[j] = nodeB
-				(*input)[j+1] = nodeA
-
-				//if node A and B are the same distance from target
-			} else if EquiDistantNode(nodeA.ID(), nodeB.ID(), target) {
-
-				// if node A is larger than node B
-				if LargerNode(nodeA.ID(), nodeB.ID()) {
-					(*input)[j] = nodeB
-					(*input)[j+1] = nodeA
-				}
-			}
+// sorts contact slice based on distance to the target, ties are broken as in CompareContacts.
+// The distance of each contact is computed once up front rather than on every comparison.
+func SortContactsByDistance(input *[]Contact, target KademliaID) {
+	scratch := sortPool.Get().(*[]distanceKey)
+	keys := (*scratch)[:0]
+	for _, c := range *input {
+		keys = append(keys, distanceKey{RelativeDistance(c.ID(), target), c})
+	}
+	slices.SortFunc(keys, func(a distanceKey, b distanceKey) int {
+		res := CompareDistance(a.dist, b.dist)
+		if res != 0 {
+			return res
 		}
+		return compareAddress(a.contact, b.contact)
+	})
+	for i, k := range keys {
+		(*input)[i] = k.contact
 	}
+	// the pool keeps the scratch alive, it must not keep the sorted contacts alive with it
+	clear(keys[:cap(keys)])
+	*scratch = keys[:0]
+	sortPool.Put(scratch)
 }
 
 // Merges two slices of Contacts and removes all duplicates.
-func MergeContactsByDistance(setA *[]Contact, setB *[]Contact, target [5]uint32) []Contact {
+func MergeContactsByDistance(setA *[]Contact, setB *[]Contact, target KademliaID) []Contact {
 	res := make([]Contact, 0)
 	res = append(res, (*setA)...)
 	res = append(res, (*setB)...)
@@ -141,7 +136,7 @@ func RemoveDuplicateContacts(set *[]Contact) {
 }
 
 // Returns true if the slice contains a node with provided ID.
-func SliceContains(id [5]uint32, slice *[]Contact) bool {
+func SliceContains(id KademliaID, slice *[]Contact) bool {
 	for _, node := range *slice {
 		if node.ID() == id {
 			return true
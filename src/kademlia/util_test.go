@@ -3,6 +3,7 @@ package kademlia
 import (
 	"fmt"
 	"log"
+	"slices"
 	"testing"
 )
 
@@ -28,31 +29,31 @@ func TestRelativeDistance(t *testing.T) {
 	pI := [5]uint32{1, 1, uint32(10), 1, 1}
 	pHI := RelativeDistance(pH, pI)
 
-	if pAB != [5]uint32{0, 0, 0, 0, 1} {
+	if pAB != (Distance{[5]uint32{0, 0, 0, 0, 1}}) {
 		log.Printf("[%s] - relative distance pA -> pB incorrect, received %v", testName, pAB)
 		t.Fail()
 	}
-	if pAC != [5]uint32{0, 0, 1, 0, 0} {
+	if pAC != (Distance{[5]uint32{0, 0, 1, 0, 0}}) {
 		log.Printf("[%s] - relative distance pA -> pC incorrect, received %v", testName, pAC)
 		t.Fail()
 	}
-	if pAD != [5]uint32{0, 0, 0, 1, 0} {
+	if pAD != (Distance{[5]uint32{0, 0, 0, 1, 0}}) {
 		log.Printf("[%s] - relative distance pA -> pD incorrect, received %v", testName, pAD)
 		t.Fail()
 	}
-	if pAE != [5]uint32{0, 12, 0, 0, 0} {
+	if pAE != (Distance{[5]uint32{0, 12, 0, 0, 0}}) {
 		log.Printf("[%s] - relative distance pA -> pE incorrect, received %v", testName, pAE)
 		t.Fail()
 	}
-	if pAF != [5]uint32{0, 0, 0, 0, 10} {
+	if pAF != (Distance{[5]uint32{0, 0, 0, 0, 10}}) {
 		log.Printf("[%s] - relative distance pA -> pF incorrect, received %v", testName, pAF)
 		t.Fail()
 	}
-	if pAG != [5]uint32{1, 0, 0, 0, 0} {
+	if pAG != (Distance{[5]uint32{1, 0, 0, 0, 0}}) {
 		log.Printf("[%s] - relative distance pA -> pG incorrect, received %v", testName, pAG)
 		t.Fail()
 	}
-	if pHI != [5]uint32{0, 0, 11, 0, 0} {
+	if pHI != (Distance{[5]uint32{0, 0, 11, 0, 0}}) {
 		log.Printf("[%s] - relative distance pH -> pI incorrect, received %v", testName, pHI)
 		t.Fail()
 	}
@@ -136,7 +137,7 @@ func TestSortSliceByDistance(t *testing.T) {
 	if verbose {
 		s := fmt.Sprintf("[%s]\nbefore sort:\n", testName)
 		for _, v := range input {
-			s += fmt.Sprintf("node - %v, relative distance %d\n", v, RelativeDistance(v.ID(), target))
+			s += fmt.Sprintf("node - %v, relative distance %v\n", v, RelativeDistance(v.ID(), target))
 		}
 		log.Println(s)
 	}
@@ -146,7 +147,7 @@ func TestSortSliceByDistance(t *testing.T) {
 	if verbose {
 		res := fmt.Sprintf("[%s]\nafter sort:\n", testName)
 		for _, v := range input {
-			res += fmt.Sprintf("node - %v, relative distance %d\n", v, RelativeDistance(v.ID(), target))
+			res += fmt.Sprintf("node - %v, relative distance %v\n", v, RelativeDistance(v.ID(), target))
 		}
 		log.Println(res)
 	}
@@ -563,3 +564,120 @@ func TestSliceContainsAllFalse(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestSortContactsByDistanceMatchesPairwise(t *testing.T) {
+	testName := "TestSortContactsByDistanceMatchesPairwise"
+	target := RandomID()
+	input := make([]Contact, 0, 500)
+	for range 500 {
+		input = append(input, NewRandomContact())
+	}
+	SortContactsByDistance(&input, target)
+	for i := 0; i < len(input)-1; i++ {
+		if CloserNode(input[i+1].ID(), input[i].ID(), target) {
+			log.Printf("[%s] - node at %d is closer than node at %d", testName, i+1, i)
+			t.Fail()
+		}
+	}
+}
+
+func benchmarkSortContactsByDistance(b *testing.B, size int) {
+	target := RandomID()
+	contacts := make([]Contact, 0, size)
+	for range size {
+		contacts = append(contacts, NewRandomContact())
+	}
+	input := make([]Contact, size)
+	b.ResetTimer()
+	for range b.N {
+		copy(input, contacts)
+		SortContactsByDistance(&input, target)
+	}
+}
+
+func BenchmarkSortContactsByDistance20(b *testing.B) {
+	benchmarkSortContactsByDistance(b, REPLICATION)
+}
+
+func BenchmarkSortContactsByDistance1000(b *testing.B) {
+	benchmarkSortContactsByDistance(b, 1000)
+}
+
+func BenchmarkSortContactsByDistance10000(b *testing.B) {
+	benchmarkSortContactsByDistance(b, 10000)
+}
+
+func TestSortContactsByDistanceDeterministic(t *testing.T) {
+	testName := "TestSortContactsByDistanceDeterministic"
+	target := RandomID()
+	shared := RandomID()
+	contacts := []Contact{
+		NewContact([4]byte{10, 0, 0, 3}, shared),
+		NewRandomContact(),
+		NewContact([4]byte{10, 0, 0, 1}, shared),
+		NewRandomContact(),
+		NewContact([4]byte{10, 0, 0, 2}, shared),
+	}
+	reversed := slices.Clone(contacts)
+	slices.Reverse(reversed)
+	SortContactsByDistance(&contacts, target)
+	SortContactsByDistance(&reversed, target)
+	if !slices.Equal(contacts, reversed) {
+		log.Printf("[%s] - order depends on the input order", testName)
+		t.Fail()
+	}
+	for i := 1; i < len(contacts); i++ {
+		if CompareContacts(contacts[i-1], contacts[i], target) >= 0 {
+			log.Printf("[%s] - contacts %d and %d are out of order", testName, i-1, i)
+			t.Fail()
+		}
+	}
+}
+
+func FuzzSortContactsByDistance(f *testing.F) {
+	testName := "FuzzSortContactsByDistance"
+	f.Add(make([]byte, ID_BYTES+3*(ID_BYTES+4)))
+	f.Add([]byte("a target followed by contacts sharing ids and ips"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		target := IDFromBytes(data)
+		contacts := fuzzContacts(data[min(len(data), ID_BYTES):])
+		sorted := slices.Clone(contacts)
+		SortContactsByDistance(&sorted, target)
+		for i := 1; i < len(sorted); i++ {
+			if CompareContacts(sorted[i-1], sorted[i], target) > 0 || CloserNode(sorted[i].ID(), sorted[i-1].ID(), target) {
+				log.Printf("[%s] - contacts %d and %d are out of order", testName, i-1, i)
+				t.FailNow()
+			}
+		}
+		reversed := slices.Clone(contacts)
+		slices.Reverse(reversed)
+		SortContactsByDistance(&reversed, target)
+		if !slices.Equal(sorted, reversed) {
+			log.Printf("[%s] - order depends on the input order", testName)
+			t.FailNow()
+		}
+		byID := func(a Contact, b Contact) int { return CompareContacts(a, b, KademliaID{}) }
+		slices.SortFunc(contacts, byID)
+		slices.SortFunc(reversed, byID)
+		if !slices.Equal(contacts, reversed) {
+			log.Printf("[%s] - sorting changed the contacts", testName)
+			t.FailNow()
+		}
+	})
+}
+
+// Splits fuzzer input into contacts of an id and an ip each, a trailing partial contact is dropped.
+// Contacts whose ip starts with 0xfe get a port made of the ip's second byte.
+func fuzzContacts(data []byte) []Contact {
+	res := make([]Contact, 0, len(data)/(ID_BYTES+4))
+	for len(data) >= ID_BYTES+4 {
+		id := IDFromBytes(data)
+		con := NewContact([4]byte(data[ID_BYTES:ID_BYTES+4]), id)
+		if data[ID_BYTES] == 0xfe {
+			con.port = int(data[ID_BYTES+1])
+		}
+		res = append(res, con)
+		data = data[ID_BYTES+4:]
+	}
+	return res
+}
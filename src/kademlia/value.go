@@ -0,0 +1,275 @@
+package kademlia
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	VALUE_TTL       = 10 * time.Minute // lifetime of a value at its K closest nodes
+	VALUE_CACHE_TTL = 30 * time.Second // lifetime of a value cached along a lookup path
+	VALUE_MAX_BYTES = 64 * 1024        // largest value a node stores
+)
+
+// A value held by a node, either as one of the K closest nodes of its key or as a cached copy.
+type storedValue struct {
+	data        []byte
+	expires     time.Time
+	replication int // closest nodes the value is kept at, zero for the node's replication
+}
+
+// Content-addressed values held by a node, values are keyed by the hash of their data.
+type valueStore struct {
+	stored      map[KademliaID]storedValue
+	cached      map[KademliaID]storedValue
+	storeHits   atomic.Int64
+	cacheHits   atomic.Int64
+	misses      atomic.Int64
+	lookups     atomic.Int64
+	found       atomic.Int64
+	foundCached atomic.Int64
+	handedOver  atomic.Int64
+	stores      atomic.Int64
+	replicas    atomic.Int64
+	underRep    atomic.Int64
+	sync.Mutex
+}
+
+func newValueStore() *valueStore {
+	return &valueStore{
+		stored: make(map[KademliaID]storedValue),
+		cached: make(map[KademliaID]storedValue),
+	}
+}
+
+// Holds the value until expires, in the cache if cache is set. Replication is the number of
+// closest nodes the value is to be kept at, zero for the node's own replication.
+// Returns an error if the data does not hash to the key or is too large.
+func (store *valueStore) put(key KademliaID, data []byte, cache bool, expires time.Time, replication int) error {
+	if len(data) > VALUE_MAX_BYTES {
+		return errors.New(fmt.Sprintf("value %v exceeds %d bytes", key, VALUE_MAX_BYTES))
+	}
+	if NewKeyFromData(data) != key {
+		return errors.New(fmt.Sprintf("value does not hash to key %v", key))
+	}
+	store.Lock()
+	defer store.Unlock()
+	if cache {
+		store.cached[key] = storedValue{bytes.Clone(data), expires, replication}
+	} else {
+		store.stored[key] = storedValue{bytes.Clone(data), expires, replication}
+	}
+	return nil
+}
+
+// Returns the unexpired value for key and whether it came from the cache, expired values are dropped.
+func (store *valueStore) get(key KademliaID, now time.Time) ([]byte, bool, bool) {
+	store.Lock()
+	defer store.Unlock()
+	for _, cache := range []bool{false, true} {
+		content := store.stored
+		if cache {
+			content = store.cached
+		}
+		val, ok := content[key]
+		if !ok {
+			continue
+		}
+		if !val.expires.After(now) {
+			delete(content, key)
+			continue
+		}
+		return val.data, cache, true
+	}
+	return nil, false, false
+}
+
+// Counters of a node's value store and of the value lookups it ran.
+type ValueStats struct {
+	Stored      int // values held as one of the K closest nodes
+	Cached      int // values held in the cache
+	StoreHits   int // find value queries answered from the store
+	CacheHits   int // find value queries answered from the cache
+	Misses      int // find value queries answered with contacts
+	Lookups     int // value lookups run by the node
+	Found       int // value lookups that found the value
+	FoundCached int // value lookups that found the value in a cache
+	HandedOver  int // values transferred to nodes that joined closer to their keys
+	// Values stored by the node at their closest nodes, the copies those stores placed and the
+	// stores that placed fewer copies than their replication. Replicas over Stores is the
+	// replication the node's stores achieved.
+	Stores          int
+	Replicas        int
+	UnderReplicated int
+}
+
+// Returns the share of the find value queries the node answered from its cache.
+func (stats ValueStats) CacheHitRate() float64 {
+	queries := stats.StoreHits + stats.CacheHits + stats.Misses
+	if queries == 0 {
+		return 0
+	}
+	return float64(stats.CacheHits) / float64(queries)
+}
+
+func (node *Node) ValueStats() ValueStats {
+	store := node.values
+	store.Lock()
+	stats := ValueStats{Stored: len(store.stored), Cached: len(store.cached)}
+	store.Unlock()
+	stats.StoreHits = int(store.storeHits.Load())
+	stats.CacheHits = int(store.cacheHits.Load())
+	stats.Misses = int(store.misses.Load())
+	stats.Lookups = int(store.lookups.Load())
+	stats.Found = int(store.found.Load())
+	stats.FoundCached = int(store.foundCached.Load())
+	stats.HandedOver = int(store.handedOver.Load())
+	stats.Stores = int(store.stores.Load())
+	stats.Replicas = int(store.replicas.Load())
+	stats.UnderReplicated = int(store.underRep.Load())
+	return stats
+}
+
+// Stores data at the K closest nodes of its key, the hash of the data.
+// Returns the key, or an error if no node stored the value.
+func (node *Node) StoreValue(data []byte) (KademliaID, error) {
+	return node.StoreValueWithReplication(data, 0)
+}
+
+// Stores data at the replication closest nodes of its key instead of the K closest, so that
+// values can be kept at fewer or, in networks with a large K, more nodes than the default. The
+// holders keep the value at that many nodes as the topology shifts, see handOver. A replication
+// of zero stores at the K closest nodes. The copies placed are counted in the node's ValueStats
+// and metrics.
+// Returns the key, or an error if replication is out of range or no node stored the value.
+func (node *Node) StoreValueWithReplication(data []byte, replication int) (KademliaID, error) {
+	key := NewKeyFromData(data)
+	if len(data) > VALUE_MAX_BYTES {
+		return key, errors.New(fmt.Sprintf("value %v exceeds %d bytes", key, VALUE_MAX_BYTES))
+	}
+	if replication < 0 || replication > node.config.Replication {
+		return key, errors.New(fmt.Sprintf("replication must be between 0 and %d, got %d", node.config.Replication, replication))
+	}
+	want := replication
+	if want == 0 {
+		want = node.config.Replication
+	}
+	holders := node.FindNode(key)
+	holders = holders[:min(len(holders), want)]
+	respChan := make(chan bool, len(holders))
+	for _, con := range holders {
+		node.routines.Go("store value", func() { respChan <- node.storeValue(con, key, data, false, replication) })
+	}
+	stored := 0
+	for range holders {
+		if <-respChan {
+			stored++
+		}
+	}
+	node.values.stores.Add(1)
+	node.values.replicas.Add(int64(stored))
+	if stored < want {
+		node.values.underRep.Add(1)
+	}
+	node.Network.metrics.ValueStored(want, stored)
+	if stored == 0 {
+		return key, errors.New(fmt.Sprintf("no node stored value %v", key))
+	}
+	return key, nil
+}
+
+func (node *Node) storeValue(con Contact, key KademliaID, data []byte, cache bool, replication int) bool {
+	rpc := GenerateRPC(con.IP(), node.Contact)
+	rpc.StoreValue(key, data, cache)
+	rpc.replication = replication
+	res, err := node.Send(rpc)
+	return err == nil && res.valueFound
+}
+
+// Looks up the value for key, stopping at the first node that returns it. The value is then
+// cached at the closest node the lookup queried that did not return it, so later lookups
+// passing that node end sooner.
+// Returns an error if no node returned a value matching the key.
+func (node *Node) FindValue(key KademliaID) ([]byte, error) {
+	node.values.lookups.Add(1)
+	if data, _, ok := node.values.get(key, node.Now()); ok {
+		node.values.found.Add(1)
+		return data, nil
+	}
+	initNodes, _ := node.FindXClosest(node.config.Replication, key)
+	res := node.lookup(initNodes, key, func(con Contact) lookupResponse { return node.findValueQuery(con, key) })
+	if res.value == nil {
+		return nil, errors.New(fmt.Sprintf("did not find value: %v", key))
+	}
+	node.values.found.Add(1)
+	if res.cached {
+		node.values.foundCached.Add(1)
+	}
+	if res.miss != nil {
+		miss := *res.miss
+		node.routines.Go("cache value", func() { node.storeValue(miss, key, res.value, true, 0) })
+	}
+	return res.value, nil
+}
+
+// Sends a find value query for key to con. Values that do not hash to the key are treated as a
+// failed query.
+func (node *Node) findValueQuery(con Contact, key KademliaID) lookupResponse {
+	rpc := GenerateRPC(con.IP(), node.Contact)
+	rpc.FindValue(key)
+	resp, err := node.Send(rpc)
+	if err != nil {
+		return lookupResponse{err: err}
+	}
+	if resp.valueFound {
+		if NewKeyFromData(resp.value) != key {
+			return lookupResponse{err: errors.New(fmt.Sprintf("node %v returned a value not matching %v", con.IP(), key))}
+		}
+		return lookupResponse{value: resp.value, cached: resp.valueCached}
+	}
+	return lookupResponse{found: resp.foundNodes}
+}
+
+// Response logic for an incoming store value RPC.
+func (node *Node) handleStoreValue(rpc *RPC) {
+	var err error
+	if node.Role() == OBSERVER {
+		err = errors.New("observers do not store values")
+	} else {
+		ttl := VALUE_TTL
+		if rpc.valueCached {
+			ttl = VALUE_CACHE_TTL
+		}
+		err = node.values.put(rpc.accountID, rpc.value, rpc.valueCached, node.Now().Add(ttl), rpc.replication)
+	}
+	if err != nil {
+		node.logger.Debug("refused to store value", "rpc", rpc.id, "key", rpc.accountID, "err", err)
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.StoredValue(rpc.accountID, err == nil)
+	node.Send(resp)
+}
+
+// Response logic for an incoming find value RPC.
+// Responds with the value if the node holds it, otherwise with the closest contacts it knows of.
+func (node *Node) handleFindValue(rpc *RPC) {
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	data, cached, ok := node.values.get(rpc.accountID, node.Now())
+	if ok {
+		if cached {
+			node.values.cacheHits.Add(1)
+		} else {
+			node.values.storeHits.Add(1)
+		}
+		resp.FoundValue(rpc.accountID, data, cached)
+	} else {
+		node.values.misses.Add(1)
+		closest, _ := node.FindXClosest(node.config.Replication, rpc.accountID)
+		resp.FoundValueContacts(rpc.accountID, closest)
+	}
+	node.Send(resp)
+}
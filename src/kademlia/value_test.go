@@ -0,0 +1,82 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestValueStoreRejectsMismatchedKey(t *testing.T) {
+	testName := "TestValueStoreRejectsMismatchedKey"
+	store := newValueStore()
+	expires := time.Now().Add(time.Minute)
+	if store.put(NewKeyFromString("other"), []byte("value"), false, expires, 0) == nil {
+		log.Printf("[%s] - stored a value under a key it does not hash to", testName)
+		t.Fail()
+	}
+	key := NewKeyFromString("value")
+	store.put(key, []byte("value"), true, time.Now().Add(time.Millisecond), 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, _, ok := store.get(key, time.Now()); ok {
+		log.Printf("[%s] - returned an expired cached value", testName)
+		t.Fail()
+	}
+}
+
+func TestFindValueCachesAtClosestMiss(t *testing.T) {
+	testName := "TestFindValueCachesAtClosestMiss"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(40, done)
+	<-done
+	defer s.Shutdown()
+
+	// Place the value at its closest node only, so lookups pass nodes that do not hold it.
+	data := []byte("cached value")
+	key := NewKeyFromData(data)
+	closest := nodes[0].FindNode(key)
+	if !nodes[0].storeValue(closest[0], key, data, false, 0) {
+		log.Printf("[%s] - closest node did not store the value", testName)
+		t.FailNow()
+	}
+
+	var cache *Node
+	for _, n := range nodes {
+		if n.ID() == closest[0].ID() {
+			continue
+		}
+		res, err := n.FindValue(key)
+		if err != nil || string(res) != string(data) {
+			log.Printf("[%s] - lookup from %v failed: %v", testName, n.ID(), err)
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+		for _, m := range nodes {
+			if m.ValueStats().Cached > 0 {
+				cache = m
+			}
+		}
+		if cache != nil {
+			break
+		}
+	}
+	if cache == nil {
+		log.Printf("[%s] - no lookup cached the value", testName)
+		t.FailNow()
+	}
+
+	rpc := GenerateRPC(cache.IP(), nodes[0].Contact)
+	rpc.FindValue(key)
+	res, err := nodes[0].Send(rpc)
+	if err != nil || !res.valueFound || !res.valueCached {
+		log.Printf("[%s] - caching node did not answer from its cache", testName)
+		t.Fail()
+	}
+	stats := cache.ValueStats()
+	if stats.CacheHits == 0 || stats.CacheHitRate() <= 0 {
+		log.Printf("[%s] - cache hit was not counted: %+v", testName, stats)
+		t.Fail()
+	}
+}
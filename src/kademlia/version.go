@@ -0,0 +1,92 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Version of the RPC protocol. Every RPC carries the version its sender speaks and the oldest
+// version the sender still understands, two nodes interoperate if each one's version is within
+// the other's range and speak the lower of their versions to each other.
+type ProtocolVersion uint16
+
+const (
+	PROTOCOL_VERSION     ProtocolVersion = 1 // version spoken by this build
+	MIN_PROTOCOL_VERSION ProtocolVersion = 1 // oldest version this build still understands
+)
+
+// Returned by Send when the receiver does not speak a version compatible with the sender's.
+type VersionError struct {
+	Receiver   [4]byte
+	Version    ProtocolVersion
+	MinVersion ProtocolVersion
+}
+
+func (err *VersionError) Error() string {
+	return fmt.Sprintf("node %v speaks protocol versions %d to %d", err.Receiver, err.MinVersion, err.Version)
+}
+
+// Negotiated protocol versions keyed by peer IP.
+type versionTable struct {
+	content map[[4]byte]ProtocolVersion
+	sync.RWMutex
+}
+
+func newVersionTable() *versionTable {
+	return &versionTable{
+		content: make(map[[4]byte]ProtocolVersion),
+	}
+}
+
+func (table *versionTable) record(ip [4]byte, version ProtocolVersion) {
+	table.Lock()
+	defer table.Unlock()
+	table.content[ip] = version
+}
+
+func (table *versionTable) get(ip [4]byte) (ProtocolVersion, bool) {
+	table.RLock()
+	defer table.RUnlock()
+	version, ok := table.content[ip]
+	return version, ok
+}
+
+// Sets the protocol versions the node speaks, must be called before the node is started.
+// Returns an error if min is zero or above version.
+func (net *Network) SetProtocolVersion(version ProtocolVersion, min ProtocolVersion) error {
+	if min == 0 || min > version {
+		return errors.New(fmt.Sprintf("invalid protocol version range %d to %d", min, version))
+	}
+	net.version = version
+	net.minVersion = min
+	return nil
+}
+
+// Returns the protocol version the node speaks and the oldest one it understands.
+func (net *Network) ProtocolVersion() (ProtocolVersion, ProtocolVersion) {
+	return net.version, net.minVersion
+}
+
+// Returns the version negotiated with the peer at ip, or false if it has not been heard from.
+func (net *Network) PeerVersion(ip [4]byte) (ProtocolVersion, bool) {
+	return net.versions.get(ip)
+}
+
+// Returns true if the sender of the rpc and the node understand each other's versions.
+func (net *Network) compatible(rpc *RPC) bool {
+	return rpc.version >= net.minVersion && net.version >= rpc.minVersion
+}
+
+// Sets the protocol versions of nodes spawned from now on, existing nodes keep theirs.
+// Returns an error if the range is invalid.
+func (simnet *Simnet) SetProtocolVersion(version ProtocolVersion, min ProtocolVersion) error {
+	if min == 0 || min > version {
+		return errors.New(fmt.Sprintf("invalid protocol version range %d to %d", min, version))
+	}
+	simnet.spawned.Lock()
+	defer simnet.spawned.Unlock()
+	simnet.version = version
+	simnet.minVersion = min
+	return nil
+}
@@ -0,0 +1,71 @@
+package kademlia
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSetProtocolVersionRange(t *testing.T) {
+	testName := "TestSetProtocolVersionRange"
+	me := NewRandomContact()
+	node := NewNode(me.ID(), me.IP(), make(chan RPC, 8), make(chan RPC, 8), [4]byte{0, 0, 0, 0}, me, false)
+	if node.SetProtocolVersion(1, 2) == nil || node.SetProtocolVersion(1, 0) == nil {
+		log.Printf("[%s] - accepted an invalid version range", testName)
+		t.Fail()
+	}
+	version, min := node.ProtocolVersion()
+	if version != PROTOCOL_VERSION || min != MIN_PROTOCOL_VERSION {
+		log.Printf("[%s] - invalid range changed the versions to %d to %d", testName, min, version)
+		t.Fail()
+	}
+}
+
+func TestMixedVersionCluster(t *testing.T) {
+	testName := "TestMixedVersionCluster"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(6, done)
+	<-done
+	defer s.Shutdown()
+	old := nodes[0]
+
+	// A newer node that still understands the old version talks to the old nodes in the old version.
+	s.SetProtocolVersion(PROTOCOL_VERSION+1, PROTOCOL_VERSION)
+	spawned := make(chan KademliaID, 1)
+	compatible := s.SpawnNode(spawned)
+	<-spawned
+	if !compatible.Ping(old.IP()) {
+		log.Printf("[%s] - compatible node could not reach an old node", testName)
+		t.Fail()
+	}
+	version, ok := compatible.PeerVersion(old.IP())
+	if !ok || version != PROTOCOL_VERSION {
+		log.Printf("[%s] - expected negotiated version %d, received %d", testName, PROTOCOL_VERSION, version)
+		t.Fail()
+	}
+
+	// A node that dropped the old version is rejected without waiting for a timeout.
+	s.SetProtocolVersion(PROTOCOL_VERSION+1, PROTOCOL_VERSION+1)
+	incompatible := s.SpawnNode(spawned)
+	<-spawned
+	rpc := GenerateRPC(old.IP(), incompatible.Contact)
+	rpc.Ping()
+	start := time.Now()
+	_, err := incompatible.Send(rpc)
+	versionErr, ok := err.(*VersionError)
+	if !ok || versionErr.Version != PROTOCOL_VERSION {
+		log.Printf("[%s] - expected a version error, received %v", testName, err)
+		t.Fail()
+	}
+	if time.Since(start) >= TIMEOUT {
+		log.Printf("[%s] - incompatible request was not answered before the timeout", testName)
+		t.Fail()
+	}
+	if _, err := old.FindByIP(incompatible.IP()); err == nil {
+		log.Printf("[%s] - old node added an incompatible node to its routing table", testName)
+		t.Fail()
+	}
+}
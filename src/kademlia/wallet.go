@@ -0,0 +1,113 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"main/src/scalegraph"
+)
+
+// Returns the balance of the account as reported by the first of its validators to answer.
+func (node *Node) Balance(accID KademliaID) (uint64, error) {
+	validators := node.FindNode(accID)
+	for _, con := range node.OrderByLatency(validators) {
+		rpc := GenerateRPC(con.IP(), node.Contact)
+		rpc.FindBalance(accID)
+		res, err := node.Send(rpc)
+		if err == nil && res.balanceFound {
+			return res.balance, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("did not find account: %v", accID))
+}
+
+// Moves amount from one account to another.
+// The transfer is first appended by the validators of the sending account, which reject it if
+// the funds are insufficient, and then by the validators of the receiving account. Each step
+// needs as many validators of the group as the node's write consistency requires. Transfers from scalegraph.MINT_ACCOUNT skip the first step.
+// The transfer is unsigned, so it is refused for wallets submitted with a key, see ProposeTransaction.
+func (node *Node) Transfer(from KademliaID, to KademliaID, amount uint64) error {
+	trx := scalegraph.NewTransfer(from, to, amount)
+	if from != scalegraph.MINT_ACCOUNT {
+		err := node.appendTransaction(from, trx)
+		if err != nil {
+			return err
+		}
+	}
+	return node.appendTransaction(to, trx)
+}
+
+// Sends the transaction to the validators of accID, returns an error unless enough of them appended
+// it for the node's write consistency, counted over the validators of the account's replication.
+func (node *Node) appendTransaction(accID KademliaID, trx *scalegraph.Transaction) error {
+	validators := node.FindNode(accID)
+	respChan := make(chan RPC, len(validators))
+	for _, val := range node.OrderByLatency(validators) {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.AppendTransaction(accID, *trx.Copy())
+		node.routines.Go("append transaction", func() {
+			res, err := node.Send(rpc)
+			if err != nil {
+				res.appendSucc = false
+			}
+			respChan <- res
+		})
+	}
+	appended, replication := 0, 0
+	for range validators {
+		if res := <-respChan; res.appendSucc {
+			appended++
+			replication = max(replication, res.replication)
+		}
+	}
+	validators = node.walletValidators(validators, accID, replication)
+	required := node.config.WriteConsistency.Required(len(validators))
+	if appended < required {
+		return failure(ErrQuorumNotReached, "transaction %v appended by %d of %d validators for account: %v, %d required", trx.ID(), appended, len(validators), accID, required)
+	}
+	return nil
+}
+
+// Replaces the rules the node evaluates, in order, before appending a transaction as a validator.
+func (node *Node) SetValidationRules(rules ...scalegraph.Rule) {
+	node.scalegraph.SetRules(rules...)
+}
+
+// Adds a rule that is evaluated after the node's existing validation rules.
+func (node *Node) AddValidationRule(rule scalegraph.Rule) {
+	node.scalegraph.AddRule(rule)
+}
+
+// Response logic for an incoming append transaction RPC.
+func (node *Node) handleAppendTransaction(rpc *RPC) {
+	trx := rpc.transaction.Copy()
+	var err error
+	if node.Role() == OBSERVER {
+		err = errors.New("observers do not validate transactions")
+	} else {
+		err = node.scalegraph.CheckSignature(rpc.accountID, trx)
+	}
+	if err == nil {
+		err = node.scalegraph.ApplyTransaction(rpc.accountID, trx)
+	}
+	if err != nil {
+		node.logger.Warn("rejected transaction", "rpc", rpc.id, "account", rpc.accountID, "transaction", trx.ID(), "err", err)
+	} else {
+		node.notifyWatchers(rpc.accountID)
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.AppendedTransaction(rpc.accountID, trx.ID(), err == nil)
+	resp.replication = node.heldReplication(rpc.accountID)
+	node.Send(resp)
+}
+
+// Response logic for an incoming find balance RPC.
+func (node *Node) handleFindBalance(rpc *RPC) {
+	acc, err := node.scalegraph.FindAccount(rpc.accountID)
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	if err != nil {
+		resp.FoundBalance(rpc.accountID, 0, false)
+	} else {
+		resp.FoundBalance(rpc.accountID, acc.Balance(), true)
+	}
+	node.Send(resp)
+}
@@ -0,0 +1,207 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	WATCH_LEASE = 40 * TIMEOUT // how long a validator notifies a watcher without the watch being renewed
+)
+
+// Watchers of the wallets a node validates, keyed by wallet and watcher IP. Also holds the
+// subscribers of the topics a node is a rendezvous point of, keyed by topic.
+type watcherTable struct {
+	content map[KademliaID]map[[4]byte]watcher
+	sync.Mutex
+}
+
+type watcher struct {
+	contact Contact
+	expires time.Time
+}
+
+func newWatcherTable() *watcherTable {
+	return &watcherTable{
+		content: make(map[KademliaID]map[[4]byte]watcher),
+	}
+}
+
+// Adds or renews the watch of con on the wallet until expires.
+func (table *watcherTable) add(walletID KademliaID, con Contact, expires time.Time) {
+	table.Lock()
+	defer table.Unlock()
+	watchers, ok := table.content[walletID]
+	if !ok {
+		watchers = make(map[[4]byte]watcher)
+		table.content[walletID] = watchers
+	}
+	watchers[con.IP()] = watcher{con, expires}
+}
+
+// Returns the live watchers of the wallet, expired watches are dropped.
+func (table *watcherTable) live(walletID KademliaID, now time.Time) []Contact {
+	table.Lock()
+	defer table.Unlock()
+	res := make([]Contact, 0)
+	for ip, w := range table.content[walletID] {
+		if now.After(w.expires) {
+			delete(table.content[walletID], ip)
+			continue
+		}
+		res = append(res, w.contact)
+	}
+	if len(table.content[walletID]) == 0 {
+		delete(table.content, walletID)
+	}
+	return res
+}
+
+// Local subscribers to wallet changes pushed by validators, keyed by wallet.
+type subscriptionTable struct {
+	content map[KademliaID]*walletWatch
+	sync.Mutex
+}
+
+type walletWatch struct {
+	listeners []chan Wallet
+	seen      int // transactions of the newest state delivered, older notifications are duplicates
+	stop      chan struct{}
+}
+
+func newSubscriptionTable() *subscriptionTable {
+	return &subscriptionTable{
+		content: make(map[KademliaID]*walletWatch),
+	}
+}
+
+// Subscribes to changes of the wallet. Its validators push the new state of the wallet whenever
+// they apply a transaction to it, which is delivered on the returned channel once per change.
+// The watch is renewed at the validators every WATCH_LEASE/2 until the returned cancel function
+// is called, which also closes the channel. A slow reader misses intermediate states.
+// Returns an error if none of the wallet's validators holds it.
+func (node *Node) SubscribeWallet(walletID KademliaID) (<-chan Wallet, func(), error) {
+	if node.watch(walletID) == 0 {
+		return nil, nil, errors.New(fmt.Sprintf("no validator holds wallet %v", walletID))
+	}
+	listener := make(chan Wallet, 1)
+	node.subscriptions.Lock()
+	sub, ok := node.subscriptions.content[walletID]
+	if !ok {
+		sub = &walletWatch{stop: make(chan struct{})}
+		node.subscriptions.content[walletID] = sub
+		node.routines.Go("wallet watch", func() { node.renewWatch(walletID, sub.stop) })
+	}
+	sub.listeners = append(sub.listeners, listener)
+	node.subscriptions.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			node.subscriptions.Lock()
+			defer node.subscriptions.Unlock()
+			for i, l := range sub.listeners {
+				if l == listener {
+					sub.listeners = append(sub.listeners[:i], sub.listeners[i+1:]...)
+					break
+				}
+			}
+			close(listener)
+			if len(sub.listeners) == 0 {
+				close(sub.stop)
+				delete(node.subscriptions.content, walletID)
+			}
+		})
+	}
+	return listener, cancel, nil
+}
+
+// Asks each validator of the wallet to notify the node of changes.
+// Returns the number of validators holding the wallet.
+func (node *Node) watch(walletID KademliaID) int {
+	validators := node.FindNode(walletID)
+	accepted := make(chan bool, len(validators))
+	for _, val := range validators {
+		rpc := GenerateRPC(val.IP(), node.Contact)
+		rpc.WatchWallet(walletID)
+		node.routines.Go("watch wallet", func() {
+			res, err := node.Send(rpc)
+			accepted <- err == nil && res.walletStored
+		})
+	}
+	holders := 0
+	for range validators {
+		if <-accepted {
+			holders++
+		}
+	}
+	return holders
+}
+
+// Renews the watch on the wallet until stop is closed or the node shuts down.
+func (node *Node) renewWatch(walletID KademliaID, stop chan struct{}) {
+	ticker := node.timebase.NewTicker(node.config.scaled(WATCH_LEASE) / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-node.Network.listener.Done():
+			return
+		case <-ticker.C():
+			node.watch(walletID)
+		}
+	}
+}
+
+// Pushes the current state of the wallet to its live watchers.
+func (node *Node) notifyWatchers(walletID KademliaID) {
+	watchers := node.watchers.live(walletID, node.timebase.Now())
+	if len(watchers) == 0 {
+		return
+	}
+	wallet, err := node.Ledger().Wallet(walletID)
+	if err != nil {
+		return
+	}
+	for _, con := range watchers {
+		rpc := GenerateRPC(con.IP(), node.Contact)
+		rpc.NotifyWallet(wallet)
+		node.routines.Go("notify watcher", func() { node.Send(rpc) })
+	}
+}
+
+// Response logic for an incoming watch wallet RPC, the watch is only taken if the node holds the wallet.
+func (node *Node) handleWatchWallet(rpc *RPC) {
+	_, err := node.Ledger().Wallet(rpc.accountID)
+	if err == nil {
+		node.watchers.add(rpc.accountID, rpc.sender, node.timebase.Now().Add(node.config.scaled(WATCH_LEASE)))
+	}
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.WatchedWallet(rpc.accountID, err == nil)
+	node.Send(resp)
+}
+
+// Response logic for an incoming notify wallet RPC. Every validator sends the same change, only
+// the first notification of a newer state is passed on to the subscribers.
+func (node *Node) handleNotifyWallet(rpc *RPC) {
+	node.subscriptions.Lock()
+	sub, ok := node.subscriptions.content[rpc.accountID]
+	if ok && rpc.wallet.Transactions > sub.seen {
+		sub.seen = rpc.wallet.Transactions
+		for _, listener := range sub.listeners {
+			// Keep only the newest state for a slow reader.
+			select {
+			case <-listener:
+			default:
+			}
+			listener <- rpc.wallet
+		}
+	}
+	node.subscriptions.Unlock()
+	resp := GenerateResponse(rpc.id, rpc.sender.IP(), node.Contact)
+	resp.NotifiedWallet(rpc.accountID)
+	node.Send(resp)
+}
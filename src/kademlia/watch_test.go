@@ -0,0 +1,62 @@
+package kademlia
+
+import (
+	"log"
+	"main/src/scalegraph"
+	"testing"
+	"time"
+)
+
+func TestSubscribeWallet(t *testing.T) {
+	testName := "TestSubscribeWallet"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	go s.StartServer()
+	nodes := s.SpawnCluster(10, done)
+	<-done
+	defer s.Shutdown()
+
+	if _, _, err := nodes[0].SubscribeWallet(RandomID()); err == nil {
+		log.Printf("[%s] - subscribed to a wallet no validator holds", testName)
+		t.Fail()
+	}
+
+	wallet := RandomID()
+	if err := nodes[1].SubmitWallet(wallet, 10); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	changes, cancel, err := nodes[0].SubscribeWallet(wallet)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	if err := nodes[2].Transfer(scalegraph.MINT_ACCOUNT, wallet, 5); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	select {
+	case state := <-changes:
+		if state.Balance != 15 {
+			log.Printf("[%s] - expected the new balance 15, got %d", testName, state.Balance)
+			t.Fail()
+		}
+	case <-time.After(2 * time.Second):
+		log.Printf("[%s] - no change was pushed", testName)
+		t.FailNow()
+	}
+	// Every validator pushes the change, the subscriber only sees it once.
+	select {
+	case state := <-changes:
+		log.Printf("[%s] - change delivered twice: %+v", testName, state)
+		t.Fail()
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	if _, ok := <-changes; ok {
+		log.Printf("[%s] - channel still open after cancel", testName)
+		t.Fail()
+	}
+}
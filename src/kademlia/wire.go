@@ -0,0 +1,309 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/src/scalegraph"
+	"reflect"
+	"time"
+)
+
+// Exported form of a contact for the wire encoding.
+type wireContact struct {
+	ID   KademliaID
+	IP   [4]byte
+	Port int `json:",omitempty"`
+}
+
+// Exported form of a value handed over to a joining node.
+type wireTransfer struct {
+	Key         KademliaID
+	Data        []byte
+	TTL         time.Duration
+	Replication int `json:",omitempty"`
+}
+
+// Wire form of a RPC, every field a receiver reads. The bookkeeping of the receiver's inbox and
+// the simnet, and the lock channel of in-process account locks, stay behind. The ids and structs
+// most RPCs leave empty are pointers, nil if zero, so that they are not encoded.
+type wireRPC struct {
+	ID               KademliaID
+	Cmd              cmd
+	Network          NetworkID
+	Version          ProtocolVersion
+	MinVersion       ProtocolVersion
+	Role             Role
+	Caps             Capability
+	SenderKey        ed25519.PublicKey `json:",omitempty"`
+	Signature        []byte            `json:",omitempty"`
+	Response         bool
+	Sender           wireContact
+	Receiver         [4]byte
+	Origin           [4]byte
+	FindNodeTarget   *KademliaID                  `json:",omitempty"`
+	FindNodeCount    int                          `json:",omitempty"`
+	FoundNodes       []wireContact                `json:",omitempty"`
+	AccountID        *KademliaID                  `json:",omitempty"`
+	DisplayString    string                       `json:",omitempty"`
+	StoreAccSucc     bool                         `json:",omitempty"`
+	FindAccountSucc  bool                         `json:",omitempty"`
+	BlockID          *KademliaID                  `json:",omitempty"`
+	Transaction      *scalegraph.Transaction      `json:",omitempty"`
+	TransactionID    *KademliaID                  `json:",omitempty"`
+	Payload          []byte                       `json:",omitempty"`
+	SnapshotID       *KademliaID                  `json:",omitempty"`
+	SnapshotOffset   int                          `json:",omitempty"`
+	SnapshotAccounts []scalegraph.AccountSnapshot `json:",omitempty"`
+	SnapshotDone     bool                         `json:",omitempty"`
+	SnapshotFailed   bool                         `json:",omitempty"`
+	AppendSucc       bool                         `json:",omitempty"`
+	Balance          uint64                       `json:",omitempty"`
+	BalanceFound     bool                         `json:",omitempty"`
+	Message          *Message                     `json:",omitempty"`
+	Messages         []Message                    `json:",omitempty"`
+	MessageStored    bool                         `json:",omitempty"`
+	Wallet           *Wallet                      `json:",omitempty"`
+	PublicKey        ed25519.PublicKey            `json:",omitempty"`
+	WalletStored     bool                         `json:",omitempty"`
+	Accepted         bool                         `json:",omitempty"`
+	Commit           bool                         `json:",omitempty"`
+	WalletStates     []walletState                `json:",omitempty"`
+	UnknownCmd       cmd                          `json:",omitempty"`
+	Value            []byte                       `json:",omitempty"`
+	ValueFound       bool                         `json:",omitempty"`
+	ValueCached      bool                         `json:",omitempty"`
+	Replication      int                          `json:",omitempty"`
+	Batch            []wireRPC                    `json:",omitempty"`
+	Publication      *Publication                 `json:",omitempty"`
+	Transfers        []wireTransfer               `json:",omitempty"`
+	Hops             int                          `json:",omitempty"`
+	Path             []wireContact                `json:",omitempty"`
+	JoinChallenge    []byte                       `json:",omitempty"`
+	JoinProof        []byte                       `json:",omitempty"`
+	JoinRejected     bool                         `json:",omitempty"`
+	AuditNonce       []byte                       `json:",omitempty"`
+	AuditProof       []byte                       `json:",omitempty"`
+	MerkleNodes      []merkleNode                 `json:",omitempty"`
+	Session          *KademliaID                  `json:",omitempty"`
+	SessionKey       []byte                       `json:",omitempty"`
+	Sealed           []byte                       `json:",omitempty"`
+}
+
+// Returns a pointer to v, or nil if v is the zero value.
+func nonZero[T any](v T) *T {
+	if reflect.ValueOf(v).IsZero() {
+		return nil
+	}
+	return &v
+}
+
+func toWireContacts(contacts []Contact) []wireContact {
+	if contacts == nil {
+		return nil
+	}
+	res := make([]wireContact, len(contacts))
+	for i, con := range contacts {
+		res[i] = wireContact{con.id, con.ip, con.port}
+	}
+	return res
+}
+
+func fromWireContacts(contacts []wireContact) []Contact {
+	if contacts == nil {
+		return nil
+	}
+	res := make([]Contact, len(contacts))
+	for i, con := range contacts {
+		res[i] = Contact{con.IP, con.ID, con.Port}
+	}
+	return res
+}
+
+func toWire(rpc *RPC) wireRPC {
+	res := wireRPC{
+		ID:               rpc.id,
+		Cmd:              rpc.cmd,
+		Network:          rpc.network,
+		Version:          rpc.version,
+		MinVersion:       rpc.minVersion,
+		Role:             rpc.role,
+		Caps:             rpc.caps,
+		SenderKey:        rpc.senderKey,
+		Signature:        rpc.signature,
+		Response:         rpc.response,
+		Sender:           wireContact{rpc.sender.id, rpc.sender.ip, rpc.sender.port},
+		Receiver:         rpc.receiver,
+		Origin:           rpc.origin,
+		FindNodeCount:    rpc.findNodeCount,
+		FoundNodes:       toWireContacts(rpc.foundNodes),
+		DisplayString:    rpc.displayString,
+		StoreAccSucc:     rpc.storeAccSucc,
+		FindAccountSucc:  rpc.findAccountSucc,
+		Payload:          rpc.payload,
+		SnapshotOffset:   rpc.snapshotOffset,
+		SnapshotAccounts: rpc.snapshotAccounts,
+		SnapshotDone:     rpc.snapshotDone,
+		SnapshotFailed:   rpc.snapshotFailed,
+		AppendSucc:       rpc.appendSucc,
+		Balance:          rpc.balance,
+		BalanceFound:     rpc.balanceFound,
+		Messages:         rpc.messages,
+		MessageStored:    rpc.messageStored,
+		PublicKey:        rpc.publicKey,
+		WalletStored:     rpc.walletStored,
+		Accepted:         rpc.accepted,
+		Commit:           rpc.commit,
+		WalletStates:     rpc.walletStates,
+		UnknownCmd:       rpc.unknownCmd,
+		Value:            rpc.value,
+		ValueFound:       rpc.valueFound,
+		ValueCached:      rpc.valueCached,
+		Replication:      rpc.replication,
+		Hops:             rpc.hops,
+		Path:             toWireContacts(rpc.path),
+		JoinChallenge:    rpc.joinChallenge,
+		JoinProof:        rpc.joinProof,
+		JoinRejected:     rpc.joinRejected,
+		AuditNonce:       rpc.auditNonce,
+		AuditProof:       rpc.auditProof,
+		MerkleNodes:      rpc.merkleNodes,
+		SessionKey:       rpc.sessionKey,
+		Sealed:           rpc.sealed,
+	}
+	res.FindNodeTarget = nonZero(rpc.findNodeTarget)
+	res.AccountID = nonZero(rpc.accountID)
+	res.BlockID = nonZero(rpc.blockID)
+	res.TransactionID = nonZero(rpc.transactionID)
+	res.SnapshotID = nonZero(rpc.snapshotID)
+	res.Session = nonZero(rpc.session)
+	res.Transaction = nonZero(rpc.transaction)
+	res.Message = nonZero(rpc.message)
+	res.Wallet = nonZero(rpc.wallet)
+	res.Publication = nonZero(rpc.publication)
+	if rpc.batch != nil {
+		res.Batch = make([]wireRPC, len(rpc.batch))
+		for i := range rpc.batch {
+			res.Batch[i] = toWire(&rpc.batch[i])
+		}
+	}
+	if rpc.transfers != nil {
+		res.Transfers = make([]wireTransfer, len(rpc.transfers))
+		for i, val := range rpc.transfers {
+			res.Transfers[i] = wireTransfer{val.key, val.data, val.ttl, val.replication}
+		}
+	}
+	return res
+}
+
+func fromWire(dec *wireRPC) RPC {
+	res := RPC{
+		id:               dec.ID,
+		cmd:              dec.Cmd,
+		network:          dec.Network,
+		version:          dec.Version,
+		minVersion:       dec.MinVersion,
+		role:             dec.Role,
+		caps:             dec.Caps,
+		senderKey:        dec.SenderKey,
+		signature:        dec.Signature,
+		response:         dec.Response,
+		sender:           Contact{dec.Sender.IP, dec.Sender.ID, dec.Sender.Port},
+		receiver:         dec.Receiver,
+		origin:           dec.Origin,
+		findNodeCount:    dec.FindNodeCount,
+		foundNodes:       fromWireContacts(dec.FoundNodes),
+		displayString:    dec.DisplayString,
+		storeAccSucc:     dec.StoreAccSucc,
+		findAccountSucc:  dec.FindAccountSucc,
+		payload:          dec.Payload,
+		snapshotOffset:   dec.SnapshotOffset,
+		snapshotAccounts: dec.SnapshotAccounts,
+		snapshotDone:     dec.SnapshotDone,
+		snapshotFailed:   dec.SnapshotFailed,
+		appendSucc:       dec.AppendSucc,
+		balance:          dec.Balance,
+		balanceFound:     dec.BalanceFound,
+		messages:         dec.Messages,
+		messageStored:    dec.MessageStored,
+		publicKey:        dec.PublicKey,
+		walletStored:     dec.WalletStored,
+		accepted:         dec.Accepted,
+		commit:           dec.Commit,
+		walletStates:     dec.WalletStates,
+		unknownCmd:       dec.UnknownCmd,
+		value:            dec.Value,
+		valueFound:       dec.ValueFound,
+		valueCached:      dec.ValueCached,
+		replication:      dec.Replication,
+		hops:             dec.Hops,
+		path:             fromWireContacts(dec.Path),
+		joinChallenge:    dec.JoinChallenge,
+		joinProof:        dec.JoinProof,
+		joinRejected:     dec.JoinRejected,
+		auditNonce:       dec.AuditNonce,
+		auditProof:       dec.AuditProof,
+		merkleNodes:      dec.MerkleNodes,
+		sessionKey:       dec.SessionKey,
+		sealed:           dec.Sealed,
+	}
+	if dec.FindNodeTarget != nil {
+		res.findNodeTarget = *dec.FindNodeTarget
+	}
+	if dec.AccountID != nil {
+		res.accountID = *dec.AccountID
+	}
+	if dec.BlockID != nil {
+		res.blockID = *dec.BlockID
+	}
+	if dec.TransactionID != nil {
+		res.transactionID = *dec.TransactionID
+	}
+	if dec.SnapshotID != nil {
+		res.snapshotID = *dec.SnapshotID
+	}
+	if dec.Session != nil {
+		res.session = *dec.Session
+	}
+	if dec.Transaction != nil {
+		res.transaction = *dec.Transaction
+	}
+	if dec.Message != nil {
+		res.message = *dec.Message
+	}
+	if dec.Wallet != nil {
+		res.wallet = *dec.Wallet
+	}
+	if dec.Publication != nil {
+		res.publication = *dec.Publication
+	}
+	if dec.Batch != nil {
+		res.batch = make([]RPC, len(dec.Batch))
+		for i := range dec.Batch {
+			res.batch[i] = fromWire(&dec.Batch[i])
+		}
+	}
+	if dec.Transfers != nil {
+		res.transfers = make([]valueTransfer, len(dec.Transfers))
+		for i, val := range dec.Transfers {
+			res.transfers[i] = valueTransfer{val.Key, val.Data, val.TTL, val.Replication}
+		}
+	}
+	return res
+}
+
+// Returns the RPC encoded for the wire, see DecodeRPC.
+func EncodeRPC(rpc RPC) ([]byte, error) {
+	return json.Marshal(toWire(&rpc))
+}
+
+// Returns the RPC encoded in data by EncodeRPC.
+// Returns an error if data does not hold an encoded RPC.
+func DecodeRPC(data []byte) (RPC, error) {
+	var dec wireRPC
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return RPC{}, errors.New(fmt.Sprintf("malformed rpc: %v", err))
+	}
+	return fromWire(&dec), nil
+}
@@ -0,0 +1,66 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"log"
+	"main/src/scalegraph"
+	"testing"
+	"time"
+)
+
+func TestWireRoundTrip(t *testing.T) {
+	testName := "TestWireRoundTrip"
+	identity, _ := GenerateIdentity(0)
+	sender := NewContact([4]byte{10, 0, 0, 1}, RandomID())
+	sender.port = PORT
+	trx := scalegraph.NewTransferWithNonce(scalegraph.RandomID(), scalegraph.RandomID(), 12, 3)
+	block := scalegraph.FirstBlock(scalegraph.RandomID(), trx)
+
+	rpc := GenerateRPC([4]byte{10, 0, 0, 2}, sender)
+	rpc.FindNode(RandomID())
+	rpc.network = 7
+	rpc.origin = sender.IP()
+	rpc.version = PROTOCOL_VERSION
+	rpc.findNodeCount = 5
+	rpc.foundNodes = []Contact{NewContact([4]byte{10, 0, 0, 3}, RandomID()), NewContact([4]byte{10, 0, 0, 4}, RandomID())}
+	rpc.accountID = RandomID()
+	rpc.transaction = *trx.Copy()
+	rpc.snapshotAccounts = []scalegraph.AccountSnapshot{{ID: scalegraph.RandomID(), Blocks: []scalegraph.Block{*block, *block.NewBlock(scalegraph.RandomID(), trx)}}}
+	rpc.message = Message{RandomID(), RandomID(), RandomID(), []byte("hello"), time.Now().Add(time.Minute)}
+	rpc.wallet = Wallet{RandomID(), 40, 2, identity.PublicKey, 1, 3}
+	rpc.walletStates = []walletState{{RandomID(), identity.PublicKey, 2, []scalegraph.Transaction{*trx.Copy()}}}
+	rpc.transfers = []valueTransfer{{RandomID(), []byte("value"), time.Minute, 4}}
+	rpc.publication = Publication{RandomID(), TopicID("topic"), RandomID(), []byte("data")}
+	rpc.path = []Contact{sender}
+	rpc.merkleNodes = []merkleNode{{2, 1, sha256.Sum256([]byte("subtree"))}}
+	rpc.sessionKey = []byte("key")
+	sub := GenerateRPC([4]byte{10, 0, 0, 2}, sender)
+	sub.Ping()
+	rpc.batch = []RPC{sub}
+	rpc.senderKey = identity.PublicKey
+	rpc.sign(identity.PrivateKey)
+
+	data, err := EncodeRPC(rpc)
+	if err != nil {
+		log.Printf("[%s] - failed to encode rpc: %v", testName, err)
+		t.FailNow()
+	}
+	dec, err := DecodeRPC(data)
+	if err != nil {
+		log.Printf("[%s] - failed to decode rpc: %v", testName, err)
+		t.FailNow()
+	}
+	if !bytes.Equal(dec.signingData(), rpc.signingData()) || dec.origin != rpc.origin {
+		log.Printf("[%s] - decoded rpc differs from the encoded one", testName)
+		t.Fail()
+	}
+	if err := dec.verifySignature(); err != nil {
+		log.Printf("[%s] - decoded rpc lost its signature: %v", testName, err)
+		t.Fail()
+	}
+	if _, err := DecodeRPC(data[:len(data)/2]); err == nil {
+		log.Printf("[%s] - decoded a truncated rpc", testName)
+		t.Fail()
+	}
+}
@@ -0,0 +1,151 @@
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_ROUTE_WORKERS = 256     // goroutines routing RPCs unless set with SetRouteWorkers
+	MAX_ROUTE_WORKERS     = 1 << 16 // upper bound on the route workers of a simnet
+)
+
+// Sets the number of goroutines that route RPCs, must be called before the server is started.
+// RPCs wait in a queue as deep as the simnet's listener until a worker is free, and senders block
+// once the queue is full, so a flood of RPCs slows its senders down instead of spawning goroutines
+// without bound. Zero routes every RPC on a goroutine of its own, the behavior before the pool.
+// Returns an error if the server is already running or count is out of range.
+func (simnet *Simnet) SetRouteWorkers(count int) error {
+	if count < 0 || count > MAX_ROUTE_WORKERS {
+		return errors.New(fmt.Sprintf("route workers must be between 0 and %d, got %d", MAX_ROUTE_WORKERS, count))
+	}
+	if simnet.started.Load() {
+		return errors.New("route workers must be set before the server is started")
+	}
+	simnet.routeWorkers = count
+	return nil
+}
+
+// Returns the number of goroutines that route RPCs, zero if every RPC gets its own.
+func (simnet *Simnet) RouteWorkers() int {
+	return simnet.routeWorkers
+}
+
+// Returns the number of RPCs waiting for a route worker.
+func (simnet *Simnet) RouteQueueDepth() int {
+	return len(simnet.routeQueue)
+}
+
+// Starts the route workers, called by StartServer.
+func (simnet *Simnet) startRouteWorkers() {
+	for range simnet.routeWorkers {
+		simnet.routines.Go("route worker", func() {
+			for {
+				select {
+				case <-simnet.shutdown:
+					return
+				case rpc := <-simnet.routeQueue:
+					simnet.Route(rpc)
+				}
+			}
+		})
+	}
+}
+
+// Hands the RPC to a route worker, blocking while the queue is full.
+func (simnet *Simnet) dispatch(rpc RPC) {
+	if simnet.routeWorkers == 0 {
+		pooled := pooledRPC(rpc)
+		simnet.routines.Go("route", func() {
+			simnet.Route(*pooled)
+			releaseRPC(pooled)
+		})
+		return
+	}
+	select {
+	case simnet.routeQueue <- rpc:
+		simnet.stats.recordRouteQueue(len(simnet.routeQueue))
+	case <-simnet.shutdown:
+	}
+}
+
+// RPCs held back for a delay in order of when they are due, see hold.
+type heldRPCs struct {
+	content []heldRPC
+	wake    chan struct{} // signals releaseHeld that an earlier RPC may be due
+	sync.Mutex
+}
+
+type heldRPC struct {
+	due time.Time
+	rpc RPC
+}
+
+// Holds the RPC back for delay, after which it is handed to a route worker again. No worker
+// waits out the delay, so a slow link does not hold up routing between other nodes.
+func (simnet *Simnet) hold(rpc RPC, delay time.Duration) {
+	due := simnet.timebase.Now().Add(delay)
+	simnet.held.Lock()
+	// RPCs due at the same time keep the order they were held in
+	i, _ := slices.BinarySearchFunc(simnet.held.content, due, func(h heldRPC, t time.Time) int {
+		if h.due.After(t) {
+			return 1
+		}
+		return -1
+	})
+	simnet.held.content = slices.Insert(simnet.held.content, i, heldRPC{due, rpc})
+	simnet.held.Unlock()
+	select {
+	case simnet.held.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Returns the number of RPCs held back for a delay.
+func (simnet *Simnet) HeldRPCs() int {
+	simnet.held.Lock()
+	defer simnet.held.Unlock()
+	return len(simnet.held.content)
+}
+
+// Dispatches held RPCs once they are due, on a single timer of the simnet's timebase set for the
+// earliest of them. Started by StartServer, returns once the simnet is shut down.
+func (simnet *Simnet) releaseHeld() {
+	for {
+		simnet.held.Lock()
+		now := simnet.timebase.Now()
+		n := 0
+		for n < len(simnet.held.content) && !simnet.held.content[n].due.After(now) {
+			n++
+		}
+		due := slices.Clone(simnet.held.content[:n])
+		simnet.held.content = slices.Delete(simnet.held.content, 0, n)
+		var timer Timer
+		var fire <-chan time.Time
+		if len(simnet.held.content) > 0 {
+			timer = simnet.timebase.NewTimer(simnet.held.content[0].due.Sub(now))
+			fire = timer.C()
+		}
+		simnet.held.Unlock()
+
+		for _, h := range due {
+			simnet.dispatch(h.rpc)
+		}
+		select {
+		case <-simnet.shutdown:
+		case <-simnet.held.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-simnet.shutdown:
+			return
+		default:
+		}
+	}
+}
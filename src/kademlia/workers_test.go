@@ -0,0 +1,137 @@
+package kademlia
+
+import (
+	"log"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRouteWorkers(t *testing.T) {
+	testName := "TestRouteWorkers"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	if s.RouteWorkers() != DEFAULT_ROUTE_WORKERS {
+		log.Printf("[%s] - expected %d route workers by default, got %d", testName, DEFAULT_ROUTE_WORKERS, s.RouteWorkers())
+		t.Fail()
+	}
+	if err := s.SetRouteWorkers(-1); err == nil {
+		log.Printf("[%s] - accepted a negative number of route workers", testName)
+		t.Fail()
+	}
+	if err := s.SetRouteWorkers(2); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	for _, target := range nodes[:5] {
+		res := nodes[len(nodes)-1].FindNode(target.ID())
+		if len(res) == 0 || res[0] != target.Contact {
+			log.Printf("[%s] - lookup through two route workers did not find %v", testName, target.ID())
+			t.Fail()
+		}
+	}
+	stats := s.Stats()
+	if stats.RouteQueuePeak < 1 || stats.RouteQueue > stats.RouteQueuePeak {
+		log.Printf("[%s] - unexpected route queue depth %d with peak %d", testName, stats.RouteQueue, stats.RouteQueuePeak)
+		t.Fail()
+	}
+	if err := s.SetRouteWorkers(8); err == nil {
+		log.Printf("[%s] - changed the route workers of a running server", testName)
+		t.Fail()
+	}
+}
+
+func TestRouteWorkersDelayedLink(t *testing.T) {
+	testName := "TestRouteWorkersDelayedLink"
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.SetRouteWorkers(4)
+	go s.StartServer()
+	nodes := s.SpawnCluster(4, done)
+	<-done
+	defer s.Shutdown()
+
+	// far more delayed pings than route workers are in flight on the slow link
+	slow, fast := nodes[0], nodes[1]
+	delay := 200 * time.Millisecond
+	s.SetLinkPolicy(slow.IP(), fast.IP(), LinkPolicy{Delay: delay})
+	for range 32 {
+		go slow.Ping(fast.IP())
+	}
+	for s.HeldRPCs() < 32 {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	if !nodes[2].Ping(nodes[3].IP()) {
+		log.Printf("[%s] - ping between unrelated nodes failed", testName)
+		t.FailNow()
+	}
+	if elapsed := time.Since(start); elapsed > delay/4 {
+		log.Printf("[%s] - ping between unrelated nodes took %v while the slow link was saturated", testName, elapsed)
+		t.Fail()
+	}
+}
+
+// Pings between random nodes of a 20 node cluster from many goroutines at once, reporting the
+// most goroutines alive during the run. The count includes the benchmark's own senders and the
+// nodes' handlers, only the simnet's routing is bounded by the pool.
+func benchmarkRouting(b *testing.B, workers int) {
+	done := make(chan struct{}, 1)
+	s := NewServer(false, 0.0)
+	s.Silence()
+	s.SetRouteWorkers(workers)
+	go s.StartServer()
+	nodes := s.SpawnCluster(20, done)
+	<-done
+	defer s.Shutdown()
+
+	peak := 0
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				peak = max(peak, runtime.NumGoroutine())
+			}
+		}
+	}()
+	b.SetParallelism(64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			from := nodes[rand.Intn(len(nodes))]
+			rpc := GenerateRPC(nodes[rand.Intn(len(nodes))].IP(), from.Contact)
+			rpc.Ping()
+			from.Send(rpc)
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	<-sampled
+	b.ReportMetric(float64(peak), "peak-goroutines")
+}
+
+func BenchmarkRoutingGoroutinePerRPC(b *testing.B) {
+	benchmarkRouting(b, 0)
+}
+
+func BenchmarkRoutingWorkers16(b *testing.B) {
+	benchmarkRouting(b, 16)
+}
+
+func BenchmarkRoutingWorkers256(b *testing.B) {
+	benchmarkRouting(b, DEFAULT_ROUTE_WORKERS)
+}
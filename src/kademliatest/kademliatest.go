@@ -0,0 +1,136 @@
+// Package kademliatest provides helpers for tests that run a simulated network: spawning a cluster
+// that is shut down with the test, waiting for the routing tables to converge, wiring fixed
+// topologies and asserting that nodes find each other.
+package kademliatest
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/src/kademlia"
+	"slices"
+	"testing"
+	"time"
+)
+
+const POLL_INTERVAL = 20 * time.Millisecond // how often WaitForConvergence checks the routing tables
+
+// Returns a started simnet with a cluster of size nodes, the master node not included, whose
+// logging is silenced. The simnet is shut down when the test finishes, see Cleanup.
+func NewCluster(t testing.TB, size int) (*kademlia.Simnet, []*kademlia.Node) {
+	simnet, nodes := spawn(size)
+	Cleanup(t, simnet)
+	return simnet, nodes
+}
+
+// Shuts the simnet down when the test finishes and fails the test if any goroutine of the
+// simnet or its nodes is still running after the shutdown grace period.
+func Cleanup(t testing.TB, simnet *kademlia.Simnet) {
+	t.Cleanup(func() {
+		err := simnet.Shutdown()
+		if err != nil {
+			log.Printf("[%s] - %s", t.Name(), err.Error())
+			t.Fail()
+		}
+	})
+}
+
+// Waits until every live node holds the K closest live nodes of its own network in its routing
+// table, the state lookups rely on to find any node.
+// Returns an error naming how many nodes are still missing close contacts once timeout has passed.
+func WaitForConvergence(simnet *kademlia.Simnet, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := unconverged(simnet)
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New(fmt.Sprintf("%d nodes had not converged after %v", pending, timeout))
+		}
+		time.Sleep(POLL_INTERVAL)
+	}
+}
+
+// Returns the number of live nodes missing one of their K closest live nodes from their routing table.
+func unconverged(simnet *kademlia.Simnet) int {
+	nodes := simnet.AllNodePointers()
+	pending := 0
+	for _, n := range nodes {
+		peers := make([]kademlia.Contact, 0, len(nodes))
+		for _, peer := range nodes {
+			if peer != n && peer.NetworkID() == n.NetworkID() && peer.Role() != kademlia.OBSERVER {
+				peers = append(peers, peer.Contact)
+			}
+		}
+		kademlia.SortContactsByDistance(&peers, n.ID())
+		known := n.AllContacts()
+		for _, peer := range peers[:min(len(peers), n.Config().Replication)] {
+			if !slices.ContainsFunc(known, func(con kademlia.Contact) bool { return con.ID() == peer.ID() }) {
+				pending++
+				break
+			}
+		}
+	}
+	return pending
+}
+
+// Fails the test unless a lookup from one node returns the other as the closest contact.
+// Returns true if the node was found.
+func AssertReachable(t testing.TB, from *kademlia.Node, to *kademlia.Node) bool {
+	t.Helper()
+	found := from.FindNode(to.ID())
+	if len(found) == 0 || found[0].ID() != to.ID() {
+		log.Printf("[%s] - node %v did not find node %v", t.Name(), from.ID(), to.ID())
+		t.Fail()
+		return false
+	}
+	return true
+}
+
+// Returns a started simnet with n nodes, the master node not included, whose routing tables
+// form a line: every node knows only the nodes before and after it. The master node knows no
+// one and no one knows it. The nodes learn further contacts as they talk, as in any Kademlia
+// network, so the line holds only until the first lookup. Shut the simnet down when done, see Cleanup.
+func BuildLineTopology(n int) (*kademlia.Simnet, []*kademlia.Node) {
+	simnet, nodes := spawn(n)
+	rewire(simnet, nodes, func(i int, j int) bool { return j == i-1 || j == i+1 })
+	return simnet, nodes
+}
+
+// Returns a started simnet with n nodes, the master node not included, whose routing tables
+// form a star: the first node knows every other node its buckets have room for and the others
+// know only the first.
+// The master node is left out as in BuildLineTopology.
+func BuildStarTopology(n int) (*kademlia.Simnet, []*kademlia.Node) {
+	simnet, nodes := spawn(n)
+	rewire(simnet, nodes, func(i int, j int) bool { return i != j && (i == 0 || j == 0) })
+	return simnet, nodes
+}
+
+func spawn(size int) (*kademlia.Simnet, []*kademlia.Node) {
+	done := make(chan struct{}, 1)
+	simnet := kademlia.NewServer(false, 0.0)
+	simnet.Silence()
+	go simnet.StartServer()
+	nodes := simnet.SpawnCluster(size, done)
+	<-done
+	return simnet, nodes
+}
+
+// Empties every routing table, the master node's included, and adds nodes[j] to the routing
+// table of nodes[i] wherever linked(i, j) holds.
+func rewire(simnet *kademlia.Simnet, nodes []*kademlia.Node, linked func(i int, j int) bool) {
+	for _, n := range simnet.AllNodePointers() {
+		for _, con := range n.AllContacts() {
+			n.RemoveContact(con)
+		}
+	}
+	for i, n := range nodes {
+		for j, peer := range nodes {
+			if linked(i, j) {
+				n.AddContact(peer.Contact)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package kademliatest
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestConvergence(t *testing.T) {
+	testName := "TestConvergence"
+	s, nodes := NewCluster(t, 20)
+	if err := WaitForConvergence(s, 10*time.Second); err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	for i := 1; i < len(nodes); i += 4 {
+		AssertReachable(t, nodes[0], nodes[i])
+	}
+}
+
+func TestTopologies(t *testing.T) {
+	testName := "TestTopologies"
+	s, nodes := BuildLineTopology(6)
+	Cleanup(t, s)
+	for i, n := range nodes {
+		contacts := n.AllContacts()
+		if (i == 0 || i == len(nodes)-1) != (len(contacts) == 1) || len(contacts) > 2 {
+			log.Printf("[%s] - node %d of the line knows %d nodes", testName, i, len(contacts))
+			t.Fail()
+		}
+	}
+	for _, n := range s.AllNodePointers() {
+		if master := s.MasterNode(); n.IP() == master.IP() && len(n.AllContacts()) != 0 {
+			log.Printf("[%s] - the master node was wired into the line", testName)
+			t.Fail()
+		}
+	}
+
+	s, nodes = BuildStarTopology(6)
+	Cleanup(t, s)
+	if len(nodes[0].AllContacts()) != 5 {
+		log.Printf("[%s] - the hub knows %d nodes", testName, len(nodes[0].AllContacts()))
+		t.Fail()
+	}
+	for _, n := range nodes[1:] {
+		if contacts := n.AllContacts(); len(contacts) != 1 || contacts[0].ID() != nodes[0].ID() {
+			log.Printf("[%s] - a leaf knows %d nodes", testName, len(contacts))
+			t.Fail()
+		}
+	}
+	// lookups through the hub reach every leaf
+	AssertReachable(t, nodes[1], nodes[5])
+}
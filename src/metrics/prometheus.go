@@ -0,0 +1,279 @@
+package metrics
+
+import (
+	"main/src/kademlia"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus implements kademlia.Metrics on top of a prometheus registry.
+type Prometheus struct {
+	registry    *prometheus.Registry
+	rpcSent     *prometheus.CounterVec
+	rpcFailed   *prometheus.CounterVec
+	rpcLatency  *prometheus.HistogramVec
+	rpcHandled  *prometheus.CounterVec
+	rpcRouted   *prometheus.CounterVec
+	rpcDropped  *prometheus.CounterVec
+	undelivered *prometheus.CounterVec
+	expired     prometheus.Counter
+	queueWait   *prometheus.HistogramVec
+	queueDepth  prometheus.Histogram
+	queueDrops  *prometheus.CounterVec
+	rejected    *prometheus.CounterVec
+	rateLimited *prometheus.CounterVec
+	reconciled  prometheus.Counter
+	divergent   prometheus.Counter
+	repaired    prometheus.Counter
+	replication prometheus.Histogram
+	underRep    prometheus.Counter
+	lookups     prometheus.Counter
+	hops        prometheus.Histogram
+	lookupTime  prometheus.Histogram
+}
+
+// Creates a exporter with its own registry and registers all scalegraph collectors on it.
+func NewPrometheus() *Prometheus {
+	prom := Prometheus{
+		registry: prometheus.NewRegistry(),
+		rpcSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_rpc_sent_total",
+			Help: "Request RPCs sent, by command.",
+		}, []string{"cmd"}),
+		rpcFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_rpc_failed_total",
+			Help: "Request RPCs that did not receive a response, by command.",
+		}, []string{"cmd"}),
+		rpcLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scalegraph_rpc_latency_seconds",
+			Help:    "Round trip time of answered request RPCs, by command.",
+			Buckets: prometheus.ExponentialBuckets(0.00005, 2, 16),
+		}, []string{"cmd"}),
+		rpcHandled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_rpc_handled_total",
+			Help: "Incoming request RPCs dispatched to handlers, by command.",
+		}, []string{"cmd"}),
+		rpcRouted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_simnet_routed_total",
+			Help: "RPCs routed by the simulated network, by command.",
+		}, []string{"cmd"}),
+		rpcDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_simnet_dropped_total",
+			Help: "RPCs dropped by the simulated network, by command.",
+		}, []string{"cmd"}),
+		undelivered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_response_undelivered_total",
+			Help: "Responses that arrived after the requester stopped waiting, by command.",
+		}, []string{"cmd"}),
+		expired: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "scalegraph_response_expired_total",
+			Help: "Response table entries expired by the response janitor without a response.",
+		}),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scalegraph_handler_queue_wait_seconds",
+			Help:    "Time request RPCs spent in the receiving node's inbound queue, by command.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 2, 16),
+		}, []string{"cmd"}),
+		queueDepth: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "scalegraph_handler_queue_depth",
+			Help:    "Inbound queue depth seen when a node takes a RPC off its queue.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+		queueDrops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_handler_queue_dropped_total",
+			Help: "RPCs discarded by the receiving node's overflow policy, by command.",
+		}, []string{"cmd"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_rpc_rejected_total",
+			Help: "Incoming RPCs dropped because their sender could not prove its id or their signature was invalid, by command.",
+		}, []string{"cmd"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scalegraph_rpc_rate_limited_total",
+			Help: "Incoming request RPCs dropped because their sender exceeded its rate limit, by command.",
+		}, []string{"cmd"}),
+		reconciled: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "scalegraph_anti_entropy_rounds_total",
+			Help: "Anti-entropy reconciliations completed between co-validators.",
+		}),
+		divergent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "scalegraph_anti_entropy_divergent_total",
+			Help: "Anti-entropy reconciliations that found the two replicas disagreeing.",
+		}),
+		repaired: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "scalegraph_anti_entropy_repaired_total",
+			Help: "Wallets added or brought up to date by anti-entropy.",
+		}),
+		replication: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "scalegraph_value_replication",
+			Help:    "Nodes that stored each value stored by a node.",
+			Buckets: prometheus.LinearBuckets(1, 1, 20),
+		}),
+		underRep: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "scalegraph_value_under_replicated_total",
+			Help: "Value stores that reached fewer nodes than their replication.",
+		}),
+		lookups: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "scalegraph_lookups_total",
+			Help: "Completed node lookups.",
+		}),
+		hops: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "scalegraph_lookup_hops",
+			Help:    "Query rounds per node lookup.",
+			Buckets: prometheus.LinearBuckets(1, 1, 12),
+		}),
+		lookupTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "scalegraph_lookup_duration_seconds",
+			Help:    "Wall clock duration of node lookups.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}),
+	}
+	prom.registry.MustRegister(
+		prom.rpcSent,
+		prom.rpcFailed,
+		prom.rpcLatency,
+		prom.rpcHandled,
+		prom.rpcRouted,
+		prom.rpcDropped,
+		prom.undelivered,
+		prom.expired,
+		prom.queueWait,
+		prom.queueDepth,
+		prom.queueDrops,
+		prom.rejected,
+		prom.rateLimited,
+		prom.reconciled,
+		prom.divergent,
+		prom.repaired,
+		prom.replication,
+		prom.underRep,
+		prom.lookups,
+		prom.hops,
+		prom.lookupTime,
+	)
+	return &prom
+}
+
+func (prom *Prometheus) RPCSent(command kademlia.Command, latency time.Duration, err error) {
+	prom.rpcSent.WithLabelValues(command.String()).Inc()
+	if err != nil {
+		prom.rpcFailed.WithLabelValues(command.String()).Inc()
+		return
+	}
+	prom.rpcLatency.WithLabelValues(command.String()).Observe(latency.Seconds())
+}
+
+func (prom *Prometheus) RPCHandled(command kademlia.Command) {
+	prom.rpcHandled.WithLabelValues(command.String()).Inc()
+}
+
+func (prom *Prometheus) RPCRouted(command kademlia.Command, dropped bool) {
+	prom.rpcRouted.WithLabelValues(command.String()).Inc()
+	if dropped {
+		prom.rpcDropped.WithLabelValues(command.String()).Inc()
+	}
+}
+
+func (prom *Prometheus) LookupCompleted(hops int, duration time.Duration) {
+	prom.lookups.Inc()
+	prom.hops.Observe(float64(hops))
+	prom.lookupTime.Observe(duration.Seconds())
+}
+
+func (prom *Prometheus) ResponseUndelivered(command kademlia.Command) {
+	prom.undelivered.WithLabelValues(command.String()).Inc()
+}
+
+func (prom *Prometheus) ResponsesExpired(count int) {
+	prom.expired.Add(float64(count))
+}
+
+func (prom *Prometheus) HandlerQueued(command kademlia.Command, wait time.Duration, depth int) {
+	prom.queueWait.WithLabelValues(command.String()).Observe(wait.Seconds())
+	prom.queueDepth.Observe(float64(depth))
+}
+
+func (prom *Prometheus) HandlerDropped(command kademlia.Command) {
+	prom.queueDrops.WithLabelValues(command.String()).Inc()
+}
+
+func (prom *Prometheus) RPCRejected(command kademlia.Command) {
+	prom.rejected.WithLabelValues(command.String()).Inc()
+}
+
+func (prom *Prometheus) RPCRateLimited(command kademlia.Command) {
+	prom.rateLimited.WithLabelValues(command.String()).Inc()
+}
+
+func (prom *Prometheus) WalletsReconciled(leaves int, repaired int) {
+	prom.reconciled.Inc()
+	if leaves > 0 {
+		prom.divergent.Inc()
+	}
+	prom.repaired.Add(float64(repaired))
+}
+
+func (prom *Prometheus) ValueStored(replication int, stored int) {
+	prom.replication.Observe(float64(stored))
+	if stored < replication {
+		prom.underRep.Inc()
+	}
+}
+
+// Registers gauges that are read from the simnet on every scrape: active nodes, RPCs waiting
+// for a route worker, the total number of contacts held in each bucket index across all nodes
+// and the total number of stale contacts, see kademlia.Node.Health.
+func (prom *Prometheus) ObserveSimnet(simnet *kademlia.Simnet) {
+	prom.registry.MustRegister(&simnetCollector{
+		simnet: simnet,
+		active: prometheus.NewDesc("scalegraph_simnet_active_nodes", "Nodes currently attached to the simulated network.", nil, nil),
+		bucket: prometheus.NewDesc("scalegraph_bucket_occupancy", "Contacts held in each bucket index, summed over all nodes.", []string{"bucket"}, nil),
+		stale:  prometheus.NewDesc("scalegraph_stale_contacts", "Contacts not heard from within the stale threshold, summed over all nodes.", nil, nil),
+		queue:  prometheus.NewDesc("scalegraph_simnet_route_queue_depth", "RPCs waiting for a route worker of the simulated network.", nil, nil),
+	})
+}
+
+// Returns a handler serving the registry in the prometheus exposition format.
+func (prom *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(prom.registry, promhttp.HandlerOpts{})
+}
+
+type simnetCollector struct {
+	simnet *kademlia.Simnet
+	active *prometheus.Desc
+	bucket *prometheus.Desc
+	stale  *prometheus.Desc
+	queue  *prometheus.Desc
+}
+
+func (col *simnetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- col.active
+	ch <- col.bucket
+	ch <- col.stale
+	ch <- col.queue
+}
+
+func (col *simnetCollector) Collect(ch chan<- prometheus.Metric) {
+	nodes := col.simnet.AllNodePointers()
+	ch <- prometheus.MustNewConstMetric(col.active, prometheus.GaugeValue, float64(len(nodes)))
+	ch <- prometheus.MustNewConstMetric(col.queue, prometheus.GaugeValue, float64(col.simnet.RouteQueueDepth()))
+	total := make([]int, kademlia.KEYSPACE)
+	stale := 0
+	for _, n := range nodes {
+		health := n.Health()
+		for i, occupancy := range health.Occupancy {
+			total[i] += occupancy
+		}
+		stale += health.Stale
+	}
+	ch <- prometheus.MustNewConstMetric(col.stale, prometheus.GaugeValue, float64(stale))
+	for i, occupancy := range total {
+		if occupancy == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(col.bucket, prometheus.GaugeValue, float64(occupancy), strconv.Itoa(i))
+	}
+}
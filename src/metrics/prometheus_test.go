@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"io"
+	"log"
+	"main/src/kademlia"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusScrape(t *testing.T) {
+	testName := "TestPrometheusScrape"
+	prom := NewPrometheus()
+	s := kademlia.NewServer(false, 0.0)
+	s.SetMetrics(prom)
+	prom.ObserveSimnet(s)
+	prom.RPCSent(kademlia.PING, time.Millisecond, nil)
+	prom.LookupCompleted(3, time.Millisecond)
+
+	server := httptest.NewServer(prom.Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, name := range []string{"scalegraph_rpc_sent_total", "scalegraph_lookup_hops", "scalegraph_simnet_active_nodes", "scalegraph_stale_contacts", "scalegraph_simnet_route_queue_depth", "scalegraph_anti_entropy_rounds_total", "scalegraph_value_replication", "scalegraph_response_expired_total"} {
+		if !strings.Contains(string(body), name) {
+			log.Printf("[%s] - scrape is missing %s", testName, name)
+			t.Fail()
+		}
+	}
+}
@@ -1,17 +1,23 @@
 package scalegraph
 
 import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"sync"
 )
 
 type Account struct {
 	sync.RWMutex
-	id [5]uint32
+	id          ID
+	publicKey   ed25519.PublicKey // key spends from the account must be signed with, nil if spends are unsigned
+	replication int               // closest nodes the account is kept at, zero for the network's replication
 	BlockChain
 }
 
-func NewAccount(id [5]uint32) *Account {
+func NewAccount(id ID) *Account {
 	acc := Account{
 		id:         id,
 		BlockChain: *NewBlockChain(),
@@ -29,6 +35,170 @@ func (acc *Account) VerifyTransaction(trx *Transaction, consensusLimit int) bool
 	return true
 }
 
+// Returns the funds received minus the funds sent by the account's transactions.
+func (acc *Account) Balance() uint64 {
+	acc.BlockChain.RLock()
+	defer acc.BlockChain.RUnlock()
+	return acc.balance()
+}
+
+// Requires spends from the account to be signed by the private key matching key.
+func (acc *Account) SetPublicKey(key ed25519.PublicKey) {
+	acc.Lock()
+	defer acc.Unlock()
+	acc.publicKey = append(ed25519.PublicKey(nil), key...)
+}
+
+func (acc *Account) PublicKey() ed25519.PublicKey {
+	acc.RLock()
+	defer acc.RUnlock()
+	return acc.publicKey
+}
+
+// Sets the number of closest nodes the account is kept at, zero keeps it at the network's replication.
+func (acc *Account) SetReplication(replication int) {
+	acc.Lock()
+	defer acc.Unlock()
+	acc.replication = replication
+}
+
+func (acc *Account) Replication() int {
+	acc.RLock()
+	defer acc.RUnlock()
+	return acc.replication
+}
+
+// Returns an error if trx spends from the account without a valid signature by the account's key.
+// Accounts without a key accept unsigned spends.
+func (acc *Account) CheckSignature(trx *Transaction) error {
+	key := acc.PublicKey()
+	if trx.sendingAccount != acc.id || key == nil {
+		return nil
+	}
+	if !trx.VerifySignature(key) {
+		return errors.New(fmt.Sprintf("transaction %v is not signed by account %v", trx.id, acc.id))
+	}
+	return nil
+}
+
+// Returns copies of the account's transactions in chain order.
+func (acc *Account) Transactions() []Transaction {
+	acc.BlockChain.RLock()
+	defer acc.BlockChain.RUnlock()
+	res := make([]Transaction, 0, len(acc.chain))
+	for _, b := range acc.chain {
+		res = append(res, *b.Transaction.Copy())
+	}
+	return res
+}
+
+// Returns the number of transactions in the account's chain.
+func (acc *Account) Len() int {
+	acc.BlockChain.RLock()
+	defer acc.BlockChain.RUnlock()
+	return len(acc.chain)
+}
+
+// Returns a hash of the account's state: nonce, then the account's id, key and transactions in
+// chain order. Replicas of the account holding the same transactions hash alike, and a fresh nonce
+// keeps the hash from being computed before it is asked for. Block ids are left out, every replica
+// picks its own.
+func (acc *Account) Digest(nonce []byte) [sha256.Size]byte {
+	key := acc.PublicKey()
+	acc.BlockChain.RLock()
+	defer acc.BlockChain.RUnlock()
+	hash := sha256.New()
+	appendBytes := func(b []byte) {
+		hash.Write(binary.BigEndian.AppendUint32(nil, uint32(len(b))))
+		hash.Write(b)
+	}
+	appendBytes(nonce)
+	for _, word := range acc.id {
+		hash.Write(binary.BigEndian.AppendUint32(nil, word))
+	}
+	appendBytes(key)
+	hash.Write(binary.BigEndian.AppendUint32(nil, uint32(len(acc.chain))))
+	for _, b := range acc.chain {
+		appendBytes(b.Transaction.SigningData())
+		appendBytes(b.Transaction.Signature())
+	}
+	var res [sha256.Size]byte
+	hash.Sum(res[:0])
+	return res
+}
+
+func (acc *Account) ID() ID {
+	return acc.id
+}
+
+// Returns the highest nonce of a transfer sent by the account, zero if it has sent none.
+func (acc *Account) Nonce() uint64 {
+	acc.BlockChain.RLock()
+	defer acc.BlockChain.RUnlock()
+	return acc.nonce()
+}
+
+func (acc *Account) nonce() uint64 {
+	var res uint64
+	for _, b := range acc.chain {
+		if b.sendingAccount == acc.id {
+			res = max(res, b.nonce)
+		}
+	}
+	return res
+}
+
+func (acc *Account) balance() uint64 {
+	var credit, debit uint64
+	for _, b := range acc.chain {
+		if b.receivingAccount == acc.id {
+			credit += b.amount
+		}
+		if b.sendingAccount == acc.id {
+			debit += b.amount
+		}
+	}
+	return credit - debit
+}
+
+// Appends the transaction to the account's chain if it passes the default rules.
+func (acc *Account) Apply(trx *Transaction) error {
+	return acc.ApplyWithRules(trx, DefaultRules())
+}
+
+// Appends the transaction to the account's chain if the account takes part in it, a spend is
+// signed by the account's key, see CheckSignature, and every rule accepts it. The rules are
+// evaluated in order while the chain is locked.
+func (acc *Account) ApplyWithRules(trx *Transaction, rules []Rule) error {
+	if trx.sendingAccount != acc.id && trx.receivingAccount != acc.id {
+		return errors.New(fmt.Sprintf("account %v is not part of transaction %v", acc.id, trx.id))
+	}
+	if err := acc.CheckSignature(trx); err != nil {
+		return err
+	}
+	acc.BlockChain.Lock()
+	defer acc.BlockChain.Unlock()
+	err := Validate(lockedAccount{acc}, trx, rules)
+	if err != nil {
+		return err
+	}
+	acc.addBlock(trx)
+	return nil
+}
+
+// Returns the error ApplyWithRules would return for the transaction, without appending it.
+func (acc *Account) CheckWithRules(trx *Transaction, rules []Rule) error {
+	if trx.sendingAccount != acc.id && trx.receivingAccount != acc.id {
+		return errors.New(fmt.Sprintf("account %v is not part of transaction %v", acc.id, trx.id))
+	}
+	if err := acc.CheckSignature(trx); err != nil {
+		return err
+	}
+	acc.BlockChain.RLock()
+	defer acc.BlockChain.RUnlock()
+	return Validate(lockedAccount{acc}, trx, rules)
+}
+
 func (acc *Account) Display() string {
 	acc.Lock()
 	defer acc.Unlock()
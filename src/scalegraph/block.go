@@ -1,31 +1,45 @@
 package scalegraph
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 type Block struct {
-	id     [5]uint32
-	prevID [5]uint32
+	id     ID
+	prevID ID
+	seq    uint64 // position in the package wide write order, used by snapshots
 	*Transaction
 }
 
-func FirstBlock(id [5]uint32, trx *Transaction) *Block {
+func FirstBlock(id ID, trx *Transaction) *Block {
 	block := Block{
 		id:          id,
-		prevID:      [5]uint32{0, 0, 0, 0, 0},
+		prevID:      ID{},
+		seq:         writeClock.Add(1),
 		Transaction: trx,
 	}
 	return &block
 }
 
-func (block *Block) NewBlock(id [5]uint32, trx *Transaction) *Block {
+func (block *Block) NewBlock(id ID, trx *Transaction) *Block {
 	b := Block{
 		id:          id,
 		prevID:      block.id,
+		seq:         writeClock.Add(1),
 		Transaction: trx,
 	}
 	return &b
 }
 
+func (block *Block) ID() ID {
+	return block.id
+}
+
+func (block *Block) PrevID() ID {
+	return block.prevID
+}
+
 func (block *Block) Display() string {
 	disp := ""
 	disp += fmt.Sprintf("block id: %10v\nprevious block id: %10v\n", block.id, block.prevID)
@@ -33,3 +47,26 @@ func (block *Block) Display() string {
 
 	return disp
 }
+
+// Exported form of a block for its JSON encoding.
+type blockJSON struct {
+	ID          ID
+	PrevID      ID
+	Transaction *Transaction
+}
+
+// Encodes the block with its transaction. The write order is local to the process, a decoded
+// block is ordered as if it was written when it was decoded.
+func (block Block) MarshalJSON() ([]byte, error) {
+	return json.Marshal(blockJSON{block.id, block.prevID, block.Transaction})
+}
+
+func (block *Block) UnmarshalJSON(data []byte) error {
+	var dec blockJSON
+	err := json.Unmarshal(data, &dec)
+	if err != nil {
+		return err
+	}
+	*block = Block{dec.ID, dec.PrevID, writeClock.Add(1), dec.Transaction}
+	return nil
+}
@@ -21,17 +21,21 @@ func NewBlockChain() *BlockChain {
 func (bc *BlockChain) AddBlock(trx *Transaction) {
 	bc.Lock()
 	defer bc.Unlock()
+	bc.addBlock(trx)
+}
+
+// Appends a block without taking the lock, the caller must hold it.
+func (bc *BlockChain) addBlock(trx *Transaction) {
 	var newBlock Block
 	if len(bc.chain) == 0 {
 		// if there are no previous blocks the chain must be started
 		newBlock = *(FirstBlock(RandomID(), trx))
 	} else {
-		newBlock = *(bc.chain[len(bc.chain)].NewBlock(RandomID(), trx))
+		newBlock = *(bc.chain[len(bc.chain)-1].NewBlock(RandomID(), trx))
 	}
 	bc.chain = append(bc.chain, newBlock)
 }
 
-
 func (bc *BlockChain) Display() string {
 	bc.Lock()
 	defer bc.Unlock()
@@ -0,0 +1,149 @@
+package scalegraph
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Read only view of an account handed to validation rules while the account is locked.
+type AccountState interface {
+	ID() ID
+	Balance() uint64
+	Len() int                  // number of blocks in the account's chain
+	Contains(trxID ID) bool    // true if the transaction is already in the chain
+	Last() (Transaction, bool) // the most recent transaction, false if the chain is empty
+	Nonce() uint64             // the highest nonce of a transfer sent by the account, zero if none
+}
+
+// A validation rule that validators evaluate before appending a transaction to an account.
+// Check returns an error describing why the transaction is rejected, or nil to accept it.
+type Rule interface {
+	Name() string
+	Check(state AccountState, trx *Transaction) error
+}
+
+type ruleFunc struct {
+	name  string
+	check func(state AccountState, trx *Transaction) error
+}
+
+// Creates a rule from a function, for application rules that need no state of their own.
+func NewRule(name string, check func(state AccountState, trx *Transaction) error) Rule {
+	return ruleFunc{name, check}
+}
+
+func (rule ruleFunc) Name() string {
+	return rule.name
+}
+
+func (rule ruleFunc) Check(state AccountState, trx *Transaction) error {
+	return rule.check(state, trx)
+}
+
+// Rejects transfers sent by the account that exceed its balance.
+type BalanceRule struct{}
+
+func (BalanceRule) Name() string {
+	return "balance"
+}
+
+func (BalanceRule) Check(state AccountState, trx *Transaction) error {
+	if trx.sendingAccount != state.ID() || trx.receivingAccount == state.ID() {
+		return nil
+	}
+	if state.Balance() < trx.amount {
+		return errors.New(fmt.Sprintf("insufficient funds in account %v", state.ID()))
+	}
+	return nil
+}
+
+// Rejects transactions that have already been appended to the account, so a replayed
+// transaction is never applied twice.
+type SequenceRule struct{}
+
+func (SequenceRule) Name() string {
+	return "sequence"
+}
+
+func (SequenceRule) Check(state AccountState, trx *Transaction) error {
+	if state.Contains(trx.id) {
+		return errors.New(fmt.Sprintf("transaction %v already applied to account %v", trx.id, state.ID()))
+	}
+	return nil
+}
+
+// Requires the transfers sent by an account to carry consecutive nonces starting at one, which
+// rejects replays and spends submitted out of order. Transfers with nonce zero are unsequenced
+// and only accepted until the account has sent its first sequenced transfer.
+type NonceRule struct{}
+
+func (NonceRule) Name() string {
+	return "nonce"
+}
+
+func (NonceRule) Check(state AccountState, trx *Transaction) error {
+	if trx.sendingAccount != state.ID() {
+		return nil
+	}
+	last := state.Nonce()
+	if trx.nonce == 0 && last == 0 {
+		return nil
+	}
+	if trx.nonce != last+1 {
+		return errors.New(fmt.Sprintf("account %v expects nonce %d, got %d", state.ID(), last+1, trx.nonce))
+	}
+	return nil
+}
+
+// Returns the rules validators evaluate unless configured otherwise.
+func DefaultRules() []Rule {
+	return []Rule{BalanceRule{}, SequenceRule{}, NonceRule{}}
+}
+
+// Evaluates the rules in order and returns the error of the first rule that rejects the transaction.
+func Validate(state AccountState, trx *Transaction, rules []Rule) error {
+	for _, rule := range rules {
+		err := rule.Check(state, trx)
+		if err != nil {
+			return errors.New(fmt.Sprintf("rule %s: %s", rule.Name(), err.Error()))
+		}
+	}
+	return nil
+}
+
+// AccountState over an account whose chain lock is held by the caller.
+type lockedAccount struct {
+	acc *Account
+}
+
+func (state lockedAccount) ID() ID {
+	return state.acc.id
+}
+
+func (state lockedAccount) Balance() uint64 {
+	return state.acc.balance()
+}
+
+func (state lockedAccount) Len() int {
+	return len(state.acc.chain)
+}
+
+func (state lockedAccount) Contains(trxID ID) bool {
+	for _, b := range state.acc.chain {
+		if b.Transaction.id == trxID {
+			return true
+		}
+	}
+	return false
+}
+
+func (state lockedAccount) Nonce() uint64 {
+	return state.acc.nonce()
+}
+
+func (state lockedAccount) Last() (Transaction, bool) {
+	if len(state.acc.chain) == 0 {
+		return Transaction{}, false
+	}
+	return *state.acc.chain[len(state.acc.chain)-1].Transaction.Copy(), true
+}
@@ -1,24 +1,29 @@
 package scalegraph
 
 import (
+	"crypto/ed25519"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 )
 
 type Scalegraph struct {
 	sync.RWMutex
-	content map[[5]uint32]*Account
+	content map[ID]*Account
+	shared  bool // content is referenced by a snapshot and must be copied before writing
+	rules   []Rule
 }
 
 func NewScaleGraph() *Scalegraph {
 	scale := Scalegraph{
-		content: make(map[[5]uint32]*Account),
+		content: make(map[ID]*Account),
+		rules:   DefaultRules(),
 	}
 	return &scale
 }
 
-func (scale *Scalegraph) AddAccount(id [5]uint32) error {
+func (scale *Scalegraph) AddAccount(id ID) error {
 	scale.Lock()
 	defer scale.Unlock()
 
@@ -27,28 +32,68 @@ func (scale *Scalegraph) AddAccount(id [5]uint32) error {
 		return errors.New("account already exists")
 	}
 
+	scale.detach()
 	scale.content[id] = NewAccount(id)
 
 	return nil
 }
 
+// Rebuilds an account from another replica's transactions, applying them in order under the
+// configured rules. The rebuilt account replaces the stored one only if it extends it.
+// Replication is the number of closest nodes the account is kept at, see Account.SetReplication.
+// Returns true if the account was installed, or an error if the transactions do not validate
+// or the stored account has diverged from them.
+func (scale *Scalegraph) RestoreAccount(id ID, key ed25519.PublicKey, replication int, trxs []Transaction) (bool, error) {
+	acc := NewAccount(id)
+	if key != nil {
+		acc.SetPublicKey(key)
+	}
+	acc.SetReplication(replication)
+	rules := scale.Rules()
+	for i := range trxs {
+		err := acc.ApplyWithRules(trxs[i].Copy(), rules)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	scale.Lock()
+	defer scale.Unlock()
+	existing, ok := scale.content[id]
+	if ok {
+		held := existing.Transactions()
+		if len(held) >= len(trxs) {
+			return false, nil
+		}
+		for i := range held {
+			if held[i].id != trxs[i].id {
+				return false, errors.New(fmt.Sprintf("account %v has diverged at transaction %d", id, i))
+			}
+		}
+	}
+	scale.detach()
+	scale.content[id] = acc
+	return true, nil
+}
+
 func (scale *Scalegraph) StoredAccountCount() int {
 	scale.RLock()
 	defer scale.RUnlock()
 	return len(scale.content)
 }
 
-func (scale *Scalegraph) StoredAccounts() [][5]uint32 {
+func (scale *Scalegraph) StoredAccounts() []ID {
 	scale.RLock()
 	defer scale.RUnlock()
-	res := make([][5]uint32, 0, len(scale.content))
+	res := make([]ID, 0, len(scale.content))
 	for _, accID := range scale.content {
 		res = append(res, accID.id)
 	}
+	slices.SortFunc(res, compareID)
 	return res
 }
 
-func (scale *Scalegraph) FindAccount(id [5]uint32) (*Account, error) {
+func (scale *Scalegraph) FindAccount(id ID) (*Account, error) {
 	scale.RLock()
 	defer scale.RUnlock()
 
@@ -59,10 +104,58 @@ func (scale *Scalegraph) FindAccount(id [5]uint32) (*Account, error) {
 	return account, nil
 }
 
-func (scale *Scalegraph) RemoveAccount(id [5]uint32) {
+// Replaces the validation rules applied to incoming transactions.
+func (scale *Scalegraph) SetRules(rules ...Rule) {
+	scale.Lock()
+	defer scale.Unlock()
+	scale.rules = append([]Rule(nil), rules...)
+}
+
+// Appends a rule that is evaluated after the existing ones.
+func (scale *Scalegraph) AddRule(rule Rule) {
 	scale.Lock()
 	defer scale.Unlock()
+	scale.rules = append(scale.rules[:len(scale.rules):len(scale.rules)], rule)
+}
 
+func (scale *Scalegraph) Rules() []Rule {
+	scale.RLock()
+	defer scale.RUnlock()
+	return append([]Rule(nil), scale.rules...)
+}
+
+// Applies the transaction to the stored account using the configured rules.
+func (scale *Scalegraph) ApplyTransaction(accID ID, trx *Transaction) error {
+	acc, err := scale.FindAccount(accID)
+	if err != nil {
+		return err
+	}
+	return acc.ApplyWithRules(trx, scale.Rules())
+}
+
+// Checks the transaction against the stored account and the configured rules without applying it.
+func (scale *Scalegraph) CheckTransaction(accID ID, trx *Transaction) error {
+	acc, err := scale.FindAccount(accID)
+	if err != nil {
+		return err
+	}
+	return acc.CheckWithRules(trx, scale.Rules())
+}
+
+// Checks the signature of a transaction spending from the stored account, see Account.CheckSignature.
+func (scale *Scalegraph) CheckSignature(accID ID, trx *Transaction) error {
+	acc, err := scale.FindAccount(accID)
+	if err != nil {
+		return err
+	}
+	return acc.CheckSignature(trx)
+}
+
+func (scale *Scalegraph) RemoveAccount(id ID) {
+	scale.Lock()
+	defer scale.Unlock()
+
+	scale.detach()
 	delete(scale.content, id)
 }
 
@@ -70,9 +163,14 @@ func (scale *Scalegraph) Display() string {
 	scale.Lock()
 	defer scale.Unlock()
 
+	ids := make([]ID, 0, len(scale.content))
+	for id := range scale.content {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, compareID)
 	view := ""
-	for id, acc := range scale.content {
-		view += fmt.Sprintf("Account id: %v\ncontent: %v\n", id, acc)
+	for _, id := range ids {
+		view += fmt.Sprintf("Account id: %v\ncontent: %v\n", id, scale.content[id])
 	}
 
 	return view
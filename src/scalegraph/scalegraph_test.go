@@ -1,6 +1,8 @@
 package scalegraph
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"log"
 	"testing"
 )
@@ -17,3 +19,195 @@ func TestScalegraphDisplay(t *testing.T) {
 		log.Printf("[%s]\n%s", testName, view)
 	}
 }
+
+func TestAccountDigest(t *testing.T) {
+	testName := "TestAccountDigest"
+	id := RandomID()
+	original, replica := NewAccount(id), NewAccount(id)
+	deposit := NewTransfer(MINT_ACCOUNT, id, 100)
+	original.Apply(deposit)
+	replica.Apply(deposit.Copy())
+	nonce := []byte("nonce")
+	if original.Digest(nonce) != replica.Digest(nonce) {
+		log.Printf("[%s] - replicas with the same transactions hash differently", testName)
+		t.Fail()
+	}
+	if original.Digest(nonce) == original.Digest([]byte("other")) {
+		log.Printf("[%s] - digest does not depend on the nonce", testName)
+		t.Fail()
+	}
+	replica.Apply(NewTransfer(id, RandomID(), 10))
+	if original.Digest(nonce) == replica.Digest(nonce) {
+		log.Printf("[%s] - diverged replicas hash alike", testName)
+		t.Fail()
+	}
+}
+
+func TestAccountApply(t *testing.T) {
+	testName := "TestAccountApply"
+	first := NewAccount(RandomID())
+	second := RandomID()
+
+	err := first.Apply(NewTransfer(MINT_ACCOUNT, first.id, 100))
+	if err != nil || first.Balance() != 100 {
+		log.Printf("[%s] - expected balance 100 after mint, got %d: %v", testName, first.Balance(), err)
+		t.Fail()
+	}
+	err = first.Apply(NewTransfer(first.id, second, 30))
+	if err != nil || first.Balance() != 70 {
+		log.Printf("[%s] - expected balance 70 after transfer, got %d: %v", testName, first.Balance(), err)
+		t.Fail()
+	}
+	if first.Apply(NewTransfer(first.id, second, 71)) == nil {
+		log.Printf("[%s] - overdraft was applied", testName)
+		t.Fail()
+	}
+	if first.Apply(NewTransfer(second, RandomID(), 1)) == nil {
+		log.Printf("[%s] - applied a transaction the account is not part of", testName)
+		t.Fail()
+	}
+	if len(first.chain) != 2 {
+		log.Printf("[%s] - expected 2 blocks, found %d", testName, len(first.chain))
+		t.Fail()
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	testName := "TestValidationRules"
+	sg := NewScaleGraph()
+	id := RandomID()
+	sg.AddAccount(id)
+	mint := NewTransfer(MINT_ACCOUNT, id, 50)
+	if sg.ApplyTransaction(id, mint) != nil {
+		log.Printf("[%s] - mint rejected", testName)
+		t.Fail()
+	}
+	if sg.ApplyTransaction(id, mint) == nil {
+		log.Printf("[%s] - replayed transaction accepted by the sequence rule", testName)
+		t.Fail()
+	}
+
+	evaluated := make([]string, 0, 2)
+	limit := NewRule("limit", func(state AccountState, trx *Transaction) error {
+		evaluated = append(evaluated, "limit")
+		if trx.Amount() > 10 {
+			return errors.New("amount above limit")
+		}
+		return nil
+	})
+	sg.AddRule(limit)
+	err := sg.ApplyTransaction(id, NewTransfer(id, RandomID(), 20))
+	if err == nil || len(evaluated) != 1 {
+		log.Printf("[%s] - expected the custom rule to reject the transfer, got %v", testName, err)
+		t.Fail()
+	}
+	if sg.ApplyTransaction(id, NewTransfer(id, RandomID(), 60)) == nil || len(evaluated) != 1 {
+		log.Printf("[%s] - expected the balance rule to reject before the custom rule ran", testName)
+		t.Fail()
+	}
+
+	sg.SetRules(limit)
+	if sg.ApplyTransaction(id, NewTransfer(id, RandomID(), 5)) != nil {
+		log.Printf("[%s] - transfer within the limit rejected", testName)
+		t.Fail()
+	}
+	acc, _ := sg.FindAccount(id)
+	if acc.Balance() != 45 {
+		log.Printf("[%s] - expected balance 45, found %d", testName, acc.Balance())
+		t.Fail()
+	}
+}
+
+func TestTransactionSignature(t *testing.T) {
+	testName := "TestTransactionSignature"
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	acc := NewAccount(RandomID())
+	acc.SetPublicKey(pub)
+
+	unsigned := NewTransferWithNonce(acc.ID(), RandomID(), 5, 1)
+	if acc.CheckSignature(unsigned) == nil {
+		log.Printf("[%s] - unsigned spend was accepted", testName)
+		t.Fail()
+	}
+	signed := unsigned.Copy()
+	signed.Sign(priv)
+	if err := acc.CheckSignature(signed); err != nil {
+		log.Printf("[%s] - signed spend was rejected: %v", testName, err)
+		t.Fail()
+	}
+	if signed.VerifySignature(otherPub) {
+		log.Printf("[%s] - signature verified with the wrong key", testName)
+		t.Fail()
+	}
+	tampered := NewTransferWithNonce(acc.ID(), signed.Receiver(), 500, 1)
+	tampered.id = signed.id
+	tampered.signature = signed.Signature()
+	if acc.CheckSignature(tampered) == nil {
+		log.Printf("[%s] - tampered amount kept a valid signature", testName)
+		t.Fail()
+	}
+	deposit := NewTransfer(MINT_ACCOUNT, acc.ID(), 5)
+	if err := acc.CheckSignature(deposit); err != nil {
+		log.Printf("[%s] - incoming transfer required a signature: %v", testName, err)
+		t.Fail()
+	}
+	// applying checks the signature as well, whichever path the transaction arrives on
+	if err := acc.ApplyWithRules(deposit, DefaultRules()); err != nil {
+		log.Printf("[%s] - deposit was not applied: %v", testName, err)
+		t.FailNow()
+	}
+	if acc.ApplyWithRules(tampered, DefaultRules()) == nil || acc.CheckWithRules(unsigned, DefaultRules()) == nil {
+		log.Printf("[%s] - spend without a valid signature passed the rules", testName)
+		t.Fail()
+	}
+	if err := acc.ApplyWithRules(signed, DefaultRules()); err != nil || acc.Balance() != 0 {
+		log.Printf("[%s] - signed spend was not applied: %v", testName, err)
+		t.Fail()
+	}
+}
+
+func TestStoredAccountsSorted(t *testing.T) {
+	testName := "TestStoredAccountsSorted"
+	sg := NewScaleGraph()
+	for range 50 {
+		sg.AddAccount(RandomID())
+	}
+	ids := sg.StoredAccounts()
+	for i := 1; i < len(ids); i++ {
+		if compareID(ids[i-1], ids[i]) >= 0 {
+			log.Printf("[%s] - accounts %d and %d are out of order", testName, i-1, i)
+			t.Fail()
+		}
+	}
+}
+
+func TestNonceRule(t *testing.T) {
+	testName := "TestNonceRule"
+	acc := NewAccount(RandomID())
+	acc.Apply(NewTransfer(MINT_ACCOUNT, acc.ID(), 100))
+	if err := acc.Apply(NewTransfer(acc.ID(), RandomID(), 1)); err != nil {
+		log.Printf("[%s] - unsequenced spend rejected before any sequenced spend: %v", testName, err)
+		t.Fail()
+	}
+	if acc.Apply(NewTransferWithNonce(acc.ID(), RandomID(), 1, 2)) == nil {
+		log.Printf("[%s] - skipped nonce was accepted", testName)
+		t.Fail()
+	}
+	if err := acc.Apply(NewTransferWithNonce(acc.ID(), RandomID(), 1, 1)); err != nil {
+		log.Printf("[%s] - first nonce was rejected: %v", testName, err)
+		t.Fail()
+	}
+	if acc.Apply(NewTransferWithNonce(acc.ID(), RandomID(), 1, 1)) == nil {
+		log.Printf("[%s] - reused nonce was accepted", testName)
+		t.Fail()
+	}
+	if acc.Apply(NewTransfer(acc.ID(), RandomID(), 1)) == nil {
+		log.Printf("[%s] - unsequenced spend accepted after a sequenced spend", testName)
+		t.Fail()
+	}
+	if acc.Nonce() != 1 {
+		log.Printf("[%s] - expected nonce 1, got %d", testName, acc.Nonce())
+		t.Fail()
+	}
+}
@@ -0,0 +1,111 @@
+package scalegraph
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Package wide write order, every block is stamped with the next value so a snapshot can tell
+// which blocks were written after it was taken.
+var writeClock atomic.Uint64
+
+// Immutable copy of an account's state at the time a snapshot was taken.
+type AccountSnapshot struct {
+	ID     ID
+	Blocks []Block
+}
+
+// Consistent view of all accounts in a scalegraph at one point in time.
+// Taking a snapshot is constant time: the account map is shared with the scalegraph and copied
+// only by the next write, and blocks appended after the snapshot are filtered out by their
+// write order, so neither readers nor writers block each other while the snapshot is read.
+type Snapshot struct {
+	content map[ID]*Account
+	seq     uint64
+	ids     []ID
+	sorted  sync.Once // sorts ids on the first read, chunks of a snapshot may be read concurrently
+}
+
+// Returns a snapshot of every account currently stored.
+func (scale *Scalegraph) Snapshot() *Snapshot {
+	scale.Lock()
+	defer scale.Unlock()
+	scale.shared = true
+	return &Snapshot{
+		content: scale.content,
+		seq:     writeClock.Load(),
+	}
+}
+
+// Copies the account map if it is shared with a snapshot, must be called with the write lock held.
+func (scale *Scalegraph) detach() {
+	if !scale.shared {
+		return
+	}
+	content := make(map[ID]*Account, len(scale.content)+1)
+	for id, acc := range scale.content {
+		content[id] = acc
+	}
+	scale.content = content
+	scale.shared = false
+}
+
+// Returns the number of accounts in the snapshot.
+func (snap *Snapshot) Len() int {
+	return len(snap.content)
+}
+
+// Returns the snapshot account ids in ascending order.
+func (snap *Snapshot) IDs() []ID {
+	snap.sorted.Do(func() {
+		snap.ids = make([]ID, 0, len(snap.content))
+		for id := range snap.content {
+			snap.ids = append(snap.ids, id)
+		}
+		slices.SortFunc(snap.ids, compareID)
+	})
+	return snap.ids
+}
+
+// Returns up to count accounts starting at offset, in ascending id order.
+func (snap *Snapshot) Read(offset int, count int) []AccountSnapshot {
+	ids := snap.IDs()
+	if offset < 0 || offset >= len(ids) {
+		return []AccountSnapshot{}
+	}
+	end := min(offset+count, len(ids))
+	res := make([]AccountSnapshot, 0, end-offset)
+	for _, id := range ids[offset:end] {
+		res = append(res, snap.content[id].snapshot(snap.seq))
+	}
+	return res
+}
+
+// Copies the blocks written at or before seq.
+func (acc *Account) snapshot(seq uint64) AccountSnapshot {
+	acc.BlockChain.RLock()
+	defer acc.BlockChain.RUnlock()
+	blocks := make([]Block, 0, len(acc.chain))
+	for _, b := range acc.chain {
+		if b.seq > seq {
+			break
+		}
+		blocks = append(blocks, b)
+	}
+	return AccountSnapshot{
+		ID:     acc.id,
+		Blocks: blocks,
+	}
+}
+
+func compareID(a ID, b ID) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
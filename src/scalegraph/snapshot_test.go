@@ -0,0 +1,83 @@
+package scalegraph
+
+import (
+	"log"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotIsolation(t *testing.T) {
+	testName := "TestSnapshotIsolation"
+	sg := NewScaleGraph()
+	first := RandomID()
+	second := RandomID()
+	sg.AddAccount(first)
+	sg.AddAccount(second)
+	acc, _ := sg.FindAccount(first)
+	acc.AddBlock(NewTransaction(first, second))
+
+	snap := sg.Snapshot()
+	sg.AddAccount(RandomID())
+	sg.RemoveAccount(second)
+	acc.AddBlock(NewTransaction(second, first))
+
+	if snap.Len() != 2 {
+		log.Printf("[%s] - expected 2 accounts in snapshot, found %d", testName, snap.Len())
+		t.Fail()
+	}
+	if sg.StoredAccountCount() != 2 {
+		log.Printf("[%s] - expected 2 stored accounts after writes, found %d", testName, sg.StoredAccountCount())
+		t.Fail()
+	}
+	for _, accSnap := range snap.Read(0, 10) {
+		if accSnap.ID == first && len(accSnap.Blocks) != 1 {
+			log.Printf("[%s] - expected 1 block in snapshot, found %d", testName, len(accSnap.Blocks))
+			t.Fail()
+		}
+	}
+}
+
+func TestSnapshotReadOrder(t *testing.T) {
+	testName := "TestSnapshotReadOrder"
+	sg := NewScaleGraph()
+	for range 10 {
+		sg.AddAccount(RandomID())
+	}
+	snap := sg.Snapshot()
+	res := append(snap.Read(0, 4), snap.Read(4, 10)...)
+	if len(res) != 10 {
+		log.Printf("[%s] - expected 10 accounts, found %d", testName, len(res))
+		t.FailNow()
+	}
+	for i := 1; i < len(res); i++ {
+		if compareID(res[i-1].ID, res[i].ID) >= 0 {
+			log.Printf("[%s] - accounts out of order at index %d", testName, i)
+			t.Fail()
+		}
+	}
+}
+
+func TestSnapshotConcurrentReads(t *testing.T) {
+	testName := "TestSnapshotConcurrentReads"
+	sg := NewScaleGraph()
+	for range 100 {
+		sg.AddAccount(RandomID())
+	}
+	snap := sg.Snapshot()
+	var wg sync.WaitGroup
+	reads := make([][]AccountSnapshot, 8)
+	for i := range reads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reads[i] = snap.Read(0, 100)
+		}()
+	}
+	wg.Wait()
+	for i := range reads {
+		if len(reads[i]) != 100 || reads[i][0].ID != reads[0][0].ID {
+			log.Printf("[%s] - concurrent read %d returned %d accounts", testName, i, len(reads[i]))
+			t.Fail()
+		}
+	}
+}
@@ -1,20 +1,32 @@
 package scalegraph
 
-import "fmt"
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// Transfers sent from the mint account create funds rather than move them, they are the only way
+// to give a wallet its opening balance.
+var MINT_ACCOUNT = ID{}
 
 // The transaction ID is used as a unique token for the transaction as it is highly
 // improbable that two matching ID's are generated randomly.
 // Valiators refer to the sending accounts validator nodes while confirmers refer to the receivers
 // validator nodes.
 type Transaction struct {
-	id               [5]uint32
-	sendingAccount   [5]uint32
-	receivingAccount [5]uint32
-	validators       [][5]uint32 // validators for sending account
-	confirmers       [][5]uint32 // validators for receiving account
+	id               ID
+	sendingAccount   ID
+	receivingAccount ID
+	amount           uint64
+	nonce            uint64 // sender chosen sequence number
+	signature        []byte // ed25519 signature by the sending wallet's key, see Sign
+	validators       []ID   // validators for sending account
+	confirmers       []ID   // validators for receiving account
 }
 
-func NewTransaction(sender [5]uint32, receriver [5]uint32) *Transaction {
+func NewTransaction(sender ID, receriver ID) *Transaction {
 	trx := Transaction{
 		id:               RandomID(),
 		sendingAccount:   sender,
@@ -23,17 +35,84 @@ func NewTransaction(sender [5]uint32, receriver [5]uint32) *Transaction {
 	return &trx
 }
 
+// Creates a transaction moving amount from the sending to the receiving account.
+func NewTransfer(sender ID, receiver ID, amount uint64) *Transaction {
+	trx := NewTransaction(sender, receiver)
+	trx.amount = amount
+	return trx
+}
+
+// Creates a transfer carrying the sender's nonce.
+func NewTransferWithNonce(sender ID, receiver ID, amount uint64, nonce uint64) *Transaction {
+	trx := NewTransfer(sender, receiver, amount)
+	trx.nonce = nonce
+	return trx
+}
+
+func (trx *Transaction) ID() ID {
+	return trx.id
+}
+
+func (trx *Transaction) Sender() ID {
+	return trx.sendingAccount
+}
+
+func (trx *Transaction) Receiver() ID {
+	return trx.receivingAccount
+}
+
+func (trx *Transaction) Amount() uint64 {
+	return trx.amount
+}
+
+func (trx *Transaction) Nonce() uint64 {
+	return trx.nonce
+}
+
+func (trx *Transaction) Signature() []byte {
+	return trx.signature
+}
+
+// Returns the bytes covered by the signature: the id, both accounts, the amount and the nonce.
+func (trx *Transaction) SigningData() []byte {
+	data := make([]byte, 0, 3*ID_WORDS*4+2*8)
+	for _, id := range []ID{trx.id, trx.sendingAccount, trx.receivingAccount} {
+		for _, word := range id {
+			data = binary.BigEndian.AppendUint32(data, word)
+		}
+	}
+	data = binary.BigEndian.AppendUint64(data, trx.amount)
+	data = binary.BigEndian.AppendUint64(data, trx.nonce)
+	return data
+}
+
+// Signs the transaction with the sending wallet's private key.
+func (trx *Transaction) Sign(key ed25519.PrivateKey) {
+	trx.signature = ed25519.Sign(key, trx.SigningData())
+}
+
+// Returns true if the transaction carries a valid signature by key.
+func (trx *Transaction) VerifySignature(key ed25519.PublicKey) bool {
+	if len(key) != ed25519.PublicKeySize || len(trx.signature) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(key, trx.SigningData(), trx.signature)
+}
+
 // Creates a copy of a transaction, this is needed to have copies of the slice's contents
 // and not just the pointers to the slices.
 func (trx *Transaction) Copy() *Transaction {
-	copyValidators := make([][5]uint32, 0, len(trx.validators))
+	copyValidators := make([]ID, 0, len(trx.validators))
 	copyValidators = append(copyValidators, trx.validators...)
-	copyConfirmers := make([][5]uint32, 0, len(trx.confirmers))
+	copyConfirmers := make([]ID, 0, len(trx.confirmers))
 	copyConfirmers = append(copyConfirmers, trx.confirmers...)
 	newTrx := Transaction{
 		id:               trx.id,
 		sendingAccount:   trx.sendingAccount,
 		receivingAccount: trx.receivingAccount,
+		amount:           trx.amount,
+		nonce:            trx.nonce,
+		signature:        append([]byte(nil), trx.signature...),
 		validators:       copyValidators,
 		confirmers:       copyConfirmers,
 	}
@@ -42,6 +121,33 @@ func (trx *Transaction) Copy() *Transaction {
 
 func (trx *Transaction) Display() string {
 	disp := ""
-	disp += fmt.Sprintf("id: %10v\nsending account: %10v\nreceiving account: %10v", trx.id, trx.sendingAccount, trx.receivingAccount)
+	disp += fmt.Sprintf("id: %10v\nsending account: %10v\nreceiving account: %10v\namount: %d\nnonce: %d", trx.id, trx.sendingAccount, trx.receivingAccount, trx.amount, trx.nonce)
 	return disp
 }
+
+// Exported form of a transaction for its JSON encoding.
+type transactionJSON struct {
+	ID         ID
+	Sender     ID
+	Receiver   ID
+	Amount     uint64
+	Nonce      uint64
+	Signature  []byte `json:",omitempty"`
+	Validators []ID   `json:",omitempty"`
+	Confirmers []ID   `json:",omitempty"`
+}
+
+// Encodes every field of the transaction, so a decoded transaction is identical to the original.
+func (trx Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(transactionJSON{trx.id, trx.sendingAccount, trx.receivingAccount, trx.amount, trx.nonce, trx.signature, trx.validators, trx.confirmers})
+}
+
+func (trx *Transaction) UnmarshalJSON(data []byte) error {
+	var dec transactionJSON
+	err := json.Unmarshal(data, &dec)
+	if err != nil {
+		return err
+	}
+	*trx = Transaction{dec.ID, dec.Sender, dec.Receiver, dec.Amount, dec.Nonce, dec.Signature, dec.Validators, dec.Confirmers}
+	return nil
+}
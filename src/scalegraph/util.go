@@ -5,6 +5,12 @@ import (
 	"math/rand"
 )
 
+const ID_WORDS = 5 // 32 bit words per id, kademlia.KademliaID is declared with the same width
+
+// Identifier of accounts, blocks and transactions, most significant word first.
+// An alias rather than a defined type, so that kademlia ids are passed without conversion.
+type ID = [ID_WORDS]uint32
+
 // returns a pseudo-random uint32 in the range (min, max]
 func RandU32(min uint32, max uint32) (uint32, error) {
 	if min >= max {
@@ -17,10 +23,10 @@ func RandU32(min uint32, max uint32) (uint32, error) {
 }
 
 // Returns a randomly generated id.
-func RandomID() [5]uint32 {
-	var res [5]uint32
-	for res == [5]uint32{0, 0, 0, 0, 0} {
-		for i := 0; i < 5; i++ {
+func RandomID() ID {
+	var res ID
+	for res == (ID{}) {
+		for i := range res {
 			res[i] = rand.Uint32()
 		}
 	}
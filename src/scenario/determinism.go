@@ -0,0 +1,98 @@
+package scenario
+
+import (
+	"fmt"
+	"main/src/kademlia"
+	"slices"
+	"time"
+)
+
+// The order sensitive record of a run that CheckDeterminism compares: the nodes the simnet
+// spawned and shut down, the outcome of every step, and the live nodes and metrics at the end.
+// RPC traffic and routing tables are left out, they depend on the interleaving of the nodes'
+// goroutines, which is not under the seed's control.
+type Trace []string
+
+// Returned by CheckDeterminism when two runs of a script with the same seed differ.
+type DivergenceError struct {
+	Seed   int64
+	Index  int    // first entry of the traces that differs
+	First  string // the entry in the first run, empty if its trace ended before Index
+	Second string // the entry in the second run, empty if its trace ended before Index
+}
+
+func (err *DivergenceError) Error() string {
+	return fmt.Sprintf("runs with seed %d diverge at trace entry %d: %q, then %q", err.Seed, err.Index, err.First, err.Second)
+}
+
+// Runs the script twice with the same seed, one run after the other, and compares their traces.
+// A script without a seed gets one picked for both runs.
+// Returns the report of the first run, and a *DivergenceError naming the first entry that differs
+// if the runs did not spawn the same nodes, take the same steps with the same outcomes and end
+// with the same live nodes. Such a divergence points at nondeterminism the seed does not
+// cover, such as map iteration, an unseeded random source or a read of the wall clock.
+func CheckDeterminism(script *Script) (Report, error) {
+	seeded := *script
+	if seeded.Seed == 0 {
+		seeded.Seed = time.Now().UnixNano()
+	}
+	first, err := Run(&seeded)
+	if err != nil {
+		return Report{}, err
+	}
+	second, err := Run(&seeded)
+	if err != nil {
+		return first, err
+	}
+	if divergence := compareTraces(first.Trace, second.Trace); divergence != nil {
+		divergence.Seed = seeded.Seed
+		return first, divergence
+	}
+	return first, nil
+}
+
+// Returns the first entry the traces differ at, nil if they are equal.
+func compareTraces(first Trace, second Trace) *DivergenceError {
+	for i := range max(len(first), len(second)) {
+		var a, b string
+		if i < len(first) {
+			a = first[i]
+		}
+		if i < len(second) {
+			b = second[i]
+		}
+		if i >= len(first) || i >= len(second) || a != b {
+			return &DivergenceError{Index: i, First: a, Second: b}
+		}
+	}
+	return nil
+}
+
+// Appends the spawns and shutdowns published since the last call to the trace.
+func (r *run) traceEvents(events <-chan kademlia.Event) {
+	for len(events) > 0 {
+		switch event := (<-events).(type) {
+		case kademlia.NodeSpawned:
+			r.trace = append(r.trace, fmt.Sprintf("spawned %v at %v", event.Node.ID(), event.Node.IP()))
+		case kademlia.NodeShutdown:
+			r.trace = append(r.trace, fmt.Sprintf("shut down %v", event.Node.ID()))
+		}
+	}
+}
+
+// Appends the live nodes and the final metrics to the trace.
+func (r *run) traceState(metrics map[string]float64) {
+	nodes := r.simnet.AllNodePointers()
+	slices.SortFunc(nodes, func(a *kademlia.Node, b *kademlia.Node) int { return a.ID().Cmp(b.ID()) })
+	for _, n := range nodes {
+		r.trace = append(r.trace, fmt.Sprintf("live %v at %v", n.ID(), n.IP()))
+	}
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		r.trace = append(r.trace, fmt.Sprintf("%s: %.4f", name, metrics[name]))
+	}
+}
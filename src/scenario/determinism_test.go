@@ -0,0 +1,82 @@
+package scenario
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+const seededScript = `{
+	"name": "seeded",
+	"seed": 42,
+	"steps": [
+		{"at": 0, "action": "spawn", "count": 8},
+		{"at": 0, "action": "link", "count": 2, "percent": 20},
+		{"at": 0, "action": "store", "count": 3},
+		{"at": 0, "action": "lookup", "count": 10},
+		{"at": "100ms", "action": "kill", "count": 2},
+		{"at": "100ms", "action": "fetch"},
+		{"at": "100ms", "action": "spawn", "count": 2}
+	]
+}`
+
+func TestCheckDeterminism(t *testing.T) {
+	testName := "TestCheckDeterminism"
+	script, err := Load(strings.NewReader(seededScript))
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	report, err := CheckDeterminism(script)
+	if err != nil {
+		log.Printf("[%s] - seeded runs diverged: %s", testName, err.Error())
+		t.FailNow()
+	}
+	if report.Seed != 42 {
+		log.Printf("[%s] - expected the report of seed 42, got %d", testName, report.Seed)
+		t.Fail()
+	}
+	// The master node, ten spawned and two shut down nodes, seven steps, nine live nodes and the metrics.
+	if len(report.Trace) < 1+10+2+7+9 {
+		log.Printf("[%s] - trace too short, got %d entries:\n%s", testName, len(report.Trace), strings.Join(report.Trace, "\n"))
+		t.Fail()
+	}
+}
+
+func TestCompareTraces(t *testing.T) {
+	testName := "TestCompareTraces"
+	if divergence := compareTraces(Trace{"a", "b"}, Trace{"a", "b"}); divergence != nil {
+		log.Printf("[%s] - equal traces diverged: %s", testName, divergence.Error())
+		t.Fail()
+	}
+	divergence := compareTraces(Trace{"a", "b", "c"}, Trace{"a", "x", "c"})
+	if divergence == nil || divergence.Index != 1 || divergence.First != "b" || divergence.Second != "x" {
+		log.Printf("[%s] - expected a divergence at entry 1, got %+v", testName, divergence)
+		t.Fail()
+	}
+	divergence = compareTraces(Trace{"a"}, Trace{"a", "b"})
+	if divergence == nil || divergence.Index != 1 || divergence.First != "" {
+		log.Printf("[%s] - expected the shorter trace to diverge at its end, got %+v", testName, divergence)
+		t.Fail()
+	}
+}
+
+func TestDifferentSeedsDiverge(t *testing.T) {
+	testName := "TestDifferentSeedsDiverge"
+	traces := make([]Trace, 0, 2)
+	for _, seed := range []int64{1, 2} {
+		script, _ := Load(strings.NewReader(`{"steps": [{"action": "spawn", "count": 3}]}`))
+		script.Seed = seed
+		report, err := Run(script)
+		if err != nil {
+			log.Printf("[%s] - %s", testName, err.Error())
+			t.FailNow()
+		}
+		traces = append(traces, report.Trace)
+	}
+	divergence := compareTraces(traces[0], traces[1])
+	if divergence == nil || divergence.Index != 0 {
+		log.Printf("[%s] - expected runs with different seeds to diverge at the first spawn, got %v", testName, divergence)
+		t.Fail()
+	}
+}
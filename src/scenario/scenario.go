@@ -0,0 +1,436 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"main/src/kademlia"
+	mrand "math/rand"
+	"os"
+	"slices"
+	"time"
+)
+
+// Actions a scenario step can take.
+const (
+	SPAWN  = "spawn"  // spawn Count nodes, the first spawn starts the cluster
+	KILL   = "kill"   // shut down Count nodes, or Percent of the live nodes, never the master node
+	STORE  = "store"  // store Count random values through random live nodes
+	FETCH  = "fetch"  // look up every stored value through random live nodes
+	LOOKUP = "lookup" // run Count node lookups between random pairs of live nodes
+	LINK   = "link"   // make Count links between random pairs of live nodes drop Percent of their RPCs
+	ASSERT = "assert" // compare Metric against Value using Op
+)
+
+// Metrics a scenario can assert on, the success rates cover every step of their kind so far.
+const (
+	NODES          = "nodes"          // live nodes, including the master node
+	LOOKUP_SUCCESS = "lookup_success" // share of lookups that found their target
+	STORE_SUCCESS  = "store_success"  // share of values stored by their replicas
+	FETCH_SUCCESS  = "fetch_success"  // share of fetches that returned the stored value
+)
+
+// A duration in a script, written as a Go duration string such as "1m30s" or a number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return errors.New(fmt.Sprintf("duration must be a string or a number of seconds: %s", string(data)))
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// A reproducible experiment: the simnet it runs on and the steps taken, in order of their time.
+type Script struct {
+	Name  string  `json:"name"`
+	Drop  float32 `json:"drop"`           // probability that the simnet drops a RPC
+	Seed  int64   `json:"seed,omitempty"` // seed of the node ids and random choices, 0 picks one
+	Steps []Step  `json:"steps"`
+}
+
+// A single action taken At its offset from the start of the scenario.
+type Step struct {
+	At      Duration `json:"at"`
+	Action  string   `json:"action"`
+	Count   int      `json:"count,omitempty"`
+	Percent float64  `json:"percent,omitempty"`
+	Metric  string   `json:"metric,omitempty"`
+	Op      string   `json:"op,omitempty"` // one of >, >=, <, <=, ==
+	Value   float64  `json:"value,omitempty"`
+}
+
+// Reads and validates a JSON script.
+func Load(r io.Reader) (*Script, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	script := &Script{}
+	if err := decoder.Decode(script); err != nil {
+		return nil, errors.New(fmt.Sprintf("malformed scenario: %s", err.Error()))
+	}
+	if err := script.Validate(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Reads and validates the JSON script at path.
+func LoadFile(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(bytes.NewReader(data))
+}
+
+// Returns an error describing the first invalid step, steps must be in order of their time and
+// the scenario must spawn nodes before anything else.
+func (script *Script) Validate() error {
+	if script.Drop < 0 || script.Drop > 1 {
+		return errors.New(fmt.Sprintf("drop must be between 0 and 1, got %v", script.Drop))
+	}
+	if len(script.Steps) == 0 || script.Steps[0].Action != SPAWN {
+		return errors.New("scenario must start by spawning nodes")
+	}
+	for i, step := range script.Steps {
+		if i > 0 && step.At < script.Steps[i-1].At {
+			return errors.New(fmt.Sprintf("step %d at %v comes before the step preceding it", i, time.Duration(step.At)))
+		}
+		if err := step.validate(); err != nil {
+			return errors.New(fmt.Sprintf("step %d: %s", i, err.Error()))
+		}
+	}
+	return nil
+}
+
+func (step Step) validate() error {
+	switch step.Action {
+	case SPAWN, STORE, LOOKUP:
+		if step.Count <= 0 {
+			return errors.New(fmt.Sprintf("%s needs a positive count", step.Action))
+		}
+	case KILL:
+		if (step.Count <= 0) == (step.Percent <= 0) {
+			return errors.New("kill needs either a positive count or a positive percent")
+		}
+		if step.Percent > 100 {
+			return errors.New("kill percent must not exceed 100")
+		}
+	case LINK:
+		if step.Count <= 0 || step.Percent <= 0 || step.Percent > 100 {
+			return errors.New("link needs a positive count and a percent between 0 and 100")
+		}
+	case FETCH:
+	case ASSERT:
+		if !slices.Contains([]string{NODES, LOOKUP_SUCCESS, STORE_SUCCESS, FETCH_SUCCESS}, step.Metric) {
+			return errors.New(fmt.Sprintf("unknown metric %q", step.Metric))
+		}
+		if !slices.Contains([]string{">", ">=", "<", "<=", "=="}, step.Op) {
+			return errors.New(fmt.Sprintf("unknown comparison %q", step.Op))
+		}
+	default:
+		return errors.New(fmt.Sprintf("unknown action %q", step.Action))
+	}
+	return nil
+}
+
+// Outcome of a single step.
+type StepResult struct {
+	Step
+	Started time.Duration // offset from the start of the scenario
+	Elapsed time.Duration
+	Detail  string
+	Failed  bool
+}
+
+// Outcome of a scenario, Passed is false if any assertion failed.
+type Report struct {
+	Name     string
+	Seed     int64 // rerunning the script with this seed spawns the same nodes and makes the same choices
+	Duration time.Duration
+	Steps    []StepResult
+	Metrics  map[string]float64 // value of every metric at the end of the scenario
+	Trace    Trace
+	Passed   bool
+}
+
+func (report Report) Display() string {
+	res := fmt.Sprintf("scenario %q with seed %d ran for %v, passed: %v\n", report.Name, report.Seed, report.Duration.Round(time.Millisecond), report.Passed)
+	for _, step := range report.Steps {
+		mark := "ok"
+		if step.Failed {
+			mark = "FAILED"
+		}
+		res += fmt.Sprintf("%10v %-7s %-6s %s\n", step.Started.Round(time.Millisecond), step.Action, mark, step.Detail)
+	}
+	metrics := make([]string, 0, len(report.Metrics))
+	for name := range report.Metrics {
+		metrics = append(metrics, name)
+	}
+	slices.Sort(metrics)
+	for _, name := range metrics {
+		res += fmt.Sprintf("%s: %.4f\n", name, report.Metrics[name])
+	}
+	return res
+}
+
+// Running totals of a scenario's operations.
+type tally struct {
+	attempts  map[string]int
+	successes map[string]int
+}
+
+func (t tally) add(metric string, attempts int, successes int) {
+	t.attempts[metric] += attempts
+	t.successes[metric] += successes
+}
+
+func (t tally) rate(metric string) float64 {
+	if t.attempts[metric] == 0 {
+		return 0
+	}
+	return float64(t.successes[metric]) / float64(t.attempts[metric])
+}
+
+// State of a running scenario.
+type run struct {
+	simnet *kademlia.Simnet
+	rng    *mrand.Rand
+	stored map[kademlia.KademliaID][]byte
+	keys   []kademlia.KademliaID // stored keys in the order they were stored
+	tally  tally
+	trace  Trace
+}
+
+// Runs the script on a fresh simnet, which is shut down when the scenario ends.
+// Steps are taken at their offsets from the start, a step that runs late delays the ones after it.
+// Returns an error if the script is invalid, failed assertions are reported in the report.
+func Run(script *Script) (Report, error) {
+	if err := script.Validate(); err != nil {
+		return Report{}, err
+	}
+	seed := script.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s := kademlia.NewSeededServer(false, script.Drop, seed)
+	s.Silence()
+	events, cancel := s.Events().Subscribe(1 << 16)
+	defer cancel()
+	go s.StartServer()
+	defer s.Shutdown()
+
+	r := &run{
+		simnet: s,
+		rng:    mrand.New(mrand.NewSource(seed)),
+		stored: make(map[kademlia.KademliaID][]byte),
+		keys:   make([]kademlia.KademliaID, 0),
+		tally:  tally{make(map[string]int), make(map[string]int)},
+		trace:  make(Trace, 0),
+	}
+	report := Report{Name: script.Name, Seed: seed, Steps: make([]StepResult, 0, len(script.Steps)), Passed: true}
+	start := time.Now()
+	for i, step := range script.Steps {
+		if wait := time.Duration(step.At) - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+		result := StepResult{Step: step, Started: time.Since(start)}
+		result.Detail, result.Failed = r.take(step, i == 0)
+		result.Elapsed = time.Since(start) - result.Started
+		r.traceEvents(events)
+		r.trace = append(r.trace, fmt.Sprintf("step %d %s: %s", i, step.Action, result.Detail))
+		if result.Failed {
+			report.Passed = false
+		}
+		report.Steps = append(report.Steps, result)
+	}
+	report.Duration = time.Since(start)
+	report.Metrics = map[string]float64{NODES: float64(len(s.AllNodePointers()))}
+	for _, metric := range []string{LOOKUP_SUCCESS, STORE_SUCCESS, FETCH_SUCCESS} {
+		if r.tally.attempts[metric] > 0 {
+			report.Metrics[metric] = r.tally.rate(metric)
+		}
+	}
+	r.traceState(report.Metrics)
+	report.Trace = r.trace
+	return report, nil
+}
+
+// Takes the step, returns a description of its outcome and whether it failed.
+func (r *run) take(step Step, first bool) (string, bool) {
+	switch step.Action {
+	case SPAWN:
+		return r.spawn(step.Count, first), false
+	case KILL:
+		return r.kill(step), false
+	case STORE:
+		return r.store(step.Count), false
+	case FETCH:
+		return r.fetch(), false
+	case LOOKUP:
+		return r.lookup(step.Count), false
+	case LINK:
+		return r.link(step), false
+	default:
+		return r.assert(step)
+	}
+}
+
+func (r *run) spawn(count int, first bool) string {
+	if first {
+		done := make(chan struct{}, 1)
+		r.simnet.SpawnCluster(count, done)
+		<-done
+	} else {
+		joined := make(chan kademlia.KademliaID, count)
+		for range count {
+			r.simnet.SpawnNode(joined)
+		}
+		for range count {
+			<-joined
+		}
+	}
+	return fmt.Sprintf("%d nodes live", len(r.simnet.AllNodePointers()))
+}
+
+// Returns the live nodes other than the master node.
+func (r *run) members() []*kademlia.Node {
+	master := r.simnet.MasterNode()
+	res := make([]*kademlia.Node, 0)
+	for _, n := range r.simnet.AllNodePointers() {
+		if n.ID() != master.ID() {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func (r *run) kill(step Step) string {
+	members := r.members()
+	count := step.Count
+	if step.Percent > 0 {
+		count = int(float64(len(members)) * step.Percent / 100)
+	}
+	count = min(count, len(members))
+	r.rng.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	for _, n := range members[:count] {
+		r.simnet.ShutdownNode(n)
+	}
+	return fmt.Sprintf("killed %d nodes, %d live", count, len(r.simnet.AllNodePointers()))
+}
+
+// Returns a random live node, the master node only if no other node is live.
+func (r *run) random() *kademlia.Node {
+	members := r.members()
+	if len(members) == 0 {
+		return r.simnet.AllNodePointers()[0]
+	}
+	return members[r.rng.Intn(len(members))]
+}
+
+func (r *run) store(count int) string {
+	stored := 0
+	for range count {
+		data := make([]byte, 32)
+		r.rng.Read(data)
+		key, err := r.random().StoreValue(data)
+		if err == nil {
+			if _, ok := r.stored[key]; !ok {
+				r.keys = append(r.keys, key)
+			}
+			r.stored[key] = data
+			stored++
+		}
+	}
+	r.tally.add(STORE_SUCCESS, count, stored)
+	return fmt.Sprintf("stored %d of %d values", stored, count)
+}
+
+func (r *run) fetch() string {
+	fetched := 0
+	for _, key := range r.keys {
+		found, err := r.random().FindValue(key)
+		if err == nil && bytes.Equal(found, r.stored[key]) {
+			fetched++
+		}
+	}
+	r.tally.add(FETCH_SUCCESS, len(r.stored), fetched)
+	return fmt.Sprintf("fetched %d of %d values", fetched, len(r.stored))
+}
+
+func (r *run) lookup(count int) string {
+	members := r.members()
+	if len(members) < 2 {
+		return "fewer than two live nodes, no lookups"
+	}
+	found := 0
+	for range count {
+		from := members[r.rng.Intn(len(members))]
+		target := members[r.rng.Intn(len(members))]
+		for target == from {
+			target = members[r.rng.Intn(len(members))]
+		}
+		if slices.ContainsFunc(from.FindNode(target.ID()), func(con kademlia.Contact) bool { return con.ID() == target.ID() }) {
+			found++
+		}
+	}
+	r.tally.add(LOOKUP_SUCCESS, count, found)
+	return fmt.Sprintf("%d of %d lookups found their target", found, count)
+}
+
+// Makes the directed links between random pairs of live nodes lossy, see kademlia.LinkPolicy.
+func (r *run) link(step Step) string {
+	members := r.members()
+	if len(members) < 2 {
+		return "fewer than two live nodes, no links"
+	}
+	policy := kademlia.LinkPolicy{Drop: float32(step.Percent / 100)}
+	for range step.Count {
+		from := members[r.rng.Intn(len(members))]
+		to := members[r.rng.Intn(len(members))]
+		for to == from {
+			to = members[r.rng.Intn(len(members))]
+		}
+		r.simnet.SetLinkPolicy(from.IP(), to.IP(), policy)
+	}
+	return fmt.Sprintf("%d links drop %v%% of their RPCs", step.Count, step.Percent)
+}
+
+func (r *run) assert(step Step) (string, bool) {
+	var actual float64
+	if step.Metric == NODES {
+		actual = float64(len(r.simnet.AllNodePointers()))
+	} else {
+		actual = r.tally.rate(step.Metric)
+	}
+	var held bool
+	switch step.Op {
+	case ">":
+		held = actual > step.Value
+	case ">=":
+		held = actual >= step.Value
+	case "<":
+		held = actual < step.Value
+	case "<=":
+		held = actual <= step.Value
+	default:
+		held = actual == step.Value
+	}
+	return fmt.Sprintf("%s %s %v, actual %.4f", step.Metric, step.Op, step.Value, actual), !held
+}
@@ -0,0 +1,80 @@
+package scenario
+
+import (
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testScript = `{
+	"name": "kill a fifth",
+	"steps": [
+		{"at": 0, "action": "spawn", "count": 10},
+		{"at": "0s", "action": "store", "count": 5},
+		{"at": "100ms", "action": "lookup", "count": 20},
+		{"at": "200ms", "action": "kill", "percent": 20},
+		{"at": "200ms", "action": "fetch"},
+		{"at": "300ms", "action": "assert", "metric": "nodes", "op": "==", "value": 9},
+		{"at": "300ms", "action": "assert", "metric": "lookup_success", "op": ">=", "value": 0.9},
+		{"at": "300ms", "action": "assert", "metric": "store_success", "op": ">", "value": 1}
+	]
+}`
+
+func TestRunScenario(t *testing.T) {
+	testName := "TestRunScenario"
+	script, err := Load(strings.NewReader(testScript))
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	report, err := Run(script)
+	if err != nil {
+		log.Printf("[%s] - %s", testName, err.Error())
+		t.FailNow()
+	}
+	log.Printf("[%s] - report:\n%s", testName, report.Display())
+	if len(report.Steps) != len(script.Steps) {
+		log.Printf("[%s] - expected %d step results, got %d", testName, len(script.Steps), len(report.Steps))
+		t.FailNow()
+	}
+	for _, step := range report.Steps[:len(report.Steps)-1] {
+		if step.Failed {
+			log.Printf("[%s] - step %s failed: %s", testName, step.Action, step.Detail)
+			t.Fail()
+		}
+	}
+	// A success rate can never exceed one, so the last assertion fails the scenario.
+	if !report.Steps[len(report.Steps)-1].Failed || report.Passed {
+		log.Printf("[%s] - impossible assertion passed", testName)
+		t.Fail()
+	}
+	if report.Steps[3].Started < 200*time.Millisecond {
+		log.Printf("[%s] - kill ran at %v, before its time", testName, report.Steps[3].Started)
+		t.Fail()
+	}
+	if report.Metrics[NODES] != 9 {
+		log.Printf("[%s] - expected 9 live nodes at the end, got %v", testName, report.Metrics[NODES])
+		t.Fail()
+	}
+}
+
+func TestLoadInvalidScenario(t *testing.T) {
+	testName := "TestLoadInvalidScenario"
+	scripts := map[string]string{
+		"no spawn":       `{"steps": [{"action": "lookup", "count": 1}]}`,
+		"unknown action": `{"steps": [{"action": "spawn", "count": 1}, {"action": "explode"}]}`,
+		"out of order":   `{"steps": [{"at": "1s", "action": "spawn", "count": 1}, {"at": "0s", "action": "fetch"}]}`,
+		"bad metric":     `{"steps": [{"action": "spawn", "count": 1}, {"action": "assert", "metric": "speed", "op": ">"}]}`,
+		"bad kill":       `{"steps": [{"action": "spawn", "count": 1}, {"action": "kill", "count": 1, "percent": 5}]}`,
+		"bad link":       `{"steps": [{"action": "spawn", "count": 1}, {"action": "link", "count": 1, "percent": 150}]}`,
+		"unknown field":  `{"steps": [{"action": "spawn", "count": 1, "size": 3}]}`,
+		"bad duration":   `{"steps": [{"at": "soon", "action": "spawn", "count": 1}]}`,
+	}
+	for name, script := range scripts {
+		if _, err := Load(strings.NewReader(script)); err == nil {
+			log.Printf("[%s] - loaded invalid scenario: %s", testName, name)
+			t.Fail()
+		}
+	}
+}